@@ -64,6 +64,66 @@ func runBackupRawCommand(command *cobra.Command, cmdName string) error {
 	return nil
 }
 
+func runBackupCopyCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.BackupCopyConfig{Config: task.Config{LogProgress: HasLogFile()}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return errors.Trace(err)
+	}
+
+	ctx := GetDefaultContext()
+	if err := task.RunBackupCopy(ctx, gluetikv.Glue{}, cmdName, &cfg); err != nil {
+		log.Error("failed to copy backup", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+func runBackupExtractCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.BackupExtractConfig{Config: task.Config{LogProgress: HasLogFile()}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return errors.Trace(err)
+	}
+
+	ctx := GetDefaultContext()
+	if err := task.RunBackupExtract(ctx, gluetikv.Glue{}, cmdName, &cfg); err != nil {
+		log.Error("failed to extract backup", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+func runBackupMultiCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.MultiBackupConfig{BackupConfig: task.BackupConfig{Config: task.Config{LogProgress: HasLogFile()}}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return errors.Trace(err)
+	}
+
+	ctx := GetDefaultContext()
+	if err := task.RunMultiBackup(ctx, gluetikv.Glue{}, cmdName, &cfg); err != nil {
+		log.Error("failed to backup multiple clusters", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+func runBackupAgentCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.BackupAgentConfig{Config: task.Config{LogProgress: HasLogFile()}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return errors.Trace(err)
+	}
+
+	ctx := GetDefaultContext()
+	if err := task.RunBackupAgent(ctx, gluetikv.Glue{}, cmdName, &cfg); err != nil {
+		log.Error("backup agent failed", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 // NewBackupCommand return a full backup subcommand.
 func NewBackupCommand() *cobra.Command {
 	command := &cobra.Command{
@@ -89,7 +149,12 @@ func NewBackupCommand() *cobra.Command {
 		newFullBackupCommand(),
 		newDBBackupCommand(),
 		newTableBackupCommand(),
+		newSchemaBackupCommand(),
 		newRawBackupCommand(),
+		newCopyBackupCommand(),
+		newExtractBackupCommand(),
+		newMultiBackupCommand(),
+		newAgentBackupCommand(),
 	)
 
 	task.DefineBackupFlags(command.PersistentFlags())
@@ -141,6 +206,24 @@ func newTableBackupCommand() *cobra.Command {
 	return command
 }
 
+// newSchemaBackupCommand return a subcommand which backs up the schemas and
+// the stats of the tables without their data.
+func newSchemaBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "schema",
+		Short: "backup the schemas and the stats of the tables without their data",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			if err := command.Flags().Set(task.FlagSchemaOnly, "true"); err != nil {
+				return errors.Trace(err)
+			}
+			return runBackupCommand(command, "Schema backup")
+		},
+	}
+	task.DefineFilterFlags(command, acceptAllTables)
+	return command
+}
+
 // newRawBackupCommand return a raw kv range backup subcommand.
 func newRawBackupCommand() *cobra.Command {
 	// TODO: remove experimental tag if it's stable
@@ -156,3 +239,68 @@ func newRawBackupCommand() *cobra.Command {
 	task.DefineRawBackupFlags(command)
 	return command
 }
+
+// newCopyBackupCommand return a subcommand which copies an existing backup to
+// another storage.
+func newCopyBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "copy",
+		Short: "copy an existing backup to another storage",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runBackupCopyCommand(command, "Backup copy")
+		},
+	}
+
+	task.DefineBackupCopyFlags(command.Flags())
+	return command
+}
+
+// newExtractBackupCommand return a subcommand which extracts a table from an
+// existing backup into a standalone backup.
+func newExtractBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "extract",
+		Short: "extract a table from an existing backup into another storage",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runBackupExtractCommand(command, "Backup extract")
+		},
+	}
+
+	task.DefineTableFlags(command)
+	task.DefineBackupExtractFlags(command.Flags())
+	return command
+}
+
+// newMultiBackupCommand return a subcommand which backs up several clusters at
+// the same TSO.
+func newMultiBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "multi",
+		Short: "backup several clusters at the same TSO",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runBackupMultiCommand(command, "Multi-cluster backup")
+		},
+	}
+	task.DefineFilterFlags(command, acceptAllTables)
+	task.DefineMultiBackupFlags(command.Flags())
+	return command
+}
+
+// newAgentBackupCommand return a subcommand which serves the backup requests
+// dispatched by the coordinator br.
+func newAgentBackupCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "agent",
+		Short: "serve the backup requests of the stores in the same locality for the coordinator br",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runBackupAgentCommand(command, "Backup agent")
+		},
+	}
+
+	task.DefineBackupAgentFlags(command.Flags())
+	return command
+}
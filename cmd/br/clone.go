@@ -0,0 +1,67 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package main
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/ddl"
+	"github.com/pingcap/tidb/session"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"sourcegraph.com/sourcegraph/appdash"
+
+	"github.com/pingcap/br/pkg/task"
+	"github.com/pingcap/br/pkg/trace"
+	"github.com/pingcap/br/pkg/utils"
+	"github.com/pingcap/br/pkg/version/build"
+)
+
+func runCloneCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.CloneConfig{Config: task.Config{LogProgress: HasLogFile()}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return errors.Trace(err)
+	}
+
+	ctx := GetDefaultContext()
+	if cfg.EnableOpenTracing {
+		var store *appdash.MemoryStore
+		ctx, store = trace.TracerStartSpan(ctx)
+		defer trace.TracerFinishSpan(ctx, store)
+	}
+	if err := task.RunClone(ctx, tidbGlue, cmdName, &cfg); err != nil {
+		log.Error("failed to clone", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// NewCloneCommand returns a command which clones tables from a cluster into
+// another one.
+func NewCloneCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:          "clone",
+		Short:        "clone tables from a TiDB cluster into another one through the storage",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PreRunE: func(c *cobra.Command, args []string) error {
+			if err := Init(c); err != nil {
+				return errors.Trace(err)
+			}
+			build.LogInfo(build.BR)
+			utils.LogEnvVariables()
+			task.LogArguments(c)
+
+			// Do not run ddl worker and stats worker in BR.
+			ddl.RunWorker = false
+			session.DisableStats4Test()
+			return nil
+		},
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runCloneCommand(command, "Clone")
+		},
+	}
+	task.DefineCloneFlags(command.Flags())
+	return command
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"os"
 	"path"
 	"reflect"
 
@@ -17,9 +18,11 @@ import (
 	"github.com/pingcap/kvproto/pkg/import_sstpb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/ddl"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/pingcap/br/pkg/backup"
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/metautil"
@@ -54,6 +57,12 @@ func NewDebugCommand() *cobra.Command {
 	meta.AddCommand(decodeBackupMetaCommand())
 	meta.AddCommand(encodeBackupMetaCommand())
 	meta.AddCommand(setPDConfigCommand())
+	meta.AddCommand(newStorageBenchCommand())
+	meta.AddCommand(newIngestBenchCommand())
+	meta.AddCommand(newRangeCoverageCommand())
+	meta.AddCommand(newSpaceReportCommand())
+	meta.AddCommand(newFetchRegionInfoCommand())
+	meta.AddCommand(newBackupDiffCommand())
 	meta.Hidden = true
 
 	return meta
@@ -68,12 +77,15 @@ func newCheckSumCommand() *cobra.Command {
 			ctx, cancel := context.WithCancel(GetDefaultContext())
 			defer cancel()
 
-			var cfg task.Config
+			var cfg task.VerifyConfig
 			if err := cfg.ParseFromFlags(cmd.Flags()); err != nil {
 				return errors.Trace(err)
 			}
+			if cfg.Watch {
+				return errors.Trace(task.RunVerifyWatch(ctx, &cfg))
+			}
 
-			_, s, backupMeta, err := task.ReadBackupMeta(ctx, metautil.MetaFile, &cfg)
+			_, s, backupMeta, err := task.ReadBackupMeta(ctx, metautil.MetaFile, &cfg.Config)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -141,6 +153,7 @@ origin sha256 is %s`,
 			return nil
 		},
 	}
+	task.DefineVerifyFlags(command.Flags())
 	command.Hidden = true
 	return command
 }
@@ -262,15 +275,29 @@ func decodeBackupMetaCommand() *cobra.Command {
 			if err := cfg.ParseFromFlags(cmd.Flags()); err != nil {
 				return errors.Trace(err)
 			}
-			_, s, backupMeta, err := task.ReadBackupMeta(ctx, metautil.MetaFile, &cfg)
+			_, s, metaData, err := task.ReadRawBackupMeta(ctx, metautil.MetaFile, &cfg)
 			if err != nil {
 				return errors.Trace(err)
 			}
+			backupMeta := &backuppb.BackupMeta{}
+			if err = proto.Unmarshal(metaData, backupMeta); err != nil {
+				return errors.Annotate(err, "parse backupmeta failed")
+			}
 
 			fieldName, _ := cmd.Flags().GetString("field")
 			if fieldName == "" {
 				// No field flag, write backupmeta to external storage in JSON format.
-				backupMetaJSON, err := utils.MarshalBackupMeta(backupMeta)
+				// Keep the fields written by a newer BR, so that they survive
+				// the encode command.
+				unknown, err := metautil.UnknownFields(metaData, backupMeta)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				if len(unknown) > 0 {
+					log.Warn("backupmeta has fields unknown to this BR, they are kept as is",
+						zap.String("brVersion", backupMeta.GetBrVersion()), zap.Int("size", len(unknown)))
+				}
+				backupMetaJSON, err := utils.MarshalBackupMetaWithUnknownFields(backupMeta, unknown)
 				if err != nil {
 					return errors.Trace(err)
 				}
@@ -333,11 +360,11 @@ func encodeBackupMetaCommand() *cobra.Command {
 				return errors.Trace(err)
 			}
 
-			backupMetaJSON, err := utils.UnmarshalBackupMeta(metaData)
+			backupMetaJSON, unknown, err := utils.UnmarshalBackupMetaWithUnknownFields(metaData)
 			if err != nil {
 				return errors.Trace(err)
 			}
-			backupMeta, err := proto.Marshal(backupMetaJSON)
+			backupMeta, err := metautil.MarshalWithUnknownFields(backupMetaJSON, unknown)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -371,7 +398,7 @@ func setPDConfigCommand() *cobra.Command {
 				return errors.Trace(err)
 			}
 
-			mgr, err := task.NewMgr(ctx, tidbGlue, cfg.PD, cfg.TLS, task.GetKeepalive(&cfg), cfg.CheckRequirements, false)
+			mgr, err := task.NewMgr(ctx, tidbGlue, cfg.PD, cfg.TLS, task.GetKeepalive(&cfg), cfg.GRPC, cfg.CheckRequirements, false)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -386,3 +413,295 @@ func setPDConfigCommand() *cobra.Command {
 	}
 	return pdConfigCmd
 }
+
+func newStorageBenchCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "storage-bench",
+		Short: "benchmark writing and reading the external storage",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			var cfg task.StorageBenchConfig
+			if err := cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				return errors.Trace(err)
+			}
+			phases, err := task.RunStorageBench(ctx, &cfg)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			for _, phase := range phases {
+				cmd.Println(phase.String())
+			}
+			return nil
+		},
+	}
+	task.DefineStorageBenchFlags(command.Flags())
+	return command
+}
+
+func newIngestBenchCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "ingest-bench",
+		Short: "benchmark restoring synthetic SST files into disposable tables",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			var cfg task.IngestBenchConfig
+			if err := cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				return errors.Trace(err)
+			}
+			result, err := task.RunIngestBench(ctx, tidbGlue, &cfg)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			cmd.Println(result.String())
+			return nil
+		},
+	}
+	task.DefineIngestBenchFlags(command.Flags())
+	return command
+}
+
+func newRangeCoverageCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "range-coverage",
+		Short: "export how the backup files cover the key ranges of the tables as JSON or SVG",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if format != "json" && format != "svg" {
+				return errors.Annotatef(berrors.ErrInvalidArgument, "unknown format %s, must be json or svg", format)
+			}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return errors.Trace(err)
+			}
+			withSplit, err := cmd.Flags().GetBool("split-ranges")
+			if err != nil {
+				return errors.Trace(err)
+			}
+
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				return errors.Trace(err)
+			}
+			_, s, backupMeta, err := task.ReadBackupMeta(ctx, metautil.MetaFile, &cfg)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			reader := metautil.NewMetaReader(backupMeta, s)
+			dbs, err := utils.LoadBackupTables(ctx, reader)
+			if err != nil {
+				return errors.Trace(err)
+			}
+
+			coverages := make([]rtree.TableCoverage, 0)
+			for _, db := range dbs {
+				for _, table := range db.Tables {
+					if table.Info == nil {
+						continue
+					}
+					keyRanges, err := backup.BuildTableRanges(table.Info)
+					if err != nil {
+						return errors.Trace(err)
+					}
+					expected := make([]rtree.Range, 0, len(keyRanges))
+					for _, r := range keyRanges {
+						expected = append(expected, rtree.Range{StartKey: r.StartKey, EndKey: r.EndKey})
+					}
+					coverage := rtree.NewTableCoverage(
+						utils.EncloseDBAndTable(db.Info.Name.O, table.Info.Name.O), expected, table.Files)
+					if withSplit {
+						splitRanges, _, err := restore.MergeFileRanges(
+							table.Files, restore.DefaultMergeRegionSizeBytes, restore.DefaultMergeRegionKeyCount)
+						if err != nil {
+							return errors.Trace(err)
+						}
+						coverage.SetSplitRanges(splitRanges)
+					}
+					if coverage.Gaps > 0 || coverage.Overlaps > 0 {
+						log.Warn("table range coverage is incomplete", zap.String("table", coverage.Name),
+							zap.Int("gaps", coverage.Gaps), zap.Int("overlaps", coverage.Overlaps))
+					}
+					coverages = append(coverages, coverage)
+				}
+			}
+
+			w := cmd.OutOrStdout()
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				defer f.Close()
+				w = f
+			}
+			if format == "svg" {
+				return errors.Trace(rtree.WriteCoverageSVG(w, coverages))
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return errors.Trace(enc.Encode(coverages))
+		},
+	}
+	command.Flags().String("format", "json", "the format of the coverage, json or svg")
+	command.Flags().String("output", "", "the local file to write the coverage to, stdout if empty")
+	command.Flags().Bool("split-ranges", true, "whether to export the ranges restore splits the regions by as well")
+	return command
+}
+
+func newSpaceReportCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "space-report",
+		Short: "report the regions, approximate size and replica placement of the tables as JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return errors.Trace(err)
+			}
+			var cfg task.SpaceReportConfig
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				return errors.Trace(err)
+			}
+			// Do not run ddl worker in BR.
+			ddl.RunWorker = false
+
+			w := cmd.OutOrStdout()
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				defer f.Close()
+				w = f
+			}
+			return task.RunSpaceReport(GetDefaultContext(), tidbGlue, &cfg, w)
+		},
+	}
+	task.DefineFilterFlags(command, acceptAllTables)
+	task.DefineSpaceReportFlags(command.Flags())
+	command.Flags().String("output", "", "the local file to write the report to, stdout if empty")
+	return command
+}
+
+func newFetchRegionInfoCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "fetch-region-info",
+		Short: "dump the regions covering the tables or a key range, with their states and operators, as JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return errors.Trace(err)
+			}
+			var cfg task.RegionInfoConfig
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				return errors.Trace(err)
+			}
+			// Do not run ddl worker in BR.
+			ddl.RunWorker = false
+
+			w := cmd.OutOrStdout()
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				defer f.Close()
+				w = f
+			}
+			return task.RunFetchRegionInfo(GetDefaultContext(), tidbGlue, &cfg, w)
+		},
+	}
+	task.DefineFilterFlags(command, acceptAllTables)
+	task.DefineRegionInfoFlags(command.Flags())
+	command.Flags().String("output", "", "the local file to write the regions to, stdout if empty")
+	return command
+}
+
+// loadBackupTables reads the backup meta and the tables of the backup in the
+// storage.
+func loadBackupTables(
+	ctx context.Context, cfg task.Config, storage string,
+) (*backuppb.BackupMeta, []*metautil.Table, error) {
+	cfg.Storage = storage
+	_, s, backupMeta, err := task.ReadBackupMeta(ctx, metautil.MetaFile, &cfg)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	dbs, err := utils.LoadBackupTables(ctx, metautil.NewMetaReader(backupMeta, s))
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	tables := make([]*metautil.Table, 0)
+	for _, db := range dbs {
+		tables = append(tables, db.Tables...)
+	}
+	return backupMeta, tables, nil
+}
+
+func newBackupDiffCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "diff <storageA> <storageB>",
+		Short: "compare the tables, sizes, checksums and TS of two backups as JSON",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithCancel(GetDefaultContext())
+			defer cancel()
+
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return errors.Trace(err)
+			}
+			var cfg task.Config
+			if err = cfg.ParseFromFlags(cmd.Flags()); err != nil {
+				return errors.Trace(err)
+			}
+			metaA, tablesA, err := loadBackupTables(ctx, cfg, args[0])
+			if err != nil {
+				return errors.Trace(err)
+			}
+			metaB, tablesB, err := loadBackupTables(ctx, cfg, args[1])
+			if err != nil {
+				return errors.Trace(err)
+			}
+
+			diff := metautil.DiffBackups(metaA, tablesA, metaB, tablesB)
+			for _, td := range diff.Tables {
+				if td.Status == metautil.TableShrunk || td.Status == metautil.TableRemoved {
+					log.Warn("table shrunk in the backup B", zap.String("table", td.Name),
+						zap.String("status", td.Status), zap.Uint64("kvsA", td.KvsA), zap.Uint64("kvsB", td.KvsB))
+				}
+			}
+			log.Info("backups compared", zap.Duration("tsGap", diff.TSGap),
+				zap.Int("added", diff.Added), zap.Int("removed", diff.Removed),
+				zap.Int("shrunk", diff.Shrunk), zap.Int("changed", diff.Changed))
+
+			w := cmd.OutOrStdout()
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				defer f.Close()
+				w = f
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return errors.Trace(enc.Encode(diff))
+		},
+	}
+	command.Flags().String("output", "", "the local file to write the diff to, stdout if empty")
+	return command
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/pingcap/log"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
 )
 
 func main() {
@@ -48,6 +50,7 @@ func main() {
 		NewDebugCommand(),
 		NewBackupCommand(),
 		NewRestoreCommand(),
+		NewCloneCommand(),
 	)
 	// Ouputs cmd.Print to stdout.
 	rootCmd.SetOut(os.Stdout)
@@ -55,7 +58,11 @@ func main() {
 	rootCmd.SetArgs(os.Args[1:])
 	if err := rootCmd.Execute(); err != nil {
 		cancel()
-		log.Error("br failed", zap.Error(err))
-		os.Exit(1) // nolint:gocritic
+		// the orchestration tools tell the type of the failure by the exit
+		// code or the error.code field, see berrors.ErrorClass.
+		class := berrors.ClassOf(err)
+		log.Error("br failed", zap.Error(err),
+			zap.String("error.code", class.Code), zap.Int("exit-code", class.ExitCode))
+		os.Exit(class.ExitCode) // nolint:gocritic
 	}
 }
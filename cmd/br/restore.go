@@ -38,6 +38,26 @@ func runRestoreCommand(command *cobra.Command, cmdName string) error {
 	return nil
 }
 
+func runSnapshotTableRestoreCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.SnapshotTableConfig{RestoreConfig: task.RestoreConfig{Config: task.Config{LogProgress: HasLogFile()}}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return errors.Trace(err)
+	}
+
+	ctx := GetDefaultContext()
+	if cfg.EnableOpenTracing {
+		var store *appdash.MemoryStore
+		ctx, store = trace.TracerStartSpan(ctx)
+		defer trace.TracerFinishSpan(ctx, store)
+	}
+	if err := task.RunSnapshotTableRestore(ctx, tidbGlue, cmdName, &cfg); err != nil {
+		log.Error("failed to restore snapshot table", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 func runLogRestoreCommand(command *cobra.Command) error {
 	cfg := task.LogRestoreConfig{Config: task.Config{LogProgress: HasLogFile()}}
 	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
@@ -80,6 +100,21 @@ func runRestoreRawCommand(command *cobra.Command, cmdName string) error {
 	return nil
 }
 
+func runRestoreAgentCommand(command *cobra.Command, cmdName string) error {
+	cfg := task.RestoreAgentConfig{RestoreConfig: task.RestoreConfig{Config: task.Config{LogProgress: HasLogFile()}}}
+	if err := cfg.ParseFromFlags(command.Flags()); err != nil {
+		command.SilenceUsage = false
+		return errors.Trace(err)
+	}
+
+	ctx := GetDefaultContext()
+	if err := task.RunRestoreAgent(ctx, tidbGlue, cmdName, &cfg); err != nil {
+		log.Error("restore agent failed", zap.Error(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 // NewRestoreCommand returns a restore subcommand.
 func NewRestoreCommand() *cobra.Command {
 	command := &cobra.Command{
@@ -103,8 +138,10 @@ func NewRestoreCommand() *cobra.Command {
 		newFullRestoreCommand(),
 		newDBRestoreCommand(),
 		newTableRestoreCommand(),
+		newSnapshotTableRestoreCommand(),
 		newLogRestoreCommand(),
 		newRawRestoreCommand(),
+		newAgentRestoreCommand(),
 	)
 	task.DefineRestoreFlags(command.PersistentFlags())
 
@@ -150,6 +187,22 @@ func newTableRestoreCommand() *cobra.Command {
 	return command
 }
 
+func newSnapshotTableRestoreCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "snapshot-table",
+		Short: "restore a table as it was at a historical TS into a new table",
+		Long: "read the table at the historical TS from the cluster into the storage, " +
+			"and restore it from there into a new table. The storage must be empty, " +
+			"and the TS must not be older than the GC safe point.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runSnapshotTableRestoreCommand(cmd, "Snapshot table restore")
+		},
+	}
+	task.DefineSnapshotTableFlags(command)
+	return command
+}
+
 func newLogRestoreCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   "cdclog",
@@ -177,3 +230,19 @@ func newRawRestoreCommand() *cobra.Command {
 	task.DefineRawRestoreFlags(command)
 	return command
 }
+
+// newAgentRestoreCommand return a subcommand which restores the partitions of
+// the tables claimed from the coordinator br.
+func newAgentRestoreCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "agent",
+		Short: "restore the partitions of the tables claimed from the coordinator br",
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runRestoreAgentCommand(command, "Restore agent")
+		},
+	}
+
+	task.DefineRestoreAgentFlags(command.Flags())
+	return command
+}
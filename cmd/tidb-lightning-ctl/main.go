@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pingcap/errors"
@@ -32,6 +33,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/mydump"
 	"github.com/pingcap/br/pkg/lightning/restore"
 	"github.com/pingcap/br/pkg/lightning/tikv"
 )
@@ -51,7 +53,11 @@ func run() error {
 		compact, flagFetchMode                      *bool
 		mode, flagImportEngine, flagCleanupEngine   *string
 		cpRemove, cpErrIgnore, cpErrDestroy, cpDump *string
-		localStoringTables                          *bool
+		localStoringTables, flagCleanupEngines      *bool
+
+		cpDumpJSON, cpMark, cpRemoveChunk, cpRewritePath *string
+		cpTable, cpChunk                                 *string
+		cpEngine                                         *int
 
 		fsUsage func()
 	)
@@ -75,8 +81,16 @@ func run() error {
 		cpErrIgnore = fs.String("checkpoint-error-ignore", "", "ignore errors encoutered previously on the given table (value can be 'all' or '`db`.`table`'); may corrupt this table if used incorrectly")
 		cpErrDestroy = fs.String("checkpoint-error-destroy", "", "deletes imported data with table which has an error before (value can be 'all' or '`db`.`table`')")
 		cpDump = fs.String("checkpoint-dump", "", "dump the checkpoint information as two CSV files in the given folder")
+		cpDumpJSON = fs.String("checkpoint-dump-json", "", "print the checkpoints of the task and the given table as JSON (value can be 'all' or '`db`.`table`')")
+		cpMark = fs.String("checkpoint-mark", "", "mark the chunks of -checkpoint-table, -checkpoint-engine and (optionally) -checkpoint-chunk as done or dirty (value can be 'done' or 'dirty')")
+		cpRemoveChunk = fs.String("checkpoint-remove-chunk", "", "remove the checkpoint of a chunk of -checkpoint-table and -checkpoint-engine, so it is never imported (value is 'path:offset')")
+		cpRewritePath = fs.String("checkpoint-rewrite-path", "", "replace the prefix of the source dir and chunk paths in the checkpoints, e.g. after moving the data source (value is 'old-prefix=new-prefix')")
+		cpTable = fs.String("checkpoint-table", "", "the table edited by -checkpoint-mark and -checkpoint-remove-chunk (value is '`db`.`table`')")
+		cpEngine = fs.Int("checkpoint-engine", 0, "the engine ID edited by -checkpoint-mark and -checkpoint-remove-chunk")
+		cpChunk = fs.String("checkpoint-chunk", "", "the chunk edited by -checkpoint-mark (value is 'path:offset'); all chunks of the engine if empty")
 
 		localStoringTables = fs.Bool("check-local-storage", false, "show tables that are missing local intermediate files (value can be 'all' or '`db`.`table`')")
+		flagCleanupEngines = fs.Bool("cleanup-engines", false, "remove local engines which are not referenced by any checkpoint and older than tikv-importer.engine-ttl")
 
 		fsUsage = fs.Usage
 	}))
@@ -127,9 +141,24 @@ func run() error {
 	if len(*cpDump) != 0 {
 		return errors.Trace(checkpointDump(ctx, cfg, *cpDump))
 	}
+	if len(*cpDumpJSON) != 0 {
+		return errors.Trace(checkpointDumpJSON(ctx, cfg, *cpDumpJSON))
+	}
+	if len(*cpMark) != 0 {
+		return errors.Trace(checkpointMark(ctx, cfg, *cpMark, *cpTable, int32(*cpEngine), *cpChunk))
+	}
+	if len(*cpRemoveChunk) != 0 {
+		return errors.Trace(checkpointRemoveChunk(ctx, cfg, *cpTable, int32(*cpEngine), *cpRemoveChunk))
+	}
+	if len(*cpRewritePath) != 0 {
+		return errors.Trace(checkpointRewritePath(ctx, cfg, *cpRewritePath))
+	}
 	if *localStoringTables {
 		return errors.Trace(getLocalStoringTables(ctx, cfg))
 	}
+	if *flagCleanupEngines {
+		return errors.Trace(cleanupOrphanEngines(ctx, cfg))
+	}
 
 	fsUsage()
 	return nil
@@ -234,7 +263,7 @@ func checkpointErrorDestroy(ctx context.Context, cfg *config.Config, tls *common
 	}
 
 	if cfg.TikvImporter.Backend == "importer" {
-		importer, err := importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr)
+		importer, err := importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr, backend.NewBackendConfig(cfg))
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -295,7 +324,7 @@ func checkpointDump(ctx context.Context, cfg *config.Config, dumpFolder string)
 	defer tablesFile.Close()
 
 	enginesFileName := filepath.Join(dumpFolder, "engines.csv")
-	enginesFile, err := os.Create(tablesFileName)
+	enginesFile, err := os.Create(enginesFileName)
 	if err != nil {
 		return errors.Annotatef(err, "failed to create %s", enginesFileName)
 	}
@@ -320,6 +349,114 @@ func checkpointDump(ctx context.Context, cfg *config.Config, dumpFolder string)
 	return nil
 }
 
+func openCheckpointsEditor(ctx context.Context, cfg *config.Config) (checkpoints.Editor, error) {
+	cpdb, err := checkpoints.OpenCheckpointsDB(ctx, cfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	editor, ok := cpdb.(checkpoints.Editor)
+	if !ok {
+		cpdb.Close()
+		return nil, errors.Errorf("checkpoints of driver %s cannot be edited, checkpoints may be disabled", cfg.Checkpoint.Driver)
+	}
+	return editor, nil
+}
+
+// parseChunkKey parses the chunk key in the form of 'path:offset'. The path
+// may contain ':', so the key is split at the last one.
+func parseChunkKey(chunk string) (checkpoints.ChunkCheckpointKey, error) {
+	index := strings.LastIndexByte(chunk, ':')
+	if index < 0 {
+		return checkpoints.ChunkCheckpointKey{}, errors.Errorf("invalid chunk %s, must be 'path:offset'", chunk)
+	}
+	offset, err := strconv.ParseInt(chunk[index+1:], 10, 64)
+	if err != nil {
+		return checkpoints.ChunkCheckpointKey{}, errors.Annotatef(err, "invalid chunk %s, must be 'path:offset'", chunk)
+	}
+	return checkpoints.ChunkCheckpointKey{Path: chunk[:index], Offset: offset}, nil
+}
+
+func checkpointDumpJSON(ctx context.Context, cfg *config.Config, tableName string) error {
+	editor, err := openCheckpointsEditor(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer editor.Close()
+
+	return errors.Trace(checkpoints.DumpJSON(ctx, editor, tableName, os.Stdout))
+}
+
+func checkpointMark(ctx context.Context, cfg *config.Config, mark, tableName string, engineID int32, chunk string) error {
+	if mark != "done" && mark != "dirty" {
+		return errors.Errorf("invalid mark %s, must be 'done' or 'dirty'", mark)
+	}
+	done := mark == "done"
+	if len(tableName) == 0 {
+		return errors.New("-checkpoint-table must be specified")
+	}
+
+	editor, err := openCheckpointsEditor(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer editor.Close()
+
+	if len(chunk) == 0 {
+		if err := checkpoints.MarkEngine(ctx, editor, tableName, engineID, done); err != nil {
+			return errors.Trace(err)
+		}
+		fmt.Fprintf(os.Stderr, "Marked engine %s:%d as %s\n", tableName, engineID, mark)
+		return nil
+	}
+	key, err := parseChunkKey(chunk)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := checkpoints.MarkChunk(ctx, editor, tableName, engineID, key, done); err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Fprintf(os.Stderr, "Marked chunk %s in engine %s:%d as %s\n", chunk, tableName, engineID, mark)
+	return nil
+}
+
+func checkpointRemoveChunk(ctx context.Context, cfg *config.Config, tableName string, engineID int32, chunk string) error {
+	if len(tableName) == 0 {
+		return errors.New("-checkpoint-table must be specified")
+	}
+	key, err := parseChunkKey(chunk)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	editor, err := openCheckpointsEditor(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer editor.Close()
+
+	return errors.Trace(editor.RemoveChunk(ctx, tableName, engineID, key))
+}
+
+func checkpointRewritePath(ctx context.Context, cfg *config.Config, rewrite string) error {
+	index := strings.IndexByte(rewrite, '=')
+	if index <= 0 {
+		return errors.Errorf("invalid path rewrite %s, must be 'old-prefix=new-prefix'", rewrite)
+	}
+
+	editor, err := openCheckpointsEditor(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer editor.Close()
+
+	rewritten, err := editor.RewritePaths(ctx, rewrite[:index], rewrite[index+1:])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Fprintln(os.Stderr, "Rewritten chunks:", rewritten)
+	return nil
+}
+
 func getLocalStoringTables(ctx context.Context, cfg *config.Config) (err2 error) {
 	//nolint:prealloc // This is a placeholder.
 	var tables []string
@@ -361,6 +498,43 @@ func getLocalStoringTables(ctx context.Context, cfg *config.Config) (err2 error)
 	return nil
 }
 
+func cleanupOrphanEngines(ctx context.Context, cfg *config.Config) error {
+	if cfg.TikvImporter.Backend != config.BackendLocal {
+		return errors.Errorf("cleanup-engines only supports the local backend, but the backend is %s", cfg.TikvImporter.Backend)
+	}
+	// the index engine of a table is referenced before any of its chunks is
+	// read, so the tables must be listed from the data source.
+	loader, err := mydump.NewMyDumpLoader(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var tableNames []string
+	for _, dbMeta := range loader.GetDatabases() {
+		for _, tableMeta := range dbMeta.Tables {
+			tableNames = append(tableNames, common.UniqueTable(dbMeta.Name, tableMeta.Name))
+		}
+	}
+
+	cpdb, err := checkpoints.OpenCheckpointsDB(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer cpdb.Close()
+
+	referenced, err := local.ReferencedEngines(ctx, cpdb, tableNames)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	removed, err := local.CleanupOrphanEngines(cfg.TikvImporter.SortedKVDir, referenced, cfg.TikvImporter.EngineTTL.Duration)
+	for _, engine := range removed {
+		fmt.Fprintln(os.Stderr, "Removed engine:", engine.UUID, engine.TableName, engine.CreatedAt.Format(time.RFC3339))
+	}
+	if len(removed) == 0 && err == nil {
+		fmt.Fprintln(os.Stderr, "No orphan engine found")
+	}
+	return errors.Trace(err)
+}
+
 func unsafeCloseEngine(ctx context.Context, importer backend.Backend, engine string) (*backend.ClosedEngine, error) {
 	if index := strings.LastIndexByte(engine, ':'); index >= 0 {
 		tableName := engine[:index]
@@ -382,7 +556,7 @@ func unsafeCloseEngine(ctx context.Context, importer backend.Backend, engine str
 }
 
 func importEngine(ctx context.Context, cfg *config.Config, tls *common.TLS, engine string) error {
-	importer, err := importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr)
+	importer, err := importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr, backend.NewBackendConfig(cfg))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -396,7 +570,7 @@ func importEngine(ctx context.Context, cfg *config.Config, tls *common.TLS, engi
 }
 
 func cleanupEngine(ctx context.Context, cfg *config.Config, tls *common.TLS, engine string) error {
-	importer, err := importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr)
+	importer, err := importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr, backend.NewBackendConfig(cfg))
 	if err != nil {
 		return errors.Trace(err)
 	}
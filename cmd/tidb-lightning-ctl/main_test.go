@@ -14,9 +14,16 @@
 package main
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/pingcap/br/pkg/lightning/backend"
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/config"
 )
 
 func TestRunMain(_ *testing.T) {
@@ -45,3 +52,67 @@ func TestRunMain(_ *testing.T) {
 
 	<-waitCh
 }
+
+func TestCleanupOrphanEnginesKeepsIndexEngine(t *testing.T) {
+	ctx := context.Background()
+	sourceDir := t.TempDir()
+	files := map[string]string{
+		"db-schema-create.sql": "CREATE DATABASE db;",
+		"db.t-schema.sql":      "CREATE TABLE t (a INT);",
+		"db.t.sql":             "INSERT INTO t VALUES (1);",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.NewConfig()
+	cfg.TikvImporter.Backend = config.BackendLocal
+	cfg.TikvImporter.SortedKVDir = t.TempDir()
+	cfg.TikvImporter.EngineTTL.Duration = 24 * time.Hour
+	cfg.Mydumper.SourceDir = sourceDir
+	cfg.Mydumper.DefaultFileRules = true
+	cfg.Checkpoint.Enable = true
+	cfg.Checkpoint.Driver = config.CheckpointDriverFile
+	cfg.Checkpoint.DSN = filepath.Join(t.TempDir(), "cp.pb")
+
+	// the table is initialized, but no chunk of it has been read yet.
+	cpdb := checkpoints.NewFileCheckpointsDB(cfg.Checkpoint.DSN)
+	err := cpdb.Initialize(ctx, cfg, map[string]*checkpoints.TidbDBInfo{
+		"db": {
+			Name:   "db",
+			Tables: map[string]*checkpoints.TidbTableInfo{"t": {Name: "t"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cpdb.InsertEngineCheckpoints(ctx, "`db`.`t`", map[int32]*checkpoints.EngineCheckpoint{
+		-1: {Status: checkpoints.CheckpointStatusLoaded},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cpdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the index engine was created before the engine TTL.
+	createdAt := time.Now().Add(-48 * time.Hour)
+	_, indexEngine := backend.MakeUUID("`db`.`t`", -1)
+	indexEngineDir := filepath.Join(cfg.TikvImporter.SortedKVDir, indexEngine.String())
+	if err = os.Mkdir(indexEngineDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(indexEngineDir, createdAt, createdAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cleanupOrphanEngines(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(indexEngineDir); err != nil {
+		t.Fatalf("the index engine of the table not imported is removed: %v", err)
+	}
+}
@@ -26,10 +26,18 @@ import (
 	"github.com/pingcap/br/pkg/lightning"
 	"github.com/pingcap/br/pkg/lightning/config"
 	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/validation"
 )
 
 func main() {
 	globalCfg := config.Must(config.LoadGlobalConfig(os.Args[1:], nil))
+	if globalCfg.App.ValidateOnly {
+		if err := validateConfig(globalCfg); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid config:", err)
+			exit(1)
+		}
+		return
+	}
 	fmt.Fprintf(os.Stdout, "Verbose debug logs will be written to %s\n\n", globalCfg.App.Config.File)
 
 	app := lightning.New(globalCfg)
@@ -108,5 +116,24 @@ func main() {
 	}
 }
 
+// validateConfig prints all the problems of the task config as a whole.
+func validateConfig(globalCfg *config.GlobalConfig) error {
+	cfg := config.NewConfig()
+	if err := cfg.LoadFromGlobal(globalCfg); err != nil {
+		return err
+	}
+	v := &validation.Validator{}
+	cfg.Validate(v)
+	warnings := v.Warnings()
+	for i := range warnings {
+		fmt.Fprintln(os.Stdout, warnings[i].String())
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "the config is valid with %d warnings\n", len(warnings))
+	return nil
+}
+
 // main_test.go override exit to pass unit test.
 var exit = os.Exit
@@ -0,0 +1,111 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+// Package audit records the actions of BR mutating the cluster, so the changes
+// made by a task, e.g. a restore in production, can be reviewed afterwards.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// The actions recorded.
+const (
+	ActionCreateDatabase         = "create-database"
+	ActionCreateTable            = "create-table"
+	ActionExecDDL                = "execute-ddl"
+	ActionRebaseAutoID           = "rebase-auto-id"
+	ActionSetPlacementRule       = "set-placement-rule"
+	ActionDeletePlacementRule    = "delete-placement-rule"
+	ActionPauseScheduler         = "pause-scheduler"
+	ActionResumeScheduler        = "resume-scheduler"
+	ActionUpdateScheduleConfig   = "update-schedule-config"
+	ActionSetStoreLabel          = "set-store-label"
+	ActionResetTS                = "reset-ts"
+	ActionUpdateServiceSafePoint = "update-service-safe-point"
+	ActionIngestSST              = "ingest-sst"
+	ActionMergeRegion            = "merge-region"
+	ActionRebuildIndex           = "rebuild-index"
+	ActionSetGlobalVariable      = "set-global-variable"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+// Entry is a record of an action.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// Target is what the action mutates, e.g. the name of the table.
+	Target string `json:"target"`
+	// Detail is the content of the action, e.g. the DDL query.
+	Detail   string        `json:"detail,omitempty"`
+	Outcome  string        `json:"outcome"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Logger writes the entries as JSON lines.
+type Logger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewLogger creates a Logger writing into w.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w, enc: json.NewEncoder(w)}
+}
+
+// Record records an action started at the time, which fails if err isn't nil.
+func (l *Logger) Record(action, target, detail string, start time.Time, err error) {
+	now := time.Now()
+	entry := Entry{
+		Time:     now,
+		Action:   action,
+		Target:   target,
+		Detail:   detail,
+		Outcome:  outcomeSuccess,
+		Duration: now.Sub(start),
+	}
+	if err != nil {
+		entry.Outcome = outcomeFailure
+		entry.Error = err.Error()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e := l.enc.Encode(&entry); e != nil {
+		// the audit log mustn't fail the task, but the loss should be noticed.
+		log.Warn("failed to write audit log", zap.String("action", action), zap.String("target", target), zap.Error(e))
+	}
+}
+
+var (
+	globalMu     sync.RWMutex
+	globalLogger *Logger
+)
+
+// SetLogger sets the logger used by Record, a nil logger stops recording.
+func SetLogger(l *Logger) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalLogger = l
+}
+
+// Record records an action by the logger set by SetLogger. It does nothing if
+// there isn't one.
+func Record(action, target, detail string, start time.Time, err error) {
+	globalMu.RLock()
+	l := globalLogger
+	globalMu.RUnlock()
+	if l != nil {
+		l.Record(action, target, detail, start, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type testAuditSuite struct{}
+
+var _ = Suite(&testAuditSuite{})
+
+func (s *testAuditSuite) TestRecord(c *C) {
+	// nothing is recorded without a logger.
+	Record(ActionCreateTable, "`db`.`t`", "", time.Now(), nil)
+
+	buf := new(bytes.Buffer)
+	SetLogger(NewLogger(buf))
+	defer SetLogger(nil)
+	start := time.Now().Add(-time.Second)
+	Record(ActionExecDDL, "db", "ALTER TABLE t ADD INDEX i(a)", start, nil)
+	Record(ActionPauseScheduler, "balance-leader-scheduler", "delay 5m0s", start, errors.New("503 Service Unavailable"))
+
+	var entries []Entry
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry Entry
+		c.Assert(json.Unmarshal(scanner.Bytes(), &entry), IsNil)
+		entries = append(entries, entry)
+	}
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Action, Equals, ActionExecDDL)
+	c.Assert(entries[0].Detail, Equals, "ALTER TABLE t ADD INDEX i(a)")
+	c.Assert(entries[0].Outcome, Equals, "success")
+	c.Assert(entries[0].Duration >= time.Second, IsTrue)
+	c.Assert(entries[1].Target, Equals, "balance-leader-scheduler")
+	c.Assert(entries[1].Outcome, Equals, "failure")
+	c.Assert(entries[1].Error, Equals, "503 Service Unavailable")
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/logutil"
+)
+
+const (
+	// agentStoreIDKey is the gRPC metadata telling the agent which store the
+	// backup request is for.
+	agentStoreIDKey = "br-backup-store-id"
+
+	agentDialTimeout = 5 * time.Second
+)
+
+// Agent serves the backup requests dispatched by the coordinator BR, and
+// sends them to the stores it's close to, e.g. in the same zone, so that the
+// responses don't cross the zones. It serves the backup service of TiKV.
+type Agent struct {
+	mgr ClientMgr
+}
+
+// NewAgent returns an agent sending the backup requests by the manager.
+func NewAgent(mgr ClientMgr) *Agent {
+	return &Agent{mgr: mgr}
+}
+
+// Backup implements backuppb.BackupServer.
+func (a *Agent) Backup(req *backuppb.BackupRequest, stream backuppb.Backup_BackupServer) error {
+	ctx := stream.Context()
+	storeID, err := agentStoreID(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("agent backup range", zap.Uint64("storeID", storeID),
+		logutil.Key("startKey", req.GetStartKey()), logutil.Key("endKey", req.GetEndKey()))
+	cli, err := a.mgr.GetBackupClient(ctx, storeID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	bcli, err := cli.Backup(ctx, req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for {
+		resp, err := bcli.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := stream.Send(resp); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+func agentStoreID(ctx context.Context) (uint64, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(agentStoreIDKey)
+	if len(values) != 1 {
+		return 0, errors.Annotatef(berrors.ErrInvalidArgument, "the backup request has no %s", agentStoreIDKey)
+	}
+	storeID, err := strconv.ParseUint(values[0], 10, 64)
+	if err != nil {
+		return 0, errors.Annotatef(berrors.ErrInvalidArgument, "invalid %s %q", agentStoreIDKey, values[0])
+	}
+	return storeID, nil
+}
+
+// agentBackupClient is the backup client of a store through an agent.
+type agentBackupClient struct {
+	backuppb.BackupClient
+	storeID uint64
+}
+
+func (c agentBackupClient) Backup(
+	ctx context.Context, in *backuppb.BackupRequest, opts ...grpc.CallOption,
+) (backuppb.Backup_BackupClient, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, agentStoreIDKey, strconv.FormatUint(c.storeID, 10))
+	return c.BackupClient.Backup(ctx, in, opts...)
+}
+
+// AgentClientMgr dispatches the backup requests of the stores to the agents
+// in the same localities as the stores, by the values of the locality label
+// of the stores. The requests of the stores without agents are sent directly.
+type AgentClientMgr struct {
+	ClientMgr
+
+	label     string
+	agents    map[string]string
+	tlsConfig *tls.Config
+
+	mu         sync.Mutex
+	conns      map[string]*grpc.ClientConn
+	localities map[uint64]string
+}
+
+// NewAgentClientMgr returns a ClientMgr dispatching the backup requests to
+// the agents, the agents are the addresses by the values of the locality
+// label, e.g. label "zone" and agents {"us-west-1a": "10.0.1.1:8290"}.
+func NewAgentClientMgr(mgr ClientMgr, label string, agents map[string]string, tlsConfig *tls.Config) *AgentClientMgr {
+	return &AgentClientMgr{
+		ClientMgr:  mgr,
+		label:      label,
+		agents:     agents,
+		tlsConfig:  tlsConfig,
+		conns:      make(map[string]*grpc.ClientConn),
+		localities: make(map[uint64]string),
+	}
+}
+
+// agentOf returns the address of the agent of the store, empty if the
+// store has no agent.
+func (mgr *AgentClientMgr) agentOf(ctx context.Context, storeID uint64) (string, error) {
+	mgr.mu.Lock()
+	locality, ok := mgr.localities[storeID]
+	mgr.mu.Unlock()
+	if !ok {
+		store, err := mgr.GetPDClient().GetStore(ctx, storeID)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		for _, label := range store.GetLabels() {
+			if label.GetKey() == mgr.label {
+				locality = label.GetValue()
+				break
+			}
+		}
+		mgr.mu.Lock()
+		mgr.localities[storeID] = locality
+		mgr.mu.Unlock()
+	}
+	return mgr.agents[locality], nil
+}
+
+func (mgr *AgentClientMgr) getAgentConn(ctx context.Context, addr string, reset bool) (*grpc.ClientConn, error) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	if conn, ok := mgr.conns[addr]; ok {
+		if !reset {
+			return conn, nil
+		}
+		if err := conn.Close(); err != nil {
+			log.Warn("close agent connection failed", zap.String("agent", addr), zap.Error(err))
+		}
+		delete(mgr.conns, addr)
+	}
+	opt := grpc.WithInsecure()
+	if mgr.tlsConfig != nil {
+		opt = grpc.WithTransportCredentials(credentials.NewTLS(mgr.tlsConfig))
+	}
+	bfConf := backoff.DefaultConfig
+	bfConf.MaxDelay = time.Second * 3
+	ctx, cancel := context.WithTimeout(ctx, agentDialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, opt, grpc.WithBlock(),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bfConf}))
+	if err != nil {
+		return nil, berrors.ErrFailedToConnect.Wrap(err).GenWithStack("failed to make connection to agent %s", addr)
+	}
+	mgr.conns[addr] = conn
+	return conn, nil
+}
+
+func (mgr *AgentClientMgr) getBackupClient(
+	ctx context.Context, storeID uint64, reset bool,
+) (backuppb.BackupClient, error) {
+	addr, err := mgr.agentOf(ctx, storeID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if addr == "" {
+		if reset {
+			return mgr.ClientMgr.ResetBackupClient(ctx, storeID)
+		}
+		return mgr.ClientMgr.GetBackupClient(ctx, storeID)
+	}
+	conn, err := mgr.getAgentConn(ctx, addr, reset)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return agentBackupClient{BackupClient: backuppb.NewBackupClient(conn), storeID: storeID}, nil
+}
+
+// GetBackupClient implements ClientMgr, it returns the client through the
+// agent of the store if there is one.
+func (mgr *AgentClientMgr) GetBackupClient(ctx context.Context, storeID uint64) (backuppb.BackupClient, error) {
+	return mgr.getBackupClient(ctx, storeID, false)
+}
+
+// ResetBackupClient implements ClientMgr.
+func (mgr *AgentClientMgr) ResetBackupClient(ctx context.Context, storeID uint64) (backuppb.BackupClient, error) {
+	return mgr.getBackupClient(ctx, storeID, true)
+}
+
+// Close closes the connections to the agents, the underlying manager is
+// closed by its owner.
+func (mgr *AgentClientMgr) Close() {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	for addr, conn := range mgr.conns {
+		if err := conn.Close(); err != nil {
+			log.Warn("close agent connection failed", zap.String("agent", addr), zap.Error(err))
+		}
+	}
+	mgr.conns = make(map[string]*grpc.ClientConn)
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup_test
+
+import (
+	"context"
+	"io"
+	"net"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
+	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	pd "github.com/tikv/pd/client"
+	"google.golang.org/grpc"
+
+	"github.com/pingcap/br/pkg/backup"
+)
+
+type testAgentSuite struct{}
+
+var _ = Suite(&testAgentSuite{})
+
+// fakeStoreServer is the backup service of a store answering the start key.
+type fakeStoreServer struct {
+	storeID uint64
+}
+
+func (s fakeStoreServer) Backup(req *backuppb.BackupRequest, stream backuppb.Backup_BackupServer) error {
+	return stream.Send(&backuppb.BackupResponse{
+		StartKey: req.GetStartKey(),
+		Files:    []*backuppb.File{{Name: string(rune('0' + s.storeID))}},
+	})
+}
+
+type fakeAgentPDClient struct {
+	pd.Client
+	stores map[uint64]*metapb.Store
+}
+
+func (c fakeAgentPDClient) GetStore(_ context.Context, storeID uint64) (*metapb.Store, error) {
+	return c.stores[storeID], nil
+}
+
+type fakeAgentClientMgr struct {
+	pdClient pd.Client
+	conns    map[uint64]*grpc.ClientConn
+}
+
+func (mgr fakeAgentClientMgr) GetBackupClient(_ context.Context, storeID uint64) (backuppb.BackupClient, error) {
+	return backuppb.NewBackupClient(mgr.conns[storeID]), nil
+}
+
+func (mgr fakeAgentClientMgr) ResetBackupClient(ctx context.Context, storeID uint64) (backuppb.BackupClient, error) {
+	return mgr.GetBackupClient(ctx, storeID)
+}
+
+func (mgr fakeAgentClientMgr) GetTiKVClient(_ context.Context, storeID uint64) (tikvpb.TikvClient, error) {
+	return tikvpb.NewTikvClient(mgr.conns[storeID]), nil
+}
+
+func (mgr fakeAgentClientMgr) GetPDClient() pd.Client {
+	return mgr.pdClient
+}
+
+func (mgr fakeAgentClientMgr) GetLockResolver() *txnlock.LockResolver {
+	return nil
+}
+
+func (mgr fakeAgentClientMgr) Close() {}
+
+func serveBackup(c *C, srv backuppb.BackupServer) (*grpc.ClientConn, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	server := grpc.NewServer()
+	backuppb.RegisterBackupServer(server, srv)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	c.Assert(err, IsNil)
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func (s *testAgentSuite) TestDispatchToAgent(c *C) {
+	ctx := context.Background()
+	conns := make(map[uint64]*grpc.ClientConn)
+	for _, storeID := range []uint64{1, 2} {
+		conn, stop := serveBackup(c, fakeStoreServer{storeID: storeID})
+		defer stop()
+		conns[storeID] = conn
+	}
+	pdClient := fakeAgentPDClient{stores: map[uint64]*metapb.Store{
+		1: {Id: 1, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "z1"}}},
+		2: {Id: 2, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "z2"}}},
+	}}
+	mgr := fakeAgentClientMgr{pdClient: pdClient, conns: conns}
+
+	// the agent in z1 reaches the stores by its own manager.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	server := grpc.NewServer()
+	backuppb.RegisterBackupServer(server, backup.NewAgent(mgr))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	// the store 2 has no agent, so it's backed up directly.
+	agentMgr := backup.NewAgentClientMgr(
+		fakeAgentClientMgr{pdClient: pdClient, conns: map[uint64]*grpc.ClientConn{2: conns[2]}},
+		"zone", map[string]string{"z1": listener.Addr().String()}, nil)
+	defer agentMgr.Close()
+	for _, storeID := range []uint64{1, 2} {
+		cli, err := agentMgr.GetBackupClient(ctx, storeID)
+		c.Assert(err, IsNil)
+		stream, err := cli.Backup(ctx, &backuppb.BackupRequest{StartKey: []byte("a")})
+		c.Assert(err, IsNil)
+		resp, err := stream.Recv()
+		c.Assert(err, IsNil)
+		c.Assert(resp.GetStartKey(), DeepEquals, []byte("a"))
+		c.Assert(resp.GetFiles()[0].GetName(), Equals, string(rune('0'+storeID)))
+		_, err = stream.Recv()
+		c.Assert(err, Equals, io.EOF)
+	}
+}
@@ -3,13 +3,17 @@
 package backup
 
 import (
+	"context"
 	"encoding/hex"
 
 	"github.com/google/btree"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/log"
 	"go.uber.org/zap"
 
+	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/rtree"
+	"github.com/pingcap/br/pkg/storage"
 )
 
 // checkDupFiles checks if there are any files are duplicated.
@@ -33,3 +37,66 @@ func checkDupFiles(rangeTree *rtree.RangeTree) {
 		return true
 	})
 }
+
+// backupResults are the ranges backed up, besides the files of the responses
+// made redundant by the others overlapping them, e.g. after the regions split
+// or merged during the backup and the ranges were re-sent.
+type backupResults struct {
+	rtree.RangeTree
+	redundant []*backuppb.File
+}
+
+func newBackupResults() *backupResults {
+	return &backupResults{RangeTree: rtree.NewRangeTree()}
+}
+
+// put keeps the minimal set of the ranges covering the responses.
+func (r *backupResults) put(startKey, endKey []byte, files []*backuppb.File) {
+	for _, rg := range r.PutDedup(startKey, endKey, files) {
+		log.Info("drop redundant backup range",
+			logutil.Key("startKey", rg.StartKey), logutil.Key("endKey", rg.EndKey),
+			zap.Int("files", len(rg.Files)))
+		r.redundant = append(r.redundant, rg.Files...)
+	}
+}
+
+// removeRedundantFiles deletes the uploaded files of the redundant ranges
+// under the prefix, so that they aren't left in the storage without being
+// referred to by the backup meta. A file named the same as a kept one is
+// kept, since the kept one was uploaded over it. The files failed to delete
+// are only logged, since they don't break the backup.
+func (r *backupResults) removeRedundantFiles(ctx context.Context, s storage.ExternalStorage, prefix string) {
+	if len(r.redundant) == 0 {
+		return
+	}
+	deleter, ok := s.(storage.Deleter)
+	if !ok {
+		log.Warn("the storage can't delete files, the redundant files are left",
+			zap.Int("files", len(r.redundant)))
+		return
+	}
+	kept := make(map[string]struct{})
+	r.Ascend(func(i btree.Item) bool {
+		for _, f := range i.(*rtree.Range).Files {
+			kept[f.Name] = struct{}{}
+		}
+		return true
+	})
+	deleted := 0
+	for _, f := range r.redundant {
+		if _, ok := kept[f.Name]; ok {
+			continue
+		}
+		name := f.Name
+		if prefix != "" {
+			name = prefix + "/" + name
+		}
+		if err := deleter.DeleteFile(ctx, name); err != nil {
+			log.Warn("failed to delete the redundant file", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		deleted++
+	}
+	log.Info("deleted the redundant files", zap.Int("deleted", deleted), zap.Int("redundant", len(r.redundant)))
+	r.redundant = nil
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/pingcap/failpoint"
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/model"
 	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
@@ -53,6 +54,7 @@ import (
 type ClientMgr interface {
 	GetBackupClient(ctx context.Context, storeID uint64) (backuppb.BackupClient, error)
 	ResetBackupClient(ctx context.Context, storeID uint64) (backuppb.BackupClient, error)
+	GetTiKVClient(ctx context.Context, storeID uint64) (tikvpb.TikvClient, error)
 	GetPDClient() pd.Client
 	GetLockResolver() *txnlock.LockResolver
 	Close()
@@ -78,6 +80,14 @@ const (
 	RegionUnit ProgressUnit = "region"
 )
 
+// The leaders on a draining store are waited to be transferred for at most
+// drainingLeaderRetryRounds rounds of the fine-grained backup, each of which
+// backs off drainingLeaderBackoffMs once.
+const (
+	drainingLeaderRetryRounds = 10
+	drainingLeaderBackoffMs   = 1000
+)
+
 // Client is a client instructs TiKV how to do a backup.
 type Client struct {
 	mgr       ClientMgr
@@ -87,6 +97,23 @@ type Client struct {
 	backend *backuppb.StorageBackend
 
 	gcTTL int64
+
+	events *EventEmitter
+	// storeFilters select the stores the backup is pushed down to.
+	storeFilters []conn.StoreFilter
+	// storeWatcher tells the stores being drained during the backup.
+	storeWatcher *conn.StoreWatcher
+	// tablePrefixes are the prefixes of the backup files of the physical
+	// tables in the table layout, nil in the flat layout.
+	tablePrefixes map[int64]string
+	// coldTables are the physical tables whose backup files are written to
+	// the cold tier, see SetColdTables.
+	coldTables       map[int64]struct{}
+	coldStorageClass string
+	// rateLimitSchedule changes the rate limit by the time of the day, and
+	// rateLimitMu serializes the rate limited ranges, see SetRateLimitSchedule.
+	rateLimitSchedule *RateLimitSchedule
+	rateLimitMu       sync.Mutex
 }
 
 // NewBackupClient returns a new backup client.
@@ -146,6 +173,25 @@ func (bc *Client) SetLockFile(ctx context.Context) error {
 			"This file exists to remind other backup jobs won't use this path"))
 }
 
+// SetEventEmitter sets the emitter publishing the uploaded files.
+func (bc *Client) SetEventEmitter(events *EventEmitter) {
+	bc.events = events
+}
+
+// SetStoreFilters sets the filters selecting the stores the backup is pushed
+// down to. The ranges not covered by these stores are backed up from their
+// leaders by the fine-grained backup, so the backup is still complete.
+func (bc *Client) SetStoreFilters(filters ...conn.StoreFilter) {
+	bc.storeFilters = filters
+}
+
+// SetStoreWatcher sets the watcher of the store states. The backup is not
+// pushed down to the stores removed, and the fine-grained backup waits for the
+// leaders to be transferred out of the stores being drained.
+func (bc *Client) SetStoreWatcher(watcher *conn.StoreWatcher) {
+	bc.storeWatcher = watcher
+}
+
 // SetGCTTL set gcTTL for client.
 func (bc *Client) SetGCTTL(ttl int64) {
 	if ttl <= 0 {
@@ -253,8 +299,37 @@ func BuildBackupRangeAndSchema(
 	storage kv.Storage,
 	tableFilter filter.Filter,
 	backupTS uint64,
+) ([]rtree.Range, *Schemas, error) {
+	return BuildBackupRangeAndSchemaWithReplicaRead(storage, tableFilter, backupTS, utils.ReplicaReadLeader)
+}
+
+// BuildBackupRangeAndSchemaWithReplicaRead is BuildBackupRangeAndSchema
+// reading the schemas from the given replicas, which falls back to the
+// leaders if the replicas fail the reads, e.g. their data isn't ready at the
+// backup TS yet.
+func BuildBackupRangeAndSchemaWithReplicaRead(
+	storage kv.Storage,
+	tableFilter filter.Filter,
+	backupTS uint64,
+	replicaRead utils.ReplicaRead,
+) ([]rtree.Range, *Schemas, error) {
+	ranges, schemas, err := buildBackupRangeAndSchema(storage, tableFilter, backupTS, replicaRead)
+	if err != nil && !replicaRead.IsLeader() {
+		log.Warn("read schemas from the replicas failed, fall back to the leaders",
+			zap.String("replica-read", string(replicaRead)), logutil.ShortError(err))
+		return buildBackupRangeAndSchema(storage, tableFilter, backupTS, utils.ReplicaReadLeader)
+	}
+	return ranges, schemas, err
+}
+
+func buildBackupRangeAndSchema(
+	storage kv.Storage,
+	tableFilter filter.Filter,
+	backupTS uint64,
+	replicaRead utils.ReplicaRead,
 ) ([]rtree.Range, *Schemas, error) {
 	snapshot := storage.GetSnapshot(kv.NewVersion(backupTS))
+	replicaRead.ApplyToSnapshot(snapshot)
 	m := meta.NewSnapshotMeta(snapshot)
 
 	ranges := make([]rtree.Range, 0)
@@ -402,6 +477,28 @@ func WriteBackupDDLJobs(metaWriter *metautil.MetaWriter, store kv.Storage, lastB
 	return nil
 }
 
+// GetSchemaHistory returns the schema version at the backupTS, and at most n
+// DDL jobs done before it from the latest.
+func GetSchemaHistory(store kv.Storage, backupTS uint64, n int) (int64, []*model.Job, error) {
+	snapMeta := meta.NewSnapshotMeta(store.GetSnapshot(kv.NewVersion(backupTS)))
+	schemaVersion, err := snapMeta.GetSchemaVersion()
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	historyJobs, err := snapMeta.GetLastNHistoryDDLJobs(n)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	jobs := make([]*model.Job, 0, len(historyJobs))
+	for _, job := range historyJobs {
+		if (job.State == model.JobStateDone || job.State == model.JobStateSynced) &&
+			job.BinlogInfo != nil && job.BinlogInfo.SchemaVersion <= schemaVersion {
+			jobs = append(jobs, job)
+		}
+	}
+	return schemaVersion, jobs, nil
+}
+
 // BackupRanges make a backup of the given key ranges.
 func (bc *Client) BackupRanges(
 	ctx context.Context,
@@ -456,24 +553,39 @@ func (bc *Client) BackupRange(
 			summary.CollectFailureUnit(key, err)
 		}
 	}()
+	defer bc.limitRate(&req)()
 	logutil.CL(ctx).Info("backup started",
 		logutil.Key("startKey", startKey), logutil.Key("endKey", endKey),
 		zap.Uint64("rateLimit", req.RateLimit),
 		zap.Uint32("concurrency", req.Concurrency))
 
 	var allStores []*metapb.Store
-	allStores, err = conn.GetAllTiKVStores(ctx, bc.mgr.GetPDClient(), conn.SkipTiFlash)
+	filters := bc.storeFilters
+	if bc.storeWatcher != nil {
+		filters = append(append([]conn.StoreFilter{}, filters...), bc.storeWatcher.NotRemoved)
+	}
+	allStores, err = conn.GetAllTiKVStores(ctx, bc.mgr.GetPDClient(), conn.SkipTiFlash, filters...)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if len(allStores) == 0 && len(filters) > 0 {
+		return errors.Annotate(berrors.ErrInvalidArgument, "no store matches the store filters")
+	}
 
 	req.StartKey = startKey
 	req.EndKey = endKey
-	req.StorageBackend = bc.backend
+	prefix := bc.prefixOf(startKey)
+	req.StorageBackend, err = BackendWithPrefix(bc.backend, prefix)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if bc.isCold(startKey) {
+		req.StorageBackend = BackendWithStorageClass(req.StorageBackend, bc.coldStorageClass)
+	}
 
 	push := newPushDown(bc.mgr, len(allStores))
 
-	var results rtree.RangeTree
+	var results *backupResults
 	results, err = push.pushBackup(ctx, req, allStores, progressCallBack)
 	if err != nil {
 		return errors.Trace(err)
@@ -484,7 +596,7 @@ func (bc *Client) BackupRange(
 	// TODO: test fine grained backup.
 	err = bc.fineGrainedBackup(
 		ctx, startKey, endKey, req.StartVersion, req.EndVersion, req.CompressionType, req.CompressionLevel,
-		req.RateLimit, req.Concurrency, results, progressCallBack)
+		req.RateLimit, req.Concurrency, req.StorageBackend, results, progressCallBack)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -503,12 +615,21 @@ func (bc *Client) BackupRange(
 			zap.Reflect("EndVersion", req.EndVersion))
 	}
 
+	if bc.storage != nil {
+		results.removeRedundantFiles(ctx, bc.storage, prefix)
+	}
+
 	var ascendErr error
 	results.Ascend(func(i btree.Item) bool {
 		r := i.(*rtree.Range)
 		for _, f := range r.Files {
+			if prefix != "" {
+				// the names are relative to the root of the storage.
+				f.Name = prefix + "/" + f.Name
+			}
 			summary.CollectSuccessUnit(summary.TotalKV, 1, f.TotalKvs)
 			summary.CollectSuccessUnit(summary.TotalBytes, 1, f.TotalBytes)
+			bc.events.Emit(NewFileUploadedEvent(f))
 		}
 		// we need keep the files in order after we support multi_ingest sst.
 		// default_sst and write_sst need to be together.
@@ -523,7 +644,7 @@ func (bc *Client) BackupRange(
 	}
 
 	// Check if there are duplicated files.
-	checkDupFiles(&results)
+	checkDupFiles(&results.RangeTree)
 
 	return nil
 }
@@ -562,7 +683,8 @@ func (bc *Client) fineGrainedBackup(
 	compressLevel int32,
 	rateLimit uint64,
 	concurrency uint32,
-	rangeTree rtree.RangeTree,
+	backend *backuppb.StorageBackend,
+	rangeTree *backupResults,
 	progressCallBack func(ProgressUnit),
 ) error {
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
@@ -587,7 +709,9 @@ func (bc *Client) fineGrainedBackup(
 	})
 
 	bo := tikv.NewBackoffer(ctx, backupFineGrainedMaxBackoff)
-	for {
+	for round := 0; ; round++ {
+		// the leaders left on the draining stores are used after enough rounds.
+		avoidDraining := round < drainingLeaderRetryRounds
 		// Step1, check whether there is any incomplete range
 		incomplete := rangeTree.GetIncompleteRange(startKey, endKey)
 		if len(incomplete) == 0 {
@@ -612,7 +736,7 @@ func (bc *Client) fineGrainedBackup(
 				for rg := range retry {
 					backoffMs, err :=
 						bc.handleFineGrained(ctx, boFork, rg, lastBackupTS, backupTS,
-							compressType, compressLevel, rateLimit, concurrency, respCh)
+							compressType, compressLevel, rateLimit, concurrency, backend, avoidDraining, respCh)
 					if err != nil {
 						errCh <- err
 						return
@@ -657,7 +781,7 @@ func (bc *Client) fineGrainedBackup(
 					logutil.Key("fine-grained-range-start", resp.StartKey),
 					logutil.Key("fine-grained-range-end", resp.EndKey),
 				)
-				rangeTree.Put(resp.StartKey, resp.EndKey, resp.Files)
+				rangeTree.put(resp.StartKey, resp.EndKey, resp.Files)
 
 				// Update progress
 				progressCallBack(RegionUnit)
@@ -757,6 +881,8 @@ func (bc *Client) handleFineGrained(
 	compressionLevel int32,
 	rateLimit uint64,
 	concurrency uint32,
+	backend *backuppb.StorageBackend,
+	avoidDraining bool,
 	respCh chan<- *backuppb.BackupResponse,
 ) (int, error) {
 	leader, pderr := bc.findRegionLeader(ctx, rg.StartKey)
@@ -764,6 +890,13 @@ func (bc *Client) handleFineGrained(
 		return 0, errors.Trace(pderr)
 	}
 	storeID := leader.GetStoreId()
+	if avoidDraining && bc.storeWatcher.IsDraining(storeID) {
+		// PD transfers the leader out of the draining store soon, so the range
+		// is retried in the next round rather than against the store.
+		logutil.CL(ctx).Info("the leader is on a draining store, wait for it to be transferred",
+			zap.Uint64("storeID", storeID), logutil.Key("key", rg.StartKey))
+		return drainingLeaderBackoffMs, nil
+	}
 
 	req := backuppb.BackupRequest{
 		ClusterId:        bc.clusterID,
@@ -771,7 +904,7 @@ func (bc *Client) handleFineGrained(
 		EndKey:           rg.EndKey,
 		StartVersion:     lastBackupTS,
 		EndVersion:       backupTS,
-		StorageBackend:   bc.backend,
+		StorageBackend:   backend,
 		RateLimit:        rateLimit,
 		Concurrency:      concurrency,
 		CompressionType:  compressType,
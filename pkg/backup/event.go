@@ -0,0 +1,242 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/httputil"
+)
+
+const (
+	eventChannelSize   = 1024
+	eventMaxBatchSize  = 128
+	eventRetryTimes    = 3
+	eventRetryInterval = time.Second
+	// eventPostTimeout bounds every post, so that a slow endpoint delays the
+	// following events by at most eventRetryTimes posts.
+	eventPostTimeout = 5 * time.Second
+)
+
+// EventType is the type of the events of a backup task.
+type EventType string
+
+const (
+	// EventBackupStarted is emitted when the backup starts.
+	EventBackupStarted EventType = "backup-started"
+	// EventFileUploaded is emitted when a backup file is uploaded by TiKV.
+	EventFileUploaded EventType = "file-uploaded"
+	// EventTableChecksummed is emitted when the checksum of a table is
+	// calculated, or the checksum is skipped.
+	EventTableChecksummed EventType = "table-checksummed"
+	// EventPhaseCompleted is emitted when a phase of the backup is completed.
+	EventPhaseCompleted EventType = "phase-completed"
+	// EventFileCorrupted is emitted when a stored backup file doesn't match
+	// its recorded sha256, or can't be read.
+	EventFileCorrupted EventType = "file-corrupted"
+)
+
+// Event is a structured event of a backup task.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// TaskID, Storage and BackupTS identify the backup task, and are filled
+	// by the EventEmitter.
+	TaskID   string `json:"task-id"`
+	Storage  string `json:"storage"`
+	BackupTS uint64 `json:"backup-ts"`
+
+	Phase string `json:"phase,omitempty"`
+
+	File   string `json:"file,omitempty"`
+	Size   uint64 `json:"size,omitempty"`
+	Sha256 string `json:"sha256,omitempty"`
+
+	DB         string `json:"db,omitempty"`
+	Table      string `json:"table,omitempty"`
+	Crc64Xor   uint64 `json:"crc64xor,omitempty"`
+	TotalKvs   uint64 `json:"total-kvs,omitempty"`
+	TotalBytes uint64 `json:"total-bytes,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// NewFileUploadedEvent returns the event of the uploaded backup file.
+func NewFileUploadedEvent(file *backuppb.File) *Event {
+	return &Event{
+		Type:       EventFileUploaded,
+		File:       file.Name,
+		Size:       file.Size_,
+		Sha256:     hex.EncodeToString(file.Sha256),
+		Crc64Xor:   file.Crc64Xor,
+		TotalKvs:   file.TotalKvs,
+		TotalBytes: file.TotalBytes,
+	}
+}
+
+// NewPhaseCompletedEvent returns the event of the completed phase.
+func NewPhaseCompletedEvent(phase string) *Event {
+	return &Event{Type: EventPhaseCompleted, Phase: phase}
+}
+
+// EventEmitter publishes the events of a backup task to an HTTP endpoint, so
+// that the data pipelines can react to the backup without polling the
+// storage. The events are posted in the background as JSON arrays, in the
+// order they are emitted. The events are dropped instead of blocking the
+// backup if the endpoint falls far behind. A nil EventEmitter discards all
+// events.
+type EventEmitter struct {
+	url      string
+	taskID   string
+	storage  string
+	backupTS uint64
+	client   *http.Client
+	dropped  uint64
+
+	mu     sync.Mutex
+	closed bool
+	ch     chan *Event
+	done   chan struct{}
+}
+
+// NewEventEmitter creates an EventEmitter posting the events of the backup
+// task to the url.
+func NewEventEmitter(url, taskID, storage string, backupTS uint64) *EventEmitter {
+	e := &EventEmitter{
+		url:      url,
+		taskID:   taskID,
+		storage:  storage,
+		backupTS: backupTS,
+		client:   httputil.NewClient(nil),
+		ch:       make(chan *Event, eventChannelSize),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Emit publishes the event. It never blocks, the event is dropped if the
+// endpoint falls far behind.
+func (e *EventEmitter) Emit(event *Event) {
+	if e == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.TaskID = e.taskID
+	event.Storage = e.storage
+	event.BackupTS = e.backupTS
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.ch <- event:
+	default:
+		if atomic.AddUint64(&e.dropped, 1) == 1 {
+			log.Warn("the backup events are published too slowly, dropping events", zap.String("url", e.url))
+		}
+	}
+}
+
+// Dropped returns the number of the events dropped since the endpoint fell
+// behind.
+func (e *EventEmitter) Dropped() uint64 {
+	if e == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// Close publishes the pending events and stops the emitter.
+func (e *EventEmitter) Close(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.ch)
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		log.Warn("some backup events are not published", zap.Error(ctx.Err()))
+	}
+	if dropped := e.Dropped(); dropped > 0 {
+		log.Warn("some backup events are dropped", zap.Uint64("count", dropped))
+	}
+}
+
+func (e *EventEmitter) run() {
+	defer close(e.done)
+	for event := range e.ch {
+		batch := []*Event{event}
+	collect:
+		for len(batch) < eventMaxBatchSize {
+			select {
+			case event, ok := <-e.ch:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, event)
+			default:
+				break collect
+			}
+		}
+		if err := e.post(batch); err != nil {
+			log.Warn("failed to publish backup events", zap.Int("count", len(batch)), zap.Error(err))
+		}
+	}
+}
+
+func (e *EventEmitter) post(batch []*Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for i := 0; ; i++ {
+		err = e.postOnce(body)
+		if err == nil || i+1 >= eventRetryTimes {
+			return err
+		}
+		time.Sleep(eventRetryInterval)
+	}
+}
+
+func (e *EventEmitter) postOnce(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), eventPostTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Annotatef(berrors.ErrUnknown, "unexpected response status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/backup"
+)
+
+type testEventSuite struct{}
+
+var _ = Suite(&testEventSuite{})
+
+func (s *testEventSuite) TestEventEmitter(c *C) {
+	var (
+		mu       sync.Mutex
+		received []*backup.Event
+		failed   bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.Header.Get("Content-Type"), Equals, "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		// the first request fails, and should be retried.
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []*backup.Event
+		c.Assert(json.NewDecoder(r.Body).Decode(&batch), IsNil)
+		received = append(received, batch...)
+	}))
+	defer server.Close()
+
+	events := backup.NewEventEmitter(server.URL, "task", "local:///tmp/backup", 42)
+	events.Emit(&backup.Event{Type: backup.EventBackupStarted})
+	events.Emit(backup.NewFileUploadedEvent(&backuppb.File{Name: "1_2_write.sst", Size_: 100, Sha256: []byte{0xab}}))
+	events.Emit(&backup.Event{Type: backup.EventTableChecksummed, DB: "db", Table: "t", TotalKvs: 10})
+	events.Emit(backup.NewPhaseCompletedEvent("checksum"))
+	events.Close(context.Background())
+	// the events emitted after closing are discarded.
+	events.Emit(backup.NewPhaseCompletedEvent("finished"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	c.Assert(received, HasLen, 4)
+	for _, event := range received {
+		c.Assert(event.TaskID, Equals, "task")
+		c.Assert(event.Storage, Equals, "local:///tmp/backup")
+		c.Assert(event.BackupTS, Equals, uint64(42))
+		c.Assert(event.Time.IsZero(), IsFalse)
+	}
+	c.Assert(received[0].Type, Equals, backup.EventBackupStarted)
+	c.Assert(received[1].Type, Equals, backup.EventFileUploaded)
+	c.Assert(received[1].File, Equals, "1_2_write.sst")
+	c.Assert(received[1].Size, Equals, uint64(100))
+	c.Assert(received[1].Sha256, Equals, "ab")
+	c.Assert(received[2].Table, Equals, "t")
+	c.Assert(received[3].Phase, Equals, "checksum")
+}
+
+func (s *testEventSuite) TestNilEventEmitter(c *C) {
+	var events *backup.EventEmitter
+	events.Emit(backup.NewPhaseCompletedEvent("backup"))
+	events.Close(context.Background())
+}
+
+func (s *testEventSuite) TestEventEmitterNeverBlocks(c *C) {
+	release := make(chan struct{})
+	var (
+		mu       sync.Mutex
+		received int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var batch []*backup.Event
+		c.Assert(json.NewDecoder(r.Body).Decode(&batch), IsNil)
+		mu.Lock()
+		received += len(batch)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	events := backup.NewEventEmitter(server.URL, "task", "local:///tmp/backup", 42)
+	// the endpoint is stuck, the events beyond the buffer are dropped.
+	const count = 4096
+	for i := 0; i < count; i++ {
+		events.Emit(backup.NewPhaseCompletedEvent("backup"))
+	}
+	dropped := events.Dropped()
+	c.Assert(dropped, Greater, uint64(0))
+	close(release)
+	events.Close(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	c.Assert(uint64(received), Equals, count-dropped)
+}
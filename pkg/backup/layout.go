@@ -0,0 +1,104 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/tidb/tablecodec"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// The layouts of the backup files in the storage.
+const (
+	// FileLayoutFlat writes all the backup files into the root of the storage.
+	FileLayoutFlat = "flat"
+	// FileLayoutTable writes the backup files of a table under the `db/table/`
+	// prefix, so the lifecycle policies of the object storage can be set per
+	// table, and the files of a table can be listed without the others. The
+	// prefixes are recorded in the file names of the backupmeta, so the restore
+	// reads both layouts the same way.
+	FileLayoutTable = "table"
+)
+
+// ParseFileLayout checks the layout of the backup files.
+func ParseFileLayout(layout string) (string, error) {
+	switch layout {
+	case "", FileLayoutFlat:
+		return FileLayoutFlat, nil
+	case FileLayoutTable:
+		return FileLayoutTable, nil
+	default:
+		return "", errors.Annotatef(berrors.ErrInvalidArgument,
+			"invalid file layout %s, must be %s or %s", layout, FileLayoutFlat, FileLayoutTable)
+	}
+}
+
+// TablePrefix returns the prefix of the backup files of the table in the
+// table layout. The names are escaped since they may contain '/'.
+func TablePrefix(db, table string) string {
+	return url.PathEscape(db) + "/" + url.PathEscape(table)
+}
+
+// SetTableLayout writes the backup files of the tables in the schemas under
+// their prefixes.
+func (bc *Client) SetTableLayout(schemas *Schemas) {
+	bc.tablePrefixes = make(map[int64]string)
+	for _, s := range schemas.schemas {
+		prefix := TablePrefix(s.dbInfo.Name.O, s.tableInfo.Name.O)
+		bc.tablePrefixes[s.tableInfo.ID] = prefix
+		if pi := s.tableInfo.GetPartitionInfo(); pi != nil {
+			for _, def := range pi.Definitions {
+				bc.tablePrefixes[def.ID] = prefix
+			}
+		}
+	}
+}
+
+// prefixOf returns the prefix of the backup files of the range starting from
+// the key, empty in the flat layout of the hot tier.
+func (bc *Client) prefixOf(startKey []byte) string {
+	var prefix string
+	if bc.tablePrefixes != nil {
+		prefix = bc.tablePrefixes[tablecodec.DecodeTableID(startKey)]
+	}
+	if bc.isCold(startKey) {
+		prefix = path.Join(ColdTierPrefix, prefix)
+	}
+	return prefix
+}
+
+// BackendWithPrefix returns the storage backend writing the files under the
+// prefix.
+func BackendWithPrefix(backend *backuppb.StorageBackend, prefix string) (*backuppb.StorageBackend, error) {
+	if prefix == "" {
+		return backend, nil
+	}
+	b := proto.Clone(backend).(*backuppb.StorageBackend)
+	switch s := b.Backend.(type) {
+	case *backuppb.StorageBackend_Local:
+		s.Local.Path = path.Join(s.Local.Path, prefix)
+	case *backuppb.StorageBackend_S3:
+		s.S3.Prefix = joinPrefix(s.S3.Prefix, prefix)
+	case *backuppb.StorageBackend_Gcs:
+		s.Gcs.Prefix = joinPrefix(s.Gcs.Prefix, prefix)
+	case *backuppb.StorageBackend_Noop:
+	default:
+		return nil, errors.Annotatef(berrors.ErrStorageInvalidConfig,
+			"the table file layout doesn't support the storage %T", s)
+	}
+	return b, nil
+}
+
+func joinPrefix(base, prefix string) string {
+	if base = strings.Trim(base, "/"); base == "" {
+		return prefix
+	}
+	return base + "/" + prefix
+}
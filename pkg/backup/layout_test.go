@@ -0,0 +1,58 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup_test
+
+import (
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/backup"
+)
+
+type testLayoutSuite struct{}
+
+var _ = Suite(&testLayoutSuite{})
+
+func (s *testLayoutSuite) TestParseFileLayout(c *C) {
+	layout, err := backup.ParseFileLayout("")
+	c.Assert(err, IsNil)
+	c.Assert(layout, Equals, backup.FileLayoutFlat)
+	layout, err = backup.ParseFileLayout("table")
+	c.Assert(err, IsNil)
+	c.Assert(layout, Equals, backup.FileLayoutTable)
+	_, err = backup.ParseFileLayout("db")
+	c.Assert(err, ErrorMatches, ".*invalid file layout db.*")
+}
+
+func (s *testLayoutSuite) TestBackendWithPrefix(c *C) {
+	prefix := backup.TablePrefix("test", "a/b")
+	c.Assert(prefix, Equals, "test/a%2Fb")
+
+	local := &backuppb.StorageBackend{
+		Backend: &backuppb.StorageBackend_Local{Local: &backuppb.Local{Path: "/tmp/backup"}},
+	}
+	b, err := backup.BackendWithPrefix(local, prefix)
+	c.Assert(err, IsNil)
+	c.Assert(b.GetLocal().GetPath(), Equals, "/tmp/backup/test/a%2Fb")
+	// the original backend is untouched.
+	c.Assert(local.GetLocal().GetPath(), Equals, "/tmp/backup")
+
+	b, err = backup.BackendWithPrefix(local, "")
+	c.Assert(err, IsNil)
+	c.Assert(b, Equals, local)
+
+	s3 := &backuppb.StorageBackend{
+		Backend: &backuppb.StorageBackend_S3{S3: &backuppb.S3{Bucket: "bucket", Prefix: "backup/"}},
+	}
+	b, err = backup.BackendWithPrefix(s3, prefix)
+	c.Assert(err, IsNil)
+	c.Assert(b.GetS3().GetPrefix(), Equals, "backup/test/a%2Fb")
+	c.Assert(b.GetS3().GetBucket(), Equals, "bucket")
+
+	gcs := &backuppb.StorageBackend{
+		Backend: &backuppb.StorageBackend_Gcs{Gcs: &backuppb.GCS{Bucket: "bucket"}},
+	}
+	b, err = backup.BackendWithPrefix(gcs, prefix)
+	c.Assert(err, IsNil)
+	c.Assert(b.GetGcs().GetPrefix(), Equals, "test/a%2Fb")
+}
@@ -0,0 +1,290 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/util/codec"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/txnlock"
+	pd "github.com/tikv/pd/client"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/redact"
+	"github.com/pingcap/br/pkg/rtree"
+)
+
+const (
+	// scanLockLimit is the max number of locks scanned by a request.
+	scanLockLimit = 1024
+	// scanLockRegionLimit is the max number of regions scanned from PD at once.
+	scanLockRegionLimit = 128
+	// resolveLockMaxBackoff is the max sleep time(in ms) resolving a batch of locks.
+	resolveLockMaxBackoff = 20000
+	// resolveLockRetryInterval is the max interval to scan the locks again
+	// while some transactions are still alive.
+	resolveLockRetryInterval = 5 * time.Second
+	// maxReportedLongTxns is the max number of long running transactions
+	// listed in the error, the others are only logged.
+	maxReportedLongTxns = 10
+)
+
+// LongTxn is a transaction whose locks before the backup TS are still alive
+// after resolving the locks. The backup would wait for it to be committed or
+// rolled back, so the backup TS is unsafe to use.
+type LongTxn struct {
+	StartTS uint64
+	Primary []byte
+	// Locks is the number of the locks of the transaction in the ranges.
+	Locks int
+	// TTL is the TTL of the locks in milliseconds, starting from StartTS.
+	TTL uint64
+}
+
+// ExpireAt returns when the locks of the transaction expire if it doesn't
+// heartbeat any more.
+func (t LongTxn) ExpireAt() time.Time {
+	return oracle.GetTimeFromTS(t.StartTS).Add(time.Duration(t.TTL) * time.Millisecond)
+}
+
+// CollectLongTxns groups the locks by the transactions, the transactions
+// started earliest first.
+func CollectLongTxns(locks []*kvrpcpb.LockInfo) []LongTxn {
+	txns := make(map[uint64]*LongTxn)
+	for _, lock := range locks {
+		txn, ok := txns[lock.GetLockVersion()]
+		if !ok {
+			txn = &LongTxn{StartTS: lock.GetLockVersion(), Primary: lock.GetPrimaryLock()}
+			txns[lock.GetLockVersion()] = txn
+		}
+		txn.Locks++
+		if lock.GetLockTtl() > txn.TTL {
+			txn.TTL = lock.GetLockTtl()
+		}
+	}
+	result := make([]LongTxn, 0, len(txns))
+	for _, txn := range txns {
+		result = append(result, *txn)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].StartTS < result[j].StartTS })
+	return result
+}
+
+// LongTxnsError reports the long running transactions making the backup TS
+// unsafe.
+func LongTxnsError(backupTS uint64, txns []LongTxn) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d transactions before the backup TS %d are still running", len(txns), backupTS)
+	for i, txn := range txns {
+		if i == maxReportedLongTxns {
+			fmt.Fprintf(&b, "\n  and %d more", len(txns)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n  start ts %d (started at %s), primary %s, %d locks, expire at %s",
+			txn.StartTS, oracle.GetTimeFromTS(txn.StartTS).Format(time.RFC3339),
+			redact.Key(txn.Primary), txn.Locks, txn.ExpireAt().Format(time.RFC3339))
+	}
+	b.WriteString("\nplease use an earlier --backupts or --timeago, or wait longer by --resolve-locks-timeout")
+	return errors.Annotate(berrors.ErrBackupLongTxn, b.String())
+}
+
+// ResolveLocks resolves the locks before the backup TS in the ranges ahead of
+// the backup, so that the backup doesn't fail deep inside on the locks of the
+// large transactions. The alive transactions are waited until the timeout,
+// and the ones still running then are reported by the error.
+func (bc *Client) ResolveLocks(ctx context.Context, ranges []rtree.Range, backupTS uint64, timeout time.Duration) error {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	lockResolver := bc.mgr.GetLockResolver()
+	for {
+		var (
+			scanned  int
+			resolved int
+			waitMs   int64
+			alive    []*kvrpcpb.LockInfo
+		)
+		for _, r := range ranges {
+			err := bc.scanLocks(ctx, r.StartKey, r.EndKey, backupTS, func(locks []*kvrpcpb.LockInfo) error {
+				scanned += len(locks)
+				bo := tikv.NewBackoffer(ctx, resolveLockMaxBackoff)
+				toResolve := make([]*txnlock.Lock, 0, len(locks))
+				for _, lock := range locks {
+					toResolve = append(toResolve, txnlock.NewLock(lock))
+				}
+				msBeforeExpired, _, err := lockResolver.ResolveLocks(bo, backupTS, toResolve)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				if msBeforeExpired > 0 {
+					if waitMs == 0 || msBeforeExpired < waitMs {
+						waitMs = msBeforeExpired
+					}
+					alive = append(alive, locks...)
+				} else {
+					resolved += len(locks)
+				}
+				return nil
+			})
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		logutil.CL(ctx).Info("resolved the locks before backup",
+			zap.Uint64("backupTS", backupTS), zap.Int("scanned", scanned),
+			zap.Int("resolved", resolved), zap.Duration("take", time.Since(start)))
+		if len(alive) == 0 {
+			return nil
+		}
+
+		remain := time.Until(deadline)
+		if remain <= 0 {
+			txns := CollectLongTxns(alive)
+			for _, txn := range txns {
+				log.Warn("transaction before the backup TS is still running",
+					zap.Uint64("startTS", txn.StartTS), logutil.Key("primary", txn.Primary),
+					zap.Int("locks", txn.Locks), zap.Time("expireAt", txn.ExpireAt()))
+			}
+			return LongTxnsError(backupTS, txns)
+		}
+		wait := time.Duration(waitMs) * time.Millisecond
+		if wait > resolveLockRetryInterval {
+			wait = resolveLockRetryInterval
+		}
+		if wait > remain {
+			wait = remain
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// scanLocks scans the locks before the backup TS in [startKey, endKey) region
+// by region, and calls handle with the locks of every batch.
+func (bc *Client) scanLocks(
+	ctx context.Context,
+	startKey, endKey []byte,
+	backupTS uint64,
+	handle func([]*kvrpcpb.LockInfo) error,
+) error {
+	key := startKey
+	retry := 0
+	for len(endKey) == 0 || bytes.Compare(key, endKey) < 0 {
+		// Keys are saved in encoded format in TiKV, so the keys must be
+		// encoded in order to find the regions.
+		var encodedEnd []byte
+		if len(endKey) != 0 {
+			encodedEnd = codec.EncodeBytes([]byte{}, endKey)
+		}
+		regions, err := bc.mgr.GetPDClient().ScanRegions(
+			ctx, codec.EncodeBytes([]byte{}, key), encodedEnd, scanLockRegionLimit)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if len(regions) == 0 {
+			return errors.Annotatef(berrors.ErrPDUpdateFailed,
+				"no region found in [%s, %s)", redact.Key(key), redact.Key(endKey))
+		}
+		for _, region := range regions {
+			next, err := bc.scanRegionLocks(ctx, region, key, endKey, backupTS, handle)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if next == nil {
+				// the region has changed, find the regions again.
+				retry++
+				if retry > backupRetryTimes {
+					return errors.Annotatef(berrors.ErrBackupNoLeader,
+						"failed to scan locks from %s after %d retries", redact.Key(key), backupRetryTimes)
+				}
+				time.Sleep(time.Second)
+				break
+			}
+			retry = 0
+			key = next
+			if len(key) == 0 || (len(endKey) != 0 && bytes.Compare(key, endKey) >= 0) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// scanRegionLocks scans the locks in the region from the key, and returns the
+// end key of the region to continue with, which is empty if it's the last
+// region. It returns nil if the region has changed and needs to be found again.
+func (bc *Client) scanRegionLocks(
+	ctx context.Context,
+	region *pd.Region,
+	key, endKey []byte,
+	backupTS uint64,
+	handle func([]*kvrpcpb.LockInfo) error,
+) ([]byte, error) {
+	regionEnd := []byte{}
+	if len(region.Meta.GetEndKey()) != 0 {
+		var err error
+		if _, regionEnd, err = codec.DecodeBytes(region.Meta.GetEndKey(), nil); err != nil {
+			return nil, errors.Annotatef(berrors.ErrKVUnknown, "invalid end key of region %d", region.Meta.GetId())
+		}
+	}
+	scanEnd := regionEnd
+	if len(endKey) != 0 && (len(scanEnd) == 0 || bytes.Compare(endKey, scanEnd) < 0) {
+		scanEnd = endKey
+	}
+	if region.Leader == nil {
+		return nil, nil
+	}
+	cli, err := bc.mgr.GetTiKVClient(ctx, region.Leader.GetStoreId())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for {
+		resp, err := cli.KvScanLock(ctx, &kvrpcpb.ScanLockRequest{
+			Context: &kvrpcpb.Context{
+				RegionId:    region.Meta.GetId(),
+				RegionEpoch: region.Meta.GetRegionEpoch(),
+				Peer:        region.Leader,
+			},
+			MaxVersion: backupTS,
+			StartKey:   key,
+			EndKey:     scanEnd,
+			Limit:      scanLockLimit,
+		})
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to scan locks of region %d", region.Meta.GetId())
+		}
+		if regionErr := resp.GetRegionError(); regionErr != nil {
+			log.Warn("scan locks meet region error, retry",
+				zap.Uint64("regionID", region.Meta.GetId()), zap.Stringer("error", regionErr))
+			return nil, nil
+		}
+		if keyErr := resp.GetError(); keyErr != nil {
+			return nil, errors.Annotatef(berrors.ErrKVUnknown, "failed to scan locks of region %d: %s",
+				region.Meta.GetId(), keyErr.String())
+		}
+		locks := resp.GetLocks()
+		if len(locks) > 0 {
+			if err := handle(locks); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if len(locks) < scanLockLimit {
+			return regionEnd, nil
+		}
+		key = append(append([]byte{}, locks[len(locks)-1].GetKey()...), 0)
+	}
+}
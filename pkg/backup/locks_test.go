@@ -0,0 +1,37 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup_test
+
+import (
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/tikv/client-go/v2/oracle"
+
+	"github.com/pingcap/br/pkg/backup"
+)
+
+type testLocksSuite struct{}
+
+var _ = Suite(&testLocksSuite{})
+
+func (s *testLocksSuite) TestCollectLongTxns(c *C) {
+	startTS := oracle.ComposeTS(1600000000000, 0)
+	locks := []*kvrpcpb.LockInfo{
+		{LockVersion: startTS + 1, PrimaryLock: []byte("b"), Key: []byte("b"), LockTtl: 3000},
+		{LockVersion: startTS, PrimaryLock: []byte("a"), Key: []byte("a"), LockTtl: 1000},
+		{LockVersion: startTS + 1, PrimaryLock: []byte("b"), Key: []byte("c"), LockTtl: 5000},
+	}
+	txns := backup.CollectLongTxns(locks)
+	c.Assert(txns, DeepEquals, []backup.LongTxn{
+		{StartTS: startTS, Primary: []byte("a"), Locks: 1, TTL: 1000},
+		{StartTS: startTS + 1, Primary: []byte("b"), Locks: 2, TTL: 5000},
+	})
+	c.Assert(txns[1].ExpireAt().Sub(oracle.GetTimeFromTS(startTS)).Milliseconds(), Equals, int64(5000))
+
+	err := backup.LongTxnsError(startTS+2, txns)
+	c.Assert(err, ErrorMatches, fmt.Sprintf(
+		"(?s)2 transactions before the backup TS %d are still running.*start ts %d.*2 locks.*--resolve-locks-timeout.*",
+		startTS+2, startTS+1))
+}
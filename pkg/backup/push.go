@@ -17,7 +17,6 @@ import (
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/redact"
-	"github.com/pingcap/br/pkg/rtree"
 	"github.com/pingcap/br/pkg/utils"
 )
 
@@ -56,7 +55,7 @@ func (push *pushDown) pushBackup(
 	req backuppb.BackupRequest,
 	stores []*metapb.Store,
 	progressCallBack func(ProgressUnit),
-) (rtree.RangeTree, error) {
+) (*backupResults, error) {
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("pushDown.pushBackup", opentracing.ChildOf(span.Context()))
 		defer span1.Finish()
@@ -64,7 +63,7 @@ func (push *pushDown) pushBackup(
 	}
 
 	// Push down backup tasks to all tikv instances.
-	res := rtree.NewRangeTree()
+	res := newBackupResults()
 	failpoint.Inject("noop-backup", func(_ failpoint.Value) {
 		logutil.CL(ctx).Warn("skipping normal backup, jump to fine-grained backup, meow :3", logutil.Key("start-key", req.StartKey), logutil.Key("end-key", req.EndKey))
 		failpoint.Return(res, nil)
@@ -142,8 +141,7 @@ func (push *pushDown) pushBackup(
 			})
 			if resp.GetError() == nil {
 				// None error means range has been backuped successfully.
-				res.Put(
-					resp.GetStartKey(), resp.GetEndKey(), resp.GetFiles())
+				res.put(resp.GetStartKey(), resp.GetEndKey(), resp.GetFiles())
 
 				// Update progress
 				progressCallBack(RegionUnit)
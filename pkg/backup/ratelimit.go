@@ -0,0 +1,133 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// RateLimitWindow is the rate limit during a time window of the day, in the
+// local time.
+type RateLimitWindow struct {
+	// Start and End are the offsets of the window since the midnight, the
+	// window crosses the midnight if End is before Start.
+	Start, End time.Duration
+	// RateLimit is the rate limit during the window, 0 for unlimited.
+	RateLimit uint64
+}
+
+func (w RateLimitWindow) contains(offset time.Duration) bool {
+	if w.Start <= w.End {
+		return w.Start <= offset && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// RateLimitSchedule is the rate limits of the backup changing by the time of
+// the day, e.g. throttled during the business hours.
+type RateLimitSchedule struct {
+	// Windows are the rate limits during the time windows, the first matched
+	// one applies.
+	Windows []RateLimitWindow
+	// Default is the rate limit out of the windows, 0 for unlimited.
+	Default uint64
+}
+
+// ParseRateLimitSchedule parses the schedule like "09:00-21:00=50,21:00-23:00=200",
+// the rate limits are in the unit, e.g. MB/s.
+func ParseRateLimitSchedule(s string, defaultLimit, unit uint64) (*RateLimitSchedule, error) {
+	schedule := &RateLimitSchedule{Default: defaultLimit}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		window, err := parseRateLimitWindow(item, unit)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid rate limit window %s", item)
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+	return schedule, nil
+}
+
+func parseRateLimitWindow(item string, unit uint64) (RateLimitWindow, error) {
+	var w RateLimitWindow
+	eq := strings.LastIndexByte(item, '=')
+	dash := strings.IndexByte(item, '-')
+	if eq < 0 || dash < 0 || dash > eq {
+		return w, errors.Annotate(berrors.ErrInvalidArgument, "expect the format 'hh:mm-hh:mm=limit'")
+	}
+	var err error
+	if w.Start, err = parseTimeOfDay(item[:dash]); err != nil {
+		return w, errors.Trace(err)
+	}
+	if w.End, err = parseTimeOfDay(item[dash+1 : eq]); err != nil {
+		return w, errors.Trace(err)
+	}
+	if w.Start == w.End {
+		return w, errors.Annotate(berrors.ErrInvalidArgument, "the window is empty")
+	}
+	limit, err := strconv.ParseUint(strings.TrimSpace(item[eq+1:]), 10, 64)
+	if err != nil {
+		return w, errors.Annotate(berrors.ErrInvalidArgument, err.Error())
+	}
+	w.RateLimit = limit * unit
+	return w, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, errors.Annotatef(berrors.ErrInvalidArgument, "invalid time of day %s", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// RateLimitAt returns the rate limit at the time.
+func (s *RateLimitSchedule) RateLimitAt(t time.Time) uint64 {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	for _, w := range s.Windows {
+		if w.contains(offset) {
+			return w.RateLimit
+		}
+	}
+	return s.Default
+}
+
+// SetRateLimitSchedule makes each backup range rate limited by the schedule
+// at the time the range starts, instead of the rate limit of the request.
+// TiKV limits the rate of each backup request, so the ranges started during
+// the rate limited windows are backed up one by one, while the others are
+// still backed up concurrently.
+func (bc *Client) SetRateLimitSchedule(schedule *RateLimitSchedule) {
+	bc.rateLimitSchedule = schedule
+}
+
+// limitRate applies the rate limit of the schedule to the request, the
+// returned function must be called after the request is done.
+func (bc *Client) limitRate(req *backuppb.BackupRequest) (done func()) {
+	if bc.rateLimitSchedule == nil {
+		return func() {}
+	}
+	req.RateLimit = bc.rateLimitSchedule.RateLimitAt(time.Now())
+	if req.RateLimit == 0 {
+		return func() {}
+	}
+	bc.rateLimitMu.Lock()
+	// the window may be over while waiting for the other ranges.
+	req.RateLimit = bc.rateLimitSchedule.RateLimitAt(time.Now())
+	if req.RateLimit == 0 {
+		bc.rateLimitMu.Unlock()
+		return func() {}
+	}
+	return bc.rateLimitMu.Unlock
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup_test
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/backup"
+)
+
+type testRateLimitSuite struct{}
+
+var _ = Suite(&testRateLimitSuite{})
+
+func (s *testRateLimitSuite) TestRateLimitSchedule(c *C) {
+	schedule, err := backup.ParseRateLimitSchedule("09:00-21:00=50, 22:00-06:00=10", 0, 1)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.Windows, HasLen, 2)
+
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, 6, 1, hour, min, 0, 0, time.Local)
+	}
+	c.Assert(schedule.RateLimitAt(at(8, 59)), Equals, uint64(0))
+	c.Assert(schedule.RateLimitAt(at(9, 0)), Equals, uint64(50))
+	c.Assert(schedule.RateLimitAt(at(20, 59)), Equals, uint64(50))
+	c.Assert(schedule.RateLimitAt(at(21, 0)), Equals, uint64(0))
+	// the window crossing the midnight.
+	c.Assert(schedule.RateLimitAt(at(23, 30)), Equals, uint64(10))
+	c.Assert(schedule.RateLimitAt(at(5, 59)), Equals, uint64(10))
+	c.Assert(schedule.RateLimitAt(at(6, 0)), Equals, uint64(0))
+
+	schedule, err = backup.ParseRateLimitSchedule("09:00-21:00=50", 100, 1024)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.RateLimitAt(at(12, 0)), Equals, uint64(50*1024))
+	c.Assert(schedule.RateLimitAt(at(0, 0)), Equals, uint64(100))
+
+	for _, invalid := range []string{"09:00=50", "09:00-21:00", "9-21=50", "09:00-09:00=50", "09:00-21:00=fast"} {
+		_, err = backup.ParseRateLimitSchedule(invalid, 0, 1)
+		c.Assert(err, ErrorMatches, ".*invalid rate limit window.*", Commentf("%s", invalid))
+	}
+}
@@ -46,6 +46,10 @@ type scheamInfo struct {
 type Schemas struct {
 	// name -> schema
 	schemas map[string]*scheamInfo
+
+	events *EventEmitter
+	// checksumRead is the replicas the checksum requests read from.
+	checksumRead utils.ReplicaRead
 }
 
 func newBackupSchemas() *Schemas {
@@ -65,6 +69,17 @@ func (ss *Schemas) addSchema(
 	}
 }
 
+// SetEventEmitter sets the emitter publishing the checksummed tables.
+func (ss *Schemas) SetEventEmitter(events *EventEmitter) {
+	ss.events = events
+}
+
+// SetChecksumReplicaRead sets the replicas the checksum requests read from,
+// the checksum of a table falls back to the leaders if the replicas fail it.
+func (ss *Schemas) SetChecksumReplicaRead(replicaRead utils.ReplicaRead) {
+	ss.checksumRead = replicaRead
+}
+
 // BackupSchemas backups table info, including checksum and stats.
 func (ss *Schemas) BackupSchemas(
 	ctx context.Context,
@@ -105,7 +120,13 @@ func (ss *Schemas) BackupSchemas(
 				logger.Info("table checksum start")
 				start := time.Now()
 				checksumResp, err := calculateChecksum(
-					ectx, schema.tableInfo, store.GetClient(), backupTS, copConcurrency)
+					ectx, schema.tableInfo, store.GetClient(), backupTS, copConcurrency, ss.checksumRead)
+				if err != nil && !ss.checksumRead.IsLeader() && ectx.Err() == nil {
+					logger.Warn("table checksum from the replicas failed, fall back to the leaders",
+						zap.String("replica-read", string(ss.checksumRead)), logutil.ShortError(err))
+					checksumResp, err = calculateChecksum(
+						ectx, schema.tableInfo, store.GetClient(), backupTS, copConcurrency, utils.ReplicaReadLeader)
+				}
 				if err != nil {
 					return errors.Trace(err)
 				}
@@ -154,6 +175,14 @@ func (ss *Schemas) BackupSchemas(
 			if err := metaWriter.Send(s, op); err != nil {
 				return errors.Trace(err)
 			}
+			ss.events.Emit(&Event{
+				Type:       EventTableChecksummed,
+				DB:         schema.dbInfo.Name.O,
+				Table:      schema.tableInfo.Name.O,
+				Crc64Xor:   schema.crc64xor,
+				TotalKvs:   schema.totalKvs,
+				TotalBytes: schema.totalBytes,
+			})
 			updateCh.Inc()
 			return nil
 		})
@@ -177,9 +206,11 @@ func calculateChecksum(
 	client kv.Client,
 	backupTS uint64,
 	concurrency uint,
+	replicaRead utils.ReplicaRead,
 ) (*tipb.ChecksumResponse, error) {
 	exe, err := checksum.NewExecutorBuilder(table, backupTS).
 		SetConcurrency(concurrency).
+		SetReplicaRead(replicaRead).
 		Build()
 	if err != nil {
 		return nil, errors.Trace(err)
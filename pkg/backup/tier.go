@@ -0,0 +1,102 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/statistics"
+	"github.com/pingcap/tidb/statistics/handle"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/tikv/client-go/v2/oracle"
+	"go.uber.org/zap"
+)
+
+// ColdTierPrefix is the prefix the backup files of the cold tables are
+// written under, before the prefixes of the file layout. Like the file layout,
+// the prefix is recorded in the file names of the backupmeta, which tags the
+// files as cold, and the restore reads both tiers the same way.
+const ColdTierPrefix = "cold"
+
+// IsColdFile tells whether the backup file is in the cold tier.
+func IsColdFile(file *backuppb.File) bool {
+	return strings.HasPrefix(file.GetName(), ColdTierPrefix+"/")
+}
+
+// IsColdStats tells whether the physical table is cold by its stats, that is
+// the stats are not updated, by the modifications or analyzing, for coldAfter
+// before the backup TS. The tables without the stats are hot.
+func IsColdStats(stats *statistics.Table, backupTS uint64, coldAfter time.Duration) bool {
+	if stats == nil || stats.Pseudo || stats.Version == 0 {
+		return false
+	}
+	return oracle.GetTimeFromTS(backupTS).Sub(oracle.GetTimeFromTS(stats.Version)) >= coldAfter
+}
+
+// ColdPhysicalTables returns the physical tables in the schemas which are cold
+// at the backup TS by their stats.
+func (ss *Schemas) ColdPhysicalTables(
+	statsHandle *handle.Handle, backupTS uint64, coldAfter time.Duration,
+) (map[int64]struct{}, error) {
+	cold := make(map[int64]struct{})
+	for _, s := range ss.schemas {
+		ids := []int64{s.tableInfo.ID}
+		if pi := s.tableInfo.GetPartitionInfo(); pi != nil {
+			ids = ids[:0]
+			for _, def := range pi.Definitions {
+				ids = append(ids, def.ID)
+			}
+		}
+		for _, id := range ids {
+			stats, err := statsHandle.TableStatsFromStorage(s.tableInfo, id, false, backupTS)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if IsColdStats(stats, backupTS, coldAfter) {
+				cold[id] = struct{}{}
+			}
+		}
+	}
+	log.Info("found the cold tables by the stats", zap.Int("cold", len(cold)), zap.Duration("coldAfter", coldAfter))
+	return cold, nil
+}
+
+// SetColdTables writes the backup files of the physical tables under the
+// cold tier prefix, with the storage class if it's not empty and supported by
+// the storage, e.g. STANDARD_IA of S3.
+func (bc *Client) SetColdTables(cold map[int64]struct{}, storageClass string) {
+	bc.coldTables = cold
+	bc.coldStorageClass = storageClass
+}
+
+func (bc *Client) isCold(startKey []byte) bool {
+	if len(bc.coldTables) == 0 {
+		return false
+	}
+	_, ok := bc.coldTables[tablecodec.DecodeTableID(startKey)]
+	return ok
+}
+
+// BackendWithStorageClass returns the storage backend writing the files with
+// the storage class. The storages without the storage classes, e.g. local,
+// are returned as is.
+func BackendWithStorageClass(backend *backuppb.StorageBackend, storageClass string) *backuppb.StorageBackend {
+	if storageClass == "" {
+		return backend
+	}
+	b := proto.Clone(backend).(*backuppb.StorageBackend)
+	switch x := b.Backend.(type) {
+	case *backuppb.StorageBackend_S3:
+		x.S3.StorageClass = storageClass
+	case *backuppb.StorageBackend_Gcs:
+		x.Gcs.StorageClass = storageClass
+	default:
+		return backend
+	}
+	return b
+}
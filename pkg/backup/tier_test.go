@@ -0,0 +1,54 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup_test
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/tidb/statistics"
+	"github.com/tikv/client-go/v2/oracle"
+
+	"github.com/pingcap/br/pkg/backup"
+)
+
+type testTierSuite struct{}
+
+var _ = Suite(&testTierSuite{})
+
+func (s *testTierSuite) TestIsColdStats(c *C) {
+	now := time.Now()
+	backupTS := oracle.GoTimeToTS(now)
+	month := 30 * 24 * time.Hour
+
+	old := &statistics.Table{HistColl: statistics.HistColl{PhysicalID: 1}, Version: oracle.GoTimeToTS(now.Add(-2 * month))}
+	c.Assert(backup.IsColdStats(old, backupTS, month), IsTrue)
+	recent := &statistics.Table{HistColl: statistics.HistColl{PhysicalID: 2}, Version: oracle.GoTimeToTS(now.Add(-time.Hour))}
+	c.Assert(backup.IsColdStats(recent, backupTS, month), IsFalse)
+
+	// the tables without the stats are hot.
+	c.Assert(backup.IsColdStats(nil, backupTS, month), IsFalse)
+	old.Pseudo = true
+	c.Assert(backup.IsColdStats(old, backupTS, month), IsFalse)
+}
+
+func (s *testTierSuite) TestBackendWithStorageClass(c *C) {
+	s3 := &backuppb.StorageBackend{
+		Backend: &backuppb.StorageBackend_S3{S3: &backuppb.S3{Bucket: "bucket", Prefix: "backup/cold"}},
+	}
+	b := backup.BackendWithStorageClass(s3, "STANDARD_IA")
+	c.Assert(b.GetS3().GetStorageClass(), Equals, "STANDARD_IA")
+	c.Assert(b.GetS3().GetPrefix(), Equals, "backup/cold")
+	// the original backend is untouched.
+	c.Assert(s3.GetS3().GetStorageClass(), Equals, "")
+	c.Assert(backup.BackendWithStorageClass(s3, ""), Equals, s3)
+
+	local := &backuppb.StorageBackend{
+		Backend: &backuppb.StorageBackend_Local{Local: &backuppb.Local{Path: "/tmp/backup"}},
+	}
+	c.Assert(backup.BackendWithStorageClass(local, "STANDARD_IA"), Equals, local)
+
+	c.Assert(backup.IsColdFile(&backuppb.File{Name: backup.ColdTierPrefix + "/test/t/1_2_3.sst"}), IsTrue)
+	c.Assert(backup.IsColdFile(&backuppb.File{Name: "test/t/1_2_3.sst"}), IsFalse)
+}
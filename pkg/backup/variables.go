@@ -0,0 +1,139 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/meta"
+	"github.com/pingcap/tidb/table/tables"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/types"
+)
+
+// globalVariablesTable is the system table of the global variables set.
+const globalVariablesTable = "global_variables"
+
+// SnapshotVariables are the global variables recorded by the backup, which
+// change how the data is interpreted by the applications, e.g. the dates
+// by the time zone and the strings compared by the collations.
+var SnapshotVariables = []string{
+	"sql_mode",
+	"time_zone",
+	"character_set_server",
+	"character_set_database",
+	"collation_server",
+	"collation_database",
+	"default_week_format",
+	"div_precision_increment",
+	"tidb_enable_clustered_index",
+}
+
+// GetGlobalVariables returns the values of the global variables in the
+// mysql.global_variables table at the backupTS by the lower case names. The
+// variables not in the table are omitted.
+func GetGlobalVariables(store kv.Storage, backupTS uint64, names []string) (map[string]string, error) {
+	snapshot := store.GetSnapshot(kv.NewVersion(backupTS))
+	tableInfo, err := findSystemTable(meta.NewSnapshotMeta(snapshot), globalVariablesTable)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var nameCol, valueCol int64
+	cols := make(map[int64]*types.FieldType, len(tableInfo.Columns))
+	for _, col := range tableInfo.Columns {
+		switch col.Name.L {
+		case "variable_name":
+			nameCol = col.ID
+		case "variable_value":
+			valueCol = col.ID
+		}
+		cols[col.ID] = &col.FieldType
+	}
+	if nameCol == 0 || valueCol == 0 {
+		return nil, errors.Errorf("unexpected columns of table %s.%s", mysql.SystemDB, globalVariablesTable)
+	}
+	// the name is in the key rather than the row if it's a clustered index.
+	var handleCols []int64
+	if tableInfo.IsCommonHandle {
+		for _, col := range tables.FindPrimaryIndex(tableInfo).Columns {
+			handleCols = append(handleCols, tableInfo.Columns[col.Offset].ID)
+		}
+	}
+
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[strings.ToLower(name)] = struct{}{}
+	}
+	vars := make(map[string]string, len(names))
+	prefix := tablecodec.GenTableRecordPrefix(tableInfo.ID)
+	iter, err := snapshot.Iter(prefix, prefix.PrefixNext())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer iter.Close()
+	for iter.Valid() {
+		row, err := decodeRow(tableInfo, handleCols, cols, iter.Key(), iter.Value())
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		name := strings.ToLower(row[nameCol].GetString())
+		if value, ok := row[valueCol]; ok && !value.IsNull() {
+			if _, ok := wanted[name]; ok {
+				vars[name] = value.GetString()
+			}
+		}
+		if err = iter.Next(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return vars, nil
+}
+
+// decodeRow decodes the columns of a row, including those of the clustered
+// index in the key.
+func decodeRow(
+	tableInfo *model.TableInfo, handleCols []int64, cols map[int64]*types.FieldType, key kv.Key, value []byte,
+) (map[int64]types.Datum, error) {
+	row, err := tablecodec.DecodeRowToDatumMap(value, cols, time.UTC)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !tableInfo.IsCommonHandle {
+		return row, nil
+	}
+	_, handle, err := tablecodec.DecodeRecordKey(key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	row, err = tablecodec.DecodeHandleToDatumMap(handle, handleCols, cols, time.UTC, row)
+	return row, errors.Trace(err)
+}
+
+// findSystemTable returns the info of the table in the mysql schema.
+func findSystemTable(m *meta.Meta, name string) (*model.TableInfo, error) {
+	dbs, err := m.ListDatabases()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, db := range dbs {
+		if db.Name.L != mysql.SystemDB {
+			continue
+		}
+		tableInfos, err := m.ListTables(db.ID)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, tableInfo := range tableInfos {
+			if tableInfo.Name.L == name {
+				return tableInfo, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("table %s.%s not found", mysql.SystemDB, name)
+}
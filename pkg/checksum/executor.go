@@ -18,6 +18,8 @@ import (
 	"github.com/pingcap/tidb/util/ranger"
 	"github.com/pingcap/tipb/go-tipb"
 	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/utils"
 )
 
 // ExecutorBuilder is used to build a "kv.Request".
@@ -28,6 +30,7 @@ type ExecutorBuilder struct {
 	oldTable *metautil.Table
 
 	concurrency uint
+	replicaRead utils.ReplicaRead
 }
 
 // NewExecutorBuilder returns a new executor builder.
@@ -52,12 +55,21 @@ func (builder *ExecutorBuilder) SetConcurrency(conc uint) *ExecutorBuilder {
 	return builder
 }
 
+// SetReplicaRead sets the replicas the checksum requests read from.
+func (builder *ExecutorBuilder) SetReplicaRead(replicaRead utils.ReplicaRead) *ExecutorBuilder {
+	builder.replicaRead = replicaRead
+	return builder
+}
+
 // Build builds a checksum executor.
 func (builder *ExecutorBuilder) Build() (*Executor, error) {
 	reqs, err := buildChecksumRequest(builder.table, builder.oldTable, builder.ts, builder.concurrency)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	for _, req := range reqs {
+		builder.replicaRead.ApplyToRequest(req)
+	}
 	return &Executor{reqs: reqs}, nil
 }
 
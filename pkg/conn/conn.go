@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/tls"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,10 +26,13 @@ import (
 	"google.golang.org/grpc/keepalive"
 
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
 
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/grpcutil"
 	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/pdutil"
 	"github.com/pingcap/br/pkg/version"
@@ -110,6 +114,7 @@ type Mgr struct {
 		clis map[uint64]*grpc.ClientConn
 	}
 	keepalive   keepalive.ClientParameters
+	grpcCfg     grpcutil.Config
 	ownsStorage bool
 }
 
@@ -129,12 +134,62 @@ const (
 	TiFlashOnly StoreBehavior = 2
 )
 
+// StoreFilter is an extra condition of GetAllTiKVStores, only the stores the
+// filter returns true for are returned.
+type StoreFilter func(store *metapb.Store) bool
+
+// WithStoreLabels returns a StoreFilter keeping the stores having all the
+// labels.
+func WithStoreLabels(labels map[string]string) StoreFilter {
+	return func(store *metapb.Store) bool {
+		for key, value := range labels {
+			found := false
+			for _, label := range store.GetLabels() {
+				if label.GetKey() == key && label.GetValue() == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// UpStoresOnly is a StoreFilter skipping the stores which are not up, e.g.
+// the offline stores being drained.
+func UpStoresOnly(store *metapb.Store) bool {
+	return store.GetState() == metapb.StoreState_Up
+}
+
+// ParseStoreLabels parses the store label selector in the form of
+// `key1=value1,key2=value2`.
+func ParseStoreLabels(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if strings.TrimSpace(selector) == "" {
+		return labels, nil
+	}
+	for _, item := range strings.Split(selector, ",") {
+		pair := strings.SplitN(item, "=", 2)
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
+			return nil, errors.Annotatef(berrors.ErrInvalidArgument,
+				"invalid store label '%s', should be in the form of 'key=value'", item)
+		}
+		labels[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return labels, nil
+}
+
 // GetAllTiKVStores returns all TiKV stores registered to the PD client. The
 // stores must not be a tombstone and must never contain a label `engine=tiflash`.
+// The stores not passing all the filters are skipped.
 func GetAllTiKVStores(
 	ctx context.Context,
 	pdClient pd.Client,
 	storeBehavior StoreBehavior,
+	filters ...StoreFilter,
 ) ([]*metapb.Store, error) {
 	// get all live stores.
 	stores, err := pdClient.GetAllStores(ctx, pd.WithExcludeTombstone())
@@ -158,12 +213,24 @@ func GetAllTiKVStores(
 		if !isTiFlash && storeBehavior == TiFlashOnly {
 			continue
 		}
+		if !matchStoreFilters(store, filters) {
+			continue
+		}
 		stores[j] = store
 		j++
 	}
 	return stores[:j], nil
 }
 
+func matchStoreFilters(store *metapb.Store, filters []StoreFilter) bool {
+	for _, filter := range filters {
+		if !filter(store) {
+			return false
+		}
+	}
+	return true
+}
+
 // NewMgr creates a new Mgr.
 //
 // Domain is optional for Backup, set `needDomain` to false to disable
@@ -176,6 +243,7 @@ func NewMgr(
 	tlsConf *tls.Config,
 	securityOption pd.SecurityOption,
 	keepalive keepalive.ClientParameters,
+	grpcCfg grpcutil.Config,
 	storeBehavior StoreBehavior,
 	checkRequirements bool,
 	needDomain bool,
@@ -237,6 +305,7 @@ func NewMgr(
 	}
 	mgr.grpcClis.clis = make(map[uint64]*grpc.ClientConn)
 	mgr.keepalive = keepalive
+	mgr.grpcCfg = grpcCfg
 	return mgr, nil
 }
 
@@ -263,21 +332,21 @@ func (mgr *Mgr) getGrpcConnLocked(ctx context.Context, storeID uint64) (*grpc.Cl
 	if mgr.tlsConf != nil {
 		opt = grpc.WithTransportCredentials(credentials.NewTLS(mgr.tlsConf))
 	}
-	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	ctx, cancel := context.WithTimeout(ctx, mgr.grpcCfg.DialTimeoutOr(dialTimeout))
 	bfConf := backoff.DefaultConfig
 	bfConf.MaxDelay = time.Second * 3
 	addr := store.GetPeerAddress()
 	if addr == "" {
 		addr = store.GetAddress()
 	}
-	conn, err := grpc.DialContext(
-		ctx,
-		addr,
+	opts := append([]grpc.DialOption{
 		opt,
 		grpc.WithBlock(),
 		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bfConf}),
-		grpc.WithKeepaliveParams(mgr.keepalive),
-	)
+		grpc.WithKeepaliveParams(mgr.grpcCfg.KeepaliveOr(mgr.keepalive)),
+	}, mgr.grpcCfg.DialOptions()...)
+	opts = append(opts, grpcutil.InterceptorDialOptions()...)
+	conn, err := grpc.DialContext(ctx, addr, opts...)
 	cancel()
 	if err != nil {
 		return nil, berrors.ErrFailedToConnect.Wrap(err).GenWithStack("failed to make connection to store %d", storeID)
@@ -291,12 +360,35 @@ func (mgr *Mgr) GetBackupClient(ctx context.Context, storeID uint64) (backuppb.B
 		return nil, errors.Trace(ctx.Err())
 	}
 
+	conn, err := mgr.getCachedGrpcConn(ctx, storeID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return backuppb.NewBackupClient(conn), nil
+}
+
+// GetTiKVClient gets or creates a TiKV client of the store.
+func (mgr *Mgr) GetTiKVClient(ctx context.Context, storeID uint64) (tikvpb.TikvClient, error) {
+	if ctx.Err() != nil {
+		return nil, errors.Trace(ctx.Err())
+	}
+
+	conn, err := mgr.getCachedGrpcConn(ctx, storeID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return tikvpb.NewTikvClient(conn), nil
+}
+
+// getCachedGrpcConn returns the cached connection to the store, dialing and
+// caching a new one if there is none.
+func (mgr *Mgr) getCachedGrpcConn(ctx context.Context, storeID uint64) (*grpc.ClientConn, error) {
 	mgr.grpcClis.mu.Lock()
 	defer mgr.grpcClis.mu.Unlock()
 
 	if conn, ok := mgr.grpcClis.clis[storeID]; ok {
-		// Find a cached backup client.
-		return backuppb.NewBackupClient(conn), nil
+		// Find a cached client.
+		return conn, nil
 	}
 
 	conn, err := mgr.getGrpcConnLocked(ctx, storeID)
@@ -305,7 +397,43 @@ func (mgr *Mgr) GetBackupClient(ctx context.Context, storeID uint64) (backuppb.B
 	}
 	// Cache the conn.
 	mgr.grpcClis.clis[storeID] = conn
-	return backuppb.NewBackupClient(conn), nil
+	return conn, nil
+}
+
+// UnsafeDestroyRange removes all data in [startKey, endKey) from every TiKV
+// store directly, bypassing MVCC and raft. The keys are raw (not memcomparable
+// encoded) keys. It must only be used on ranges nobody else reads or writes,
+// such as the disposable tables created by benchmarks.
+func (mgr *Mgr) UnsafeDestroyRange(ctx context.Context, startKey, endKey []byte) error {
+	stores, err := GetAllTiKVStores(ctx, mgr.GetPDClient(), SkipTiFlash)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, store := range stores {
+		if store.GetState() != metapb.StoreState_Up {
+			continue
+		}
+		conn, err := mgr.getCachedGrpcConn(ctx, store.GetId())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		resp, err := tikvpb.NewTikvClient(conn).UnsafeDestroyRange(ctx, &kvrpcpb.UnsafeDestroyRangeRequest{
+			StartKey: startKey,
+			EndKey:   endKey,
+		})
+		if err != nil {
+			return errors.Annotatef(err, "failed to destroy range on store %d", store.GetId())
+		}
+		if resp.GetRegionError() != nil {
+			return errors.Annotatef(berrors.ErrKVUnknown, "failed to destroy range on store %d: %s",
+				store.GetId(), resp.GetRegionError().String())
+		}
+		if resp.GetError() != "" {
+			return errors.Annotatef(berrors.ErrKVUnknown, "failed to destroy range on store %d: %s",
+				store.GetId(), resp.GetError())
+		}
+	}
+	return nil
 }
 
 // ResetBackupClient reset the connection for backup client.
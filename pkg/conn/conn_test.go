@@ -4,6 +4,7 @@ package conn
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/pingcap/br/pkg/pdutil"
@@ -134,6 +135,44 @@ func (s *testClientSuite) TestGetAllTiKVStores(c *C) {
 	}
 }
 
+func (s *testClientSuite) TestGetAllTiKVStoresWithFilters(c *C) {
+	pdClient := fakePDClient{stores: []*metapb.Store{
+		{Id: 1, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "us-west-1"}}},
+		{Id: 2, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "us-west-1"}, {Key: "disk", Value: "ssd"}}},
+		{Id: 3, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "us-east-1"}, {Key: "disk", Value: "ssd"}}},
+		{Id: 4, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "us-west-1"}}, State: metapb.StoreState_Offline},
+		{Id: 5, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "us-west-1"}, {Key: "engine", Value: "tiflash"}}},
+	}}
+	storeIDs := func(filters ...StoreFilter) []uint64 {
+		stores, err := GetAllTiKVStores(context.Background(), pdClient, SkipTiFlash, filters...)
+		c.Assert(err, IsNil)
+		ids := make([]uint64, 0, len(stores))
+		for _, store := range stores {
+			ids = append(ids, store.Id)
+		}
+		return ids
+	}
+
+	c.Assert(storeIDs(), DeepEquals, []uint64{1, 2, 3, 4})
+	c.Assert(storeIDs(WithStoreLabels(map[string]string{"zone": "us-west-1"})), DeepEquals, []uint64{1, 2, 4})
+	c.Assert(storeIDs(WithStoreLabels(map[string]string{"zone": "us-west-1", "disk": "ssd"})), DeepEquals, []uint64{2})
+	c.Assert(storeIDs(UpStoresOnly, WithStoreLabels(map[string]string{"zone": "us-west-1"})), DeepEquals, []uint64{1, 2})
+	c.Assert(storeIDs(func(store *metapb.Store) bool { return store.Id%2 == 1 }), DeepEquals, []uint64{1, 3})
+}
+
+func (s *testClientSuite) TestParseStoreLabels(c *C) {
+	labels, err := ParseStoreLabels("")
+	c.Assert(err, IsNil)
+	c.Assert(labels, HasLen, 0)
+
+	labels, err = ParseStoreLabels("zone=us-west-1, disk = ssd")
+	c.Assert(err, IsNil)
+	c.Assert(labels, DeepEquals, map[string]string{"zone": "us-west-1", "disk": "ssd"})
+
+	_, err = ParseStoreLabels("zone")
+	c.Assert(err, ErrorMatches, "invalid store label 'zone'.*")
+}
+
 func (s *testClientSuite) TestGetConnOnCanceledContext(c *C) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
@@ -143,3 +182,19 @@ func (s *testClientSuite) TestGetConnOnCanceledContext(c *C) {
 	_, err = s.mgr.ResetBackupClient(ctx, 42)
 	c.Assert(err, ErrorMatches, ".*context canceled.*")
 }
+
+func (s *testClientSuite) TestParseRegionSplitConfig(c *C) {
+	size, keys, err := parseRegionSplitConfig(strings.NewReader(
+		`{"coprocessor":{"region-split-size":"96MiB","region-split-keys":960000}}`))
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, uint64(96*1024*1024))
+	c.Assert(keys, Equals, uint64(960000))
+
+	size, keys, err = parseRegionSplitConfig(strings.NewReader(`{"server":{}}`))
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, uint64(0))
+	c.Assert(keys, Equals, uint64(0))
+
+	_, _, err = parseRegionSplitConfig(strings.NewReader(`{"coprocessor":{"region-split-size":"huge"}}`))
+	c.Assert(err, ErrorMatches, ".*invalid region-split-size.*")
+}
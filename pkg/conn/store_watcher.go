@@ -0,0 +1,136 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package conn
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	pd "github.com/tikv/pd/client"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/redact"
+)
+
+// DefaultStoreWatchInterval is the default interval of refreshing the store
+// states from PD.
+const DefaultStoreWatchInterval = 30 * time.Second
+
+// StoreWatcher watches the states of the stores in PD during a task, so that
+// the task stops sending requests to the stores being drained or removed
+// instead of retrying against them. A nil StoreWatcher considers all stores up.
+type StoreWatcher struct {
+	pdClient pd.Client
+	interval time.Duration
+
+	mu     sync.RWMutex
+	states map[uint64]metapb.StoreState
+}
+
+// NewStoreWatcher creates a StoreWatcher refreshing the store states every
+// interval.
+func NewStoreWatcher(pdClient pd.Client, interval time.Duration) *StoreWatcher {
+	return &StoreWatcher{
+		pdClient: pdClient,
+		interval: interval,
+		states:   make(map[uint64]metapb.StoreState),
+	}
+}
+
+// Start loads the current store states, and keeps refreshing them in the
+// background until the context is done.
+func (w *StoreWatcher) Start(ctx context.Context) error {
+	if err := w.Refresh(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.Refresh(ctx); err != nil {
+					log.Warn("failed to refresh store states", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Refresh loads the store states from PD, and logs the stores whose states
+// are changed since the last refresh.
+func (w *StoreWatcher) Refresh(ctx context.Context) error {
+	stores, err := w.pdClient.GetAllStores(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	states := make(map[uint64]metapb.StoreState, len(stores))
+	for _, store := range stores {
+		states[store.GetId()] = store.GetState()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.states) > 0 {
+		for _, store := range stores {
+			old, ok := w.states[store.GetId()]
+			if !ok || old != store.GetState() {
+				log.Info("store topology changed",
+					zap.Uint64("storeID", store.GetId()),
+					zap.String("address", redact.String(store.GetAddress())),
+					zap.Stringer("from", old),
+					zap.Stringer("to", store.GetState()),
+					zap.Bool("new", !ok))
+			}
+		}
+		for storeID, old := range w.states {
+			if _, ok := states[storeID]; !ok {
+				if old != metapb.StoreState_Tombstone {
+					log.Info("store topology changed",
+						zap.Uint64("storeID", storeID),
+						zap.Stringer("from", old),
+						zap.Bool("removed", true))
+				}
+				// remember the removed store, so it's never considered up.
+				states[storeID] = metapb.StoreState_Tombstone
+			}
+		}
+	}
+	w.states = states
+	return nil
+}
+
+// IsDraining returns whether the store is being drained or has been removed.
+// The stores unknown to the last refresh are considered up.
+func (w *StoreWatcher) IsDraining(storeID uint64) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	state, ok := w.states[storeID]
+	return ok && state != metapb.StoreState_Up
+}
+
+// IsRemoved returns whether the store has been removed.
+func (w *StoreWatcher) IsRemoved(storeID uint64) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.states[storeID] == metapb.StoreState_Tombstone
+}
+
+// NotRemoved is a StoreFilter skipping the stores removed. The stores being
+// drained are kept, since they still serve the leaders not transferred yet.
+func (w *StoreWatcher) NotRemoved(store *metapb.Store) bool {
+	return !w.IsRemoved(store.GetId())
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package conn
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+)
+
+type testStoreWatcherSuite struct{}
+
+var _ = Suite(&testStoreWatcherSuite{})
+
+func (s *testStoreWatcherSuite) TestStoreWatcher(c *C) {
+	ctx := context.Background()
+	pdClient := &fakePDClient{stores: []*metapb.Store{
+		{Id: 1, State: metapb.StoreState_Up},
+		{Id: 2, State: metapb.StoreState_Up},
+		{Id: 3, State: metapb.StoreState_Up},
+	}}
+	watcher := NewStoreWatcher(pdClient, time.Hour)
+	c.Assert(watcher.Refresh(ctx), IsNil)
+	for _, id := range []uint64{1, 2, 3, 4} {
+		c.Assert(watcher.IsDraining(id), IsFalse)
+	}
+
+	// store 2 is being drained and store 3 is removed.
+	pdClient.stores = []*metapb.Store{
+		{Id: 1, State: metapb.StoreState_Up},
+		{Id: 2, State: metapb.StoreState_Offline},
+		{Id: 4, State: metapb.StoreState_Up},
+	}
+	c.Assert(watcher.Refresh(ctx), IsNil)
+	c.Assert(watcher.IsDraining(1), IsFalse)
+	c.Assert(watcher.IsDraining(2), IsTrue)
+	c.Assert(watcher.IsDraining(3), IsTrue)
+	c.Assert(watcher.IsDraining(4), IsFalse)
+	c.Assert(watcher.IsRemoved(2), IsFalse)
+	c.Assert(watcher.IsRemoved(3), IsTrue)
+
+	// the draining store still serves the leaders.
+	stores, err := GetAllTiKVStores(ctx, pdClient, SkipTiFlash, watcher.NotRemoved)
+	c.Assert(err, IsNil)
+	c.Assert(stores, HasLen, 3)
+	c.Assert(stores[0].Id, Equals, uint64(1))
+	c.Assert(stores[1].Id, Equals, uint64(2))
+	c.Assert(stores[2].Id, Equals, uint64(4))
+
+	var nilWatcher *StoreWatcher
+	c.Assert(nilWatcher.IsDraining(2), IsFalse)
+	c.Assert(nilWatcher.IsRemoved(3), IsFalse)
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package conn
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/version"
+)
+
+const tikvConfigTimeout = 10 * time.Second
+
+// GetTopology returns the TiKV stores and the region settings of the
+// cluster. The settings failed to get are left unknown.
+func (mgr *Mgr) GetTopology(ctx context.Context) (*metautil.Topology, error) {
+	stores, err := mgr.HTTPClient().GetStores(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	topology := &metautil.Topology{}
+	var statusAddr string
+	for _, info := range stores.Stores {
+		store := info.Store.Store
+		if store.GetState() == metapb.StoreState_Tombstone || version.IsTiFlash(store) {
+			continue
+		}
+		st := &metautil.StoreTopology{
+			ID:      store.GetId(),
+			Address: store.GetAddress(),
+			Version: store.GetVersion(),
+		}
+		for _, label := range store.GetLabels() {
+			if st.Labels == nil {
+				st.Labels = make(map[string]string)
+			}
+			st.Labels[label.GetKey()] = label.GetValue()
+		}
+		if info.Status != nil {
+			st.Capacity = uint64(info.Status.Capacity)
+		}
+		topology.Stores = append(topology.Stores, st)
+		if statusAddr == "" && store.GetState() == metapb.StoreState_Up {
+			statusAddr = store.GetStatusAddress()
+		}
+	}
+	if replicate, err := mgr.HTTPClient().GetReplicateConfig(ctx); err == nil {
+		topology.MaxReplicas = replicate.MaxReplicas
+	} else {
+		log.Warn("failed to get the replication config", zap.Error(err))
+	}
+	if statusAddr != "" {
+		size, keys, err := mgr.getRegionSplitConfig(ctx, statusAddr)
+		if err != nil {
+			log.Warn("failed to get the region split config of TiKV", zap.String("status", statusAddr), zap.Error(err))
+		}
+		topology.RegionSplitSize, topology.RegionSplitKeys = size, keys
+	}
+	return topology, nil
+}
+
+// getRegionSplitConfig reads the region split config from the status address
+// of a TiKV, assuming the TiKVs are configured the same.
+func (mgr *Mgr) getRegionSplitConfig(ctx context.Context, statusAddr string) (size, keys uint64, err error) {
+	scheme := "http"
+	client := &http.Client{Timeout: tikvConfigTimeout}
+	if tlsConf := mgr.GetTLSConfig(); tlsConf != nil {
+		scheme = "https"
+		client.Transport = &http.Transport{TLSClientConfig: tlsConf}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+statusAddr+"/config", nil)
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, errors.Annotatef(berrors.ErrKVUnknown, "unexpected status %s of the TiKV config", resp.Status)
+	}
+	return parseRegionSplitConfig(resp.Body)
+}
+
+type tikvCoprocessorConfig struct {
+	Coprocessor struct {
+		RegionSplitSize string `json:"region-split-size"`
+		RegionSplitKeys uint64 `json:"region-split-keys"`
+	} `json:"coprocessor"`
+}
+
+func parseRegionSplitConfig(r io.Reader) (size, keys uint64, err error) {
+	cfg := &tikvCoprocessorConfig{}
+	if err = json.NewDecoder(r).Decode(cfg); err != nil {
+		return 0, 0, errors.Annotatef(berrors.ErrKVUnknown, "invalid TiKV config: %v", err)
+	}
+	if cfg.Coprocessor.RegionSplitSize != "" {
+		// TiKV encodes the sizes like "96MiB".
+		bytes, err := units.RAMInBytes(cfg.Coprocessor.RegionSplitSize)
+		if err != nil {
+			return 0, 0, errors.Annotatef(berrors.ErrKVUnknown, "invalid region-split-size: %v", err)
+		}
+		size = uint64(bytes)
+	}
+	return size, cfg.Coprocessor.RegionSplitKeys, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package errors
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+)
+
+// ErrorClass is a stable class of the failures of BR, so the orchestration
+// tools can branch on the type of a failure by the exit code of the process or
+// the `error.code` field of the log, instead of matching the error messages.
+//
+// The codes and exit codes of the classes never change once released.
+type ErrorClass struct {
+	// Code is the name of the class in the `error.code` field of the log.
+	Code string
+	// ExitCode is the exit code of the process failed with the class.
+	ExitCode int
+
+	errs []*errors.Error
+}
+
+// The error classes. An error matching none of them is of ClassUnknown.
+var (
+	// ClassUnknown is the failures not classified, its exit code is the same
+	// as before the classes are introduced.
+	ClassUnknown = ErrorClass{Code: "unknown", ExitCode: 1}
+	// ClassInvalidArgument is the invalid flags or config.
+	ClassInvalidArgument = ErrorClass{Code: "invalid-argument", ExitCode: 2, errs: []*errors.Error{
+		ErrInvalidArgument, ErrUndefinedRestoreDbOrTable, ErrStorageInvalidConfig,
+	}}
+	// ClassVersionMismatch is the cluster version incompatible with BR.
+	ClassVersionMismatch = ErrorClass{Code: "version-mismatch", ExitCode: 3, errs: []*errors.Error{
+		ErrVersionMismatch, ErrMetaVersionUnsupported,
+	}}
+	// ClassStoragePermission is the external storage denying the access.
+	ClassStoragePermission = ErrorClass{Code: "storage-permission", ExitCode: 4, errs: []*errors.Error{
+		ErrStorageInvalidPermission,
+	}}
+	// ClassStorage is the other failures of the external storage.
+	ClassStorage = ErrorClass{Code: "storage-error", ExitCode: 5, errs: []*errors.Error{
+		ErrStorageUnknown,
+	}}
+	// ClassChecksumMismatch is the data of the backup or the restored tables
+	// failing the checksum.
+	ClassChecksumMismatch = ErrorClass{Code: "checksum-mismatch", ExitCode: 6, errs: []*errors.Error{
+		ErrBackupChecksumMismatch, ErrRestoreChecksumMismatch,
+	}}
+	// ClassGCSafepointExceeded is the backup TS older than the GC safe point.
+	ClassGCSafepointExceeded = ErrorClass{Code: "gc-safepoint-exceeded", ExitCode: 7, errs: []*errors.Error{
+		ErrBackupGCSafepointExceeded,
+	}}
+	// ClassConnection is the failures to connect to the cluster.
+	ClassConnection = ErrorClass{Code: "connection-failed", ExitCode: 8, errs: []*errors.Error{
+		ErrFailedToConnect, ErrPDLeaderNotFound, ErrKVClusterIDMismatch, ErrKVNotTiKV,
+	}}
+	// ClassInvalidBackup is the backup which can't be restored.
+	ClassInvalidBackup = ErrorClass{Code: "invalid-backup", ExitCode: 9, errs: []*errors.Error{
+		ErrInvalidMetaFile, ErrRestoreInvalidBackup, ErrRestoreModeMismatch, ErrRestoreTableIDMismatch,
+	}}
+	// ClassRestoreConflict is the restore target conflicting with the backup.
+	ClassRestoreConflict = ErrorClass{Code: "restore-conflict", ExitCode: 10, errs: []*errors.Error{
+		ErrRestoreTableOverlap, ErrUnsupportedSystemTable,
+	}}
+	// ClassKV is the failures reported by TiKV.
+	ClassKV = ErrorClass{Code: "tikv-error", ExitCode: 11, errs: []*errors.Error{
+		ErrKVStorage, ErrKVUnknown, ErrKVNotLeader, ErrKVEpochNotMatch, ErrKVKeyNotInRegion,
+		ErrKVRewriteRuleNotFound, ErrKVRangeIsEmpty, ErrKVDownloadFailed, ErrKVIngestFailed,
+	}}
+	// ClassCanceled is the task canceled, e.g. by a signal. The exit code
+	// follows the convention of the shells for an interrupted process.
+	ClassCanceled = ErrorClass{Code: "canceled", ExitCode: 130}
+
+	errorClasses = []ErrorClass{
+		ClassInvalidArgument,
+		ClassVersionMismatch,
+		ClassStoragePermission,
+		ClassStorage,
+		ClassChecksumMismatch,
+		ClassGCSafepointExceeded,
+		ClassConnection,
+		ClassInvalidBackup,
+		ClassRestoreConflict,
+		ClassKV,
+	}
+)
+
+// ClassOf returns the class of the error.
+func ClassOf(err error) ErrorClass {
+	for _, class := range errorClasses {
+		for _, e := range class.errs {
+			if Is(err, e) {
+				return class
+			}
+		}
+	}
+	if errors.Cause(err) == context.Canceled {
+		return ClassCanceled
+	}
+	return ClassUnknown
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package errors_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type testErrorClassSuite struct{}
+
+var _ = Suite(&testErrorClassSuite{})
+
+func (s *testErrorClassSuite) TestClassOf(c *C) {
+	err := errors.Annotate(berrors.ErrRestoreChecksumMismatch, "table `a`.`b`")
+	c.Assert(berrors.ClassOf(errors.Trace(err)), DeepEquals, berrors.ClassChecksumMismatch)
+	err = errors.Annotatef(berrors.ErrStorageInvalidPermission, "check permission %s failed", "PutObject")
+	c.Assert(berrors.ClassOf(err).Code, Equals, "storage-permission")
+	c.Assert(berrors.ClassOf(berrors.ErrBackupGCSafepointExceeded).ExitCode, Equals, 7)
+	c.Assert(berrors.ClassOf(errors.Trace(context.Canceled)), DeepEquals, berrors.ClassCanceled)
+	c.Assert(berrors.ClassOf(errors.New("oops")), DeepEquals, berrors.ClassUnknown)
+
+	// the codes and the exit codes must be unique.
+	codes := make(map[string]struct{})
+	exitCodes := make(map[int]struct{})
+	for _, class := range []berrors.ErrorClass{
+		berrors.ClassUnknown, berrors.ClassInvalidArgument, berrors.ClassVersionMismatch,
+		berrors.ClassStoragePermission, berrors.ClassStorage, berrors.ClassChecksumMismatch,
+		berrors.ClassGCSafepointExceeded, berrors.ClassConnection, berrors.ClassInvalidBackup,
+		berrors.ClassRestoreConflict, berrors.ClassKV, berrors.ClassCanceled,
+	} {
+		codes[class.Code] = struct{}{}
+		exitCodes[class.ExitCode] = struct{}{}
+	}
+	c.Assert(codes, HasLen, 12)
+	c.Assert(exitCodes, HasLen, 12)
+}
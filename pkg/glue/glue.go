@@ -8,10 +8,16 @@ import (
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/domain"
 	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/sessionctx"
 	pd "github.com/tikv/pd/client"
 )
 
 // Glue is an abstraction of TiDB function calls used in BR.
+//
+// A process embedding BR (e.g. TiDB executing BACKUP and RESTORE statements)
+// provides its own implementation, so that BR reuses the domain, sessions and
+// progress reporting of the host instead of creating them by itself. Hence BR
+// must never construct sessions or domains directly but always through Glue.
 type Glue interface {
 	GetDomain(store kv.Storage) (*domain.Domain, error)
 	CreateSession(store kv.Storage) (Session, error)
@@ -38,6 +44,31 @@ type Session interface {
 	Close()
 }
 
+// TxnSession is a Session which can execute several statements inside one
+// transaction. It is an optional extension of Session.
+type TxnSession interface {
+	Session
+	// ExecuteInTxn executes the statements in a single transaction. If any of
+	// them fails, the transaction is rolled back.
+	ExecuteInTxn(ctx context.Context, sqls ...string) error
+}
+
+// BatchCreateTableSession is a Session which can create several tables in one
+// round-trip. It is an optional extension of Session, implemented only with
+// the TiDB versions supporting it.
+type BatchCreateTableSession interface {
+	Session
+	// CreateTables creates the tables of each database if they don't exist.
+	CreateTables(ctx context.Context, tables map[string][]*model.TableInfo) error
+}
+
+// SessionCtxProvider is implemented by the sessions backed by an in-process
+// TiDB session. It is an optional extension of Session.
+type SessionCtxProvider interface {
+	// GetSessionCtx returns the underlying session context.
+	GetSessionCtx() sessionctx.Context
+}
+
 // Progress is an interface recording the current execution progress.
 type Progress interface {
 	// Inc increases the progress. This method must be goroutine-safe, and can
@@ -47,3 +78,18 @@ type Progress interface {
 	// called.
 	Close()
 }
+
+// BytesProgress is a Progress which also records the processed bytes. It is
+// an optional extension of Progress.
+type BytesProgress interface {
+	Progress
+	// AddBytes adds the processed bytes. This method must be goroutine-safe.
+	AddBytes(n int64)
+}
+
+// AddProgressBytes records the processed bytes if the progress supports it.
+func AddProgressBytes(p Progress, n int64) {
+	if bp, ok := p.(BytesProgress); ok {
+		bp.AddBytes(n)
+	}
+}
@@ -5,6 +5,7 @@ package gluetidb
 import (
 	"bytes"
 	"context"
+	"strings"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
@@ -19,6 +20,7 @@ import (
 	"github.com/pingcap/tidb/session"
 	"github.com/pingcap/tidb/sessionctx"
 	pd "github.com/tikv/pd/client"
+	"go.uber.org/zap"
 
 	"github.com/pingcap/br/pkg/glue"
 	"github.com/pingcap/br/pkg/gluetikv"
@@ -39,18 +41,66 @@ func New() Glue {
 	return Glue{}
 }
 
+// Host is the set of services provided by a process embedding BR in-process.
+// Every nil field falls back to the behavior of the standalone BR.
+type Host struct {
+	// Store is the storage of the host. It is returned by Open() and is never
+	// closed by BR.
+	Store kv.Storage
+	// Domain is the running domain of the host. BR reuses it instead of
+	// bootstrapping its own domain.
+	Domain *domain.Domain
+	// CreateSession creates a session within the host.
+	CreateSession func(store kv.Storage) (session.Session, error)
+	// StartProgress reports the progress of the BR tasks to the host.
+	StartProgress func(ctx context.Context, cmdName string, total int64) glue.Progress
+	// Record reports the summary information to the host.
+	Record func(name string, value uint64)
+
+	// Socket is the path of the Unix domain socket of the host TiDB, for the
+	// hosting processes running on the same machine but not in-process. If
+	// CreateSession is nil, the sessions execute the statements through it.
+	Socket string
+	// User and Password authenticate the sessions through Socket.
+	User     string
+	Password string
+}
+
+// NewEmbedded makes a new tidb glue running inside a hosting process.
+func NewEmbedded(host *Host) Glue {
+	return Glue{host: host}
+}
+
 // Glue is an implementation of glue.Glue using a new TiDB session.
 type Glue struct {
 	tikvGlue gluetikv.Glue
+	host     *Host
 }
 
 type tidbSession struct {
 	se session.Session
 }
 
+// batchCreateTableDDL is implemented by the DDL of the TiDB versions which
+// can create several tables in one job.
+type batchCreateTableDDL interface {
+	BatchCreateTableWithInfo(ctx sessionctx.Context, schema model.CIStr, info []*model.TableInfo, onExist ddl.OnExist) error
+}
+
+// tidbBatchSession is a tidbSession whose DDL supports creating the tables in
+// batch.
+type tidbBatchSession struct {
+	*tidbSession
+	ddl batchCreateTableDDL
+}
+
 // GetDomain implements glue.Glue.
-func (Glue) GetDomain(store kv.Storage) (*domain.Domain, error) {
-	se, err := session.CreateSession(store)
+func (g Glue) GetDomain(store kv.Storage) (*domain.Domain, error) {
+	if g.host != nil && g.host.Domain != nil {
+		// the host is responsible for maintaining the stats handler.
+		return g.host.Domain, nil
+	}
+	se, err := g.createSession(store)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -67,34 +117,57 @@ func (Glue) GetDomain(store kv.Storage) (*domain.Domain, error) {
 }
 
 // CreateSession implements glue.Glue.
-func (Glue) CreateSession(store kv.Storage) (glue.Session, error) {
-	se, err := session.CreateSession(store)
+func (g Glue) CreateSession(store kv.Storage) (glue.Session, error) {
+	if g.host != nil && g.host.CreateSession == nil && g.host.Socket != "" {
+		return newSocketSession(context.Background(), g.host)
+	}
+	se, err := g.createSession(store)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	tiSession := &tidbSession{
 		se: se,
 	}
+	if d, ok := domain.GetDomain(se).DDL().(batchCreateTableDDL); ok {
+		return &tidbBatchSession{tidbSession: tiSession, ddl: d}, nil
+	}
 	return tiSession, nil
 }
 
+func (g Glue) createSession(store kv.Storage) (session.Session, error) {
+	if g.host != nil && g.host.CreateSession != nil {
+		return g.host.CreateSession(store)
+	}
+	return session.CreateSession(store)
+}
+
 // Open implements glue.Glue.
 func (g Glue) Open(path string, option pd.SecurityOption) (kv.Storage, error) {
+	if g.host != nil && g.host.Store != nil {
+		return g.host.Store, nil
+	}
 	return g.tikvGlue.Open(path, option)
 }
 
 // OwnsStorage implements glue.Glue.
-func (Glue) OwnsStorage() bool {
-	return true
+func (g Glue) OwnsStorage() bool {
+	return g.host == nil || g.host.Store == nil
 }
 
 // StartProgress implements glue.Glue.
 func (g Glue) StartProgress(ctx context.Context, cmdName string, total int64, redirectLog bool) glue.Progress {
+	if g.host != nil && g.host.StartProgress != nil {
+		return g.host.StartProgress(ctx, cmdName, total)
+	}
 	return g.tikvGlue.StartProgress(ctx, cmdName, total, redirectLog)
 }
 
 // Record implements glue.Glue.
 func (g Glue) Record(name string, value uint64) {
+	if g.host != nil && g.host.Record != nil {
+		g.host.Record(name, value)
+		return
+	}
 	g.tikvGlue.Record(name, value)
 }
 
@@ -109,10 +182,32 @@ func (gs *tidbSession) Execute(ctx context.Context, sql string) error {
 	return errors.Trace(err)
 }
 
+// ExecuteInTxn implements glue.TxnSession.
+func (gs *tidbSession) ExecuteInTxn(ctx context.Context, sqls ...string) error {
+	if _, err := gs.se.ExecuteInternal(ctx, "BEGIN"); err != nil {
+		return errors.Trace(err)
+	}
+	for _, sql := range sqls {
+		if _, err := gs.se.ExecuteInternal(ctx, sql); err != nil {
+			if _, rbErr := gs.se.ExecuteInternal(ctx, "ROLLBACK"); rbErr != nil {
+				log.Warn("failed to rollback transaction", zap.Error(rbErr))
+			}
+			return errors.Trace(err)
+		}
+	}
+	_, err := gs.se.ExecuteInternal(ctx, "COMMIT")
+	return errors.Trace(err)
+}
+
+// GetSessionCtx implements glue.SessionCtxProvider.
+func (gs *tidbSession) GetSessionCtx() sessionctx.Context {
+	return gs.se
+}
+
 // CreateDatabase implements glue.Session.
 func (gs *tidbSession) CreateDatabase(ctx context.Context, schema *model.DBInfo) error {
 	d := domain.GetDomain(gs.se).DDL()
-	query, err := gs.showCreateDatabase(schema)
+	query, err := showCreateDatabase(gs.se, schema)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -127,11 +222,38 @@ func (gs *tidbSession) CreateDatabase(ctx context.Context, schema *model.DBInfo)
 // CreateTable implements glue.Session.
 func (gs *tidbSession) CreateTable(ctx context.Context, dbName model.CIStr, table *model.TableInfo) error {
 	d := domain.GetDomain(gs.se).DDL()
-	query, err := gs.showCreateTable(table)
+	query, err := showCreateTable(gs.se, table)
 	if err != nil {
 		return errors.Trace(err)
 	}
 	gs.se.SetValue(sessionctx.QueryString, query)
+	return d.CreateTableWithInfo(gs.se, dbName, cloneTableInfo(table), ddl.OnExistIgnore, true)
+}
+
+// CreateTables implements glue.BatchCreateTableSession.
+func (gs *tidbBatchSession) CreateTables(ctx context.Context, tables map[string][]*model.TableInfo) error {
+	for db, infos := range tables {
+		var query strings.Builder
+		cloned := make([]*model.TableInfo, 0, len(infos))
+		for _, table := range infos {
+			q, err := showCreateTable(gs.se, table)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			query.WriteString(q)
+			query.WriteString(";")
+			cloned = append(cloned, cloneTableInfo(table))
+		}
+		gs.se.SetValue(sessionctx.QueryString, query.String())
+		if err := gs.ddl.BatchCreateTableWithInfo(gs.se, model.NewCIStr(db), cloned, ddl.OnExistIgnore); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// cloneTableInfo clones the table info with its partitions.
+func cloneTableInfo(table *model.TableInfo) *model.TableInfo {
 	// Clone() does not clone partitions yet :(
 	table = table.Clone()
 	if table.Partition != nil {
@@ -139,7 +261,7 @@ func (gs *tidbSession) CreateTable(ctx context.Context, dbName model.CIStr, tabl
 		newPartition.Definitions = append([]model.PartitionDefinition{}, table.Partition.Definitions...)
 		table.Partition = &newPartition
 	}
-	return d.CreateTableWithInfo(gs.se, dbName, table, ddl.OnExistIgnore, true)
+	return table
 }
 
 // Close implements glue.Session.
@@ -148,24 +270,24 @@ func (gs *tidbSession) Close() {
 }
 
 // showCreateTable shows the result of SHOW CREATE TABLE from a TableInfo.
-func (gs *tidbSession) showCreateTable(tbl *model.TableInfo) (string, error) {
+func showCreateTable(sctx sessionctx.Context, tbl *model.TableInfo) (string, error) {
 	table := tbl.Clone()
 	table.AutoIncID = 0
 	result := bytes.NewBuffer(make([]byte, 0, defaultCapOfCreateTable))
 	// this can never fail.
 	_, _ = result.WriteString(brComment)
-	if err := executor.ConstructResultOfShowCreateTable(gs.se, tbl, autoid.Allocators{}, result); err != nil {
+	if err := executor.ConstructResultOfShowCreateTable(sctx, tbl, autoid.Allocators{}, result); err != nil {
 		return "", errors.Trace(err)
 	}
 	return result.String(), nil
 }
 
 // showCreateDatabase shows the result of SHOW CREATE DATABASE from a dbInfo.
-func (gs *tidbSession) showCreateDatabase(db *model.DBInfo) (string, error) {
+func showCreateDatabase(sctx sessionctx.Context, db *model.DBInfo) (string, error) {
 	result := bytes.NewBuffer(make([]byte, 0, defaultCapOfCreateDatabase))
 	// this can never fail.
 	_, _ = result.WriteString(brComment)
-	if err := executor.ConstructResultOfShowCreateDatabase(gs.se, db, true, result); err != nil {
+	if err := executor.ConstructResultOfShowCreateDatabase(sctx, db, true, result); err != nil {
 		return "", errors.Trace(err)
 	}
 	return result.String(), nil
@@ -0,0 +1,112 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package gluetidb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	tmysql "github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/util/mock"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// socketSession is a glue.Session executing the statements on the host TiDB
+// through its Unix domain socket. It keeps a single connection, so that the
+// session states like the current database are kept between the statements.
+type socketSession struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+var _ glue.TxnSession = (*socketSession)(nil)
+
+func newSocketSession(ctx context.Context, host *Host) (*socketSession, error) {
+	cfg := gomysql.NewConfig()
+	cfg.Net = "unix"
+	cfg.Addr = host.Socket
+	cfg.User = host.User
+	cfg.Passwd = host.Password
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.Annotatef(err, "connect to the host through socket %s", host.Socket)
+	}
+	return &socketSession{db: db, conn: conn}, nil
+}
+
+// Execute implements glue.Session.
+func (ss *socketSession) Execute(ctx context.Context, sql string) error {
+	_, err := ss.conn.ExecContext(ctx, sql)
+	return errors.Trace(err)
+}
+
+// ExecuteInTxn implements glue.TxnSession.
+func (ss *socketSession) ExecuteInTxn(ctx context.Context, sqls ...string) error {
+	txn, err := ss.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, sql := range sqls {
+		if _, err := txn.ExecContext(ctx, sql); err != nil {
+			if rbErr := txn.Rollback(); rbErr != nil {
+				log.Warn("failed to rollback transaction", zap.Error(rbErr))
+			}
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(txn.Commit())
+}
+
+// CreateDatabase implements glue.Session.
+func (ss *socketSession) CreateDatabase(ctx context.Context, schema *model.DBInfo) error {
+	schema = schema.Clone()
+	if len(schema.Charset) == 0 {
+		schema.Charset = tmysql.DefaultCharset
+	}
+	// the statements are only built here, so a mock context is enough.
+	query, err := showCreateDatabase(mock.NewContext(), schema)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return ss.Execute(ctx, query)
+}
+
+// CreateTable implements glue.Session.
+func (ss *socketSession) CreateTable(ctx context.Context, dbName model.CIStr, table *model.TableInfo) error {
+	query, err := showCreateTable(mock.NewContext(), table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = ss.Execute(ctx, fmt.Sprintf("USE %s;", utils.EncloseName(dbName.O))); err != nil {
+		return errors.Trace(err)
+	}
+	err = ss.Execute(ctx, query)
+	// the same as ddl.OnExistIgnore used by the in-process sessions.
+	if myErr, ok := errors.Cause(err).(*gomysql.MySQLError); ok && myErr.Number == tmysql.ErrTableExists {
+		log.Info("table already exists, skip creating",
+			zap.Stringer("database", dbName), zap.Stringer("table", table.Name))
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+// Close implements glue.Session.
+func (ss *socketSession) Close() {
+	if err := ss.conn.Close(); err != nil {
+		log.Warn("failed to close the connection to the host", zap.Error(err))
+	}
+	_ = ss.db.Close()
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package grpcutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// Config is the tunable options of the gRPC connections to the TiKV stores.
+// The zero value keeps the gRPC defaults.
+type Config struct {
+	// MaxRecvMsgSize and MaxSendMsgSize are the max sizes of a received and a
+	// sent message in bytes.
+	MaxRecvMsgSize int `json:"max-recv-msg-size" toml:"max-recv-msg-size"`
+	MaxSendMsgSize int `json:"max-send-msg-size" toml:"max-send-msg-size"`
+	// Compression is the compressor of the requests, either "gzip" or "none".
+	Compression string `json:"compression" toml:"compression"`
+	// ServiceConfig is the default service config in JSON, e.g. to set the
+	// retry policy of some methods. See
+	// https://github.com/grpc/grpc/blob/master/doc/service_config.md.
+	// The retry policy only takes effect when the GRPC_GO_RETRY environment
+	// variable is "on" in this version of gRPC.
+	ServiceConfig string `json:"service-config" toml:"service-config"`
+	// DialTimeout is the timeout of establishing a connection, zero keeps the
+	// default of the component.
+	DialTimeout Duration `json:"dial-timeout" toml:"dial-timeout"`
+	// KeepaliveTime and KeepaliveTimeout are the interval of pinging the store
+	// and the time waiting for the ack before closing the connection, zero
+	// keeps the defaults of the component. The links across data centers may
+	// need longer ones.
+	KeepaliveTime    Duration `json:"keepalive-time" toml:"keepalive-time"`
+	KeepaliveTimeout Duration `json:"keepalive-timeout" toml:"keepalive-timeout"`
+	// MaxConcurrentStreams is the max streams open at the same time on a
+	// connection, zero means no limit other than the one of the store.
+	MaxConcurrentStreams int `json:"max-concurrent-streams" toml:"max-concurrent-streams"`
+}
+
+// Duration is a time.Duration written as a string like "10s" in the config
+// files.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return errors.Trace(err)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%s"`, d.Duration)), nil
+}
+
+// Validate checks whether the config is valid.
+func (cfg *Config) Validate() error {
+	if cfg.MaxRecvMsgSize < 0 || cfg.MaxSendMsgSize < 0 {
+		return errors.Annotate(berrors.ErrInvalidArgument, "the max gRPC message size must not be negative")
+	}
+	switch cfg.Compression {
+	case "", "none", gzip.Name:
+	default:
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"unsupported gRPC compression %q, it should be none or gzip", cfg.Compression)
+	}
+	if cfg.DialTimeout.Duration < 0 || cfg.KeepaliveTime.Duration < 0 || cfg.KeepaliveTimeout.Duration < 0 {
+		return errors.Annotate(berrors.ErrInvalidArgument, "the gRPC dial timeout and keepalive must not be negative")
+	}
+	if cfg.MaxConcurrentStreams < 0 {
+		return errors.Annotate(berrors.ErrInvalidArgument, "the max gRPC concurrent streams must not be negative")
+	}
+	if cfg.ServiceConfig != "" && !json.Valid([]byte(cfg.ServiceConfig)) {
+		return errors.Annotate(berrors.ErrInvalidArgument, "the gRPC service config must be JSON")
+	}
+	return nil
+}
+
+// DialTimeoutOr returns the dial timeout, or def if it isn't set.
+func (cfg *Config) DialTimeoutOr(def time.Duration) time.Duration {
+	if cfg.DialTimeout.Duration > 0 {
+		return cfg.DialTimeout.Duration
+	}
+	return def
+}
+
+// KeepaliveOr returns def with the keepalive time and timeout set in the
+// config replaced.
+func (cfg *Config) KeepaliveOr(def keepalive.ClientParameters) keepalive.ClientParameters {
+	if cfg.KeepaliveTime.Duration > 0 {
+		def.Time = cfg.KeepaliveTime.Duration
+	}
+	if cfg.KeepaliveTimeout.Duration > 0 {
+		def.Timeout = cfg.KeepaliveTimeout.Duration
+	}
+	return def
+}
+
+// DialOptions returns the dial options applying the config. The options should
+// be got for each connection dialed, since the streams are limited per
+// connection.
+func (cfg *Config) DialOptions() []grpc.DialOption {
+	var callOpts []grpc.CallOption
+	if cfg.MaxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if cfg.Compression == gzip.Name {
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	}
+	var opts []grpc.DialOption
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if cfg.ServiceConfig != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(cfg.ServiceConfig))
+	}
+	if cfg.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.WithChainStreamInterceptor(newStreamLimiter(cfg.MaxConcurrentStreams).intercept))
+	}
+	return opts
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package grpcutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	. "github.com/pingcap/check"
+	"google.golang.org/grpc/keepalive"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type testGRPCSuite struct{}
+
+var _ = Suite(&testGRPCSuite{})
+
+func (s *testGRPCSuite) TestConfig(c *C) {
+	cfg := &Config{}
+	c.Assert(cfg.Validate(), IsNil)
+	c.Assert(cfg.DialOptions(), HasLen, 0)
+
+	cfg = &Config{
+		MaxRecvMsgSize: 128 << 20,
+		MaxSendMsgSize: 128 << 20,
+		Compression:    "gzip",
+		ServiceConfig:  `{"methodConfig": [{"name": [{"service": "import_sstpb.ImportSST"}]}]}`,
+	}
+	c.Assert(cfg.Validate(), IsNil)
+	// the call options are merged into one dial option.
+	c.Assert(cfg.DialOptions(), HasLen, 2)
+
+	cfg.Compression = "snappy"
+	c.Assert(cfg.Validate(), ErrorMatches, "unsupported gRPC compression \"snappy\".*")
+	cfg.Compression = "none"
+	cfg.ServiceConfig = "{"
+	c.Assert(cfg.Validate(), ErrorMatches, "the gRPC service config must be JSON.*")
+	cfg.ServiceConfig = ""
+	cfg.MaxRecvMsgSize = -1
+	c.Assert(cfg.Validate(), ErrorMatches, "the max gRPC message size must not be negative.*")
+	cfg.MaxRecvMsgSize = 0
+	cfg.KeepaliveTime = Duration{-time.Second}
+	c.Assert(cfg.Validate(), ErrorMatches, "the gRPC dial timeout and keepalive must not be negative.*")
+	cfg.KeepaliveTime = Duration{}
+	cfg.MaxConcurrentStreams = -1
+	c.Assert(cfg.Validate(), ErrorMatches, "the max gRPC concurrent streams must not be negative.*")
+}
+
+func (s *testGRPCSuite) TestConnConfig(c *C) {
+	cfg := &Config{}
+	_, err := toml.Decode(`
+dial-timeout = "1m"
+keepalive-time = "30s"
+max-concurrent-streams = 16
+`, cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Validate(), IsNil)
+	c.Assert(cfg.DialTimeoutOr(5*time.Second), Equals, time.Minute)
+	c.Assert(cfg.KeepaliveOr(keepalive.ClientParameters{
+		Time:                10 * time.Second,
+		Timeout:             3 * time.Second,
+		PermitWithoutStream: true,
+	}), DeepEquals, keepalive.ClientParameters{
+		Time:                30 * time.Second,
+		Timeout:             3 * time.Second,
+		PermitWithoutStream: true,
+	})
+	// the streams are limited by an interceptor.
+	c.Assert(cfg.DialOptions(), HasLen, 1)
+
+	cfg = &Config{}
+	c.Assert(cfg.DialTimeoutOr(5*time.Second), Equals, 5*time.Second)
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package grpcutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pingcap/br/pkg/redact"
+)
+
+const (
+	unaryRetryTimes      = 3
+	unaryWaitInterval    = 100 * time.Millisecond
+	unaryMaxWaitInterval = time.Second
+
+	// maxLoggedRequestLen is the max length of a request logged, since the
+	// write requests carry the data.
+	maxLoggedRequestLen = 256
+)
+
+// idempotentMethods are the unary methods which are safe to send again, since
+// they only read or set the state to the same value.
+var idempotentMethods = map[string]struct{}{
+	"/import_sstpb.ImportSST/SwitchMode":            {},
+	"/import_sstpb.ImportSST/SetDownloadSpeedLimit": {},
+	"/tikvpb.Tikv/KvGet":                            {},
+	"/tikvpb.Tikv/KvBatchGet":                       {},
+	"/tikvpb.Tikv/KvScan":                           {},
+	"/debugpb.Debug/GetMetrics":                     {},
+}
+
+var grpcRequestHistogram = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "br",
+		Subsystem: "grpc",
+		Name:      "request_seconds",
+		Help:      "The latency of the gRPC requests and of establishing the streams.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 20),
+	}, []string{"method", "store", "result"})
+
+func init() { // nolint:gochecknoinits
+	prometheus.MustRegister(grpcRequestHistogram)
+}
+
+// idempotentCallOption marks a call as idempotent, see Idempotent.
+type idempotentCallOption struct {
+	grpc.EmptyCallOption
+}
+
+// Idempotent returns a call option marking the call as safe to send again, so
+// the interceptor retries it when the store is unavailable, like the methods
+// known to be idempotent.
+func Idempotent() grpc.CallOption {
+	return idempotentCallOption{}
+}
+
+// InterceptorDialOptions returns the dial options installing the interceptors
+// shared by the connections to the stores.
+func InterceptorDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor),
+	}
+}
+
+// UnaryClientInterceptor logs the unary requests with the keys redacted if
+// asked, records their latencies by the method and the store, and retries the
+// idempotent ones when the store is unavailable.
+func UnaryClientInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	store := targetOf(cc)
+	retryable := isIdempotent(method, opts)
+	delay := unaryWaitInterval
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observe(method, store, start, err)
+		logRequest(method, store, req, attempt, start, err)
+		if err == nil || !retryable || attempt >= unaryRetryTimes || status.Code(err) != codes.Unavailable {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > unaryMaxWaitInterval {
+			delay = unaryMaxWaitInterval
+		}
+	}
+}
+
+// StreamClientInterceptor logs the streams and records the latencies of
+// establishing them. The streams are never retried, since the messages sent
+// can't be replayed.
+func StreamClientInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	store := targetOf(cc)
+	start := time.Now()
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	observe(method, store, start, err)
+	logRequest(method, store, nil, 1, start, err)
+	return stream, err
+}
+
+func isIdempotent(method string, opts []grpc.CallOption) bool {
+	if _, ok := idempotentMethods[method]; ok {
+		return true
+	}
+	for _, opt := range opts {
+		if _, ok := opt.(idempotentCallOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func targetOf(cc *grpc.ClientConn) string {
+	if cc == nil {
+		return ""
+	}
+	return cc.Target()
+}
+
+func observe(method, store string, start time.Time, err error) {
+	grpcRequestHistogram.WithLabelValues(method, store, status.Code(err).String()).
+		Observe(time.Since(start).Seconds())
+}
+
+func logRequest(method, store string, req interface{}, attempt int, start time.Time, err error) {
+	if !log.L().Core().Enabled(zapcore.DebugLevel) {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("store", store),
+		zap.Int("attempt", attempt),
+		zap.Duration("take", time.Since(start)),
+	}
+	if req != nil {
+		fields = append(fields, zap.String("request", redactRequest(req)))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	log.Debug("gRPC request", fields...)
+}
+
+// redactRequest returns the request to log, the keys and the values in it are
+// hidden if the log is redacted.
+func redactRequest(req interface{}) string {
+	text := redact.String(fmt.Sprint(req))
+	if len(text) > maxLoggedRequestLen {
+		text = text[:maxLoggedRequestLen] + "..."
+	}
+	return text
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package grpcutil
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pingcap/br/pkg/redact"
+)
+
+type testInterceptorSuite struct{}
+
+var _ = Suite(&testInterceptorSuite{})
+
+// failingInvoker fails the first failures calls with the code.
+func failingInvoker(failures int, code codes.Code, calls *int) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		opts ...grpc.CallOption) error {
+		*calls++
+		if *calls <= failures {
+			return status.Error(code, "injected")
+		}
+		return nil
+	}
+}
+
+func (s *testInterceptorSuite) TestUnaryRetry(c *C) {
+	ctx := context.Background()
+
+	// the idempotent methods are retried when the store is unavailable.
+	calls := 0
+	err := UnaryClientInterceptor(ctx, "/import_sstpb.ImportSST/SwitchMode", nil, nil, nil,
+		failingInvoker(2, codes.Unavailable, &calls))
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 3)
+
+	calls = 0
+	err = UnaryClientInterceptor(ctx, "/import_sstpb.ImportSST/SwitchMode", nil, nil, nil,
+		failingInvoker(unaryRetryTimes, codes.Unavailable, &calls))
+	c.Assert(status.Code(err), Equals, codes.Unavailable)
+	c.Assert(calls, Equals, unaryRetryTimes)
+
+	// the other errors aren't retried.
+	calls = 0
+	err = UnaryClientInterceptor(ctx, "/import_sstpb.ImportSST/SwitchMode", nil, nil, nil,
+		failingInvoker(1, codes.InvalidArgument, &calls))
+	c.Assert(status.Code(err), Equals, codes.InvalidArgument)
+	c.Assert(calls, Equals, 1)
+
+	// nor the methods not known to be idempotent.
+	calls = 0
+	err = UnaryClientInterceptor(ctx, "/import_sstpb.ImportSST/Ingest", nil, nil, nil,
+		failingInvoker(1, codes.Unavailable, &calls))
+	c.Assert(status.Code(err), Equals, codes.Unavailable)
+	c.Assert(calls, Equals, 1)
+
+	// unless the call is marked idempotent.
+	calls = 0
+	err = UnaryClientInterceptor(ctx, "/import_sstpb.ImportSST/MultiIngest", nil, nil, nil,
+		failingInvoker(1, codes.Unavailable, &calls), Idempotent())
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 2)
+}
+
+func (s *testInterceptorSuite) TestRedactRequest(c *C) {
+	defer redact.InitRedact(false)
+
+	c.Assert(redactRequest("key:\"abc\""), Equals, "key:\"abc\"")
+	long := redactRequest(strings.Repeat("a", 2*maxLoggedRequestLen))
+	c.Assert(long, HasLen, maxLoggedRequestLen+3)
+
+	redact.InitRedact(true)
+	c.Assert(redactRequest("key:\"abc\""), Equals, "?")
+}
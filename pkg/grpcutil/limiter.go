@@ -0,0 +1,83 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package grpcutil
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// streamLimiter limits the streams open at the same time on a connection.
+type streamLimiter struct {
+	sem chan struct{}
+}
+
+func newStreamLimiter(limit int) *streamLimiter {
+	return &streamLimiter{sem: make(chan struct{}, limit)}
+}
+
+// intercept is a grpc.StreamClientInterceptor waiting for a free slot before
+// opening the stream. The slot is freed once the stream ends, i.e. a message
+// received returns an error, the response of a client streaming call is
+// received, or the context is done. Like gRPC itself, a stream neither read to
+// the end nor canceled is never freed.
+func (l *streamLimiter) intercept(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case l.sem <- struct{}{}:
+	}
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	s := &limitedStream{
+		ClientStream:  stream,
+		serverStreams: desc.ServerStreams,
+		done:          make(chan struct{}),
+		release:       func() { <-l.sem },
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.finish()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// limitedStream frees the slot of the limiter once the stream ends.
+type limitedStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	done          chan struct{}
+	release       func()
+}
+
+// RecvMsg implements grpc.ClientStream.
+func (s *limitedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.finish()
+	}
+	return err
+}
+
+func (s *limitedStream) finish() {
+	s.once.Do(func() {
+		close(s.done)
+		s.release()
+	})
+}
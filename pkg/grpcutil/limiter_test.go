@@ -0,0 +1,73 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package grpcutil
+
+import (
+	"context"
+	"io"
+	"time"
+
+	. "github.com/pingcap/check"
+	"google.golang.org/grpc"
+)
+
+type testLimiterSuite struct{}
+
+var _ = Suite(&testLimiterSuite{})
+
+// fakeStream is a stream returning io.EOF once the messages are received.
+type fakeStream struct {
+	grpc.ClientStream
+	messages int
+}
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	if s.messages == 0 {
+		return io.EOF
+	}
+	s.messages--
+	return nil
+}
+
+func fakeStreamer(messages int) grpc.Streamer {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeStream{messages: messages}, nil
+	}
+}
+
+func (s *testLimiterSuite) TestStreamLimiter(c *C) {
+	ctx := context.Background()
+	limiter := newStreamLimiter(1)
+	serverStreams := &grpc.StreamDesc{ServerStreams: true}
+
+	stream, err := limiter.intercept(ctx, serverStreams, nil, "/tikvpb.Tikv/KvScan", fakeStreamer(1))
+	c.Assert(err, IsNil)
+	// the second stream waits for the first one.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = limiter.intercept(timeoutCtx, serverStreams, nil, "/tikvpb.Tikv/KvScan", fakeStreamer(1))
+	cancel()
+	c.Assert(err, Equals, context.DeadlineExceeded)
+
+	c.Assert(stream.RecvMsg(nil), IsNil)
+	c.Assert(stream.RecvMsg(nil), Equals, io.EOF)
+	// receiving after the end doesn't free the slot twice.
+	c.Assert(stream.RecvMsg(nil), Equals, io.EOF)
+	c.Assert(limiter.sem, HasLen, 0)
+
+	// the client streaming call ends once the response is received.
+	stream, err = limiter.intercept(ctx, &grpc.StreamDesc{ClientStreams: true}, nil,
+		"/import_sstpb.ImportSST/Write", fakeStreamer(1))
+	c.Assert(err, IsNil)
+	c.Assert(limiter.sem, HasLen, 1)
+	c.Assert(stream.RecvMsg(nil), IsNil)
+	c.Assert(limiter.sem, HasLen, 0)
+
+	// the slot is freed once the stream is canceled.
+	cancelCtx, cancel := context.WithCancel(ctx)
+	_, err = limiter.intercept(cancelCtx, serverStreams, nil, "/tikvpb.Tikv/KvScan", fakeStreamer(1))
+	c.Assert(err, IsNil)
+	cancel()
+	_, err = limiter.intercept(ctx, serverStreams, nil, "/tikvpb.Tikv/KvScan", fakeStreamer(0))
+	c.Assert(err, IsNil)
+}
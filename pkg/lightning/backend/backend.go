@@ -29,13 +29,16 @@ import (
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
 	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
 	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/lightning/membudget"
 	"github.com/pingcap/br/pkg/lightning/metric"
 	"github.com/pingcap/br/pkg/lightning/mydump"
 )
 
 const (
-	importMaxRetryTimes = 3 // tikv-importer has done retry internally. so we don't retry many times.
+	defaultImportMaxRetryTimes    = 3 // tikv-importer has done retry internally. so we don't retry many times.
+	defaultWriteRowsMaxRetryTimes = 3
 )
 
 /*
@@ -95,6 +98,22 @@ type EngineFileSize struct {
 	IsImporting bool
 }
 
+// EngineMetadata describes the KV pairs written into an engine.
+type EngineMetadata struct {
+	// UUID is the engine's UUID.
+	UUID uuid.UUID
+	// KVCount is the number of KV pairs flushed into the engine, which is the
+	// row count of a data engine.
+	KVCount int64
+	// Size is the total size of the KV pairs flushed into the engine.
+	Size int64
+	// MinKey and MaxKey are the smallest and the largest keys of the engine.
+	// They are nil if the engine is empty, or if the keys can't be read right
+	// now, e.g. while the engine is being imported.
+	MinKey []byte
+	MaxKey []byte
+}
+
 // LocalWriterConfig defines the configuration to open a LocalWriter
 type LocalWriterConfig struct {
 	// is the chunk KV written to this LocalWriter sent in order
@@ -119,6 +138,69 @@ type LocalEngineConfig struct {
 	CompactConcurrency int
 }
 
+// BackendConfig contains the timing knobs shared by all backends, and the
+// memory budget. It is consumed when the backend is constructed.
+type BackendConfig struct {
+	// RetryImportDelay is the duration to sleep before retrying a failed
+	// import. Zero means using the default delay of the backend.
+	RetryImportDelay time.Duration
+	// ImportMaxRetryTimes is the maximum attempts to import an engine.
+	ImportMaxRetryTimes int
+	// WriteRowsMaxRetryTimes is the maximum attempts to write a chunk of rows
+	// in the "tidb" and "importer" backends.
+	WriteRowsMaxRetryTimes int
+	// WriteTimeout is the timeout of writing a chunk of rows to the target.
+	// Zero means no timeout.
+	WriteTimeout time.Duration
+	// MemoryBudget is shared by the buffers and the caches of the "local"
+	// backend. Nil means unlimited.
+	MemoryBudget *membudget.Budget
+}
+
+// DefaultBackendConfig returns the BackendConfig used when nothing is
+// configured.
+func DefaultBackendConfig() BackendConfig {
+	return BackendConfig{
+		ImportMaxRetryTimes:    defaultImportMaxRetryTimes,
+		WriteRowsMaxRetryTimes: defaultWriteRowsMaxRetryTimes,
+	}
+}
+
+// Adjust replaces the retry times not set with the defaults, so that every
+// operation is attempted at least once.
+func (c *BackendConfig) Adjust() {
+	if c.ImportMaxRetryTimes <= 0 {
+		c.ImportMaxRetryTimes = defaultImportMaxRetryTimes
+	}
+	if c.WriteRowsMaxRetryTimes <= 0 {
+		c.WriteRowsMaxRetryTimes = defaultWriteRowsMaxRetryTimes
+	}
+}
+
+// NewBackendConfig creates the BackendConfig from the `[tikv-importer]`
+// section of the lightning config.
+func NewBackendConfig(cfg *config.Config) BackendConfig {
+	backendCfg := DefaultBackendConfig()
+	backendCfg.RetryImportDelay = cfg.TikvImporter.RetryImportDelay.Duration
+	backendCfg.WriteTimeout = cfg.TikvImporter.WriteTimeout.Duration
+	if cfg.TikvImporter.ImportMaxRetry > 0 {
+		backendCfg.ImportMaxRetryTimes = cfg.TikvImporter.ImportMaxRetry
+	}
+	if cfg.TikvImporter.WriteMaxRetry > 0 {
+		backendCfg.WriteRowsMaxRetryTimes = cfg.TikvImporter.WriteMaxRetry
+	}
+	return backendCfg
+}
+
+// WithWriteTimeout returns a context which is canceled after WriteTimeout,
+// or the context itself if WriteTimeout is not set.
+func (c BackendConfig) WithWriteTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.WriteTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.WriteTimeout)
+}
+
 // CheckCtx contains all parameters used in CheckRequirements
 type CheckCtx struct {
 	DBMetas []*mydump.MDDatabaseMeta
@@ -131,6 +213,12 @@ type AbstractBackend interface {
 	// Close the connection to the backend.
 	Close()
 
+	// CloseWithContext closes the connection to the backend like Close, but
+	// stops waiting for the pending operations, e.g. the engines being
+	// imported, once ctx is done. It returns the error of ctx then, and the
+	// rest of the close goes on in the background.
+	CloseWithContext(ctx context.Context) error
+
 	// MakeEmptyRows creates an empty collection of encoded rows.
 	MakeEmptyRows() kv.Rows
 
@@ -191,6 +279,11 @@ type AbstractBackend interface {
 	// It can return nil if the content are all stored remotely.
 	EngineFileSizes() []EngineFileSize
 
+	// EngineMetadata obtains the metadata of an engine managed by this
+	// backend. It returns nil if the engine is not found, or if the content
+	// are stored remotely.
+	EngineMetadata(ctx context.Context, engineUUID uuid.UUID) (*EngineMetadata, error)
+
 	// ResetEngine clears all written KV pairs in this opened engine.
 	ResetEngine(ctx context.Context, engineUUID uuid.UUID) error
 
@@ -209,12 +302,15 @@ type AbstractBackend interface {
 // Backend is the delivery target for Lightning
 type Backend struct {
 	abstract AbstractBackend
+	cfg      BackendConfig
 }
 
 type engine struct {
-	backend AbstractBackend
-	logger  log.Logger
-	uuid    uuid.UUID
+	backend             AbstractBackend
+	logger              log.Logger
+	uuid                uuid.UUID
+	label               string
+	importMaxRetryTimes int
 }
 
 // OpenedEngine is an opened engine, allowing data to be written via WriteRows.
@@ -241,16 +337,39 @@ type ClosedEngine struct {
 type LocalEngineWriter struct {
 	writer    EngineWriter
 	tableName string
+	label     string
+}
+
+// sizedRows is implemented by the rows reporting their total kv size.
+type sizedRows interface {
+	Size() uint64
+}
+
+func observeEngineOperation(label, op string, start time.Time) {
+	metric.EngineOperationSecondsHistogram.WithLabelValues(label, op).Observe(time.Since(start).Seconds())
 }
 
 func MakeBackend(ab AbstractBackend) Backend {
-	return Backend{abstract: ab}
+	return MakeBackendWithConfig(ab, DefaultBackendConfig())
+}
+
+// MakeBackendWithConfig wraps the AbstractBackend, applying the retry
+// settings of cfg on the engines it manages.
+func MakeBackendWithConfig(ab AbstractBackend, cfg BackendConfig) Backend {
+	cfg.Adjust()
+	return Backend{abstract: ab, cfg: cfg}
 }
 
 func (be Backend) Close() {
 	be.abstract.Close()
 }
 
+// CloseWithContext closes the backend, and stops waiting for it once ctx is
+// done.
+func (be Backend) CloseWithContext(ctx context.Context) error {
+	return be.abstract.CloseWithContext(ctx)
+}
+
 func (be Backend) MakeEmptyRows() kv.Rows {
 	return be.abstract.MakeEmptyRows()
 }
@@ -306,6 +425,37 @@ func (be Backend) CheckDiskQuota(quota int64) (
 	return
 }
 
+// EngineMetadata obtains the metadata of an engine, nil if it's unknown.
+func (be Backend) EngineMetadata(ctx context.Context, engineUUID uuid.UUID) (*EngineMetadata, error) {
+	return be.abstract.EngineMetadata(ctx, engineUUID)
+}
+
+// SortEnginesBySize sorts the engines by the sizes of the KV pairs written
+// into them from the largest, so that importing them in order frees the most
+// of the local disk first. The engines whose metadata are unknown are placed
+// last in their original order.
+func (be Backend) SortEnginesBySize(ctx context.Context, engines []uuid.UUID) {
+	sizes := make(map[uuid.UUID]int64, len(engines))
+	for _, engineUUID := range engines {
+		meta, err := be.abstract.EngineMetadata(ctx, engineUUID)
+		if err != nil {
+			makeLogger("<sort-by-size>", engineUUID).Warn("failed to get the engine metadata", log.ShortError(err))
+			continue
+		}
+		if meta != nil {
+			sizes[engineUUID] = meta.Size
+		}
+	}
+	sort.SliceStable(engines, func(i, j int) bool {
+		a, aOk := sizes[engines[i]]
+		b, bOk := sizes[engines[j]]
+		if aOk != bOk {
+			return aOk
+		}
+		return a > b
+	})
+}
+
 // UnsafeImportAndReset forces the backend to import the content of an engine
 // into the target and then reset the engine to empty. This method will not
 // close the engine. Make sure the engine is flushed manually before calling
@@ -315,9 +465,11 @@ func (be Backend) UnsafeImportAndReset(ctx context.Context, engineUUID uuid.UUID
 	// calling UnsafeImportAndReset().
 	closedEngine := ClosedEngine{
 		engine: engine{
-			backend: be.abstract,
-			logger:  makeLogger("<import-and-reset>", engineUUID),
-			uuid:    engineUUID,
+			backend:             be.abstract,
+			logger:              makeLogger("<import-and-reset>", engineUUID),
+			uuid:                engineUUID,
+			label:               metric.EngineLabelOther,
+			importMaxRetryTimes: be.cfg.ImportMaxRetryTimes,
 		},
 	}
 	if err := closedEngine.Import(ctx); err != nil {
@@ -330,10 +482,13 @@ func (be Backend) UnsafeImportAndReset(ctx context.Context, engineUUID uuid.UUID
 func (be Backend) OpenEngine(ctx context.Context, config *EngineConfig, tableName string, engineID int32) (*OpenedEngine, error) {
 	tag, engineUUID := MakeUUID(tableName, engineID)
 	logger := makeLogger(tag, engineUUID)
+	label := metric.EngineLabel(tag)
 
+	start := time.Now()
 	if err := be.abstract.OpenEngine(ctx, config, engineUUID); err != nil {
 		return nil, err
 	}
+	observeEngineOperation(label, metric.EngineOperationOpen, start)
 
 	openCounter := metric.ImporterEngineCounter.WithLabelValues("open")
 	openCounter.Inc()
@@ -351,9 +506,11 @@ func (be Backend) OpenEngine(ctx context.Context, config *EngineConfig, tableNam
 
 	return &OpenedEngine{
 		engine: engine{
-			backend: be.abstract,
-			logger:  logger,
-			uuid:    engineUUID,
+			backend:             be.abstract,
+			logger:              logger,
+			uuid:                engineUUID,
+			label:               label,
+			importMaxRetryTimes: be.cfg.ImportMaxRetryTimes,
 		},
 		tableName: tableName,
 	}, nil
@@ -378,7 +535,12 @@ func (engine *OpenedEngine) Close(ctx context.Context, cfg *EngineConfig) (*Clos
 
 // Flush current written data for local backend
 func (engine *OpenedEngine) Flush(ctx context.Context) error {
-	return engine.backend.FlushEngine(ctx, engine.uuid)
+	start := time.Now()
+	err := engine.backend.FlushEngine(ctx, engine.uuid)
+	if err == nil {
+		observeEngineOperation(engine.label, metric.EngineOperationFlush, start)
+	}
+	return err
 }
 
 func (engine *OpenedEngine) LocalWriter(ctx context.Context, cfg *LocalWriterConfig) (*LocalEngineWriter, error) {
@@ -386,16 +548,32 @@ func (engine *OpenedEngine) LocalWriter(ctx context.Context, cfg *LocalWriterCon
 	if err != nil {
 		return nil, err
 	}
-	return &LocalEngineWriter{writer: w, tableName: engine.tableName}, nil
+	return &LocalEngineWriter{writer: w, tableName: engine.tableName, label: engine.label}, nil
 }
 
-// WriteRows writes a collection of encoded rows into the engine.
+// WriteRows writes a collection of encoded rows into the engine. The time
+// spent includes the stall waiting for the backend to accept the rows.
 func (w *LocalEngineWriter) WriteRows(ctx context.Context, columnNames []string, rows kv.Rows) error {
-	return w.writer.AppendRows(ctx, w.tableName, columnNames, rows)
+	start := time.Now()
+	if err := w.writer.AppendRows(ctx, w.tableName, columnNames, rows); err != nil {
+		return err
+	}
+	observeEngineOperation(w.label, metric.EngineOperationWrite, start)
+	if sized, ok := rows.(sizedRows); ok {
+		metric.EngineWriteBytesHistogram.WithLabelValues(w.label).Observe(float64(sized.Size()))
+	}
+	return nil
 }
 
+// Close flushes the rows written by the writer, which is counted as a flush of
+// the engine.
 func (w *LocalEngineWriter) Close(ctx context.Context) (ChunkFlushStatus, error) {
-	return w.writer.Close(ctx)
+	start := time.Now()
+	status, err := w.writer.Close(ctx)
+	if err == nil {
+		observeEngineOperation(w.label, metric.EngineOperationFlush, start)
+	}
+	return status, err
 }
 
 func (w *LocalEngineWriter) IsSynced() bool {
@@ -419,19 +597,23 @@ func (be Backend) UnsafeCloseEngine(ctx context.Context, cfg *EngineConfig, tabl
 // resuming from a checkpoint.
 func (be Backend) UnsafeCloseEngineWithUUID(ctx context.Context, cfg *EngineConfig, tag string, engineUUID uuid.UUID) (*ClosedEngine, error) {
 	return engine{
-		backend: be.abstract,
-		logger:  makeLogger(tag, engineUUID),
-		uuid:    engineUUID,
+		backend:             be.abstract,
+		logger:              makeLogger(tag, engineUUID),
+		uuid:                engineUUID,
+		label:               metric.EngineLabel(tag),
+		importMaxRetryTimes: be.cfg.ImportMaxRetryTimes,
 	}.unsafeClose(ctx, cfg)
 }
 
 func (en engine) unsafeClose(ctx context.Context, cfg *EngineConfig) (*ClosedEngine, error) {
 	task := en.logger.Begin(zap.InfoLevel, "engine close")
+	start := time.Now()
 	err := en.backend.CloseEngine(ctx, cfg, en.uuid)
 	task.End(zap.ErrorLevel, err)
 	if err != nil {
 		return nil, err
 	}
+	observeEngineOperation(en.label, metric.EngineOperationClose, start)
 	return &ClosedEngine{engine: en}, nil
 }
 
@@ -439,18 +621,22 @@ func (en engine) unsafeClose(ctx context.Context, cfg *EngineConfig) (*ClosedEng
 func (engine *ClosedEngine) Import(ctx context.Context) error {
 	var err error
 
-	for i := 0; i < importMaxRetryTimes; i++ {
+	for i := 0; i < engine.importMaxRetryTimes; i++ {
 		task := engine.logger.With(zap.Int("retryCnt", i)).Begin(zap.InfoLevel, "import")
+		start := time.Now()
 		err = engine.backend.ImportEngine(ctx, engine.uuid)
 		if !common.IsRetryableError(err) {
 			task.End(zap.ErrorLevel, err)
+			if err == nil {
+				observeEngineOperation(engine.label, metric.EngineOperationImport, start)
+			}
 			return err
 		}
 		task.Warn("import spuriously failed, going to retry again", log.ShortError(err))
 		time.Sleep(engine.backend.RetryImportDelay())
 	}
 
-	return errors.Annotatef(err, "[%s] import reach max retry %d and still failed", engine.uuid, importMaxRetryTimes)
+	return errors.Annotatef(err, "[%s] import reach max retry %d and still failed", engine.uuid, engine.importMaxRetryTimes)
 }
 
 // Cleanup deletes the intermediate data from target.
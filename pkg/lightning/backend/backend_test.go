@@ -14,6 +14,7 @@ import (
 
 	"github.com/pingcap/br/pkg/lightning/backend"
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
+	"github.com/pingcap/br/pkg/lightning/config"
 	"github.com/pingcap/br/pkg/mock"
 )
 
@@ -281,6 +282,44 @@ func (s *backendSuite) TestImportFailedWithRetry(c *C) {
 	c.Assert(err, ErrorMatches, ".*fake recoverable import error")
 }
 
+func (s *backendSuite) TestImportMaxRetryTimesFromConfig(c *C) {
+	s.setUpTest(c)
+	defer s.tearDownTest()
+
+	ctx := context.Background()
+	backendCfg := backend.DefaultBackendConfig()
+	backendCfg.ImportMaxRetryTimes = 5
+	be := backend.MakeBackendWithConfig(s.mockBackend, backendCfg)
+
+	s.mockBackend.EXPECT().CloseEngine(ctx, nil, gomock.Any()).Return(nil)
+	s.mockBackend.EXPECT().
+		ImportEngine(ctx, gomock.Any()).
+		Return(errors.New("fake recoverable import error")).
+		Times(5)
+	s.mockBackend.EXPECT().RetryImportDelay().Return(time.Duration(0)).AnyTimes()
+
+	closedEngine, err := be.UnsafeCloseEngine(ctx, nil, "`db`.`table`", 1)
+	c.Assert(err, IsNil)
+	err = closedEngine.Import(ctx)
+	c.Assert(err, ErrorMatches, ".*import reach max retry 5 and still failed.*")
+}
+
+func (s *backendSuite) TestNewBackendConfig(c *C) {
+	cfg := config.NewConfig()
+	c.Assert(backend.NewBackendConfig(cfg), Equals, backend.DefaultBackendConfig())
+
+	cfg.TikvImporter.RetryImportDelay.Duration = time.Second
+	cfg.TikvImporter.ImportMaxRetry = 10
+	cfg.TikvImporter.WriteMaxRetry = 7
+	cfg.TikvImporter.WriteTimeout.Duration = time.Minute
+	c.Assert(backend.NewBackendConfig(cfg), Equals, backend.BackendConfig{
+		RetryImportDelay:       time.Second,
+		ImportMaxRetryTimes:    10,
+		WriteRowsMaxRetryTimes: 7,
+		WriteTimeout:           time.Minute,
+	})
+}
+
 func (s *backendSuite) TestImportFailedRecovered(c *C) {
 	s.setUpTest(c)
 	defer s.tearDownTest()
@@ -312,6 +351,38 @@ func (s *backendSuite) TestClose(c *C) {
 	s.backend.Close()
 }
 
+func (s *backendSuite) TestCloseWithContext(c *C) {
+	s.setUpTest(c)
+	defer s.tearDownTest()
+
+	ctx := context.Background()
+	s.mockBackend.EXPECT().CloseWithContext(ctx).Return(context.DeadlineExceeded)
+
+	err := s.backend.CloseWithContext(ctx)
+	c.Assert(err, Equals, context.DeadlineExceeded)
+}
+
+func (s *backendSuite) TestSortEnginesBySize(c *C) {
+	s.setUpTest(c)
+	defer s.tearDownTest()
+
+	uuid1 := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	uuid2 := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	uuid3 := uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	uuid4 := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	ctx := context.Background()
+	s.mockBackend.EXPECT().EngineMetadata(ctx, uuid1).Return(&backend.EngineMetadata{UUID: uuid1, KVCount: 10, Size: 1000}, nil)
+	s.mockBackend.EXPECT().EngineMetadata(ctx, uuid2).Return(nil, errors.New("fake metadata error"))
+	s.mockBackend.EXPECT().EngineMetadata(ctx, uuid3).Return(&backend.EngineMetadata{UUID: uuid3, KVCount: 30, Size: 3000}, nil)
+	s.mockBackend.EXPECT().EngineMetadata(ctx, uuid4).Return(nil, nil)
+
+	// the engines are sorted by the sizes of their KV pairs, and the engines of
+	// unknown sizes are placed last in their original order.
+	engines := []uuid.UUID{uuid4, uuid1, uuid2, uuid3}
+	s.backend.SortEnginesBySize(ctx, engines)
+	c.Assert(engines, DeepEquals, []uuid.UUID{uuid3, uuid1, uuid4, uuid2})
+}
+
 func (s *backendSuite) TestMakeEmptyRows(c *C) {
 	s.setUpTest(c)
 	defer s.tearDownTest()
@@ -40,7 +40,13 @@ import (
 
 const (
 	defaultRetryBackoffTime = time.Second * 3
-	writeRowsMaxRetryTimes  = 3
+
+	// the batches written to tikv-importer are sized between
+	// minWriteBatchSize and maxWriteBatchSize, adapting to take about
+	// targetWriteBatchDuration each.
+	minWriteBatchSize        = 31 << 10
+	maxWriteBatchSize        = 31 << 20
+	targetWriteBatchDuration = time.Second
 )
 
 var (
@@ -53,11 +59,23 @@ var (
 	requiredMaxTiKVVersion = version.NextMajorVersion()
 )
 
+// Client is the part of the tikv-importer API used by the importer backend.
+// The backend only depends on this interface instead of the whole
+// import_kvpb.ImportKVClient, so it is kept working while tikv-importer is
+// being deprecated in favor of the local backend.
+type Client interface {
+	OpenEngine(ctx context.Context, in *import_kvpb.OpenEngineRequest, opts ...grpc.CallOption) (*import_kvpb.OpenEngineResponse, error)
+	WriteEngine(ctx context.Context, opts ...grpc.CallOption) (import_kvpb.ImportKV_WriteEngineClient, error)
+	CloseEngine(ctx context.Context, in *import_kvpb.CloseEngineRequest, opts ...grpc.CallOption) (*import_kvpb.CloseEngineResponse, error)
+	ImportEngine(ctx context.Context, in *import_kvpb.ImportEngineRequest, opts ...grpc.CallOption) (*import_kvpb.ImportEngineResponse, error)
+	CleanupEngine(ctx context.Context, in *import_kvpb.CleanupEngineRequest, opts ...grpc.CallOption) (*import_kvpb.CleanupEngineResponse, error)
+}
+
 // importer represents a gRPC connection to tikv-importer. This type is
 // goroutine safe: you can share this instance and execute any method anywhere.
 type importer struct {
 	conn   *grpc.ClientConn
-	cli    import_kvpb.ImportKVClient
+	cli    Client
 	pdAddr string
 	tls    *common.TLS
 
@@ -66,14 +84,29 @@ type importer struct {
 	lock sync.Mutex
 
 	tsMap sync.Map // engineUUID -> commitTS
+	// batchSizer adapts the size of the batches written by WriteRows.
+	batchSizer writeBatchSizer
 	// For testing convenience.
 	getTSFunc func(ctx context.Context) (uint64, error)
+
+	cfg backend.BackendConfig
 }
 
 // NewImporter creates a new connection to tikv-importer. A single connection
-// per tidb-lightning instance is enough.
-func NewImporter(ctx context.Context, tls *common.TLS, importServerAddr string, pdAddr string) (backend.Backend, error) {
-	conn, err := grpc.DialContext(ctx, importServerAddr, tls.ToGRPCDialOption())
+// per tidb-lightning instance is enough. The dial options are applied to the
+// connection, e.g. to compress the written KV pairs.
+// tikv-importer is deprecated, the `tikv-importer.migrate-importer` config
+// migrates to the local backend instead.
+func NewImporter(
+	ctx context.Context,
+	tls *common.TLS,
+	importServerAddr string,
+	pdAddr string,
+	backendCfg backend.BackendConfig,
+	opts ...grpc.DialOption,
+) (backend.Backend, error) {
+	opts = append(opts, tls.ToGRPCDialOption())
+	conn, err := grpc.DialContext(ctx, importServerAddr, opts...)
 	if err != nil {
 		return backend.MakeBackend(nil), errors.Trace(err)
 	}
@@ -92,20 +125,25 @@ func NewImporter(ctx context.Context, tls *common.TLS, importServerAddr string,
 		return oracle.ComposeTS(physical, logical), nil
 	}
 
-	return backend.MakeBackend(&importer{
+	if backendCfg.RetryImportDelay == 0 {
+		backendCfg.RetryImportDelay = defaultRetryBackoffTime
+	}
+	backendCfg.Adjust()
+	return backend.MakeBackendWithConfig(&importer{
 		conn:         conn,
 		cli:          import_kvpb.NewImportKVClient(conn),
 		pdAddr:       pdAddr,
 		tls:          tls,
 		mutationPool: sync.Pool{New: func() interface{} { return &import_kvpb.Mutation{} }},
 		getTSFunc:    getTSFunc,
-	}), nil
+		cfg:          backendCfg,
+	}, backendCfg), nil
 }
 
 // NewMockImporter creates an *unconnected* importer based on a custom
-// ImportKVClient. This is provided for testing only. Do not use this function
+// Client. This is provided for testing only. Do not use this function
 // outside of tests.
-func NewMockImporter(cli import_kvpb.ImportKVClient, pdAddr string) backend.Backend {
+func NewMockImporter(cli Client, pdAddr string) backend.Backend {
 	return backend.MakeBackend(&importer{
 		conn:         nil,
 		cli:          cli,
@@ -114,25 +152,86 @@ func NewMockImporter(cli import_kvpb.ImportKVClient, pdAddr string) backend.Back
 		getTSFunc: func(ctx context.Context) (uint64, error) {
 			return uint64(time.Now().UnixNano()), nil
 		},
+		cfg: backend.DefaultBackendConfig(),
 	})
 }
 
 // Close the importer connection.
 func (importer *importer) Close() {
-	if importer.conn != nil {
-		if err := importer.conn.Close(); err != nil {
-			log.L().Warn("close importer gRPC connection failed", zap.Error(err))
-		}
+	if err := importer.CloseWithContext(context.Background()); err != nil {
+		log.L().Warn("close importer gRPC connection failed", zap.Error(err))
 	}
 }
 
-func (*importer) RetryImportDelay() time.Duration {
-	return defaultRetryBackoffTime
+// CloseWithContext closes the importer connection, which doesn't wait for
+// the pending requests.
+func (importer *importer) CloseWithContext(context.Context) error {
+	if importer.conn == nil {
+		return nil
+	}
+	return errors.Trace(importer.conn.Close())
+}
+
+func (importer *importer) RetryImportDelay() time.Duration {
+	return importer.cfg.RetryImportDelay
 }
 
+// MaxChunkSize returns the size of the batches written to tikv-importer
+// before adapting to the observed write duration.
 func (*importer) MaxChunkSize() int {
-	// 31 MB. hardcoded by importer, so do we
-	return 31 << 10
+	return minWriteBatchSize
+}
+
+// writeBatchSizer adapts the size of the batches written to tikv-importer. A
+// batch is written in its own stream, so over a high-latency link most of the
+// time of a small batch is spent on the round trips. The size is doubled while
+// the batches are written faster than targetWriteBatchDuration, and halved when
+// slower than twice of it or failing, such that the throughput is bound by the
+// bandwidth rather than the latency. The zero value is ready to use.
+type writeBatchSizer struct {
+	mu   sync.Mutex
+	size int
+}
+
+func (s *writeBatchSizer) current() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size == 0 {
+		s.size = minWriteBatchSize
+	}
+	return s.size
+}
+
+// observe adapts the size to a batch of the given size written successfully
+// in the given duration.
+func (s *writeBatchSizer) observe(size int, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case elapsed > 2*targetWriteBatchDuration:
+		s.size /= 2
+	// a batch much smaller than the size, e.g. the last one of the rows, tells
+	// nothing about whether a larger batch can be written in time.
+	case elapsed < targetWriteBatchDuration && size >= s.size/2:
+		s.size *= 2
+	}
+	s.clamp()
+}
+
+// shrink halves the size after a batch failed to be written.
+func (s *writeBatchSizer) shrink() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size /= 2
+	s.clamp()
+}
+
+func (s *writeBatchSizer) clamp() {
+	if s.size < minWriteBatchSize {
+		s.size = minWriteBatchSize
+	} else if s.size > maxWriteBatchSize {
+		s.size = maxWriteBatchSize
+	}
 }
 
 func (*importer) ShouldPostProcess() bool {
@@ -243,23 +342,38 @@ func (importer *importer) WriteRows(
 	var err error
 	ts := importer.getEngineTS(engineUUID)
 outside:
-	for _, r := range rows.SplitIntoChunks(importer.MaxChunkSize()) {
-		for i := 0; i < writeRowsMaxRetryTimes; i++ {
-			err = importer.WriteRowsToImporter(ctx, engineUUID, ts, r)
+	for _, r := range rows.SplitIntoChunks(importer.batchSizer.current()) {
+		for i := 0; i < importer.cfg.WriteRowsMaxRetryTimes; i++ {
+			writeCtx, cancel := importer.cfg.WithWriteTimeout(ctx)
+			start := time.Now()
+			err = importer.WriteRowsToImporter(writeCtx, engineUUID, ts, r)
+			// a write exceeding the write timeout is retried like other retryable errors.
+			timedOut := ctx.Err() == nil && writeCtx.Err() == context.DeadlineExceeded
+			cancel()
 			switch {
 			case err == nil:
+				importer.batchSizer.observe(rowsSize(r), time.Since(start))
 				continue outside
-			case common.IsRetryableError(err):
+			case timedOut, common.IsRetryableError(err):
 				// retry next loop
+				importer.batchSizer.shrink()
 			default:
 				return err
 			}
 		}
-		return errors.Annotatef(err, "[%s] write rows reach max retry %d and still failed", tableName, writeRowsMaxRetryTimes)
+		return errors.Annotatef(err, "[%s] write rows reach max retry %d and still failed", tableName, importer.cfg.WriteRowsMaxRetryTimes)
 	}
 	return nil
 }
 
+func rowsSize(rows kv.Rows) int {
+	size := 0
+	for _, pair := range kv.KvPairsFromRows(rows) {
+		size += len(pair.Key) + len(pair.Val)
+	}
+	return size
+}
+
 func (importer *importer) WriteRowsToImporter(
 	ctx context.Context,
 	//nolint:interfacer // false positive
@@ -374,6 +488,11 @@ func (importer *importer) EngineFileSizes() []backend.EngineFileSize {
 	return nil
 }
 
+// EngineMetadata returns nil since the engines are kept by tikv-importer.
+func (importer *importer) EngineMetadata(context.Context, uuid.UUID) (*backend.EngineMetadata, error) {
+	return nil, nil
+}
+
 func (importer *importer) FlushEngine(context.Context, uuid.UUID) error {
 	return nil
 }
@@ -20,6 +20,7 @@ import (
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
@@ -291,3 +292,33 @@ func (s *importerSuite) TestCheckTiDBVersion(c *C) {
 	version = "5.7.25-TiDB-v1.0.0"
 	c.Assert(checkTiDBVersionByTLS(ctx, tls, requiredMinTiDBVersion, requiredMaxTiDBVersion), ErrorMatches, "TiDB version too old.*")
 }
+
+func (s *importerSuite) TestWriteBatchSizer(c *C) {
+	var sizer writeBatchSizer
+	c.Assert(sizer.current(), Equals, minWriteBatchSize)
+
+	// fast writes of full batches double the size up to the max.
+	sizer.observe(minWriteBatchSize, 10*time.Millisecond)
+	c.Assert(sizer.current(), Equals, 2*minWriteBatchSize)
+	for i := 0; i < 20; i++ {
+		sizer.observe(sizer.current(), 10*time.Millisecond)
+	}
+	c.Assert(sizer.current(), Equals, maxWriteBatchSize)
+
+	// a small batch doesn't grow the size.
+	sizer.shrink()
+	sizer.observe(1, 10*time.Millisecond)
+	c.Assert(sizer.current(), Equals, maxWriteBatchSize/2)
+
+	// a batch near the target duration keeps the size.
+	sizer.observe(maxWriteBatchSize/2, targetWriteBatchDuration+time.Millisecond)
+	c.Assert(sizer.current(), Equals, maxWriteBatchSize/2)
+
+	// slow writes and failures halve the size down to the min.
+	sizer.observe(maxWriteBatchSize/2, 3*targetWriteBatchDuration)
+	c.Assert(sizer.current(), Equals, maxWriteBatchSize/4)
+	for i := 0; i < 20; i++ {
+		sizer.shrink()
+	}
+	c.Assert(sizer.current(), Equals, minWriteBatchSize)
+}
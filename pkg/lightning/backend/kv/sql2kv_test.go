@@ -353,6 +353,29 @@ func mockTableInfo(c *C, createSQL string) *model.TableInfo {
 	return info
 }
 
+func (s *kvSuite) TestEncodeGeneratedAndMissingColumns(c *C) {
+	tblInfo := mockTableInfo(c, "create table t (c0 int, c1 int default 5, c2 int as (c0 + 1) stored)")
+	tbl, err := tables.TableFromMeta(NewPanickingAllocators(0), tblInfo)
+	c.Assert(err, IsNil)
+	encoder, err := NewTableKVEncoder(tbl, &SessionOptions{SQLMode: mysql.ModeStrictAllTables})
+	c.Assert(err, IsNil)
+	logger := log.Logger{Logger: zap.NewNop()}
+
+	// the fields are (c0, c2, c1), the value of the generated column c2 is
+	// computed rather than taken from the field, and c1 is missing.
+	pairs, err := encoder.Encode(logger, []types.Datum{
+		types.NewIntDatum(1),
+		types.NewIntDatum(100),
+	}, 1, []int{0, 2, 1, -1}, 1234)
+	c.Assert(err, IsNil)
+	expected, err := encoder.Encode(logger, []types.Datum{
+		types.NewIntDatum(1),
+		types.NewIntDatum(5),
+	}, 1, []int{0, 1, -1, -1}, 1234)
+	c.Assert(err, IsNil)
+	c.Assert(pairs.(*KvPairs).pairs, DeepEquals, expected.(*KvPairs).pairs)
+}
+
 func (s *kvSuite) TestDefaultAutoRandoms(c *C) {
 	tblInfo := mockTableInfo(c, "create table t (id bigint unsigned NOT NULL auto_random primary key clustered, a varchar(100));")
 	// seems parser can't parse auto_random properly.
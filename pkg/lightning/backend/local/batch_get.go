@@ -0,0 +1,208 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/util/codec"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// regionBatchGetter gets the values of the keys from TiKV by KvBatchGet. The
+// keys are grouped by the regions containing them and sent to the leaders in
+// batches. The keys failed by the region errors are retried with the regions
+// scanned again, and a NotLeader error is retried on the new leader at once.
+type regionBatchGetter struct {
+	splitCli  restore.SplitClient
+	getClient func(ctx context.Context, peer *metapb.Peer) (tikvpb.TikvClient, error)
+	ts        uint64
+	batchSize int
+	backoff   time.Duration
+}
+
+func newRegionBatchGetter(
+	splitCli restore.SplitClient,
+	getClient func(ctx context.Context, peer *metapb.Peer) (tikvpb.TikvClient, error),
+	ts uint64,
+) *regionBatchGetter {
+	return &regionBatchGetter{
+		splitCli:  splitCli,
+		getClient: getClient,
+		ts:        ts,
+		batchSize: maxGetRequestKeyCount,
+		backoff:   defaultRetryBackoffTime,
+	}
+}
+
+// regionKeys are the keys in a region.
+type regionKeys struct {
+	region *restore.RegionInfo
+	keys   [][]byte
+}
+
+// BatchGet gets the values of the keys, and calls fn with the pairs found in
+// each batch. The keys not existing are skipped. It fails if some keys are
+// still not got after maxRetryTimes retries.
+func (g *regionBatchGetter) BatchGet(ctx context.Context, keys [][]byte, fn func([]*kvrpcpb.KvPair) error) error {
+	pending := make([][]byte, len(keys))
+	copy(pending, keys)
+	for retry := 0; len(pending) > 0; retry++ {
+		if retry > maxRetryTimes {
+			return errors.Errorf("failed to get %d keys from TiKV after %d retries", len(pending), maxRetryTimes)
+		}
+		if retry > 0 {
+			if err := utils.SleepWithContext(ctx, g.backoff); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		sort.Slice(pending, func(i, j int) bool {
+			return bytes.Compare(pending[i], pending[j]) < 0
+		})
+		startKey := codec.EncodeBytes([]byte{}, pending[0])
+		endKey := codec.EncodeBytes([]byte{}, tidbkv.Key(pending[len(pending)-1]).Next())
+		regions, err := paginateScanRegion(ctx, g.splitCli, startKey, endKey, scanRegionLimit)
+		if err != nil {
+			log.L().Warn("scan regions failed when getting keys, retry again", zap.Error(err))
+			continue
+		}
+		groups, unfinished := groupKeysByRegion(regions, pending)
+		for _, group := range groups {
+			for i := 0; i < len(group.keys); i += g.batchSize {
+				end := i + g.batchSize
+				if end > len(group.keys) {
+					end = len(group.keys)
+				}
+				failed, err := g.getFromRegion(ctx, group.region, group.keys[i:end], fn)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				unfinished = append(unfinished, failed...)
+			}
+		}
+		pending = unfinished
+	}
+	return nil
+}
+
+// groupKeysByRegion groups the sorted keys by the sorted regions, the keys not
+// in any region are returned as unfinished.
+func groupKeysByRegion(regions []*restore.RegionInfo, keys [][]byte) (groups []regionKeys, unfinished [][]byte) {
+	idx := 0
+	for _, key := range keys {
+		encoded := codec.EncodeBytes([]byte{}, key)
+		for idx < len(regions) && len(regions[idx].Region.EndKey) > 0 &&
+			bytes.Compare(encoded, regions[idx].Region.EndKey) >= 0 {
+			idx++
+		}
+		if idx >= len(regions) || bytes.Compare(encoded, regions[idx].Region.StartKey) < 0 {
+			unfinished = append(unfinished, key)
+			continue
+		}
+		if len(groups) == 0 || groups[len(groups)-1].region != regions[idx] {
+			groups = append(groups, regionKeys{region: regions[idx]})
+		}
+		groups[len(groups)-1].keys = append(groups[len(groups)-1].keys, key)
+	}
+	return groups, unfinished
+}
+
+// getFromRegion gets the keys in a region by a single KvBatchGet, and returns
+// the keys to retry.
+func (g *regionBatchGetter) getFromRegion(
+	ctx context.Context,
+	region *restore.RegionInfo,
+	keys [][]byte,
+	fn func([]*kvrpcpb.KvPair) error,
+) ([][]byte, error) {
+	leader := region.Leader
+	if leader == nil {
+		leader = region.Region.GetPeers()[0]
+	}
+	for i := 0; ; i++ {
+		cli, err := g.getClient(ctx, leader)
+		if err != nil {
+			log.L().Warn("failed to connect to the leader when getting keys, retry again",
+				logutil.Region(region.Region), logutil.Leader(leader), zap.Error(err))
+			return keys, nil
+		}
+		req := &kvrpcpb.BatchGetRequest{
+			Context: &kvrpcpb.Context{
+				RegionId:    region.Region.GetId(),
+				RegionEpoch: region.Region.GetRegionEpoch(),
+				Peer:        leader,
+			},
+			Keys:    keys,
+			Version: g.ts,
+		}
+		resp, err := cli.KvBatchGet(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errors.Trace(ctx.Err())
+			}
+			log.L().Warn("failed to get keys from TiKV, retry again",
+				logutil.Region(region.Region), logutil.Leader(leader), zap.Error(err))
+			return keys, nil
+		}
+		if regionErr := resp.GetRegionError(); regionErr != nil {
+			// the new leader is tried at once, the other errors are retried
+			// with the regions scanned again.
+			if newLeader := regionErr.GetNotLeader().GetLeader(); newLeader != nil && i < maxRetryTimes {
+				log.L().Info("region leader changed when getting keys, retry on the new leader",
+					logutil.Region(region.Region), logutil.Leader(leader), zap.Uint64("newLeaderStore", newLeader.GetStoreId()))
+				leader = newLeader
+				continue
+			}
+			log.L().Warn("meet region error when getting keys, retry again",
+				logutil.Region(region.Region), logutil.Leader(leader),
+				zap.Stringer("RegionError", regionErr))
+			return keys, nil
+		}
+		if keyErr := resp.GetError(); keyErr != nil {
+			log.L().Warn("meet key error when getting keys, retry again",
+				logutil.Region(region.Region), logutil.Leader(leader),
+				zap.Stringer("KeyError", keyErr))
+			return keys, nil
+		}
+
+		var failed [][]byte
+		pairs := make([]*kvrpcpb.KvPair, 0, len(resp.Pairs))
+		for _, pair := range resp.Pairs {
+			// the locked keys are returned with the errors.
+			if pair.GetError() != nil {
+				failed = append(failed, pair.Key)
+				continue
+			}
+			pairs = append(pairs, pair)
+		}
+		if len(failed) > 0 {
+			log.L().Warn("meet key errors when getting keys, retry them again",
+				logutil.Region(region.Region), zap.Int("keys", len(failed)))
+		}
+		return failed, errors.Trace(fn(pairs))
+	}
+}
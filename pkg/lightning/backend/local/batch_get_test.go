@@ -0,0 +1,173 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/tikvpb"
+	"google.golang.org/grpc"
+)
+
+type batchGetSuite struct{}
+
+var _ = Suite(&batchGetSuite{})
+
+// mockBatchGetClient is a TikvClient serving KvBatchGet from the data, the
+// region error returned by onGet is responded instead if it isn't nil.
+type mockBatchGetClient struct {
+	tikvpb.TikvClient
+
+	mu       sync.Mutex
+	data     map[string]string
+	onGet    func(req *kvrpcpb.BatchGetRequest, storeID uint64) *errorpb.Error
+	storeID  uint64
+	requests []*kvrpcpb.BatchGetRequest
+}
+
+func (m *mockBatchGetClient) KvBatchGet(
+	ctx context.Context, req *kvrpcpb.BatchGetRequest, opts ...grpc.CallOption,
+) (*kvrpcpb.BatchGetResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+	if m.onGet != nil {
+		if regionErr := m.onGet(req, m.storeID); regionErr != nil {
+			return &kvrpcpb.BatchGetResponse{RegionError: regionErr}, nil
+		}
+	}
+	resp := &kvrpcpb.BatchGetResponse{}
+	for _, key := range req.Keys {
+		if value, ok := m.data[string(key)]; ok {
+			resp.Pairs = append(resp.Pairs, &kvrpcpb.KvPair{Key: key, Value: []byte(value)})
+		}
+	}
+	return resp, nil
+}
+
+func (s *batchGetSuite) newGetter(cli *mockBatchGetClient) *regionBatchGetter {
+	// regions: [, b), [b, d), [d, )
+	splitCli := initTestClient([][]byte{{}, []byte("b"), []byte("d"), {}}, nil)
+	getter := newRegionBatchGetter(splitCli, func(ctx context.Context, peer *metapb.Peer) (tikvpb.TikvClient, error) {
+		cli.mu.Lock()
+		cli.storeID = peer.GetStoreId()
+		cli.mu.Unlock()
+		return cli, nil
+	}, 42)
+	getter.batchSize = 2
+	getter.backoff = 0
+	return getter
+}
+
+func (s *batchGetSuite) batchGet(c *C, getter *regionBatchGetter, keys ...string) (map[string]string, error) {
+	got := make(map[string]string)
+	rawKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		rawKeys = append(rawKeys, []byte(key))
+	}
+	err := getter.BatchGet(context.Background(), rawKeys, func(pairs []*kvrpcpb.KvPair) error {
+		for _, pair := range pairs {
+			_, ok := got[string(pair.Key)]
+			c.Assert(ok, IsFalse)
+			got[string(pair.Key)] = string(pair.Value)
+		}
+		return nil
+	})
+	return got, err
+}
+
+func requestedKeys(reqs []*kvrpcpb.BatchGetRequest) []string {
+	keys := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		for _, key := range req.Keys {
+			keys = append(keys, string(key))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *batchGetSuite) TestBatchGet(c *C) {
+	cli := &mockBatchGetClient{data: map[string]string{"a": "1", "a2": "2", "c": "3", "e": "4", "e2": "5"}}
+	getter := s.newGetter(cli)
+	got, err := s.batchGet(c, getter, "e2", "x", "a", "c", "e", "a2")
+	c.Assert(err, IsNil)
+	c.Assert(got, DeepEquals, cli.data)
+
+	// the keys are grouped by the regions, and split into the batches.
+	c.Assert(cli.requests, HasLen, 4)
+	regionIDs := []uint64{1, 2, 3, 3}
+	keys := [][]string{{"a", "a2"}, {"c"}, {"e", "e2"}, {"x"}}
+	for i, req := range cli.requests {
+		c.Assert(req.Context.RegionId, Equals, regionIDs[i])
+		c.Assert(req.Version, Equals, uint64(42))
+		c.Assert(requestedKeys([]*kvrpcpb.BatchGetRequest{req}), DeepEquals, keys[i])
+	}
+}
+
+func (s *batchGetSuite) TestBatchGetNotLeader(c *C) {
+	newLeader := &metapb.Peer{Id: 2, StoreId: 2}
+	cli := &mockBatchGetClient{data: map[string]string{"a": "1", "c": "2"}}
+	cli.onGet = func(req *kvrpcpb.BatchGetRequest, storeID uint64) *errorpb.Error {
+		if req.Context.RegionId == 1 && storeID != newLeader.StoreId {
+			return &errorpb.Error{NotLeader: &errorpb.NotLeader{RegionId: 1, Leader: newLeader}}
+		}
+		return nil
+	}
+	got, err := s.batchGet(c, s.newGetter(cli), "a", "c")
+	c.Assert(err, IsNil)
+	c.Assert(got, DeepEquals, cli.data)
+
+	// the new leader is tried at once.
+	c.Assert(cli.requests, HasLen, 3)
+	c.Assert(cli.requests[0].Context.Peer.StoreId, Equals, uint64(1))
+	c.Assert(cli.requests[1].Context.Peer, DeepEquals, newLeader)
+	c.Assert(cli.requests[2].Context.RegionId, Equals, uint64(2))
+}
+
+func (s *batchGetSuite) TestBatchGetRegionError(c *C) {
+	cli := &mockBatchGetClient{data: map[string]string{"a": "1", "c": "2", "e": "3"}}
+	failed := false
+	cli.onGet = func(req *kvrpcpb.BatchGetRequest, storeID uint64) *errorpb.Error {
+		if req.Context.RegionId == 2 && !failed {
+			failed = true
+			return &errorpb.Error{EpochNotMatch: &errorpb.EpochNotMatch{}}
+		}
+		return nil
+	}
+	got, err := s.batchGet(c, s.newGetter(cli), "a", "c", "e")
+	c.Assert(err, IsNil)
+	c.Assert(got, DeepEquals, cli.data)
+	// only the keys of the failed region are retried.
+	c.Assert(cli.requests, HasLen, 4)
+	c.Assert(requestedKeys(cli.requests), DeepEquals, []string{"a", "c", "c", "e"})
+
+	// the keys are never got.
+	cli = &mockBatchGetClient{data: map[string]string{"a": "1", "c": "2"}}
+	cli.onGet = func(req *kvrpcpb.BatchGetRequest, storeID uint64) *errorpb.Error {
+		if req.Context.RegionId == 2 {
+			return &errorpb.Error{ServerIsBusy: &errorpb.ServerIsBusy{}}
+		}
+		return nil
+	}
+	_, err = s.batchGet(c, s.newGetter(cli), "a", "c")
+	c.Assert(err, ErrorMatches, "failed to get 1 keys from TiKV after 5 retries")
+	c.Assert(cli.requests, HasLen, 1+maxRetryTimes+1)
+}
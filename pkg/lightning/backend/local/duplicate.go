@@ -17,10 +17,7 @@ import (
 	"bytes"
 	"context"
 	"io"
-	"sort"
-	"time"
-
-	"golang.org/x/sync/errgroup"
+	"sync"
 
 	"github.com/cockroachdb/pebble"
 	"github.com/pingcap/errors"
@@ -36,16 +33,13 @@ import (
 	"github.com/pingcap/tidb/util/codec"
 	"github.com/pingcap/tidb/util/ranger"
 	"go.uber.org/zap"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/backoff"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/keepalive"
 
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/utils"
 )
 
 const (
@@ -58,6 +52,10 @@ type DuplicateRequest struct {
 	start     tidbkv.Key
 	end       tidbkv.Key
 	indexInfo *model.IndexInfo
+	// keyOnly detects the duplicated keys without their values, which is
+	// enough if the handles are encoded in the keys. The rows of the handles
+	// are got in another pass.
+	keyOnly bool
 }
 
 type DuplicateManager struct {
@@ -65,27 +63,37 @@ type DuplicateManager struct {
 	db                *pebble.DB
 	splitCli          restore.SplitClient
 	regionConcurrency int
-	connPool          common.GRPCConns
-	tls               *common.TLS
+	conns             *common.StoreConnManager
 	ts                uint64
 	keyAdapter        KeyAdapter
+	getter            *regionBatchGetter
+	// failFast cancels the other requests once a request fails.
+	failFast bool
 }
 
 func NewDuplicateManager(
 	db *pebble.DB,
 	splitCli restore.SplitClient,
 	ts uint64,
-	tls *common.TLS,
-	regionConcurrency int) (*DuplicateManager, error) {
-	return &DuplicateManager{
+	conns *common.StoreConnManager,
+	regionConcurrency int,
+	failFast bool) (*DuplicateManager, error) {
+	manager := &DuplicateManager{
 		db:                db,
-		tls:               tls,
+		conns:             conns,
 		regionConcurrency: regionConcurrency,
 		splitCli:          splitCli,
 		keyAdapter:        duplicateKeyAdapter{},
 		ts:                ts,
-		connPool:          common.NewGRPCConns(),
-	}, nil
+		failFast:          failFast,
+	}
+	manager.getter = newRegionBatchGetter(splitCli, manager.getKvClient, ts)
+	return manager, nil
+}
+
+// Close releases the store connections shared with the manager.
+func (manager *DuplicateManager) Close() {
+	manager.conns.Release()
 }
 
 func (manager *DuplicateManager) CollectDuplicateRowsFromTiKV(ctx context.Context, tbl table.Table) error {
@@ -101,20 +109,45 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromTiKV(ctx context.Contex
 	if err != nil {
 		return err
 	}
-	g, rpcctx := errgroup.WithContext(ctx)
+	err = manager.collectDuplicateRows(ctx, decoder, reqs)
+	log.L().Info("End collect duplicate data from remote TiKV", zap.Error(err))
+	return err
+}
+
+// collectDuplicateRows sends the requests concurrently and returns the first
+// error of them. With failFast the first error cancels the other requests,
+// otherwise they all run to the end to collect as many duplicated rows as
+// possible.
+func (manager *DuplicateManager) collectDuplicateRows(
+	ctx context.Context,
+	decoder *kv.TableKVDecoder,
+	reqs []*DuplicateRequest,
+) error {
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		firstErr common.OnceError
+	)
 	for _, r := range reqs {
 		req := r
-		g.Go(func() error {
-			err := manager.sendRequestToTiKV(rpcctx, decoder, req)
-			if err != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := manager.sendRequestToTiKV(reqCtx, decoder, req); err != nil {
 				log.L().Error("error occur when collect duplicate data from TiKV", zap.Error(err))
+				firstErr.Set(err)
+				if manager.failFast {
+					cancel()
+				}
 			}
-			return err
-		})
+		}()
 	}
-	err = g.Wait()
-	log.L().Info("End collect duplicate data from remote TiKV")
-	return err
+	wg.Wait()
+	if err := firstErr.Get(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ctx.Err())
 }
 
 func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
@@ -128,7 +161,6 @@ func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
 		return err
 	}
 	tryTimes := 0
-	indexHandles := make([][]byte, 0)
 	for {
 		if len(regions) == 0 {
 			break
@@ -154,7 +186,7 @@ func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
 				end = req.end
 			}
 
-			cli, err := manager.getDuplicateStream(ctx, region, start, end)
+			cli, err := manager.getDuplicateStream(ctx, region, start, end, req.keyOnly)
 			if err != nil {
 				r, err := manager.splitCli.GetRegionByID(ctx, region.Region.GetId())
 				if err != nil {
@@ -168,15 +200,6 @@ func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
 			}
 		}
 
-		if len(indexHandles) > 0 {
-			handles := manager.getValues(ctx, indexHandles)
-			if len(handles) > 0 {
-				indexHandles = handles
-			} else {
-				indexHandles = indexHandles[:0]
-			}
-		}
-
 		for idx, cli := range waitingClients {
 			region := watingRegions[idx]
 			for {
@@ -223,20 +246,18 @@ func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
 					break
 				}
 
-				handles, err := manager.storeDuplicateData(ctx, resp, decoder, req)
-				if err != nil {
+				if err := manager.storeDuplicateData(ctx, resp, decoder, req); err != nil {
 					return err
 				}
-				if handles != nil && len(handles) > 0 {
-					indexHandles = append(indexHandles, handles...)
-				}
 			}
 		}
 
 		// it means that all of region send to TiKV fail, so we must sleep some time to avoid retry too frequency
 		if len(unfinishedRegions) == len(regions) {
 			tryTimes += 1
-			time.Sleep(defaultRetryBackoffTime)
+			if err := utils.SleepWithContext(ctx, defaultRetryBackoffTime); err != nil {
+				return errors.Trace(err)
+			}
 		}
 		regions = unfinishedRegions
 	}
@@ -248,7 +269,7 @@ func (manager *DuplicateManager) storeDuplicateData(
 	resp *import_sstpb.DuplicateDetectResponse,
 	decoder *kv.TableKVDecoder,
 	req *DuplicateRequest,
-) ([][]byte, error) {
+) error {
 	opts := &pebble.WriteOptions{Sync: false}
 	var err error
 	maxKeyLen := 0
@@ -284,11 +305,11 @@ func (manager *DuplicateManager) storeDuplicateData(
 		}
 		b.Close()
 		if len(handles) == 0 {
-			return handles, nil
+			return nil
 		}
-		return manager.getValues(ctx, handles), nil
+		return manager.getValues(ctx, handles)
 	}
-	return nil, err
+	return err
 }
 
 func (manager *DuplicateManager) ReportDuplicateData() error {
@@ -313,7 +334,6 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromLocalIndex(
 		return err
 	}
 	handles := make([][]byte, 0)
-	allRanges := make([]tidbkv.KeyRange, 0)
 	for _, indexInfo := range tbl.Meta().Indices {
 		if indexInfo.State != model.StatePublic {
 			continue
@@ -323,7 +343,6 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromLocalIndex(
 		if err != nil {
 			return err
 		}
-		allRanges = append(allRanges, keysRanges...)
 		for _, r := range keysRanges {
 			startKey := codec.EncodeBytes([]byte{}, r.StartKey)
 			endKey := codec.EncodeBytes([]byte{}, r.EndKey)
@@ -356,113 +375,39 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromLocalIndex(
 				}
 				key := decoder.EncodeHandleKey(h)
 				handles = append(handles, key)
-				if len(handles) > maxGetRequestKeyCount {
-					handles = manager.getValues(ctx, handles)
+				if len(handles) >= maxGetRequestKeyCount {
+					if err := manager.getValues(ctx, handles); err != nil {
+						iter.Close()
+						return err
+					}
+					handles = handles[:0]
 				}
 			}
+			iter.Close()
 			if len(handles) > 0 {
-				handles = manager.getValues(ctx, handles)
+				if err := manager.getValues(ctx, handles); err != nil {
+					return err
+				}
+				handles = handles[:0]
 			}
-			if len(handles) == 0 {
-				db.DeleteRange(r.StartKey, r.EndKey, &pebble.WriteOptions{Sync: false})
+			// all the rows of the index are collected.
+			if err := db.DeleteRange(startKey, endKey, &pebble.WriteOptions{Sync: false}); err != nil {
+				return errors.Trace(err)
 			}
-			iter.Close()
 		}
 	}
-	if len(handles) == 0 {
-		return nil
-	}
-
-	for i := 0; i < maxRetryTimes; i++ {
-		handles = manager.getValues(ctx, handles)
-		if len(handles) == 0 {
-			for _, r := range allRanges {
-				db.DeleteRange(r.StartKey, r.EndKey, &pebble.WriteOptions{Sync: false})
-			}
-		}
-	}
-	return errors.Errorf("retry getValues time exceed limit")
+	return nil
 }
 
-func (manager *DuplicateManager) getValues(
-	ctx context.Context,
-	handles [][]byte,
-) [][]byte {
-	retryHandles := make([][]byte, 0)
-	sort.Slice(handles, func(i, j int) bool {
-		return bytes.Compare(handles[i], handles[j]) < 0
-	})
-	l := len(handles)
-	startKey := codec.EncodeBytes([]byte{}, handles[0])
-	endKey := codec.EncodeBytes([]byte{}, nextKey(handles[l-1]))
-	regions, err := paginateScanRegion(ctx, manager.splitCli, startKey, endKey, scanRegionLimit)
-	if err != nil {
-		log.L().Error("scan regions errors", zap.Error(err))
-		return handles
-	}
-	startIdx := 0
-	endIdx := 0
-	batch := make([][]byte, 0)
-	for _, region := range regions {
-		if startIdx >= l {
-			break
-		}
-		handleKey := codec.EncodeBytes([]byte{}, handles[startIdx])
-		if bytes.Compare(handleKey, region.Region.EndKey) >= 0 {
-			continue
-		}
-		endIdx = startIdx
-		for endIdx < l {
-			handleKey := codec.EncodeBytes([]byte{}, handles[endIdx])
-			if bytes.Compare(handleKey, region.Region.EndKey) < 0 {
-				batch = append(batch, handles[endIdx])
-				endIdx++
-			} else {
-				break
-			}
-		}
-		if err := manager.getValuesFromRegion(ctx, region, batch); err != nil {
-			log.L().Error("failed to collect values from TiKV by handle, we will retry it again", zap.Error(err))
-			retryHandles = append(retryHandles, batch...)
-		}
-		startIdx = endIdx
-	}
-	return retryHandles
+// getValues gets the rows of the handles from TiKV and stores them.
+func (manager *DuplicateManager) getValues(ctx context.Context, handles [][]byte) error {
+	return manager.getter.BatchGet(ctx, handles, manager.storeValues)
 }
 
-func (manager *DuplicateManager) getValuesFromRegion(
-	ctx context.Context,
-	region *restore.RegionInfo,
-	handles [][]byte,
-) error {
-	kvclient, err := manager.getKvClient(ctx, region.Leader)
-	if err != nil {
-		return err
-	}
-	reqCtx := &kvrpcpb.Context{
-		RegionId:    region.Region.GetId(),
-		RegionEpoch: region.Region.GetRegionEpoch(),
-		Peer:        region.Leader,
-	}
-
-	req := &kvrpcpb.BatchGetRequest{
-		Context: reqCtx,
-		Keys:    handles,
-		Version: manager.ts,
-	}
-	resp, err := kvclient.KvBatchGet(ctx, req)
-	if err != nil {
-		return err
-	}
-	if resp.GetRegionError() != nil {
-		return errors.Errorf("region error because of %v", resp.GetRegionError().GetMessage())
-	}
-	if resp.Error != nil {
-		return errors.Errorf("key error")
-	}
-
+// storeValues stores the rows got from TiKV.
+func (manager *DuplicateManager) storeValues(pairs []*kvrpcpb.KvPair) error {
 	maxKeyLen := 0
-	for _, kv := range resp.Pairs {
+	for _, kv := range pairs {
 		l := manager.keyAdapter.EncodedLen(kv.Key)
 		if l > maxKeyLen {
 			maxKeyLen = l
@@ -470,35 +415,34 @@ func (manager *DuplicateManager) getValuesFromRegion(
 	}
 	buf := make([]byte, maxKeyLen)
 
-	log.L().Error("get keys", zap.Int("key size", len(resp.Pairs)))
+	var err error
 	for i := 0; i < maxRetryTimes; i++ {
 		b := manager.db.NewBatch()
 		opts := &pebble.WriteOptions{Sync: false}
-		for _, kv := range resp.Pairs {
+		for _, kv := range pairs {
 			encodedKey := manager.keyAdapter.Encode(buf, kv.Key, 0, 0)
 			b.Set(encodedKey, kv.Value, opts)
 			if b.Count() > maxWriteBatchCount {
-				err = b.Commit(opts)
-				if err != nil {
+				if err = b.Commit(opts); err != nil {
 					break
-				} else {
-					b.Reset()
 				}
+				b.Reset()
 			}
 		}
 		if err == nil {
 			err = b.Commit(opts)
 		}
+		b.Close()
 		if err == nil {
 			return nil
 		}
 	}
-	return err
+	return errors.Trace(err)
 }
 
 func (manager *DuplicateManager) getDuplicateStream(ctx context.Context,
 	region *restore.RegionInfo,
-	start []byte, end []byte) (import_sstpb.ImportSST_DuplicateDetectClient, error) {
+	start []byte, end []byte, keyOnly bool) (import_sstpb.ImportSST_DuplicateDetectClient, error) {
 	leader := region.Leader
 	if leader == nil {
 		leader = region.Region.GetPeers()[0]
@@ -518,16 +462,14 @@ func (manager *DuplicateManager) getDuplicateStream(ctx context.Context,
 		Context:  reqCtx,
 		StartKey: start,
 		EndKey:   end,
-		KeyOnly:  false,
+		KeyOnly:  keyOnly,
 	}
 	stream, err := cli.DuplicateDetect(ctx, req)
 	return stream, err
 }
 
 func (manager *DuplicateManager) getKvClient(ctx context.Context, peer *metapb.Peer) (tikvpb.TikvClient, error) {
-	conn, err := manager.connPool.GetGrpcConn(ctx, peer.GetStoreId(), 1, func(ctx context.Context) (*grpc.ClientConn, error) {
-		return manager.makeConn(ctx, peer.GetStoreId())
-	})
+	conn, err := manager.conns.GetConn(ctx, peer.GetStoreId())
 	if err != nil {
 		return nil, err
 	}
@@ -535,51 +477,13 @@ func (manager *DuplicateManager) getKvClient(ctx context.Context, peer *metapb.P
 }
 
 func (manager *DuplicateManager) getImportClient(ctx context.Context, peer *metapb.Peer) (import_sstpb.ImportSSTClient, error) {
-	conn, err := manager.connPool.GetGrpcConn(ctx, peer.GetStoreId(), 1, func(ctx context.Context) (*grpc.ClientConn, error) {
-		return manager.makeConn(ctx, peer.GetStoreId())
-	})
+	conn, err := manager.conns.GetConn(ctx, peer.GetStoreId())
 	if err != nil {
 		return nil, err
 	}
 	return import_sstpb.NewImportSSTClient(conn), nil
 }
 
-func (manager *DuplicateManager) makeConn(ctx context.Context, storeID uint64) (*grpc.ClientConn, error) {
-	store, err := manager.splitCli.GetStore(ctx, storeID)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	opt := grpc.WithInsecure()
-	if manager.tls.TLSConfig() != nil {
-		opt = grpc.WithTransportCredentials(credentials.NewTLS(manager.tls.TLSConfig()))
-	}
-	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
-
-	bfConf := backoff.DefaultConfig
-	bfConf.MaxDelay = gRPCBackOffMaxDelay
-	// we should use peer address for tiflash. for tikv, peer address is empty
-	addr := store.GetPeerAddress()
-	if addr == "" {
-		addr = store.GetAddress()
-	}
-	conn, err := grpc.DialContext(
-		ctx,
-		addr,
-		opt,
-		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bfConf}),
-		grpc.WithKeepaliveParams(keepalive.ClientParameters{
-			Time:                gRPCKeepAliveTime,
-			Timeout:             gRPCKeepAliveTimeout,
-			PermitWithoutStream: true,
-		}),
-	)
-	cancel()
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	return conn, nil
-}
-
 func buildDuplicateRequests(tableInfo *model.TableInfo) ([]*DuplicateRequest, error) {
 	reqs := make([]*DuplicateRequest, 0)
 	req := buildTableRequest(tableInfo.ID)
@@ -626,6 +530,10 @@ func buildIndexRequest(tableID int64, indexInfo *model.IndexInfo) ([]*DuplicateR
 			end:       r.EndKey,
 			tableID:   tableID,
 			indexInfo: indexInfo,
+			// the handles are in the keys of the non-unique indices, so only
+			// the keys are transferred, and the rows of the duplicated
+			// handles are got later.
+			keyOnly: !indexInfo.Unique,
 		}
 		reqs = append(reqs, r)
 	}
@@ -0,0 +1,104 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+	"go.uber.org/atomic"
+
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type duplicateSuite struct{}
+
+var _ = Suite(&duplicateSuite{})
+
+// failedScanHook fails every scan of the regions, and blocks the scans
+// starting at the blockKey until the context is canceled.
+type failedScanHook struct {
+	noopHook
+	blockKey []byte
+	scans    atomic.Int32
+}
+
+func (h *failedScanHook) BeforeScanRegions(ctx context.Context, key, endKey []byte, limit int) ([]byte, []byte, int) {
+	h.scans.Inc()
+	if h.blockKey != nil && bytes.Equal(key, h.blockKey) {
+		<-ctx.Done()
+	}
+	return key, endKey, limit
+}
+
+func (h *failedScanHook) AfterScanRegions(res []*restore.RegionInfo, err error) ([]*restore.RegionInfo, error) {
+	return nil, errors.New("scan regions failed")
+}
+
+func newTestDuplicateRequests(tableIDs ...int64) []*DuplicateRequest {
+	reqs := make([]*DuplicateRequest, 0, len(tableIDs))
+	for _, id := range tableIDs {
+		reqs = append(reqs, &DuplicateRequest{
+			tableID: id,
+			start:   tablecodec.EncodeTablePrefix(id),
+			end:     tablecodec.EncodeTablePrefix(id + 1),
+		})
+	}
+	return reqs
+}
+
+func (s *duplicateSuite) TestCollectDuplicateRowsError(c *C) {
+	hook := &failedScanHook{}
+	manager := &DuplicateManager{splitCli: initTestClient([][]byte{{}, {}}, hook)}
+	err := manager.collectDuplicateRows(context.Background(), nil, newTestDuplicateRequests(1, 2, 3))
+	c.Assert(err, ErrorMatches, ".*scan regions failed")
+	// all the requests are sent without fail-fast.
+	c.Assert(hook.scans.Load(), Equals, int32(3))
+}
+
+func (s *duplicateSuite) TestCollectDuplicateRowsFailFast(c *C) {
+	reqs := newTestDuplicateRequests(1, 2)
+	// the second request never finishes unless canceled.
+	hook := &failedScanHook{blockKey: codec.EncodeBytes([]byte{}, reqs[1].start)}
+	manager := &DuplicateManager{splitCli: initTestClient([][]byte{{}, {}}, hook), failFast: true}
+	err := manager.collectDuplicateRows(context.Background(), nil, reqs)
+	c.Assert(err, ErrorMatches, ".*scan regions failed")
+}
+
+func (s *duplicateSuite) TestBuildDuplicateRequests(c *C) {
+	tableInfo := &model.TableInfo{
+		ID: 1,
+		Indices: []*model.IndexInfo{
+			{ID: 1, Name: model.NewCIStr("uk"), Unique: true, State: model.StatePublic},
+			{ID: 2, Name: model.NewCIStr("idx"), State: model.StatePublic},
+			{ID: 3, Name: model.NewCIStr("adding"), State: model.StateWriteReorganization},
+		},
+	}
+	reqs, err := buildDuplicateRequests(tableInfo)
+	c.Assert(err, IsNil)
+	c.Assert(reqs, HasLen, 3)
+	// the rows and the unique index values are needed.
+	c.Assert(reqs[0].indexInfo, IsNil)
+	c.Assert(reqs[0].keyOnly, IsFalse)
+	c.Assert(reqs[1].indexInfo.Name.L, Equals, "uk")
+	c.Assert(reqs[1].keyOnly, IsFalse)
+	// the handles are in the keys of the non-unique index.
+	c.Assert(reqs[2].indexInfo.Name.L, Equals, "idx")
+	c.Assert(reqs[2].keyOnly, IsTrue)
+}
@@ -0,0 +1,208 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/backend"
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/log"
+)
+
+const engineMetaSuffix = ".meta"
+
+// indexEngineID is the ID of the index engine of every table.
+const indexEngineID = -1
+
+// engineCreateMeta is stored beside the engine directory, so that the
+// engine can be inspected without opening its pebble DB, which may be locked
+// by another process.
+type engineCreateMeta struct {
+	UUID      uuid.UUID `json:"uuid"`
+	CreatedAt time.Time `json:"created_at"`
+	// TableName is empty if the engine is not opened with table info.
+	TableName string `json:"table_name,omitempty"`
+}
+
+// EngineInfo describes an engine found in the sorted-kv-dir.
+type EngineInfo struct {
+	UUID      uuid.UUID
+	CreatedAt time.Time
+	TableName string
+}
+
+func engineMetaPath(storeDir string, engineUUID uuid.UUID) string {
+	return filepath.Join(storeDir, engineUUID.String()+engineMetaSuffix)
+}
+
+// saveEngineCreateMeta records the creation time of the engine, unless it is
+// already recorded by a previous run.
+func saveEngineCreateMeta(storeDir string, engineUUID uuid.UUID, tableInfo *checkpoints.TidbTableInfo) error {
+	path := engineMetaPath(storeDir, engineUUID)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	meta := engineCreateMeta{
+		UUID:      engineUUID,
+		CreatedAt: time.Now(),
+	}
+	if tableInfo != nil {
+		meta.TableName = common.UniqueTable(tableInfo.DB, tableInfo.Name)
+	}
+	data, err := json.Marshal(&meta)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(path, data, 0o644))
+}
+
+func loadEngineCreateMeta(storeDir string, engineUUID uuid.UUID) (*engineCreateMeta, error) {
+	data, err := ioutil.ReadFile(engineMetaPath(storeDir, engineUUID))
+	if err != nil {
+		return nil, err
+	}
+	meta := &engineCreateMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return meta, nil
+}
+
+// ListEngines lists all engines stored in the sorted-kv-dir of local backend.
+// The creation time of an engine without metadata (e.g. created by an older
+// version) is the modification time of its directory.
+func ListEngines(storeDir string) ([]EngineInfo, error) {
+	entries, err := ioutil.ReadDir(storeDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Trace(err)
+	}
+	engines := make([]EngineInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		// skip the sst dirs and the duplicate dbs.
+		engineUUID, err := uuid.Parse(entry.Name())
+		if err != nil {
+			continue
+		}
+		info := EngineInfo{UUID: engineUUID, CreatedAt: entry.ModTime()}
+		meta, err := loadEngineCreateMeta(storeDir, engineUUID)
+		switch {
+		case err == nil:
+			info.CreatedAt = meta.CreatedAt
+			info.TableName = meta.TableName
+		case os.IsNotExist(errors.Cause(err)):
+		default:
+			log.L().Warn("failed to load engine meta, use the modification time instead",
+				zap.Stringer("engine", engineUUID), log.ShortError(err))
+		}
+		engines = append(engines, info)
+	}
+	return engines, nil
+}
+
+// removeEngineFiles removes the db, sst dir and metadata of the engine.
+func removeEngineFiles(storeDir string, engineUUID uuid.UUID) error {
+	if err := os.RemoveAll(engineSSTDir(storeDir, engineUUID)); err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.RemoveAll(filepath.Join(storeDir, engineUUID.String())); err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.Remove(engineMetaPath(storeDir, engineUUID)); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// ReferencedEngines returns the UUIDs of the engines whose data is still
+// required to resume the tables from the checkpoints.
+//
+// GetLocalStoringTables only returns the engines into which some chunks have
+// been read. The index engine of a table is however written by every data
+// engine and is only imported after all of them, so it is referenced as long
+// as the table is not imported, however long ago it was created.
+func ReferencedEngines(ctx context.Context, cpdb checkpoints.DB, tableNames []string) (map[uuid.UUID]struct{}, error) {
+	tables, err := cpdb.GetLocalStoringTables(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	referenced := make(map[uuid.UUID]struct{})
+	for tableName, engineIDs := range tables {
+		for _, engineID := range engineIDs {
+			_, engineUUID := backend.MakeUUID(tableName, engineID)
+			referenced[engineUUID] = struct{}{}
+		}
+	}
+	for _, tableName := range tableNames {
+		cp, err := cpdb.Get(ctx, tableName)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Trace(err)
+		}
+		if cp.Status == checkpoints.CheckpointStatusMissing || cp.Status >= checkpoints.CheckpointStatusIndexImported {
+			continue
+		}
+		_, engineUUID := backend.MakeUUID(tableName, indexEngineID)
+		referenced[engineUUID] = struct{}{}
+	}
+	return referenced, nil
+}
+
+// CleanupOrphanEngines removes the engines in the sorted-kv-dir which are not
+// referenced and were created more than ttl ago. Engines are orphaned when
+// Lightning exits abnormally after opening them. It returns the removed
+// engines.
+func CleanupOrphanEngines(storeDir string, referenced map[uuid.UUID]struct{}, ttl time.Duration) ([]EngineInfo, error) {
+	engines, err := ListEngines(storeDir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	deadline := time.Now().Add(-ttl)
+	var removed []EngineInfo
+	for _, engine := range engines {
+		if _, ok := referenced[engine.UUID]; ok {
+			continue
+		}
+		if engine.CreatedAt.After(deadline) {
+			continue
+		}
+		log.L().Info("remove orphan engine",
+			zap.Stringer("engine", engine.UUID),
+			zap.String("table", engine.TableName),
+			zap.Time("createdAt", engine.CreatedAt))
+		if err := removeEngineFiles(storeDir, engine.UUID); err != nil {
+			return removed, errors.Annotatef(err, "failed to remove orphan engine %s", engine.UUID)
+		}
+		removed = append(removed, engine)
+	}
+	return removed, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/lightning/backend"
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/mydump"
+)
+
+type engineMetaSuite struct{}
+
+var _ = Suite(&engineMetaSuite{})
+
+func (s *engineMetaSuite) TestCleanupOrphanEngines(c *C) {
+	dir := c.MkDir()
+	c.Assert(os.Mkdir(filepath.Join(dir, duplicateDBName), 0o755), IsNil)
+
+	newEngine := func(withMeta bool, age time.Duration) uuid.UUID {
+		engineUUID := uuid.New()
+		c.Assert(os.Mkdir(filepath.Join(dir, engineUUID.String()), 0o755), IsNil)
+		c.Assert(os.Mkdir(engineSSTDir(dir, engineUUID), 0o755), IsNil)
+		createdAt := time.Now().Add(-age)
+		if withMeta {
+			c.Assert(saveEngineCreateMeta(dir, engineUUID, &checkpoints.TidbTableInfo{DB: "db", Name: "t"}), IsNil)
+			meta, err := loadEngineCreateMeta(dir, engineUUID)
+			c.Assert(err, IsNil)
+			c.Assert(meta.TableName, Equals, "`db`.`t`")
+			// saving again does not overwrite the creation time.
+			c.Assert(saveEngineCreateMeta(dir, engineUUID, nil), IsNil)
+			meta2, err := loadEngineCreateMeta(dir, engineUUID)
+			c.Assert(err, IsNil)
+			c.Assert(meta2.CreatedAt.Equal(meta.CreatedAt), IsTrue)
+		}
+		c.Assert(os.Chtimes(filepath.Join(dir, engineUUID.String()), createdAt, createdAt), IsNil)
+		return engineUUID
+	}
+
+	referencedOld := newEngine(false, 48*time.Hour)
+	orphanOld := newEngine(false, 48*time.Hour)
+	orphanNew := newEngine(true, 0)
+
+	engines, err := ListEngines(dir)
+	c.Assert(err, IsNil)
+	c.Assert(engines, HasLen, 3)
+
+	referenced := map[uuid.UUID]struct{}{referencedOld: {}}
+	removed, err := CleanupOrphanEngines(dir, referenced, 24*time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(removed, HasLen, 1)
+	c.Assert(removed[0].UUID, Equals, orphanOld)
+	_, err = os.Stat(filepath.Join(dir, orphanOld.String()))
+	c.Assert(os.IsNotExist(err), IsTrue)
+	_, err = os.Stat(engineSSTDir(dir, orphanOld))
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	// without TTL, all unreferenced engines are removed.
+	removed, err = CleanupOrphanEngines(dir, referenced, 0)
+	c.Assert(err, IsNil)
+	c.Assert(removed, HasLen, 1)
+	c.Assert(removed[0].UUID, Equals, orphanNew)
+	_, err = os.Stat(engineMetaPath(dir, orphanNew))
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	engines, err = ListEngines(dir)
+	c.Assert(err, IsNil)
+	c.Assert(engines, HasLen, 1)
+	c.Assert(engines[0].UUID, Equals, referencedOld)
+	_, err = os.Stat(filepath.Join(dir, duplicateDBName))
+	c.Assert(err, IsNil)
+}
+
+func (s *engineMetaSuite) TestReferencedIndexEngine(c *C) {
+	ctx := context.Background()
+	dir := c.MkDir()
+	cpdb := checkpoints.NewFileCheckpointsDB(filepath.Join(dir, "cp.pb"))
+	defer cpdb.Close()
+
+	cfg := config.NewConfig()
+	cfg.TikvImporter.Backend = config.BackendLocal
+	err := cpdb.Initialize(ctx, cfg, map[string]*checkpoints.TidbDBInfo{
+		"db": {
+			Name:   "db",
+			Tables: map[string]*checkpoints.TidbTableInfo{"t": {Name: "t"}},
+		},
+	})
+	c.Assert(err, IsNil)
+	// no chunk of the data engine has been read yet.
+	err = cpdb.InsertEngineCheckpoints(ctx, "`db`.`t`", map[int32]*checkpoints.EngineCheckpoint{
+		-1: {Status: checkpoints.CheckpointStatusLoaded},
+		0: {
+			Status: checkpoints.CheckpointStatusLoaded,
+			Chunks: []*checkpoints.ChunkCheckpoint{{
+				Key:   checkpoints.ChunkCheckpointKey{Path: "/data/db.t.sql"},
+				Chunk: mydump.Chunk{Offset: 0, EndOffset: 100},
+			}},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// the task resumes after the engine TTL.
+	createdAt := time.Now().Add(-48 * time.Hour)
+	_, indexEngine := backend.MakeUUID("`db`.`t`", -1)
+	c.Assert(os.Mkdir(filepath.Join(dir, indexEngine.String()), 0o755), IsNil)
+	c.Assert(os.Chtimes(filepath.Join(dir, indexEngine.String()), createdAt, createdAt), IsNil)
+
+	tableNames := []string{"`db`.`t`", "`db`.`not_initialized`"}
+	referenced, err := ReferencedEngines(ctx, cpdb, tableNames)
+	c.Assert(err, IsNil)
+	c.Assert(referenced, HasKey, indexEngine)
+	removed, err := CleanupOrphanEngines(dir, referenced, 24*time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(removed, HasLen, 0)
+
+	// once the table is imported, the index engine is no longer referenced.
+	diff := checkpoints.NewTableCheckpointDiff()
+	merger := &checkpoints.StatusCheckpointMerger{
+		EngineID: checkpoints.WholeTableEngineID,
+		Status:   checkpoints.CheckpointStatusIndexImported,
+	}
+	merger.MergeInto(diff)
+	cpdb.Update(map[string]*checkpoints.TableCheckpointDiff{"`db`.`t`": diff})
+	referenced, err = ReferencedEngines(ctx, cpdb, tableNames)
+	c.Assert(err, IsNil)
+	removed, err = CleanupOrphanEngines(dir, referenced, 24*time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(removed, HasLen, 1)
+	c.Assert(removed[0].UUID, Equals, indexEngine)
+}
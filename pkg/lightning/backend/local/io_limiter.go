@@ -0,0 +1,118 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// TableIOLimiter shapes the bandwidth of the local writers writing into the
+// engines. Every table has its own budget, so that a gigantic table can't
+// starve the flushes of small tables on a shared disk.
+//
+// Each local backend has its own limiter, which is shared by all its writers.
+// The owner of the backend keeps a reference to adjust the limit at run time,
+// e.g. through the status API.
+type TableIOLimiter struct {
+	// limit is the bytes per second allowed for each table, 0 means unlimited.
+	limit atomic.Int64
+
+	mu      sync.Mutex
+	buckets map[string]*tableIOBucket
+}
+
+type tableIOBucket struct {
+	// available is the bytes which can be written without waiting. It becomes
+	// negative when the writers are in debt.
+	available float64
+	last      time.Time
+}
+
+// NewTableIOLimiter creates a TableIOLimiter with the given bytes per second
+// for each table. 0 means unlimited.
+func NewTableIOLimiter(limit int64) *TableIOLimiter {
+	l := &TableIOLimiter{buckets: make(map[string]*tableIOBucket)}
+	l.SetLimit(limit)
+	return l
+}
+
+// Limit returns the bytes per second allowed for each table.
+func (l *TableIOLimiter) Limit() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.limit.Load()
+}
+
+// SetLimit changes the bytes per second allowed for each table. It takes
+// effect on the next write.
+func (l *TableIOLimiter) SetLimit(limit int64) {
+	if limit < 0 {
+		limit = 0
+	}
+	l.limit.Store(limit)
+}
+
+// reserve takes n bytes from the budget of the table, and returns how long
+// the caller should wait before writing them.
+func (l *TableIOLimiter) reserve(tableName string, n int64, now time.Time) time.Duration {
+	limit := l.limit.Load()
+	if limit <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[tableName]
+	if !ok {
+		// allow a burst of one second on start.
+		bucket = &tableIOBucket{available: float64(limit), last: now}
+		l.buckets[tableName] = bucket
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.available += elapsed.Seconds() * float64(limit)
+		if bucket.available > float64(limit) {
+			bucket.available = float64(limit)
+		}
+		bucket.last = now
+	}
+	bucket.available -= float64(n)
+	if bucket.available >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.available / float64(limit) * float64(time.Second))
+}
+
+// Wait blocks until n bytes of the table are allowed to be written.
+func (l *TableIOLimiter) Wait(ctx context.Context, tableName string, n int64) error {
+	if l == nil {
+		return nil
+	}
+	wait := l.reserve(tableName, n, time.Now())
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
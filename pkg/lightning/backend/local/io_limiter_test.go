@@ -0,0 +1,57 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+type ioLimiterSuite struct{}
+
+var _ = Suite(&ioLimiterSuite{})
+
+func (s *ioLimiterSuite) TestReserve(c *C) {
+	l := NewTableIOLimiter(0)
+	now := time.Now()
+	// unlimited.
+	c.Assert(l.reserve("a", 1<<30, now), Equals, time.Duration(0))
+
+	l.SetLimit(1000)
+	// a burst of one second is allowed.
+	c.Assert(l.reserve("a", 1000, now), Equals, time.Duration(0))
+	// then the writer is in debt.
+	c.Assert(l.reserve("a", 500, now), Equals, 500*time.Millisecond)
+	// other tables are not affected.
+	c.Assert(l.reserve("b", 1000, now), Equals, time.Duration(0))
+	// the budget is refilled as time goes.
+	c.Assert(l.reserve("a", 1000, now.Add(time.Second)), Equals, 500*time.Millisecond)
+	c.Assert(l.reserve("a", 0, now.Add(3*time.Second)), Equals, time.Duration(0))
+	// the burst is capped to one second.
+	c.Assert(l.reserve("a", 2000, now.Add(10*time.Second)), Equals, time.Second)
+
+	var nilLimiter *TableIOLimiter
+	c.Assert(nilLimiter.Limit(), Equals, int64(0))
+	c.Assert(nilLimiter.Wait(context.Background(), "a", 1<<30), IsNil)
+}
+
+func (s *ioLimiterSuite) TestWaitCanceled(c *C) {
+	l := NewTableIOLimiter(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(l.Wait(ctx, "a", 1), IsNil)
+	c.Assert(l.Wait(ctx, "a", 1000), Equals, context.Canceled)
+}
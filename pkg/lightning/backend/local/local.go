@@ -62,6 +62,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/pingcap/br/pkg/conn"
+	"github.com/pingcap/br/pkg/grpcutil"
 	"github.com/pingcap/br/pkg/lightning/backend"
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
@@ -70,6 +71,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/glue"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/manual"
+	"github.com/pingcap/br/pkg/lightning/membudget"
 	"github.com/pingcap/br/pkg/lightning/metric"
 	"github.com/pingcap/br/pkg/lightning/tikv"
 	"github.com/pingcap/br/pkg/lightning/worker"
@@ -106,6 +108,14 @@ const (
 	duplicateDBName       = "duplicates"
 	remoteDuplicateDBName = "remote_duplicates"
 	scanRegionLimit       = 128
+
+	// blockCacheBudgetRatio is the share of the memory budget reserved for
+	// the block cache shared by the engines.
+	blockCacheBudgetRatio = 0.25
+	// writerBudgetRatio is the share of the memory budget split for the KV
+	// pairs buffered by the local writers. The writers flush early rather
+	// than wait for it, and the rest of the budget is left to the encoders.
+	writerBudgetRatio = 0.25
 )
 
 var (
@@ -231,8 +241,7 @@ func (e *File) Cleanup(dataDir string) error {
 		return errors.Trace(err)
 	}
 
-	dbPath := filepath.Join(dataDir, e.UUID.String())
-	return os.RemoveAll(dbPath)
+	return removeEngineFiles(dataDir, e.UUID)
 }
 
 // Exist checks if db folder existing (meta sometimes won't flush before lightning exit)
@@ -298,6 +307,22 @@ func (e *File) isLocked() bool {
 	return isStateLocked(importMutexState(e.isImportingAtomic.Load()))
 }
 
+// keyRange returns the first and the last keys of the engine, nil if it's
+// empty.
+func (e *File) keyRange(ctx context.Context) (firstKey, lastKey []byte, err error) {
+	iter := newKeyIter(ctx, e, &pebble.IterOptions{})
+	defer iter.Close()
+	if !iter.First() {
+		return nil, nil, errors.Annotate(iter.Error(), "failed to read the first key")
+	}
+	firstKey = append([]byte{}, iter.Key()...)
+	if !iter.Last() {
+		return nil, nil, errors.Annotate(iter.Error(), "failed to seek to the last key")
+	}
+	lastKey = append([]byte{}, iter.Key()...)
+	return firstKey, lastKey, nil
+}
+
 func (e *File) getEngineFileSize() backend.EngineFileSize {
 	metrics := e.db.Metrics()
 	total := metrics.Total()
@@ -801,12 +826,18 @@ type local struct {
 	engines sync.Map // sync version of map[uuid.UUID]*File
 
 	pdCtl    *pdutil.PdController
-	conns    common.GRPCConns
+	conns    *common.StoreConnManager
 	splitCli split.SplitClient
 	tls      *common.TLS
+	grpcCfg  grpcutil.Config
 	pdAddr   string
 	g        glue.Glue
 
+	// duplicateConns are the connections of detecting the duplicates, which
+	// are the ones of ingesting unless the duplicate detection has its own
+	// gRPC options.
+	duplicateConns *common.StoreConnManager
+
 	localStoreDir   string
 	regionSplitSize int64
 	regionSplitKeys int64
@@ -821,68 +852,24 @@ type local struct {
 
 	engineMemCacheSize      int
 	localWriterMemCacheSize int64
+	spillUnorderedKVs       bool
 	supportMultiIngest      bool
 
-	duplicateDetection bool
-	duplicateDB        *pebble.DB
-}
-
-// connPool is a lazy pool of gRPC channels.
-// When `Get` called, it lazily allocates new connection if connection not full.
-// If it's full, then it will return allocated channels round-robin.
-type connPool struct {
-	mu sync.Mutex
+	duplicateDetection      bool
+	duplicateDetectFailFast bool
+	duplicateDB             *pebble.DB
 
-	conns   []*grpc.ClientConn
-	next    int
-	cap     int
-	newConn func(ctx context.Context) (*grpc.ClientConn, error)
-}
+	cfg       backend.BackendConfig
+	ioLimiter *TableIOLimiter
 
-func (p *connPool) takeConns() (conns []*grpc.ClientConn) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.conns, conns = nil, p.conns
-	p.next = 0
-	return conns
-}
-
-// Close closes the conn pool.
-func (p *connPool) Close() {
-	for _, c := range p.takeConns() {
-		if err := c.Close(); err != nil {
-			log.L().Warn("failed to close clientConn", zap.String("target", c.Target()), log.ShortError(err))
-		}
-	}
-}
-
-// get tries to get an existing connection from the pool, or make a new one if the pool not full.
-func (p *connPool) get(ctx context.Context) (*grpc.ClientConn, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if len(p.conns) < p.cap {
-		c, err := p.newConn(ctx)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		p.conns = append(p.conns, c)
-		return c, nil
-	}
-
-	conn := p.conns[p.next]
-	p.next = (p.next + 1) % p.cap
-	return conn, nil
-}
-
-// newConnPool creates a new connPool by the specified conn factory function and capacity.
-func newConnPool(cap int, newConn func(ctx context.Context) (*grpc.ClientConn, error)) *connPool {
-	return &connPool{
-		cap:     cap,
-		conns:   make([]*grpc.ClientConn, 0, cap),
-		newConn: newConn,
-
-		mu: sync.Mutex{},
-	}
+	// memBudget is nil if the memory isn't budgeted. writerBudget is the
+	// share split from it for the local writers.
+	memBudget    *membudget.Budget
+	writerBudget *membudget.Budget
+	// blockCache is shared by the engines, it's nil if the memory isn't
+	// budgeted and every engine has its own default cache.
+	blockCache     *pebble.Cache
+	blockCacheSize int64
 }
 
 var bufferPool = membuf.NewPool(1024, manual.Allocator{})
@@ -903,6 +890,8 @@ func NewLocalBackend(
 	enableCheckpoint bool,
 	g glue.Glue,
 	maxOpenFiles int,
+	backendCfg backend.BackendConfig,
+	ioLimiter *TableIOLimiter,
 ) (backend.Backend, error) {
 	localFile := cfg.SortedKVDir
 	rangeConcurrency := cfg.RangeConcurrency
@@ -911,7 +900,7 @@ func NewLocalBackend(
 	if err != nil {
 		return backend.MakeBackend(nil), errors.Annotate(err, "construct pd client failed")
 	}
-	splitCli := split.NewSplitClient(pdCtl.GetPDClient(), tls.TLSConfig())
+	splitCli := split.NewSplitClientWithHTTP(pdCtl.GetPDClient(), tls.TLSConfig(), pdCtl.HTTPClient())
 
 	shouldCreate := true
 	if enableCheckpoint {
@@ -950,6 +939,7 @@ func NewLocalBackend(
 		pdCtl:    pdCtl,
 		splitCli: splitCli,
 		tls:      tls,
+		grpcCfg:  cfg.GRPC,
 		pdAddr:   pdAddr,
 		g:        g,
 
@@ -966,15 +956,40 @@ func NewLocalBackend(
 
 		engineMemCacheSize:      int(cfg.EngineMemCacheSize),
 		localWriterMemCacheSize: int64(cfg.LocalWriterMemCacheSize),
+		spillUnorderedKVs:       cfg.SpillUnorderedKVs,
 		duplicateDetection:      cfg.DuplicateDetection,
+		duplicateDetectFailFast: cfg.DuplicateDetectFailFast,
 		duplicateDB:             duplicateDB,
 	}
-	local.conns = common.NewGRPCConns()
+	if backendCfg.RetryImportDelay == 0 {
+		backendCfg.RetryImportDelay = defaultRetryBackoffTime
+	}
+	local.cfg = backendCfg
+	if ioLimiter == nil {
+		ioLimiter = NewTableIOLimiter(int64(cfg.PerTableIOLimit))
+	}
+	local.ioLimiter = ioLimiter
+	local.memBudget = backendCfg.MemoryBudget
+	if local.blockCacheSize = local.memBudget.Reserve(blockCacheBudgetRatio); local.blockCacheSize > 0 {
+		local.blockCache = pebble.NewCache(local.blockCacheSize)
+		log.L().Info("share the block cache among the engines", zap.Int64("size", local.blockCacheSize))
+	}
+	local.writerBudget = local.memBudget.Split(writerBudgetRatio)
+	local.conns = common.NewStoreConnManager(local.tcpConcurrency, common.DefaultConnIdleTimeout, local.makeConn)
+	if cfg.DuplicateGRPC == (grpcutil.Config{}) {
+		local.duplicateConns = local.conns.Retain()
+	} else {
+		duplicateGRPC := cfg.DuplicateGRPC
+		local.duplicateConns = common.NewStoreConnManager(local.tcpConcurrency, common.DefaultConnIdleTimeout,
+			func(ctx context.Context, storeID uint64, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+				return local.dialStore(ctx, storeID, &duplicateGRPC, opts...)
+			})
+	}
 	if err = local.checkMultiIngestSupport(ctx, pdCtl); err != nil {
 		return backend.MakeBackend(nil), err
 	}
 
-	return backend.MakeBackend(local), nil
+	return backend.MakeBackendWithConfig(local, backendCfg), nil
 }
 
 func (local *local) checkMultiIngestSupport(ctx context.Context, pdCtl *pdutil.PdController) error {
@@ -987,34 +1002,16 @@ func (local *local) checkMultiIngestSupport(ctx context.Context, pdCtl *pdutil.P
 		if s.State != metapb.StoreState_Up {
 			continue
 		}
-		var err error
-		for i := 0; i < maxRetryTimes; i++ {
-			if i > 0 {
-				select {
-				case <-time.After(100 * time.Millisecond):
-				case <-ctx.Done():
-					return ctx.Err()
-				}
-			}
-			client, err1 := local.getImportClient(ctx, s.Id)
-			if err1 != nil {
-				err = err1
-				log.L().Warn("get import client failed", zap.Error(err), zap.String("store", s.Address))
-				continue
-			}
-			_, err = client.MultiIngest(ctx, &sst.MultiIngestRequest{})
-			if err == nil {
-				break
-			}
-			if st, ok := status.FromError(err); ok {
-				if st.Code() == codes.Unimplemented {
-					log.L().Info("multi ingest not support", zap.Any("unsupported store", s))
-					local.supportMultiIngest = false
-					return nil
-				}
-			}
-			log.L().Warn("check multi ingest support failed", zap.Error(err), zap.String("store", s.Address),
-				zap.Int("retry", i))
+		client, err := local.getImportClient(ctx, s.Id)
+		if err == nil {
+			// the empty request ingests nothing, so it's retried by the
+			// interceptor when the store is unavailable.
+			_, err = client.MultiIngest(ctx, &sst.MultiIngestRequest{}, grpcutil.Idempotent())
+		}
+		if status.Code(err) == codes.Unimplemented {
+			log.L().Info("multi ingest not support", zap.Any("unsupported store", s))
+			local.supportMultiIngest = false
+			return nil
 		}
 		if err != nil {
 			log.L().Warn("check multi failed all retry, fallback to false", log.ShortError(err))
@@ -1080,7 +1077,17 @@ func (local *local) lockAllEnginesUnless(newState, ignoreStateMask importMutexSt
 	return allEngines
 }
 
-func (local *local) makeConn(ctx context.Context, storeID uint64) (*grpc.ClientConn, error) {
+func (local *local) makeConn(ctx context.Context, storeID uint64, extraOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return local.dialStore(ctx, storeID, &local.grpcCfg, extraOpts...)
+}
+
+// dialStore connects to the store with the gRPC options.
+func (local *local) dialStore(
+	ctx context.Context,
+	storeID uint64,
+	grpcCfg *grpcutil.Config,
+	extraOpts ...grpc.DialOption,
+) (*grpc.ClientConn, error) {
 	store, err := local.splitCli.GetStore(ctx, storeID)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -1089,7 +1096,7 @@ func (local *local) makeConn(ctx context.Context, storeID uint64) (*grpc.ClientC
 	if local.tls.TLSConfig() != nil {
 		opt = grpc.WithTransportCredentials(credentials.NewTLS(local.tls.TLSConfig()))
 	}
-	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	ctx, cancel := context.WithTimeout(ctx, grpcCfg.DialTimeoutOr(dialTimeout))
 
 	bfConf := backoff.DefaultConfig
 	bfConf.MaxDelay = gRPCBackOffMaxDelay
@@ -1098,17 +1105,19 @@ func (local *local) makeConn(ctx context.Context, storeID uint64) (*grpc.ClientC
 	if addr == "" {
 		addr = store.GetAddress()
 	}
-	conn, err := grpc.DialContext(
-		ctx,
-		addr,
+	opts := append([]grpc.DialOption{
 		opt,
 		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bfConf}),
-		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		grpc.WithKeepaliveParams(grpcCfg.KeepaliveOr(keepalive.ClientParameters{
 			Time:                gRPCKeepAliveTime,
 			Timeout:             gRPCKeepAliveTimeout,
 			PermitWithoutStream: true,
-		}),
-	)
+		})),
+	}, grpcCfg.DialOptions()...)
+	// the connections of the duplicate detection are intercepted as well.
+	opts = append(opts, grpcutil.InterceptorDialOptions()...)
+	opts = append(opts, extraOpts...)
+	conn, err := grpc.DialContext(ctx, addr, opts...)
 	cancel()
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -1117,10 +1126,24 @@ func (local *local) makeConn(ctx context.Context, storeID uint64) (*grpc.ClientC
 }
 
 func (local *local) getGrpcConn(ctx context.Context, storeID uint64) (*grpc.ClientConn, error) {
-	return local.conns.GetGrpcConn(ctx, storeID, local.tcpConcurrency,
-		func(ctx context.Context) (*grpc.ClientConn, error) {
-			return local.makeConn(ctx, storeID)
-		})
+	return local.conns.GetConn(ctx, storeID)
+}
+
+// CloseWithContext closes the local backend like Close, which waits for the
+// engines being imported or flushed. If ctx is done first, it returns the
+// error of ctx and the close goes on in the background.
+func (local *local) CloseWithContext(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		local.Close()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Annotate(ctx.Err(), "stop waiting for closing the local backend")
+	}
 }
 
 // Close the local backend.
@@ -1132,7 +1155,13 @@ func (local *local) Close() {
 		engine.Close()
 		engine.unlock()
 	}
-	local.conns.Close()
+	local.conns.Release()
+	local.duplicateConns.Release()
+	if local.blockCache != nil {
+		local.blockCache.Unref()
+		local.memBudget.Unreserve(local.blockCacheSize)
+	}
+	local.memBudget.Unreserve(local.writerBudget.Limit())
 
 	if local.duplicateDB != nil {
 		// Check whether there are duplicates.
@@ -1205,7 +1234,7 @@ func (local *local) FlushAllEngines(parentCtx context.Context) (err error) {
 }
 
 func (local *local) RetryImportDelay() time.Duration {
-	return defaultRetryBackoffTime
+	return local.cfg.RetryImportDelay
 }
 
 func (local *local) MaxChunkSize() int {
@@ -1233,6 +1262,8 @@ func (local *local) openEngineDB(engineUUID uuid.UUID, readOnly bool) (*pebble.D
 		TablePropertyCollectors: []func() pebble.TablePropertyCollector{
 			newRangePropertiesCollector,
 		},
+		// nil uses a cache of the default size for the engine.
+		Cache: local.blockCache,
 	}
 	// set level target file size to avoid pebble auto triggering compaction that split ingest SST files into small SST.
 	opt.Levels = []pebble.LevelOptions{
@@ -1256,6 +1287,9 @@ func (local *local) OpenEngine(ctx context.Context, cfg *backend.EngineConfig, e
 	if err != nil {
 		return err
 	}
+	if err := saveEngineCreateMeta(local.localStoreDir, engineUUID, cfg.TableInfo); err != nil {
+		log.L().Warn("failed to save engine meta", zap.Stringer("engine", engineUUID), log.ShortError(err))
+	}
 
 	sstDir := engineSSTDir(local.localStoreDir, engineUUID)
 	if err := os.RemoveAll(sstDir); err != nil {
@@ -1805,7 +1839,9 @@ loopWrite:
 		var metas []*sst.SSTMeta
 		var finishedRange Range
 		var rangeStats rangeStats
-		metas, finishedRange, rangeStats, err = local.WriteToTiKV(ctx, engineFile, region, start, end)
+		writeCtx, cancel := local.cfg.WithWriteTimeout(ctx)
+		metas, finishedRange, rangeStats, err = local.WriteToTiKV(writeCtx, engineFile, region, start, end)
+		cancel()
 		if err != nil {
 			if common.IsContextCanceledError(err) {
 				return err
@@ -2074,10 +2110,12 @@ func (local *local) CollectLocalDuplicateRows(ctx context.Context, tbl table.Tab
 	ts := oracle.ComposeTS(physicalTS, logicalTS)
 	// TODO: Here we use this db to store the duplicate rows. We shall remove this parameter and store the result in
 	//  a TiDB table.
-	duplicateManager, err := NewDuplicateManager(local.duplicateDB, local.splitCli, ts, local.tls, local.tcpConcurrency)
+	duplicateManager, err := NewDuplicateManager(local.duplicateDB, local.splitCli, ts, local.duplicateConns.Retain(), local.tcpConcurrency,
+		local.duplicateDetectFailFast)
 	if err != nil {
 		return errors.Annotate(err, "open duplicatemanager failed")
 	}
+	defer duplicateManager.Close()
 	if err := duplicateManager.CollectDuplicateRowsFromLocalIndex(ctx, tbl, local.duplicateDB); err != nil {
 		return errors.Annotate(err, "collect local duplicate rows failed")
 	}
@@ -2098,19 +2136,20 @@ func (local *local) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Ta
 	if err != nil {
 		return errors.Annotate(err, "open duplicate db failed")
 	}
+	defer duplicateDB.Close()
 
 	// TODO: Here we use the temp created db to store the duplicate rows. We shall remove this parameter and store the
 	//  result in a TiDB table.
-	duplicateManager, err := NewDuplicateManager(duplicateDB, local.splitCli, ts, local.tls, local.tcpConcurrency)
+	duplicateManager, err := NewDuplicateManager(duplicateDB, local.splitCli, ts, local.duplicateConns.Retain(), local.tcpConcurrency,
+		local.duplicateDetectFailFast)
 	if err != nil {
 		return errors.Annotate(err, "open duplicatemanager failed")
 	}
+	defer duplicateManager.Close()
 	if err = duplicateManager.CollectDuplicateRowsFromTiKV(ctx, tbl); err != nil {
 		return errors.Annotate(err, "collect remote duplicate rows failed")
 	}
-	err = local.reportDuplicateRows(tbl, duplicateDB)
-	duplicateDB.Close()
-	return err
+	return local.reportDuplicateRows(tbl, duplicateDB)
 }
 
 func (local *local) reportDuplicateRows(tbl table.Table, db *pebble.DB) error {
@@ -2409,13 +2448,28 @@ func (local *local) LocalWriter(ctx context.Context, cfg *backend.LocalWriterCon
 		return nil, errors.Errorf("could not find engine for %s", engineUUID.String())
 	}
 	engineFile := e.(*File)
-	return openLocalWriter(ctx, cfg, engineFile, local.localWriterMemCacheSize)
+	w, err := openLocalWriter(ctx, cfg, engineFile, local.localWriterMemCacheSize, local.ioLimiter)
+	if err != nil {
+		return nil, err
+	}
+	w.memBudget = local.writerBudget
+	if local.spillUnorderedKVs {
+		w.spill = &spillSorter{}
+	}
+	return w, nil
 }
 
-func openLocalWriter(ctx context.Context, cfg *backend.LocalWriterConfig, f *File, cacheSize int64) (*Writer, error) {
+func openLocalWriter(
+	ctx context.Context,
+	cfg *backend.LocalWriterConfig,
+	f *File,
+	cacheSize int64,
+	ioLimiter *TableIOLimiter,
+) (*Writer, error) {
 	w := &Writer{
 		local:              f,
 		memtableSizeLimit:  cacheSize,
+		ioLimiter:          ioLimiter,
 		kvBuffer:           bufferPool.NewBuffer(),
 		isKVSorted:         cfg.IsKVSorted,
 		isWriteBatchSorted: true,
@@ -2744,6 +2798,14 @@ type Writer struct {
 	totalCount int64
 
 	lastMetaSeq int32
+
+	ioLimiter *TableIOLimiter
+	// memBudget admits the KV pairs buffered in writeBatch, budgetSize is
+	// the bytes admitted for them.
+	memBudget  *membudget.Budget
+	budgetSize int64
+	// spill is nil if the KV pairs out of order are never sorted externally.
+	spill *spillSorter
 }
 
 func (w *Writer) appendRowsSorted(kvs []common.KvPair) error {
@@ -2800,7 +2862,12 @@ func (w *Writer) appendRowsUnsorted(ctx context.Context, kvs []common.KvPair) er
 	}
 	w.batchCount = cnt
 
-	if w.batchSize > w.memtableSizeLimit {
+	// flush early if the memory budget can't admit the grown buffer.
+	budgetFull := !w.memBudget.TryAcquire(w.batchSize - w.budgetSize)
+	if !budgetFull {
+		w.budgetSize = w.batchSize
+	}
+	if w.batchSize > w.memtableSizeLimit || budgetFull {
 		if err := w.flushKVs(ctx); err != nil {
 			return err
 		}
@@ -2818,6 +2885,31 @@ func (local *local) EngineFileSizes() (res []backend.EngineFileSize) {
 	return
 }
 
+// EngineMetadata obtains the count and the size of the KV pairs flushed into
+// the engine, and its key range unless it's being imported or closed.
+func (local *local) EngineMetadata(ctx context.Context, engineUUID uuid.UUID) (*backend.EngineMetadata, error) {
+	e, ok := local.engines.Load(engineUUID)
+	if !ok {
+		return nil, nil
+	}
+	engine := e.(*File)
+	meta := &backend.EngineMetadata{
+		UUID:    engineUUID,
+		KVCount: engine.Length.Load(),
+		Size:    engine.TotalSize.Load(),
+	}
+	if !engine.tryRLock() {
+		return meta, nil
+	}
+	defer engine.rUnlock()
+	if engine.closed.Load() {
+		return meta, nil
+	}
+	var err error
+	meta.MinKey, meta.MaxKey, err = engine.keyRange(ctx)
+	return meta, err
+}
+
 func (w *Writer) AppendRows(ctx context.Context, tableName string, columnNames []string, rows kv.Rows) error {
 	kvs := kv.KvPairsFromRows(rows)
 	if len(kvs) == 0 {
@@ -2828,6 +2920,16 @@ func (w *Writer) AppendRows(ctx context.Context, tableName string, columnNames [
 		return errorEngineClosed
 	}
 
+	if w.ioLimiter.Limit() > 0 {
+		var size int64
+		for _, pair := range kvs {
+			size += int64(len(pair.Key) + len(pair.Val))
+		}
+		if err := w.ioLimiter.Wait(ctx, tableName, size); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
 	w.Lock()
 	defer w.Unlock()
 
@@ -2849,14 +2951,16 @@ func (w *Writer) AppendRows(ctx context.Context, tableName string, columnNames [
 func (w *Writer) flush(ctx context.Context) error {
 	w.Lock()
 	defer w.Unlock()
-	if w.batchCount == 0 {
+	if w.batchCount == 0 && !w.spill.pending() {
 		return nil
 	}
 
-	w.totalSize += w.batchSize
-	if len(w.writeBatch) > 0 {
-		if err := w.flushKVs(ctx); err != nil {
-			return errors.Trace(err)
+	if w.batchCount > 0 {
+		w.totalSize += w.batchSize
+		if len(w.writeBatch) > 0 {
+			if err := w.flushKVs(ctx); err != nil {
+				return errors.Trace(err)
+			}
 		}
 	}
 
@@ -2872,7 +2976,8 @@ func (w *Writer) flush(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	// the runs spilled are merged after the last batch is spilled.
+	return w.mergeSpilled(ctx)
 }
 
 type flushStatus struct {
@@ -2892,11 +2997,12 @@ func (w *Writer) Close(ctx context.Context) (backend.ChunkFlushStatus, error) {
 	// this can resolve the memory consistently increasing issue.
 	// maybe this is a bug related to go GC mechanism.
 	w.writeBatch = nil
+	w.releaseBudget()
 	return flushStatus{local: w.local, seq: w.lastMetaSeq}, err
 }
 
 func (w *Writer) IsSynced() bool {
-	return w.batchCount == 0 && w.lastMetaSeq <= w.local.finishedMetaSeq.Load()
+	return w.batchCount == 0 && !w.spill.pending() && w.lastMetaSeq <= w.local.finishedMetaSeq.Load()
 }
 
 func (w *Writer) flushKVs(ctx context.Context) error {
@@ -2920,8 +3026,9 @@ func (w *Writer) flushKVs(ctx context.Context) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
-	err = w.addSST(ctx, meta)
-	if err != nil {
+	if w.spill.observe(meta) {
+		w.spill.add(w.local, meta)
+	} else if err = w.addSST(ctx, meta); err != nil {
 		return errors.Trace(err)
 	}
 
@@ -2929,9 +3036,17 @@ func (w *Writer) flushKVs(ctx context.Context) error {
 	w.batchSize = 0
 	w.batchCount = 0
 	w.kvBuffer.Reset()
+	w.releaseBudget()
 	return nil
 }
 
+// releaseBudget gives back the memory budget admitted for the KV pairs
+// buffered.
+func (w *Writer) releaseBudget() {
+	w.memBudget.Release(w.budgetSize)
+	w.budgetSize = 0
+}
+
 func (w *Writer) addSST(ctx context.Context, meta *sstMeta) error {
 	seq, err := w.local.addSST(ctx, meta)
 	if err != nil {
@@ -30,6 +30,7 @@ import (
 	"github.com/coreos/go-semver/semver"
 	"github.com/docker/go-units"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/errorpb"
@@ -348,7 +349,7 @@ func testLocalWriter(c *C, needSort bool, partitialSort bool) {
 	f.wg.Add(1)
 	go f.ingestSSTLoop()
 	sorted := needSort && !partitialSort
-	w, err := openLocalWriter(context.Background(), &backend.LocalWriterConfig{IsKVSorted: sorted}, f, 1<<20)
+	w, err := openLocalWriter(context.Background(), &backend.LocalWriterConfig{IsKVSorted: sorted}, f, 1<<20, nil)
 	c.Assert(err, IsNil)
 
 	ctx := context.Background()
@@ -414,6 +415,21 @@ func testLocalWriter(c *C, needSort bool, partitialSort bool) {
 		c.Assert(it.Key(), DeepEquals, k)
 		it.Next()
 	}
+
+	local := &local{}
+	local.engines.Store(engineUUID, f)
+	meta, err := local.EngineMetadata(ctx, engineUUID)
+	c.Assert(err, IsNil)
+	c.Assert(meta, DeepEquals, &backend.EngineMetadata{
+		UUID:    engineUUID,
+		KVCount: 20000,
+		Size:    144 * 20000,
+		MinKey:  keys[0],
+		MaxKey:  keys[len(keys)-1],
+	})
+	meta, err = local.EngineMetadata(ctx, uuid.New())
+	c.Assert(err, IsNil)
+	c.Assert(meta, IsNil)
 	close(f.sstMetasChan)
 	f.wg.Wait()
 }
@@ -430,6 +446,78 @@ func (s *localSuite) TestLocalWriterWithIngestUnsort(c *C) {
 	testLocalWriter(c, true, true)
 }
 
+func (s *localSuite) TestLocalWriterSpill(c *C) {
+	dir := c.MkDir()
+	db, err := pebble.Open(filepath.Join(dir, "test"), &pebble.Options{DisableWAL: true})
+	c.Assert(err, IsNil)
+	defer db.Close()
+	tmpPath := filepath.Join(dir, "test.sst")
+	c.Assert(os.Mkdir(tmpPath, 0o755), IsNil)
+
+	_, engineUUID := backend.MakeUUID("ww", 0)
+	engineCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := &File{
+		db:           db,
+		UUID:         engineUUID,
+		sstDir:       tmpPath,
+		ctx:          engineCtx,
+		cancel:       cancel,
+		sstMetasChan: make(chan metaOrFlush, 64),
+		keyAdapter:   noopKeyAdapter{},
+	}
+	f.sstIngester = dbSSTIngester{e: f}
+	f.wg.Add(1)
+	go f.ingestSSTLoop()
+	w, err := openLocalWriter(context.Background(), &backend.LocalWriterConfig{}, f, 16<<10, nil)
+	c.Assert(err, IsNil)
+	w.spill = &spillSorter{}
+
+	// the random keys of every batch overlap those of the batches before.
+	ctx := context.Background()
+	keys := make([][]byte, 0, 4000)
+	for i := 0; i < 40; i++ {
+		kvs := make([]common.KvPair, 0, 100)
+		for j := 0; j < 100; j++ {
+			key := make([]byte, 16)
+			binary.BigEndian.PutUint64(key, uint64(rand.Intn(1000)))
+			binary.BigEndian.PutUint64(key[8:], uint64(i*100+j))
+			kvs = append(kvs, common.KvPair{Key: key, Val: make([]byte, 128)})
+			keys = append(keys, key)
+		}
+		c.Assert(w.AppendRows(ctx, "", []string{}, kv.MakeRowsFromKvPairs(kvs)), IsNil)
+	}
+	c.Assert(w.spill.spilling, IsTrue)
+	c.Assert(w.spill.pending(), IsTrue)
+	c.Assert(w.IsSynced(), IsFalse)
+	spilled := w.spill.runs
+
+	flushStatus, err := w.Close(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(f.flushEngineWithoutLock(ctx), IsNil)
+	c.Assert(flushStatus.Flushed(), IsTrue)
+	c.Assert(w.spill.pending(), IsFalse)
+	for _, run := range spilled {
+		_, err := os.Stat(run.path)
+		c.Assert(os.IsNotExist(err), IsTrue)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	c.Assert(int(f.Length.Load()), Equals, len(keys))
+	it := db.NewIter(&pebble.IterOptions{})
+	defer it.Close()
+	c.Assert(it.First(), IsTrue)
+	for _, k := range keys {
+		c.Assert(it.Key(), DeepEquals, k)
+		it.Next()
+	}
+	c.Assert(it.Valid(), IsFalse)
+	close(f.sstMetasChan)
+	f.wg.Wait()
+}
+
 type mockSplitClient struct {
 	restore.SplitClient
 }
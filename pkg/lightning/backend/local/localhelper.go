@@ -373,28 +373,9 @@ func paginateScanRegion(
 		return nil, errors.Errorf("startKey >= endKey when paginating scan region")
 	}
 
-	var regions []*split.RegionInfo
-	for {
-		batch, err := client.ScanRegions(ctx, startKey, endKey, limit)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		regions = append(regions, batch...)
-		if len(batch) < limit {
-			// No more region
-			break
-		}
-		startKey = batch[len(batch)-1].Region.GetEndKey()
-		if len(startKey) == 0 ||
-			(len(endKey) > 0 && bytes.Compare(startKey, endKey) >= 0) {
-			// All key space have scanned
-			break
-		}
-	}
-	sort.Slice(regions, func(i, j int) bool {
-		return bytes.Compare(regions[i].Region.StartKey, regions[j].Region.StartKey) < 0
-	})
-	return regions, nil
+	// the regions are verified to cover the range, and scanned again if not.
+	regions, err := split.PaginateScanRegion(ctx, client, startKey, endKey, limit)
+	return regions, errors.Trace(err)
 }
 
 func (local *local) BatchSplitRegions(ctx context.Context, region *split.RegionInfo, keys [][]byte) (*split.RegionInfo, []*split.RegionInfo, error) {
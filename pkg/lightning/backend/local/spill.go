@@ -0,0 +1,207 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/log"
+)
+
+// spillOverlappedRuns is the number of the sorted runs overlapping the key
+// range of the runs flushed before them, after which a writer finds its KV
+// pairs wildly out of order and starts to spill.
+const spillOverlappedRuns = 4
+
+// spillSorter sorts the KV pairs of a writer externally. Every batch of the
+// writer is sorted in memory and written as an SST, i.e. a sorted run. While
+// the runs are in order, they go to the engine as usual. Once they overlap
+// each other too often, which lets the engine compact the same key range
+// again and again, the runs are spilled aside instead, and merged into the
+// non-overlapping SSTs fed to the engine when the writer is flushed.
+//
+// A nil spillSorter never spills.
+type spillSorter struct {
+	// maxKey is the max key of the runs observed.
+	maxKey []byte
+	// overlapped is the number of the runs overlapping those before them.
+	overlapped int
+	spilling   bool
+	// runs are the runs spilled and not merged yet.
+	runs []*sstMeta
+}
+
+// observe records the key range of the run, and reports whether it should be
+// spilled rather than sent to the engine.
+func (s *spillSorter) observe(run *sstMeta) bool {
+	if s == nil {
+		return false
+	}
+	if s.spilling {
+		return true
+	}
+	if s.maxKey != nil && bytes.Compare(run.minKey, s.maxKey) <= 0 {
+		s.overlapped++
+	}
+	if bytes.Compare(run.maxKey, s.maxKey) > 0 {
+		s.maxKey = append(s.maxKey[:0], run.maxKey...)
+	}
+	if s.overlapped >= spillOverlappedRuns {
+		s.spilling = true
+		log.L().Info("KV pairs of the writer are out of order, spill the sorted runs",
+			zap.Int("overlapped", s.overlapped))
+	}
+	return s.spilling
+}
+
+// add spills the run of the engine. Its file is counted in the pending size
+// of the engine until merged, for the disk quota.
+func (s *spillSorter) add(e *File, run *sstMeta) {
+	e.pendingFileSize.Add(run.fileSize)
+	s.runs = append(s.runs, run)
+}
+
+// pending reports whether there are runs spilled and not merged yet.
+func (s *spillSorter) pending() bool {
+	return s != nil && len(s.runs) > 0
+}
+
+// mergeSpilled merges the runs spilled into the SSTs of at most
+// memtableSizeLimit bytes, which don't overlap each other, and sends them to
+// the engine.
+func (w *Writer) mergeSpilled(ctx context.Context) error {
+	if !w.spill.pending() {
+		return nil
+	}
+	start := time.Now()
+	runs := w.spill.runs
+	mergeIter := &sstIterHeap{iters: make([]*sstIter, 0, len(runs))}
+	defer func() {
+		for _, iter := range mergeIter.iters {
+			_ = iter.Close()
+		}
+	}()
+	for _, run := range runs {
+		iter, err := openSSTIter(run.path)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if iter == nil {
+			continue
+		}
+		mergeIter.iters = append(mergeIter.iters, iter)
+	}
+	heap.Init(mergeIter)
+
+	var (
+		writer  *sstWriter
+		lastKey []byte
+		count   int
+	)
+	pair := make([]common.KvPair, 1)
+	finishSST := func() error {
+		meta, err := writer.close()
+		writer = nil
+		if err != nil {
+			return errors.Trace(err)
+		}
+		count++
+		return w.addSST(ctx, meta)
+	}
+	for {
+		key, val, err := mergeIter.Next()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if key == nil {
+			break
+		}
+		if lastKey != nil && bytes.Equal(lastKey, key) {
+			log.L().Warn("duplicated key found, skipped", zap.Binary("key", key))
+			continue
+		}
+		if writer == nil {
+			if writer, err = w.createSSTWriter(); err != nil {
+				return errors.Trace(err)
+			}
+			writer.minKey = append(writer.minKey[:0], key...)
+		}
+		pair[0] = common.KvPair{Key: key, Val: val}
+		if err = writer.writeKVs(pair); err != nil {
+			return errors.Trace(err)
+		}
+		lastKey = append(lastKey[:0], key...)
+		if writer.totalSize >= w.memtableSizeLimit {
+			if err = finishSST(); err != nil {
+				return err
+			}
+		}
+	}
+	if writer != nil {
+		if err := finishSST(); err != nil {
+			return err
+		}
+	}
+
+	var fileSize int64
+	for _, run := range runs {
+		fileSize += run.fileSize
+		if err := os.Remove(run.path); err != nil {
+			log.L().Warn("cleanup spilled sst file failed", zap.String("file", run.path), zap.Error(err))
+		}
+	}
+	w.local.pendingFileSize.Sub(fileSize)
+	w.spill.runs = nil
+	log.L().Info("merge spilled sst", zap.Int("runs", len(runs)), zap.Int("files", count),
+		zap.Duration("cost", time.Since(start)))
+	return nil
+}
+
+// openSSTIter opens the SST at path and reads its first KV pair. It returns
+// nil if the SST is empty.
+func openSSTIter(path string) (*sstIter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	reader, err := sstable.NewReader(f, sstable.ReaderOptions{})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	iter, err := reader.NewIter(nil, nil)
+	if err != nil {
+		_ = reader.Close()
+		return nil, errors.Trace(err)
+	}
+	it := &sstIter{name: path, iter: iter, reader: reader}
+	key, val := iter.First()
+	if key == nil {
+		err = iter.Error()
+		if closeErr := it.Close(); err == nil {
+			err = closeErr
+		}
+		return nil, errors.Trace(err)
+	}
+	it.key, it.val, it.valid = key.UserKey, val, true
+	return it, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package noop
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/table"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/backend"
+	"github.com/pingcap/br/pkg/lightning/backend/kv"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/lightning/tikv"
+	"github.com/pingcap/br/pkg/lightning/verification"
+)
+
+// NewDryRunBackend creates a backend which encodes the rows into KV pairs
+// exactly like the local and importer backends, and computes their checksums,
+// but discards the KV pairs instead of writing them into TiKV. It is used to
+// validate the source data and estimate the size of an import.
+func NewDryRunBackend(tls *common.TLS) backend.Backend {
+	return backend.MakeBackend(newDryRunBackend(tls))
+}
+
+func newDryRunBackend(tls *common.TLS) *dryRunBackend {
+	return &dryRunBackend{
+		tls:     tls,
+		engines: make(map[uuid.UUID]*dryRunEngine),
+	}
+}
+
+type dryRunBackend struct {
+	noopBackend
+
+	tls *common.TLS
+
+	mu      sync.Mutex
+	engines map[uuid.UUID]*dryRunEngine
+	total   verification.KVChecksum
+}
+
+type dryRunEngine struct {
+	sync.Mutex
+	tableName string
+	checksum  verification.KVChecksum
+}
+
+// Close logs the total size of the KV pairs which would have been imported.
+func (b *dryRunBackend) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	log.L().Info("dry run finished", zap.Object("checksum", &b.total))
+}
+
+// CloseWithContext logs the total size of the KV pairs like Close.
+func (b *dryRunBackend) CloseWithContext(context.Context) error {
+	b.Close()
+	return nil
+}
+
+// MakeEmptyRows creates an empty collection of encoded rows.
+func (b *dryRunBackend) MakeEmptyRows() kv.Rows {
+	return kv.MakeRowsFromKvPairs(nil)
+}
+
+// NewEncoder creates an encoder of a TiDB table.
+func (b *dryRunBackend) NewEncoder(tbl table.Table, options *kv.SessionOptions) (kv.Encoder, error) {
+	return kv.NewTableKVEncoder(tbl, options)
+}
+
+// FetchRemoteTableModels obtains the models of all tables given the schema
+// name.
+func (b *dryRunBackend) FetchRemoteTableModels(ctx context.Context, schemaName string) ([]*model.TableInfo, error) {
+	return tikv.FetchRemoteTableModelsFromTLS(ctx, b.tls, schemaName)
+}
+
+func (b *dryRunBackend) OpenEngine(_ context.Context, cfg *backend.EngineConfig, engineUUID uuid.UUID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.engines[engineUUID]; ok {
+		return nil
+	}
+	engine := &dryRunEngine{}
+	if cfg != nil && cfg.TableInfo != nil {
+		engine.tableName = common.UniqueTable(cfg.TableInfo.DB, cfg.TableInfo.Name)
+	}
+	b.engines[engineUUID] = engine
+	return nil
+}
+
+// CloseEngine logs the size and checksum of the KV pairs written into the
+// engine.
+func (b *dryRunBackend) CloseEngine(_ context.Context, _ *backend.EngineConfig, engineUUID uuid.UUID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	engine, ok := b.engines[engineUUID]
+	if !ok {
+		// the engine is reopened as closed engine when resuming from checkpoint.
+		return nil
+	}
+	delete(b.engines, engineUUID)
+	engine.Lock()
+	defer engine.Unlock()
+	b.total.Add(&engine.checksum)
+	log.L().Info("dry run engine closed",
+		zap.String("table", engine.tableName),
+		zap.Stringer("engine", engineUUID),
+		zap.Object("checksum", &engine.checksum))
+	return nil
+}
+
+// EngineMetadata obtains the count and the size of the KV pairs written into
+// an engine not closed yet. The keys are not kept.
+func (b *dryRunBackend) EngineMetadata(_ context.Context, engineUUID uuid.UUID) (*backend.EngineMetadata, error) {
+	b.mu.Lock()
+	engine, ok := b.engines[engineUUID]
+	b.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	engine.Lock()
+	defer engine.Unlock()
+	return &backend.EngineMetadata{
+		UUID:    engineUUID,
+		KVCount: int64(engine.checksum.SumKVS()),
+		Size:    int64(engine.checksum.SumSize()),
+	}, nil
+}
+
+// LocalWriter obtains a thread-local EngineWriter for writing rows into the given engine.
+func (b *dryRunBackend) LocalWriter(_ context.Context, _ *backend.LocalWriterConfig, engineUUID uuid.UUID) (backend.EngineWriter, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	engine, ok := b.engines[engineUUID]
+	if !ok {
+		engine = &dryRunEngine{}
+		b.engines[engineUUID] = engine
+	}
+	return &dryRunWriter{engine: engine}, nil
+}
+
+type dryRunWriter struct {
+	engine *dryRunEngine
+}
+
+func (w *dryRunWriter) AppendRows(_ context.Context, _ string, _ []string, rows kv.Rows) error {
+	kvs := kv.KvPairsFromRows(rows)
+	if len(kvs) == 0 {
+		return nil
+	}
+	w.engine.Lock()
+	w.engine.checksum.Update(kvs)
+	w.engine.Unlock()
+	return nil
+}
+
+func (w *dryRunWriter) IsSynced() bool {
+	return true
+}
+
+func (w *dryRunWriter) Close(context.Context) (backend.ChunkFlushStatus, error) {
+	return nil, nil
+}
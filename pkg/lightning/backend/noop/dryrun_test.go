@@ -0,0 +1,70 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package noop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/lightning/backend"
+	"github.com/pingcap/br/pkg/lightning/backend/kv"
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/common"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type dryRunSuite struct{}
+
+var _ = Suite(&dryRunSuite{})
+
+func (s *dryRunSuite) TestWriteAndClose(c *C) {
+	ctx := context.Background()
+	b := newDryRunBackend(nil)
+	engineUUID := uuid.New()
+	cfg := &backend.EngineConfig{TableInfo: &checkpoints.TidbTableInfo{DB: "db", Name: "t"}}
+	c.Assert(b.OpenEngine(ctx, cfg, engineUUID), IsNil)
+
+	w1, err := b.LocalWriter(ctx, &backend.LocalWriterConfig{}, engineUUID)
+	c.Assert(err, IsNil)
+	w2, err := b.LocalWriter(ctx, &backend.LocalWriterConfig{}, engineUUID)
+	c.Assert(err, IsNil)
+	c.Assert(w1.AppendRows(ctx, "`db`.`t`", nil, kv.MakeRowsFromKvPairs([]common.KvPair{
+		{Key: []byte("a"), Val: []byte("1")},
+		{Key: []byte("b"), Val: []byte("22")},
+	})), IsNil)
+	c.Assert(w2.AppendRows(ctx, "`db`.`t`", nil, kv.MakeRowsFromKvPairs([]common.KvPair{
+		{Key: []byte("c"), Val: []byte("333")},
+	})), IsNil)
+	c.Assert(w2.AppendRows(ctx, "`db`.`t`", nil, b.MakeEmptyRows()), IsNil)
+	c.Assert(w1.IsSynced(), IsTrue)
+
+	engine := b.engines[engineUUID]
+	c.Assert(engine.tableName, Equals, "`db`.`t`")
+	c.Assert(engine.checksum.SumKVS(), Equals, uint64(3))
+	c.Assert(engine.checksum.SumSize(), Equals, uint64(9))
+
+	c.Assert(b.CloseEngine(ctx, cfg, engineUUID), IsNil)
+	c.Assert(b.engines, HasLen, 0)
+	c.Assert(b.total.SumKVS(), Equals, uint64(3))
+	c.Assert(b.total.SumSize(), Equals, uint64(9))
+	// closing again is a no-op.
+	c.Assert(b.CloseEngine(ctx, cfg, engineUUID), IsNil)
+	c.Assert(b.total.SumKVS(), Equals, uint64(3))
+}
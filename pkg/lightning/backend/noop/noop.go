@@ -49,6 +49,11 @@ func (r noopRows) Clear() kv.Rows {
 // Close the connection to the backend.
 func (b noopBackend) Close() {}
 
+// CloseWithContext closes the connection to the backend.
+func (b noopBackend) CloseWithContext(context.Context) error {
+	return nil
+}
+
 // MakeEmptyRows creates an empty collection of encoded rows.
 func (b noopBackend) MakeEmptyRows() kv.Rows {
 	return noopRows{}
@@ -132,6 +137,12 @@ func (b noopBackend) EngineFileSizes() []backend.EngineFileSize {
 	return nil
 }
 
+// EngineMetadata obtains the metadata of an engine. It returns nil since the
+// rows are discarded.
+func (b noopBackend) EngineMetadata(context.Context, uuid.UUID) (*backend.EngineMetadata, error) {
+	return nil, nil
+}
+
 // ResetEngine clears all written KV pairs in this opened engine.
 func (b noopBackend) ResetEngine(ctx context.Context, engineUUID uuid.UUID) error {
 	return nil
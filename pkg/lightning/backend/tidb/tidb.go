@@ -49,18 +49,46 @@ var extraHandleTableColumn = &table.Column{
 	DefaultExpr:   nil,
 }
 
-const (
-	writeRowsMaxRetryTimes = 3
-)
+// tidbRow is the values of a row to be inserted, e.g. "(1,'a')".
+type tidbRow struct {
+	values string
+	// fields is the number of the values, and columns is the number of the
+	// fields of the rows in the data file. The row takes the default values
+	// of the missing trailing fields when written.
+	fields  int
+	columns int
+}
+
+// valuesOf returns the values of the row for the insert statement with n
+// columns, where the missing trailing fields are filled with DEFAULT.
+func (row tidbRow) valuesOf(n int) string {
+	if row.fields >= n {
+		return row.values
+	}
+	var sb strings.Builder
+	sb.Grow(len(row.values) + 8*(n-row.fields))
+	sb.WriteString(row.values[:len(row.values)-1])
+	for i := row.fields; i < n; i++ {
+		if i != 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString("DEFAULT")
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
 
-type tidbRow string
+// String returns the values of the row with all the fields.
+func (row tidbRow) String() string {
+	return row.valuesOf(row.columns)
+}
 
 type tidbRows []tidbRow
 
 // MarshalLogArray implements the zapcore.ArrayMarshaler interface
 func (rows tidbRows) MarshalLogArray(encoder zapcore.ArrayEncoder) error {
 	for _, r := range rows {
-		encoder.AppendString(redact.String(string(r)))
+		encoder.AppendString(redact.String(r.String()))
 	}
 	return nil
 }
@@ -78,24 +106,26 @@ type tidbEncoder struct {
 type tidbBackend struct {
 	db          *sql.DB
 	onDuplicate string
+	cfg         backend.BackendConfig
 }
 
 // NewTiDBBackend creates a new TiDB backend using the given database.
 //
 // The backend does not take ownership of `db`. Caller should close `db`
 // manually after the backend expired.
-func NewTiDBBackend(db *sql.DB, onDuplicate string) backend.Backend {
+func NewTiDBBackend(db *sql.DB, onDuplicate string, backendCfg backend.BackendConfig) backend.Backend {
 	switch onDuplicate {
 	case config.ReplaceOnDup, config.IgnoreOnDup, config.ErrorOnDup:
 	default:
 		log.L().Warn("unsupported action on duplicate, overwrite with `replace`")
 		onDuplicate = config.ReplaceOnDup
 	}
-	return backend.MakeBackend(&tidbBackend{db: db, onDuplicate: onDuplicate})
+	backendCfg.Adjust()
+	return backend.MakeBackendWithConfig(&tidbBackend{db: db, onDuplicate: onDuplicate, cfg: backendCfg}, backendCfg)
 }
 
 func (row tidbRow) Size() uint64 {
-	return uint64(len(row))
+	return uint64(len(row.values))
 }
 
 func (row tidbRow) ClassifyAndAppend(data *kv.Rows, checksum *verification.KVChecksum, _ *kv.Rows, _ *verification.KVChecksum) {
@@ -103,7 +133,7 @@ func (row tidbRow) ClassifyAndAppend(data *kv.Rows, checksum *verification.KVChe
 	// Cannot do `rows := data.(*tidbRows); *rows = append(*rows, row)`.
 	//nolint:gocritic
 	*data = append(rows, row)
-	cs := verification.MakeKVChecksum(uint64(len(row)), 1, 0)
+	cs := verification.MakeKVChecksum(uint64(len(row.values)), 1, 0)
 	checksum.Add(&cs)
 }
 
@@ -117,12 +147,12 @@ func (rows tidbRows) SplitIntoChunks(splitSize int) []kv.Rows {
 	cumSize := 0
 
 	for j, row := range rows {
-		if i < j && cumSize+len(row) > splitSize {
+		if i < j && cumSize+len(row.values) > splitSize {
 			res = append(res, rows[i:j])
 			i = j
 			cumSize = 0
 		}
-		cumSize += len(row)
+		cumSize += len(row.values)
 	}
 
 	return append(res, rows[i:])
@@ -280,7 +310,8 @@ func (enc *tidbEncoder) Encode(logger log.Logger, row []types.Datum, _ int64, co
 
 	// TODO: since the column count doesn't exactly reflect the real column names, we only check the upper bound currently.
 	// See: tests/generated_columns/data/gencol.various_types.0.sql this sql has no columns, so encodeLoop will fill the
-	// column permutation with default, thus enc.columnCnt > len(row).
+	// column permutation with default, thus enc.columnCnt > len(row). The missing fields are filled with DEFAULT when
+	// the row is written, where the columns of the insert statement are known.
 	if len(row) > enc.columnCnt {
 		logger.Error("column count mismatch", zap.Ints("column_permutation", columnPermutation),
 			zap.Array("data", kv.RowArrayMarshaler(row)))
@@ -294,8 +325,14 @@ func (enc *tidbEncoder) Encode(logger log.Logger, row []types.Datum, _ int64, co
 		if i != 0 {
 			encoded.WriteByte(',')
 		}
+		col := getColumnByIndex(cols, enc.columnIdx[i])
+		// the values of generated columns are computed by TiDB.
+		if col.IsGenerated() {
+			encoded.WriteString("DEFAULT")
+			continue
+		}
 		datum := field
-		if err := enc.appendSQL(&encoded, &datum, getColumnByIndex(cols, enc.columnIdx[i])); err != nil {
+		if err := enc.appendSQL(&encoded, &datum, col); err != nil {
 			logger.Error("tidb encode failed",
 				zap.Array("original", kv.RowArrayMarshaler(row)),
 				zap.Int("originalCol", i),
@@ -305,7 +342,7 @@ func (enc *tidbEncoder) Encode(logger log.Logger, row []types.Datum, _ int64, co
 		}
 	}
 	encoded.WriteByte(')')
-	return tidbRow(encoded.String()), nil
+	return tidbRow{values: encoded.String(), fields: len(row), columns: enc.columnCnt}, nil
 }
 
 func (be *tidbBackend) Close() {
@@ -313,12 +350,17 @@ func (be *tidbBackend) Close() {
 	// TidbManager, so we let the manager to close it.
 }
 
+func (be *tidbBackend) CloseWithContext(context.Context) error {
+	be.Close()
+	return nil
+}
+
 func (be *tidbBackend) MakeEmptyRows() kv.Rows {
 	return tidbRows(nil)
 }
 
 func (be *tidbBackend) RetryImportDelay() time.Duration {
-	return 0
+	return be.cfg.RetryImportDelay
 }
 
 func (be *tidbBackend) MaxChunkSize() int {
@@ -375,18 +417,22 @@ func (be *tidbBackend) WriteRows(ctx context.Context, _ uuid.UUID, tableName str
 	var err error
 outside:
 	for _, r := range rows.SplitIntoChunks(be.MaxChunkSize()) {
-		for i := 0; i < writeRowsMaxRetryTimes; i++ {
-			err = be.WriteRowsToDB(ctx, tableName, columnNames, r)
+		for i := 0; i < be.cfg.WriteRowsMaxRetryTimes; i++ {
+			writeCtx, cancel := be.cfg.WithWriteTimeout(ctx)
+			err = be.WriteRowsToDB(writeCtx, tableName, columnNames, r)
+			// a write exceeding the write timeout is retried like other retryable errors.
+			timedOut := ctx.Err() == nil && writeCtx.Err() == context.DeadlineExceeded
+			cancel()
 			switch {
 			case err == nil:
 				continue outside
-			case common.IsRetryableError(err):
+			case timedOut, common.IsRetryableError(err):
 				// retry next loop
 			default:
 				return err
 			}
 		}
-		return errors.Annotatef(err, "[%s] write rows reach max retry %d and still failed", tableName, writeRowsMaxRetryTimes)
+		return errors.Annotatef(err, "[%s] write rows reach max retry %d and still failed", tableName, be.cfg.WriteRowsMaxRetryTimes)
 	}
 	return nil
 }
@@ -427,7 +473,12 @@ func (be *tidbBackend) WriteRowsToDB(ctx context.Context, tableName string, colu
 		if i != 0 {
 			insertStmt.WriteByte(',')
 		}
-		insertStmt.WriteString(string(row))
+		// the missing trailing fields take the default values of the columns.
+		columnCount := len(columnNames)
+		if columnCount == 0 {
+			columnCount = row.columns
+		}
+		insertStmt.WriteString(row.valuesOf(columnCount))
 	}
 
 	// Retry will be done externally, so we're not going to retry here.
@@ -551,6 +602,10 @@ func (be *tidbBackend) EngineFileSizes() []backend.EngineFileSize {
 	return nil
 }
 
+func (be *tidbBackend) EngineMetadata(context.Context, uuid.UUID) (*backend.EngineMetadata, error) {
+	return nil, nil
+}
+
 func (be *tidbBackend) FlushEngine(context.Context, uuid.UUID) error {
 	return nil
 }
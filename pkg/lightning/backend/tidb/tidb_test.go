@@ -69,7 +69,7 @@ func (s *mysqlSuite) SetUpTest(c *C) {
 
 	s.dbHandle = db
 	s.mockDB = mock
-	s.backend = tidb.NewTiDBBackend(db, config.ReplaceOnDup)
+	s.backend = tidb.NewTiDBBackend(db, config.ReplaceOnDup, backend.DefaultBackendConfig())
 	s.tbl = tbl
 }
 
@@ -139,7 +139,7 @@ func (s *mysqlSuite) TestWriteRowsIgnoreOnDup(c *C) {
 	ctx := context.Background()
 	logger := log.L()
 
-	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, config.IgnoreOnDup)
+	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, config.IgnoreOnDup, backend.DefaultBackendConfig())
 	engine, err := ignoreBackend.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
 	c.Assert(err, IsNil)
 
@@ -183,7 +183,7 @@ func (s *mysqlSuite) TestWriteRowsErrorOnDup(c *C) {
 	ctx := context.Background()
 	logger := log.L()
 
-	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup, backend.DefaultBackendConfig())
 	engine, err := ignoreBackend.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
 	c.Assert(err, IsNil)
 
@@ -210,6 +210,93 @@ func (s *mysqlSuite) TestWriteRowsErrorOnDup(c *C) {
 	c.Assert(st, IsNil)
 }
 
+func (s *mysqlSuite) TestWriteRowsZeroRetryTimes(c *C) {
+	// the rows are still written once if the retry times are not set.
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1)\\E").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	ctx := context.Background()
+	logger := log.L()
+
+	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup, backend.BackendConfig{})
+	engine, err := bk.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
+	c.Assert(err, IsNil)
+
+	dataRows := bk.MakeEmptyRows()
+	dataChecksum := verification.MakeKVChecksum(0, 0, 0)
+	indexRows := bk.MakeEmptyRows()
+	indexChecksum := verification.MakeKVChecksum(0, 0, 0)
+
+	encoder, err := bk.NewEncoder(s.tbl, &kv.SessionOptions{})
+	c.Assert(err, IsNil)
+	row, err := encoder.Encode(logger, []types.Datum{
+		types.NewIntDatum(1),
+	}, 1, []int{0, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1}, 0)
+	c.Assert(err, IsNil)
+	row.ClassifyAndAppend(&dataRows, &dataChecksum, &indexRows, &indexChecksum)
+
+	writer, err := engine.LocalWriter(ctx, nil)
+	c.Assert(err, IsNil)
+	err = writer.WriteRows(ctx, []string{"a"}, dataRows)
+	c.Assert(err, IsNil)
+	_, err = writer.Close(ctx)
+	c.Assert(err, IsNil)
+}
+
+func (s *mysqlSuite) TestEncodeGeneratedAndMissingColumns(c *C) {
+	c0 := &model.ColumnInfo{ID: 1, Name: model.NewCIStr("c0"), State: model.StatePublic, Offset: 0, FieldType: *types.NewFieldType(mysql.TypeLong)}
+	c1 := &model.ColumnInfo{ID: 2, Name: model.NewCIStr("c1"), State: model.StatePublic, Offset: 1, FieldType: *types.NewFieldType(mysql.TypeLong),
+		DefaultValue: "5"}
+	c2 := &model.ColumnInfo{ID: 3, Name: model.NewCIStr("c2"), State: model.StatePublic, Offset: 2, FieldType: *types.NewFieldType(mysql.TypeLong),
+		GeneratedExprString: "`c0` + 1", GeneratedStored: true}
+	tblInfo := &model.TableInfo{ID: 1, Columns: []*model.ColumnInfo{c0, c1, c2}, PKIsHandle: false, State: model.StatePublic}
+	tbl, err := tables.TableFromMeta(kv.NewPanickingAllocators(0), tblInfo)
+	c.Assert(err, IsNil)
+
+	encoder, err := s.backend.NewEncoder(tbl, &kv.SessionOptions{})
+	c.Assert(err, IsNil)
+	logger := log.L()
+	// the fields are (c0, c2, c1), the value of the generated column c2 is ignored and c1 is missing.
+	row, err := encoder.Encode(logger, []types.Datum{
+		types.NewIntDatum(1),
+		types.NewIntDatum(100),
+	}, 1, []int{0, 2, 1, -1}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(fmt.Sprint(row), Equals, "(1,DEFAULT,DEFAULT)")
+
+	row, err = encoder.Encode(logger, []types.Datum{
+		types.NewIntDatum(1),
+		types.NewIntDatum(100),
+		types.NewIntDatum(3),
+	}, 1, []int{0, 2, 1, -1}, 0)
+	c.Assert(err, IsNil)
+	c.Assert(fmt.Sprint(row), Equals, "(1,DEFAULT,3)")
+
+	// the missing trailing fields are filled when the row is written with the
+	// columns of the data file.
+	s.mockDB.
+		ExpectExec("\\QREPLACE INTO `foo`.`bar`(`c0`,`c2`,`c1`) VALUES(1,DEFAULT,DEFAULT),(1,DEFAULT,3)\\E").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	short, err := encoder.Encode(logger, []types.Datum{
+		types.NewIntDatum(1),
+		types.NewIntDatum(100),
+	}, 1, []int{0, 2, 1, -1}, 0)
+	c.Assert(err, IsNil)
+	rows := s.backend.MakeEmptyRows()
+	checksum := verification.MakeKVChecksum(0, 0, 0)
+	short.ClassifyAndAppend(&rows, &checksum, nil, nil)
+	row.ClassifyAndAppend(&rows, &checksum, nil, nil)
+	ctx := context.Background()
+	engine, err := s.backend.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
+	c.Assert(err, IsNil)
+	writer, err := engine.LocalWriter(ctx, nil)
+	c.Assert(err, IsNil)
+	c.Assert(writer.WriteRows(ctx, []string{"c0", "c2", "c1"}, rows), IsNil)
+	_, err = writer.Close(ctx)
+	c.Assert(err, IsNil)
+}
+
 // TODO: temporarily disable this test before we fix strict mode
 //nolint:unused
 func (s *mysqlSuite) testStrictMode(c *C) {
@@ -223,7 +310,7 @@ func (s *mysqlSuite) testStrictMode(c *C) {
 	tbl, err := tables.TableFromMeta(kv.NewPanickingAllocators(0), tblInfo)
 	c.Assert(err, IsNil)
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup, backend.DefaultBackendConfig())
 	encoder, err := bk.NewEncoder(tbl, &kv.SessionOptions{SQLMode: mysql.ModeStrictAllTables})
 	c.Assert(err, IsNil)
 
@@ -258,7 +345,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_3_x(c *C) {
 			AddRow("t", "id", "int(10)", "auto_increment"))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup, backend.DefaultBackendConfig())
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
@@ -293,7 +380,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_4_0(c *C) {
 			AddRow("test", "t", "id", int64(1)))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup, backend.DefaultBackendConfig())
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
@@ -328,7 +415,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_4_x_auto_increment(c *C) {
 			AddRow("test", "t", "id", int64(1), "AUTO_INCREMENT"))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup, backend.DefaultBackendConfig())
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
@@ -363,7 +450,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_4_x_auto_random(c *C) {
 			AddRow("test", "t", "id", int64(1), "AUTO_RANDOM"))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup, backend.DefaultBackendConfig())
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
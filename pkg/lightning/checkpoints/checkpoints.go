@@ -63,7 +63,7 @@ const (
 	CheckpointTableNameTask   = "task_v2"
 	CheckpointTableNameTable  = "table_v7"
 	CheckpointTableNameEngine = "engine_v5"
-	CheckpointTableNameChunk  = "chunk_v5"
+	CheckpointTableNameChunk  = "chunk_v7"
 
 	// Some frequently used table name or constants.
 	allTables       = "all"
@@ -130,6 +130,8 @@ const (
 			kvc_bytes bigint unsigned NOT NULL DEFAULT 0,
 			kvc_kvs bigint unsigned NOT NULL DEFAULT 0,
 			kvc_checksum bigint unsigned NOT NULL DEFAULT 0,
+			error_rows bigint NOT NULL DEFAULT 0,
+			failure varchar(1024) NOT NULL DEFAULT '',
 			create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			PRIMARY KEY(table_name, engine_id, path(500), offset)
@@ -151,7 +153,7 @@ const (
 		SELECT
 			engine_id, path, offset, type, compression, sort_key, file_size, columns,
 			pos, end_offset, prev_rowid_max, rowid_max,
-			kvc_bytes, kvc_kvs, kvc_checksum, unix_timestamp(create_time)
+			kvc_bytes, kvc_kvs, kvc_checksum, error_rows, failure, unix_timestamp(create_time)
 		FROM %s.%s WHERE table_name = ?
 		ORDER BY engine_id, path, offset;`
 	ReadTableRemainTemplate = `
@@ -171,7 +173,7 @@ const (
 				0, 0, 0, from_unixtime(?)
 			);`
 	UpdateChunkTemplate = `
-		UPDATE %s.%s SET pos = ?, prev_rowid_max = ?, kvc_bytes = ?, kvc_kvs = ?, kvc_checksum = ?, columns = ?
+		UPDATE %s.%s SET pos = ?, prev_rowid_max = ?, kvc_bytes = ?, kvc_kvs = ?, kvc_checksum = ?, columns = ?, error_rows = ?, failure = ?
 		WHERE (table_name, engine_id, path, offset) = (?, ?, ?, ?);`
 	UpdateTableRebaseTemplate = `
 		UPDATE %s.%s SET alloc_base = GREATEST(?, alloc_base) WHERE table_name = ?;`
@@ -244,6 +246,12 @@ type ChunkCheckpoint struct {
 	Chunk             mydump.Chunk
 	Checksum          verify.KVChecksum
 	Timestamp         int64
+	// ErrorRows is the number of rows in the chunk which failed to be encoded
+	// and were written into the quarantine file instead.
+	ErrorRows int64
+	// Failure is the error of the chunk if it failed repeatedly and has been
+	// quarantined, the chunk is skipped until it is marked dirty again.
+	Failure string
 }
 
 func (ccp *ChunkCheckpoint) DeepCopy() *ChunkCheckpoint {
@@ -256,6 +264,8 @@ func (ccp *ChunkCheckpoint) DeepCopy() *ChunkCheckpoint {
 		Chunk:             ccp.Chunk,
 		Checksum:          ccp.Checksum,
 		Timestamp:         ccp.Timestamp,
+		ErrorRows:         ccp.ErrorRows,
+		Failure:           ccp.Failure,
 	}
 }
 
@@ -306,11 +316,25 @@ func (cp *TableCheckpoint) CountChunks() int {
 	return result
 }
 
+// CountErrorRows returns the total number of rows of the table which have been
+// quarantined.
+func (cp *TableCheckpoint) CountErrorRows() int64 {
+	var result int64
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			result += chunk.ErrorRows
+		}
+	}
+	return result
+}
+
 type chunkCheckpointDiff struct {
 	pos               int64
 	rowID             int64
 	checksum          verify.KVChecksum
 	columnPermutation []int
+	errorRows         int64
+	failure           string
 }
 
 type engineCheckpointDiff struct {
@@ -387,6 +411,8 @@ func (cp *TableCheckpoint) Apply(cpd *TableCheckpointDiff) {
 			chunk.Chunk.Offset = diff.pos
 			chunk.Chunk.PrevRowIDMax = diff.rowID
 			chunk.Checksum = diff.checksum
+			chunk.ErrorRows = diff.errorRows
+			chunk.Failure = diff.failure
 		}
 	}
 }
@@ -430,6 +456,8 @@ type ChunkCheckpointMerger struct {
 	Pos               int64
 	RowID             int64
 	ColumnPermutation []int
+	ErrorRows         int64
+	Failure           string
 }
 
 func (merger *ChunkCheckpointMerger) MergeInto(cpd *TableCheckpointDiff) {
@@ -440,6 +468,8 @@ func (merger *ChunkCheckpointMerger) MergeInto(cpd *TableCheckpointDiff) {
 				rowID:             merger.RowID,
 				checksum:          merger.Checksum,
 				columnPermutation: merger.ColumnPermutation,
+				errorRows:         merger.ErrorRows,
+				failure:           merger.Failure,
 			},
 		},
 	})
@@ -772,7 +802,7 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 				&engineID, &value.Key.Path, &value.Key.Offset, &value.FileMeta.Type, &value.FileMeta.Compression,
 				&value.FileMeta.SortKey, &value.FileMeta.FileSize, &colPerm, &value.Chunk.Offset, &value.Chunk.EndOffset,
 				&value.Chunk.PrevRowIDMax, &value.Chunk.RowIDMax, &kvcBytes, &kvcKVs, &kvcChecksum,
-				&value.Timestamp,
+				&value.ErrorRows, &value.Failure, &value.Timestamp,
 			); err != nil {
 				return errors.Trace(err)
 			}
@@ -860,6 +890,13 @@ func (cpdb *MySQLCheckpointsDB) InsertEngineCheckpoints(ctx context.Context, tab
 }
 
 func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDiff) {
+	if err := cpdb.ApplyDiffs(context.Background(), checkpointDiffs); err != nil {
+		log.L().Error("save checkpoint failed", zap.Error(err))
+	}
+}
+
+// ApplyDiffs is like Update, but returns the error instead of logging it.
+func (cpdb *MySQLCheckpointsDB) ApplyDiffs(ctx context.Context, checkpointDiffs map[string]*TableCheckpointDiff) error {
 	chunkQuery := fmt.Sprintf(UpdateChunkTemplate, cpdb.schema, CheckpointTableNameChunk)
 	rebaseQuery := fmt.Sprintf(UpdateTableRebaseTemplate, cpdb.schema, CheckpointTableNameTable)
 	tableStatusQuery := fmt.Sprintf(UpdateTableStatusTemplate, cpdb.schema, CheckpointTableNameTable)
@@ -867,7 +904,7 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 	engineStatusQuery := fmt.Sprintf(UpdateEngineTemplate, cpdb.schema, CheckpointTableNameEngine)
 
 	s := common.SQLWithRetry{DB: cpdb.db, Logger: log.L()}
-	err := s.Transact(context.Background(), "update checkpoints", func(c context.Context, tx *sql.Tx) error {
+	return s.Transact(ctx, "update checkpoints", func(c context.Context, tx *sql.Tx) error {
 		chunkStmt, e := tx.PrepareContext(c, chunkQuery)
 		if e != nil {
 			return errors.Trace(e)
@@ -923,7 +960,7 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 					if _, e := chunkStmt.ExecContext(
 						c,
 						diff.pos, diff.rowID, diff.checksum.SumSize(), diff.checksum.SumKVS(), diff.checksum.Sum(),
-						columnPerm, tableName, engineID, key.Path, key.Offset,
+						columnPerm, diff.errorRows, diff.failure, tableName, engineID, key.Path, key.Offset,
 					); e != nil {
 						return errors.Trace(e)
 					}
@@ -933,9 +970,6 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 
 		return nil
 	})
-	if err != nil {
-		log.L().Error("save checkpoint failed", zap.Error(err))
-	}
 }
 
 type FileCheckpointsDB struct {
@@ -1113,6 +1147,8 @@ func (cpdb *FileCheckpointsDB) Get(_ context.Context, tableName string) (*TableC
 				},
 				Checksum:  verify.MakeKVChecksum(chunkModel.KvcBytes, chunkModel.KvcKvs, chunkModel.KvcChecksum),
 				Timestamp: chunkModel.Timestamp,
+				ErrorRows: chunkModel.ErrorRows,
+				Failure:   chunkModel.Failure,
 			})
 		}
 
@@ -1166,6 +1202,13 @@ func (cpdb *FileCheckpointsDB) InsertEngineCheckpoints(_ context.Context, tableN
 }
 
 func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDiff) {
+	if err := cpdb.ApplyDiffs(context.Background(), checkpointDiffs); err != nil {
+		log.L().Error("save checkpoint failed", zap.Error(err))
+	}
+}
+
+// ApplyDiffs is like Update, but returns the error instead of logging it.
+func (cpdb *FileCheckpointsDB) ApplyDiffs(_ context.Context, checkpointDiffs map[string]*TableCheckpointDiff) error {
 	cpdb.lock.Lock()
 	defer cpdb.lock.Unlock()
 
@@ -1196,13 +1239,13 @@ func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoin
 				chunkModel.KvcKvs = diff.checksum.SumKVS()
 				chunkModel.KvcChecksum = diff.checksum.Sum()
 				chunkModel.ColumnPermutation = intSlice2Int32Slice(diff.columnPermutation)
+				chunkModel.ErrorRows = diff.errorRows
+				chunkModel.Failure = diff.failure
 			}
 		}
 	}
 
-	if err := cpdb.save(); err != nil {
-		log.L().Error("save checkpoint failed", zap.Error(err))
-	}
+	return errors.Trace(cpdb.save())
 }
 
 // Management functions ----------------------------------------------------------------------------
@@ -1513,6 +1556,8 @@ func (cpdb *MySQLCheckpointsDB) DumpChunks(ctx context.Context, writer io.Writer
 			kvc_bytes,
 			kvc_kvs,
 			kvc_checksum,
+			error_rows,
+			failure,
 			create_time,
 			update_time
 		FROM %s.%s;
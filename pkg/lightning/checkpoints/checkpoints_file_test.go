@@ -123,11 +123,12 @@ func (s *cpFileSuite) SetUpTest(c *C) {
 	}
 	cksum.MergeInto(cpd)
 	ccm := checkpoints.ChunkCheckpointMerger{
-		EngineID: 0,
-		Key:      checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
-		Checksum: verification.MakeKVChecksum(4491, 586, 486070148917),
-		Pos:      55904,
-		RowID:    681,
+		EngineID:  0,
+		Key:       checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
+		Checksum:  verification.MakeKVChecksum(4491, 586, 486070148917),
+		Pos:       55904,
+		RowID:     681,
+		ErrorRows: 3,
 	}
 	ccm.MergeInto(cpd)
 
@@ -188,7 +189,8 @@ func (s *cpFileSuite) TestGet(c *C) {
 						PrevRowIDMax: 681,
 						RowIDMax:     5000,
 					},
-					Checksum: verification.MakeKVChecksum(4491, 586, 486070148917),
+					Checksum:  verification.MakeKVChecksum(4491, 586, 486070148917),
+					ErrorRows: 3,
 				}},
 			},
 		},
@@ -328,3 +330,105 @@ func (s *cpFileSuite) TestDestroyOneErrorCheckpoint(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusAllWritten/10)
 }
+
+func (s *cpFileSuite) TestMarkChunk(c *C) {
+	ctx := context.Background()
+	key := checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0}
+
+	err := checkpoints.MarkChunk(ctx, s.cpdb, "`db1`.`t2`", 0, key, true)
+	c.Assert(err, IsNil)
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	chunk := cp.Engines[0].Chunks[0]
+	c.Assert(chunk.Chunk.Offset, Equals, int64(102400))
+	c.Assert(chunk.Chunk.PrevRowIDMax, Equals, int64(5000))
+	c.Assert(chunk.ErrorRows, Equals, int64(3))
+
+	err = checkpoints.MarkChunk(ctx, s.cpdb, "`db1`.`t2`", 0, key, false)
+	c.Assert(err, IsNil)
+	cp, err = s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusLoaded)
+	c.Assert(cp.Engines[0].Status, Equals, checkpoints.CheckpointStatusLoaded)
+	chunk = cp.Engines[0].Chunks[0]
+	c.Assert(chunk.Chunk.Offset, Equals, int64(0))
+	c.Assert(chunk.Chunk.PrevRowIDMax, Equals, int64(0))
+	c.Assert(chunk.Checksum.SumKVS(), Equals, uint64(0))
+	c.Assert(chunk.ErrorRows, Equals, int64(0))
+
+	err = checkpoints.MarkEngine(ctx, s.cpdb, "`db1`.`t2`", 0, true)
+	c.Assert(err, IsNil)
+	cp, err = s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Engines[0].Status, Equals, checkpoints.CheckpointStatusImported)
+	c.Assert(cp.Engines[0].Chunks[0].Chunk.Offset, Equals, int64(102400))
+
+	err = checkpoints.MarkChunk(ctx, s.cpdb, "`db1`.`t2`", 0, checkpoints.ChunkCheckpointKey{Path: "/tmp/path/2.sql"}, true)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+	err = checkpoints.MarkEngine(ctx, s.cpdb, "`db1`.`t2`", 1, true)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+}
+
+func (s *cpFileSuite) TestChunkFailure(c *C) {
+	ctx := context.Background()
+	key := checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0}
+
+	cpd := checkpoints.NewTableCheckpointDiff()
+	ccm := checkpoints.ChunkCheckpointMerger{
+		EngineID: 0,
+		Key:      key,
+		Pos:      55904,
+		RowID:    681,
+		Failure:  "broken file",
+	}
+	ccm.MergeInto(cpd)
+	s.cpdb.Update(map[string]*checkpoints.TableCheckpointDiff{"`db1`.`t2`": cpd})
+
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Engines[0].Chunks[0].Failure, Equals, "broken file")
+
+	// marking the chunk clears the failure.
+	err = checkpoints.MarkChunk(ctx, s.cpdb, "`db1`.`t2`", 0, key, false)
+	c.Assert(err, IsNil)
+	cp, err = s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Engines[0].Chunks[0].Failure, Equals, "")
+}
+
+func (s *cpFileSuite) TestRemoveChunk(c *C) {
+	ctx := context.Background()
+	key := checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0}
+
+	err := s.cpdb.RemoveChunk(ctx, "`db1`.`t2`", 0, key)
+	c.Assert(err, IsNil)
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Engines[0].Chunks, HasLen, 0)
+
+	err = s.cpdb.RemoveChunk(ctx, "`db1`.`t2`", 0, key)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+}
+
+func (s *cpFileSuite) TestRewritePaths(c *C) {
+	ctx := context.Background()
+
+	rewritten, err := s.cpdb.RewritePaths(ctx, "/tmp/path/", "/mnt/data/")
+	c.Assert(err, IsNil)
+	c.Assert(rewritten, Equals, int64(1))
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Engines[0].Chunks[0].Key, Equals, checkpoints.ChunkCheckpointKey{Path: "/mnt/data/1.sql", Offset: 0})
+	c.Assert(cp.Engines[0].Chunks[0].Chunk.Offset, Equals, int64(55904))
+
+	rewritten, err = s.cpdb.RewritePaths(ctx, "/data", "/mnt/source")
+	c.Assert(err, IsNil)
+	c.Assert(rewritten, Equals, int64(0))
+	task, err := s.cpdb.TaskCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(task.SourceDir, Equals, "/mnt/source")
+
+	tables, err := s.cpdb.ListTables(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(tables, DeepEquals, []string{"`db1`.`t1`", "`db1`.`t2`", "`db2`.`t3`"})
+}
@@ -180,11 +180,12 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 	}
 	cksum.MergeInto(cpd)
 	ccm := checkpoints.ChunkCheckpointMerger{
-		EngineID: 0,
-		Key:      checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
-		Checksum: verification.MakeKVChecksum(4491, 586, 486070148917),
-		Pos:      55904,
-		RowID:    681,
+		EngineID:  0,
+		Key:       checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
+		Checksum:  verification.MakeKVChecksum(4491, 586, 486070148917),
+		Pos:       55904,
+		RowID:     681,
+		ErrorRows: 3,
 	}
 	ccm.MergeInto(cpd)
 
@@ -193,7 +194,7 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 		ExpectPrepare("UPDATE `mock-schema`\\.chunk_v\\d+ SET pos = .+").
 		ExpectExec().
 		WithArgs(
-			55904, 681, 4491, 586, 486070148917, []byte("null"),
+			55904, 681, 4491, 586, 486070148917, []byte("null"), 3, "",
 			"`db1`.`t2`", 0, "/tmp/path/1.sql", 0,
 		).
 		WillReturnResult(sqlmock.NewResult(11, 1))
@@ -243,12 +244,12 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 			sqlmock.NewRows([]string{
 				"engine_id", "path", "offset", "type", "compression", "sort_key", "file_size", "columns",
 				"pos", "end_offset", "prev_rowid_max", "rowid_max",
-				"kvc_bytes", "kvc_kvs", "kvc_checksum", "unix_timestamp(create_time)",
+				"kvc_bytes", "kvc_kvs", "kvc_checksum", "error_rows", "failure", "unix_timestamp(create_time)",
 			}).
 				AddRow(
 					0, "/tmp/path/1.sql", 0, mydump.SourceTypeSQL, 0, "", 123, "[]",
 					55904, 102400, 681, 5000,
-					4491, 586, 486070148917, 1234567894,
+					4491, 586, 486070148917, 3, "", 1234567894,
 				),
 		)
 	s.mock.
@@ -289,6 +290,7 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 					},
 					Checksum:  verification.MakeKVChecksum(4491, 586, 486070148917),
 					Timestamp: 1234567894,
+					ErrorRows: 3,
 				}},
 			},
 		},
@@ -317,7 +319,7 @@ func (s *cpSQLSuite) TestRemoveAllCheckpoints(c *C) {
 			sqlmock.NewRows([]string{
 				"engine_id", "path", "offset", "type", "compression", "sort_key", "file_size", "columns",
 				"pos", "end_offset", "prev_rowid_max", "rowid_max",
-				"kvc_bytes", "kvc_kvs", "kvc_checksum", "unix_timestamp(create_time)",
+				"kvc_bytes", "kvc_kvs", "kvc_checksum", "error_rows", "failure", "unix_timestamp(create_time)",
 			}))
 	s.mock.
 		ExpectQuery("SELECT .+ FROM `mock-schema`\\.table_v\\d+").
@@ -456,12 +458,12 @@ func (s *cpSQLSuite) TestDump(c *C) {
 			sqlmock.NewRows([]string{
 				"table_name", "path", "offset", "type", "compression", "sort_key", "file_size", "columns",
 				"pos", "end_offset", "prev_rowid_max", "rowid_max",
-				"kvc_bytes", "kvc_kvs", "kvc_checksum",
+				"kvc_bytes", "kvc_kvs", "kvc_checksum", "error_rows", "failure",
 				"create_time", "update_time",
 			}).AddRow(
 				"`db1`.`t2`", "/tmp/path/1.sql", 0, mydump.SourceTypeSQL, mydump.CompressionNone, "", 456, "[]",
 				55904, 102400, 681, 5000,
-				4491, 586, 486070148917,
+				4491, 586, 486070148917, 3, "",
 				t, t,
 			),
 		)
@@ -470,8 +472,8 @@ func (s *cpSQLSuite) TestDump(c *C) {
 	err := s.cpdb.DumpChunks(ctx, &csvBuilder)
 	c.Assert(err, IsNil)
 	c.Assert(csvBuilder.String(), Equals,
-		"table_name,path,offset,type,compression,sort_key,file_size,columns,pos,end_offset,prev_rowid_max,rowid_max,kvc_bytes,kvc_kvs,kvc_checksum,create_time,update_time\n"+
-			"`db1`.`t2`,/tmp/path/1.sql,0,3,0,,456,[],55904,102400,681,5000,4491,586,486070148917,2019-04-18 02:45:55 +0000 UTC,2019-04-18 02:45:55 +0000 UTC\n",
+		"table_name,path,offset,type,compression,sort_key,file_size,columns,pos,end_offset,prev_rowid_max,rowid_max,kvc_bytes,kvc_kvs,kvc_checksum,error_rows,failure,create_time,update_time\n"+
+			"`db1`.`t2`,/tmp/path/1.sql,0,3,0,,456,[],55904,102400,681,5000,4491,586,486070148917,3,,2019-04-18 02:45:55 +0000 UTC,2019-04-18 02:45:55 +0000 UTC\n",
 	)
 
 	s.mock.
@@ -230,11 +230,12 @@ func (s *checkpointSuite) TestApplyDiff(c *C) {
 	(&StatusCheckpointMerger{EngineID: 1234, Status: CheckpointStatusAnalyzeSkipped}).MergeInto(cpd)
 	(&RebaseCheckpointMerger{AllocBase: 11111}).MergeInto(cpd)
 	(&ChunkCheckpointMerger{
-		EngineID: 0,
-		Key:      ChunkCheckpointKey{Path: "/tmp/01.sql"},
-		Checksum: verification.MakeKVChecksum(3333, 4444, 5555),
-		Pos:      6666,
-		RowID:    777,
+		EngineID:  0,
+		Key:       ChunkCheckpointKey{Path: "/tmp/01.sql"},
+		Checksum:  verification.MakeKVChecksum(3333, 4444, 5555),
+		Pos:       6666,
+		RowID:     777,
+		ErrorRows: 12,
 	}).MergeInto(cpd)
 	(&ChunkCheckpointMerger{
 		EngineID: 5678,
@@ -275,7 +276,8 @@ func (s *checkpointSuite) TestApplyDiff(c *C) {
 							PrevRowIDMax: 777,
 							RowIDMax:     1000,
 						},
-						Checksum: verification.MakeKVChecksum(3333, 4444, 5555),
+						Checksum:  verification.MakeKVChecksum(3333, 4444, 5555),
+						ErrorRows: 12,
 					},
 					{
 						Key: ChunkCheckpointKey{Path: "/tmp/04.sql"},
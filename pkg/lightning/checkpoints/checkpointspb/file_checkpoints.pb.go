@@ -207,6 +207,8 @@ type ChunkCheckpointModel struct {
 	Compression       int32   `protobuf:"varint,15,opt,name=compression,proto3" json:"compression,omitempty"`
 	SortKey           string  `protobuf:"bytes,16,opt,name=sort_key,json=sortKey,proto3" json:"sort_key,omitempty"`
 	FileSize          int64   `protobuf:"varint,17,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	ErrorRows         int64   `protobuf:"varint,18,opt,name=error_rows,json=errorRows,proto3" json:"error_rows,omitempty"`
+	Failure           string  `protobuf:"bytes,19,opt,name=failure,proto3" json:"failure,omitempty"`
 }
 
 func (m *ChunkCheckpointModel) Reset()         { *m = ChunkCheckpointModel{} }
@@ -258,62 +260,62 @@ func init() {
 }
 
 var fileDescriptor_c57c7b77a714394c = []byte{
-	// 870 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xcd, 0x6e, 0xdb, 0x46,
-	0x10, 0x36, 0x45, 0x8b, 0x92, 0x96, 0x92, 0x23, 0x6f, 0xed, 0x64, 0xeb, 0xb6, 0x2a, 0xab, 0xf4,
-	0x20, 0x20, 0x8d, 0x04, 0xa4, 0x97, 0x22, 0x68, 0x0b, 0xd4, 0x76, 0x80, 0x06, 0x46, 0x50, 0x63,
-	0x9b, 0xf6, 0xd0, 0x0b, 0xc1, 0x9f, 0xb5, 0x44, 0xac, 0xc8, 0x25, 0xb8, 0x4b, 0x36, 0xca, 0x53,
-	0xf4, 0x31, 0xfa, 0x12, 0xbd, 0x07, 0x3d, 0xe5, 0xd8, 0x63, 0x6a, 0xdf, 0xfb, 0x0c, 0xc5, 0xce,
-	0x52, 0x16, 0x15, 0x08, 0x46, 0x6e, 0x33, 0xdf, 0x7c, 0x3b, 0x3b, 0xfb, 0xe9, 0x1b, 0x11, 0x7d,
-	0x9f, 0xf3, 0xf9, 0x6c, 0x99, 0xcc, 0x17, 0x2a, 0x4b, 0xb2, 0xf9, 0x2c, 0x5a, 0xb0, 0x88, 0xe7,
-	0x22, 0xc9, 0x94, 0x6c, 0xc6, 0x79, 0x38, 0xbb, 0x4a, 0x96, 0xcc, 0x6f, 0x40, 0xd3, 0xbc, 0x10,
-	0x4a, 0x9c, 0x3c, 0x9e, 0x27, 0x6a, 0x51, 0x86, 0xd3, 0x48, 0xa4, 0xb3, 0xb9, 0x98, 0x8b, 0x19,
-	0xc0, 0x61, 0x79, 0x05, 0x19, 0x24, 0x10, 0x19, 0xfa, 0xf8, 0x3f, 0x0b, 0x0d, 0xcf, 0x36, 0x4d,
-	0x5e, 0x88, 0x98, 0x2d, 0xf1, 0x39, 0x72, 0x1b, 0x8d, 0x89, 0xe5, 0xd9, 0x13, 0xf7, 0xc9, 0x78,
-	0xfa, 0x3e, 0xaf, 0x09, 0x3c, 0xcb, 0x54, 0xb1, 0xa2, 0xcd, 0x63, 0xf8, 0x3b, 0x74, 0x4f, 0x05,
-	0x92, 0x37, 0x66, 0x24, 0x2d, 0xcf, 0x9a, 0xb8, 0x4f, 0x8e, 0xa6, 0x2f, 0x03, 0xc9, 0x37, 0x87,
-	0xa1, 0x19, 0x3d, 0x50, 0x5b, 0xe0, 0xc9, 0x2f, 0x5b, 0x83, 0x41, 0x7f, 0x3c, 0x44, 0x36, 0x67,
-	0x2b, 0x62, 0x79, 0xd6, 0xa4, 0x47, 0x75, 0x88, 0x1f, 0xa1, 0x76, 0x15, 0x2c, 0x4b, 0x56, 0xb7,
-	0x3e, 0x9e, 0xbe, 0x0c, 0xc2, 0x25, 0x7b, 0xbf, 0xb7, 0xe1, 0x3c, 0x6d, 0x7d, 0x63, 0x8d, 0xff,
-	0x6c, 0xa1, 0x8f, 0x76, 0x5c, 0x8f, 0x1f, 0xa0, 0x0e, 0x4c, 0x9b, 0xc4, 0xd0, 0xde, 0xa6, 0x8e,
-	0x4e, 0x9f, 0xc7, 0xf8, 0x33, 0x84, 0xa4, 0x28, 0x8b, 0x88, 0xf9, 0x71, 0x52, 0xc0, 0x35, 0x3d,
-	0xda, 0x33, 0xc8, 0x79, 0x52, 0x60, 0x82, 0x3a, 0x61, 0x10, 0x71, 0x96, 0xc5, 0xc4, 0x86, 0xda,
-	0x3a, 0xc5, 0x0f, 0xd1, 0x20, 0x49, 0x73, 0x51, 0x28, 0x56, 0xf8, 0x41, 0x1c, 0x17, 0x64, 0x1f,
-	0xea, 0xfd, 0x35, 0xf8, 0x43, 0x1c, 0x17, 0xf8, 0x13, 0xd4, 0x53, 0x49, 0x1c, 0xfa, 0x0b, 0x21,
-	0x15, 0x69, 0x03, 0xa1, 0xab, 0x81, 0x1f, 0x85, 0x54, 0xb7, 0x45, 0xcd, 0x27, 0x8e, 0x67, 0x4d,
-	0xda, 0xa6, 0x78, 0x29, 0x0a, 0xa5, 0x07, 0xce, 0x63, 0xd3, 0xb8, 0x03, 0xe7, 0x9c, 0x3c, 0x86,
-	0x96, 0x63, 0x34, 0x90, 0xfa, 0x82, 0xd8, 0xe7, 0x15, 0xcc, 0xdc, 0x85, 0xb2, 0x6b, 0xc0, 0x8b,
-	0x4a, 0x4f, 0xfd, 0x10, 0x0d, 0x6e, 0x3d, 0xe6, 0x57, 0xac, 0x20, 0x3d, 0x33, 0xdb, 0x2d, 0xf8,
-	0x2b, 0x2b, 0xc6, 0xef, 0x5a, 0xe8, 0x68, 0x97, 0x9c, 0x18, 0xa3, 0xfd, 0x45, 0x20, 0x17, 0x20,
-	0x54, 0x9f, 0x42, 0x8c, 0xef, 0x23, 0x47, 0xaa, 0x40, 0x95, 0x12, 0x64, 0x18, 0xd0, 0x3a, 0xd3,
-	0xf2, 0x05, 0xcb, 0xa5, 0x88, 0xfc, 0x30, 0x90, 0x0c, 0x24, 0xb0, 0x69, 0x0f, 0x90, 0xd3, 0x40,
-	0x32, 0xfc, 0x2d, 0xea, 0xb0, 0x6c, 0x9e, 0x64, 0x4c, 0x92, 0x6e, 0x6d, 0xb3, 0x5d, 0x57, 0x4e,
-	0x9f, 0x19, 0x92, 0xb1, 0xd9, 0xfa, 0x88, 0x16, 0x5f, 0x69, 0xf6, 0xf3, 0x73, 0x78, 0x80, 0x4d,
-	0xd7, 0x29, 0xfe, 0x18, 0x75, 0x79, 0xe5, 0x87, 0x2b, 0xc5, 0x24, 0x41, 0x9e, 0x35, 0xd9, 0xa7,
-	0x1d, 0x5e, 0x9d, 0xea, 0x14, 0x1f, 0x23, 0x87, 0x57, 0x3e, 0xaf, 0x24, 0x71, 0xa1, 0xd0, 0xe6,
-	0xd5, 0x45, 0x25, 0xf1, 0xe7, 0xc8, 0xe5, 0x95, 0x31, 0xab, 0x2c, 0x53, 0xd2, 0xf7, 0xac, 0x89,
-	0x43, 0x11, 0xaf, 0xce, 0x6a, 0xe4, 0x84, 0xa2, 0x7e, 0x73, 0x8a, 0xa6, 0x19, 0x0f, 0x8d, 0x19,
-	0xbf, 0xda, 0x36, 0xe3, 0xfd, 0x7a, 0xea, 0x3b, 0xdc, 0xf8, 0x97, 0x85, 0x8e, 0x77, 0x92, 0x1a,
-	0x7a, 0x5a, 0x5b, 0x7a, 0x3e, 0x45, 0x4e, 0xb4, 0x28, 0x33, 0x2e, 0x49, 0xab, 0xd6, 0x6b, 0xe7,
-	0xf9, 0xe9, 0x19, 0x90, 0x8c, 0x5e, 0xf5, 0x89, 0x93, 0x4b, 0xe4, 0x36, 0xe0, 0x0f, 0xd9, 0x26,
-	0xa0, 0xdf, 0x31, 0xff, 0xdf, 0x36, 0x3a, 0xda, 0xc5, 0xd1, 0x16, 0xc9, 0x03, 0xb5, 0xa8, 0x9b,
-	0x43, 0xac, 0x9f, 0x24, 0xae, 0xae, 0x24, 0x33, 0xff, 0x03, 0x36, 0xad, 0x33, 0xfc, 0x18, 0xe1,
-	0x48, 0x2c, 0xcb, 0x34, 0xf3, 0x73, 0x56, 0xa4, 0xa5, 0x0a, 0x54, 0x22, 0x32, 0xd2, 0xf7, 0xec,
-	0x49, 0x9b, 0x1e, 0x9a, 0xca, 0xe5, 0xa6, 0xa0, 0x1d, 0xc5, 0xb2, 0xd8, 0xaf, 0x5b, 0xb5, 0x8d,
-	0xa3, 0x58, 0x16, 0xff, 0x64, 0xba, 0x0d, 0x91, 0x9d, 0x0b, 0x09, 0xeb, 0x62, 0x53, 0x1d, 0xe2,
-	0x2f, 0xd1, 0x41, 0x5e, 0xb0, 0xca, 0x2f, 0xc4, 0xef, 0x49, 0xec, 0xa7, 0xc1, 0x2b, 0x58, 0x18,
-	0x9b, 0xf6, 0x35, 0x4a, 0x35, 0xf8, 0x22, 0x78, 0xa5, 0x97, 0x6d, 0x43, 0xe8, 0x02, 0xa1, 0x5b,
-	0x34, 0x8a, 0xbc, 0x8a, 0x6a, 0x3f, 0xf5, 0xc0, 0x36, 0x5d, 0x5e, 0x45, 0xc6, 0x50, 0x0f, 0x50,
-	0x47, 0x17, 0xb5, 0xa3, 0x8c, 0xd5, 0x1c, 0x5e, 0x45, 0xda, 0x52, 0x5f, 0xa0, 0xbe, 0x2e, 0xdc,
-	0x7a, 0xca, 0x05, 0x4f, 0xb9, 0xbc, 0x8a, 0xd6, 0xa6, 0xc2, 0x9f, 0xea, 0x15, 0x4f, 0x99, 0x54,
-	0x41, 0x9a, 0x93, 0x81, 0x67, 0x4d, 0x86, 0x74, 0x03, 0x68, 0x15, 0xd5, 0x2a, 0x67, 0xe4, 0x00,
-	0x76, 0x1f, 0x62, 0xec, 0x21, 0x37, 0x12, 0x69, 0x5e, 0x30, 0x29, 0xb5, 0x4c, 0xf7, 0xa0, 0xd4,
-	0x84, 0xb4, 0xf7, 0xf5, 0xae, 0xfb, 0xfa, 0xc7, 0x1d, 0x9a, 0xff, 0x24, 0x9d, 0x5f, 0xb0, 0x95,
-	0x7e, 0x07, 0x7c, 0x37, 0x64, 0xf2, 0x9a, 0x91, 0x43, 0xf3, 0x48, 0x0d, 0xfc, 0x9c, 0xbc, 0x66,
-	0xa7, 0x8f, 0xde, 0xfc, 0x3b, 0xda, 0x7b, 0x73, 0x3d, 0xb2, 0xde, 0x5e, 0x8f, 0xac, 0x77, 0xd7,
-	0x23, 0xeb, 0x8f, 0x9b, 0xd1, 0xde, 0xdb, 0x9b, 0xd1, 0xde, 0x3f, 0x37, 0xa3, 0xbd, 0xdf, 0x06,
-	0x5b, 0x9f, 0x9f, 0xd0, 0x81, 0xef, 0xc7, 0xd7, 0xff, 0x07, 0x00, 0x00, 0xff, 0xff, 0x8b, 0xff,
-	0xf2, 0x75, 0xb0, 0x06, 0x00, 0x00,
+	// 875 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8d, 0x55, 0x4d, 0x8f, 0xd4, 0x46,
+	0x10, 0x65, 0xd6, 0x3b, 0x5f, 0x3d, 0x33, 0xcb, 0x6e, 0xb3, 0x0b, 0xce, 0x26, 0x59, 0x36, 0x13,
+	0x0e, 0x48, 0xc0, 0x8c, 0x94, 0x5c, 0x10, 0x22, 0x48, 0x59, 0x16, 0x29, 0x68, 0x85, 0xb2, 0x72,
+	0x20, 0x87, 0x5c, 0x2c, 0x7f, 0xf4, 0xcc, 0x58, 0xfe, 0x68, 0xab, 0xdb, 0x76, 0xd8, 0x5c, 0xf9,
+	0x03, 0xfc, 0x0c, 0xfe, 0x04, 0x77, 0x8e, 0x1c, 0x73, 0x04, 0x72, 0xcf, 0x6f, 0x48, 0x55, 0xb5,
+	0x67, 0xc7, 0x83, 0x46, 0x28, 0x07, 0x4b, 0x5d, 0xef, 0x55, 0xbf, 0xae, 0x2e, 0xbf, 0xb2, 0xd9,
+	0xa3, 0x3c, 0x9e, 0x4f, 0x93, 0x68, 0xbe, 0x28, 0xb2, 0x28, 0x9b, 0x4f, 0x83, 0x85, 0x08, 0xe2,
+	0x5c, 0x46, 0x59, 0xa1, 0x9b, 0xeb, 0xdc, 0x9f, 0xce, 0xa2, 0x44, 0xb8, 0x0d, 0x68, 0x92, 0x2b,
+	0x59, 0xc8, 0xc3, 0x7b, 0xf3, 0xa8, 0x58, 0x94, 0xfe, 0x24, 0x90, 0xe9, 0x74, 0x2e, 0xe7, 0x72,
+	0x4a, 0xb0, 0x5f, 0xce, 0x28, 0xa2, 0x80, 0x56, 0x26, 0x7d, 0xfc, 0x6f, 0x8b, 0xed, 0x3e, 0x5e,
+	0x89, 0x3c, 0x93, 0xa1, 0x48, 0xf8, 0x29, 0x1b, 0x34, 0x84, 0xed, 0xd6, 0xb1, 0x75, 0x7b, 0xf0,
+	0xc3, 0x78, 0xf2, 0x79, 0x5e, 0x13, 0x78, 0x92, 0x15, 0xea, 0xc2, 0x69, 0x6e, 0xe3, 0x3f, 0xb1,
+	0xab, 0x85, 0xa7, 0xe3, 0x46, 0x8d, 0xf6, 0xd6, 0x71, 0x0b, 0x94, 0xf6, 0x27, 0xcf, 0x01, 0x5f,
+	0x6d, 0x26, 0x31, 0x67, 0xa7, 0x58, 0x03, 0x0f, 0x5f, 0xac, 0x15, 0x46, 0xfa, 0x7c, 0x97, 0x59,
+	0xb1, 0xb8, 0x80, 0x82, 0x5a, 0xb7, 0xfb, 0x0e, 0x2e, 0xf9, 0x1d, 0xd6, 0xae, 0xbc, 0xa4, 0x14,
+	0xb5, 0xf4, 0x01, 0x48, 0xfb, 0x89, 0xf8, 0x5c, 0xdb, 0xe4, 0x3c, 0xd8, 0xba, 0xdf, 0x1a, 0xbf,
+	0xd9, 0x62, 0xd7, 0x36, 0x1c, 0xcf, 0x6f, 0xb0, 0x2e, 0x55, 0x1b, 0x85, 0x24, 0x6f, 0x39, 0x1d,
+	0x0c, 0x9f, 0x86, 0xfc, 0x5b, 0xc6, 0xb4, 0x2c, 0x55, 0x20, 0xdc, 0x30, 0x52, 0x74, 0x4c, 0xdf,
+	0xe9, 0x1b, 0xe4, 0x34, 0x52, 0xdc, 0x66, 0x5d, 0xdf, 0x0b, 0x62, 0x91, 0x85, 0xb6, 0x45, 0xdc,
+	0x32, 0xe4, 0xdf, 0xb3, 0x51, 0x94, 0xe6, 0x52, 0x15, 0x42, 0xb9, 0x5e, 0x18, 0x2a, 0x7b, 0x9b,
+	0xf8, 0xe1, 0x12, 0xfc, 0x19, 0x30, 0xfe, 0x35, 0xeb, 0x17, 0x51, 0xe8, 0xbb, 0x0b, 0xa9, 0x0b,
+	0xbb, 0x4d, 0x09, 0x3d, 0x04, 0x7e, 0x81, 0xf8, 0x92, 0xc4, 0x7c, 0xbb, 0x03, 0x64, 0xdb, 0x90,
+	0xe7, 0x10, 0x63, 0xc1, 0x79, 0x68, 0x84, 0xbb, 0xb4, 0xaf, 0x93, 0x87, 0x24, 0x39, 0x66, 0x23,
+	0x8d, 0x07, 0x84, 0x6e, 0x5c, 0x51, 0xcd, 0x3d, 0xa2, 0x07, 0x06, 0x3c, 0xab, 0xb0, 0x6a, 0xa8,
+	0xed, 0xd2, 0x63, 0x6e, 0x25, 0x94, 0xdd, 0x37, 0xb5, 0x5d, 0x82, 0xbf, 0x0b, 0x35, 0xfe, 0xb0,
+	0xc5, 0xf6, 0x37, 0xb5, 0x93, 0x73, 0xb6, 0xbd, 0xf0, 0xf4, 0x82, 0x1a, 0x35, 0x74, 0x68, 0xcd,
+	0xaf, 0xb3, 0x8e, 0x2e, 0xbc, 0xa2, 0xd4, 0xd4, 0x86, 0x91, 0x53, 0x47, 0xd8, 0x3e, 0x2f, 0x49,
+	0x64, 0xe0, 0xfa, 0x9e, 0x16, 0xd4, 0x02, 0xcb, 0xe9, 0x13, 0x72, 0x02, 0x00, 0x7f, 0xc8, 0xba,
+	0x22, 0x9b, 0x47, 0x99, 0xd0, 0x50, 0xa6, 0xb1, 0xd9, 0xa6, 0x23, 0x27, 0x4f, 0x4c, 0x92, 0xb1,
+	0xd9, 0x72, 0x0b, 0x36, 0xbf, 0xc0, 0xec, 0xa7, 0xa7, 0x74, 0x01, 0xcb, 0x59, 0x86, 0xfc, 0x2b,
+	0xd6, 0x83, 0xdb, 0xfb, 0x17, 0x05, 0x08, 0x33, 0xa0, 0xb6, 0x9d, 0x6e, 0x5c, 0x9d, 0x60, 0xc8,
+	0x0f, 0x58, 0x07, 0xa8, 0xb8, 0xd2, 0xf6, 0x80, 0x88, 0x76, 0x5c, 0x9d, 0x55, 0x9a, 0xdf, 0x64,
+	0x03, 0x80, 0xc9, 0xac, 0xba, 0x4c, 0xed, 0x21, 0x70, 0x1d, 0x87, 0xc5, 0xd5, 0xe3, 0x1a, 0x39,
+	0x74, 0xd8, 0xb0, 0x59, 0x45, 0xd3, 0x8c, 0x7b, 0xc6, 0x8c, 0x77, 0xd7, 0xcd, 0x78, 0xbd, 0xae,
+	0xfa, 0x0b, 0x6e, 0x7c, 0xdb, 0x62, 0x07, 0x1b, 0x93, 0x1a, 0xfd, 0x6c, 0xad, 0xf5, 0xf3, 0x01,
+	0xeb, 0x04, 0x8b, 0x32, 0x8b, 0x35, 0x1c, 0x62, 0xfa, 0xb5, 0x71, 0x3f, 0xcc, 0x26, 0x26, 0x99,
+	0x7e, 0xd5, 0x3b, 0x0e, 0xcf, 0xd9, 0xa0, 0x01, 0xff, 0x9f, 0x69, 0xa2, 0xf4, 0x2f, 0xd4, 0xff,
+	0x6a, 0x9b, 0xed, 0x6f, 0xca, 0x41, 0x8b, 0xe4, 0x5e, 0xb1, 0xa8, 0xc5, 0x69, 0x8d, 0x57, 0x92,
+	0xb3, 0x99, 0x16, 0xe6, 0x3b, 0x00, 0x13, 0x66, 0x22, 0x7e, 0x8f, 0xf1, 0x40, 0x26, 0x65, 0x9a,
+	0xb9, 0xb9, 0x50, 0x69, 0x09, 0xf7, 0x8c, 0x64, 0x06, 0x2f, 0xc0, 0x02, 0xbf, 0xef, 0x19, 0xe6,
+	0x7c, 0x45, 0xa0, 0xa3, 0x60, 0xbc, 0xdc, 0x5a, 0xaa, 0x6d, 0x1c, 0x05, 0xc8, 0xaf, 0x46, 0x0d,
+	0x6e, 0x95, 0x4b, 0x4d, 0xe3, 0x62, 0x39, 0xb8, 0xe4, 0xb7, 0xd8, 0x4e, 0xae, 0x44, 0xe5, 0x2a,
+	0xf9, 0x67, 0x14, 0xba, 0xa9, 0xf7, 0x92, 0x06, 0xc6, 0x72, 0x86, 0x88, 0x3a, 0x08, 0x3e, 0xf3,
+	0x5e, 0xe2, 0xb0, 0xad, 0x12, 0x7a, 0x94, 0xd0, 0x53, 0x0d, 0x32, 0xae, 0x82, 0xda, 0x4f, 0x7d,
+	0xb2, 0x0d, 0xf8, 0x2b, 0x30, 0x86, 0x82, 0x49, 0x44, 0x12, 0x1d, 0x65, 0xac, 0x06, 0xfe, 0x0a,
+	0xd0, 0x52, 0xdf, 0xb1, 0x21, 0x12, 0x97, 0x9e, 0x1a, 0x90, 0xa7, 0xc0, 0x66, 0xc1, 0xd2, 0x54,
+	0xfc, 0x1b, 0x1c, 0xf1, 0x54, 0xc0, 0xcb, 0x4d, 0x73, 0x7b, 0x04, 0xfc, 0xae, 0xb3, 0x02, 0xb0,
+	0x8b, 0xc5, 0x45, 0x2e, 0xec, 0x1d, 0x9a, 0x7d, 0x5a, 0xf3, 0x63, 0xf8, 0x38, 0xcb, 0x14, 0x4a,
+	0xd7, 0x1a, 0xdb, 0x74, 0x95, 0xa8, 0x26, 0x84, 0xde, 0xc7, 0x59, 0x77, 0xf1, 0xe5, 0xee, 0x9a,
+	0x6f, 0x12, 0xc6, 0x67, 0xf0, 0x82, 0xe1, 0x1e, 0xf4, 0xdf, 0xd0, 0xd1, 0x5f, 0xc2, 0xde, 0x33,
+	0x97, 0x44, 0xe0, 0x37, 0x88, 0xa9, 0xb1, 0x4a, 0x49, 0x85, 0x8d, 0xd2, 0x36, 0xaf, 0x1b, 0x8b,
+	0x08, 0x34, 0x89, 0x86, 0x6d, 0xe6, 0x45, 0x49, 0xa9, 0x84, 0x7d, 0xcd, 0xa8, 0xd6, 0xe1, 0xc9,
+	0x9d, 0x77, 0x1f, 0x8f, 0xae, 0xbc, 0xfb, 0x74, 0xd4, 0x7a, 0x0f, 0xcf, 0x07, 0x78, 0x5e, 0xff,
+	0x73, 0x74, 0xe5, 0x3d, 0x3c, 0x7f, 0xc3, 0xf3, 0xc7, 0x68, 0xed, 0xbf, 0xe5, 0x77, 0xe8, 0xc7,
+	0xf3, 0xe3, 0x7f, 0xe9, 0xd0, 0xd3, 0xf8, 0xe9, 0x06, 0x00, 0x00,
 }
 
 func (m *CheckpointsModel) Marshal() (dAtA []byte, err error) {
@@ -618,6 +620,22 @@ func (m *ChunkCheckpointModel) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Failure) > 0 {
+		i -= len(m.Failure)
+		copy(dAtA[i:], m.Failure)
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(len(m.Failure)))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x9a
+	}
+	if m.ErrorRows != 0 {
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.ErrorRows))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x90
+	}
 	if m.FileSize != 0 {
 		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.FileSize))
 		i--
@@ -926,6 +944,13 @@ func (m *ChunkCheckpointModel) Size() (n int) {
 	if m.FileSize != 0 {
 		n += 2 + sovFileCheckpoints(uint64(m.FileSize))
 	}
+	if m.ErrorRows != 0 {
+		n += 2 + sovFileCheckpoints(uint64(m.ErrorRows))
+	}
+	l = len(m.Failure)
+	if l > 0 {
+		n += 2 + l + sovFileCheckpoints(uint64(l))
+	}
 	return n
 }
 
@@ -2358,6 +2383,57 @@ func (m *ChunkCheckpointModel) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 18:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ErrorRows", wireType)
+			}
+			m.ErrorRows = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ErrorRows |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Failure", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFileCheckpoints
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthFileCheckpoints
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Failure = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFileCheckpoints(dAtA[iNdEx:])
@@ -0,0 +1,318 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoints
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints/checkpointspb"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/log"
+	verify "github.com/pingcap/br/pkg/lightning/verification"
+)
+
+// Editor is a checkpoints database which can be inspected and edited offline,
+// i.e. while no Lightning is running with it.
+type Editor interface {
+	DB
+
+	// ListTables returns the names of the tables having checkpoints, in order.
+	ListTables(ctx context.Context) ([]string, error)
+	// ApplyDiffs is like Update, but returns the error instead of logging it.
+	ApplyDiffs(ctx context.Context, checkpointDiffs map[string]*TableCheckpointDiff) error
+	// RemoveChunk removes the checkpoint of a chunk, so it is never imported.
+	RemoveChunk(ctx context.Context, tableName string, engineID int32, key ChunkCheckpointKey) error
+	// RewritePaths replaces the prefix of the source directory and of the
+	// paths of the chunks, and returns the number of the rewritten chunks.
+	RewritePaths(ctx context.Context, oldPrefix, newPrefix string) (int64, error)
+}
+
+// DumpJSON writes the checkpoints of the task and of the given table (or all
+// tables) as a JSON document.
+func DumpJSON(ctx context.Context, editor Editor, tableName string, w io.Writer) error {
+	task, err := editor.TaskCheckpoint(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tableNames := []string{tableName}
+	if tableName == allTables {
+		if tableNames, err = editor.ListTables(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	tables := make(map[string]*TableCheckpoint, len(tableNames))
+	for _, name := range tableNames {
+		if tables[name], err = editor.Get(ctx, name); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Trace(encoder.Encode(struct {
+		Task   *TaskCheckpoint
+		Tables map[string]*TableCheckpoint
+	}{Task: task, Tables: tables}))
+}
+
+// MarkEngine marks all chunks of the engine as done or dirty. A done engine is
+// regarded as imported. A dirty engine is encoded and imported again from the
+// start of its chunks by the next run.
+func MarkEngine(ctx context.Context, editor Editor, tableName string, engineID int32, done bool) error {
+	cp, err := editor.Get(ctx, tableName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	engine, ok := cp.Engines[engineID]
+	if !ok {
+		return errors.NotFoundf("engine %d of table %s", engineID, tableName)
+	}
+
+	cpd := NewTableCheckpointDiff()
+	for _, chunk := range engine.Chunks {
+		chunkMerger(cp, engineID, chunk, done).MergeInto(cpd)
+	}
+	if done {
+		if engine.Status < CheckpointStatusImported {
+			(&StatusCheckpointMerger{EngineID: engineID, Status: CheckpointStatusImported}).MergeInto(cpd)
+		}
+	} else {
+		mergeDirtyStatus(cp, engineID, cpd)
+	}
+	return errors.Trace(editor.ApplyDiffs(ctx, map[string]*TableCheckpointDiff{tableName: cpd}))
+}
+
+// MarkChunk marks a chunk as done or dirty. A done chunk is skipped by the
+// next run, and its checksum is kept as is, so the checksum of the table may
+// mismatch if the rest of the chunk was never imported. A dirty chunk is
+// encoded again from its start, along with the engine containing it. Either
+// mark clears the failure of a quarantined chunk.
+func MarkChunk(ctx context.Context, editor Editor, tableName string, engineID int32, key ChunkCheckpointKey, done bool) error {
+	cp, err := editor.Get(ctx, tableName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	engine, ok := cp.Engines[engineID]
+	if !ok {
+		return errors.NotFoundf("engine %d of table %s", engineID, tableName)
+	}
+	var target *ChunkCheckpoint
+	for _, chunk := range engine.Chunks {
+		if chunk.Key == key {
+			target = chunk
+			break
+		}
+	}
+	if target == nil {
+		return errors.NotFoundf("chunk %s in engine %d of table %s", key.String(), engineID, tableName)
+	}
+
+	cpd := NewTableCheckpointDiff()
+	chunkMerger(cp, engineID, target, done).MergeInto(cpd)
+	if !done {
+		mergeDirtyStatus(cp, engineID, cpd)
+	}
+	return errors.Trace(editor.ApplyDiffs(ctx, map[string]*TableCheckpointDiff{tableName: cpd}))
+}
+
+func chunkMerger(cp *TableCheckpoint, engineID int32, chunk *ChunkCheckpoint, done bool) *ChunkCheckpointMerger {
+	if done {
+		return &ChunkCheckpointMerger{
+			EngineID:          engineID,
+			Key:               chunk.Key,
+			Checksum:          chunk.Checksum,
+			Pos:               chunk.Chunk.EndOffset,
+			RowID:             chunk.Chunk.RowIDMax,
+			ColumnPermutation: chunk.ColumnPermutation,
+			ErrorRows:         chunk.ErrorRows,
+		}
+	}
+	return &ChunkCheckpointMerger{
+		EngineID:          engineID,
+		Key:               chunk.Key,
+		Checksum:          verify.MakeKVChecksum(0, 0, 0),
+		Pos:               chunk.Key.Offset,
+		RowID:             initialRowID(cp, chunk),
+		ColumnPermutation: chunk.ColumnPermutation,
+	}
+}
+
+// initialRowID returns the row ID the chunk starts with. The row IDs are
+// allocated to the chunks of a table contiguously, so a chunk starts from the
+// end of the row IDs of its preceding chunk.
+func initialRowID(cp *TableCheckpoint, target *ChunkCheckpoint) int64 {
+	var rowID int64
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			if chunk.Chunk.RowIDMax < target.Chunk.RowIDMax && chunk.Chunk.RowIDMax > rowID {
+				rowID = chunk.Chunk.RowIDMax
+			}
+		}
+	}
+	return rowID
+}
+
+// mergeDirtyStatus lowers the status of the engine and the table, so that the
+// dirty chunks are restored again.
+func mergeDirtyStatus(cp *TableCheckpoint, engineID int32, cpd *TableCheckpointDiff) {
+	if cp.Engines[engineID].Status > CheckpointStatusLoaded {
+		(&StatusCheckpointMerger{EngineID: engineID, Status: CheckpointStatusLoaded}).MergeInto(cpd)
+	}
+	if cp.Status > CheckpointStatusLoaded {
+		(&StatusCheckpointMerger{EngineID: WholeTableEngineID, Status: CheckpointStatusLoaded}).MergeInto(cpd)
+	}
+}
+
+func (cpdb *MySQLCheckpointsDB) ListTables(ctx context.Context) ([]string, error) {
+	query := fmt.Sprintf("SELECT table_name FROM %s.%s ORDER BY table_name;", cpdb.schema, CheckpointTableNameTable)
+	rows, err := cpdb.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	var tableNames []string
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, errors.Trace(err)
+		}
+		tableNames = append(tableNames, tableName)
+	}
+	return tableNames, errors.Trace(rows.Err())
+}
+
+func (cpdb *MySQLCheckpointsDB) RemoveChunk(ctx context.Context, tableName string, engineID int32, key ChunkCheckpointKey) error {
+	s := common.SQLWithRetry{
+		DB:     cpdb.db,
+		Logger: log.With(zap.String("table", tableName)),
+	}
+	query := fmt.Sprintf("DELETE FROM %s.%s WHERE (table_name, engine_id, path, offset) = (?, ?, ?, ?);",
+		cpdb.schema, CheckpointTableNameChunk)
+	return s.Transact(ctx, "remove chunk checkpoint", func(c context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(c, query, tableName, engineID, key.Path, key.Offset)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if affected == 0 {
+			return errors.NotFoundf("chunk %s in engine %d of table %s", key.String(), engineID, tableName)
+		}
+		return nil
+	})
+}
+
+func (cpdb *MySQLCheckpointsDB) RewritePaths(ctx context.Context, oldPrefix, newPrefix string) (int64, error) {
+	s := common.SQLWithRetry{DB: cpdb.db, Logger: log.L()}
+	// the lengths in SQL are counted in characters.
+	length := utf8.RuneCountInString(oldPrefix)
+	taskQuery := fmt.Sprintf(
+		"UPDATE %s.%s SET source_dir = CONCAT(?, SUBSTRING(source_dir, ?)) WHERE LEFT(source_dir, ?) = ?;",
+		cpdb.schema, CheckpointTableNameTask)
+	chunkQuery := fmt.Sprintf(
+		"UPDATE %s.%s SET path = CONCAT(?, SUBSTRING(path, ?)) WHERE LEFT(path, ?) = ?;",
+		cpdb.schema, CheckpointTableNameChunk)
+
+	var rewritten int64
+	err := s.Transact(ctx, "rewrite checkpoint paths", func(c context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(c, taskQuery, newPrefix, length+1, length, oldPrefix); err != nil {
+			return errors.Trace(err)
+		}
+		result, err := tx.ExecContext(c, chunkQuery, newPrefix, length+1, length, oldPrefix)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		rewritten, err = result.RowsAffected()
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return rewritten, nil
+}
+
+func (cpdb *FileCheckpointsDB) ListTables(context.Context) ([]string, error) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	tableNames := make([]string, 0, len(cpdb.checkpoints.Checkpoints))
+	for tableName := range cpdb.checkpoints.Checkpoints {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	return tableNames, nil
+}
+
+func (cpdb *FileCheckpointsDB) RemoveChunk(_ context.Context, tableName string, engineID int32, key ChunkCheckpointKey) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	tableModel, ok := cpdb.checkpoints.Checkpoints[tableName]
+	if !ok {
+		return errors.NotFoundf("checkpoint for table %s", tableName)
+	}
+	engineModel, ok := tableModel.Engines[engineID]
+	if !ok {
+		return errors.NotFoundf("engine %d of table %s", engineID, tableName)
+	}
+	if _, ok := engineModel.Chunks[key.String()]; !ok {
+		return errors.NotFoundf("chunk %s in engine %d of table %s", key.String(), engineID, tableName)
+	}
+	delete(engineModel.Chunks, key.String())
+	return errors.Trace(cpdb.save())
+}
+
+func (cpdb *FileCheckpointsDB) RewritePaths(_ context.Context, oldPrefix, newPrefix string) (int64, error) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if task := cpdb.checkpoints.TaskCheckpoint; task != nil && strings.HasPrefix(task.SourceDir, oldPrefix) {
+		task.SourceDir = newPrefix + strings.TrimPrefix(task.SourceDir, oldPrefix)
+	}
+	var rewritten int64
+	for _, tableModel := range cpdb.checkpoints.Checkpoints {
+		for _, engineModel := range tableModel.Engines {
+			// the chunks are keyed by the paths, so collect the rewritten ones
+			// before re-inserting them.
+			var chunks []*checkpointspb.ChunkCheckpointModel
+			for key, chunkModel := range engineModel.Chunks {
+				if !strings.HasPrefix(chunkModel.Path, oldPrefix) {
+					continue
+				}
+				delete(engineModel.Chunks, key)
+				chunkModel.Path = newPrefix + strings.TrimPrefix(chunkModel.Path, oldPrefix)
+				chunks = append(chunks, chunkModel)
+			}
+			for _, chunkModel := range chunks {
+				key := ChunkCheckpointKey{Path: chunkModel.Path, Offset: chunkModel.Offset}
+				engineModel.Chunks[key.String()] = chunkModel
+			}
+			rewritten += int64(len(chunks))
+		}
+	}
+	return rewritten, errors.Trace(cpdb.save())
+}
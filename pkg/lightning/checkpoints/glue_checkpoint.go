@@ -301,7 +301,9 @@ func (g GlueCheckpointsDB) Get(ctx context.Context, tableName string) (*TableChe
 				kvcBytes := row.GetUint64(12)
 				kvcKVs := row.GetUint64(13)
 				kvcChecksum := row.GetUint64(14)
-				value.Timestamp = row.GetInt64(15)
+				value.ErrorRows = row.GetInt64(15)
+				value.Failure = row.GetString(16)
+				value.Timestamp = row.GetInt64(17)
 
 				value.FileMeta.Path = value.Key.Path
 				value.Checksum = verify.MakeKVChecksum(kvcBytes, kvcKVs, kvcChecksum)
@@ -488,6 +490,8 @@ func (g GlueCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDif
 						types.NewUintDatum(diff.checksum.SumKVS()),
 						types.NewUintDatum(diff.checksum.Sum()),
 						types.NewBytesDatum(columnPerm),
+						types.NewIntDatum(diff.errorRows),
+						types.NewStringDatum(diff.failure),
 						types.NewStringDatum(tableName),
 						types.NewIntDatum(int64(engineID)),
 						types.NewStringDatum(key.Path),
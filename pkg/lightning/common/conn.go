@@ -16,6 +16,8 @@ package common
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pingcap/errors"
 	"go.uber.org/zap"
@@ -82,30 +84,200 @@ func NewConnPool(cap int, newConn func(ctx context.Context) (*grpc.ClientConn, e
 	}
 }
 
-type GRPCConns struct {
-	mu    sync.Mutex
-	conns map[uint64]*ConnPool
+// DefaultConnIdleTimeout is the default duration after which the connections
+// to a store not used are closed.
+const DefaultConnIdleTimeout = 10 * time.Minute
+
+type storeConnPool struct {
+	*ConnPool
+	// inflight is the number of the calls and the streams not finished, and
+	// lastUsed is the unix nano time when the pool was last used.
+	inflight int32
+	lastUsed int64
+}
+
+func (p *storeConnPool) touch(now time.Time) {
+	atomic.StoreInt64(&p.lastUsed, now.UnixNano())
+}
+
+// isIdle returns whether the connections carry no call and haven't been
+// used since the idle timeout before now.
+func (p *storeConnPool) isIdle(now time.Time, idleTimeout time.Duration) bool {
+	return atomic.LoadInt32(&p.inflight) == 0 &&
+		now.Sub(time.Unix(0, atomic.LoadInt64(&p.lastUsed))) >= idleTimeout
+}
+
+func (p *storeConnPool) begin() {
+	atomic.AddInt32(&p.inflight, 1)
+	p.touch(time.Now())
 }
 
-func (conns *GRPCConns) Close() {
-	conns.mu.Lock()
-	defer conns.mu.Unlock()
+func (p *storeConnPool) end() {
+	p.touch(time.Now())
+	atomic.AddInt32(&p.inflight, -1)
+}
 
-	for _, cp := range conns.conns {
-		cp.Close()
+// dialOptions returns the options counting the calls and the streams in
+// flight on the connections of the pool, which are never closed as idle.
+func (p *storeConnPool) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(
+			ctx context.Context,
+			method string,
+			req, reply interface{},
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			p.begin()
+			defer p.end()
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(
+			ctx context.Context,
+			desc *grpc.StreamDesc,
+			cc *grpc.ClientConn,
+			method string,
+			streamer grpc.Streamer,
+			opts ...grpc.CallOption,
+		) (grpc.ClientStream, error) {
+			p.begin()
+			stream, err := streamer(ctx, desc, cc, method, opts...)
+			if err != nil {
+				p.end()
+				return nil, err
+			}
+			// the context of the stream is done once the stream finishes.
+			go func() {
+				<-stream.Context().Done()
+				p.end()
+			}()
+			return stream, nil
+		}),
 	}
 }
 
-func (conns *GRPCConns) GetGrpcConn(ctx context.Context, storeID uint64, tcpConcurrency int, newConn func(ctx context.Context) (*grpc.ClientConn, error)) (*grpc.ClientConn, error) {
-	conns.mu.Lock()
-	defer conns.mu.Unlock()
-	if _, ok := conns.conns[storeID]; !ok {
-		conns.conns[storeID] = NewConnPool(tcpConcurrency, newConn)
+// StoreConnManager holds the gRPC connection pools of the stores shared by
+// several users, e.g. the local backend and the duplicate managers.
+//
+// The manager is reference counted. It's created with one reference, every
+// user sharing it should call Retain, and Release when it no longer needs the
+// connections. All connections are closed when the last reference is released.
+// The connections to a store not used for the idle timeout are closed in the
+// background, and are dialed again on demand. The connections carrying calls
+// or streams are never closed as idle.
+type StoreConnManager struct {
+	mu       sync.Mutex
+	pools    map[uint64]*storeConnPool
+	refs     int
+	stopIdle chan struct{}
+
+	tcpConcurrency int
+	idleTimeout    time.Duration
+	newConn        func(ctx context.Context, storeID uint64, opts ...grpc.DialOption) (*grpc.ClientConn, error)
+}
+
+// NewStoreConnManager creates a StoreConnManager keeping at most
+// tcpConcurrency connections to each store, which are dialed by newConn with
+// the extra options opts. A non-positive idleTimeout never closes the idle
+// connections.
+func NewStoreConnManager(
+	tcpConcurrency int,
+	idleTimeout time.Duration,
+	newConn func(ctx context.Context, storeID uint64, opts ...grpc.DialOption) (*grpc.ClientConn, error),
+) *StoreConnManager {
+	m := &StoreConnManager{
+		pools:          make(map[uint64]*storeConnPool),
+		refs:           1,
+		stopIdle:       make(chan struct{}),
+		tcpConcurrency: tcpConcurrency,
+		idleTimeout:    idleTimeout,
+		newConn:        newConn,
 	}
-	return conns.conns[storeID].get(ctx)
+	if idleTimeout > 0 {
+		go m.evictIdleLoop()
+	}
+	return m
+}
+
+// Retain adds a reference to the manager and returns it.
+func (m *StoreConnManager) Retain() *StoreConnManager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refs++
+	return m
 }
 
-func NewGRPCConns() GRPCConns {
-	cons := GRPCConns{conns: make(map[uint64]*ConnPool)}
-	return cons
+// Release drops a reference to the manager. The connections are closed once
+// there is no reference.
+func (m *StoreConnManager) Release() {
+	m.mu.Lock()
+	if m.refs <= 0 {
+		m.mu.Unlock()
+		return
+	}
+	m.refs--
+	if m.refs > 0 {
+		m.mu.Unlock()
+		return
+	}
+	close(m.stopIdle)
+	pools := m.pools
+	m.pools = make(map[uint64]*storeConnPool)
+	m.mu.Unlock()
+	for _, pool := range pools {
+		pool.Close()
+	}
+}
+
+// GetConn returns a connection to the store.
+func (m *StoreConnManager) GetConn(ctx context.Context, storeID uint64) (*grpc.ClientConn, error) {
+	m.mu.Lock()
+	if m.refs <= 0 {
+		m.mu.Unlock()
+		return nil, errors.New("the store connections have been closed")
+	}
+	pool, ok := m.pools[storeID]
+	if !ok {
+		pool = &storeConnPool{}
+		pool.ConnPool = NewConnPool(m.tcpConcurrency, func(ctx context.Context) (*grpc.ClientConn, error) {
+			return m.newConn(ctx, storeID, pool.dialOptions()...)
+		})
+		m.pools[storeID] = pool
+	}
+	pool.touch(time.Now())
+	m.mu.Unlock()
+	// dial outside of the lock, so a slow store doesn't block the others.
+	return pool.get(ctx)
+}
+
+func (m *StoreConnManager) evictIdleLoop() {
+	ticker := time.NewTicker(m.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopIdle:
+			return
+		case now := <-ticker.C:
+			m.evictIdle(now)
+		}
+	}
+}
+
+// evictIdle closes the connections to the stores not used since the idle
+// timeout before now.
+func (m *StoreConnManager) evictIdle(now time.Time) {
+	m.mu.Lock()
+	var idle []*storeConnPool
+	for storeID, pool := range m.pools {
+		if pool.isIdle(now, m.idleTimeout) {
+			log.L().Info("close idle store connections", zap.Uint64("store", storeID))
+			idle = append(idle, pool)
+			delete(m.pools, storeID)
+		}
+	}
+	m.mu.Unlock()
+	for _, pool := range idle {
+		pool.Close()
+	}
 }
@@ -0,0 +1,118 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	. "github.com/pingcap/check"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/pingcap/br/pkg/lightning/common"
+)
+
+type connSuite struct{}
+
+var _ = Suite(&connSuite{})
+
+func (s *connSuite) TestStoreConnManager(c *C) {
+	var dialed int32
+	newConn := func(ctx context.Context, storeID uint64, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		atomic.AddInt32(&dialed, 1)
+		// the dial is non-blocking, so nothing needs to listen on the address.
+		return grpc.DialContext(ctx, "127.0.0.1:1", append(opts, grpc.WithInsecure())...)
+	}
+	ctx := context.Background()
+
+	m := common.NewStoreConnManager(2, 0, newConn)
+	conns := make([]*grpc.ClientConn, 0, 4)
+	for i := 0; i < 4; i++ {
+		conn, err := m.GetConn(ctx, 1)
+		c.Assert(err, IsNil)
+		conns = append(conns, conn)
+	}
+	c.Assert(atomic.LoadInt32(&dialed), Equals, int32(2))
+	c.Assert(conns[2], Equals, conns[0])
+	c.Assert(conns[3], Equals, conns[1])
+
+	// the connections are kept until the last user releases the manager.
+	m.Retain()
+	m.Release()
+	c.Assert(conns[0].GetState(), Not(Equals), connectivity.Shutdown)
+	m.Release()
+	c.Assert(conns[0].GetState(), Equals, connectivity.Shutdown)
+	c.Assert(conns[1].GetState(), Equals, connectivity.Shutdown)
+	_, err := m.GetConn(ctx, 1)
+	c.Assert(err, ErrorMatches, ".*closed.*")
+}
+
+func (s *connSuite) TestStoreConnManagerEvictIdle(c *C) {
+	var dialed int32
+	newConn := func(ctx context.Context, storeID uint64, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		atomic.AddInt32(&dialed, 1)
+		return grpc.DialContext(ctx, "127.0.0.1:1", append(opts, grpc.WithInsecure())...)
+	}
+	ctx := context.Background()
+
+	m := common.NewStoreConnManager(1, 100*time.Millisecond, newConn)
+	defer m.Release()
+	conn, err := m.GetConn(ctx, 1)
+	c.Assert(err, IsNil)
+
+	time.Sleep(300 * time.Millisecond)
+	c.Assert(conn.GetState(), Equals, connectivity.Shutdown)
+	conn, err = m.GetConn(ctx, 1)
+	c.Assert(err, IsNil)
+	c.Assert(conn.GetState(), Not(Equals), connectivity.Shutdown)
+	c.Assert(atomic.LoadInt32(&dialed), Equals, int32(2))
+}
+
+func (s *connSuite) TestStoreConnManagerKeepStreaming(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	newConn := func(ctx context.Context, storeID uint64, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		return grpc.DialContext(ctx, listener.Addr().String(), append(opts, grpc.WithInsecure())...)
+	}
+	m := common.NewStoreConnManager(1, 100*time.Millisecond, newConn)
+	defer m.Release()
+	conn, err := m.GetConn(context.Background(), 1)
+	c.Assert(err, IsNil)
+
+	// the connection carrying a stream is not closed as idle.
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{})
+	c.Assert(err, IsNil)
+	_, err = stream.Recv()
+	c.Assert(err, IsNil)
+	time.Sleep(300 * time.Millisecond)
+	c.Assert(conn.GetState(), Not(Equals), connectivity.Shutdown)
+
+	// it's closed once the stream finishes and the idle timeout passes.
+	cancel()
+	time.Sleep(300 * time.Millisecond)
+	c.Assert(conn.GetState(), Equals, connectivity.Shutdown)
+}
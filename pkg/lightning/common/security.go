@@ -149,6 +149,16 @@ func (tc *TLS) GetJSON(ctx context.Context, path string, v interface{}) error {
 	return GetJSON(ctx, tc.client, tc.url+path, v)
 }
 
+// URL returns the base URL of the host.
+func (tc *TLS) URL() string {
+	return tc.url
+}
+
+// HTTPClient returns the HTTP client with the TLS configured.
+func (tc *TLS) HTTPClient() *http.Client {
+	return tc.client
+}
+
 func (tc *TLS) ToPDSecurityOption() pd.SecurityOption {
 	return pd.SecurityOption{
 		CAPath:   tc.caPath,
@@ -0,0 +1,237 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/pdutil"
+)
+
+const (
+	// autoTuneDiskProbeSize is the size of the file written to measure the
+	// write throughput of the sorted-kv-dir.
+	autoTuneDiskProbeSize = 32 * units.MiB
+	// autoTuneDiskBytesPerIOWorker is the disk throughput which can be kept
+	// busy by one io worker.
+	autoTuneDiskBytesPerIOWorker = 64 * units.MiB
+	autoTuneMinIOConcurrency     = 2
+	// the default table and index concurrency fit a cluster with 3 stores.
+	autoTuneMaxTableConcurrency = 32
+	autoTuneMaxIndexConcurrency = 8
+)
+
+// hostProbe is the result of probing the host and the target cluster. The
+// zero values mean unknown.
+type hostProbe struct {
+	cpuCount int
+	// diskSpeed is the write throughput of the sorted-kv-dir in bytes per second.
+	diskSpeed  float64
+	storeCount int
+}
+
+// autoTune probes the host and the target cluster, and derives the
+// concurrency settings not explicitly set in the config file.
+func (cfg *Config) autoTune(ctx context.Context) {
+	probe := hostProbe{cpuCount: effectiveCPUCount()}
+	if cfg.TikvImporter.Backend == BackendLocal {
+		speed, err := probeDiskSpeed(cfg.TikvImporter.SortedKVDir, autoTuneDiskProbeSize)
+		if err != nil {
+			log.L().Warn("failed to measure the disk speed of sorted-kv-dir", log.ShortError(err))
+		}
+		probe.diskSpeed = speed
+	}
+	if cfg.TikvImporter.Backend == BackendLocal || cfg.TikvImporter.Backend == BackendImporter {
+		count, err := cfg.probeStoreCount(ctx)
+		if err != nil {
+			log.L().Warn("failed to fetch the store count of the cluster", log.ShortError(err))
+		}
+		probe.storeCount = count
+	}
+
+	cfg.applyAutoTune(probe)
+	log.L().Info("auto-tune concurrency",
+		zap.Int("cpuCount", probe.cpuCount),
+		zap.String("diskSpeed", units.BytesSize(probe.diskSpeed)+"/s"),
+		zap.Int("storeCount", probe.storeCount),
+		zap.Int("region-concurrency", cfg.App.RegionConcurrency),
+		zap.Int("io-concurrency", cfg.App.IOConcurrency),
+		zap.Int("table-concurrency", cfg.App.TableConcurrency),
+		zap.Int("index-concurrency", cfg.App.IndexConcurrency),
+	)
+}
+
+// applyAutoTune derives the concurrency settings from the probe result. The
+// settings explicitly set in the config file and the ones which cannot be
+// derived since the probe failed are kept.
+func (cfg *Config) applyAutoTune(probe hostProbe) {
+	if probe.cpuCount > 0 && !cfg.isExplicit("lightning.region-concurrency") {
+		cfg.App.RegionConcurrency = probe.cpuCount
+	}
+	if probe.diskSpeed > 0 && !cfg.isExplicit("lightning.io-concurrency") {
+		ioConcurrency := int(probe.diskSpeed / autoTuneDiskBytesPerIOWorker)
+		if ioConcurrency < autoTuneMinIOConcurrency {
+			ioConcurrency = autoTuneMinIOConcurrency
+		}
+		if probe.cpuCount > 0 && ioConcurrency > probe.cpuCount {
+			ioConcurrency = probe.cpuCount
+		}
+		cfg.App.IOConcurrency = ioConcurrency
+	}
+
+	switch cfg.TikvImporter.Backend {
+	case BackendTiDB:
+		// the tidb backend writes every table through SQL statements, so the
+		// tables are restored as concurrent as the regions.
+		if !cfg.isExplicit("lightning.table-concurrency") {
+			cfg.App.TableConcurrency = cfg.App.RegionConcurrency
+		}
+		if !cfg.isExplicit("lightning.index-concurrency") {
+			cfg.App.IndexConcurrency = cfg.App.RegionConcurrency
+		}
+	case BackendLocal, BackendImporter:
+		if probe.storeCount <= 0 {
+			break
+		}
+		if !cfg.isExplicit("lightning.table-concurrency") {
+			cfg.App.TableConcurrency = clampInt(probe.storeCount*2, defaultTableConcurrency, autoTuneMaxTableConcurrency)
+		}
+		if !cfg.isExplicit("lightning.index-concurrency") {
+			cfg.App.IndexConcurrency = clampInt((probe.storeCount+2)/3, defaultIndexConcurrency, autoTuneMaxIndexConcurrency)
+		}
+	}
+}
+
+// isExplicit checks whether the key is set in the config file.
+func (cfg *Config) isExplicit(key string) bool {
+	_, ok := cfg.definedKeys[key]
+	return ok
+}
+
+func (cfg *Config) probeStoreCount(ctx context.Context) (int, error) {
+	if len(cfg.TiDB.PdAddr) == 0 {
+		return 0, errors.New("pd address is unknown")
+	}
+	tls, err := cfg.ToTLS()
+	if err != nil {
+		return 0, err
+	}
+	result, err := pdutil.NewHTTPClientFromTLS(tls.WithHost(cfg.TiDB.PdAddr)).GetStores(ctx)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return len(result.Stores), nil
+}
+
+// effectiveCPUCount returns the number of CPUs, limited by the cgroup CPU
+// quota if Lightning runs inside a container.
+func effectiveCPUCount() int {
+	cpuCount := runtime.NumCPU()
+	if quota, ok := cgroupCPUQuota(); ok && quota < float64(cpuCount) {
+		cpuCount = int(math.Ceil(quota))
+	}
+	if cpuCount < 1 {
+		cpuCount = 1
+	}
+	return cpuCount
+}
+
+// cgroupCPUQuota reads the CPU quota from cgroup v2 or v1.
+func cgroupCPUQuota() (float64, bool) {
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		// the format is "$MAX $PERIOD", where $MAX is "max" if unlimited.
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			return parseCPUQuota(fields[0], fields[1])
+		}
+		return 0, false
+	}
+	quota, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+	return parseCPUQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func parseCPUQuota(quotaStr, periodStr string) (float64, bool) {
+	quota, err := strconv.ParseFloat(quotaStr, 64)
+	if err != nil || quota <= 0 {
+		return 0, false
+	}
+	period, err := strconv.ParseFloat(periodStr, 64)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+	return quota / period, true
+}
+
+// probeDiskSpeed measures the write throughput of the directory by writing a
+// file of the given size and syncing it. If the directory does not exist yet,
+// its parent is measured instead.
+func probeDiskSpeed(dir string, size int) (float64, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		dir = filepath.Dir(dir)
+	}
+	f, err := os.CreateTemp(dir, "lightning-autotune-*")
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	buf := make([]byte, units.MiB)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	start := time.Now()
+	for written := 0; written < size; written += len(buf) {
+		if _, err := f.Write(buf); err != nil {
+			return 0, errors.Trace(err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return 0, errors.Trace(err)
+	}
+	elapsed := time.Since(start).Seconds()
+	if elapsed <= 0 {
+		return 0, errors.New("the disk is too fast to measure")
+	}
+	return float64(size) / elapsed, nil
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -23,8 +23,10 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -35,11 +37,13 @@ import (
 	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
 	router "github.com/pingcap/tidb-tools/pkg/table-router"
 	tidbcfg "github.com/pingcap/tidb/config"
-	"github.com/tikv/pd/server/api"
 	"go.uber.org/zap"
 
+	"github.com/pingcap/br/pkg/grpcutil"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/validation"
 )
 
 const (
@@ -55,6 +59,10 @@ const (
 	// BackendLocal is a constant for choosing the "Local" backup in the configuration.
 	// In this mode, we write & sort kv pairs with local storage and directly write them to tikv.
 	BackendLocal = "local"
+	// BackendNoop is a constant for choosing the "Noop" backend in the configuration.
+	// In this mode, we encode the data and compute the checksums, but discard the kv pairs. It is a dry run to
+	// validate the source data and estimate the size of the import.
+	BackendNoop = "noop"
 
 	// CheckpointDriverMySQL is a constant for choosing the "MySQL" checkpoint driver in the configuration.
 	CheckpointDriverMySQL = "mysql"
@@ -68,6 +76,18 @@ const (
 	// ErrorOnDup indicates using INSERT INTO to insert data, which would violate PK or UNIQUE constraint
 	ErrorOnDup = "error"
 
+	// DataCharsetValidationStrict fails the row if the data contains byte sequences
+	// which are invalid in `mydumper.data-character-set`.
+	DataCharsetValidationStrict = "strict"
+	// DataCharsetValidationReplace replaces the invalid byte sequences with U+FFFD.
+	DataCharsetValidationReplace = "replace"
+
+	// TableOrderSource restores the tables in the order of the data source.
+	TableOrderSource = "source"
+	// TableOrderLargestFirst restores the largest tables first, so that the
+	// small tables fill the idle workers near the end of the import.
+	TableOrderLargestFirst = "largest-first"
+
 	defaultDistSQLScanConcurrency     = 15
 	distSQLScanConcurrencyPerStore    = 4
 	defaultBuildStatsConcurrency      = 20
@@ -75,6 +95,7 @@ const (
 	defaultChecksumTableConcurrency   = 2
 	defaultTableConcurrency           = 6
 	defaultIndexConcurrency           = 2
+	defaultSampleVerifyRows           = 100
 
 	// defaultMetaSchemaName is the default database name used to store lightning metadata
 	defaultMetaSchemaName = "lightning_metadata"
@@ -89,10 +110,7 @@ const (
 	autoDiskQuotaLocalReservedSpeed uint64 = 1 * units.KiB
 	defaultEngineMemCacheSize              = 512 * units.MiB
 	defaultLocalWriterMemCacheSize         = 128 * units.MiB
-
-	maxRetryTimes           = 4
-	defaultRetryBackoffTime = 100 * time.Millisecond
-	pdStores                = "/pd/api/v1/stores"
+	defaultEngineTTL                       = 24 * time.Hour
 )
 
 var (
@@ -129,6 +147,15 @@ type DBStore struct {
 	ChecksumTableConcurrency   int `toml:"checksum-table-concurrency" json:"checksum-table-concurrency"`
 }
 
+// TLSParam returns the value of the `tls` parameter in the DSN to connect to
+// the database, which is the registered name of the TLS config for "cluster".
+func (db *DBStore) TLSParam() string {
+	if db.TLS == "cluster" && db.Security != nil {
+		return db.Security.tlsConfigName()
+	}
+	return db.TLS
+}
+
 type Config struct {
 	TaskID int64 `toml:"-" json:"id"`
 
@@ -144,6 +171,9 @@ type Config struct {
 	Security     Security            `toml:"security" json:"security"`
 
 	BWList filter.MySQLReplicationRules `toml:"black-white-list" json:"black-white-list"`
+
+	// definedKeys are the keys set in the config files, like "lightning.io-concurrency".
+	definedKeys map[string]struct{}
 }
 
 func (cfg *Config) String() string {
@@ -166,6 +196,31 @@ type Lightning struct {
 	IOConcurrency     int    `toml:"io-concurrency" json:"io-concurrency"`
 	CheckRequirements bool   `toml:"check-requirements" json:"check-requirements"`
 	MetaSchemaName    string `toml:"meta-schema-name" json:"meta-schema-name"`
+	MaxError          int64  `toml:"max-error" json:"max-error"`
+	QuarantineDir     string `toml:"quarantine-dir" json:"quarantine-dir"`
+	// ChunkRetry is the number of times a chunk failing to be encoded or
+	// written is retried from its last progress. 0 disables the retries.
+	ChunkRetry int `toml:"chunk-retry" json:"chunk-retry"`
+	// QuarantineChunks quarantines the chunks still failing after the retries
+	// instead of failing the table at once, the import fails after the rest of
+	// the tables are imported.
+	QuarantineChunks bool `toml:"quarantine-chunks" json:"quarantine-chunks"`
+	// AutoTune derives the concurrency settings which are not explicitly set
+	// from the host and the target cluster.
+	AutoTune bool `toml:"auto-tune" json:"auto-tune"`
+	// Priority orders the queued tasks in the server mode, the tasks of higher
+	// priority are run first.
+	Priority int `toml:"priority" json:"priority"`
+	// MemoryBudgetRatio is the ratio of the host memory shared by the encoded
+	// KV batches, the buffers of the local writers and the block cache of the
+	// engines. 0 disables the budget.
+	MemoryBudgetRatio float64 `toml:"memory-budget-ratio" json:"memory-budget-ratio"`
+	// TableOrder is the order in which the tables start to be restored,
+	// TableOrderSource or TableOrderLargestFirst.
+	TableOrder string `toml:"table-order" json:"table-order"`
+	// TableDependencies maps a table, "schema.table", to the tables which
+	// must be restored before it starts, whatever the TableOrder is.
+	TableDependencies map[string][]string `toml:"table-dependencies" json:"table-dependencies"`
 }
 
 type PostOpLevel int
@@ -240,6 +295,8 @@ type PostRestore struct {
 	Level1Compact     bool        `toml:"level-1-compact" json:"level-1-compact"`
 	PostProcessAtLast bool        `toml:"post-process-at-last" json:"post-process-at-last"`
 	Compact           bool        `toml:"compact" json:"compact"`
+	SampleVerify      PostOpLevel `toml:"sample-verify" json:"sample-verify"`
+	SampleVerifyRows  int         `toml:"sample-verify-rows" json:"sample-verify-rows"`
 }
 
 type CSVConfig struct {
@@ -251,6 +308,8 @@ type CSVConfig struct {
 	TrimLastSep     bool   `toml:"trim-last-separator" json:"trim-last-separator"`
 	NotNull         bool   `toml:"not-null" json:"not-null"`
 	BackslashEscape bool   `toml:"backslash-escape" json:"backslash-escape"`
+	// NullIf maps the column names to the field values which are treated as NULL for that column.
+	NullIf map[string][]string `toml:"null-if" json:"null-if"`
 }
 
 type MydumperRuntime struct {
@@ -269,6 +328,18 @@ type MydumperRuntime struct {
 	StrictFormat     bool             `toml:"strict-format" json:"strict-format"`
 	DefaultFileRules bool             `toml:"default-file-rules" json:"default-file-rules"`
 	IgnoreColumns    AllIgnoreColumns `toml:"ignore-data-columns" json:"ignore-data-columns"`
+	// SchemaTemplateDir contains the schema files used to create the tables
+	// which have no schema files in the data source.
+	SchemaTemplateDir string `toml:"schema-template-dir" json:"schema-template-dir"`
+	// InferSchema creates the tables which have no schema files by inferring
+	// the column types from the CSV data files.
+	InferSchema bool `toml:"infer-schema" json:"infer-schema"`
+	// DataCharacterSet is the character set of the data files. The string
+	// values are converted from this character set to utf8mb4 before encoding.
+	DataCharacterSet string `toml:"data-character-set" json:"data-character-set"`
+	// DataCharsetValidation decides how to handle invalid byte sequences in the
+	// data files, either "strict" or "replace".
+	DataCharsetValidation string `toml:"data-charset-validation" json:"data-charset-validation"`
 }
 
 type AllIgnoreColumns []*IgnoreColumns
@@ -322,9 +393,35 @@ type TikvImporter struct {
 	DiskQuota          ByteSize `toml:"disk-quota" json:"disk-quota"`
 	RangeConcurrency   int      `toml:"range-concurrency" json:"range-concurrency"`
 	DuplicateDetection bool     `toml:"duplicate-detection" json:"duplicate-detection"`
+	// DuplicateDetectFailFast fails the table once collecting the duplicated
+	// rows fails, instead of logging the error and going on.
+	DuplicateDetectFailFast bool `toml:"duplicate-detect-fail-fast" json:"duplicate-detect-fail-fast"`
+	// MigrateImporter switches the deprecated "importer" backend to the
+	// "local" backend, translating the settings where possible.
+	MigrateImporter bool `toml:"migrate-importer" json:"migrate-importer"`
 
 	EngineMemCacheSize      ByteSize `toml:"engine-mem-cache-size" json:"engine-mem-cache-size"`
 	LocalWriterMemCacheSize ByteSize `toml:"local-writer-mem-cache-size" json:"local-writer-mem-cache-size"`
+	// SpillUnorderedKVs lets the local writers which find their KV pairs
+	// wildly out of order sort them externally through temporary files, so
+	// that the engines ingest non-overlapping SSTs.
+	SpillUnorderedKVs bool `toml:"spill-unordered-kvs" json:"spill-unordered-kvs"`
+
+	RetryImportDelay Duration `toml:"retry-import-delay" json:"retry-import-delay"`
+	ImportMaxRetry   int      `toml:"import-max-retry" json:"import-max-retry"`
+	WriteMaxRetry    int      `toml:"write-max-retry" json:"write-max-retry"`
+	WriteTimeout     Duration `toml:"write-timeout" json:"write-timeout"`
+
+	EngineTTL Duration `toml:"engine-ttl" json:"engine-ttl"`
+
+	PerTableIOLimit ByteSize `toml:"per-table-io-limit" json:"per-table-io-limit"`
+
+	// GRPC is the options of the gRPC connections of the "local" backend to
+	// TiKV, and of the "importer" backend to tikv-importer.
+	GRPC grpcutil.Config `toml:"grpc" json:"grpc"`
+	// DuplicateGRPC is the options of the separate connections of the "local"
+	// backend detecting the duplicates, which share the ones of GRPC if unset.
+	DuplicateGRPC grpcutil.Config `toml:"duplicate-grpc" json:"duplicate-grpc"`
 }
 
 type Checkpoint struct {
@@ -339,6 +436,9 @@ type Cron struct {
 	SwitchMode     Duration `toml:"switch-mode" json:"switch-mode"`
 	LogProgress    Duration `toml:"log-progress" json:"log-progress"`
 	CheckDiskQuota Duration `toml:"check-disk-quota" json:"check-disk-quota"`
+	// CheckSchemaChange is the interval to check whether the target tables
+	// are altered during the import. Zero disables the check.
+	CheckSchemaChange Duration `toml:"check-schema-change" json:"check-schema-change"`
 }
 
 type Security struct {
@@ -347,10 +447,24 @@ type Security struct {
 	KeyPath  string `toml:"key-path" json:"key-path"`
 	// RedactInfoLog indicates that whether enabling redact log
 	RedactInfoLog bool `toml:"redact-info-log" json:"redact-info-log"`
+	// TLSConfigName is the name the TLS config is registered by, which is
+	// unique for every config, so the tasks running at the same time don't
+	// replace each other's TLS config. It's "cluster" if not set.
+	TLSConfigName string `toml:"-" json:"-"`
+}
+
+// tlsConfigSeq makes the names of the registered TLS configs unique.
+var tlsConfigSeq uint64
+
+func (sec *Security) tlsConfigName() string {
+	if sec.TLSConfigName == "" {
+		return "cluster"
+	}
+	return sec.TLSConfigName
 }
 
-// RegistersMySQL registers (or deregisters) the TLS config with name "cluster"
-// for use in `sql.Open()`. This method is goroutine-safe.
+// RegistersMySQL registers (or deregisters) the TLS config with its name for
+// use in `sql.Open()`. This method is goroutine-safe.
 func (sec *Security) RegisterMySQL() error {
 	if sec == nil {
 		return nil
@@ -361,13 +475,21 @@ func (sec *Security) RegisterMySQL() error {
 		return errors.Trace(err)
 	case tlsConfig != nil:
 		// error happens only when the key coincides with the built-in names.
-		_ = gomysql.RegisterTLSConfig("cluster", tlsConfig)
+		_ = gomysql.RegisterTLSConfig(sec.tlsConfigName(), tlsConfig)
 	default:
-		gomysql.DeregisterTLSConfig("cluster")
+		gomysql.DeregisterTLSConfig(sec.tlsConfigName())
 	}
 	return nil
 }
 
+// DeregisterMySQL deregisters the TLS config registered by RegisterMySQL.
+func (sec *Security) DeregisterMySQL() {
+	if sec == nil {
+		return
+	}
+	gomysql.DeregisterTLSConfig(sec.tlsConfigName())
+}
+
 // A duration which can be deserialized from a TOML string.
 // Implemented as https://github.com/BurntSushi/toml#using-the-encodingtextunmarshaler-interface
 type Duration struct {
@@ -396,6 +518,7 @@ func NewConfig() *Config {
 			IndexConcurrency:  0,
 			IOConcurrency:     5,
 			CheckRequirements: true,
+			TableOrder:        TableOrderSource,
 		},
 		Checkpoint: Checkpoint{
 			Enable: true,
@@ -412,9 +535,10 @@ func NewConfig() *Config {
 			ChecksumTableConcurrency:   defaultChecksumTableConcurrency,
 		},
 		Cron: Cron{
-			SwitchMode:     Duration{Duration: 5 * time.Minute},
-			LogProgress:    Duration{Duration: 5 * time.Minute},
-			CheckDiskQuota: Duration{Duration: 1 * time.Minute},
+			SwitchMode:        Duration{Duration: 5 * time.Minute},
+			LogProgress:       Duration{Duration: 5 * time.Minute},
+			CheckDiskQuota:    Duration{Duration: 1 * time.Minute},
+			CheckSchemaChange: Duration{Duration: 1 * time.Minute},
 		},
 		Mydumper: MydumperRuntime{
 			ReadBlockSize: ReadBlockSize,
@@ -427,9 +551,11 @@ func NewConfig() *Config {
 				BackslashEscape: true,
 				TrimLastSep:     false,
 			},
-			StrictFormat:  false,
-			MaxRegionSize: MaxRegionSize,
-			Filter:        DefaultFilter,
+			StrictFormat:          false,
+			MaxRegionSize:         MaxRegionSize,
+			Filter:                DefaultFilter,
+			DataCharacterSet:      "binary",
+			DataCharsetValidation: DataCharsetValidationStrict,
 		},
 		TikvImporter: TikvImporter{
 			Backend:         "",
@@ -438,11 +564,13 @@ func NewConfig() *Config {
 			SendKVPairs:     32768,
 			RegionSplitSize: SplitRegionSize,
 			DiskQuota:       ByteSize(math.MaxInt64),
+			EngineTTL:       Duration{Duration: defaultEngineTTL},
 		},
 		PostRestore: PostRestore{
 			Checksum:          OpLevelRequired,
 			Analyze:           OpLevelOptional,
 			PostProcessAtLast: true,
+			SampleVerifyRows:  defaultSampleVerifyRows,
 		},
 	}
 }
@@ -465,6 +593,7 @@ func (cfg *Config) LoadFromGlobal(global *GlobalConfig) error {
 	cfg.TikvImporter.Addr = global.TikvImporter.Addr
 	cfg.TikvImporter.Backend = global.TikvImporter.Backend
 	cfg.TikvImporter.SortedKVDir = global.TikvImporter.SortedKVDir
+	cfg.TikvImporter.DuplicateDetectFailFast = global.TikvImporter.DuplicateDetectFailFast
 	cfg.Checkpoint.Enable = global.Checkpoint.Enable
 	cfg.PostRestore.Checksum = global.PostRestore.Checksum
 	cfg.PostRestore.Analyze = global.PostRestore.Analyze
@@ -491,6 +620,13 @@ func (cfg *Config) LoadFromTOML(data []byte) error {
 		return errors.Trace(err)
 	}
 
+	if cfg.definedKeys == nil {
+		cfg.definedKeys = make(map[string]struct{})
+	}
+	for _, key := range metaData.Keys() {
+		cfg.definedKeys[key.String()] = struct{}{}
+	}
+
 	unusedConfigKeys := metaData.Undecoded()
 	if len(unusedConfigKeys) == 0 {
 		return nil
@@ -534,8 +670,54 @@ func (cfg *Config) LoadFromTOML(data []byte) error {
 	return nil
 }
 
+// Validate reports the problems of the combinations of the config items, e.g.
+// an item ignored by the chosen backend. It's run before Adjust, so it sees
+// the items as the users set them.
+func (cfg *Config) Validate(v *validation.Validator) {
+	backend := strings.ToLower(cfg.TikvImporter.Backend)
+	if cfg.TikvImporter.DuplicateDetectFailFast && !cfg.TikvImporter.DuplicateDetection {
+		v.Warn([]string{"`tikv-importer.duplicate-detect-fail-fast`"},
+			"the duplicates aren't detected, so it never fails",
+			"set `tikv-importer.duplicate-detection = true` as well")
+	}
+	if cfg.Checkpoint.KeepAfterSuccess && !cfg.Checkpoint.Enable {
+		v.Warn([]string{"`checkpoint.keep-after-success`"},
+			"the checkpoints are disabled, so there is nothing to keep",
+			"set `checkpoint.enable = true` as well")
+	}
+	if cfg.TikvImporter.Addr != "" && backend != BackendImporter && !cfg.TikvImporter.MigrateImporter {
+		v.Warn([]string{"`tikv-importer.addr`", "`tikv-importer.backend`"},
+			fmt.Sprintf("the address of tikv-importer is ignored by the %s backend", backend),
+			"remove `tikv-importer.addr`")
+	}
+	if cfg.TikvImporter.DiskQuota != 0 && backend != BackendLocal && backend != BackendImporter {
+		v.Warn([]string{"`tikv-importer.disk-quota`", "`tikv-importer.backend`"},
+			fmt.Sprintf("nothing is sorted locally by the %s backend, so the disk quota is ignored", backend),
+			"remove `tikv-importer.disk-quota`")
+	}
+	sec := &cfg.Security
+	switch {
+	case sec.CertPath != "" && sec.KeyPath == "":
+		v.Error([]string{"`security.cert-path`"}, "the certificate is set without the private key",
+			"set `security.key-path` as well")
+	case sec.CertPath == "" && sec.KeyPath != "":
+		v.Error([]string{"`security.key-path`"}, "the private key is set without the certificate",
+			"set `security.cert-path` as well")
+	}
+}
+
 // Adjust fixes the invalid or unspecified settings to reasonable valid values.
 func (cfg *Config) Adjust(ctx context.Context) error {
+	v := &validation.Validator{}
+	cfg.Validate(v)
+	warnings := v.Warnings()
+	for i := range warnings {
+		log.L().Warn("problem in the config", zap.Stringer("problem", &warnings[i]))
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
 	// Reject problematic CSV configurations.
 	csv := &cfg.Mydumper.CSV
 	if len(csv.Separator) == 0 {
@@ -558,6 +740,38 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		}
 	}
 
+	if len(csv.NullIf) > 0 {
+		if csv.NotNull {
+			return errors.New("invalid config: `mydumper.csv.null-if` cannot be used when `mydumper.csv.not-null` is true")
+		}
+		nullIf := make(map[string][]string, len(csv.NullIf))
+		for column, tokens := range csv.NullIf {
+			nullIf[strings.ToLower(column)] = tokens
+		}
+		csv.NullIf = nullIf
+	}
+
+	if cfg.Mydumper.InferSchema && !csv.Header {
+		return errors.New("invalid config: `mydumper.infer-schema` requires `mydumper.csv.header` to be true")
+	}
+
+	cfg.Mydumper.DataCharacterSet = strings.ToLower(cfg.Mydumper.DataCharacterSet)
+	switch cfg.Mydumper.DataCharacterSet {
+	case "":
+		cfg.Mydumper.DataCharacterSet = "binary"
+	case "binary", "utf8mb4", "utf8", "gbk", "gb18030", "latin1":
+	default:
+		return errors.Errorf("invalid config: unsupported `mydumper.data-character-set` (%s)", cfg.Mydumper.DataCharacterSet)
+	}
+	cfg.Mydumper.DataCharsetValidation = strings.ToLower(cfg.Mydumper.DataCharsetValidation)
+	switch cfg.Mydumper.DataCharsetValidation {
+	case "":
+		cfg.Mydumper.DataCharsetValidation = DataCharsetValidationStrict
+	case DataCharsetValidationStrict, DataCharsetValidationReplace:
+	default:
+		return errors.Errorf("invalid config: unsupported `mydumper.data-charset-validation` (%s)", cfg.Mydumper.DataCharsetValidation)
+	}
+
 	// adjust file routing
 	for _, rule := range cfg.Mydumper.FileRouters {
 		if filepath.IsAbs(rule.Path) {
@@ -582,6 +796,14 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		return errors.New("tikv-importer.backend must not be empty!")
 	}
 	cfg.TikvImporter.Backend = strings.ToLower(cfg.TikvImporter.Backend)
+	if cfg.TikvImporter.Backend == BackendImporter {
+		if cfg.TikvImporter.MigrateImporter {
+			cfg.migrateImporterToLocal().Log()
+		} else {
+			log.L().Warn("the importer backend is deprecated, please switch to the local backend, " +
+				"or set `tikv-importer.migrate-importer = true` to migrate the config automatically")
+		}
+	}
 	mustHaveInternalConnections := true
 	switch cfg.TikvImporter.Backend {
 	case BackendTiDB:
@@ -589,17 +811,33 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		mustHaveInternalConnections = false
 		cfg.PostRestore.Checksum = OpLevelOff
 		cfg.PostRestore.Analyze = OpLevelOff
+		cfg.PostRestore.SampleVerify = OpLevelOff
 		cfg.TikvImporter.DuplicateDetection = false
-	case BackendImporter, BackendLocal:
+	case BackendImporter, BackendLocal, BackendNoop:
 		// RegionConcurrency > NumCPU is meaningless.
 		cpuCount := runtime.NumCPU()
 		if cfg.App.RegionConcurrency > cpuCount {
 			cfg.App.RegionConcurrency = cpuCount
 		}
 		cfg.DefaultVarsForImporterAndLocalBackend(ctx)
+		if cfg.TikvImporter.Backend == BackendNoop {
+			// nothing is written by the noop backend, so there is nothing to verify on the target cluster.
+			cfg.PostRestore.Checksum = OpLevelOff
+			cfg.PostRestore.Analyze = OpLevelOff
+			cfg.PostRestore.SampleVerify = OpLevelOff
+			cfg.TikvImporter.DuplicateDetection = false
+			// the checkpoints may be saved into the target, and a dry run has nothing to resume anyway.
+			cfg.Checkpoint.Enable = false
+		}
 	default:
 		return errors.Errorf("invalid config: unsupported `tikv-importer.backend` (%s)", cfg.TikvImporter.Backend)
 	}
+	if err := cfg.TikvImporter.GRPC.Validate(); err != nil {
+		return errors.Annotate(err, "invalid config: `tikv-importer.grpc`")
+	}
+	if err := cfg.TikvImporter.DuplicateGRPC.Validate(); err != nil {
+		return errors.Annotate(err, "invalid config: `tikv-importer.duplicate-grpc`")
+	}
 
 	// TODO calculate these from the machine's free memory.
 	if cfg.TikvImporter.EngineMemCacheSize == 0 {
@@ -608,6 +846,43 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 	if cfg.TikvImporter.LocalWriterMemCacheSize == 0 {
 		cfg.TikvImporter.LocalWriterMemCacheSize = defaultLocalWriterMemCacheSize
 	}
+	if cfg.TikvImporter.EngineTTL.Duration < 0 {
+		return errors.New("invalid config: `tikv-importer.engine-ttl` must not be negative")
+	}
+	if cfg.App.MaxError < 0 {
+		return errors.New("invalid config: `lightning.max-error` must not be negative")
+	}
+	if cfg.App.ChunkRetry < 0 {
+		return errors.New("invalid config: `lightning.chunk-retry` must not be negative")
+	}
+	if cfg.App.MemoryBudgetRatio < 0 || cfg.App.MemoryBudgetRatio > 1 {
+		return errors.New("invalid config: `lightning.memory-budget-ratio` must be between 0 and 1")
+	}
+	cfg.App.TableOrder = strings.ToLower(cfg.App.TableOrder)
+	switch cfg.App.TableOrder {
+	case "":
+		cfg.App.TableOrder = TableOrderSource
+	case TableOrderSource, TableOrderLargestFirst:
+	default:
+		return errors.Errorf("invalid config: unsupported `lightning.table-order` (%s)", cfg.App.TableOrder)
+	}
+	if err := checkTableDependencies(cfg.App.TableDependencies); err != nil {
+		return errors.Annotate(err, "invalid config: `lightning.table-dependencies`")
+	}
+	if cfg.App.MaxError > 0 && len(cfg.App.QuarantineDir) == 0 {
+		cfg.App.QuarantineDir = filepath.Join(os.TempDir(), "lightning_quarantine")
+	}
+	if cfg.PostRestore.SampleVerify != OpLevelOff && cfg.PostRestore.SampleVerifyRows <= 0 {
+		return errors.New("invalid config: `post-restore.sample-verify-rows` must be positive when sample verify is enabled")
+	}
+	if cfg.TikvImporter.PerTableIOLimit < 0 {
+		return errors.New("invalid config: `tikv-importer.per-table-io-limit` must not be negative")
+	}
+	if cfg.TikvImporter.RetryImportDelay.Duration < 0 || cfg.TikvImporter.WriteTimeout.Duration < 0 ||
+		cfg.TikvImporter.ImportMaxRetry < 0 || cfg.TikvImporter.WriteMaxRetry < 0 {
+		return errors.New("invalid config: `tikv-importer.retry-import-delay`, `tikv-importer.write-timeout`, " +
+			"`tikv-importer.import-max-retry` and `tikv-importer.write-max-retry` must not be negative")
+	}
 
 	if cfg.TikvImporter.Backend == BackendLocal {
 		if err := cfg.CheckAndAdjustForLocalBackend(); err != nil {
@@ -632,6 +907,24 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		return errors.Annotate(err, "invalid config: `mydumper.tidb.sql_mode` must be a valid SQL_MODE")
 	}
 
+	// the string values are decoded after being parsed, so the parser must
+	// never take the trail bytes of the multi-byte characters as the special
+	// characters, like '\' of "\x95\x5c" in GBK.
+	switch cfg.Mydumper.DataCharacterSet {
+	case "gbk", "gb18030":
+		if csv.BackslashEscape {
+			return errors.Errorf("invalid config: `mydumper.data-character-set` (%s) cannot be used when `mydumper.csv.backslash-escape` is true", cfg.Mydumper.DataCharacterSet)
+		}
+		if !cfg.TiDB.SQLMode.HasNoBackslashEscapesMode() {
+			return errors.Errorf("invalid config: `mydumper.data-character-set` (%s) requires NO_BACKSLASH_ESCAPES in `tidb.sql-mode`", cfg.Mydumper.DataCharacterSet)
+		}
+		for _, token := range []string{csv.Separator, csv.Delimiter, csv.Terminator} {
+			if hasGBKTrailByte(token) {
+				return errors.Errorf("invalid config: cannot use %q in CSV when `mydumper.data-character-set` is %s", token, cfg.Mydumper.DataCharacterSet)
+			}
+		}
+	}
+
 	if err := cfg.CheckAndAdjustSecurity(); err != nil {
 		return err
 	}
@@ -656,11 +949,25 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 	if err := cfg.CheckAndAdjustTiDBPort(ctx, mustHaveInternalConnections); err != nil {
 		return err
 	}
+	if cfg.App.AutoTune {
+		cfg.autoTune(ctx)
+	}
 	cfg.AdjustMydumper()
 	cfg.AdjustCheckPoint()
 	return cfg.CheckAndAdjustFilePath()
 }
 
+// hasGBKTrailByte reports whether s contains a byte which may be the trail
+// byte of a multi-byte character in GBK or GB18030.
+func hasGBKTrailByte(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x40 {
+			return true
+		}
+	}
+	return false
+}
+
 func (cfg *Config) CheckAndAdjustForLocalBackend() error {
 	if len(cfg.TikvImporter.SortedKVDir) == 0 {
 		return errors.Errorf("tikv-importer.sorted-kv-dir must not be empty!")
@@ -699,8 +1006,7 @@ func (cfg *Config) adjustDistSQLConcurrency(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	result := &api.StoresInfo{}
-	err = tls.WithHost(cfg.TiDB.PdAddr).GetJSON(ctx, pdStores, result)
+	result, err := pdutil.NewHTTPClientFromTLS(tls.WithHost(cfg.TiDB.PdAddr)).GetStores(ctx)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -714,15 +1020,8 @@ func (cfg *Config) adjustDistSQLConcurrency(ctx context.Context) error {
 
 func (cfg *Config) DefaultVarsForImporterAndLocalBackend(ctx context.Context) {
 	if cfg.TiDB.DistSQLScanConcurrency == defaultDistSQLScanConcurrency {
-		var e error
-		for i := 0; i < maxRetryTimes; i++ {
-			e = cfg.adjustDistSQLConcurrency(ctx)
-			if e == nil {
-				break
-			}
-			time.Sleep(defaultRetryBackoffTime)
-		}
-		if e != nil {
+		// the request to PD is retried by the client.
+		if e := cfg.adjustDistSQLConcurrency(ctx); e != nil {
 			log.L().Error("failed to adjust scan concurrency", zap.Error(e))
 		}
 	}
@@ -850,7 +1149,7 @@ func (cfg *Config) AdjustCheckPoint() {
 				Password:         cfg.TiDB.Psw,
 				SQLMode:          mysql.DefaultSQLMode,
 				MaxAllowedPacket: defaultMaxAllowedPacket,
-				TLS:              cfg.TiDB.TLS,
+				TLS:              cfg.TiDB.TLSParam(),
 			}
 			cfg.Checkpoint.DSN = param.ToDSN()
 		case CheckpointDriverFile:
@@ -903,6 +1202,9 @@ func (cfg *Config) CheckAndAdjustSecurity() error {
 	default:
 		return errors.Errorf("invalid config: unsupported `tidb.tls` config %s", cfg.TiDB.TLS)
 	}
+	if cfg.TiDB.TLS == "cluster" && cfg.TiDB.Security.TLSConfigName == "" {
+		cfg.TiDB.Security.TLSConfigName = fmt.Sprintf("cluster-%d", atomic.AddUint64(&tlsConfigSeq, 1))
+	}
 	return nil
 }
 
@@ -911,3 +1213,67 @@ func (cfg *Config) CheckAndAdjustSecurity() error {
 func (cfg *Config) HasLegacyBlackWhiteList() bool {
 	return len(cfg.BWList.DoTables) != 0 || len(cfg.BWList.DoDBs) != 0 || len(cfg.BWList.IgnoreTables) != 0 || len(cfg.BWList.IgnoreDBs) != 0
 }
+
+// SplitTableName splits a table name of `lightning.table-dependencies`,
+// "schema.table", at the first dot.
+func SplitTableName(name string) (schema, table string, err error) {
+	i := strings.IndexByte(name, '.')
+	if i <= 0 || i == len(name)-1 {
+		return "", "", errors.Errorf("table name %q is not in the form of \"schema.table\"", name)
+	}
+	return name[:i], name[i+1:], nil
+}
+
+// checkTableDependencies rejects the table names not in the form of
+// "schema.table", and the tables depending on themselves through others.
+// The names are compared case-insensitively.
+func checkTableDependencies(deps map[string][]string) error {
+	graph := make(map[string][]string, len(deps))
+	for name, before := range deps {
+		if _, _, err := SplitTableName(name); err != nil {
+			return err
+		}
+		key := strings.ToLower(name)
+		for _, dep := range before {
+			if _, _, err := SplitTableName(dep); err != nil {
+				return err
+			}
+			graph[key] = append(graph[key], strings.ToLower(dep))
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int, len(graph))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch states[name] {
+		case visiting:
+			return errors.Errorf("table %s depends on itself", name)
+		case visited:
+			return nil
+		}
+		states[name] = visiting
+		for _, dep := range graph[name] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		states[name] = visited
+		return nil
+	}
+	names := make([]string, 0, len(graph))
+	for name := range graph {
+		names = append(names, name)
+	}
+	// check in order for a deterministic error.
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -29,10 +30,12 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/docker/go-units"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/parser/mysql"
 
 	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/validation"
 )
 
 func Test(t *testing.T) {
@@ -243,6 +246,23 @@ func (s *configTestSuite) TestAdjustWillBatchImportRatioInvalid(c *C) {
 	c.Assert(cfg.Mydumper.BatchImportRatio, Equals, 0.75)
 }
 
+func (s *configTestSuite) TestAdjustGRPC(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TiDB.DistSQLScanConcurrency = 1
+	cfg.TikvImporter.GRPC.Compression = "snappy"
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `tikv-importer.grpc`.*")
+
+	cfg.TikvImporter.GRPC.Compression = "gzip"
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+
+	cfg.TikvImporter.DuplicateGRPC.MaxConcurrentStreams = -1
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `tikv-importer.duplicate-grpc`.*")
+}
+
 func (s *configTestSuite) TestAdjustSecuritySection(c *C) {
 	testCases := []struct {
 		input       string
@@ -309,6 +329,7 @@ func (s *configTestSuite) TestAdjustSecuritySection(c *C) {
 		},
 	}
 
+	tlsParams := make(map[string]bool)
 	for _, tc := range testCases {
 		comment := Commentf("input = %s", tc.input)
 
@@ -322,6 +343,14 @@ func (s *configTestSuite) TestAdjustSecuritySection(c *C) {
 		c.Assert(err, IsNil, comment)
 		c.Assert(cfg.TiDB.Security.CAPath, Equals, tc.expectedCA, comment)
 		c.Assert(cfg.TiDB.TLS, Equals, tc.expectedTLS, comment)
+		if tc.expectedTLS == "cluster" {
+			// every config registers the TLS config by its own name.
+			c.Assert(cfg.TiDB.TLSParam(), Matches, `cluster-\d+`, comment)
+			c.Assert(tlsParams[cfg.TiDB.TLSParam()], IsFalse, comment)
+			tlsParams[cfg.TiDB.TLSParam()] = true
+		} else {
+			c.Assert(cfg.TiDB.TLSParam(), Equals, tc.expectedTLS, comment)
+		}
 	}
 }
 
@@ -422,6 +451,22 @@ func (s *configTestSuite) TestInvalidCSV(c *C) {
 			`,
 			err: "invalid config: cannot use '\\' as CSV delimiter when `mydumper.csv.backslash-escape` is true",
 		},
+		{
+			input: `
+				[mydumper.csv]
+				not-null = true
+				[mydumper.csv.null-if]
+				a = ["", "N/A"]
+			`,
+			err: "invalid config: `mydumper.csv.null-if` cannot be used when `mydumper.csv.not-null` is true",
+		},
+		{
+			input: `
+				[mydumper.csv.null-if]
+				a = ["", "N/A"]
+			`,
+			err: "",
+		},
 		{
 			input: `
 				[tidb]
@@ -536,6 +581,7 @@ func (s *configTestSuite) TestLoadConfig(c *C) {
 		"-d", path,
 		"-backend", config.BackendLocal,
 		"-sorted-kv-dir", ".",
+		"-duplicate-detect-fail-fast",
 		"-checksum=false",
 	}, nil)
 	c.Assert(err, IsNil)
@@ -549,6 +595,7 @@ func (s *configTestSuite) TestLoadConfig(c *C) {
 	c.Assert(cfg.Mydumper.SourceDir, Equals, path)
 	c.Assert(cfg.TikvImporter.Backend, Equals, config.BackendLocal)
 	c.Assert(cfg.TikvImporter.SortedKVDir, Equals, ".")
+	c.Assert(cfg.TikvImporter.DuplicateDetectFailFast, IsTrue)
 	c.Assert(cfg.PostRestore.Checksum, Equals, config.OpLevelOff)
 	c.Assert(cfg.PostRestore.Analyze, Equals, config.OpLevelOptional)
 
@@ -557,6 +604,7 @@ func (s *configTestSuite) TestLoadConfig(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(taskCfg.PostRestore.Checksum, Equals, config.OpLevelOff)
 	c.Assert(taskCfg.PostRestore.Analyze, Equals, config.OpLevelOptional)
+	c.Assert(taskCfg.TikvImporter.DuplicateDetectFailFast, IsTrue)
 
 	taskCfg.Checkpoint.DSN = ""
 	taskCfg.Checkpoint.Driver = config.CheckpointDriverMySQL
@@ -569,6 +617,26 @@ func (s *configTestSuite) TestLoadConfig(c *C) {
 	c.Assert(result, Matches, `.*"pd-addr":"172.16.30.11:2379,172.16.30.12:2379".*`)
 }
 
+func (s *configTestSuite) TestLimitTask(c *C) {
+	global := config.NewGlobalConfig()
+	task := config.NewConfig()
+	task.App.TableConcurrency = 6
+	task.App.IndexConcurrency = 2
+	task.TikvImporter.DiskQuota = 100 * config.ByteSize(units.GiB)
+
+	global.App.LimitTask(task)
+	c.Assert(task.App.TableConcurrency, Equals, 6)
+	c.Assert(task.App.IndexConcurrency, Equals, 2)
+	c.Assert(task.TikvImporter.DiskQuota, Equals, 100*config.ByteSize(units.GiB))
+
+	global.App.TaskMaxEngines = 4
+	global.App.TaskMaxDiskQuota = 10 * config.ByteSize(units.GiB)
+	global.App.LimitTask(task)
+	c.Assert(task.App.TableConcurrency, Equals, 4)
+	c.Assert(task.App.IndexConcurrency, Equals, 2)
+	c.Assert(task.TikvImporter.DiskQuota, Equals, 10*config.ByteSize(units.GiB))
+}
+
 func (s *configTestSuite) TestDefaultImporterBackendValue(c *C) {
 	cfg := config.NewConfig()
 	assignMinimalLegalValue(cfg)
@@ -591,6 +659,213 @@ func (s *configTestSuite) TestDefaultTidbBackendValue(c *C) {
 	c.Assert(cfg.App.TableConcurrency, Equals, 123)
 }
 
+func (s *configTestSuite) TestDefaultNoopBackendValue(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = "Noop"
+	cfg.TiDB.DistSQLScanConcurrency = 1
+	cfg.PostRestore.Checksum = config.OpLevelRequired
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.TikvImporter.Backend, Equals, config.BackendNoop)
+	c.Assert(cfg.App.IndexConcurrency, Equals, 2)
+	c.Assert(cfg.PostRestore.Checksum, Equals, config.OpLevelOff)
+	c.Assert(cfg.PostRestore.Analyze, Equals, config.OpLevelOff)
+	c.Assert(cfg.Checkpoint.Enable, IsFalse)
+}
+
+func (s *configTestSuite) TestAdjustInferSchema(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.Mydumper.InferSchema = true
+	cfg.Mydumper.CSV.Header = false
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `mydumper.infer-schema` requires `mydumper.csv.header` to be true")
+
+	cfg.Mydumper.CSV.Header = true
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+}
+
+func (s *configTestSuite) TestAutoTune(c *C) {
+	ts, host, port := startMockServer(c, http.StatusOK, `{"count":12,"stores":[{},{},{},{},{},{},{},{},{},{},{},{}]}`)
+	defer ts.Close()
+
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	err := cfg.LoadFromTOML([]byte(`
+		[lightning]
+		auto-tune = true
+		index-concurrency = 3
+	`))
+	c.Assert(err, IsNil)
+	cfg.TiDB.PdAddr = fmt.Sprintf("%s:%d", host, port)
+	cfg.TikvImporter.SortedKVDir = c.MkDir()
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.App.TableConcurrency, Equals, 24)
+	c.Assert(cfg.App.IndexConcurrency, Equals, 3)
+	c.Assert(cfg.App.RegionConcurrency, Greater, 0)
+	c.Assert(cfg.App.IOConcurrency, Greater, 0)
+
+	// nothing is tuned without auto-tune.
+	cfg = config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TiDB.PdAddr = fmt.Sprintf("%s:%d", host, port)
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.App.TableConcurrency, Equals, 6)
+	c.Assert(cfg.App.IndexConcurrency, Equals, 2)
+	c.Assert(cfg.App.IOConcurrency, Equals, 5)
+}
+
+func (s *configTestSuite) TestAdjustDataCharacterSet(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.Mydumper.DataCharacterSet = ""
+	cfg.Mydumper.DataCharsetValidation = ""
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Mydumper.DataCharacterSet, Equals, "binary")
+	c.Assert(cfg.Mydumper.DataCharsetValidation, Equals, config.DataCharsetValidationStrict)
+
+	cfg.Mydumper.DataCharacterSet = "GBK"
+	cfg.Mydumper.DataCharsetValidation = "Replace"
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `mydumper.data-character-set` \\(gbk\\) cannot be used when `mydumper.csv.backslash-escape` is true")
+	cfg.Mydumper.CSV.BackslashEscape = false
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `mydumper.data-character-set` \\(gbk\\) requires NO_BACKSLASH_ESCAPES in `tidb.sql-mode`")
+	cfg.TiDB.StrSQLMode = "NO_BACKSLASH_ESCAPES"
+	cfg.Mydumper.CSV.Separator = "|"
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, `invalid config: cannot use "\|" in CSV when .* is gbk`)
+	cfg.Mydumper.CSV.Separator = ","
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Mydumper.DataCharacterSet, Equals, "gbk")
+	c.Assert(cfg.Mydumper.DataCharsetValidation, Equals, config.DataCharsetValidationReplace)
+
+	cfg.Mydumper.DataCharacterSet = "ascii"
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: unsupported `mydumper.data-character-set` \\(ascii\\)")
+
+	cfg.Mydumper.DataCharacterSet = "latin1"
+	cfg.Mydumper.DataCharsetValidation = "ignore"
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: unsupported `mydumper.data-charset-validation` \\(ignore\\)")
+}
+
+func (s *configTestSuite) TestAdjustMaxError(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.App.MaxError = -1
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `lightning.max-error` must not be negative")
+
+	cfg.App.MaxError = 100
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.App.QuarantineDir, Equals, filepath.Join(os.TempDir(), "lightning_quarantine"))
+
+	cfg.App.QuarantineDir = "/data/quarantine"
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.App.QuarantineDir, Equals, "/data/quarantine")
+}
+
+func (s *configTestSuite) TestAdjustChunkRetry(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.App.ChunkRetry = -1
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `lightning.chunk-retry` must not be negative")
+
+	cfg.App.ChunkRetry = 3
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+}
+
+func (s *configTestSuite) TestAdjustMemoryBudgetRatio(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.App.MemoryBudgetRatio = 1.5
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `lightning.memory-budget-ratio` must be between 0 and 1")
+
+	cfg.App.MemoryBudgetRatio = 0.6
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+}
+
+func (s *configTestSuite) TestAdjustTableOrder(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.App.TableOrder = "Largest-First"
+	cfg.App.TableDependencies = map[string][]string{
+		"db.orders":   {"db.customers", "db.items"},
+		"db.items":    {"db.vendors"},
+		"db.payments": {"db.orders"},
+	}
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.App.TableOrder, Equals, config.TableOrderLargestFirst)
+
+	cfg.App.TableOrder = "smallest-first"
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: unsupported `lightning.table-order` \\(smallest-first\\)")
+
+	cfg.App.TableOrder = ""
+	cfg.App.TableDependencies["db.vendors"] = []string{"DB.Payments"}
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `lightning.table-dependencies`: table db.items depends on itself")
+
+	cfg.App.TableDependencies = map[string][]string{"orders": {"db.customers"}}
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "invalid config: `lightning.table-dependencies`: table name \"orders\" is not in the form of \"schema.table\"")
+}
+
+func (s *configTestSuite) TestMigrateImporter(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = "Importer"
+	cfg.TikvImporter.Addr = "127.0.0.1:8287"
+	cfg.TikvImporter.SortedKVDir = ""
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.TikvImporter.Backend, Equals, config.BackendImporter)
+	c.Assert(cfg.TikvImporter.Addr, Equals, "127.0.0.1:8287")
+
+	cfg = config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = "Importer"
+	cfg.TikvImporter.Addr = "127.0.0.1:8287"
+	cfg.TikvImporter.SortedKVDir = ""
+	cfg.TikvImporter.MigrateImporter = true
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.TikvImporter.Backend, Equals, config.BackendLocal)
+	c.Assert(cfg.TikvImporter.Addr, Equals, "")
+	c.Assert(cfg.TikvImporter.SortedKVDir, Equals, filepath.Join(os.TempDir(), "lightning_sorted_kv"))
+
+	sortedKVDir := c.MkDir()
+	cfg = config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendImporter
+	cfg.TikvImporter.SortedKVDir = sortedKVDir
+	cfg.TikvImporter.MigrateImporter = true
+	err = cfg.Adjust(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg.TikvImporter.Backend, Equals, config.BackendLocal)
+	c.Assert(cfg.TikvImporter.SortedKVDir, Equals, sortedKVDir)
+}
+
 func (s *configTestSuite) TestDefaultCouldBeOverwritten(c *C) {
 	cfg := config.NewConfig()
 	assignMinimalLegalValue(cfg)
@@ -710,3 +985,22 @@ func (s *configTestSuite) TestAdjustDiskQuota(c *C) {
 	c.Assert(cfg.Adjust(ctx), IsNil)
 	c.Assert(int64(cfg.TikvImporter.DiskQuota), Equals, int64(0))
 }
+
+func (s *configTestSuite) TestValidate(c *C) {
+	cfg := config.NewConfig()
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.TikvImporter.Addr = "127.0.0.1:8287"
+	cfg.TikvImporter.DiskQuota = 1
+	cfg.TikvImporter.DuplicateDetectFailFast = true
+	cfg.Security.KeyPath = "/tmp/key.pem"
+
+	v := &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Warnings(), HasLen, 3)
+	c.Assert(v.Problems(), HasLen, 4)
+	c.Assert(v.Err(), ErrorMatches, "(?s)found 4 problems in the config.*`security.key-path`: the private key is set without the certificate.*")
+
+	// the errors are reported as a whole before adjusting the other items.
+	err := cfg.Adjust(context.Background())
+	c.Assert(err, ErrorMatches, "(?s)found 4 problems in the config.*")
+}
@@ -20,8 +20,9 @@ import (
 	"time"
 )
 
-// List is a goroutine-safe FIFO list of *Config, which supports removal
-// from the middle. The list is not expected to be very long.
+// List is a goroutine-safe list of *Config ordered by the task priority, in
+// which the tasks of the same priority are FIFO. It supports removal from the
+// middle. The list is not expected to be very long.
 type List struct {
 	cond      *sync.Cond
 	taskIDMap map[int64]*list.Element
@@ -43,8 +44,9 @@ func NewConfigList() *List {
 	}
 }
 
-// Push adds a configuration to the end of the list. The field `cfg.TaskID` will
-// be modified to include a unique ID to identify this task.
+// Push adds a configuration after all tasks whose priority is not lower than
+// `cfg.App.Priority`. The field `cfg.TaskID` will be modified to include a
+// unique ID to identify this task.
 func (cl *List) Push(cfg *Config) {
 	id := time.Now().UnixNano()
 	cl.cond.L.Lock()
@@ -54,7 +56,15 @@ func (cl *List) Push(cfg *Config) {
 	}
 	cfg.TaskID = id
 	cl.lastID = id
-	cl.taskIDMap[id] = cl.nodes.PushBack(cfg)
+	element := cl.nodes.Back()
+	for element != nil && element.Value.(*Config).App.Priority < cfg.App.Priority {
+		element = element.Prev()
+	}
+	if element == nil {
+		cl.taskIDMap[id] = cl.nodes.PushFront(cfg)
+	} else {
+		cl.taskIDMap[id] = cl.nodes.InsertAfter(cfg, element)
+	}
 	cl.cond.Broadcast()
 }
 
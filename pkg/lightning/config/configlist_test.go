@@ -131,3 +131,24 @@ func (s *configListTestSuite) TestMoveFrontBack(c *C) {
 	c.Assert(cl.MoveToBack(123456), IsFalse)
 	c.Assert(cl.AllIDs(), DeepEquals, []int64{cfg1.TaskID, cfg3.TaskID, cfg2.TaskID})
 }
+
+func (s *configListTestSuite) TestPushByPriority(c *C) {
+	cl := config.NewConfigList()
+
+	cfg1 := &config.Config{App: config.Lightning{Priority: 0}}
+	cl.Push(cfg1)
+	cfg2 := &config.Config{App: config.Lightning{Priority: 10}}
+	cl.Push(cfg2)
+	cfg3 := &config.Config{App: config.Lightning{Priority: 0}}
+	cl.Push(cfg3)
+	cfg4 := &config.Config{App: config.Lightning{Priority: 10}}
+	cl.Push(cfg4)
+	cfg5 := &config.Config{App: config.Lightning{Priority: -1}}
+	cl.Push(cfg5)
+
+	c.Assert(cl.AllIDs(), DeepEquals, []int64{cfg2.TaskID, cfg4.TaskID, cfg1.TaskID, cfg3.TaskID, cfg5.TaskID})
+
+	cfg, err := cl.Pop(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cfg, Equals, cfg2)
+}
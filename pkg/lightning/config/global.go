@@ -33,6 +33,19 @@ type GlobalLightning struct {
 	StatusAddr        string `toml:"status-addr" json:"status-addr"`
 	ServerMode        bool   `toml:"server-mode" json:"server-mode"`
 	CheckRequirements bool   `toml:"check-requirements" json:"check-requirements"`
+	// ValidateOnly reports the problems of the config and exits without
+	// importing.
+	ValidateOnly bool `toml:"-" json:"-"`
+
+	// MaxConcurrentTasks is the number of tasks run in parallel in the server
+	// mode.
+	MaxConcurrentTasks int `toml:"max-concurrent-tasks" json:"max-concurrent-tasks"`
+	// TaskMaxEngines and TaskMaxDiskQuota limit the resources of every task
+	// submitted in the server mode. TaskMaxEngines caps the table-concurrency
+	// and index-concurrency, i.e. the data and index engines opened at the same
+	// time, and TaskMaxDiskQuota caps the disk-quota. Zero means unlimited.
+	TaskMaxEngines   int      `toml:"task-max-engines" json:"task-max-engines"`
+	TaskMaxDiskQuota ByteSize `toml:"task-max-disk-quota" json:"task-max-disk-quota"`
 
 	// The legacy alias for setting "status-addr". The value should always the
 	// same as StatusAddr, and will not be published in the JSON encoding.
@@ -61,6 +74,8 @@ type GlobalImporter struct {
 	Addr        string `toml:"addr" json:"addr"`
 	Backend     string `toml:"backend" json:"backend"`
 	SortedKVDir string `toml:"sorted-kv-dir" json:"sorted-kv-dir"`
+
+	DuplicateDetectFailFast bool `toml:"duplicate-detect-fail-fast" json:"duplicate-detect-fail-fast"`
 }
 
 type GlobalConfig struct {
@@ -87,8 +102,9 @@ type GlobalPostRestore struct {
 func NewGlobalConfig() *GlobalConfig {
 	return &GlobalConfig{
 		App: GlobalLightning{
-			ServerMode:        false,
-			CheckRequirements: true,
+			ServerMode:         false,
+			CheckRequirements:  true,
+			MaxConcurrentTasks: 1,
 		},
 		Checkpoint: GlobalCheckpoint{
 			Enable: true,
@@ -126,6 +142,21 @@ func Must(cfg *GlobalConfig, err error) *GlobalConfig {
 	return cfg
 }
 
+// LimitTask caps the resources of the task to the limits of the server mode.
+func (cfg *GlobalLightning) LimitTask(task *Config) {
+	if cfg.TaskMaxEngines > 0 {
+		if task.App.TableConcurrency > cfg.TaskMaxEngines {
+			task.App.TableConcurrency = cfg.TaskMaxEngines
+		}
+		if task.App.IndexConcurrency > cfg.TaskMaxEngines {
+			task.App.IndexConcurrency = cfg.TaskMaxEngines
+		}
+	}
+	if cfg.TaskMaxDiskQuota > 0 && task.TikvImporter.DiskQuota > cfg.TaskMaxDiskQuota {
+		task.TikvImporter.DiskQuota = cfg.TaskMaxDiskQuota
+	}
+}
+
 func timestampLogFileName() string {
 	return filepath.Join(os.TempDir(), time.Now().Format("lightning.log.2006-01-02T15.04.05Z0700"))
 }
@@ -155,6 +186,8 @@ func LoadGlobalConfig(args []string, extraFlags func(*flag.FlagSet)) (*GlobalCon
 	importerAddr := fs.String("importer", "", "address (host:port) to connect to tikv-importer")
 	backend := flagext.ChoiceVar(fs, "backend", "", `delivery backend: local, importer, tidb`, "", "local", "importer", "tidb")
 	sortedKVDir := fs.String("sorted-kv-dir", "", "path for KV pairs when local backend enabled")
+	duplicateDetectFailFast := fs.Bool("duplicate-detect-fail-fast", false,
+		"fail the table once collecting the duplicated rows fails, instead of going on")
 	enableCheckpoint := fs.Bool("enable-checkpoint", true, "whether to enable checkpoints")
 	noSchema := fs.Bool("no-schema", false, "ignore schema files, get schema directly from TiDB instead")
 	checksum := flagext.ChoiceVar(fs, "checksum", "", "compare checksum after importing.", "", "required", "optional", "off", "true", "false")
@@ -167,6 +200,7 @@ func LoadGlobalConfig(args []string, extraFlags func(*flag.FlagSet)) (*GlobalCon
 
 	statusAddr := fs.String("status-addr", "", "the Lightning server address")
 	serverMode := fs.Bool("server-mode", false, "start Lightning in server mode, wait for multiple tasks instead of starting immediately")
+	validateOnly := fs.Bool("validate-only", false, "report all the problems of the config as a whole, and exit without importing")
 
 	var filter []string
 	flagext.StringsVar(fs, &filter, "f", "select tables to import")
@@ -236,12 +270,18 @@ func LoadGlobalConfig(args []string, extraFlags func(*flag.FlagSet)) (*GlobalCon
 	if *statusAddr != "" {
 		cfg.App.StatusAddr = *statusAddr
 	}
+	if *validateOnly {
+		cfg.App.ValidateOnly = true
+	}
 	if *backend != "" {
 		cfg.TikvImporter.Backend = *backend
 	}
 	if *sortedKVDir != "" {
 		cfg.TikvImporter.SortedKVDir = *sortedKVDir
 	}
+	if *duplicateDetectFailFast {
+		cfg.TikvImporter.DuplicateDetectFailFast = true
+	}
 	if !*enableCheckpoint {
 		cfg.Checkpoint.Enable = false
 	}
@@ -279,6 +319,9 @@ func LoadGlobalConfig(args []string, extraFlags func(*flag.FlagSet)) (*GlobalCon
 	if cfg.App.StatusAddr == "" && cfg.App.ServerMode {
 		return nil, errors.New("If server-mode is enabled, the status-addr must be a valid listen address")
 	}
+	if cfg.App.MaxConcurrentTasks < 1 {
+		return nil, errors.New("invalid config: `lightning.max-concurrent-tasks` must be positive")
+	}
 
 	cfg.App.Config.Adjust()
 	return cfg, nil
@@ -0,0 +1,64 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/log"
+)
+
+// defaultMigratedSortedKVDir is the name of the sorted-kv-dir, under the
+// temporary directory, used when migrating a config without one.
+const defaultMigratedSortedKVDir = "lightning_sorted_kv"
+
+// ImporterMigration is the report of migrating the config of the deprecated
+// "importer" backend to the "local" backend.
+type ImporterMigration struct {
+	// Translated lists the settings changed to their "local" counterparts.
+	Translated []string
+	// Dropped lists the settings having no counterpart, which are ignored.
+	Dropped []string
+}
+
+// migrateImporterToLocal switches the config from the "importer" backend to
+// the "local" backend, translating the settings where possible.
+func (cfg *Config) migrateImporterToLocal() *ImporterMigration {
+	m := &ImporterMigration{}
+	cfg.TikvImporter.Backend = BackendLocal
+	m.Translated = append(m.Translated, fmt.Sprintf("tikv-importer.backend: %q => %q", BackendImporter, BackendLocal))
+
+	if len(cfg.TikvImporter.SortedKVDir) == 0 {
+		// tikv-importer sorted the KV pairs in its own import-dir, which is
+		// on another host, so there is nothing to reuse.
+		cfg.TikvImporter.SortedKVDir = filepath.Join(os.TempDir(), defaultMigratedSortedKVDir)
+		m.Translated = append(m.Translated, fmt.Sprintf("tikv-importer.sorted-kv-dir: %q", cfg.TikvImporter.SortedKVDir))
+	}
+	if len(cfg.TikvImporter.Addr) != 0 {
+		m.Dropped = append(m.Dropped, fmt.Sprintf("tikv-importer.addr: %q, the local backend ingests into TiKV directly", cfg.TikvImporter.Addr))
+		cfg.TikvImporter.Addr = ""
+	}
+	return m
+}
+
+// Log writes the report into the log.
+func (m *ImporterMigration) Log() {
+	log.L().Warn("the importer backend is deprecated, the config has been migrated to the local backend, "+
+		"please update the config file accordingly",
+		zap.Strings("translated", m.Translated), zap.Strings("dropped", m.Dropped))
+}
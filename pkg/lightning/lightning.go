@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"net/http/pprof"
@@ -45,6 +46,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/mydump"
 	"github.com/pingcap/br/pkg/lightning/restore"
 	"github.com/pingcap/br/pkg/lightning/web"
+	"github.com/pingcap/br/pkg/pdutil"
 	"github.com/pingcap/br/pkg/redact"
 	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/utils"
@@ -63,8 +65,19 @@ type Lightning struct {
 	serverLock sync.Mutex
 
 	cancelLock sync.Mutex
-	curTask    *config.Config
-	cancel     context.CancelFunc // for per task context, which maybe different from lightning context
+	// running is the tasks being run by their task IDs. There may be several
+	// tasks running in the server mode.
+	running map[int64]*runningTask
+}
+
+type runningTask struct {
+	cfg    *config.Config
+	cancel context.CancelFunc // for per task context, which maybe different from lightning context
+	// pauser pauses the progress of the task only.
+	pauser *common.Pauser
+	// ioLimiter is the limiter of the local backend of the task, it's nil
+	// before the backend is created or for the other backends.
+	ioLimiter *local.TableIOLimiter
 }
 
 func initEnv(cfg *config.GlobalConfig) error {
@@ -90,6 +103,7 @@ func New(globalCfg *config.GlobalConfig) *Lightning {
 		globalTLS: tls,
 		ctx:       ctx,
 		shutdown:  shutdown,
+		running:   make(map[int64]*runningTask),
 	}
 }
 
@@ -140,9 +154,14 @@ func (l *Lightning) goServe(statusAddr string, realAddrWriter io.Writer) error {
 	mux.Handle("/tasks/", handleTasks)
 	mux.HandleFunc("/progress/task", handleProgressTask)
 	mux.HandleFunc("/progress/table", handleProgressTable)
-	mux.HandleFunc("/pause", handlePause)
-	mux.HandleFunc("/resume", handleResume)
+	mux.HandleFunc("/progress/engines", handleProgressEngines)
+	mux.HandleFunc("/progress/schedule", handleProgressSchedule)
+	mux.HandleFunc("/pause", l.handlePause)
+	mux.HandleFunc("/resume", l.handleResume)
 	mux.HandleFunc("/loglevel", handleLogLevel)
+	mux.HandleFunc("/io-limit", l.handleIOLimit)
+	mux.HandleFunc("/healthz", l.handleHealthz)
+	mux.HandleFunc("/readyz", l.handleReadyz)
 
 	mux.Handle("/web/", http.StripPrefix("/web", httpgzip.FileServer(web.Res, httpgzip.FileServerOptions{
 		IndexHTML: true,
@@ -174,7 +193,7 @@ func (l *Lightning) goServe(statusAddr string, realAddrWriter io.Writer) error {
 
 // RunOnce is used by binary lightning and host when using lightning as a library.
 // - for binary lightning, taskCtx could be context.Background which means taskCtx wouldn't be canceled directly by its
-//   cancel function, but only by Lightning.Stop or HTTP DELETE using l.cancel. and glue could be nil to let lightning
+//   cancel function, but only by Lightning.Stop or HTTP DELETE using the task cancel. and glue could be nil to let lightning
 //   use a default glue later.
 // - for lightning as a library, taskCtx could be a meaningful context that get canceled outside, and glue could be a
 //   caller implemented glue.
@@ -191,24 +210,72 @@ func (l *Lightning) RunOnce(taskCtx context.Context, taskCfg *config.Config, glu
 	return l.run(taskCtx, taskCfg, glue)
 }
 
+// RunServer runs the tasks posted to /tasks by their priorities, at most
+// `max-concurrent-tasks` at the same time.
 func (l *Lightning) RunServer() error {
 	l.taskCfgs = config.NewConfigList()
 	log.L().Info(
 		"Lightning server is running, post to /tasks to start an import task",
 		zap.Stringer("address", l.serverAddr),
+		zap.Int("maxConcurrentTasks", l.globalCfg.App.MaxConcurrentTasks),
 	)
 
+	slots := make(chan struct{}, utils.MaxInt(l.globalCfg.App.MaxConcurrentTasks, 1))
+	dirLocks := newSortedKVDirLocks()
+	var wg sync.WaitGroup
+	defer wg.Wait()
 	for {
+		// take a slot before popping, so the task of the highest priority at the
+		// time a slot is freed is run.
+		select {
+		case slots <- struct{}{}:
+		case <-l.ctx.Done():
+			return l.ctx.Err()
+		}
 		task, err := l.taskCfgs.Pop(l.ctx)
 		if err != nil {
 			return err
 		}
-		err = l.run(context.Background(), task, nil)
-		if err != nil {
-			restore.DeliverPauser.Pause() // force pause the progress on error
-			log.L().Error("tidb lightning encountered error", zap.Error(err))
-		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			// the local backends of the tasks sharing the sorted-kv-dir can't
+			// run at the same time, since the engines are cleaned up on close.
+			if task.TikvImporter.Backend == config.BackendLocal {
+				unlock := dirLocks.lock(task.TikvImporter.SortedKVDir)
+				defer unlock()
+			}
+			err := l.run(context.Background(), task, nil)
+			if err != nil {
+				log.L().Error("tidb lightning encountered error", zap.Int64("taskID", task.TaskID), zap.Error(err))
+			}
+		}()
+	}
+}
+
+// sortedKVDirLocks serializes the tasks using the same sorted-kv-dir.
+type sortedKVDirLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newSortedKVDirLocks() *sortedKVDirLocks {
+	return &sortedKVDirLocks{locks: make(map[string]*sync.Mutex)}
+}
+
+func (d *sortedKVDirLocks) lock(dir string) (unlock func()) {
+	d.mu.Lock()
+	lock, ok := d.locks[dir]
+	if !ok {
+		lock = new(sync.Mutex)
+		d.locks[dir] = lock
 	}
+	d.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock
 }
 
 var taskCfgRecorderKey struct{}
@@ -220,16 +287,16 @@ func (l *Lightning) run(taskCtx context.Context, taskCfg *config.Config, g glue.
 	utils.LogEnvVariables()
 
 	ctx, cancel := context.WithCancel(taskCtx)
+	task := &runningTask{cfg: taskCfg, cancel: cancel, pauser: common.NewPauser()}
 	l.cancelLock.Lock()
-	l.cancel = cancel
-	l.curTask = taskCfg
+	l.running[taskCfg.TaskID] = task
 	l.cancelLock.Unlock()
 	web.BroadcastStartTask()
 
 	defer func() {
 		cancel()
 		l.cancelLock.Lock()
-		l.cancel = nil
+		delete(l.running, taskCfg.TaskID)
 		l.cancelLock.Unlock()
 		web.BroadcastEndTask(err)
 	}()
@@ -245,19 +312,12 @@ func (l *Lightning) run(taskCtx context.Context, taskCfg *config.Config, g glue.
 		failpoint.Return(nil)
 	})
 
+	// the TLS config is registered by a name unique to the task, so the tasks
+	// running at the same time don't replace each other's.
 	if err := taskCfg.TiDB.Security.RegisterMySQL(); err != nil {
 		return err
 	}
-	defer func() {
-		// deregister TLS config with name "cluster"
-		if taskCfg.TiDB.Security == nil {
-			return
-		}
-		taskCfg.TiDB.Security.CAPath = ""
-		if err := taskCfg.TiDB.Security.RegisterMySQL(); err != nil {
-			log.L().Warn("failed to deregister TLS config", log.ShortError(err))
-		}
-	}()
+	defer taskCfg.TiDB.Security.DeregisterMySQL()
 
 	// initiation of default glue should be after RegisterMySQL, which is ready to be called after taskCfg.Adjust
 	// and also put it here could avoid injecting another two SkipRunTask failpoint to caller
@@ -301,12 +361,21 @@ func (l *Lightning) run(taskCtx context.Context, taskCfg *config.Config, g glue.
 	web.BroadcastInitProgress(dbMetas)
 
 	var procedure *restore.Controller
-	procedure, err = restore.NewRestoreController(ctx, dbMetas, taskCfg, s, g)
+	procedure, err = restore.NewRestoreControllerWithPauser(ctx, dbMetas, taskCfg, s, task.pauser, g)
 	if err != nil {
 		log.L().Error("restore failed", log.ShortError(err))
 		return errors.Trace(err)
 	}
-	defer procedure.Close()
+	defer func() {
+		// the task context is canceled on stopping the task, so the backend is
+		// closed with a fresh context, bounded in case it never completes.
+		closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		procedure.Close(closeCtx)
+	}()
+	l.cancelLock.Lock()
+	task.ioLimiter = procedure.IOLimiter()
+	l.cancelLock.Unlock()
 
 	err = procedure.Run(ctx)
 	return errors.Trace(err)
@@ -314,8 +383,10 @@ func (l *Lightning) run(taskCtx context.Context, taskCfg *config.Config, g glue.
 
 func (l *Lightning) Stop() {
 	l.cancelLock.Lock()
-	if l.cancel != nil {
-		l.cancel()
+	for _, task := range l.running {
+		if task.cancel != nil {
+			task.cancel()
+		}
 	}
 	l.cancelLock.Unlock()
 	if err := l.server.Shutdown(l.ctx); err != nil {
@@ -337,6 +408,16 @@ func writeJSONError(w http.ResponseWriter, code int, prefix string, err error) {
 	_ = json.NewEncoder(w).Encode(errorResponse{Error: prefix})
 }
 
+// parseTaskQuery returns the task ID given by the `task` query parameter, or
+// -1 if it's not given.
+func parseTaskQuery(req *http.Request) (int64, error) {
+	taskIDString := req.URL.Query().Get("task")
+	if taskIDString == "" {
+		return -1, nil
+	}
+	return strconv.ParseInt(taskIDString, 10, 64)
+}
+
 func parseTaskID(req *http.Request) (int64, string, error) {
 	path := strings.TrimPrefix(req.URL.Path, "/")
 	taskIDString := path
@@ -384,10 +465,27 @@ func (l *Lightning) handleTask(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// runningTaskIDs returns the IDs of the running tasks which aren't canceled, in
+// the order of submission.
+func (l *Lightning) runningTaskIDs() []int64 {
+	l.cancelLock.Lock()
+	defer l.cancelLock.Unlock()
+	ids := make([]int64, 0, len(l.running))
+	for id, task := range l.running {
+		if task.cancel != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 func (l *Lightning) handleGetTask(w http.ResponseWriter) {
 	var response struct {
-		Current   *int64  `json:"current"`
-		QueuedIDs []int64 `json:"queue"`
+		// Current is the earliest running task, kept for compatibility.
+		Current    *int64  `json:"current"`
+		RunningIDs []int64 `json:"running"`
+		QueuedIDs  []int64 `json:"queue"`
 	}
 
 	if l.taskCfgs != nil {
@@ -396,12 +494,10 @@ func (l *Lightning) handleGetTask(w http.ResponseWriter) {
 		response.QueuedIDs = []int64{}
 	}
 
-	l.cancelLock.Lock()
-	if l.cancel != nil && l.curTask != nil {
-		response.Current = new(int64)
-		*response.Current = l.curTask.TaskID
+	response.RunningIDs = l.runningTaskIDs()
+	if len(response.RunningIDs) > 0 {
+		response.Current = &response.RunningIDs[0]
 	}
-	l.cancelLock.Unlock()
 
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(response)
@@ -411,8 +507,8 @@ func (l *Lightning) handleGetOneTask(w http.ResponseWriter, req *http.Request, t
 	var task *config.Config
 
 	l.cancelLock.Lock()
-	if l.curTask != nil && l.curTask.TaskID == taskID {
-		task = l.curTask
+	if running, ok := l.running[taskID]; ok {
+		task = running.cfg
 	}
 	l.cancelLock.Unlock()
 
@@ -468,6 +564,7 @@ func (l *Lightning) handlePostTask(w http.ResponseWriter, req *http.Request) {
 		writeJSONError(w, http.StatusBadRequest, "invalid task configuration", err)
 		return
 	}
+	l.globalCfg.App.LimitTask(cfg)
 
 	l.taskCfgs.Push(cfg)
 	w.WriteHeader(http.StatusOK)
@@ -487,9 +584,9 @@ func (l *Lightning) handleDeleteOneTask(w http.ResponseWriter, req *http.Request
 	cancelSuccess := false
 
 	l.cancelLock.Lock()
-	if l.cancel != nil && l.curTask != nil && l.curTask.TaskID == taskID {
-		cancel = l.cancel
-		l.cancel = nil
+	if running, ok := l.running[taskID]; ok && running.cancel != nil {
+		cancel = running.cancel
+		running.cancel = nil
 	}
 	l.cancelLock.Unlock()
 
@@ -581,18 +678,97 @@ func handleProgressTable(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func handlePause(w http.ResponseWriter, req *http.Request) {
+func handleProgressEngines(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tableName := req.URL.Query().Get("t")
+	res, err := web.MarshalTableEngines(tableName)
+	if err == nil {
+		writeBytesCompressed(w, req, res)
+	} else {
+		if errors.IsNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_ = json.NewEncoder(w).Encode(err.Error())
+	}
+}
+
+func handleProgressSchedule(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	res, err := web.MarshalTableSchedule()
+	if err == nil {
+		writeBytesCompressed(w, req, res)
+	} else {
+		if errors.IsNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_ = json.NewEncoder(w).Encode(err.Error())
+	}
+}
+
+// pausers returns the pausers of the running tasks, or only that of the task
+// given by the `task` query parameter.
+func (l *Lightning) pausers(req *http.Request) (map[int64]*common.Pauser, error) {
+	taskID, err := parseTaskQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	l.cancelLock.Lock()
+	defer l.cancelLock.Unlock()
+	pausers := make(map[int64]*common.Pauser, len(l.running))
+	for id, task := range l.running {
+		if task.pauser != nil && (taskID < 0 || id == taskID) {
+			pausers[id] = task.pauser
+		}
+	}
+	return pausers, nil
+}
+
+// isPaused returns whether the progress of any running task is paused.
+func (l *Lightning) isPaused() bool {
+	l.cancelLock.Lock()
+	defer l.cancelLock.Unlock()
+	for _, task := range l.running {
+		if task.pauser != nil && task.pauser.IsPaused() {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePause gets or pauses the progress of the running tasks, or only that
+// of the task given by the `task` query parameter.
+func (l *Lightning) handlePause(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	switch req.Method {
 	case http.MethodGet:
+		pausers, err := l.pausers(req)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid task ID", err)
+			return
+		}
+		paused := false
+		for _, pauser := range pausers {
+			paused = paused || pauser.IsPaused()
+		}
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"paused":%v}`, restore.DeliverPauser.IsPaused())
+		fmt.Fprintf(w, `{"paused":%v}`, paused)
 
 	case http.MethodPut:
+		pausers, err := l.pausers(req)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid task ID", err)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
-		restore.DeliverPauser.Pause()
-		log.L().Info("progress paused")
+		for id, pauser := range pausers {
+			pauser.Pause()
+			log.L().Info("progress paused", zap.Int64("taskID", id))
+		}
 		_, _ = w.Write([]byte("{}"))
 
 	default:
@@ -601,14 +777,23 @@ func handlePause(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func handleResume(w http.ResponseWriter, req *http.Request) {
+// handleResume resumes the progress of the running tasks, or only that of the
+// task given by the `task` query parameter.
+func (l *Lightning) handleResume(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	switch req.Method {
 	case http.MethodPut:
+		pausers, err := l.pausers(req)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid task ID", err)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
-		restore.DeliverPauser.Resume()
-		log.L().Info("progress resumed")
+		for id, pauser := range pausers {
+			pauser.Resume()
+			log.L().Info("progress resumed", zap.Int64("taskID", id))
+		}
 		_, _ = w.Write([]byte("{}"))
 
 	default:
@@ -647,6 +832,195 @@ func handleLogLevel(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// ioLimiters returns the IO limiters of the running tasks using the local
+// backend, or only that of the task given by the `task` query parameter.
+func (l *Lightning) ioLimiters(req *http.Request) (map[int64]*local.TableIOLimiter, error) {
+	taskID, err := parseTaskQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	l.cancelLock.Lock()
+	defer l.cancelLock.Unlock()
+	limiters := make(map[int64]*local.TableIOLimiter, len(l.running))
+	for id, task := range l.running {
+		if task.ioLimiter != nil && (taskID < 0 || id == taskID) {
+			limiters[id] = task.ioLimiter
+		}
+	}
+	return limiters, nil
+}
+
+// handleIOLimit gets or changes the per-table IO limit of the running tasks.
+// Every task has its own limit. GET returns the limit of the task given by the
+// `task` query parameter, or that of the earliest task. PUT and POST change the
+// limit of the given task, or of all the running tasks.
+func (l *Lightning) handleIOLimit(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var ioLimit struct {
+		PerTableIOLimit config.ByteSize `json:"per-table-io-limit"`
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodPut, http.MethodPost:
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut+", "+http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "only GET, PUT and POST are allowed", nil)
+		return
+	}
+	limiters, err := l.ioLimiters(req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid task ID", err)
+		return
+	}
+	if len(limiters) == 0 {
+		writeJSONError(w, http.StatusNotFound, "no running task using the local backend", nil)
+		return
+	}
+
+	if req.Method == http.MethodGet {
+		var minTaskID int64 = math.MaxInt64
+		for id, limiter := range limiters {
+			if id < minTaskID {
+				minTaskID = id
+				ioLimit.PerTableIOLimit = config.ByteSize(limiter.Limit())
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(ioLimit)
+		return
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&ioLimit); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid io limit", err)
+		return
+	}
+	if ioLimit.PerTableIOLimit < 0 {
+		writeJSONError(w, http.StatusBadRequest, "io limit must not be negative", nil)
+		return
+	}
+	for id, limiter := range limiters {
+		oldLimit := limiter.Limit()
+		limiter.SetLimit(int64(ioLimit.PerTableIOLimit))
+		log.L().Info("changed per-table io limit", zap.Int64("taskID", id),
+			zap.Int64("old", oldLimit), zap.Int64("new", int64(ioLimit.PerTableIOLimit)))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("{}"))
+}
+
+const (
+	// readyzProbeTimeout is the timeout of checking whether the backend of the
+	// running task is reachable.
+	readyzProbeTimeout = 5 * time.Second
+	// readyzStallTimeout is the duration without any progress after which the
+	// running task is considered stalled. Importing a large engine may not
+	// update the checkpoints for a long time, so it's generous.
+	readyzStallTimeout = time.Hour
+	// closeTimeout is the max duration of closing the backend of a task, e.g.
+	// waiting for the local engines to be flushed and cleaned up.
+	closeTimeout = 5 * time.Minute
+)
+
+// handleHealthz is the liveness probe, which fails once Lightning is stopping.
+func (l *Lightning) handleHealthz(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	select {
+	case <-l.ctx.Done():
+		writeJSONError(w, http.StatusServiceUnavailable, "lightning is stopping", nil)
+	default:
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}
+}
+
+type readyzCheck struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// handleReadyz is the readiness probe. Lightning is ready if it can accept
+// tasks, and the running tasks, if any, can reach their backends, are making
+// progress and aren't blocked by the disk quota.
+func (l *Lightning) handleReadyz(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	l.cancelLock.Lock()
+	tasks := make([]*config.Config, 0, len(l.running))
+	for _, task := range l.running {
+		if task.cancel != nil {
+			tasks = append(tasks, task.cfg)
+		}
+	}
+	l.cancelLock.Unlock()
+
+	checks := make(map[string]readyzCheck, 4)
+	if l.taskCfgs == nil && len(tasks) == 0 {
+		checks["config"] = readyzCheck{Message: "no task config is loaded"}
+	} else {
+		checks["config"] = readyzCheck{OK: true}
+	}
+	if len(tasks) > 0 {
+		backend := readyzCheck{OK: true}
+		for _, task := range tasks {
+			if backend = l.checkBackendReachable(req.Context(), task); !backend.OK {
+				backend.Message = fmt.Sprintf("task %d: %s", task.TaskID, backend.Message)
+				break
+			}
+		}
+		checks["backend"] = backend
+
+		progress := readyzCheck{OK: true}
+		if l.isPaused() {
+			progress.Message = "paused"
+		} else if idle := time.Since(web.LastProgressTime()); idle > readyzStallTimeout {
+			progress = readyzCheck{Message: fmt.Sprintf("no progress in the last %s", idle.Round(time.Second))}
+		}
+		checks["progress"] = progress
+
+		if web.IsDiskQuotaExceeded() {
+			checks["disk-quota"] = readyzCheck{Message: "disk quota exceeded, writes are blocked until large engines are imported"}
+		} else {
+			checks["disk-quota"] = readyzCheck{OK: true}
+		}
+	}
+
+	response := struct {
+		Ready  bool                   `json:"ready"`
+		Checks map[string]readyzCheck `json:"checks"`
+	}{Ready: true, Checks: checks}
+	for _, check := range checks {
+		response.Ready = response.Ready && check.OK
+	}
+	if response.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// checkBackendReachable checks whether PD of the task can be reached. The
+// TiDB backend doesn't talk to PD, so it's always considered reachable.
+func (l *Lightning) checkBackendReachable(ctx context.Context, task *config.Config) readyzCheck {
+	if task.TikvImporter.Backend == config.BackendTiDB {
+		return readyzCheck{OK: true}
+	}
+	tls, err := task.ToTLS()
+	if err != nil {
+		return readyzCheck{Message: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyzProbeTimeout)
+	defer cancel()
+	if _, err := pdutil.NewHTTPClientFromTLS(tls.WithHost(task.TiDB.PdAddr)).GetVersion(ctx); err != nil {
+		return readyzCheck{Message: "PD is unreachable: " + err.Error()}
+	}
+	return readyzCheck{OK: true}
+}
+
 func checkSystemRequirement(cfg *config.Config, dbsMeta []*mydump.MDDatabaseMeta) error {
 	// in local mode, we need to read&write a lot of L0 sst files, so we need to check system max open files limit
 	if cfg.TikvImporter.Backend == config.BackendLocal {
@@ -37,7 +37,9 @@ import (
 	. "github.com/pingcap/check"
 	"github.com/pingcap/failpoint"
 
+	"github.com/pingcap/br/pkg/lightning/backend/local"
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/config"
 	"github.com/pingcap/br/pkg/lightning/glue"
 	"github.com/pingcap/br/pkg/lightning/mydump"
@@ -437,6 +439,172 @@ func (s *lightningServerSuite) TestHTTPAPIOutsideServerMode(c *C) {
 	c.Assert(<-errCh, Equals, context.Canceled)
 }
 
+func (s *lightningServerSuite) TestPauseTask(c *C) {
+	baseURL := "http://" + s.lightning.serverAddr.String()
+
+	// every task has its own pauser.
+	pauser1 := common.NewPauser()
+	pauser2 := common.NewPauser()
+	s.lightning.cancelLock.Lock()
+	s.lightning.running[1] = &runningTask{pauser: pauser1}
+	s.lightning.running[2] = &runningTask{pauser: pauser2}
+	s.lightning.cancelLock.Unlock()
+	defer func() {
+		s.lightning.cancelLock.Lock()
+		delete(s.lightning.running, 1)
+		delete(s.lightning.running, 2)
+		s.lightning.cancelLock.Unlock()
+	}()
+
+	put := func(path string) {
+		req, err := http.NewRequest(http.MethodPut, baseURL+path, nil)
+		c.Assert(err, IsNil)
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, IsNil)
+		c.Assert(resp.StatusCode, Equals, http.StatusOK)
+		resp.Body.Close()
+	}
+	isPaused := func(path string) bool {
+		var result struct {
+			Paused bool `json:"paused"`
+		}
+		resp, err := http.Get(baseURL + path)
+		c.Assert(err, IsNil)
+		c.Assert(resp.StatusCode, Equals, http.StatusOK)
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		c.Assert(err, IsNil)
+		return result.Paused
+	}
+
+	put("/pause?task=1")
+	c.Assert(pauser1.IsPaused(), IsTrue)
+	c.Assert(pauser2.IsPaused(), IsFalse)
+	c.Assert(isPaused("/pause"), IsTrue)
+	c.Assert(isPaused("/pause?task=2"), IsFalse)
+
+	put("/pause")
+	c.Assert(pauser2.IsPaused(), IsTrue)
+	put("/resume?task=2")
+	c.Assert(pauser1.IsPaused(), IsTrue)
+	c.Assert(pauser2.IsPaused(), IsFalse)
+	put("/resume")
+	c.Assert(isPaused("/pause"), IsFalse)
+}
+
+func (s *lightningServerSuite) TestIOLimit(c *C) {
+	url := "http://" + s.lightning.serverAddr.String() + "/io-limit"
+
+	// without any task using the local backend, there is no limit to change.
+	resp, err := http.Get(url)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+	resp.Body.Close()
+
+	// every task has its own limiter.
+	limiter1 := local.NewTableIOLimiter(0)
+	limiter2 := local.NewTableIOLimiter(4096)
+	s.lightning.cancelLock.Lock()
+	s.lightning.running[1] = &runningTask{ioLimiter: limiter1}
+	s.lightning.running[2] = &runningTask{ioLimiter: limiter2}
+	s.lightning.cancelLock.Unlock()
+	defer func() {
+		s.lightning.cancelLock.Lock()
+		delete(s.lightning.running, 1)
+		delete(s.lightning.running, 2)
+		s.lightning.cancelLock.Unlock()
+	}()
+
+	var ioLimit struct {
+		PerTableIOLimit int64 `json:"per-table-io-limit"`
+	}
+	resp, err = http.Get(url + "?task=2")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	err = json.NewDecoder(resp.Body).Decode(&ioLimit)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(ioLimit.PerTableIOLimit, Equals, int64(4096))
+
+	req, err := http.NewRequest(http.MethodPut, url+"?task=1", strings.NewReader(`{"per-table-io-limit": 1048576}`))
+	c.Assert(err, IsNil)
+	resp, err = http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp.Body.Close()
+	c.Assert(limiter1.Limit(), Equals, int64(1048576))
+	c.Assert(limiter2.Limit(), Equals, int64(4096))
+
+	// the limit of the earliest task is returned by default.
+	resp, err = http.Get(url)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	err = json.NewDecoder(resp.Body).Decode(&ioLimit)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(ioLimit.PerTableIOLimit, Equals, int64(1048576))
+
+	req, err = http.NewRequest(http.MethodPut, url, strings.NewReader(`{"per-table-io-limit": 2048}`))
+	c.Assert(err, IsNil)
+	resp, err = http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp.Body.Close()
+	c.Assert(limiter1.Limit(), Equals, int64(2048))
+	c.Assert(limiter2.Limit(), Equals, int64(2048))
+
+	req, err = http.NewRequest(http.MethodPut, url, strings.NewReader(`{"per-table-io-limit": -1}`))
+	c.Assert(err, IsNil)
+	resp, err = http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	resp.Body.Close()
+	c.Assert(limiter1.Limit(), Equals, int64(2048))
+
+	resp, err = http.Get(url + "?task=3")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+	resp.Body.Close()
+}
+
+func (s *lightningServerSuite) TestHealthProbes(c *C) {
+	addr := "http://" + s.lightning.serverAddr.String()
+
+	resp, err := http.Get(addr + "/healthz")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp.Body.Close()
+
+	type readyzResult struct {
+		Ready  bool `json:"ready"`
+		Checks map[string]struct {
+			OK      bool   `json:"ok"`
+			Message string `json:"message"`
+		} `json:"checks"`
+	}
+	var result readyzResult
+	// no task queue before the server runs.
+	resp, err = http.Get(addr + "/readyz")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusServiceUnavailable)
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(result.Ready, IsFalse)
+	c.Assert(result.Checks["config"].OK, IsFalse)
+
+	s.lightning.taskCfgs = config.NewConfigList()
+	result = readyzResult{}
+	resp, err = http.Get(addr + "/readyz")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(result.Ready, IsTrue)
+	c.Assert(result.Checks, HasLen, 1)
+}
+
 func (s *lightningServerSuite) TestCheckSystemRequirement(c *C) {
 	if runtime.GOOS == "windows" {
 		c.Skip("Local-backend is not supported on Windows")
@@ -0,0 +1,173 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package membudget shares a memory budget among the components of lightning
+// which buffer data, so that they don't size themselves independently and run
+// the host out of memory together.
+package membudget
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/memory"
+	"go.uber.org/atomic"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/pingcap/br/pkg/lightning/metric"
+)
+
+// Budget admission-controls the memory of the buffers, e.g. the encoded KV
+// batches waiting to be written. The caches sized once, e.g. the block cache
+// of the engines, reserve their share of it up front, and the buffers which
+// must not wait for the others, e.g. those of the writers, are admitted by a
+// share split from it.
+//
+// A nil Budget admits everything.
+type Budget struct {
+	limit    int64
+	sem      *semaphore.Weighted
+	reserved atomic.Int64
+	// used is the bytes acquired, it's shared with the budgets split from
+	// this one.
+	used *atomic.Int64
+}
+
+// New creates a Budget of limit bytes. It returns nil if limit isn't positive.
+func New(limit int64) *Budget {
+	if limit <= 0 {
+		return nil
+	}
+	metric.MemoryBudgetBytesGauge.WithLabelValues(metric.MemoryBudgetLimit).Set(float64(limit))
+	return &Budget{limit: limit, sem: semaphore.NewWeighted(limit), used: atomic.NewInt64(0)}
+}
+
+// FromHostMemory creates a Budget of the ratio of the RAM of the host, or of
+// the cgroup limit if lightning runs in a container. It returns nil if ratio
+// is 0.
+func FromHostMemory(ratio float64) (*Budget, error) {
+	if ratio <= 0 {
+		return nil, nil
+	}
+	total, err := memory.MemTotal()
+	if err != nil {
+		return nil, errors.Annotate(err, "failed to get the memory of the host")
+	}
+	return New(int64(float64(total) * ratio)), nil
+}
+
+// Limit returns the bytes of the budget, 0 if b is nil.
+func (b *Budget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// Reserved returns the bytes reserved by Reserve and Split.
+func (b *Budget) Reserved() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.reserved.Load()
+}
+
+// Used returns the bytes acquired from the budget and those split from it.
+func (b *Budget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.used.Load()
+}
+
+// Reserve takes the ratio of the budget for a cache sized once, and returns
+// the bytes reserved, 0 if b is nil. They are given back by Unreserve.
+func (b *Budget) Reserve(ratio float64) int64 {
+	if b == nil {
+		return 0
+	}
+	n := int64(float64(b.limit) * ratio)
+	if n <= 0 || !b.sem.TryAcquire(n) {
+		return 0
+	}
+	metric.MemoryBudgetBytesGauge.WithLabelValues(metric.MemoryBudgetReserved).Set(float64(b.reserved.Add(n)))
+	return n
+}
+
+// Split reserves the ratio of the budget as a separate Budget, so that what
+// it admits never waits for the rest of the budget. The bytes acquired from
+// it are reported as used by this one. It returns nil if b is nil, and the
+// share is given back by Unreserve(split.Limit()).
+func (b *Budget) Split(ratio float64) *Budget {
+	n := b.Reserve(ratio)
+	if n == 0 {
+		return nil
+	}
+	return &Budget{limit: n, sem: semaphore.NewWeighted(n), used: b.used}
+}
+
+// Unreserve gives back the bytes returned by Reserve.
+func (b *Budget) Unreserve(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.sem.Release(n)
+	metric.MemoryBudgetBytesGauge.WithLabelValues(metric.MemoryBudgetReserved).Set(float64(b.reserved.Sub(n)))
+}
+
+// Acquire blocks until n bytes are admitted or ctx is done. Requests larger
+// than the unreserved budget are cut to it so that they are admitted
+// eventually. It returns the bytes acquired, which must be given back by
+// Release.
+func (b *Budget) Acquire(ctx context.Context, n int64) (int64, error) {
+	if b == nil || n <= 0 {
+		return 0, nil
+	}
+	if unreserved := b.limit - b.reserved.Load(); n > unreserved {
+		n = unreserved
+	}
+	if n <= 0 {
+		return 0, nil
+	}
+	if err := b.sem.Acquire(ctx, n); err != nil {
+		return 0, errors.Trace(err)
+	}
+	b.addUsed(n)
+	return n, nil
+}
+
+// TryAcquire admits n bytes without blocking, and reports whether they are
+// admitted. A nil Budget admits them all.
+func (b *Budget) TryAcquire(n int64) bool {
+	if b == nil || n <= 0 {
+		return true
+	}
+	if !b.sem.TryAcquire(n) {
+		return false
+	}
+	b.addUsed(n)
+	return true
+}
+
+// Release gives back the bytes acquired.
+func (b *Budget) Release(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.sem.Release(n)
+	b.addUsed(-n)
+}
+
+func (b *Budget) addUsed(n int64) {
+	metric.MemoryBudgetBytesGauge.WithLabelValues(metric.MemoryBudgetUsed).Set(float64(b.used.Add(n)))
+}
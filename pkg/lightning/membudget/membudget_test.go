@@ -0,0 +1,107 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membudget_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/lightning/membudget"
+)
+
+func TestMemBudget(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testMemBudgetSuite{})
+
+type testMemBudgetSuite struct{}
+
+func (s *testMemBudgetSuite) TestNilBudget(c *C) {
+	c.Assert(membudget.New(0), IsNil)
+	b, err := membudget.FromHostMemory(0)
+	c.Assert(err, IsNil)
+	c.Assert(b, IsNil)
+
+	n, err := b.Acquire(context.Background(), 100)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(0))
+	c.Assert(b.TryAcquire(100), IsTrue)
+	c.Assert(b.Reserve(0.5), Equals, int64(0))
+	c.Assert(b.Split(0.5), IsNil)
+	b.Release(100)
+	b.Unreserve(100)
+	c.Assert(b.Limit(), Equals, int64(0))
+	c.Assert(b.Used(), Equals, int64(0))
+}
+
+func (s *testMemBudgetSuite) TestAcquire(c *C) {
+	ctx := context.Background()
+	b := membudget.New(100)
+	n, err := b.Acquire(ctx, 60)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(60))
+	c.Assert(b.TryAcquire(50), IsFalse)
+	c.Assert(b.TryAcquire(40), IsTrue)
+	c.Assert(b.Used(), Equals, int64(100))
+
+	// the acquire blocks until the bytes are released.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = b.Acquire(timeoutCtx, 1)
+	c.Assert(err, NotNil)
+
+	b.Release(100)
+	c.Assert(b.Used(), Equals, int64(0))
+
+	// the requests larger than the budget are cut to it.
+	n, err = b.Acquire(ctx, 1000)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(100))
+	b.Release(n)
+}
+
+func (s *testMemBudgetSuite) TestReserveAndSplit(c *C) {
+	ctx := context.Background()
+	b := membudget.New(100)
+	reserved := b.Reserve(0.25)
+	c.Assert(reserved, Equals, int64(25))
+	split := b.Split(0.25)
+	c.Assert(split.Limit(), Equals, int64(25))
+	c.Assert(b.Reserved(), Equals, int64(50))
+
+	// the requests are cut to the unreserved budget.
+	n, err := b.Acquire(ctx, 1000)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(50))
+
+	// the split share admits even if the rest of the budget is used up, and
+	// its bytes are reported by the parent.
+	c.Assert(split.TryAcquire(20), IsTrue)
+	c.Assert(split.TryAcquire(10), IsFalse)
+	c.Assert(b.Used(), Equals, int64(70))
+	split.Release(20)
+	b.Release(n)
+	c.Assert(b.Used(), Equals, int64(0))
+
+	// the reservation fails rather than waits if the budget is used up.
+	c.Assert(b.Reserve(0.6), Equals, int64(0))
+	b.Unreserve(reserved)
+	b.Unreserve(split.Limit())
+	c.Assert(b.Reserved(), Equals, int64(0))
+	c.Assert(b.TryAcquire(100), IsTrue)
+}
@@ -15,6 +15,7 @@ package metric
 
 import (
 	"math"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -43,6 +44,25 @@ const (
 
 	BlockDeliverKindIndex = "index"
 	BlockDeliverKindData  = "data"
+
+	// operations used for the EngineOperationSecondsHistogram labels
+	EngineOperationOpen   = "open"
+	EngineOperationWrite  = "write"
+	EngineOperationFlush  = "flush"
+	EngineOperationClose  = "close"
+	EngineOperationImport = "import"
+
+	// types used for the MemoryBudgetBytesGauge labels
+	MemoryBudgetLimit    = "limit"
+	MemoryBudgetReserved = "reserved"
+	MemoryBudgetUsed     = "used"
+
+	// EngineLabelOther is the engine label shared by all engines beyond the
+	// first MaxEngineLabels ones.
+	EngineLabelOther = "other"
+	// MaxEngineLabels is the number of distinct engine labels of the engine
+	// metrics, which caps the cardinality on importing many tables.
+	MaxEngineLabels = 64
 )
 
 var (
@@ -187,6 +207,22 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(1, 2.2679331552660544, 10),
 		},
 	)
+	EngineOperationSecondsHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "lightning",
+			Name:      "engine_operation_seconds",
+			Help:      "time needed to open, write into, flush, close or import an engine",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 14),
+		}, []string{"engine", "op"},
+	)
+	EngineWriteBytesHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "lightning",
+			Name:      "engine_write_bytes",
+			Help:      "number of bytes written into an engine at once",
+			Buckets:   prometheus.ExponentialBuckets(512, 2, 14),
+		}, []string{"engine"},
+	)
 
 	LocalStorageUsageBytesGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -195,6 +231,14 @@ var (
 			Help:      "disk/memory size currently occupied by intermediate files in local backend",
 		}, []string{"medium"},
 	)
+
+	MemoryBudgetBytesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "lightning",
+			Name:      "memory_budget_bytes",
+			Help:      "the limit of the memory budget, the bytes reserved by the caches and the bytes used by the buffers",
+		}, []string{"type"},
+	)
 )
 
 //nolint:gochecknoinits // TODO: refactor
@@ -217,7 +261,31 @@ func init() {
 	prometheus.MustRegister(ChecksumSecondsHistogram)
 	prometheus.MustRegister(ChunkParserReadBlockSecondsHistogram)
 	prometheus.MustRegister(ApplyWorkerSecondsHistogram)
+	prometheus.MustRegister(EngineOperationSecondsHistogram)
+	prometheus.MustRegister(EngineWriteBytesHistogram)
 	prometheus.MustRegister(LocalStorageUsageBytesGauge)
+	prometheus.MustRegister(MemoryBudgetBytesGauge)
+}
+
+var engineLabels = struct {
+	sync.Mutex
+	tags map[string]struct{}
+}{tags: make(map[string]struct{})}
+
+// EngineLabel returns the label of the engine metrics for the engine tag. The
+// first MaxEngineLabels engines are labelled by their tags, and the rest share
+// EngineLabelOther.
+func EngineLabel(tag string) string {
+	engineLabels.Lock()
+	defer engineLabels.Unlock()
+	if _, ok := engineLabels.tags[tag]; ok {
+		return tag
+	}
+	if len(engineLabels.tags) >= MaxEngineLabels {
+		return EngineLabelOther
+	}
+	engineLabels.tags[tag] = struct{}{}
+	return tag
 }
 
 func RecordTableCount(status string, err error) {
@@ -15,6 +15,7 @@ package metric_test
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	. "github.com/pingcap/check"
@@ -59,3 +60,12 @@ func (s *testMetricSuite) TestRecordEngineCount(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(metric.ReadCounter(failureCount), Equals, 1.0)
 }
+
+func (s *testMetricSuite) TestEngineLabel(c *C) {
+	for i := 0; i < metric.MaxEngineLabels; i++ {
+		tag := fmt.Sprintf("`db`.`t`:%d", i)
+		c.Assert(metric.EngineLabel(tag), Equals, tag)
+	}
+	c.Assert(metric.EngineLabel("`db`.`t`:-1"), Equals, metric.EngineLabelOther)
+	c.Assert(metric.EngineLabel("`db`.`t`:0"), Equals, "`db`.`t`:0")
+}
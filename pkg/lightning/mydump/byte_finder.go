@@ -0,0 +1,61 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import "bytes"
+
+// byteFinder finds the first of several bytes in a block by bytes.IndexByte,
+// which is vectorized like memchr, rather than testing the bytes one by one
+// against a byteSet as IndexAnyByte does.
+//
+// The next occurrence of each byte is cached by its distance to the end of
+// the block, which stays the same while the block is consumed from the front.
+// So the bytes far apart, e.g. the terminator of a wide row or a quote absent
+// from the file, are searched once per occurrence rather than once per field.
+type byteFinder struct {
+	// dist is the distance of the next occurrence of the byte to the end of
+	// the block, 0 if the byte doesn't occur in the rest of the block.
+	dist [256]int
+	// known marks the bytes whose dist is found in the current block.
+	known [256]bool
+}
+
+// reset forgets the occurrences found, it must be called when the block is
+// replaced.
+func (f *byteFinder) reset() {
+	f.known = [256]bool{}
+}
+
+// index returns the index of the first occurrence in s of any of the chars,
+// -1 if none of them occurs. s must be the rest of the block since the last
+// reset.
+func (f *byteFinder) index(s []byte, chars []byte) int {
+	first := -1
+	for _, c := range chars {
+		d := f.dist[c]
+		// search again if the cached occurrence is consumed.
+		if !f.known[c] || d > len(s) {
+			d = 0
+			if i := bytes.IndexByte(s, c); i >= 0 {
+				d = len(s) - i
+			}
+			f.dist[c] = d
+			f.known[c] = true
+		}
+		if d > 0 && (first < 0 || len(s)-d < first) {
+			first = len(s) - d
+		}
+	}
+	return first
+}
@@ -0,0 +1,75 @@
+package mydump
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testByteFinderSuite{})
+
+type testByteFinderSuite struct{}
+
+func (s *testByteFinderSuite) TestIndex(c *C) {
+	chars := []byte{',', '"', '\r', '\n', '\\'}
+	set := makeByteSet(chars)
+	var f byteFinder
+	c.Assert(f.index(nil, chars), Equals, -1)
+	c.Assert(f.index([]byte("abc"), nil), Equals, -1)
+
+	// the finder agrees with IndexAnyByte while the block is consumed.
+	for n := 0; n < 100; n++ {
+		block := make([]byte, rand.Intn(200))
+		for i := range block {
+			block[i] = "ab,\"\r\n\\"[rand.Intn(7)]
+		}
+		f.reset()
+		for rest := block; ; {
+			index := f.index(rest, chars)
+			c.Assert(index, Equals, IndexAnyByte(rest, &set), Commentf("block = %q, rest = %q", block, rest))
+			if index < 0 {
+				break
+			}
+			// consume beyond the found byte sometimes.
+			if index += 1 + rand.Intn(2); index > len(rest) {
+				break
+			}
+			rest = rest[index:]
+		}
+	}
+}
+
+// benchBlock is a block of wide rows, with short fields of 257 columns.
+var benchBlock = bytes.Repeat([]byte(strings.Repeat("1234567,rw9AOV1AjoI1,-10.00,2020-02-26 20:06:00.193,", 64)+"DI\n"), 64)
+
+func (s *testByteFinderSuite) BenchmarkIndexAnyByte(c *C) {
+	set := makeByteSet([]byte{',', '"', '\r', '\n', '\\'})
+	c.SetBytes(int64(len(benchBlock)))
+	for i := 0; i < c.N; i++ {
+		for rest := benchBlock; ; {
+			index := IndexAnyByte(rest, &set)
+			if index < 0 {
+				break
+			}
+			rest = rest[index+1:]
+		}
+	}
+}
+
+func (s *testByteFinderSuite) BenchmarkByteFinder(c *C) {
+	chars := []byte{',', '"', '\r', '\n', '\\'}
+	var f byteFinder
+	c.SetBytes(int64(len(benchBlock)))
+	for i := 0; i < c.N; i++ {
+		f.reset()
+		for rest := benchBlock; ; {
+			index := f.index(rest, chars)
+			if index < 0 {
+				break
+			}
+			rest = rest[index+1:]
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+)
+
+var errInvalidDataEncoding = errors.New("invalid data encoding")
+
+// CharsetConvertor converts the string values read from the data files from
+// `mydumper.data-character-set` to utf8mb4.
+//
+// The conversion is applied to the parsed values instead of the file content,
+// so the offsets recorded in the checkpoints still refer to the source files.
+// Config.Adjust makes sure the parser never splits the multi-byte characters.
+type CharsetConvertor struct {
+	charset  string
+	replace  bool
+	encoding encoding.Encoding
+}
+
+// NewCharsetConvertor creates a CharsetConvertor for the data character set
+// in the config. It returns nil if the data should be imported as is.
+func NewCharsetConvertor(cfg *config.MydumperRuntime) (*CharsetConvertor, error) {
+	switch cfg.DataCharacterSet {
+	case "", "binary":
+		return nil, nil
+	}
+	cc := newCharsetConvertor(cfg.DataCharacterSet, cfg.DataCharsetValidation == config.DataCharsetValidationReplace)
+	if cc == nil {
+		return nil, errors.Errorf("unsupported data character set %s", cfg.DataCharacterSet)
+	}
+	return cc, nil
+}
+
+// newCharsetConvertor creates a CharsetConvertor for the character set, which
+// is shared by the data files and the schema files. It returns nil if the
+// character set is not supported.
+func newCharsetConvertor(charset string, replace bool) *CharsetConvertor {
+	cc := &CharsetConvertor{charset: charset, replace: replace}
+	switch charset {
+	case "utf8mb4", "utf8", "latin1":
+	case "gbk":
+		cc.encoding = simplifiedchinese.GBK
+	case "gb18030":
+		cc.encoding = simplifiedchinese.GB18030
+	default:
+		return nil
+	}
+	return cc
+}
+
+// Decode converts the string s to utf8mb4.
+func (cc *CharsetConvertor) Decode(s string) (string, error) {
+	if isASCII(s) {
+		return s, nil
+	}
+
+	switch cc.charset {
+	case "latin1":
+		return decodeLatin1(s), nil
+	case "utf8mb4", "utf8":
+		if utf8.ValidString(s) {
+			return s, nil
+		}
+		if cc.replace {
+			return strings.ToValidUTF8(s, "\ufffd"), nil
+		}
+		return "", errors.Annotatef(errInvalidDataEncoding, "%q is not a valid %s string", s, cc.charset)
+	}
+
+	decoded, err := cc.encoding.NewDecoder().String(s)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	// the decoder replaces the invalid byte sequences with U+FFFD.
+	if !cc.replace && strings.ContainsRune(decoded, utf8.RuneError) {
+		return "", errors.Annotatef(errInvalidDataEncoding, "%q is not a valid %s string", s, cc.charset)
+	}
+	return decoded, nil
+}
+
+// decodeLatin1 decodes the latin1 string s. MySQL's latin1 is actually cp1252,
+// except that every byte is valid: the five bytes undefined in cp1252 are
+// mapped to the control characters with the same code points.
+func decodeLatin1(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) * 2)
+	for i := 0; i < len(s); i++ {
+		r := charmap.Windows1252.DecodeByte(s[i])
+		if r == utf8.RuneError {
+			r = rune(s[i])
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/mydump"
+)
+
+var _ = Suite(&testCharsetConvertorSuite{})
+
+type testCharsetConvertorSuite struct{}
+
+func (s *testCharsetConvertorSuite) newConvertor(c *C, charset, validation string) *mydump.CharsetConvertor {
+	cfg := config.NewConfig()
+	cfg.Mydumper.DataCharacterSet = charset
+	cfg.Mydumper.DataCharsetValidation = validation
+	cc, err := mydump.NewCharsetConvertor(&cfg.Mydumper)
+	c.Assert(err, IsNil)
+	c.Assert(cc, NotNil)
+	return cc
+}
+
+func (s *testCharsetConvertorSuite) TestBinary(c *C) {
+	cfg := config.NewConfig()
+	cc, err := mydump.NewCharsetConvertor(&cfg.Mydumper)
+	c.Assert(err, IsNil)
+	c.Assert(cc, IsNil)
+
+	cfg.Mydumper.DataCharacterSet = "ebcdic"
+	_, err = mydump.NewCharsetConvertor(&cfg.Mydumper)
+	c.Assert(err, ErrorMatches, "unsupported data character set ebcdic")
+}
+
+func (s *testCharsetConvertorSuite) TestGBK(c *C) {
+	cc := s.newConvertor(c, "gbk", config.DataCharsetValidationStrict)
+	res, err := cc.Decode("abc")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "abc")
+	res, err = cc.Decode("\xd6\xd0\xce\xc4")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "中文")
+	_, err = cc.Decode("\xd6\xd0\xff")
+	c.Assert(err, ErrorMatches, `"\\xd6\\xd0\\xff" is not a valid gbk string: invalid data encoding`)
+
+	cc = s.newConvertor(c, "gbk", config.DataCharsetValidationReplace)
+	res, err = cc.Decode("\xd6\xd0\xff")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "中\ufffd")
+}
+
+func (s *testCharsetConvertorSuite) TestGB18030(c *C) {
+	cc := s.newConvertor(c, "gb18030", config.DataCharsetValidationStrict)
+	res, err := cc.Decode("\xd6\xd0\x81\x30\x81\x30")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "中\u0080")
+}
+
+func (s *testCharsetConvertorSuite) TestLatin1(c *C) {
+	cc := s.newConvertor(c, "latin1", config.DataCharsetValidationStrict)
+	res, err := cc.Decode("caf\xe9 \x80\x81")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "café €\u0081")
+}
+
+func (s *testCharsetConvertorSuite) TestUTF8(c *C) {
+	cc := s.newConvertor(c, "utf8mb4", config.DataCharsetValidationStrict)
+	res, err := cc.Decode("中文")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "中文")
+	_, err = cc.Decode("中\xe6")
+	c.Assert(err, ErrorMatches, `"中\\xe6" is not a valid utf8mb4 string: invalid data encoding`)
+
+	cc = s.newConvertor(c, "utf8mb4", config.DataCharsetValidationReplace)
+	res, err = cc.Decode("中\xe6")
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "中\ufffd")
+}
@@ -41,18 +41,26 @@ type CSVParser struct {
 	quote   []byte
 	newLine []byte
 
-	// These variables are used with IndexAnyByte to search a byte slice for the
+	// These variables are used with the finder to search a byte slice for the
 	// first index which some special character may appear.
-	// quoteByteSet is used inside quoted fields (so the first characters of
+	// quoteStopSet is used inside quoted fields (so the first characters of
 	// the closing delimiter and backslash are special).
-	// unquoteByteSet is used outside quoted fields (so the first characters
+	// unquoteStopSet is used outside quoted fields (so the first characters
 	// of the opening delimiter, separator, terminator and backslash are
 	// special).
-	// newLineByteSet is used in strict-format CSV dividing (so the first
+	// newLineStopSet is used in strict-format CSV dividing (so the first
 	// characters of the terminator are special).
-	quoteByteSet   byteSet
-	unquoteByteSet byteSet
-	newLineByteSet byteSet
+	// plainStopSet is used by the fast path of reading records (so the first
+	// characters of the opening delimiter and backslash are special).
+	quoteStopSet   []byte
+	unquoteStopSet []byte
+	newLineStopSet []byte
+	plainStopSet   []byte
+	finder         byteFinder
+	// plainSplittable is whether the records without any delimiter and
+	// backslash can be split by the separator at once, which requires the
+	// separator and the terminator to be single bytes.
+	plainSplittable bool
 
 	// recordBuffer holds the unescaped fields, one after another.
 	// The fields can be accessed by using the indexes in fieldIndexes.
@@ -79,11 +87,12 @@ func NewCSVParser(
 	shouldParseHeader bool,
 ) *CSVParser {
 	escFlavor := backslashEscapeFlavorNone
-	var quoteStopSet, newLineStopSet []byte
+	var quoteStopSet, newLineStopSet, plainStopSet []byte
 	unquoteStopSet := []byte{cfg.Separator[0]}
 	if len(cfg.Delimiter) > 0 {
 		quoteStopSet = []byte{cfg.Delimiter[0]}
 		unquoteStopSet = append(unquoteStopSet, cfg.Delimiter[0])
+		plainStopSet = []byte{cfg.Delimiter[0]}
 	}
 	if len(cfg.Terminator) > 0 {
 		newLineStopSet = []byte{cfg.Terminator[0]}
@@ -95,6 +104,7 @@ func NewCSVParser(
 		escFlavor = backslashEscapeFlavorMySQL
 		quoteStopSet = append(quoteStopSet, '\\')
 		unquoteStopSet = append(unquoteStopSet, '\\')
+		plainStopSet = append(plainStopSet, '\\')
 		// we need special treatment of the NULL value \N, used by MySQL.
 		if !cfg.NotNull && cfg.Null == `\N` {
 			escFlavor = backslashEscapeFlavorMySQLWithNull
@@ -108,9 +118,11 @@ func NewCSVParser(
 		quote:             []byte(cfg.Delimiter),
 		newLine:           []byte(cfg.Terminator),
 		escFlavor:         escFlavor,
-		quoteByteSet:      makeByteSet(quoteStopSet),
-		unquoteByteSet:    makeByteSet(unquoteStopSet),
-		newLineByteSet:    makeByteSet(newLineStopSet),
+		quoteStopSet:      quoteStopSet,
+		unquoteStopSet:    unquoteStopSet,
+		newLineStopSet:    newLineStopSet,
+		plainStopSet:      plainStopSet,
+		plainSplittable:   len(cfg.Separator) == 1 && len(cfg.Terminator) <= 1,
 		shouldParseHeader: shouldParseHeader,
 	}
 }
@@ -145,6 +157,13 @@ const (
 	csvTokenDelimiter csvToken = 0x800
 )
 
+// readBlock reads the next block into the buffer. The occurrences found by
+// the finder are forgotten as the buffer is replaced.
+func (parser *CSVParser) readBlock() error {
+	parser.finder.reset()
+	return parser.blockParser.readBlock()
+}
+
 func (parser *CSVParser) readByte() (byte, error) {
 	if len(parser.buf) == 0 {
 		if err := parser.readBlock(); err != nil {
@@ -274,8 +293,8 @@ func (parser *CSVParser) appendCSVTokenToRecordBuffer(token csvToken) {
 
 // readUntil reads the buffer until any character from the `chars` set is found.
 // that character is excluded from the final buffer.
-func (parser *CSVParser) readUntil(chars *byteSet) ([]byte, byte, error) {
-	index := IndexAnyByte(parser.buf, chars)
+func (parser *CSVParser) readUntil(chars []byte) ([]byte, byte, error) {
+	index := parser.finder.index(parser.buf, chars)
 	if index >= 0 {
 		ret := parser.buf[:index]
 		parser.buf = parser.buf[index:]
@@ -295,7 +314,7 @@ func (parser *CSVParser) readUntil(chars *byteSet) ([]byte, byte, error) {
 			parser.pos += int64(len(buf))
 			return buf, 0, errors.Trace(err)
 		}
-		index := IndexAnyByte(parser.buf, chars)
+		index := parser.finder.index(parser.buf, chars)
 		if index >= 0 {
 			buf = append(buf, parser.buf[:index]...)
 			parser.buf = parser.buf[index:]
@@ -305,7 +324,51 @@ func (parser *CSVParser) readUntil(chars *byteSet) ([]byte, byte, error) {
 	}
 }
 
+// tryReadPlainRecord is the fast path of readRecord. If the next record ends
+// in the buffer and contains neither the delimiter nor the backslash, it's
+// split by the separator at once rather than read token by token.
+func (parser *CSVParser) tryReadPlainRecord(dst []string) ([]string, bool) {
+	if !parser.plainSplittable {
+		return dst, false
+	}
+	// skip the empty lines.
+	for len(parser.buf) > 0 && bytes.IndexByte(parser.newLineStopSet, parser.buf[0]) >= 0 {
+		parser.skipBytes(1)
+	}
+	end := parser.finder.index(parser.buf, parser.newLineStopSet)
+	if end < 0 {
+		return dst, false
+	}
+	if index := parser.finder.index(parser.buf, parser.plainStopSet); index >= 0 && index < end {
+		return dst, false
+	}
+	// leave the lines of whitespaces to the slow path which may skip them.
+	line := parser.buf[:end]
+	if len(bytes.TrimSpace(line)) == 0 {
+		return dst, false
+	}
+
+	str := string(line)
+	comma := parser.comma[0]
+	dst = dst[:0]
+	for {
+		index := strings.IndexByte(str, comma)
+		if index < 0 {
+			break
+		}
+		dst = append(dst, str[:index])
+		str = str[index+1:]
+	}
+	dst = append(dst, str)
+	parser.skipBytes(end + 1)
+	return dst, true
+}
+
 func (parser *CSVParser) readRecord(dst []string) ([]string, error) {
+	if record, ok := parser.tryReadPlainRecord(dst); ok {
+		return record, nil
+	}
+
 	parser.recordBuffer = parser.recordBuffer[:0]
 	parser.fieldIndexes = parser.fieldIndexes[:0]
 
@@ -316,7 +379,7 @@ func (parser *CSVParser) readRecord(dst []string) ([]string, error) {
 
 outside:
 	for {
-		content, firstByte, err := parser.readUntil(&parser.unquoteByteSet)
+		content, firstByte, err := parser.readUntil(parser.unquoteStopSet)
 
 		if len(content) > 0 {
 			isEmptyLine = false
@@ -398,7 +461,7 @@ outside:
 
 func (parser *CSVParser) readQuotedField() error {
 	for {
-		content, terminator, err := parser.readUntil(&parser.quoteByteSet)
+		content, terminator, err := parser.readUntil(parser.quoteStopSet)
 		err = parser.replaceEOF(err, errUnterminatedQuotedField)
 		if err != nil {
 			return err
@@ -513,7 +576,7 @@ func indexOfNewLine(b []byte) int {
 // This function is used in strict-format dividing a CSV file.
 func (parser *CSVParser) ReadUntilTerminator() (int64, error) {
 	for {
-		_, firstByte, err := parser.readUntil(&parser.newLineByteSet)
+		_, firstByte, err := parser.readUntil(parser.newLineStopSet)
 		if err != nil {
 			return 0, err
 		}
@@ -885,6 +885,38 @@ func (s *testMydumpCSVParserSuite) TestTerminator(c *C) {
 	s.runTestCases(c, &cfg, 1, testCases)
 }
 
+func (s *testMydumpCSVParserSuite) TestPlainAndQuotedRecords(c *C) {
+	cfg := config.CSVConfig{
+		Separator:       ",",
+		Delimiter:       `"`,
+		BackslashEscape: true,
+	}
+
+	// the plain records are split at once, the others are read token by token.
+	testCases := []testCase{
+		{
+			input: "1,abc\n\"2\",d\n3,e\\nf\r\n\n4,\"g,h\"\n  \n5,i\n",
+			expected: [][]types.Datum{
+				{types.NewStringDatum("1"), types.NewStringDatum("abc")},
+				{types.NewStringDatum("2"), types.NewStringDatum("d")},
+				{types.NewStringDatum("3"), types.NewStringDatum("e\nf")},
+				{types.NewStringDatum("4"), types.NewStringDatum("g,h")},
+				{types.NewStringDatum("5"), types.NewStringDatum("i")},
+			},
+		},
+	}
+	s.runTestCases(c, &cfg, 1, testCases)
+	s.runTestCases(c, &cfg, 8, testCases)
+	s.runTestCases(c, &cfg, int64(config.ReadBlockSize), testCases)
+
+	parser := mydump.NewCSVParser(&cfg, mydump.NewStringReader("1,abc\n\n2,def\r\n"), int64(config.ReadBlockSize), s.ioWorkers, false)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser, posEq, 6, 1)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser, posEq, 13, 2)
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
 // Run `go test github.com/pingcap/br/pkg/lightning/mydump -check.b -check.bmem -test.v` to get benchmark result.
 // Please ensure your temporary storage has (c.N / 2) KiB of free space.
 
@@ -895,7 +927,21 @@ type benchCSVParserSuite struct {
 
 var _ = Suite(&benchCSVParserSuite{})
 
+const (
+	benchCSVRow     = "18,1,1,0.3650,GC,BARBARBAR,rw9AOV1AjoI1,50000.00,-10.00,10.00,1,1,djj3Q2XaIPoYVy1FuF,gc80Q2o82Au3C9xv,PYOolSxG3w,DI,265111111,7586538936787184,2020-02-26 20:06:00.193,OE,YCkSPBVqoJ2V5F8zWs87V5XzbaIY70aWCD4dgcB6bjUzCr5wOJCJ2TYH49J7yWyysbudJIxlTAEWSJahY7hswLtTsqyjEkrlsN8iDMAa9Poj29miJ08tnn2G8mL64IlyywvnRGbLbyGvWDdrOSF42RyUFTWVyqlDWc6Gr5wyMPYgvweKemzFDVD3kro5JsmBmJY08EK54nQoyfo2sScyb34zcM9GFo9ZQTwloINfPYQKXQm32m0XvU7jiNmYpFTFJQjdqA825SEvQqMMefG2WG4jVu9UPdhdUjRsFRd0Gw7YPKByOlcuY0eKxT7sAzMKXx2000RR6dqHNXe47oVYd\n"
+	benchCSVColumns = 21
+	// benchWideCSVColumns is the number of the columns of benchWideCSVRow.
+	benchWideCSVColumns = 257
+)
+
+// benchWideCSVRow is a row of a wide table, with short fields of 257 columns.
+var benchWideCSVRow = strings.Repeat("1234567,rw9AOV1AjoI1,-10.00,2020-02-26 20:06:00.193,", 64) + "DI\n"
+
 func (s *benchCSVParserSuite) setupTest(c *C) {
+	s.setupTestWithRow(c, benchCSVRow)
+}
+
+func (s *benchCSVParserSuite) setupTestWithRow(c *C, row string) {
 	s.ioWorkers = worker.NewPool(context.Background(), 5, "bench_csv")
 
 	dir := c.MkDir()
@@ -906,7 +952,7 @@ func (s *benchCSVParserSuite) setupTest(c *C) {
 		c.Assert(file.Close(), IsNil)
 	}()
 	for i := 0; i < c.N; i++ {
-		_, err = file.WriteString("18,1,1,0.3650,GC,BARBARBAR,rw9AOV1AjoI1,50000.00,-10.00,10.00,1,1,djj3Q2XaIPoYVy1FuF,gc80Q2o82Au3C9xv,PYOolSxG3w,DI,265111111,7586538936787184,2020-02-26 20:06:00.193,OE,YCkSPBVqoJ2V5F8zWs87V5XzbaIY70aWCD4dgcB6bjUzCr5wOJCJ2TYH49J7yWyysbudJIxlTAEWSJahY7hswLtTsqyjEkrlsN8iDMAa9Poj29miJ08tnn2G8mL64IlyywvnRGbLbyGvWDdrOSF42RyUFTWVyqlDWc6Gr5wyMPYgvweKemzFDVD3kro5JsmBmJY08EK54nQoyfo2sScyb34zcM9GFo9ZQTwloINfPYQKXQm32m0XvU7jiNmYpFTFJQjdqA825SEvQqMMefG2WG4jVu9UPdhdUjRsFRd0Gw7YPKByOlcuY0eKxT7sAzMKXx2000RR6dqHNXe47oVYd\n")
+		_, err = file.WriteString(row)
 		c.Assert(err, IsNil)
 	}
 	c.ResetTimer()
@@ -914,14 +960,40 @@ func (s *benchCSVParserSuite) setupTest(c *C) {
 
 func (s *benchCSVParserSuite) BenchmarkReadRowUsingMydumpCSVParser(c *C) {
 	s.setupTest(c)
+	s.readRowsUsingMydumpCSVParser(c, benchCSVColumns)
+}
+
+func (s *benchCSVParserSuite) BenchmarkReadRowUsingEncodingCSV(c *C) {
+	s.setupTest(c)
+	s.readRowsUsingEncodingCSV(c)
+}
+
+func (s *benchCSVParserSuite) BenchmarkReadWideRowUsingMydumpCSVParser(c *C) {
+	s.setupTestWithRow(c, benchWideCSVRow)
+	s.readRowsUsingMydumpCSVParser(c, benchWideCSVColumns)
+}
+
+func (s *benchCSVParserSuite) BenchmarkReadWideRowUsingEncodingCSV(c *C) {
+	s.setupTestWithRow(c, benchWideCSVRow)
+	s.readRowsUsingEncodingCSV(c)
+}
+
+// BenchmarkReadQuotedWideRowUsingMydumpCSVParser reads the wide rows with all
+// fields quoted, which are read token by token as before the fast path.
+func (s *benchCSVParserSuite) BenchmarkReadQuotedWideRowUsingMydumpCSVParser(c *C) {
+	row := strings.TrimSuffix(benchWideCSVRow, "\n")
+	s.setupTestWithRow(c, `"`+strings.ReplaceAll(row, ",", `","`)+"\"\n")
+	s.readRowsUsingMydumpCSVParser(c, benchWideCSVColumns)
+}
 
+func (s *benchCSVParserSuite) readRowsUsingMydumpCSVParser(c *C, columns int) {
 	file, err := os.Open(s.csvPath)
 	c.Assert(err, IsNil)
 	defer func() {
 		c.Assert(file.Close(), IsNil)
 	}()
 
-	cfg := config.CSVConfig{Separator: ","}
+	cfg := config.CSVConfig{Separator: ",", Delimiter: `"`}
 	parser := mydump.NewCSVParser(&cfg, file, 65536, s.ioWorkers, false)
 	parser.SetLogger(log.Logger{Logger: zap.NewNop()})
 
@@ -929,6 +1001,9 @@ func (s *benchCSVParserSuite) BenchmarkReadRowUsingMydumpCSVParser(c *C) {
 	for {
 		err := parser.ReadRow()
 		if err == nil {
+			if len(parser.LastRow().Row) != columns {
+				c.Fatalf("expect %d columns, got %d", columns, len(parser.LastRow().Row))
+			}
 			parser.RecycleRow(parser.LastRow())
 			rowsCount++
 			continue
@@ -941,9 +1016,7 @@ func (s *benchCSVParserSuite) BenchmarkReadRowUsingMydumpCSVParser(c *C) {
 	c.Assert(rowsCount, Equals, c.N)
 }
 
-func (s *benchCSVParserSuite) BenchmarkReadRowUsingEncodingCSV(c *C) {
-	s.setupTest(c)
-
+func (s *benchCSVParserSuite) readRowsUsingEncodingCSV(c *C) {
 	file, err := os.Open(s.csvPath)
 	c.Assert(err, IsNil)
 	defer func() {
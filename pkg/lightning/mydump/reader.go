@@ -23,7 +23,6 @@ import (
 
 	"github.com/pingcap/errors"
 	"go.uber.org/zap"
-	"golang.org/x/text/encoding/simplifiedchinese"
 
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/worker"
@@ -51,16 +50,13 @@ func decodeCharacterSet(data []byte, characterSet string) ([]byte, error) {
 		// perform `chardet` first.
 		fallthrough
 	case "gb18030":
-		decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		// check for U+FFFD to see if decoding contains errors.
-		// https://groups.google.com/d/msg/golang-nuts/pENT3i4zJYk/v2X3yyiICwAJ
-		if bytes.ContainsRune(decoded, '\ufffd') {
+		decoded, err := newCharsetConvertor("gb18030", false).Decode(string(data))
+		if errors.Cause(err) == errInvalidDataEncoding {
 			return nil, errInvalidSchemaEncoding
+		} else if err != nil {
+			return nil, errors.Trace(err)
 		}
-		data = decoded
+		data = []byte(decoded)
 	default:
 		return nil, errors.Errorf("Unsupported encoding %s", characterSet)
 	}
@@ -21,7 +21,9 @@ import (
 	"time"
 
 	"github.com/pingcap/errors"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/pingcap/br/pkg/lightning/config"
 	"github.com/pingcap/br/pkg/lightning/log"
@@ -334,7 +336,9 @@ func makeParquetFileRegion(
 }
 
 // SplitLargeFile splits a large csv file into multiple regions, the size of
-// each regions is specified by `config.MaxRegionSize`.
+// each regions is specified by `config.MaxRegionSize`. The file is divided at
+// every `MaxRegionSize` bytes, and each offset is moved beyond the next
+// terminator concurrently.
 // Note: We split the file coarsely, thus the format of csv file is needed to be
 // strict.
 // e.g.
@@ -352,7 +356,7 @@ func SplitLargeFile(
 ) (prevRowIDMax int64, regions []*TableRegion, dataFileSizes []float64, err error) {
 	maxRegionSize := int64(cfg.Mydumper.MaxRegionSize)
 	dataFileSizes = make([]float64, 0, dataFile.FileMeta.FileSize/maxRegionSize+1)
-	startOffset, endOffset := int64(0), maxRegionSize
+	startOffset := int64(0)
 	var columns []string
 	if cfg.Mydumper.CSV.Header {
 		r, err := store.Open(ctx, dataFile.FileMeta.Path)
@@ -365,36 +369,28 @@ func SplitLargeFile(
 		}
 		columns = parser.Columns()
 		startOffset, _ = parser.Pos()
-		endOffset = startOffset + maxRegionSize
-		if endOffset > dataFile.FileMeta.FileSize {
-			endOffset = dataFile.FileMeta.FileSize
-		}
 	}
-	for {
+
+	var offsets []int64
+	for offset := startOffset + maxRegionSize; offset < dataFile.FileMeta.FileSize; offset += maxRegionSize {
+		offsets = append(offsets, offset)
+	}
+	endOffsets, err := resolveChunkEndOffsets(ctx, cfg, dataFile, offsets, ioWorker, store)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	endOffsets = append(endOffsets, dataFile.FileMeta.FileSize)
+
+	for i, endOffset := range endOffsets {
+		// the offsets within `MaxRegionSize` bytes from the start of the chunk
+		// are skipped, as splitting the file sequentially would do. This also
+		// skips the offsets moved to the same terminator, or to the '\n' of a
+		// "\r\n" ending the previous chunk.
+		if endOffset <= startOffset || (i < len(offsets) && offsets[i] < startOffset+maxRegionSize) {
+			continue
+		}
 		curRowsCnt := (endOffset - startOffset) / divisor
 		rowIDMax := prevRowIdxMax + curRowsCnt
-		if endOffset != dataFile.FileMeta.FileSize {
-			r, err := store.Open(ctx, dataFile.FileMeta.Path)
-			if err != nil {
-				return 0, nil, nil, err
-			}
-			parser := NewCSVParser(&cfg.Mydumper.CSV, r, int64(cfg.Mydumper.ReadBlockSize), ioWorker, false)
-			if err = parser.SetPos(endOffset, prevRowIDMax); err != nil {
-				return 0, nil, nil, err
-			}
-			pos, err := parser.ReadUntilTerminator()
-			if err != nil {
-				if !errors.ErrorEqual(err, io.EOF) {
-					return 0, nil, nil, err
-				}
-				log.L().Warn("file contains no terminator at end",
-					zap.String("path", dataFile.FileMeta.Path),
-					zap.String("terminator", cfg.Mydumper.CSV.Terminator))
-				pos = dataFile.FileMeta.FileSize
-			}
-			endOffset = pos
-			parser.Close()
-		}
 		regions = append(regions,
 			&TableRegion{
 				DB:       meta.DB,
@@ -410,13 +406,64 @@ func SplitLargeFile(
 			})
 		dataFileSizes = append(dataFileSizes, float64(endOffset-startOffset))
 		prevRowIdxMax = rowIDMax
-		if endOffset == dataFile.FileMeta.FileSize {
-			break
-		}
 		startOffset = endOffset
-		if endOffset += maxRegionSize; endOffset > dataFile.FileMeta.FileSize {
-			endOffset = dataFile.FileMeta.FileSize
-		}
 	}
 	return prevRowIdxMax, regions, dataFileSizes, nil
 }
+
+// resolveChunkEndOffsets moves each of the offsets of the file beyond the next
+// terminator, or to the end of the file if there is no terminator after it.
+// The offsets are resolved concurrently, each by a parser seeking to it.
+func resolveChunkEndOffsets(
+	ctx context.Context,
+	cfg *config.Config,
+	dataFile FileInfo,
+	offsets []int64,
+	ioWorker *worker.Pool,
+	store storage.ExternalStorage,
+) ([]int64, error) {
+	endOffsets := make([]int64, len(offsets))
+	noTerminator := atomic.NewBool(false)
+	eg, egCtx := errgroup.WithContext(ctx)
+	limit := make(chan struct{}, utils.MaxInt(cfg.App.RegionConcurrency, 2))
+loop:
+	for i, offset := range offsets {
+		select {
+		case limit <- struct{}{}:
+		case <-egCtx.Done():
+			break loop
+		}
+		i, offset := i, offset
+		eg.Go(func() error {
+			defer func() { <-limit }()
+			r, err := store.Open(egCtx, dataFile.FileMeta.Path)
+			if err != nil {
+				return err
+			}
+			parser := NewCSVParser(&cfg.Mydumper.CSV, r, int64(cfg.Mydumper.ReadBlockSize), ioWorker, false)
+			defer parser.Close()
+			if err = parser.SetPos(offset, 0); err != nil {
+				return err
+			}
+			pos, err := parser.ReadUntilTerminator()
+			if err != nil {
+				if !errors.ErrorEqual(err, io.EOF) {
+					return err
+				}
+				noTerminator.Store(true)
+				pos = dataFile.FileMeta.FileSize
+			}
+			endOffsets[i] = pos
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	if noTerminator.Load() {
+		log.L().Warn("file contains no terminator at end",
+			zap.String("path", dataFile.FileMeta.Path),
+			zap.String("terminator", cfg.Mydumper.CSV.Terminator))
+	}
+	return endOffsets, nil
+}
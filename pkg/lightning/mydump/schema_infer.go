@@ -0,0 +1,247 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/worker"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+// InferTableSchema generates the CREATE TABLE statement of the table from the
+// header and the first `sampleRows` rows of its first CSV data file. All
+// columns are nullable and no index is created. An empty string is returned if
+// the table has no CSV data file.
+func InferTableSchema(
+	ctx context.Context,
+	cfg *config.MydumperRuntime,
+	tblMeta *MDTableMeta,
+	store storage.ExternalStorage,
+	ioWorkers *worker.Pool,
+	sampleRows int,
+) (string, error) {
+	var dataFile *FileInfo
+	for i := range tblMeta.DataFiles {
+		if tblMeta.DataFiles[i].FileMeta.Type == SourceTypeCSV {
+			dataFile = &tblMeta.DataFiles[i]
+			break
+		}
+	}
+	if dataFile == nil {
+		return "", nil
+	}
+
+	reader, err := store.Open(ctx, dataFile.FileMeta.Path)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	parser := NewCSVParser(&cfg.CSV, reader, int64(cfg.ReadBlockSize), ioWorkers, true)
+	defer parser.Close()
+
+	var columns []*columnInferrer
+	for i := 0; i < sampleRows; i++ {
+		err := parser.ReadRow()
+		if errors.Cause(err) == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.Annotatef(err, "failed to infer schema from %s", dataFile.FileMeta.Path)
+		}
+		if columns == nil {
+			for _, name := range parser.Columns() {
+				if len(name) == 0 {
+					return "", errors.Errorf("failed to infer schema from %s: the CSV header contains empty column name", dataFile.FileMeta.Path)
+				}
+				columns = append(columns, &columnInferrer{name: name})
+			}
+		}
+		lastRow := parser.LastRow()
+		for j := range lastRow.Row {
+			if j >= len(columns) {
+				break
+			}
+			if !lastRow.Row[j].IsNull() {
+				columns[j].observe(lastRow.Row[j].GetString())
+			}
+		}
+		parser.RecycleRow(lastRow)
+	}
+	if columns == nil {
+		for _, name := range parser.Columns() {
+			columns = append(columns, &columnInferrer{name: name})
+		}
+	}
+	if len(columns) == 0 {
+		return "", errors.Errorf("failed to infer schema from %s: the CSV header is empty", dataFile.FileMeta.Path)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("CREATE TABLE ")
+	common.WriteMySQLIdentifier(&sb, tblMeta.Name)
+	sb.WriteString(" (")
+	for i, column := range columns {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n  ")
+		common.WriteMySQLIdentifier(&sb, column.name)
+		sb.WriteString(" ")
+		sb.WriteString(column.sqlType())
+	}
+	sb.WriteString("\n);")
+	return sb.String(), nil
+}
+
+type inferredType int
+
+const (
+	// inferredTypeUnknown means only NULL values are seen.
+	inferredTypeUnknown inferredType = iota
+	inferredTypeInt
+	inferredTypeDecimal
+	inferredTypeDouble
+	inferredTypeDate
+	inferredTypeDatetime
+	inferredTypeString
+)
+
+const (
+	maxDecimalPrecision = 65
+	maxDecimalScale     = 30
+	maxVarcharLength    = 255
+)
+
+var (
+	decimalRegexp  = regexp.MustCompile(`^[-+]?(\d+)(?:\.(\d+))?$`)
+	doubleRegexp   = regexp.MustCompile(`^[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?$`)
+	datetimeRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(?:\.(\d{1,6}))?$`)
+)
+
+// columnInferrer infers the narrowest type of a column which can hold all the
+// observed values.
+type columnInferrer struct {
+	name string
+	tp   inferredType
+	// the maximum number of digits before and after the decimal point.
+	intDigits  int
+	fracDigits int
+	// the maximum fractional seconds precision of datetime values.
+	fsp    int
+	maxLen int
+}
+
+func (ci *columnInferrer) observe(value string) {
+	if l := utf8.RuneCountInString(value); l > ci.maxLen {
+		ci.maxLen = l
+	}
+
+	tp := inferredTypeString
+	switch {
+	case decimalRegexp.MatchString(value):
+		m := decimalRegexp.FindStringSubmatch(value)
+		// keep the leading zeros of values like zip codes.
+		if len(m[1]) > 1 && m[1][0] == '0' {
+			break
+		}
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			tp = inferredTypeInt
+		} else {
+			tp = inferredTypeDecimal
+		}
+		if len(m[1]) > ci.intDigits {
+			ci.intDigits = len(m[1])
+		}
+		if len(m[2]) > ci.fracDigits {
+			ci.fracDigits = len(m[2])
+		}
+	case doubleRegexp.MatchString(value):
+		tp = inferredTypeDouble
+	case datetimeRegexp.MatchString(value):
+		m := datetimeRegexp.FindStringSubmatch(value)
+		layout := "2006-01-02 15:04:05"
+		if value[10] == 'T' {
+			layout = "2006-01-02T15:04:05"
+		}
+		if _, err := time.Parse(layout, value[:19]); err == nil {
+			tp = inferredTypeDatetime
+			if len(m[1]) > ci.fsp {
+				ci.fsp = len(m[1])
+			}
+		}
+	default:
+		if _, err := time.Parse("2006-01-02", value); err == nil {
+			tp = inferredTypeDate
+		}
+	}
+	ci.tp = mergeInferredType(ci.tp, tp)
+}
+
+func mergeInferredType(a, b inferredType) inferredType {
+	switch {
+	case a == b || b == inferredTypeUnknown:
+		return a
+	case a == inferredTypeUnknown:
+		return b
+	case a <= inferredTypeDouble && b <= inferredTypeDouble:
+		// int < decimal < double
+		if a > b {
+			return a
+		}
+		return b
+	case (a == inferredTypeDate || a == inferredTypeDatetime) && (b == inferredTypeDate || b == inferredTypeDatetime):
+		return inferredTypeDatetime
+	default:
+		return inferredTypeString
+	}
+}
+
+func (ci *columnInferrer) sqlType() string {
+	switch ci.tp {
+	case inferredTypeInt:
+		return "bigint"
+	case inferredTypeDecimal:
+		if ci.intDigits+ci.fracDigits > maxDecimalPrecision || ci.fracDigits > maxDecimalScale {
+			return "double"
+		}
+		return fmt.Sprintf("decimal(%d,%d)", ci.intDigits+ci.fracDigits, ci.fracDigits)
+	case inferredTypeDouble:
+		return "double"
+	case inferredTypeDate:
+		return "date"
+	case inferredTypeDatetime:
+		if ci.fsp > 0 {
+			return fmt.Sprintf("datetime(%d)", ci.fsp)
+		}
+		return "datetime"
+	case inferredTypeString:
+		if ci.maxLen > maxVarcharLength {
+			return "longtext"
+		}
+		return fmt.Sprintf("varchar(%d)", maxVarcharLength)
+	default:
+		return fmt.Sprintf("varchar(%d)", maxVarcharLength)
+	}
+}
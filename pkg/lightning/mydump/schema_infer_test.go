@@ -0,0 +1,81 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/mydump"
+	"github.com/pingcap/br/pkg/lightning/worker"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+var _ = Suite(&testSchemaInferSuite{})
+
+type testSchemaInferSuite struct{}
+
+func (s *testSchemaInferSuite) inferSchema(c *C, content string, sampleRows int) (string, error) {
+	dir := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "db.t.csv"), []byte(content), 0o644), IsNil)
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	cfg := config.NewConfig()
+	cfg.Mydumper.CSV.Header = true
+	tblMeta := &mydump.MDTableMeta{
+		DB:   "db",
+		Name: "t",
+		DataFiles: []mydump.FileInfo{{
+			FileMeta: mydump.SourceFileMeta{Path: "db.t.csv", Type: mydump.SourceTypeCSV},
+		}},
+	}
+	ioWorkers := worker.NewPool(context.Background(), 1, "infer_schema")
+	return mydump.InferTableSchema(context.Background(), &cfg.Mydumper, tblMeta, store, ioWorkers, sampleRows)
+}
+
+func (s *testSchemaInferSuite) TestInferTableSchema(c *C) {
+	schema, err := s.inferSchema(c, "ID,Price,Ratio,Day,Created,Zip,Name,Empty\n"+
+		"1,1.5,1e3,2021-01-02,2021-01-02 03:04:05,00123,a,\\N\n"+
+		"99999999999999999999,12.25,2.5,2021-02-03,2021-02-03,10001,bb,\\N\n"+
+		"-3,7,-4,2021-03-04,2021-03-04T05:06:07.123,20002,c`c,\\N\n", 100)
+	c.Assert(err, IsNil)
+	c.Assert(schema, Equals, "CREATE TABLE `t` (\n"+
+		"  `id` decimal(20,0),\n"+
+		"  `price` decimal(4,2),\n"+
+		"  `ratio` double,\n"+
+		"  `day` date,\n"+
+		"  `created` datetime(3),\n"+
+		"  `zip` varchar(255),\n"+
+		"  `name` varchar(255),\n"+
+		"  `empty` varchar(255)\n"+
+		");")
+
+	// only the sampled rows are considered.
+	schema, err = s.inferSchema(c, "a,b\n1,2\nx,2021-01-01\n", 1)
+	c.Assert(err, IsNil)
+	c.Assert(schema, Equals, "CREATE TABLE `t` (\n  `a` bigint,\n  `b` bigint\n);")
+
+	// the header is still used if there is no data row.
+	schema, err = s.inferSchema(c, "a,b\n", 100)
+	c.Assert(err, IsNil)
+	c.Assert(schema, Equals, "CREATE TABLE `t` (\n  `a` varchar(255),\n  `b` varchar(255)\n);")
+
+	_, err = s.inferSchema(c, "a,,c\n1,2,3\n", 100)
+	c.Assert(err, ErrorMatches, ".*the CSV header contains empty column name")
+}
@@ -29,8 +29,6 @@ import (
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/table/tables"
 	"github.com/tikv/pd/pkg/typeutil"
-	"github.com/tikv/pd/server/api"
-	pdconfig "github.com/tikv/pd/server/config"
 	"go.uber.org/zap"
 
 	"github.com/pingcap/br/pkg/lightning/backend"
@@ -41,6 +39,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/mydump"
 	"github.com/pingcap/br/pkg/lightning/verification"
+	"github.com/pingcap/br/pkg/pdutil"
 	"github.com/pingcap/br/pkg/storage"
 )
 
@@ -55,9 +54,6 @@ const (
 	OnlineBytesLimitation = 10 * units.MiB
 	OnlineKeysLimitation  = 5000
 
-	pdStores    = "/pd/api/v1/stores"
-	pdReplicate = "/pd/api/v1/config/replicate"
-
 	defaultCSVSize    = 10 * units.GiB
 	maxSampleDataSize = 10 * 1024 * 1024
 	maxSampleRowCount = 10 * 1024
@@ -68,8 +64,7 @@ func (rc *Controller) isSourceInLocal() bool {
 }
 
 func (rc *Controller) getReplicaCount(ctx context.Context) (uint64, error) {
-	result := &pdconfig.ReplicationConfig{}
-	err := rc.tls.WithHost(rc.cfg.TiDB.PdAddr).GetJSON(ctx, pdReplicate, &result)
+	result, err := pdutil.NewHTTPClientFromTLS(rc.tls.WithHost(rc.cfg.TiDB.PdAddr)).GetReplicateConfig(ctx)
 	if err != nil {
 		return 0, errors.Trace(err)
 	}
@@ -84,8 +79,7 @@ func (rc *Controller) ClusterResource(ctx context.Context, localSource int64) er
 		rc.checkTemplate.Collect(Critical, passed, message)
 	}()
 
-	result := &api.StoresInfo{}
-	err := rc.tls.WithHost(rc.cfg.TiDB.PdAddr).GetJSON(ctx, pdStores, result)
+	result, err := pdutil.NewHTTPClientFromTLS(rc.tls.WithHost(rc.cfg.TiDB.PdAddr)).GetStores(ctx)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -0,0 +1,98 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+)
+
+// quarantineRecord is a row which failed to be encoded, written as one line of
+// JSON into the quarantine file.
+type quarantineRecord struct {
+	Path   string        `json:"path"`
+	Offset int64         `json:"offset"`
+	Row    []interface{} `json:"row"`
+	Error  string        `json:"error"`
+}
+
+// quarantineWriter appends the rows of a chunk which failed to be encoded to
+// a file in the quarantine directory. The file is only created when the first
+// row is quarantined. Since the chunk is restarted from the last saved
+// checkpoint on resume, the file may contain duplicated records.
+type quarantineWriter struct {
+	path string
+	file *os.File
+}
+
+func newQuarantineWriter(dir, schema, table string, chunk *checkpoints.ChunkCheckpoint) *quarantineWriter {
+	name := fmt.Sprintf("%s.%s.%s.%d.jsonl",
+		url.PathEscape(schema), url.PathEscape(table), filepath.Base(chunk.Key.Path), chunk.Key.Offset)
+	return &quarantineWriter{path: filepath.Join(dir, name)}
+}
+
+func (w *quarantineWriter) append(record *quarantineRecord) error {
+	if w.file == nil {
+		if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+			return errors.Annotate(err, "failed to create quarantine directory")
+		}
+		file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return errors.Annotate(err, "failed to open quarantine file")
+		}
+		w.file = file
+	}
+	content, err := json.Marshal(record)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = w.file.Write(append(content, '\n'))
+	return errors.Annotate(err, "failed to write quarantine file")
+}
+
+func (w *quarantineWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return errors.Trace(err)
+}
+
+// quarantineRowValues converts the datums of a row into values which can be
+// marshaled into JSON. NULL is kept as null, all other values are strings.
+func quarantineRowValues(row []types.Datum) []interface{} {
+	values := make([]interface{}, 0, len(row))
+	for i := range row {
+		if row[i].IsNull() {
+			values = append(values, nil)
+			continue
+		}
+		s, err := row[i].ToString()
+		if err != nil {
+			values = append(values, fmt.Sprint(row[i].GetValue()))
+			continue
+		}
+		values = append(values, s)
+	}
+	return values
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/config"
+)
+
+type quarantineSuite struct{}
+
+var _ = Suite(&quarantineSuite{})
+
+func (s *quarantineSuite) TestQuarantineRow(c *C) {
+	dir := filepath.Join(c.MkDir(), "quarantine")
+	chunk := &checkpoints.ChunkCheckpoint{
+		Key: checkpoints.ChunkCheckpointKey{Path: "/data/db.t.001.csv", Offset: 100},
+	}
+	cr := &chunkRestore{chunk: chunk}
+	rc := &Controller{cfg: &config.Config{App: config.Lightning{MaxError: 2, QuarantineDir: dir}}}
+	tr := &TableRestore{tableName: "`db`.`t`"}
+	w := newQuarantineWriter(dir, "db", "t", chunk)
+	c.Assert(w.path, Equals, filepath.Join(dir, "db.t.db.t.001.csv.100.jsonl"))
+
+	// nothing is created before the first row is quarantined.
+	c.Assert(w.close(), IsNil)
+	_, err := os.Stat(dir)
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	row := []types.Datum{types.NewStringDatum("abc"), types.NewDatum(nil), types.NewIntDatum(3)}
+	c.Assert(cr.quarantineRow(tr, rc, w, row, 123, errors.New("bad value")), IsNil)
+	c.Assert(cr.quarantineRow(tr, rc, w, row[:1], 456, errors.New("bad row")), IsNil)
+	err = cr.quarantineRow(tr, rc, w, row, 789, errors.New("another bad row"))
+	c.Assert(err, ErrorMatches, "too many rows of table `db`.`t` failed to be encoded \\(max-error = 2\\): another bad row")
+	c.Assert(tr.errorRows.Load(), Equals, int64(3))
+	c.Assert(w.close(), IsNil)
+
+	content, err := os.ReadFile(w.path)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals,
+		`{"path":"/data/db.t.001.csv","offset":123,"row":["abc",null,"3"],"error":"bad value"}`+"\n"+
+			`{"path":"/data/db.t.001.csv","offset":456,"row":["abc"],"error":"bad row"}`+"\n")
+
+	// without max-error the encode error is returned directly.
+	rc.cfg.App.MaxError = 0
+	err = cr.quarantineRow(tr, rc, w, row, 123, errors.New("bad value"))
+	c.Assert(err, ErrorMatches, "bad value")
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -28,8 +29,12 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
 	sstpb "github.com/pingcap/kvproto/pkg/import_sstpb"
+	"github.com/pingcap/parser/charset"
 	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/meta/autoid"
+	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/util/collate"
 	"go.uber.org/atomic"
 	"go.uber.org/multierr"
@@ -40,12 +45,14 @@ import (
 	"github.com/pingcap/br/pkg/lightning/backend/importer"
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
 	"github.com/pingcap/br/pkg/lightning/backend/local"
+	"github.com/pingcap/br/pkg/lightning/backend/noop"
 	"github.com/pingcap/br/pkg/lightning/backend/tidb"
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/config"
 	"github.com/pingcap/br/pkg/lightning/glue"
 	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/lightning/membudget"
 	"github.com/pingcap/br/pkg/lightning/metric"
 	"github.com/pingcap/br/pkg/lightning/mydump"
 	"github.com/pingcap/br/pkg/lightning/tikv"
@@ -72,6 +79,11 @@ const (
 	indexEngineID = -1
 )
 
+const (
+	// the number of rows sampled from the CSV data file to infer the schema of a table.
+	inferSchemaSampleRows = 1000
+)
+
 const (
 	compactStateIdle int32 = iota
 	compactStateDoing
@@ -134,13 +146,25 @@ type errorSummaries struct {
 	sync.Mutex
 	logger  log.Logger
 	summary map[string]errorSummary
+	// errorRows is the number of quarantined rows of each table.
+	errorRows map[string]int64
+	// failedChunks are the quarantined chunks of each table.
+	failedChunks map[string][]failedChunk
+}
+
+type failedChunk struct {
+	engineID int32
+	key      checkpoints.ChunkCheckpointKey
+	failure  string
 }
 
 // makeErrorSummaries returns an initialized errorSummaries instance
 func makeErrorSummaries(logger log.Logger) errorSummaries {
 	return errorSummaries{
-		logger:  logger,
-		summary: make(map[string]errorSummary),
+		logger:       logger,
+		summary:      make(map[string]errorSummary),
+		errorRows:    make(map[string]int64),
+		failedChunks: make(map[string][]failedChunk),
 	}
 }
 
@@ -159,6 +183,30 @@ func (es *errorSummaries) emitLog() {
 			)
 		}
 	}
+
+	if tableCount := len(es.errorRows); tableCount > 0 {
+		logger := es.logger
+		logger.Warn("tables have quarantined rows", zap.Int("count", tableCount))
+		for tableName, errorRows := range es.errorRows {
+			logger.Warn("-", zap.String("table", tableName), zap.Int64("errorRows", errorRows))
+		}
+	}
+
+	if tableCount := len(es.failedChunks); tableCount > 0 {
+		logger := es.logger
+		logger.Warn("tables have quarantined chunks, re-run them by `tidb-lightning-ctl -checkpoint-mark dirty`",
+			zap.Int("count", tableCount))
+		for tableName, chunks := range es.failedChunks {
+			for _, chunk := range chunks {
+				logger.Warn("-",
+					zap.String("table", tableName),
+					zap.Int32("engine", chunk.engineID),
+					zap.String("chunk", chunk.key.String()),
+					zap.String("failure", chunk.failure),
+				)
+			}
+		}
+	}
 }
 
 func (es *errorSummaries) record(tableName string, err error, status checkpoints.CheckpointStatus) {
@@ -167,6 +215,34 @@ func (es *errorSummaries) record(tableName string, err error, status checkpoints
 	es.summary[tableName] = errorSummary{status: status, err: err}
 }
 
+func (es *errorSummaries) recordFailedChunks(tableName string, chunks []failedChunk) {
+	es.Lock()
+	defer es.Unlock()
+	es.failedChunks[tableName] = chunks
+}
+
+// failedChunksError returns an error if any chunk has been quarantined, so the
+// import fails and the checkpoints are kept to re-run the chunks.
+func (es *errorSummaries) failedChunksError() error {
+	es.Lock()
+	defer es.Unlock()
+	chunks := 0
+	for _, tableChunks := range es.failedChunks {
+		chunks += len(tableChunks)
+	}
+	if chunks == 0 {
+		return nil
+	}
+	return errors.Errorf("%d chunks of %d tables have been quarantined, re-run them by `tidb-lightning-ctl -checkpoint-mark dirty`",
+		chunks, len(es.failedChunks))
+}
+
+func (es *errorSummaries) recordErrorRows(tableName string, errorRows int64) {
+	es.Lock()
+	defer es.Unlock()
+	es.errorRows[tableName] = errorRows
+}
+
 const (
 	diskQuotaStateIdle int32 = iota
 	diskQuotaStateChecking
@@ -255,6 +331,16 @@ type Controller struct {
 	diskQuotaLock  *diskQuotaLock
 	diskQuotaState atomic.Int32
 	compactState   atomic.Int32
+
+	// schemaWatcher is nil if the schema changes of the target tables are not
+	// checked.
+	schemaWatcher *schemaWatcher
+	// memBudget admits the encoded KV batches waiting to be written, it's nil
+	// if the memory isn't budgeted.
+	memBudget *membudget.Budget
+	// ioLimiter shapes the writes of the local backend, it's nil for the
+	// other backends.
+	ioLimiter *local.TableIOLimiter
 }
 
 func NewRestoreController(
@@ -297,11 +383,26 @@ func NewRestoreControllerWithPauser(
 		cfg.TaskID = taskCp.TaskID
 	}
 
-	var backend backend.Backend
+	memBudget, err := membudget.FromHostMemory(cfg.App.MemoryBudgetRatio)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if memBudget != nil {
+		log.L().Info("budget the memory", zap.Float64("ratio", cfg.App.MemoryBudgetRatio),
+			zap.String("limit", units.BytesSize(float64(memBudget.Limit()))))
+	}
+
+	backendCfg := backend.NewBackendConfig(cfg)
+	backendCfg.MemoryBudget = memBudget
+	var (
+		backend   backend.Backend
+		ioLimiter *local.TableIOLimiter
+	)
 	switch cfg.TikvImporter.Backend {
 	case config.BackendImporter:
 		var err error
-		backend, err = importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr)
+		backend, err = importer.NewImporter(ctx, tls, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr, backendCfg,
+			cfg.TikvImporter.GRPC.DialOptions()...)
 		if err != nil {
 			return nil, errors.Annotate(err, "open importer backend failed")
 		}
@@ -310,7 +411,7 @@ func NewRestoreControllerWithPauser(
 		if err != nil {
 			return nil, errors.Annotate(err, "open tidb backend failed")
 		}
-		backend = tidb.NewTiDBBackend(db, cfg.TikvImporter.OnDuplicate)
+		backend = tidb.NewTiDBBackend(db, cfg.TikvImporter.OnDuplicate, backendCfg)
 	case config.BackendLocal:
 		var rLimit local.Rlim_t
 		rLimit, err = local.GetSystemRLimit()
@@ -323,8 +424,9 @@ func NewRestoreControllerWithPauser(
 			maxOpenFiles = math.MaxInt32
 		}
 
+		ioLimiter = local.NewTableIOLimiter(int64(cfg.TikvImporter.PerTableIOLimit))
 		backend, err = local.NewLocalBackend(ctx, tls, cfg.TiDB.PdAddr, &cfg.TikvImporter,
-			cfg.Checkpoint.Enable, g, maxOpenFiles)
+			cfg.Checkpoint.Enable, g, maxOpenFiles, backendCfg, ioLimiter)
 		if err != nil {
 			return nil, errors.Annotate(err, "build local backend failed")
 		}
@@ -332,6 +434,13 @@ func NewRestoreControllerWithPauser(
 		if err != nil {
 			return nil, err
 		}
+		if cfg.Checkpoint.Enable && cfg.TikvImporter.EngineTTL.Duration > 0 {
+			if err = cleanupOrphanEngines(ctx, cpdb, dbMetas, cfg.TikvImporter.SortedKVDir, cfg.TikvImporter.EngineTTL.Duration); err != nil {
+				return nil, err
+			}
+		}
+	case config.BackendNoop:
+		backend = noop.NewDryRunBackend(tls)
 	default:
 		return nil, errors.New("unknown backend: " + cfg.TikvImporter.Backend)
 	}
@@ -378,13 +487,38 @@ func NewRestoreControllerWithPauser(
 		metaMgrBuilder: metaBuilder,
 		diskQuotaLock:  newDiskQuotaLock(),
 		taskMgr:        nil,
+		memBudget:      memBudget,
+		ioLimiter:      ioLimiter,
+	}
+
+	// the tidb backend writes through SQL statements, so TiDB handles the
+	// schema changes itself.
+	switch cfg.TikvImporter.Backend {
+	case config.BackendLocal, config.BackendImporter:
+		if cfg.Cron.CheckSchemaChange.Duration > 0 {
+			getTableFunc := backend.FetchRemoteTableModels
+			if !g.OwnsSQLExecutor() {
+				getTableFunc = g.GetTables
+			}
+			rc.schemaWatcher = newSchemaWatcher(getTableFunc)
+		}
 	}
 
 	return rc, nil
 }
 
-func (rc *Controller) Close() {
-	rc.backend.Close()
+// IOLimiter returns the limiter of the writes of the local backend, which can
+// be adjusted while the task is running. It's nil for the other backends.
+func (rc *Controller) IOLimiter() *local.TableIOLimiter {
+	return rc.ioLimiter
+}
+
+// Close closes the backend and the connection to TiDB. It stops waiting for
+// the backend once ctx is done.
+func (rc *Controller) Close(ctx context.Context) {
+	if err := rc.backend.CloseWithContext(ctx); err != nil {
+		log.L().Warn("close backend failed", log.ShortError(err))
+	}
 	rc.tidbGlue.GetSQLExecutor().Close()
 }
 
@@ -474,6 +608,11 @@ type restoreSchemaWorker struct {
 	wg    sync.WaitGroup
 	glue  glue.Glue
 	store storage.ExternalStorage
+
+	// used to generate the schema of the tables without schema files.
+	cfg           *config.Config
+	ioWorkers     *worker.Pool
+	templateStore storage.ExternalStorage
 }
 
 func (worker *restoreSchemaWorker) makeJobs(
@@ -517,10 +656,13 @@ func (worker *restoreSchemaWorker) makeJobs(
 				// we already has this table in TiDB.
 				// we should skip ddl job and let SchemaValid check.
 				continue
-			} else if tblMeta.SchemaFile.FileMeta.Path == "" {
-				return errors.Errorf("table `%s`.`%s` schema not found", dbMeta.Name, tblMeta.Name)
 			}
-			sql, err := tblMeta.GetSchema(worker.ctx, worker.store)
+			var sql string
+			if tblMeta.SchemaFile.FileMeta.Path == "" {
+				sql, err = worker.generateSchema(tblMeta)
+			} else {
+				sql, err = tblMeta.GetSchema(worker.ctx, worker.store)
+			}
 			if sql != "" {
 				stmts, err := createTableIfNotExistsStmt(worker.glue.GetParser(), sql, dbMeta.Name, tblMeta.Name)
 				if err != nil {
@@ -589,6 +731,48 @@ func (worker *restoreSchemaWorker) makeJobs(
 	return nil
 }
 
+// generateSchema generates the CREATE TABLE statement of a table which has no
+// schema file in the data source, either from the schema template directory or
+// by inferring the column types from the CSV data files.
+func (worker *restoreSchemaWorker) generateSchema(tblMeta *mydump.MDTableMeta) (string, error) {
+	logger := log.With(zap.String("table", common.UniqueTable(tblMeta.DB, tblMeta.Name)))
+	if worker.templateStore != nil {
+		// the template of a specific table takes precedence over the one shared by tables of the same name.
+		for _, name := range []string{
+			fmt.Sprintf("%s.%s-schema.sql", tblMeta.DB, tblMeta.Name),
+			fmt.Sprintf("%s-schema.sql", tblMeta.Name),
+		} {
+			exists, err := worker.templateStore.FileExists(worker.ctx, name)
+			if err != nil {
+				return "", errors.Trace(err)
+			}
+			if !exists {
+				continue
+			}
+			logger.Info("create table from schema template", zap.String("template", name))
+			template := mydump.FileInfo{
+				FileMeta: mydump.SourceFileMeta{Path: name, Type: mydump.SourceTypeTableSchema},
+			}
+			schema, err := mydump.ExportStatement(worker.ctx, worker.templateStore, template, worker.cfg.Mydumper.CharacterSet)
+			if err != nil {
+				return "", errors.Annotatef(err, "failed to read schema template %s", name)
+			}
+			return string(schema), nil
+		}
+	}
+	if worker.cfg != nil && worker.cfg.Mydumper.InferSchema {
+		schema, err := mydump.InferTableSchema(worker.ctx, &worker.cfg.Mydumper, tblMeta, worker.store, worker.ioWorkers, inferSchemaSampleRows)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		if schema != "" {
+			logger.Info("create table with inferred schema", zap.String("schema", schema))
+			return schema, nil
+		}
+	}
+	return "", errors.Errorf("table `%s`.`%s` schema not found", tblMeta.DB, tblMeta.Name)
+}
+
 func (worker *restoreSchemaWorker) doJob() {
 	var session *sql.Conn
 	defer func() {
@@ -700,30 +884,15 @@ func (worker *restoreSchemaWorker) appendJob(job *schemaJob) error {
 }
 
 func (rc *Controller) restoreSchema(ctx context.Context) error {
-	// create table with schema file
-	// we can handle the duplicated created with createIfNotExist statement
-	// and we will check the schema in TiDB is valid with the datafile in DataCheck later.
-	logTask := log.L().Begin(zap.InfoLevel, "restore all schema")
-	concurrency := utils.MinInt(rc.cfg.App.RegionConcurrency, 8)
-	childCtx, cancel := context.WithCancel(ctx)
-	worker := restoreSchemaWorker{
-		ctx:   childCtx,
-		quit:  cancel,
-		jobCh: make(chan *schemaJob, concurrency),
-		errCh: make(chan error),
-		glue:  rc.tidbGlue,
-		store: rc.store,
-	}
-	for i := 0; i < concurrency; i++ {
-		go worker.doJob()
-	}
 	getTableFunc := rc.backend.FetchRemoteTableModels
 	if !rc.tidbGlue.OwnsSQLExecutor() {
 		getTableFunc = rc.tidbGlue.GetTables
 	}
-	err := worker.makeJobs(rc.dbMetas, getTableFunc)
-	logTask.End(zap.ErrorLevel, err)
-	if err != nil {
+	// a dry run never changes the target, so the tables are expected to
+	// exist there already.
+	if rc.cfg.TikvImporter.Backend == config.BackendNoop {
+		log.L().Info("skip restoring schema in dry run")
+	} else if err := rc.createSchemas(ctx, getTableFunc); err != nil {
 		return err
 	}
 
@@ -768,6 +937,47 @@ func (rc *Controller) restoreSchema(ctx context.Context) error {
 	return nil
 }
 
+// createSchemas creates the databases, tables and views with the schema files.
+func (rc *Controller) createSchemas(
+	ctx context.Context,
+	getTableFunc func(context.Context, string) ([]*model.TableInfo, error),
+) error {
+	// create table with schema file
+	// we can handle the duplicated created with createIfNotExist statement
+	// and we will check the schema in TiDB is valid with the datafile in DataCheck later.
+	logTask := log.L().Begin(zap.InfoLevel, "restore all schema")
+	concurrency := utils.MinInt(rc.cfg.App.RegionConcurrency, 8)
+	childCtx, cancel := context.WithCancel(ctx)
+	worker := restoreSchemaWorker{
+		ctx:       childCtx,
+		quit:      cancel,
+		jobCh:     make(chan *schemaJob, concurrency),
+		errCh:     make(chan error),
+		glue:      rc.tidbGlue,
+		store:     rc.store,
+		cfg:       rc.cfg,
+		ioWorkers: rc.ioWorkers,
+	}
+	if dir := rc.cfg.Mydumper.SchemaTemplateDir; len(dir) > 0 {
+		u, err := storage.ParseBackend(dir, nil)
+		if err != nil {
+			cancel()
+			return errors.Annotate(err, "invalid schema template directory")
+		}
+		worker.templateStore, err = storage.New(ctx, u, &storage.ExternalStorageOptions{})
+		if err != nil {
+			cancel()
+			return errors.Annotate(err, "failed to open schema template directory")
+		}
+	}
+	for i := 0; i < concurrency; i++ {
+		go worker.doJob()
+	}
+	err := worker.makeJobs(rc.dbMetas, getTableFunc)
+	logTask.End(zap.ErrorLevel, err)
+	return err
+}
+
 // verifyCheckpoint check whether previous task checkpoint is compatible with task config
 func verifyCheckpoint(cfg *config.Config, taskCp *checkpoints.TaskCheckpoint) error {
 	if taskCp == nil {
@@ -846,6 +1056,32 @@ func verifyLocalFile(ctx context.Context, cpdb checkpoints.DB, dir string) error
 	return nil
 }
 
+// cleanupOrphanEngines removes the local engines which are left by a previous
+// crashed run and no longer needed to resume from the checkpoints.
+func cleanupOrphanEngines(
+	ctx context.Context,
+	cpdb checkpoints.DB,
+	dbMetas []*mydump.MDDatabaseMeta,
+	dir string,
+	ttl time.Duration,
+) error {
+	var tableNames []string
+	for _, dbMeta := range dbMetas {
+		for _, tableMeta := range dbMeta.Tables {
+			tableNames = append(tableNames, common.UniqueTable(dbMeta.Name, tableMeta.Name))
+		}
+	}
+	referenced, err := local.ReferencedEngines(ctx, cpdb, tableNames)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	removed, err := local.CleanupOrphanEngines(dir, referenced, ttl)
+	if len(removed) > 0 {
+		log.L().Info("cleaned up orphan engines", zap.Int("count", len(removed)), zap.Duration("ttl", ttl))
+	}
+	return errors.Trace(err)
+}
+
 func (rc *Controller) estimateChunkCountIntoMetrics(ctx context.Context) error {
 	estimatedChunkCount := 0.0
 	estimatedEngineCnt := int64(0)
@@ -1036,8 +1272,10 @@ func (rc *Controller) buildRunPeriodicActionAndCancelFunc(ctx context.Context, s
 	})
 
 	var switchModeChan <-chan time.Time
-	// tidb backend don't need to switch tikv to import mode
-	if rc.cfg.TikvImporter.Backend != config.BackendTiDB && rc.cfg.Cron.SwitchMode.Duration > 0 {
+	// tidb backend don't need to switch tikv to import mode, and noop backend doesn't write anything into tikv.
+	needSwitchMode := rc.cfg.TikvImporter.Backend != config.BackendTiDB && rc.cfg.TikvImporter.Backend != config.BackendNoop &&
+		rc.cfg.Cron.SwitchMode.Duration > 0
+	if needSwitchMode {
 		switchModeTicker := time.NewTicker(rc.cfg.Cron.SwitchMode.Duration)
 		cancelFuncs = append(cancelFuncs, func(bool) { switchModeTicker.Stop() })
 		cancelFuncs = append(cancelFuncs, func(do bool) {
@@ -1059,14 +1297,20 @@ func (rc *Controller) buildRunPeriodicActionAndCancelFunc(ctx context.Context, s
 		checkQuotaChan = checkQuotaTicker.C
 	}
 
+	var checkSchemaChan <-chan time.Time
+	if rc.schemaWatcher != nil {
+		checkSchemaTicker := time.NewTicker(rc.cfg.Cron.CheckSchemaChange.Duration)
+		cancelFuncs = append(cancelFuncs, func(bool) { checkSchemaTicker.Stop() })
+		checkSchemaChan = checkSchemaTicker.C
+	}
+
 	return func() {
 			defer func() {
 				for _, f := range closeFuncs {
 					f()
 				}
 			}()
-			// tidb backend don't need to switch tikv to import mode
-			if rc.cfg.TikvImporter.Backend != config.BackendTiDB && rc.cfg.Cron.SwitchMode.Duration > 0 {
+			if needSwitchMode {
 				rc.switchToImportMode(ctx)
 			}
 			start := time.Now()
@@ -1171,6 +1415,11 @@ func (rc *Controller) buildRunPeriodicActionAndCancelFunc(ctx context.Context, s
 					// otherwise we perform an emergency import.
 					rc.enforceDiskQuota(ctx)
 
+				case <-checkSchemaChan:
+					// pause the encoders of the tables being altered, and
+					// rebuild them after the DDL jobs are done.
+					rc.schemaWatcher.check(ctx)
+
 				case <-glueProgressTicker.C:
 					finished := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished))
 					rc.tidbGlue.Record(glue.RecordFinishedChunk, uint64(finished))
@@ -1292,6 +1541,8 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 		return errors.Trace(err)
 	}
 	ctx2 := context.WithValue(ctx, &checksumManagerKey, manager)
+	// scheduler is set before the first task is sent to the workers.
+	var scheduler *tableScheduler
 	for i := 0; i < rc.cfg.App.IndexConcurrency; i++ {
 		go func() {
 			for task := range taskCh {
@@ -1303,6 +1554,7 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 				web.BroadcastError(task.tr.tableName, err)
 				metric.RecordTableCount("completed", err)
 				restoreErr.Set(err)
+				scheduler.finish(task.tr.tableName)
 				if needPostProcess {
 					postProcessTaskChan <- task
 				}
@@ -1392,6 +1644,8 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 		return errors.New("TiDB Lightning has detected tables with illegal checkpoints; please remove these checkpoints first")
 	}
 
+	tasks := make(map[string]task, totalTables)
+	scheduledTables := make([]*scheduledTable, 0, totalTables)
 	for _, dbMeta := range rc.dbMetas {
 		dbInfo := rc.dbInfos[dbMeta.Name]
 		for _, tableMeta := range dbMeta.Tables {
@@ -1409,13 +1663,25 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 			if err != nil {
 				return errors.Trace(err)
 			}
+			tasks[tableName] = task{tr: tr, cp: cp}
+			scheduledTables = append(scheduledTables, &scheduledTable{name: tableName, size: tableMeta.TotalSize})
+		}
+	}
 
-			wg.Add(1)
-			select {
-			case taskCh <- task{tr: tr, cp: cp}:
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+	scheduler = newTableScheduler(rc.cfg.App.TableOrder, rc.cfg.App.TableDependencies, scheduledTables)
+	for {
+		tableName, err := scheduler.next(ctx)
+		if err != nil {
+			return err
+		}
+		if tableName == "" {
+			break
+		}
+		wg.Add(1)
+		select {
+		case taskCh <- tasks[tableName]:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 
@@ -1456,6 +1722,9 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 	wg.Wait()
 
 	err = restoreErr.Get()
+	if err == nil {
+		err = rc.errorSummaries.failedChunksError()
+	}
 	logTask.End(zap.ErrorLevel, err)
 	return err
 }
@@ -1556,10 +1825,21 @@ func (tr *TableRestore) restoreTable(
 	}
 
 	// 2. Restore engines (if still needed)
+	rc.schemaWatcher.register(tr)
 	err := tr.restoreEngines(ctx, rc, cp)
+	rc.schemaWatcher.unregister(tr)
 	if err != nil {
 		return false, errors.Trace(err)
 	}
+	if errorRows := tr.errorRows.Load(); errorRows > 0 {
+		tr.logger.Warn("some rows failed to be encoded and have been quarantined",
+			zap.Int64("errorRows", errorRows), zap.String("dir", rc.cfg.App.QuarantineDir))
+		rc.errorSummaries.recordErrorRows(tr.tableName, errorRows)
+	}
+	if chunks := quarantinedChunks(cp); len(chunks) > 0 {
+		tr.logger.Warn("some chunks failed repeatedly and have been quarantined", zap.Int("chunks", len(chunks)))
+		rc.errorSummaries.recordFailedChunks(tr.tableName, chunks)
+	}
 
 	err = metaMgr.UpdateTableStatus(ctx, metaStatusRestoreFinished)
 	if err != nil {
@@ -1570,6 +1850,21 @@ func (tr *TableRestore) restoreTable(
 	return tr.postProcess(ctx, rc, cp, false /* force-analyze */, metaMgr)
 }
 
+// quarantinedChunks returns the chunks of the table which have been quarantined
+// in this or the previous runs, in the order of the engines.
+func quarantinedChunks(cp *checkpoints.TableCheckpoint) []failedChunk {
+	var chunks []failedChunk
+	for engineID, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			if len(chunk.Failure) > 0 {
+				chunks = append(chunks, failedChunk{engineID: engineID, key: chunk.Key, failure: chunk.Failure})
+			}
+		}
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].engineID < chunks[j].engineID })
+	return chunks
+}
+
 // do full compaction for the whole data.
 func (rc *Controller) fullCompact(ctx context.Context) error {
 	if !rc.cfg.PostRestore.Compact {
@@ -1679,6 +1974,7 @@ func (rc *Controller) enforceDiskQuota(ctx context.Context) {
 
 			if len(largeEngines) == 0 && inProgressLargeEngines == 0 {
 				logger.Debug("disk quota respected")
+				web.BroadcastDiskQuota(false)
 				return
 			}
 
@@ -1689,6 +1985,7 @@ func (rc *Controller) enforceDiskQuota(ctx context.Context) {
 			}
 
 			logger.Warn("disk quota exceeded")
+			web.BroadcastDiskQuota(true)
 			if len(largeEngines) == 0 {
 				logger.Warn("all large engines are already importing, keep blocking all writes")
 				continue
@@ -1704,6 +2001,8 @@ func (rc *Controller) enforceDiskQuota(ctx context.Context) {
 			// we then import every large engines one by one and complete.
 			// if any engine failed to import, we just try again next time, since the data are still intact.
 			rc.diskQuotaState.Store(diskQuotaStateImporting)
+			// import the largest engines first, which free the most disk.
+			rc.backend.SortEnginesBySize(ctx, largeEngines)
 			task := logger.Begin(zap.WarnLevel, "importing large engines for disk quota")
 			var importErr error
 			for _, engine := range largeEngines {
@@ -1875,6 +2174,8 @@ type chunkRestore struct {
 	parser mydump.Parser
 	index  int
 	chunk  *checkpoints.ChunkCheckpoint
+	// schemaVersion is the version of the table schema used by the encoder.
+	schemaVersion int64
 }
 
 func newChunkRestore(
@@ -1961,16 +2262,98 @@ func getColumnNames(tableInfo *model.TableInfo, permutation []int) []string {
 	return names
 }
 
+// csvNullIfTokens maps the fields of a CSV file to the `null-if` tokens of
+// their columns.
+func csvNullIfTokens(nullIf map[string][]string, tableInfo *model.TableInfo, permutation []int) [][]string {
+	if len(nullIf) == 0 {
+		return nil
+	}
+	var fieldTokens [][]string
+	for i, col := range tableInfo.Columns {
+		if i >= len(permutation) {
+			break
+		}
+		tokens, ok := nullIf[col.Name.L]
+		if !ok || permutation[i] < 0 {
+			continue
+		}
+		for len(fieldTokens) <= permutation[i] {
+			fieldTokens = append(fieldTokens, nil)
+		}
+		fieldTokens[permutation[i]] = tokens
+	}
+	return fieldTokens
+}
+
+// applyNullIf sets the fields equal to one of the `null-if` tokens to NULL.
+func applyNullIf(row []types.Datum, fieldTokens [][]string) {
+	for i, tokens := range fieldTokens {
+		if i >= len(row) || row[i].Kind() != types.KindString {
+			continue
+		}
+		for _, token := range tokens {
+			if row[i].GetString() == token {
+				row[i].SetNull()
+				break
+			}
+		}
+	}
+}
+
+// charsetConvertFields marks the fields whose columns store non-binary strings,
+// which should be converted by `mydumper.data-character-set`.
+func charsetConvertFields(tableInfo *model.TableInfo, permutation []int) []bool {
+	var fields []bool
+	for i, col := range tableInfo.Columns {
+		if i >= len(permutation) {
+			break
+		}
+		if permutation[i] < 0 || col.Charset == charset.CharsetBin {
+			continue
+		}
+		switch {
+		case types.IsString(col.Tp), col.Tp == mysql.TypeEnum, col.Tp == mysql.TypeSet, col.Tp == mysql.TypeJSON:
+		default:
+			continue
+		}
+		for len(fields) <= permutation[i] {
+			fields = append(fields, false)
+		}
+		fields[permutation[i]] = true
+	}
+	return fields
+}
+
+// convertCharset converts the string values of the marked fields to utf8mb4.
+func convertCharset(cc *mydump.CharsetConvertor, row []types.Datum, fields []bool) error {
+	for i, convert := range fields {
+		if !convert || i >= len(row) || row[i].Kind() != types.KindString {
+			continue
+		}
+		s, err := cc.Decode(row[i].GetString())
+		if err != nil {
+			return errors.Annotatef(err, "failed to convert field %d", i)
+		}
+		row[i].SetString(s, row[i].Collation())
+	}
+	return nil
+}
+
 var (
 	maxKVQueueSize         = 32             // Cache at most this number of rows before blocking the encode loop
 	minDeliverBytes uint64 = 96 * units.KiB // 96 KB (data + index). batch at least this amount of bytes to reduce number of messages
 )
 
 type deliveredKVs struct {
-	kvs     kv.Row // if kvs is nil, this indicated we've got the last message.
+	kvs     kv.Row // if kvs is nil, the row has been quarantined and only the progress is delivered.
 	columns []string
 	offset  int64
 	rowID   int64
+	// errorRows is the number of rows of the chunk quarantined so far.
+	errorRows int64
+	// budget is the memory budget acquired for the packet of the rows, it's
+	// set on the last row of the packet.
+	budget int64
 }
 
 type deliverResult struct {
@@ -1998,6 +2381,11 @@ func (cr *chunkRestore) deliverLoop(
 	// Fetch enough KV pairs from the source.
 	dataKVs := rc.backend.MakeEmptyRows()
 	indexKVs := rc.backend.MakeEmptyRows()
+	// budget is the memory budget of the packets fetched but not written yet.
+	var budget int64
+	defer func() {
+		rc.memBudget.Release(budget)
+	}()
 
 	dataSynced := true
 	for !channelClosed {
@@ -2008,6 +2396,7 @@ func (cr *chunkRestore) deliverLoop(
 		// chunk checkpoint should stay the same
 		offset := cr.chunk.Chunk.Offset
 		rowID := cr.chunk.Chunk.PrevRowIDMax
+		errorRows := cr.chunk.ErrorRows
 
 	populate:
 		for dataChecksum.SumSize()+indexChecksum.SumSize() < minDeliverBytes {
@@ -2018,10 +2407,14 @@ func (cr *chunkRestore) deliverLoop(
 					break populate
 				}
 				for _, p := range kvPacket {
-					p.kvs.ClassifyAndAppend(&dataKVs, &dataChecksum, &indexKVs, &indexChecksum)
+					if p.kvs != nil {
+						p.kvs.ClassifyAndAppend(&dataKVs, &dataChecksum, &indexKVs, &indexChecksum)
+					}
 					columns = p.columns
 					offset = p.offset
 					rowID = p.rowID
+					errorRows = p.errorRows
+					budget += p.budget
 				}
 			case <-ctx.Done():
 				err = ctx.Err()
@@ -2076,6 +2469,8 @@ func (cr *chunkRestore) deliverLoop(
 
 		dataKVs = dataKVs.Clear()
 		indexKVs = indexKVs.Clear()
+		rc.memBudget.Release(budget)
+		budget = 0
 
 		// Update the table, and save a checkpoint.
 		// (the write to the importer is effective immediately, thus update these here)
@@ -2085,6 +2480,7 @@ func (cr *chunkRestore) deliverLoop(
 		cr.chunk.Checksum.Add(&indexChecksum)
 		cr.chunk.Chunk.Offset = offset
 		cr.chunk.Chunk.PrevRowIDMax = rowID
+		cr.chunk.ErrorRows = errorRows
 
 		if dataChecksum.SumKVS() != 0 || indexChecksum.SumKVS() != 0 {
 			// No need to save checkpoint if nothing was delivered.
@@ -2149,6 +2545,8 @@ func saveCheckpoint(rc *Controller, t *TableRestore, engineID int32, chunk *chec
 			Pos:               chunk.Chunk.Offset,
 			RowID:             chunk.Chunk.PrevRowIDMax,
 			ColumnPermutation: chunk.ColumnPermutation,
+			ErrorRows:         chunk.ErrorRows,
+			Failure:           chunk.Failure,
 		},
 	}
 }
@@ -2183,10 +2581,52 @@ func (cr *chunkRestore) encodeLoop(
 
 	pauser, maxKvPairsCnt := rc.pauser, rc.cfg.TikvImporter.MaxKVPairs
 	initializedColumns, reachEOF := false, false
+	var nullIfTokens [][]string
+	var charsetFields []bool
+	// parquet files store the strings in utf8 already.
+	var charsetConvertor *mydump.CharsetConvertor
+	if cr.chunk.FileMeta.Type != mydump.SourceTypeParquet {
+		if charsetConvertor, err = mydump.NewCharsetConvertor(&rc.cfg.Mydumper); err != nil {
+			return
+		}
+	}
+	errorRows := cr.chunk.ErrorRows
+	quarantine := newQuarantineWriter(rc.cfg.App.QuarantineDir, t.dbInfo.Name, t.tableInfo.Name, cr.chunk)
+	// the encoders rebuilt after the target table is altered are owned by the
+	// encode loop, while the initial one is closed by the caller.
+	ownedEncoder := false
+	defer func() {
+		if ownedEncoder {
+			kvEncoder.Close()
+		}
+		if closeErr := quarantine.close(); err == nil {
+			err = closeErr
+		}
+	}()
 	for !reachEOF {
 		if err = pauser.Wait(ctx); err != nil {
 			return
 		}
+		encTable, schemaVersion, schemaErr := t.waitSchema(ctx)
+		if schemaErr != nil {
+			err = errors.Trace(schemaErr)
+			return
+		}
+		if schemaVersion != cr.schemaVersion {
+			var newEncoder kv.Encoder
+			if newEncoder, err = cr.newEncoder(encTable, rc); err != nil {
+				return
+			}
+			if ownedEncoder {
+				kvEncoder.Close()
+			}
+			kvEncoder, ownedEncoder = newEncoder, true
+			cr.schemaVersion = schemaVersion
+			logger.Info("rebuild the encoder for the altered table", zap.Int64("schemaVersion", schemaVersion))
+		}
+		if cr.schemaVersion > 0 {
+			cr.chunk.ColumnPermutation = adjustColumnPermutation(cr.chunk.ColumnPermutation, encTable.Meta())
+		}
 		offset, _ := cr.parser.Pos()
 		if offset >= cr.chunk.Chunk.EndOffset {
 			break
@@ -2212,6 +2652,15 @@ func (cr *chunkRestore) encodeLoop(
 						if err = t.initializeColumns(columnNames, cr.chunk); err != nil {
 							return
 						}
+						if cr.schemaVersion > 0 {
+							cr.chunk.ColumnPermutation = adjustColumnPermutation(cr.chunk.ColumnPermutation, encTable.Meta())
+						}
+					}
+					if cr.chunk.FileMeta.Type == mydump.SourceTypeCSV {
+						nullIfTokens = csvNullIfTokens(rc.cfg.Mydumper.CSV.NullIf, t.tableInfo.Core, cr.chunk.ColumnPermutation)
+					}
+					if charsetConvertor != nil {
+						charsetFields = charsetConvertFields(t.tableInfo.Core, cr.chunk.ColumnPermutation)
 					}
 					initializedColumns = true
 				}
@@ -2225,16 +2674,30 @@ func (cr *chunkRestore) encodeLoop(
 			readDur += time.Since(readDurStart)
 			encodeDurStart := time.Now()
 			lastRow := cr.parser.LastRow()
-			// sql -> kv
-			kvs, encodeErr := kvEncoder.Encode(logger, lastRow.Row, lastRow.RowID, cr.chunk.ColumnPermutation, curOffset)
+			var kvs kv.Row
+			encodeErr := convertCharset(charsetConvertor, lastRow.Row, charsetFields)
+			if encodeErr == nil {
+				applyNullIf(lastRow.Row, nullIfTokens)
+				// sql -> kv
+				kvs, encodeErr = kvEncoder.Encode(logger, lastRow.Row, lastRow.RowID, cr.chunk.ColumnPermutation, curOffset)
+			}
 			encodeDur += time.Since(encodeDurStart)
-			cr.parser.RecycleRow(lastRow)
 			if encodeErr != nil {
-				err = errors.Annotatef(encodeErr, "in file %s at offset %d", &cr.chunk.Key, newOffset)
+				encodeErr = errors.Annotatef(encodeErr, "in file %s at offset %d", &cr.chunk.Key, newOffset)
+				err = cr.quarantineRow(t, rc, quarantine, lastRow.Row, curOffset, encodeErr)
+			}
+			cr.parser.RecycleRow(lastRow)
+			if err != nil {
 				return
 			}
-			kvPacket = append(kvPacket, deliveredKVs{kvs: kvs, columns: columnNames, offset: newOffset, rowID: rowID})
-			kvSize += kvs.Size()
+			if encodeErr != nil {
+				// the row is quarantined, only the progress is delivered.
+				kvs = nil
+				errorRows++
+			} else {
+				kvSize += kvs.Size()
+			}
+			kvPacket = append(kvPacket, deliveredKVs{kvs: kvs, columns: columnNames, offset: newOffset, rowID: rowID, errorRows: errorRows})
 			failpoint.Inject("mock-kv-size", func(val failpoint.Value) {
 				kvSize += uint64(val.(int))
 			})
@@ -2243,7 +2706,6 @@ func (cr *chunkRestore) encodeLoop(
 			// so add this check.
 			if kvSize >= minDeliverBytes || len(kvPacket) >= maxKvPairsCnt || newOffset == cr.chunk.Chunk.EndOffset {
 				canDeliver = true
-				kvSize = 0
 			}
 			curOffset = newOffset
 		}
@@ -2255,7 +2717,14 @@ func (cr *chunkRestore) encodeLoop(
 
 		if len(kvPacket) != 0 {
 			deliverKvStart := time.Now()
+			// wait for the memory budget of the packet, which is given back
+			// once the packet is written.
+			last := &kvPacket[len(kvPacket)-1]
+			if last.budget, err = rc.memBudget.Acquire(ctx, int64(kvSize)); err != nil {
+				return
+			}
 			if err = send(kvPacket); err != nil {
+				rc.memBudget.Release(last.budget)
 				return
 			}
 			metric.RowKVDeliverSecondsHistogram.Observe(time.Since(deliverKvStart).Seconds())
@@ -2266,24 +2735,59 @@ func (cr *chunkRestore) encodeLoop(
 	return
 }
 
-func (cr *chunkRestore) restore(
-	ctx context.Context,
+// quarantineRow writes the row which failed to be encoded into the quarantine
+// file. It returns the encode error instead if `max-error` is not set or the
+// table has used up its budget.
+func (cr *chunkRestore) quarantineRow(
 	t *TableRestore,
-	engineID int32,
-	dataEngine, indexEngine *backend.LocalEngineWriter,
 	rc *Controller,
+	quarantine *quarantineWriter,
+	row []types.Datum,
+	offset int64,
+	encodeErr error,
 ) error {
-	// Create the encoder.
-	kvEncoder, err := rc.backend.NewEncoder(t.encTable, &kv.SessionOptions{
+	maxError := rc.cfg.App.MaxError
+	if maxError <= 0 {
+		return encodeErr
+	}
+	if t.errorRows.Inc() > maxError {
+		return errors.Annotatef(encodeErr, "too many rows of table %s failed to be encoded (max-error = %d)", t.tableName, maxError)
+	}
+	return quarantine.append(&quarantineRecord{
+		Path:   cr.chunk.Key.Path,
+		Offset: offset,
+		Row:    quarantineRowValues(row),
+		Error:  encodeErr.Error(),
+	})
+}
+
+func (cr *chunkRestore) newEncoder(tbl table.Table, rc *Controller) (kv.Encoder, error) {
+	return rc.backend.NewEncoder(tbl, &kv.SessionOptions{
 		SQLMode:   rc.cfg.TiDB.SQLMode,
 		Timestamp: cr.chunk.Timestamp,
 		SysVars:   rc.sysVars,
 		// use chunk.PrevRowIDMax as the auto random seed, so it can stay the same value after recover from checkpoint.
 		AutoRandomSeed: cr.chunk.Chunk.PrevRowIDMax,
 	})
+}
+
+func (cr *chunkRestore) restore(
+	ctx context.Context,
+	t *TableRestore,
+	engineID int32,
+	dataEngine, indexEngine *backend.LocalEngineWriter,
+	rc *Controller,
+) error {
+	// Create the encoder.
+	encTable, schemaVersion, err := t.waitSchema(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	kvEncoder, err := cr.newEncoder(encTable, rc)
 	if err != nil {
 		return err
 	}
+	cr.schemaVersion = schemaVersion
 
 	kvsCh := make(chan []deliveredKVs, maxKVQueueSize)
 	deliverCompleteCh := make(chan deliverResult)
@@ -2292,6 +2796,12 @@ func (cr *chunkRestore) restore(
 		kvEncoder.Close()
 		kvEncoder = nil
 		close(kvsCh)
+		// give back the memory budget of the packets never delivered.
+		for kvPacket := range kvsCh {
+			for _, p := range kvPacket {
+				rc.memBudget.Release(p.budget)
+			}
+		}
 	}()
 
 	go func() {
@@ -40,6 +40,7 @@ import (
 	"github.com/pingcap/parser/types"
 	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
 	"github.com/pingcap/tidb/ddl"
+	tidbtypes "github.com/pingcap/tidb/types"
 	tmock "github.com/pingcap/tidb/util/mock"
 
 	"github.com/pingcap/br/pkg/lightning/backend"
@@ -133,6 +134,75 @@ func (s *restoreSuite) TestErrorSummaries(c *C) {
 	})
 }
 
+func (s *restoreSuite) TestErrorSummariesErrorRows(c *C) {
+	logger, buffer := log.MakeTestLogger()
+
+	es := makeErrorSummaries(logger)
+	es.recordErrorRows("first", 3)
+	es.emitLog()
+
+	c.Assert(buffer.Lines(), DeepEquals, []string{
+		`{"$lvl":"WARN","$msg":"tables have quarantined rows","count":1}`,
+		`{"$lvl":"WARN","$msg":"-","table":"first","errorRows":3}`,
+	})
+}
+
+func (s *restoreSuite) TestErrorSummariesFailedChunks(c *C) {
+	logger, buffer := log.MakeTestLogger()
+
+	cp := &checkpoints.TableCheckpoint{
+		Engines: map[int32]*checkpoints.EngineCheckpoint{
+			1: {Chunks: []*checkpoints.ChunkCheckpoint{
+				{Key: checkpoints.ChunkCheckpointKey{Path: "b.csv", Offset: 0}, Failure: "broken"},
+			}},
+			0: {Chunks: []*checkpoints.ChunkCheckpoint{
+				{Key: checkpoints.ChunkCheckpointKey{Path: "a.csv", Offset: 0}},
+				{Key: checkpoints.ChunkCheckpointKey{Path: "a.csv", Offset: 100}, Failure: "io error"},
+			}},
+		},
+	}
+	chunks := quarantinedChunks(cp)
+	c.Assert(chunks, HasLen, 2)
+
+	es := makeErrorSummaries(logger)
+	c.Assert(es.failedChunksError(), IsNil)
+	es.recordFailedChunks("first", chunks)
+	c.Assert(es.failedChunksError(), ErrorMatches, "2 chunks of 1 tables have been quarantined, .*")
+	es.emitLog()
+
+	c.Assert(buffer.Lines(), DeepEquals, []string{
+		`{"$lvl":"WARN","$msg":"tables have quarantined chunks, re-run them by ` + "`tidb-lightning-ctl -checkpoint-mark dirty`" + `","count":1}`,
+		`{"$lvl":"WARN","$msg":"-","table":"first","engine":0,"chunk":"a.csv:100","failure":"io error"}`,
+		`{"$lvl":"WARN","$msg":"-","table":"first","engine":1,"chunk":"b.csv:0","failure":"broken"}`,
+	})
+}
+
+func (s *restoreSuite) TestChunkFailure(c *C) {
+	c.Assert(chunkFailure(errors.New("broken")), Equals, "broken")
+	failure := chunkFailure(errors.New(strings.Repeat("x", maxChunkFailureLen-1) + "中文"))
+	c.Assert(failure, HasLen, maxChunkFailureLen-1)
+}
+
+func (s *restoreSuite) TestGenerateSchemaFromTemplate(c *C) {
+	dir := c.MkDir()
+	err := os.WriteFile(filepath.Join(dir, "t-schema.sql"), []byte("CREATE TABLE `x` (`a` int);\n"), 0o644)
+	c.Assert(err, IsNil)
+	err = os.WriteFile(filepath.Join(dir, "db2.t-schema.sql"), []byte("CREATE TABLE `x` (`b` int);\n"), 0o644)
+	c.Assert(err, IsNil)
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	worker := &restoreSchemaWorker{ctx: context.Background(), cfg: config.NewConfig(), templateStore: store}
+	schema, err := worker.generateSchema(&mydump.MDTableMeta{DB: "db1", Name: "t"})
+	c.Assert(err, IsNil)
+	c.Assert(schema, Equals, "CREATE TABLE `x` (`a` int);")
+	schema, err = worker.generateSchema(&mydump.MDTableMeta{DB: "db2", Name: "t"})
+	c.Assert(err, IsNil)
+	c.Assert(schema, Equals, "CREATE TABLE `x` (`b` int);")
+	_, err = worker.generateSchema(&mydump.MDTableMeta{DB: "db1", Name: "t2"})
+	c.Assert(err, ErrorMatches, "table `db1`.`t2` schema not found")
+}
+
 func (s *restoreSuite) TestVerifyCheckpoint(c *C) {
 	dir := c.MkDir()
 	cpdb := checkpoints.NewFileCheckpointsDB(filepath.Join(dir, "cp.pb"))
@@ -737,6 +807,65 @@ func (s *tableRestoreSuite) TestGetColumnsNames(c *C) {
 	c.Assert(getColumnNames(s.tableInfo.Core, []int{-1, 1, -1, 0}), DeepEquals, []string{"_tidb_rowid", "b"})
 }
 
+func (s *tableRestoreSuite) TestApplyNullIf(c *C) {
+	c.Assert(csvNullIfTokens(nil, s.tableInfo.Core, []int{0, 1, 2, -1}), IsNil)
+
+	nullIf := map[string][]string{"b": {"", "N/A"}, "c": {"-"}}
+	// the fields are (c, a), b is missing.
+	tokens := csvNullIfTokens(nullIf, s.tableInfo.Core, []int{1, -1, 0, -1})
+	c.Assert(tokens, DeepEquals, [][]string{{"-"}})
+
+	tokens = csvNullIfTokens(nullIf, s.tableInfo.Core, []int{0, 1, 2, -1})
+	c.Assert(tokens, DeepEquals, [][]string{nil, {"", "N/A"}, {"-"}})
+	row := []tidbtypes.Datum{
+		tidbtypes.NewStringDatum("N/A"),
+		tidbtypes.NewStringDatum("N/A"),
+		tidbtypes.NewStringDatum("--"),
+	}
+	applyNullIf(row, tokens)
+	c.Assert(row[0].GetString(), Equals, "N/A")
+	c.Assert(row[1].IsNull(), IsTrue)
+	c.Assert(row[2].GetString(), Equals, "--")
+
+	row = []tidbtypes.Datum{tidbtypes.NewStringDatum("1"), tidbtypes.NewStringDatum("")}
+	applyNullIf(row, tokens)
+	c.Assert(row[1].IsNull(), IsTrue)
+}
+
+func (s *tableRestoreSuite) TestConvertCharset(c *C) {
+	tableInfo := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` int, `b` varchar(20), "+
+		"`c` varbinary(20), `d` enum('中', 'x'), `e` text)")
+	// the fields are (e, d, c, a, b)
+	fields := charsetConvertFields(tableInfo, []int{3, 4, 2, 1, 0, -1})
+	c.Assert(fields, DeepEquals, []bool{true, true, false, false, true})
+	// the fields are (b, a), the others are missing.
+	fields = charsetConvertFields(tableInfo, []int{1, 0, -1, -1, -1, -1})
+	c.Assert(fields, DeepEquals, []bool{true})
+
+	cfg := config.NewConfig()
+	cfg.Mydumper.DataCharacterSet = "gbk"
+	cc, err := mydump.NewCharsetConvertor(&cfg.Mydumper)
+	c.Assert(err, IsNil)
+	fields = charsetConvertFields(tableInfo, []int{0, 1, 2, 3, 4, -1})
+	row := []tidbtypes.Datum{
+		tidbtypes.NewStringDatum("\xd6\xd0"),
+		tidbtypes.NewStringDatum("\xd6\xd0"),
+		tidbtypes.NewStringDatum("\xd6\xd0"),
+		tidbtypes.NewStringDatum("\xd6\xd0"),
+		tidbtypes.NewDatum(nil),
+	}
+	c.Assert(convertCharset(cc, row, fields), IsNil)
+	c.Assert(row[0].GetString(), Equals, "\xd6\xd0")
+	c.Assert(row[1].GetString(), Equals, "中")
+	c.Assert(row[2].GetString(), Equals, "\xd6\xd0")
+	c.Assert(row[3].GetString(), Equals, "中")
+	c.Assert(row[4].IsNull(), IsTrue)
+
+	row = []tidbtypes.Datum{tidbtypes.NewStringDatum("1"), tidbtypes.NewStringDatum("\xd6")}
+	err = convertCharset(cc, row, fields)
+	c.Assert(err, ErrorMatches, `failed to convert field 1: "\\xd6" is not a valid gbk string: invalid data encoding`)
+}
+
 func (s *tableRestoreSuite) TestInitializeColumns(c *C) {
 	ccp := &checkpoints.ChunkCheckpoint{}
 	c.Assert(s.tr.initializeColumns(nil, ccp), IsNil)
@@ -1329,7 +1458,7 @@ func (s *chunkRestoreSuite) TestEncodeLoopColumnsMismatch(c *C) {
 
 	kvsCh := make(chan []deliveredKVs, 2)
 	deliverCompleteCh := make(chan deliverResult)
-	kvEncoder, err := tidb.NewTiDBBackend(nil, config.ReplaceOnDup).NewEncoder(
+	kvEncoder, err := tidb.NewTiDBBackend(nil, config.ReplaceOnDup, backend.DefaultBackendConfig()).NewEncoder(
 		s.tr.encTable,
 		&kv.SessionOptions{
 			SQLMode:   s.cfg.TiDB.SQLMode,
@@ -1476,7 +1605,7 @@ func (s *restoreSchemaSuite) SetUpTest(c *C) {
 		FetchRemoteTableModels(gomock.Any(), gomock.Any()).
 		AnyTimes().
 		Return(s.tableInfos, nil)
-	mockBackend.EXPECT().Close()
+	mockBackend.EXPECT().CloseWithContext(gomock.Any()).Return(nil)
 	s.rc.backend = backend.MakeBackend(mockBackend)
 
 	mockDB, sqlMock, err := sqlmock.New()
@@ -1499,7 +1628,7 @@ func (s *restoreSchemaSuite) SetUpTest(c *C) {
 }
 
 func (s *restoreSchemaSuite) TearDownTest(c *C) {
-	s.rc.Close()
+	s.rc.Close(s.ctx)
 	s.controller.Finish()
 }
 
@@ -1508,6 +1637,37 @@ func (s *restoreSchemaSuite) TestRestoreSchemaSuccessful(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *restoreSchemaSuite) TestRestoreSchemaDryRun(c *C) {
+	backendName := s.rc.cfg.TikvImporter.Backend
+	s.rc.cfg.TikvImporter.Backend = config.BackendNoop
+	defer func() {
+		s.rc.cfg.TikvImporter.Backend = backendName
+	}()
+
+	// no DDL may be executed on the target, neither through the DB nor
+	// through the SQL executor.
+	mockExecutor := mock.NewMockSQLExecutor(s.controller)
+	mockExecutor.EXPECT().
+		QueryStringsWithLog(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil, nil)
+	mockExecutor.EXPECT().Close().AnyTimes()
+	mockTiDBGlue := mock.NewMockGlue(s.controller)
+	mockTiDBGlue.EXPECT().
+		OwnsSQLExecutor().
+		AnyTimes().
+		Return(true)
+	mockTiDBGlue.EXPECT().
+		GetSQLExecutor().
+		AnyTimes().
+		Return(mockExecutor)
+	s.rc.tidbGlue = mockTiDBGlue
+
+	err := s.rc.restoreSchema(s.ctx)
+	c.Assert(err, IsNil)
+	c.Assert(s.rc.dbInfos["fakedb"].Tables, HasLen, len(s.tableInfos))
+}
+
 func (s *restoreSchemaSuite) TestRestoreSchemaFailed(c *C) {
 	injectErr := errors.New("Something wrong")
 	mockSession := mock.NewMockSession(s.controller)
@@ -0,0 +1,418 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/collate"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/backend/kv"
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/mydump"
+)
+
+// sampleVerifyRowsPerChunk is the number of rows sampled from each chunk. The
+// sampled chunks are read through to pick the rows uniformly inside them, so
+// the rows are spread over a limited number of chunks.
+const sampleVerifyRowsPerChunk = 64
+
+// sampleVerifyTable samples some rows from the source files of the table, and
+// checks that every sampled row can be found in the target table. A sampled
+// row is looked up by a unique key, and its values are compared after being
+// converted to the types of the columns, so the precision of the FLOAT and
+// DOUBLE columns and the collations of the string columns are respected.
+func (tr *TableRestore) sampleVerifyTable(ctx context.Context, rc *Controller, cp *checkpoints.TableCheckpoint) error {
+	tableInfo := tr.tableInfo.Core
+	if !hasUniqueKey(tableInfo) {
+		tr.logger.Warn("skip sample verify for table without primary key or unique index to look up the rows")
+		return nil
+	}
+	// the rows conflicting with the others are silently replaced or ignored,
+	// so the sampled rows may be legitimately missing.
+	if rc.cfg.TikvImporter.Backend == config.BackendTiDB && rc.cfg.TikvImporter.OnDuplicate != config.ErrorOnDup {
+		tr.logger.Warn("skip sample verify for table imported with duplicated rows allowed",
+			zap.String("on-duplicate", rc.cfg.TikvImporter.OnDuplicate))
+		return nil
+	}
+
+	n := rc.cfg.PostRestore.SampleVerifyRows
+	chunks := sampleChunks(cp, (n+sampleVerifyRowsPerChunk-1)/sampleVerifyRowsPerChunk)
+	if len(chunks) == 0 {
+		return nil
+	}
+	rowsPerChunk := (n + len(chunks) - 1) / len(chunks)
+
+	db, err := rc.tidbGlue.GetDB()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	exec := common.SQLWithRetry{DB: db, Logger: tr.logger}
+	se := kv.NewSession(&kv.SessionOptions{SQLMode: rc.cfg.TiDB.SQLMode, SysVars: rc.sysVars})
+
+	task := tr.logger.Begin(zap.InfoLevel, "sample verify")
+	sampled, mismatched := 0, 0
+	for _, chunk := range chunks {
+		rows, err := tr.readSampleRows(ctx, rc, chunk, rowsPerChunk)
+		if err != nil {
+			task.End(zap.ErrorLevel, err)
+			return errors.Trace(err)
+		}
+		for _, row := range rows {
+			q, err := buildSampleVerifyQuery(se, tr.tableName, tableInfo, chunk.ColumnPermutation, row.datums)
+			if err != nil {
+				task.End(zap.ErrorLevel, err)
+				return errors.Trace(err)
+			}
+			// the row can't be looked up by any unique key.
+			if q == nil {
+				continue
+			}
+			var found []sql.NullString
+			err = exec.Transact(ctx, "sample verify", func(c context.Context, tx *sql.Tx) error {
+				found = nil
+				values := make([]sql.NullString, len(q.columns))
+				dest := make([]interface{}, len(values))
+				for i := range values {
+					dest[i] = &values[i]
+				}
+				err := tx.QueryRowContext(c, q.query, q.args...).Scan(dest...)
+				if err == sql.ErrNoRows {
+					return nil
+				}
+				found = values
+				return err
+			})
+			if err != nil {
+				task.End(zap.ErrorLevel, err)
+				return errors.Trace(err)
+			}
+			sampled++
+			if found == nil {
+				mismatched++
+				tr.logger.Warn("sampled row not found in the target table",
+					zap.String("path", chunk.Key.Path),
+					zap.Int64("offset", row.offset),
+					zap.Array("row", kv.RowArrayMarshaler(row.datums)))
+				continue
+			}
+			col, err := q.compare(se, found)
+			if err != nil {
+				task.End(zap.ErrorLevel, err)
+				return errors.Trace(err)
+			}
+			if col != nil {
+				mismatched++
+				tr.logger.Warn("sampled row differs from the one in the target table",
+					zap.String("path", chunk.Key.Path),
+					zap.Int64("offset", row.offset),
+					zap.String("column", col.Name.O),
+					zap.Array("row", kv.RowArrayMarshaler(row.datums)))
+			}
+		}
+	}
+	task.End(zap.ErrorLevel, nil, zap.Int("sampled", sampled), zap.Int("mismatched", mismatched))
+
+	if mismatched > 0 {
+		return errors.Errorf("sample verify failed: %d of %d sampled rows of table %s are not found in the target table",
+			mismatched, sampled, tr.tableName)
+	}
+	return nil
+}
+
+type sampleRow struct {
+	offset int64
+	datums []types.Datum
+}
+
+// readSampleRows reads through the chunk and samples at most n rows of it
+// uniformly by reservoir sampling.
+func (tr *TableRestore) readSampleRows(
+	ctx context.Context,
+	rc *Controller,
+	chunk *checkpoints.ChunkCheckpoint,
+	n int,
+) ([]sampleRow, error) {
+	// the offset of the chunk checkpoint is the progress of the import, so
+	// restart from the original offset.
+	chunkCp := chunk.DeepCopy()
+	chunkCp.Chunk.Offset = chunkCp.Key.Offset
+	cr, err := newChunkRestore(ctx, 0, rc.cfg, chunkCp, rc.ioWorkers, rc.store, tr.tableInfo)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer cr.close()
+
+	var charsetConvertor *mydump.CharsetConvertor
+	if chunkCp.FileMeta.Type != mydump.SourceTypeParquet {
+		if charsetConvertor, err = mydump.NewCharsetConvertor(&rc.cfg.Mydumper); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	var charsetFields []bool
+
+	rows := make([]sampleRow, 0, n)
+	seen := 0
+	for {
+		offset, _ := cr.parser.Pos()
+		if offset >= chunkCp.Chunk.EndOffset {
+			break
+		}
+		switch err := cr.parser.ReadRow(); errors.Cause(err) {
+		case nil:
+		case io.EOF:
+			return rows, nil
+		default:
+			return nil, errors.Annotatef(err, "failed to read sample rows from %s", chunkCp.Key.Path)
+		}
+		if len(chunkCp.ColumnPermutation) == 0 {
+			if err := tr.initializeColumns(cr.parser.Columns(), chunkCp); err != nil {
+				return nil, errors.Trace(err)
+			}
+			chunk.ColumnPermutation = chunkCp.ColumnPermutation
+		}
+		if charsetConvertor != nil && charsetFields == nil {
+			charsetFields = charsetConvertFields(tr.tableInfo.Core, chunkCp.ColumnPermutation)
+		}
+		lastRow := cr.parser.LastRow()
+		seen++
+		slot := len(rows)
+		if slot == n {
+			// the row replaces a sampled one with the probability n/seen.
+			if slot = rand.Intn(seen); slot >= n {
+				cr.parser.RecycleRow(lastRow)
+				continue
+			}
+		}
+		datums := make([]types.Datum, len(lastRow.Row))
+		for i := range lastRow.Row {
+			lastRow.Row[i].Copy(&datums[i])
+		}
+		cr.parser.RecycleRow(lastRow)
+		// the rows which cannot be converted are not imported, skip them.
+		if err := convertCharset(charsetConvertor, datums, charsetFields); err != nil {
+			continue
+		}
+		row := sampleRow{offset: offset, datums: datums}
+		if slot == len(rows) {
+			rows = append(rows, row)
+		} else {
+			rows[slot] = row
+		}
+	}
+	return rows, nil
+}
+
+// sampleChunks randomly chooses at most n chunks of the table to sample rows
+// from.
+func sampleChunks(cp *checkpoints.TableCheckpoint, n int) []*checkpoints.ChunkCheckpoint {
+	engineIDs := make([]int32, 0, len(cp.Engines))
+	for engineID := range cp.Engines {
+		engineIDs = append(engineIDs, engineID)
+	}
+	sort.Slice(engineIDs, func(i, j int) bool { return engineIDs[i] < engineIDs[j] })
+
+	var chunks []*checkpoints.ChunkCheckpoint
+	for _, engineID := range engineIDs {
+		chunks = append(chunks, cp.Engines[engineID].Chunks...)
+	}
+	rand.Shuffle(len(chunks), func(i, j int) { chunks[i], chunks[j] = chunks[j], chunks[i] })
+	if len(chunks) > n {
+		chunks = chunks[:n]
+	}
+	return chunks
+}
+
+func hasUniqueKey(tableInfo *model.TableInfo) bool {
+	return len(sampleVerifyKeys(tableInfo)) > 0
+}
+
+// sampleVerifyKeys returns the columns of the unique keys by which the sampled
+// rows can be looked up, with the primary key first. The keys containing
+// generated columns, or approximate values which can't be matched exactly,
+// are left out.
+func sampleVerifyKeys(tableInfo *model.TableInfo) [][]*model.ColumnInfo {
+	var keys [][]*model.ColumnInfo
+	if tableInfo.PKIsHandle {
+		if pk := tableInfo.GetPkColInfo(); pk != nil {
+			keys = append(keys, []*model.ColumnInfo{pk})
+		}
+	}
+	indices := make([]*model.IndexInfo, 0, len(tableInfo.Indices))
+	for _, index := range tableInfo.Indices {
+		if index.Primary {
+			indices = append([]*model.IndexInfo{index}, indices...)
+		} else if index.Unique {
+			indices = append(indices, index)
+		}
+	}
+NextIndex:
+	for _, index := range indices {
+		key := make([]*model.ColumnInfo, 0, len(index.Columns))
+		for _, indexCol := range index.Columns {
+			col := tableInfo.Columns[indexCol.Offset]
+			// the prefix of the column doesn't identify the row by its value.
+			if indexCol.Length != types.UnspecifiedLength {
+				continue NextIndex
+			}
+			key = append(key, col)
+		}
+		keys = append(keys, key)
+	}
+
+	usable := keys[:0]
+NextKey:
+	for _, key := range keys {
+		for _, col := range key {
+			switch {
+			case col.IsGenerated(), col.Tp == mysql.TypeFloat, col.Tp == mysql.TypeDouble:
+				continue NextKey
+			}
+		}
+		usable = append(usable, key)
+	}
+	return usable
+}
+
+// sampleVerifyQuery looks up a sampled row in the target table.
+type sampleVerifyQuery struct {
+	query string
+	args  []interface{}
+	// columns are the columns selected, and expected are the sampled values of
+	// them converted to the column types.
+	columns  []*model.ColumnInfo
+	expected []types.Datum
+}
+
+// buildSampleVerifyQuery builds the query selecting the row in the target
+// table with the same unique key as the sampled row. It returns nil if none of
+// the unique keys is given by the sampled row.
+func buildSampleVerifyQuery(
+	se sessionctx.Context,
+	tableName string,
+	tableInfo *model.TableInfo,
+	permutation []int,
+	row []types.Datum,
+) (*sampleVerifyQuery, error) {
+	q := &sampleVerifyQuery{}
+	values := make(map[int64]*types.Datum, len(tableInfo.Columns))
+	selected := make([]string, 0, len(tableInfo.Columns))
+	for i, col := range tableInfo.Columns {
+		if i >= len(permutation) {
+			break
+		}
+		idx := permutation[i]
+		if idx < 0 || idx >= len(row) || col.IsGenerated() {
+			continue
+		}
+		datum := row[idx]
+		// the value is generated on import.
+		if datum.IsNull() && (mysql.HasAutoIncrementFlag(col.Flag) ||
+			(tableInfo.ContainsAutoRandomBits() && mysql.HasPriKeyFlag(col.Flag))) {
+			continue
+		}
+		casted, err := table.CastValue(se, datum, col, false, false)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		values[col.ID] = &casted
+		// the text of these types may not be converted back to the same value.
+		if col.Tp == mysql.TypeBit || col.Tp == mysql.TypeTimestamp {
+			continue
+		}
+		selected = append(selected, common.EscapeIdentifier(col.Name.O))
+		q.columns = append(q.columns, col)
+		q.expected = append(q.expected, casted)
+	}
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+NextKey:
+	for _, key := range sampleVerifyKeys(tableInfo) {
+		conds := make([]string, 0, len(key))
+		args := make([]interface{}, 0, len(key))
+		for _, col := range key {
+			value, ok := values[col.ID]
+			if !ok || value.IsNull() {
+				continue NextKey
+			}
+			conds = append(conds, common.EscapeIdentifier(col.Name.O)+" = ?")
+			args = append(args, datumToSQLArg(value))
+		}
+		q.query = fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+			strings.Join(selected, ", "), tableName, strings.Join(conds, " AND "))
+		q.args = args
+		return q, nil
+	}
+	return nil, nil
+}
+
+// compare compares the values found in the target table with the expected
+// ones, and returns the first column differing.
+func (q *sampleVerifyQuery) compare(se sessionctx.Context, found []sql.NullString) (*model.ColumnInfo, error) {
+	sc := se.GetSessionVars().StmtCtx
+	for i, col := range q.columns {
+		actual := types.NewDatum(nil)
+		if found[i].Valid {
+			var err error
+			actual, err = types.NewStringDatum(found[i].String).ConvertTo(sc, &col.FieldType)
+			if err != nil {
+				return nil, errors.Annotatef(err, "failed to convert the value of column %s", col.Name.O)
+			}
+		}
+		collator := collate.GetBinaryCollator()
+		if types.IsString(col.Tp) {
+			collator = collate.GetCollator(col.Collate)
+		}
+		cmp, err := q.expected[i].Compare(sc, &actual, collator)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if cmp != 0 {
+			return col, nil
+		}
+	}
+	return nil, nil
+}
+
+func datumToSQLArg(d *types.Datum) interface{} {
+	switch d.Kind() {
+	case types.KindNull:
+		return nil
+	case types.KindInt64, types.KindUint64, types.KindFloat32, types.KindFloat64, types.KindString, types.KindBytes:
+		return d.GetValue()
+	default:
+		s, err := d.ToString()
+		if err != nil {
+			return d.GetValue()
+		}
+		return s
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"database/sql"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/ddl"
+	"github.com/pingcap/tidb/types"
+	tmock "github.com/pingcap/tidb/util/mock"
+
+	"github.com/pingcap/br/pkg/lightning/backend/kv"
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+)
+
+type sampleVerifySuite struct{}
+
+var _ = Suite(&sampleVerifySuite{})
+
+func mockSampleVerifyTableInfo(c *C, createSQL string) *model.TableInfo {
+	p := parser.New()
+	se := tmock.NewContext()
+	node, err := p.ParseOneStmt(createSQL, "utf8mb4", "utf8mb4_bin")
+	c.Assert(err, IsNil)
+	tableInfo, err := ddl.MockTableInfo(se, node.(*ast.CreateTableStmt), 1)
+	c.Assert(err, IsNil)
+	return tableInfo
+}
+
+func (s *sampleVerifySuite) TestBuildSampleVerifyQuery(c *C) {
+	tableInfo := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`id` int AUTO_INCREMENT PRIMARY KEY, "+
+		"`name` varchar(20), `v` decimal(10, 2), `f` float, `g` int AS (`id` + 1), `ignored` int, "+
+		"UNIQUE KEY `uk_name` (`name`))")
+	c.Assert(hasUniqueKey(tableInfo), IsTrue)
+	se := kv.NewSession(&kv.SessionOptions{})
+
+	row := []types.Datum{
+		types.NewStringDatum("abc"),
+		types.NewDatum(nil),
+		types.NewStringDatum("1.50"),
+		types.NewStringDatum("1.1"),
+	}
+	// the columns in the file are `name`, `id`, `v`, `f`
+	permutation := []int{1, 0, 2, 3, -1, -1, -1}
+	q, err := buildSampleVerifyQuery(se, "`db`.`t`", tableInfo, permutation, row)
+	c.Assert(err, IsNil)
+	// the NULL auto increment column is skipped, so the row is looked up by
+	// the unique key.
+	c.Assert(q.query, Equals, "SELECT `name`, `v`, `f` FROM `db`.`t` WHERE `name` = ?")
+	c.Assert(q.args, DeepEquals, []interface{}{"abc"})
+
+	// the values are compared after converted to the column types.
+	col, err := q.compare(se, []sql.NullString{
+		{String: "abc", Valid: true},
+		{String: "1.5", Valid: true},
+		{String: "1.1", Valid: true},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(col, IsNil)
+	col, err = q.compare(se, []sql.NullString{
+		{String: "abc", Valid: true},
+		{String: "1.51", Valid: true},
+		{String: "1.1", Valid: true},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(col.Name.O, Equals, "v")
+	col, err = q.compare(se, []sql.NullString{
+		{String: "abc", Valid: true},
+		{String: "1.5", Valid: true},
+		{},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(col.Name.O, Equals, "f")
+
+	row[1] = types.NewIntDatum(7)
+	q, err = buildSampleVerifyQuery(se, "`db`.`t`", tableInfo, permutation, row)
+	c.Assert(err, IsNil)
+	c.Assert(q.query, Equals, "SELECT `id`, `name`, `v`, `f` FROM `db`.`t` WHERE `id` = ?")
+	c.Assert(q.args, DeepEquals, []interface{}{int64(7)})
+
+	// neither key is given by the row.
+	row[0] = types.NewDatum(nil)
+	row[1] = types.NewDatum(nil)
+	q, err = buildSampleVerifyQuery(se, "`db`.`t`", tableInfo, permutation, row)
+	c.Assert(err, IsNil)
+	c.Assert(q, IsNil)
+
+	noKey := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` int, `b` int, KEY `idx_a` (`a`))")
+	c.Assert(hasUniqueKey(noKey), IsFalse)
+	uniqueKey := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` int, `b` int, UNIQUE KEY `uk_a` (`a`))")
+	c.Assert(hasUniqueKey(uniqueKey), IsTrue)
+	// the approximate values can't be looked up exactly.
+	floatKey := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` double, `b` int, UNIQUE KEY `uk_a` (`a`))")
+	c.Assert(hasUniqueKey(floatKey), IsFalse)
+	prefixKey := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` varchar(20), UNIQUE KEY `uk_a` (`a`(4)))")
+	c.Assert(hasUniqueKey(prefixKey), IsFalse)
+}
+
+func (s *sampleVerifySuite) TestSampleChunks(c *C) {
+	cp := &checkpoints.TableCheckpoint{
+		Engines: map[int32]*checkpoints.EngineCheckpoint{
+			-1: {},
+			0:  {Chunks: []*checkpoints.ChunkCheckpoint{{}, {}, {}}},
+			1:  {Chunks: []*checkpoints.ChunkCheckpoint{{}, {}}},
+		},
+	}
+	c.Assert(sampleChunks(cp, 3), HasLen, 3)
+	c.Assert(sampleChunks(cp, 100), HasLen, 5)
+	c.Assert(sampleChunks(&checkpoints.TableCheckpoint{}, 100), HasLen, 0)
+}
@@ -0,0 +1,239 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/table/tables"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/log"
+)
+
+// schemaWatcher periodically fetches the schemas of the tables being restored,
+// so that the encoders don't keep producing KVs for a stale schema when the
+// target tables are altered during the import.
+type schemaWatcher struct {
+	getTables func(context.Context, string) ([]*model.TableInfo, error)
+
+	mu     sync.Mutex
+	tables map[string]*TableRestore
+}
+
+func newSchemaWatcher(getTables func(context.Context, string) ([]*model.TableInfo, error)) *schemaWatcher {
+	return &schemaWatcher{
+		getTables: getTables,
+		tables:    make(map[string]*TableRestore),
+	}
+}
+
+// register starts watching the schema of the table.
+func (w *schemaWatcher) register(tr *TableRestore) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.tables[tr.tableName] = tr
+	w.mu.Unlock()
+}
+
+// unregister stops watching the schema of the table.
+func (w *schemaWatcher) unregister(tr *TableRestore) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	delete(w.tables, tr.tableName)
+	w.mu.Unlock()
+}
+
+// check fetches the schemas of all the watched tables, and applies the
+// changes to them. The lock is held during the whole check, so the schema of a
+// table is never changed after it is unregistered.
+func (w *schemaWatcher) check(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	tablesByDB := make(map[string][]*TableRestore)
+	for _, tr := range w.tables {
+		tablesByDB[tr.dbInfo.Name] = append(tablesByDB[tr.dbInfo.Name], tr)
+	}
+
+	for dbName, trs := range tablesByDB {
+		tableInfos, err := w.getTables(ctx, dbName)
+		if err != nil {
+			log.L().Warn("failed to fetch the schemas of the target tables", zap.String("db", dbName), log.ShortError(err))
+			continue
+		}
+		tableMap := make(map[string]*model.TableInfo, len(tableInfos))
+		for _, tblInfo := range tableInfos {
+			tableMap[tblInfo.Name.L] = tblInfo
+		}
+		for _, tr := range trs {
+			tr.applySchemaChange(tableMap[tr.tableInfo.Core.Name.L])
+		}
+	}
+}
+
+// waitSchema blocks while the target table is being altered, and returns the
+// table to encode the rows with and its version.
+func (tr *TableRestore) waitSchema(ctx context.Context) (table.Table, int64, error) {
+	if tr.schemaPauser != nil {
+		if err := tr.schemaPauser.Wait(ctx); err != nil {
+			return nil, 0, err
+		}
+	}
+	tr.schemaMu.RLock()
+	defer tr.schemaMu.RUnlock()
+	return tr.encTable, tr.schemaVersion, tr.schemaErr
+}
+
+// applySchemaChange updates the schema used to encode the rows to the latest
+// one fetched from the target database. The encoding is paused until all the
+// running DDL jobs of the table are done. Only appending columns is supported,
+// since the KVs already written are still valid in this case. Any other change
+// fails the table.
+func (tr *TableRestore) applySchemaChange(newInfo *model.TableInfo) {
+	tr.schemaMu.Lock()
+	defer tr.schemaMu.Unlock()
+	if tr.schemaErr != nil {
+		return
+	}
+
+	if newInfo == nil {
+		tr.schemaErr = errors.Errorf("table %s is dropped during the import", tr.tableName)
+		tr.logger.Error("target table is dropped")
+		tr.schemaPauser.Resume()
+		return
+	}
+	current := tr.encTable.Meta()
+	if newInfo.UpdateTS == current.UpdateTS {
+		return
+	}
+	if !isSchemaSettled(newInfo) {
+		if !tr.schemaPauser.IsPaused() {
+			tr.logger.Info("target table is being altered, pause encoding")
+			tr.schemaPauser.Pause()
+		}
+		return
+	}
+
+	defer tr.schemaPauser.Resume()
+	if err := checkSchemaCompatible(current, newInfo); err != nil {
+		tr.schemaErr = errors.Annotatef(err, "table %s is altered during the import", tr.tableName)
+		tr.logger.Error("target table is altered incompatibly", log.ShortError(err))
+		return
+	}
+	tbl, err := tables.TableFromMeta(tr.alloc, newInfo)
+	if err != nil {
+		tr.schemaErr = errors.Annotatef(err, "failed to tables.TableFromMeta %s", tr.tableName)
+		return
+	}
+	tr.encTable = tbl
+	tr.schemaVersion++
+	tr.logger.Info("target table is altered, rebuild the encoders",
+		zap.Int64("schemaVersion", tr.schemaVersion), zap.Int("columns", len(newInfo.Columns)))
+}
+
+// isSchemaSettled checks whether there is no running DDL job changing the
+// columns or indices of the table.
+func isSchemaSettled(tblInfo *model.TableInfo) bool {
+	if tblInfo.State != model.StatePublic {
+		return false
+	}
+	for _, col := range tblInfo.Columns {
+		if col.State != model.StatePublic {
+			return false
+		}
+	}
+	for _, idx := range tblInfo.Indices {
+		if idx.State != model.StatePublic {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSchemaCompatible checks whether the KVs encoded with the old schema are
+// still valid with the new schema, i.e. only columns are appended, which are
+// filled by their original default values when the old rows are read.
+func checkSchemaCompatible(oldInfo, newInfo *model.TableInfo) error {
+	if oldInfo.PKIsHandle != newInfo.PKIsHandle || oldInfo.IsCommonHandle != newInfo.IsCommonHandle {
+		return errors.New("the primary key is changed")
+	}
+	if len(newInfo.Columns) < len(oldInfo.Columns) {
+		return errors.New("some columns are dropped")
+	}
+	for i, col := range oldInfo.Columns {
+		newCol := newInfo.Columns[i]
+		if newCol.ID != col.ID {
+			return errors.Errorf("column %s is dropped or moved", col.Name.O)
+		}
+		if !newCol.FieldType.Equal(&col.FieldType) {
+			return errors.Errorf("column %s is modified", col.Name.O)
+		}
+	}
+	for _, col := range newInfo.Columns[len(oldInfo.Columns):] {
+		if col.IsGenerated() && col.GeneratedStored {
+			return errors.Errorf("stored generated column %s is added", col.Name.O)
+		}
+	}
+
+	oldIndices := make(map[int64]struct{}, len(oldInfo.Indices))
+	for _, idx := range oldInfo.Indices {
+		oldIndices[idx.ID] = struct{}{}
+	}
+	for _, idx := range newInfo.Indices {
+		if _, ok := oldIndices[idx.ID]; !ok {
+			return errors.Errorf("index %s is added", idx.Name.O)
+		}
+		delete(oldIndices, idx.ID)
+	}
+	if len(oldIndices) > 0 {
+		return errors.New("some indices are dropped")
+	}
+	return nil
+}
+
+// adjustColumnPermutation extends the column permutation for the columns
+// appended to the table. The new columns are not in the data files.
+func adjustColumnPermutation(permutation []int, tblInfo *model.TableInfo) []int {
+	expected := len(tblInfo.Columns)
+	hasRowID := common.TableHasAutoRowID(tblInfo)
+	if hasRowID {
+		expected++
+	}
+	if len(permutation) == 0 || len(permutation) >= expected {
+		return permutation
+	}
+
+	res := make([]int, 0, expected)
+	if hasRowID {
+		res = append(res, permutation[:len(permutation)-1]...)
+	} else {
+		res = append(res, permutation...)
+	}
+	for len(res) < len(tblInfo.Columns) {
+		res = append(res, -1)
+	}
+	if hasRowID {
+		res = append(res, permutation[len(permutation)-1])
+	}
+	return res
+}
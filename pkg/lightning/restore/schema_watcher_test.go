@@ -0,0 +1,136 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+)
+
+type schemaWatcherSuite struct{}
+
+var _ = Suite(&schemaWatcherSuite{})
+
+func (s *schemaWatcherSuite) newTableRestore(c *C, tableInfo *model.TableInfo) *TableRestore {
+	dbInfo := &checkpoints.TidbDBInfo{Name: "db", Tables: map[string]*checkpoints.TidbTableInfo{}}
+	tblInfo := &checkpoints.TidbTableInfo{ID: tableInfo.ID, DB: "db", Name: "t", Core: tableInfo}
+	dbInfo.Tables["t"] = tblInfo
+	tr, err := NewTableRestore("`db`.`t`", nil, dbInfo, tblInfo, &checkpoints.TableCheckpoint{}, nil)
+	c.Assert(err, IsNil)
+	return tr
+}
+
+func mockPublicTableInfo(c *C, createSQL string, updateTS uint64) *model.TableInfo {
+	tableInfo := mockSampleVerifyTableInfo(c, createSQL)
+	tableInfo.State = model.StatePublic
+	for _, col := range tableInfo.Columns {
+		col.State = model.StatePublic
+	}
+	for _, idx := range tableInfo.Indices {
+		idx.State = model.StatePublic
+	}
+	tableInfo.UpdateTS = updateTS
+	return tableInfo
+}
+
+func (s *schemaWatcherSuite) TestCheckSchemaCompatible(c *C) {
+	oldInfo := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` int, `b` varchar(10), KEY `idx_b` (`b`))")
+
+	// appending columns is compatible.
+	newInfo := oldInfo.Clone()
+	newInfo.Columns = append(newInfo.Columns, &model.ColumnInfo{ID: 3, Name: model.NewCIStr("c"), Offset: 2})
+	c.Assert(checkSchemaCompatible(oldInfo, newInfo), IsNil)
+
+	newInfo = oldInfo.Clone()
+	newInfo.Columns = newInfo.Columns[:1]
+	c.Assert(checkSchemaCompatible(oldInfo, newInfo), ErrorMatches, "some columns are dropped")
+
+	newInfo = oldInfo.Clone()
+	newInfo.Columns[0], newInfo.Columns[1] = newInfo.Columns[1], newInfo.Columns[0]
+	c.Assert(checkSchemaCompatible(oldInfo, newInfo), ErrorMatches, "column a is dropped or moved")
+
+	newInfo = oldInfo.Clone()
+	newInfo.Columns[1].Flen = 20
+	c.Assert(checkSchemaCompatible(oldInfo, newInfo), ErrorMatches, "column b is modified")
+
+	newInfo = oldInfo.Clone()
+	newInfo.Indices = append(newInfo.Indices, &model.IndexInfo{ID: 2, Name: model.NewCIStr("idx_a")})
+	c.Assert(checkSchemaCompatible(oldInfo, newInfo), ErrorMatches, "index idx_a is added")
+
+	newInfo = oldInfo.Clone()
+	newInfo.Indices = nil
+	c.Assert(checkSchemaCompatible(oldInfo, newInfo), ErrorMatches, "some indices are dropped")
+}
+
+func (s *schemaWatcherSuite) TestAdjustColumnPermutation(c *C) {
+	tableInfo := mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` int, `b` int, `c` int)")
+	// the table has _tidb_rowid, and `c` is appended.
+	c.Assert(adjustColumnPermutation([]int{1, 0, 2}, tableInfo), DeepEquals, []int{1, 0, -1, 2})
+	c.Assert(adjustColumnPermutation([]int{1, 0, -1, 2}, tableInfo), DeepEquals, []int{1, 0, -1, 2})
+	c.Assert(adjustColumnPermutation(nil, tableInfo), IsNil)
+
+	tableInfo = mockSampleVerifyTableInfo(c, "CREATE TABLE `t` (`a` int PRIMARY KEY, `b` int, `c` int)")
+	c.Assert(adjustColumnPermutation([]int{0}, tableInfo), DeepEquals, []int{0, -1, -1})
+}
+
+func (s *schemaWatcherSuite) TestApplySchemaChange(c *C) {
+	ctx := context.Background()
+	oldInfo := mockPublicTableInfo(c, "CREATE TABLE `t` (`a` int, `b` int)", 1)
+	tr := s.newTableRestore(c, oldInfo)
+
+	// nothing changed.
+	tr.applySchemaChange(oldInfo.Clone())
+	_, version, err := tr.waitSchema(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(version, Equals, int64(0))
+
+	// the encoding is paused while a column is being added.
+	newInfo := mockPublicTableInfo(c, "CREATE TABLE `t` (`a` int, `b` int, `c` int)", 2)
+	newInfo.Columns[2].State = model.StateWriteOnly
+	tr.applySchemaChange(newInfo)
+	c.Assert(tr.schemaPauser.IsPaused(), IsTrue)
+
+	newInfo = newInfo.Clone()
+	newInfo.UpdateTS = 3
+	newInfo.Columns[2].State = model.StatePublic
+	tr.applySchemaChange(newInfo)
+	c.Assert(tr.schemaPauser.IsPaused(), IsFalse)
+	tbl, version, err := tr.waitSchema(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(version, Equals, int64(1))
+	c.Assert(tbl.Meta().Columns, HasLen, 3)
+
+	// the table fails if the change is incompatible.
+	newInfo = newInfo.Clone()
+	newInfo.UpdateTS = 4
+	newInfo.Columns = newInfo.Columns[1:]
+	tr.applySchemaChange(newInfo)
+	_, _, err = tr.waitSchema(ctx)
+	c.Assert(err, ErrorMatches, "table `db`.`t` is altered during the import: some columns are dropped")
+
+	tr = s.newTableRestore(c, oldInfo)
+	w := newSchemaWatcher(func(context.Context, string) ([]*model.TableInfo, error) {
+		return nil, nil
+	})
+	w.register(tr)
+	w.check(ctx)
+	_, _, err = tr.waitSchema(ctx)
+	c.Assert(err, ErrorMatches, "table `db`.`t` is dropped during the import")
+	w.unregister(tr)
+	c.Assert(w.tables, HasLen, 0)
+}
@@ -16,6 +16,7 @@ package restore
 import (
 	"context"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/pingcap/tidb/meta/autoid"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/table/tables"
+	"go.uber.org/atomic"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
@@ -38,6 +40,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/metric"
 	"github.com/pingcap/br/pkg/lightning/mydump"
 	verify "github.com/pingcap/br/pkg/lightning/verification"
+	"github.com/pingcap/br/pkg/lightning/web"
 	"github.com/pingcap/br/pkg/lightning/worker"
 	"github.com/pingcap/br/pkg/utils"
 )
@@ -48,11 +51,23 @@ type TableRestore struct {
 	dbInfo    *checkpoints.TidbDBInfo
 	tableInfo *checkpoints.TidbTableInfo
 	tableMeta *mydump.MDTableMeta
-	encTable  table.Table
 	alloc     autoid.Allocators
 	logger    log.Logger
 
+	// schemaMu protects encTable, schemaVersion and schemaErr, which are
+	// updated by the schema watcher when the target table is altered during
+	// the import.
+	schemaMu      sync.RWMutex
+	encTable      table.Table
+	schemaVersion int64
+	schemaErr     error
+	// schemaPauser pauses the encoders while the target table is being altered.
+	schemaPauser *common.Pauser
+
 	ignoreColumns []string
+	// errorRows is the number of rows of the table which failed to be encoded
+	// and have been quarantined.
+	errorRows atomic.Int64
 }
 
 func NewTableRestore(
@@ -69,7 +84,7 @@ func NewTableRestore(
 		return nil, errors.Annotatef(err, "failed to tables.TableFromMeta %s", tableName)
 	}
 
-	return &TableRestore{
+	tr := &TableRestore{
 		tableName:     tableName,
 		dbInfo:        dbInfo,
 		tableInfo:     tableInfo,
@@ -78,7 +93,10 @@ func NewTableRestore(
 		alloc:         idAlloc,
 		logger:        log.With(zap.String("table", tableName)),
 		ignoreColumns: ignoreColumns,
-	}, nil
+		schemaPauser:  common.NewPauser(),
+	}
+	tr.errorRows.Store(cp.CountErrorRows())
+	return tr, nil
 }
 
 func (tr *TableRestore) Close() {
@@ -434,7 +452,8 @@ func (tr *TableRestore) restoreEngine(
 
 	// Restore table data
 	for chunkIndex, chunk := range cp.Chunks {
-		if chunk.Chunk.Offset >= chunk.Chunk.EndOffset {
+		// the quarantined chunks are skipped until they are marked dirty.
+		if chunk.Chunk.Offset >= chunk.Chunk.EndOffset || len(chunk.Failure) > 0 {
 			continue
 		}
 
@@ -499,7 +518,16 @@ func (tr *TableRestore) restoreEngine(
 				rc.regionWorkers.Recycle(w)
 			}()
 			metric.ChunkCounter.WithLabelValues(metric.ChunkStateRunning).Add(remainChunkCnt)
-			err := cr.restore(ctx, tr, engineID, dataWriter, indexWriter, rc)
+			var err error
+			cr, err = tr.restoreChunk(ctx, rc, engineID, cr, dataWriter, indexWriter)
+			if err != nil && rc.cfg.App.QuarantineChunks && !common.IsContextCanceledError(err) {
+				// the rows delivered before the failure are kept, and the chunk
+				// is saved as failed along with them.
+				tr.logger.Error("chunk failed repeatedly, quarantined",
+					zap.Int32("engineNumber", engineID), zap.Stringer("path", &cr.chunk.Key), log.ShortError(err))
+				cr.chunk.Failure = chunkFailure(err)
+				err = nil
+			}
 			var dataFlushStatus, indexFlushStaus backend.ChunkFlushStatus
 			if err == nil {
 				dataFlushStatus, err = dataWriter.Close(ctx)
@@ -603,6 +631,50 @@ func (tr *TableRestore) restoreEngine(
 	return closedDataEngine, nil
 }
 
+// restoreChunk restores the chunk, and retries it from its last progress for at
+// most `lightning.chunk-retry` times on failure. The progress only covers the
+// rows already delivered to the writers, so no row is lost or written twice by
+// the retries. It returns the chunk restore used last, which is closed by the
+// caller.
+func (tr *TableRestore) restoreChunk(
+	ctx context.Context,
+	rc *Controller,
+	engineID int32,
+	cr *chunkRestore,
+	dataWriter, indexWriter *backend.LocalEngineWriter,
+) (*chunkRestore, error) {
+	err := cr.restore(ctx, tr, engineID, dataWriter, indexWriter, rc)
+	for retry := 1; err != nil && retry <= rc.cfg.App.ChunkRetry; retry++ {
+		if common.IsContextCanceledError(err) || ctx.Err() != nil {
+			break
+		}
+		tr.logger.Warn("chunk failed, retrying from the last progress",
+			zap.Int32("engineNumber", engineID), zap.Stringer("path", &cr.chunk.Key),
+			zap.Int64("pos", cr.chunk.Chunk.Offset), zap.Int("retry", retry), log.ShortError(err))
+		next, reopenErr := newChunkRestore(ctx, cr.index, rc.cfg, cr.chunk, rc.ioWorkers, rc.store, tr.tableInfo)
+		if reopenErr != nil {
+			err = reopenErr
+			continue
+		}
+		cr.close()
+		cr = next
+		err = cr.restore(ctx, tr, engineID, dataWriter, indexWriter, rc)
+	}
+	return cr, errors.Trace(err)
+}
+
+// maxChunkFailureLen is the maximum length of the failure saved in the chunk
+// checkpoint, which is limited by the column of the MySQL checkpoints.
+const maxChunkFailureLen = 1024
+
+func chunkFailure(err error) string {
+	failure := err.Error()
+	if len(failure) > maxChunkFailureLen {
+		failure = strings.ToValidUTF8(failure[:maxChunkFailureLen], "")
+	}
+	return failure
+}
+
 func (tr *TableRestore) importEngine(
 	ctx context.Context,
 	closedEngine *backend.ClosedEngine,
@@ -696,9 +768,15 @@ func (tr *TableRestore) postProcess(
 			if forcePostProcess || !rc.cfg.PostRestore.PostProcessAtLast {
 				tr.logger.Info("local checksum", zap.Object("checksum", &localChecksum))
 				if rc.cfg.TikvImporter.DuplicateDetection {
-					if err := rc.backend.CollectLocalDuplicateRows(ctx, tr.encTable); err != nil {
+					web.BroadcastDuplicateDetection(tr.tableName, "local")
+					err := rc.backend.CollectLocalDuplicateRows(ctx, tr.encTable)
+					if err != nil {
 						tr.logger.Error("collect local duplicate keys failed", log.ShortError(err))
 					}
+					web.BroadcastDuplicateDetectionEnd(tr.tableName, "local", err)
+					if err != nil && rc.cfg.TikvImporter.DuplicateDetectFailFast {
+						return false, errors.Trace(err)
+					}
 				}
 				needChecksum, baseTotalChecksum, err := metaMgr.CheckAndUpdateLocalChecksum(ctx, &localChecksum)
 				if err != nil {
@@ -708,9 +786,14 @@ func (tr *TableRestore) postProcess(
 					return false, nil
 				}
 				if rc.cfg.TikvImporter.DuplicateDetection {
-					if err := rc.backend.CollectRemoteDuplicateRows(ctx, tr.encTable); err != nil {
+					web.BroadcastDuplicateDetection(tr.tableName, "remote")
+					err := rc.backend.CollectRemoteDuplicateRows(ctx, tr.encTable)
+					if err != nil {
 						tr.logger.Error("collect remote duplicate keys failed", log.ShortError(err))
-						err = nil
+					}
+					web.BroadcastDuplicateDetectionEnd(tr.tableName, "remote", err)
+					if err != nil && rc.cfg.TikvImporter.DuplicateDetectFailFast {
+						return false, errors.Trace(err)
 					}
 				}
 				if cp.Checksum.SumKVS() > 0 || baseTotalChecksum.SumKVS() > 0 {
@@ -748,7 +831,23 @@ func (tr *TableRestore) postProcess(
 		return !finished, nil
 	}
 
-	// 5. do table analyze
+	// 5. verify the rows sampled from source files
+	if cp.Status < checkpoints.CheckpointStatusAnalyzed && rc.cfg.PostRestore.SampleVerify != config.OpLevelOff {
+		if !forcePostProcess && rc.cfg.PostRestore.PostProcessAtLast {
+			return true, nil
+		}
+		err := tr.sampleVerifyTable(ctx, rc, cp)
+		// with post restore level 'optional', we will skip sample verify error
+		if rc.cfg.PostRestore.SampleVerify == config.OpLevelOptional && err != nil {
+			tr.logger.Warn("sample verify failed, will skip this error and go on", log.ShortError(err))
+			err = nil
+		}
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+	}
+
+	// 6. do table analyze
 	if cp.Status < checkpoints.CheckpointStatusAnalyzed {
 		switch {
 		case rc.cfg.PostRestore.Analyze == config.OpLevelOff:
@@ -0,0 +1,182 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/lightning/web"
+)
+
+// scheduledTable is a table waiting to be restored.
+type scheduledTable struct {
+	// name is the unique name of the table.
+	name string
+	size int64
+	// waitingFor are the tables it depends on which are not restored yet.
+	waitingFor []string
+}
+
+// tableScheduler decides the order in which the tables start to be restored
+// by `lightning.table-order`, and holds back the tables until the tables they
+// depend on by `lightning.table-dependencies` are restored. The schedule is
+// broadcast to the status API whenever it changes.
+type tableScheduler struct {
+	order string
+
+	mu sync.Mutex
+	// pending are the tables not started yet, in the order to be started.
+	pending  []*scheduledTable
+	running  []string
+	finished []string
+	// changed is closed and replaced when a table is restored.
+	changed chan struct{}
+}
+
+// newTableScheduler schedules the tables given in the order of the data
+// source. The dependencies on the tables not given are ignored, since they
+// are not restored by this task.
+func newTableScheduler(order string, dependencies map[string][]string, tables []*scheduledTable) *tableScheduler {
+	s := &tableScheduler{
+		order:   order,
+		pending: tables,
+		changed: make(chan struct{}),
+	}
+	if order == config.TableOrderLargestFirst {
+		sort.SliceStable(s.pending, func(i, j int) bool {
+			return s.pending[i].size > s.pending[j].size
+		})
+	}
+
+	// the table names of the dependencies are "schema.table", which are
+	// matched case-insensitively.
+	names := make(map[string]string, len(tables))
+	for _, t := range tables {
+		names[strings.ToLower(t.name)] = t.name
+	}
+	uniqueName := func(name string) (string, bool) {
+		schema, table, err := config.SplitTableName(name)
+		if err != nil {
+			return "", false
+		}
+		uniqueName, ok := names[strings.ToLower(common.UniqueTable(schema, table))]
+		return uniqueName, ok
+	}
+	dependents := make(map[string][]string, len(dependencies))
+	for name, deps := range dependencies {
+		dependent, ok := uniqueName(name)
+		if !ok {
+			continue
+		}
+		for _, dep := range deps {
+			if depName, ok := uniqueName(dep); ok {
+				dependents[dependent] = append(dependents[dependent], depName)
+			} else {
+				log.L().Warn("ignore the dependency on the table not restored by this task",
+					zap.String("table", dependent), zap.String("dependency", dep))
+			}
+		}
+	}
+	for _, t := range s.pending {
+		t.waitingFor = dependents[t.name]
+	}
+	s.broadcast()
+	return s
+}
+
+// next starts the first pending table which depends on no unfinished table,
+// and returns its name. It blocks until such a table is found, and returns ""
+// if all the tables are started.
+func (s *tableScheduler) next(ctx context.Context) (string, error) {
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			return "", nil
+		}
+		for i, t := range s.pending {
+			if len(t.waitingFor) == 0 {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				s.running = append(s.running, t.name)
+				s.broadcastLocked()
+				s.mu.Unlock()
+				return t.name, nil
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// finish marks the table restored, successfully or not, and releases the
+// tables depending on it. A failed table fails the task anyway.
+func (s *tableScheduler) finish(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = removeTableName(s.running, name)
+	s.finished = append(s.finished, name)
+	for _, t := range s.pending {
+		t.waitingFor = removeTableName(t.waitingFor, name)
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.broadcastLocked()
+}
+
+func (s *tableScheduler) broadcast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcastLocked()
+}
+
+// broadcastLocked sends a copy of the schedule to the status API.
+func (s *tableScheduler) broadcastLocked() {
+	schedule := &web.TableSchedule{
+		Order:    s.order,
+		Pending:  make([]web.PendingTable, 0, len(s.pending)),
+		Running:  append([]string{}, s.running...),
+		Finished: append([]string{}, s.finished...),
+	}
+	for _, t := range s.pending {
+		schedule.Pending = append(schedule.Pending, web.PendingTable{
+			Name:       t.name,
+			Size:       t.size,
+			WaitingFor: append([]string(nil), t.waitingFor...),
+		})
+	}
+	web.BroadcastTableSchedule(schedule)
+}
+
+func removeTableName(names []string, name string) []string {
+	for i, n := range names {
+		if n == name {
+			return append(names[:i], names[i+1:]...)
+		}
+	}
+	return names
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/web"
+)
+
+type tableSchedulerSuite struct{}
+
+var _ = Suite(&tableSchedulerSuite{})
+
+func makeScheduledTables() []*scheduledTable {
+	return []*scheduledTable{
+		{name: "`db`.`a`", size: 100},
+		{name: "`db`.`b`", size: 300},
+		{name: "`db`.`c`", size: 200},
+	}
+}
+
+func nextTables(c *C, s *tableScheduler, n int) []string {
+	names := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		name, err := s.next(context.Background())
+		c.Assert(err, IsNil)
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *tableSchedulerSuite) TestOrder(c *C) {
+	scheduler := newTableScheduler(config.TableOrderSource, nil, makeScheduledTables())
+	c.Assert(nextTables(c, scheduler, 4), DeepEquals, []string{"`db`.`a`", "`db`.`b`", "`db`.`c`", ""})
+
+	scheduler = newTableScheduler(config.TableOrderLargestFirst, nil, makeScheduledTables())
+	c.Assert(nextTables(c, scheduler, 4), DeepEquals, []string{"`db`.`b`", "`db`.`c`", "`db`.`a`", ""})
+}
+
+func (s *tableSchedulerSuite) TestDependencies(c *C) {
+	deps := map[string][]string{
+		"DB.B":  {"db.a", "other.x"},
+		"db.c":  {"db.b"},
+		"db.zz": {"db.a"},
+	}
+	scheduler := newTableScheduler(config.TableOrderLargestFirst, deps, makeScheduledTables())
+
+	// b waits for a though it's larger, and c waits for b.
+	c.Assert(nextTables(c, scheduler, 1), DeepEquals, []string{"`db`.`a`"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := scheduler.next(ctx)
+	c.Assert(err, Equals, context.DeadlineExceeded)
+
+	data, err := web.MarshalTableSchedule()
+	c.Assert(err, IsNil)
+	var schedule web.TableSchedule
+	c.Assert(json.Unmarshal(data, &schedule), IsNil)
+	c.Assert(schedule, DeepEquals, web.TableSchedule{
+		Order: config.TableOrderLargestFirst,
+		Pending: []web.PendingTable{
+			{Name: "`db`.`b`", Size: 300, WaitingFor: []string{"`db`.`a`"}},
+			{Name: "`db`.`c`", Size: 200, WaitingFor: []string{"`db`.`b`"}},
+		},
+		Running:  []string{"`db`.`a`"},
+		Finished: []string{},
+	})
+
+	// the blocked call returns once the dependency is restored.
+	done := make(chan string)
+	go func() {
+		name, err := scheduler.next(context.Background())
+		c.Check(err, IsNil)
+		done <- name
+	}()
+	scheduler.finish("`db`.`a`")
+	c.Assert(<-done, Equals, "`db`.`b`")
+	scheduler.finish("`db`.`b`")
+	c.Assert(nextTables(c, scheduler, 2), DeepEquals, []string{"`db`.`c`", ""})
+}
@@ -84,7 +84,7 @@ func DBFromConfig(dsn config.DBStore) (*sql.DB, error) {
 		Password:         dsn.Psw,
 		SQLMode:          dsn.StrSQLMode,
 		MaxAllowedPacket: dsn.MaxAllowedPacket,
-		TLS:              dsn.TLS,
+		TLS:              dsn.TLSParam(),
 		Vars: map[string]string{
 			"tidb_build_stats_concurrency":       strconv.Itoa(dsn.BuildStatsConcurrency),
 			"tidb_distsql_scan_concurrency":      strconv.Itoa(dsn.DistSQLScanConcurrency),
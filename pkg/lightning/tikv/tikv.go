@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/coreos/go-semver/semver"
@@ -114,21 +115,27 @@ func ForAllStores(
 ) error {
 	// Go through the HTTP interface instead of gRPC so we don't need to keep
 	// track of the cluster ID.
-	var stores struct {
-		Stores []struct {
-			Store Store
-		}
-	}
-	err := tls.GetJSON(ctx, "/pd/api/v1/stores", &stores)
+	stores, err := pdutil.NewHTTPClientFromTLS(tls).GetStores(ctx)
 	if err != nil {
 		return err
 	}
 
 	eg, c := errgroup.WithContext(ctx)
 	for _, store := range stores.Stores {
-		if store.Store.State >= minState {
-			s := store.Store
-			eg.Go(func() error { return action(c, &s) })
+		if store.Store == nil {
+			continue
+		}
+		var state StoreState
+		if err := state.UnmarshalJSON([]byte(strconv.Quote(store.Store.StateName))); err != nil {
+			return err
+		}
+		if state >= minState {
+			s := &Store{
+				Address: store.Store.GetAddress(),
+				Version: store.Store.GetVersion(),
+				State:   state,
+			}
+			eg.Go(func() error { return action(c, s) })
 		}
 	}
 	return eg.Wait()
@@ -199,9 +206,18 @@ func FetchModeFromMetrics(metrics string) (import_sstpb.SwitchMode, error) {
 	}
 }
 
+// FetchRemoteTableModelsFromTLS obtains the models of all tables in the schema
+// from the status port of TiDB. The request is retried on timeouts.
+//
+// The models can't be rebuilt from information_schema or SHOW CREATE TABLE
+// instead, since the column and index IDs, which are encoded into the KV
+// pairs, are not exposed through SQL.
 func FetchRemoteTableModelsFromTLS(ctx context.Context, tls *common.TLS, schema string) ([]*model.TableInfo, error) {
 	var tables []*model.TableInfo
-	err := tls.GetJSON(ctx, "/schema/"+schema, &tables)
+	err := common.Retry("fetch table models", log.L().With(zap.String("schema", schema)), func() error {
+		tables = nil
+		return tls.GetJSON(ctx, "/schema/"+schema, &tables)
+	})
 	if err != nil {
 		return nil, errors.Annotatef(err, "cannot read schema '%s' from remote", schema)
 	}
@@ -14,9 +14,12 @@
 package verification
 
 import (
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"hash/crc64"
 
+	"github.com/pingcap/errors"
 	"go.uber.org/zap/zapcore"
 
 	"github.com/pingcap/br/pkg/lightning/common"
@@ -24,6 +27,9 @@ import (
 
 var ecmaTable = crc64.MakeTable(crc64.ECMA)
 
+// kvChecksumBinarySize is the size of the binary encoding of a KVChecksum.
+const kvChecksumBinarySize = 24
+
 type KVChecksum struct {
 	bytes    uint64
 	kvs      uint64
@@ -80,6 +86,25 @@ func (c *KVChecksum) Add(other *KVChecksum) {
 	c.checksum ^= other.checksum
 }
 
+// Sub removes the KV pairs summed by other from the checksum, e.g. a chunk to
+// be encoded again after resuming. other must be a part of c.
+func (c *KVChecksum) Sub(other *KVChecksum) {
+	c.bytes -= other.bytes
+	c.kvs -= other.kvs
+	// XOR is its own inverse.
+	c.checksum ^= other.checksum
+}
+
+// MergeKVChecksums returns the checksum of all the KV pairs summed by the
+// checksums, e.g. of the chunks of an engine. The order doesn't matter.
+func MergeKVChecksums(checksums ...KVChecksum) KVChecksum {
+	var merged KVChecksum
+	for i := range checksums {
+		merged.Add(&checksums[i])
+	}
+	return merged
+}
+
 func (c *KVChecksum) Sum() uint64 {
 	return c.checksum
 }
@@ -105,3 +130,40 @@ func (c KVChecksum) MarshalJSON() ([]byte, error) {
 	result := fmt.Sprintf(`{"checksum":%d,"size":%d,"kvs":%d}`, c.checksum, c.bytes, c.kvs)
 	return []byte(result), nil
 }
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *KVChecksum) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Checksum uint64 `json:"checksum"`
+		Size     uint64 `json:"size"`
+		KVs      uint64 `json:"kvs"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return errors.Trace(err)
+	}
+	*c = MakeKVChecksum(fields.Size, fields.KVs, fields.Checksum)
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, the size,
+// the KVs and the checksum in big endian.
+func (c KVChecksum) MarshalBinary() ([]byte, error) {
+	data := make([]byte, kvChecksumBinarySize)
+	binary.BigEndian.PutUint64(data[0:8], c.bytes)
+	binary.BigEndian.PutUint64(data[8:16], c.kvs)
+	binary.BigEndian.PutUint64(data[16:24], c.checksum)
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (c *KVChecksum) UnmarshalBinary(data []byte) error {
+	if len(data) != kvChecksumBinarySize {
+		return errors.Errorf("invalid KV checksum of %d bytes, expect %d bytes", len(data), kvChecksumBinarySize)
+	}
+	*c = MakeKVChecksum(
+		binary.BigEndian.Uint64(data[0:8]),
+		binary.BigEndian.Uint64(data[8:16]),
+		binary.BigEndian.Uint64(data[16:24]),
+	)
+	return nil
+}
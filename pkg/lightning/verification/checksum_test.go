@@ -90,3 +90,48 @@ func (s *testKVChcksumSuite) TestChecksumJSON(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(res, BytesEquals, []byte(`{"Checksum":{"checksum":7890,"size":123,"kvs":456}}`))
 }
+
+func (s *testKVChcksumSuite) TestChecksumUnmarshalJSON(c *C) {
+	testStruct := &struct {
+		Checksum verification.KVChecksum
+	}{}
+	err := json.Unmarshal([]byte(`{"Checksum":{"checksum":7890,"size":123,"kvs":456}}`), testStruct)
+	c.Assert(err, IsNil)
+	c.Assert(testStruct.Checksum, Equals, verification.MakeKVChecksum(123, 456, 7890))
+
+	c.Assert(json.Unmarshal([]byte(`{"Checksum":{"checksum":"x"}}`), testStruct), NotNil)
+}
+
+func (s *testKVChcksumSuite) TestChecksumBinary(c *C) {
+	checksum := verification.MakeKVChecksum(123, 456, 7890)
+	data, err := checksum.MarshalBinary()
+	c.Assert(err, IsNil)
+	c.Assert(data, HasLen, 24)
+
+	var decoded verification.KVChecksum
+	c.Assert(decoded.UnmarshalBinary(data), IsNil)
+	c.Assert(decoded, Equals, checksum)
+	c.Assert(decoded.UnmarshalBinary(data[:10]), ErrorMatches, "invalid KV checksum of 10 bytes.*")
+}
+
+func (s *testKVChcksumSuite) TestChecksumMerge(c *C) {
+	chunk1 := verification.NewKVChecksum(0)
+	chunk1.Update([]common.KvPair{{Key: []byte("a"), Val: []byte("1")}})
+	chunk2 := verification.NewKVChecksum(0)
+	chunk2.Update([]common.KvPair{{Key: []byte("b"), Val: []byte("22")}, {Key: []byte("c"), Val: []byte("333")}})
+	whole := verification.NewKVChecksum(0)
+	whole.Update([]common.KvPair{
+		{Key: []byte("c"), Val: []byte("333")},
+		{Key: []byte("a"), Val: []byte("1")},
+		{Key: []byte("b"), Val: []byte("22")},
+	})
+
+	// the checksums of the chunks persisted are recombined in any order.
+	merged := verification.MergeKVChecksums(*chunk2, *chunk1)
+	c.Assert(merged, Equals, *whole)
+	c.Assert(verification.MergeKVChecksums(), Equals, verification.KVChecksum{})
+
+	// removing a chunk leaves the checksum of the others.
+	merged.Sub(chunk1)
+	c.Assert(merged, Equals, *chunk2)
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"sync"
+	"time"
+)
+
+// taskHealth is the state of the running task reported by the readiness probe.
+type taskHealth struct {
+	mu                sync.RWMutex
+	lastProgress      time.Time
+	diskQuotaExceeded bool
+}
+
+var currentHealth taskHealth
+
+func broadcastProgress() {
+	currentHealth.mu.Lock()
+	currentHealth.lastProgress = time.Now()
+	currentHealth.mu.Unlock()
+}
+
+// BroadcastDiskQuota records whether the disk quota of the local backend is
+// exceeded, which blocks the writers until the large engines are imported.
+func BroadcastDiskQuota(exceeded bool) {
+	currentHealth.mu.Lock()
+	currentHealth.diskQuotaExceeded = exceeded
+	currentHealth.mu.Unlock()
+}
+
+// LastProgressTime returns when the running task made progress the last time,
+// i.e. it was started or a checkpoint of it was updated.
+func LastProgressTime() time.Time {
+	currentHealth.mu.RLock()
+	defer currentHealth.mu.RUnlock()
+	return currentHealth.lastProgress
+}
+
+// IsDiskQuotaExceeded returns whether the disk quota is exceeded.
+func IsDiskQuotaExceeded() bool {
+	currentHealth.mu.RLock()
+	defer currentHealth.mu.RUnlock()
+	return currentHealth.diskQuotaExceeded
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"sync"
+	"time"
 
 	"github.com/pingcap/errors"
 
@@ -26,16 +27,20 @@ import (
 type checkpointsMap struct {
 	mu          sync.RWMutex
 	checkpoints map[string]*checkpoints.TableCheckpoint
+	// speeds tracks the read speed of the chunks by "table:path:offset".
+	speeds map[string]*chunkSpeed
 }
 
 func makeCheckpointsMap() (res checkpointsMap) {
 	res.checkpoints = make(map[string]*checkpoints.TableCheckpoint)
+	res.speeds = make(map[string]*chunkSpeed)
 	return
 }
 
 func (cpm *checkpointsMap) clear() {
 	cpm.mu.Lock()
 	cpm.checkpoints = make(map[string]*checkpoints.TableCheckpoint)
+	cpm.speeds = make(map[string]*chunkSpeed)
 	cpm.mu.Unlock()
 }
 
@@ -45,6 +50,38 @@ func (cpm *checkpointsMap) insert(key string, cp *checkpoints.TableCheckpoint) {
 	cpm.mu.Unlock()
 }
 
+// chunkSpeed is the read speed of a chunk between its last two updates.
+type chunkSpeed struct {
+	offset     int64
+	lastUpdate time.Time
+	speed      float64
+}
+
+func chunkSpeedKey(tableName string, chunk *checkpoints.ChunkCheckpoint) string {
+	return tableName + ":" + chunk.Key.String()
+}
+
+func (cpm *checkpointsMap) updateSpeeds(tableName string, cp *checkpoints.TableCheckpoint, now time.Time) {
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			key := chunkSpeedKey(tableName, chunk)
+			sp, ok := cpm.speeds[key]
+			if !ok {
+				cpm.speeds[key] = &chunkSpeed{offset: chunk.Chunk.Offset, lastUpdate: now}
+				continue
+			}
+			if chunk.Chunk.Offset == sp.offset {
+				continue
+			}
+			if elapsed := now.Sub(sp.lastUpdate).Seconds(); elapsed > 0 {
+				sp.speed = float64(chunk.Chunk.Offset-sp.offset) / elapsed
+			}
+			sp.offset = chunk.Chunk.Offset
+			sp.lastUpdate = now
+		}
+	}
+}
+
 type totalWritten struct {
 	key          string
 	totalWritten int64
@@ -56,9 +93,14 @@ func (cpm *checkpointsMap) update(diffs map[string]*checkpoints.TableCheckpointD
 	cpm.mu.Lock()
 	defer cpm.mu.Unlock()
 
+	now := time.Now()
 	for key, diff := range diffs {
 		cp := cpm.checkpoints[key]
+		if cp == nil {
+			continue
+		}
 		cp.Apply(diff)
+		cpm.updateSpeeds(key, cp, now)
 
 		tw := int64(0)
 		for _, engine := range cp.Engines {
@@ -97,6 +139,15 @@ type tableInfo struct {
 	TotalSize    int64      `json:"z"`
 	Status       taskStatus `json:"s"`
 	Message      string     `json:"m,omitempty"`
+	// DuplicateDetection is the state of the duplicate detection, if enabled.
+	DuplicateDetection *duplicateDetectionInfo `json:"d,omitempty"`
+}
+
+type duplicateDetectionInfo struct {
+	// Phase is either "local" or "remote".
+	Phase   string     `json:"p"`
+	Status  taskStatus `json:"s"`
+	Message string     `json:"m,omitempty"`
 }
 
 type taskProgress struct {
@@ -107,6 +158,33 @@ type taskProgress struct {
 
 	// The contents have their own mutex for protection
 	checkpoints checkpointsMap
+
+	// runningTasks is the number of tasks running, the progress of which are
+	// merged.
+	runningTasks int
+	// schedule is the latest schedule of the tables, nil before the tables
+	// start to be restored.
+	schedule *TableSchedule
+}
+
+// TableSchedule is the order in which the tables of the task are restored.
+type TableSchedule struct {
+	// Order is `lightning.table-order`.
+	Order string `json:"order"`
+	// Pending are the tables not started yet, in the order to be started.
+	Pending []PendingTable `json:"pending"`
+	// Running are the tables started and not restored yet.
+	Running []string `json:"running"`
+	// Finished are the tables restored, successfully or not.
+	Finished []string `json:"finished"`
+}
+
+// PendingTable is a table of the schedule not started yet.
+type PendingTable struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// WaitingFor are the tables it depends on which are not restored yet.
+	WaitingFor []string `json:"waiting-for,omitempty"`
 }
 
 var currentProgress = taskProgress{
@@ -115,43 +193,64 @@ var currentProgress = taskProgress{
 
 func BroadcastStartTask() {
 	currentProgress.mu.Lock()
+	first := currentProgress.runningTasks == 0
+	currentProgress.runningTasks++
 	currentProgress.Status = taskStatusRunning
+	if first {
+		currentProgress.Tables = make(map[string]*tableInfo)
+		currentProgress.Message = ""
+		currentProgress.schedule = nil
+	}
 	currentProgress.mu.Unlock()
 
-	currentProgress.checkpoints.clear()
+	if first {
+		currentProgress.checkpoints.clear()
+	}
+	BroadcastDiskQuota(false)
+	broadcastProgress()
 }
 
 func BroadcastEndTask(err error) {
 	errString := errors.ErrorStack(err)
 
 	currentProgress.mu.Lock()
-	currentProgress.Status = taskStatusCompleted
-	currentProgress.Message = errString
+	if currentProgress.runningTasks > 0 {
+		currentProgress.runningTasks--
+	}
+	if currentProgress.runningTasks == 0 {
+		currentProgress.Status = taskStatusCompleted
+	}
+	if err != nil {
+		currentProgress.Message = errString
+	}
 	currentProgress.mu.Unlock()
 }
 
 func BroadcastInitProgress(databases []*mydump.MDDatabaseMeta) {
-	tables := make(map[string]*tableInfo, len(databases))
-
+	currentProgress.mu.Lock()
+	defer currentProgress.mu.Unlock()
+	if currentProgress.Tables == nil {
+		currentProgress.Tables = make(map[string]*tableInfo, len(databases))
+	}
+	// the tables of the tasks running at the same time are merged.
 	for _, db := range databases {
 		for _, tbl := range db.Tables {
 			name := common.UniqueTable(db.Name, tbl.Name)
-			tables[name] = &tableInfo{TotalSize: tbl.TotalSize}
+			currentProgress.Tables[name] = &tableInfo{TotalSize: tbl.TotalSize}
 		}
 	}
-
-	currentProgress.mu.Lock()
-	currentProgress.Tables = tables
-	currentProgress.mu.Unlock()
 }
 
 func BroadcastTableCheckpoint(tableName string, cp *checkpoints.TableCheckpoint) {
 	currentProgress.mu.Lock()
-	currentProgress.Tables[tableName].Status = taskStatusRunning
+	if tbl := currentProgress.Tables[tableName]; tbl != nil {
+		tbl.Status = taskStatusRunning
+	}
 	currentProgress.mu.Unlock()
 
 	// create a deep copy to avoid false sharing
 	currentProgress.checkpoints.insert(tableName, cp.DeepCopy())
+	broadcastProgress()
 }
 
 func BroadcastCheckpointDiff(diffs map[string]*checkpoints.TableCheckpointDiff) {
@@ -159,9 +258,12 @@ func BroadcastCheckpointDiff(diffs map[string]*checkpoints.TableCheckpointDiff)
 
 	currentProgress.mu.Lock()
 	for _, tw := range totalWrittens {
-		currentProgress.Tables[tw.key].TotalWritten = tw.totalWritten
+		if tbl := currentProgress.Tables[tw.key]; tbl != nil {
+			tbl.TotalWritten = tw.totalWritten
+		}
 	}
 	currentProgress.mu.Unlock()
+	broadcastProgress()
 }
 
 func BroadcastError(tableName string, err error) {
@@ -175,6 +277,49 @@ func BroadcastError(tableName string, err error) {
 	currentProgress.mu.Unlock()
 }
 
+// BroadcastDuplicateDetection records the duplicate detection of the table in
+// the phase, "local" or "remote", is started.
+func BroadcastDuplicateDetection(tableName string, phase string) {
+	currentProgress.mu.Lock()
+	if tbl := currentProgress.Tables[tableName]; tbl != nil {
+		tbl.DuplicateDetection = &duplicateDetectionInfo{Phase: phase, Status: taskStatusRunning}
+	}
+	currentProgress.mu.Unlock()
+}
+
+// BroadcastDuplicateDetectionEnd records the duplicate detection of the table
+// in the phase is finished.
+func BroadcastDuplicateDetectionEnd(tableName string, phase string, err error) {
+	info := &duplicateDetectionInfo{Phase: phase, Status: taskStatusCompleted}
+	if err != nil {
+		info.Message = errors.ErrorStack(err)
+	}
+	currentProgress.mu.Lock()
+	if tbl := currentProgress.Tables[tableName]; tbl != nil {
+		tbl.DuplicateDetection = info
+	}
+	currentProgress.mu.Unlock()
+}
+
+// BroadcastTableSchedule records the schedule of the tables, which must not
+// be modified afterwards.
+func BroadcastTableSchedule(schedule *TableSchedule) {
+	currentProgress.mu.Lock()
+	currentProgress.schedule = schedule
+	currentProgress.mu.Unlock()
+}
+
+// MarshalTableSchedule returns the schedule of the tables. It returns a
+// NotFound error before the tables start to be restored.
+func MarshalTableSchedule() ([]byte, error) {
+	currentProgress.mu.RLock()
+	defer currentProgress.mu.RUnlock()
+	if currentProgress.schedule == nil {
+		return nil, errors.NotFoundf("table schedule")
+	}
+	return json.Marshal(currentProgress.schedule)
+}
+
 func MarshalTaskProgress() ([]byte, error) {
 	currentProgress.mu.RLock()
 	defer currentProgress.mu.RUnlock()
@@ -184,3 +329,69 @@ func MarshalTaskProgress() ([]byte, error) {
 func MarshalTableCheckpoints(tableName string) ([]byte, error) {
 	return currentProgress.checkpoints.marshal(tableName)
 }
+
+// speedStaleAfter is the duration after which a chunk not updated is
+// considered not being read.
+const speedStaleAfter = time.Minute
+
+type chunkProgress struct {
+	Path        string  `json:"path"`
+	StartOffset int64   `json:"start-offset"`
+	Offset      int64   `json:"offset"`
+	EndOffset   int64   `json:"end-offset"`
+	ErrorRows   int64   `json:"error-rows"`
+	Speed       float64 `json:"speed"` // bytes per second
+}
+
+type engineProgress struct {
+	Status         checkpoints.CheckpointStatus `json:"status"`
+	Written        int64                        `json:"written"`
+	Total          int64                        `json:"total"`
+	Speed          float64                      `json:"speed"` // bytes per second
+	FinishedChunks int                          `json:"finished-chunks"`
+	Chunks         []chunkProgress              `json:"chunks"`
+}
+
+func (cpm *checkpointsMap) marshalEngines(key string, now time.Time) ([]byte, error) {
+	cpm.mu.RLock()
+	defer cpm.mu.RUnlock()
+
+	cp, ok := cpm.checkpoints[key]
+	if !ok {
+		return nil, errors.NotFoundf("table %s", key)
+	}
+	engines := make(map[int32]*engineProgress, len(cp.Engines))
+	for engineID, engine := range cp.Engines {
+		ep := &engineProgress{
+			Status: engine.Status,
+			Chunks: make([]chunkProgress, 0, len(engine.Chunks)),
+		}
+		for _, chunk := range engine.Chunks {
+			chp := chunkProgress{
+				Path:        chunk.Key.Path,
+				StartOffset: chunk.Key.Offset,
+				Offset:      chunk.Chunk.Offset,
+				EndOffset:   chunk.Chunk.EndOffset,
+				ErrorRows:   chunk.ErrorRows,
+			}
+			if engine.Status >= checkpoints.CheckpointStatusAllWritten || chunk.Chunk.Offset >= chunk.Chunk.EndOffset {
+				chp.Offset = chunk.Chunk.EndOffset
+				ep.FinishedChunks++
+			} else if sp, ok := cpm.speeds[chunkSpeedKey(key, chunk)]; ok && now.Sub(sp.lastUpdate) < speedStaleAfter {
+				chp.Speed = sp.speed
+			}
+			ep.Written += chp.Offset - chp.StartOffset
+			ep.Total += chp.EndOffset - chp.StartOffset
+			ep.Speed += chp.Speed
+			ep.Chunks = append(ep.Chunks, chp)
+		}
+		engines[engineID] = ep
+	}
+	return json.Marshal(engines)
+}
+
+// MarshalTableEngines returns the progress of the engines of the table and the
+// chunks in them, including the read offsets and speeds of the chunks.
+func MarshalTableEngines(tableName string) ([]byte, error) {
+	return currentProgress.checkpoints.marshalEngines(tableName, time.Now())
+}
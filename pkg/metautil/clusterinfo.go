@@ -0,0 +1,106 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package metautil
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pingcap/errors"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+// ClusterInfoFile is the file recording the settings of the backed up cluster
+// which the backupmeta has no field for.
+const ClusterInfoFile = "backupcluster.json"
+
+// ClusterInfo is the state of the backed up cluster at the backup point.
+type ClusterInfo struct {
+	// NewCollationsEnabled is whether the cluster is bootstrapped with
+	// new_collations_enabled_on_first_bootstrap, nil if unknown.
+	NewCollationsEnabled *bool `json:"new-collations-enabled,omitempty"`
+
+	// BackupTS, SchemaVersion and RecentDDLs describe the schema at the backup
+	// point, so a changefeed of the backed up cluster can be started exactly
+	// from it after restore.
+	BackupTS      uint64       `json:"backup-ts"`
+	SchemaVersion int64        `json:"schema-version"`
+	RecentDDLs    []*DDLRecord `json:"recent-ddls,omitempty"`
+
+	// Topology is the stores and the region settings of the cluster, which
+	// the restore is planned by, nil if unknown.
+	Topology *Topology `json:"topology,omitempty"`
+
+	// GlobalVariables are the global variables interpreting the data at the
+	// backup point by the lower case names, e.g. sql_mode and time_zone,
+	// which may be applied to the target cluster by the restore.
+	GlobalVariables map[string]string `json:"global-variables,omitempty"`
+}
+
+// Topology is the stores and the region settings of a cluster.
+type Topology struct {
+	Stores []*StoreTopology `json:"stores"`
+	// RegionSplitSize and RegionSplitKeys are the region split settings of
+	// TiKV, 0 if unknown.
+	RegionSplitSize uint64 `json:"region-split-size,omitempty"`
+	RegionSplitKeys uint64 `json:"region-split-keys,omitempty"`
+	MaxReplicas     uint64 `json:"max-replicas,omitempty"`
+}
+
+// StoreTopology is a TiKV store of the cluster.
+type StoreTopology struct {
+	ID      uint64            `json:"id"`
+	Address string            `json:"address"`
+	Version string            `json:"version"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	// Capacity is the capacity of the store in bytes, 0 if unknown.
+	Capacity uint64 `json:"capacity,omitempty"`
+}
+
+// TotalCapacity returns the total capacity of the stores, 0 if unknown.
+func (t *Topology) TotalCapacity() uint64 {
+	var capacity uint64
+	for _, s := range t.Stores {
+		capacity += s.Capacity
+	}
+	return capacity
+}
+
+// DDLRecord is a DDL job done before the backup point.
+type DDLRecord struct {
+	JobID         int64  `json:"job-id"`
+	Type          string `json:"type"`
+	SchemaName    string `json:"schema-name"`
+	Query         string `json:"query"`
+	SchemaVersion int64  `json:"schema-version"`
+	FinishedTS    uint64 `json:"finished-ts"`
+}
+
+// WriteClusterInfo writes the cluster info into the backup storage.
+func WriteClusterInfo(ctx context.Context, s storage.ExternalStorage, info *ClusterInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.WriteFile(ctx, ClusterInfoFile, data))
+}
+
+// ReadClusterInfo reads the cluster info from the backup storage. It returns
+// nil if the backup is taken by a BR not recording it.
+func ReadClusterInfo(ctx context.Context, s storage.ExternalStorage) (*ClusterInfo, error) {
+	exist, err := s.FileExists(ctx, ClusterInfoFile)
+	if err != nil || !exist {
+		return nil, errors.Trace(err)
+	}
+	data, err := s.ReadFile(ctx, ClusterInfoFile)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	info := &ClusterInfo{}
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, errors.Annotatef(berrors.ErrInvalidMetaFile, "invalid %s: %v", ClusterInfoFile, err)
+	}
+	return info, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package metautil
+
+import (
+	"encoding/binary"
+	"reflect"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// MaxMetaVersion is the newest version of backupmeta this BR can read.
+const MaxMetaVersion = MetaV2
+
+// CheckMetaVersion checks whether this BR can read the backupmeta. The newer
+// versions change how the backup is laid out, so they can't be restored
+// by ignoring what's unknown.
+func CheckMetaVersion(meta *backuppb.BackupMeta) error {
+	if meta.GetVersion() > MaxMetaVersion {
+		return errors.Annotatef(berrors.ErrMetaVersionUnsupported,
+			"the backupmeta version %d written by BR %s is newer than the supported version %d, please upgrade BR",
+			meta.GetVersion(), meta.GetBrVersion(), MaxMetaVersion)
+	}
+	return nil
+}
+
+// UnknownFields returns the top level fields of the encoded message which
+// the type of the message doesn't know, e.g. written by a newer BR, in the
+// wire format. The unknown fields in the nested messages aren't returned.
+func UnknownFields(data []byte, msg proto.Message) ([]byte, error) {
+	known := make(map[uint64]struct{})
+	props := proto.GetProperties(reflect.TypeOf(msg).Elem())
+	for _, p := range props.Prop {
+		if p.Tag > 0 {
+			known[uint64(p.Tag)] = struct{}{}
+		}
+	}
+	for _, oneof := range props.OneofTypes {
+		known[uint64(oneof.Prop.Tag)] = struct{}{}
+	}
+
+	var unknown []byte
+	for len(data) > 0 {
+		n, err := fieldLength(data)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		key, _ := binary.Uvarint(data)
+		if _, ok := known[key>>3]; !ok {
+			unknown = append(unknown, data[:n]...)
+		}
+		data = data[n:]
+	}
+	return unknown, nil
+}
+
+// fieldLength returns the length of the first field in the wire format,
+// including its key.
+func fieldLength(data []byte) (int, error) {
+	key, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, errors.Annotate(berrors.ErrInvalidMetaFile, "invalid field key")
+	}
+	length := n
+	switch wireType := key & 0x7; wireType {
+	case proto.WireVarint:
+		_, m := binary.Uvarint(data[length:])
+		if m <= 0 {
+			return 0, errors.Annotate(berrors.ErrInvalidMetaFile, "invalid varint field")
+		}
+		length += m
+	case proto.WireFixed64:
+		length += 8
+	case proto.WireFixed32:
+		length += 4
+	case proto.WireBytes:
+		size, m := binary.Uvarint(data[length:])
+		if m <= 0 {
+			return 0, errors.Annotate(berrors.ErrInvalidMetaFile, "invalid length of bytes field")
+		}
+		length += m + int(size)
+	case proto.WireStartGroup:
+		for {
+			if length >= len(data) {
+				return 0, errors.Annotate(berrors.ErrInvalidMetaFile, "unterminated group field")
+			}
+			m, err := fieldLength(data[length:])
+			if err != nil {
+				return 0, errors.Trace(err)
+			}
+			inner, _ := binary.Uvarint(data[length:])
+			length += m
+			if inner&0x7 == proto.WireEndGroup {
+				break
+			}
+		}
+	case proto.WireEndGroup:
+	default:
+		return 0, errors.Annotatef(berrors.ErrInvalidMetaFile, "unknown wire type %d", wireType)
+	}
+	if length > len(data) {
+		return 0, errors.Annotate(berrors.ErrInvalidMetaFile, "truncated field")
+	}
+	return length, nil
+}
+
+// MarshalWithUnknownFields encodes the message with the unknown fields
+// returned by UnknownFields, so that a read-modify-write of the message keeps
+// the fields this BR doesn't know.
+func MarshalWithUnknownFields(msg proto.Message, unknown []byte) ([]byte, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// the fields may be in any order in the wire format.
+	return append(data, unknown...), nil
+}
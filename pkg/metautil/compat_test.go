@@ -0,0 +1,56 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package metautil
+
+import (
+	"github.com/gogo/protobuf/proto"
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+)
+
+func (m *metaSuit) TestCheckMetaVersion(c *C) {
+	c.Assert(CheckMetaVersion(&backuppb.BackupMeta{Version: MetaV1}), IsNil)
+	c.Assert(CheckMetaVersion(&backuppb.BackupMeta{Version: MetaV2}), IsNil)
+	err := CheckMetaVersion(&backuppb.BackupMeta{Version: MaxMetaVersion + 1, BrVersion: "BR v9.9.9"})
+	c.Assert(err, ErrorMatches, ".*BR v9.9.9.*upgrade BR.*unsupported backupmeta version.*")
+}
+
+func (m *metaSuit) TestPreserveUnknownFields(c *C) {
+	meta := &backuppb.BackupMeta{ClusterId: 1, EndVersion: 42, BrVersion: "v5.2.0"}
+	data, err := proto.Marshal(meta)
+	c.Assert(err, IsNil)
+	// the fields 1000 (varint), 1001 (bytes) and 1002 (fixed64) written by a
+	// newer BR.
+	newer := []byte{
+		0xc0, 0x3e, 0x07,
+		0xca, 0x3e, 0x03, 'n', 'e', 'w',
+		0xd1, 0x3e, 1, 2, 3, 4, 5, 6, 7, 8,
+	}
+	data = append(data[:len(data):len(data)], newer...)
+
+	decoded := &backuppb.BackupMeta{}
+	c.Assert(proto.Unmarshal(data, decoded), IsNil)
+	unknown, err := UnknownFields(data, decoded)
+	c.Assert(err, IsNil)
+	c.Assert(unknown, DeepEquals, newer)
+
+	// modify and re-encode the backupmeta.
+	decoded.EndVersion = 43
+	encoded, err := MarshalWithUnknownFields(decoded, unknown)
+	c.Assert(err, IsNil)
+	reDecoded := &backuppb.BackupMeta{}
+	c.Assert(proto.Unmarshal(encoded, reDecoded), IsNil)
+	c.Assert(reDecoded.EndVersion, Equals, uint64(43))
+	c.Assert(reDecoded.BrVersion, Equals, "v5.2.0")
+	unknown, err = UnknownFields(encoded, reDecoded)
+	c.Assert(err, IsNil)
+	c.Assert(unknown, DeepEquals, newer)
+
+	// nothing is unknown in the backupmeta of this BR.
+	unknown, err = UnknownFields(data[:len(data)-len(newer)], meta)
+	c.Assert(err, IsNil)
+	c.Assert(unknown, HasLen, 0)
+
+	_, err = UnknownFields([]byte{0xca, 0x3e, 0x05, 'n'}, decoded)
+	c.Assert(err, ErrorMatches, ".*truncated field.*")
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package metautil
+
+import (
+	"sort"
+	"time"
+
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+// The status of a table in the diff of two backups.
+const (
+	TableAdded     = "added"
+	TableRemoved   = "removed"
+	TableShrunk    = "shrunk"
+	TableChanged   = "changed"
+	TableUnchanged = "unchanged"
+)
+
+// TableDiff is the difference of a table between the backups A and B.
+type TableDiff struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+
+	KvsA       uint64 `json:"kvs-a"`
+	KvsB       uint64 `json:"kvs-b"`
+	BytesA     uint64 `json:"bytes-a"`
+	BytesB     uint64 `json:"bytes-b"`
+	BytesDelta int64  `json:"bytes-delta"`
+	// ChecksumChanged is whether the checksums differ, it's false if either
+	// backup skipped the checksum.
+	ChecksumChanged bool `json:"checksum-changed"`
+}
+
+// BackupDiff is the difference between the backups A and B.
+type BackupDiff struct {
+	EndVersionA uint64 `json:"end-version-a"`
+	EndVersionB uint64 `json:"end-version-b"`
+	// TSGap is the physical time B is backed up after A, negative if before.
+	TSGap time.Duration `json:"ts-gap"`
+
+	Added     int         `json:"added"`
+	Removed   int         `json:"removed"`
+	Shrunk    int         `json:"shrunk"`
+	Changed   int         `json:"changed"`
+	Unchanged int         `json:"unchanged"`
+	Tables    []TableDiff `json:"tables"`
+}
+
+// DiffBackups compares the tables of the backups A and B. A table is shrunk
+// if it has fewer KVs in B than in A, which is suspicious when B is the newer
+// backup of the same cluster.
+func DiffBackups(metaA *backuppb.BackupMeta, tablesA []*Table, metaB *backuppb.BackupMeta, tablesB []*Table) *BackupDiff {
+	diff := &BackupDiff{
+		EndVersionA: metaA.GetEndVersion(),
+		EndVersionB: metaB.GetEndVersion(),
+		TSGap:       oracle.GetTimeFromTS(metaB.GetEndVersion()).Sub(oracle.GetTimeFromTS(metaA.GetEndVersion())),
+	}
+	byName := make(map[string]*Table, len(tablesB))
+	for _, t := range tablesB {
+		if t.Info != nil {
+			byName[tableName(t)] = t
+		}
+	}
+	for _, a := range tablesA {
+		if a.Info == nil {
+			continue
+		}
+		name := tableName(a)
+		td := TableDiff{Name: name, KvsA: a.TotalKvs, BytesA: a.TotalBytes}
+		b, ok := byName[name]
+		if !ok {
+			td.Status = TableRemoved
+			td.BytesDelta = -int64(a.TotalBytes)
+			diff.Tables = append(diff.Tables, td)
+			continue
+		}
+		delete(byName, name)
+		td.KvsB, td.BytesB = b.TotalKvs, b.TotalBytes
+		td.BytesDelta = int64(b.TotalBytes) - int64(a.TotalBytes)
+		td.ChecksumChanged = !a.NoChecksum() && !b.NoChecksum() && a.Crc64Xor != b.Crc64Xor
+		switch {
+		case b.TotalKvs < a.TotalKvs:
+			td.Status = TableShrunk
+		case td.ChecksumChanged || td.KvsB != td.KvsA || td.BytesDelta != 0:
+			td.Status = TableChanged
+		default:
+			td.Status = TableUnchanged
+		}
+		diff.Tables = append(diff.Tables, td)
+	}
+	for name, b := range byName {
+		diff.Tables = append(diff.Tables, TableDiff{
+			Name:       name,
+			Status:     TableAdded,
+			KvsB:       b.TotalKvs,
+			BytesB:     b.TotalBytes,
+			BytesDelta: int64(b.TotalBytes),
+		})
+	}
+	sort.Slice(diff.Tables, func(i, j int) bool { return diff.Tables[i].Name < diff.Tables[j].Name })
+
+	for _, td := range diff.Tables {
+		switch td.Status {
+		case TableAdded:
+			diff.Added++
+		case TableRemoved:
+			diff.Removed++
+		case TableShrunk:
+			diff.Shrunk++
+		case TableChanged:
+			diff.Changed++
+		default:
+			diff.Unchanged++
+		}
+	}
+	return diff
+}
+
+func tableName(t *Table) string {
+	return t.DB.Name.O + "." + t.Info.Name.O
+}
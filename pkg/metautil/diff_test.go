@@ -0,0 +1,65 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package metautil
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func diffTable(name string, crc, kvs, bytes uint64) *Table {
+	return &Table{
+		DB:         &model.DBInfo{Name: model.NewCIStr("test")},
+		Info:       &model.TableInfo{Name: model.NewCIStr(name)},
+		Crc64Xor:   crc,
+		TotalKvs:   kvs,
+		TotalBytes: bytes,
+	}
+}
+
+func (m *metaSuit) TestDiffBackups(c *C) {
+	now := time.Now()
+	metaA := &backuppb.BackupMeta{EndVersion: oracle.GoTimeToTS(now)}
+	metaB := &backuppb.BackupMeta{EndVersion: oracle.GoTimeToTS(now.Add(24 * time.Hour))}
+	tablesA := []*Table{
+		diffTable("same", 1, 10, 100),
+		diffTable("grown", 2, 10, 100),
+		diffTable("shrunk", 3, 10, 100),
+		diffTable("removed", 4, 10, 100),
+		diffTable("nochecksum", 0, 0, 0),
+	}
+	tablesB := []*Table{
+		diffTable("same", 1, 10, 100),
+		diffTable("grown", 5, 20, 200),
+		diffTable("shrunk", 6, 5, 50),
+		diffTable("added", 7, 10, 100),
+		diffTable("nochecksum", 0, 0, 0),
+	}
+
+	diff := DiffBackups(metaA, tablesA, metaB, tablesB)
+	c.Assert(diff.TSGap, Equals, 24*time.Hour)
+	c.Assert(diff.Added, Equals, 1)
+	c.Assert(diff.Removed, Equals, 1)
+	c.Assert(diff.Shrunk, Equals, 1)
+	c.Assert(diff.Changed, Equals, 1)
+	c.Assert(diff.Unchanged, Equals, 2)
+
+	status := make(map[string]TableDiff)
+	for _, td := range diff.Tables {
+		status[td.Name] = td
+	}
+	c.Assert(status["test.added"].Status, Equals, TableAdded)
+	c.Assert(status["test.added"].BytesDelta, Equals, int64(100))
+	c.Assert(status["test.removed"].Status, Equals, TableRemoved)
+	c.Assert(status["test.removed"].BytesDelta, Equals, int64(-100))
+	c.Assert(status["test.shrunk"].Status, Equals, TableShrunk)
+	c.Assert(status["test.shrunk"].BytesDelta, Equals, int64(-50))
+	c.Assert(status["test.grown"].Status, Equals, TableChanged)
+	c.Assert(status["test.grown"].ChecksumChanged, IsTrue)
+	c.Assert(status["test.same"].Status, Equals, TableUnchanged)
+	c.Assert(status["test.nochecksum"].ChecksumChanged, IsFalse)
+}
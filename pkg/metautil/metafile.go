@@ -206,6 +206,16 @@ func (reader *MetaReader) ReadDDLs(ctx context.Context) ([]byte, error) {
 	}
 }
 
+// ReadDataFiles reads all the data files from the backupmeta.
+// This function is compatible with the old backupmeta.
+func (reader *MetaReader) ReadDataFiles(ctx context.Context) ([]*backuppb.File, error) {
+	var files []*backuppb.File
+	err := reader.readDataFiles(ctx, func(file *backuppb.File) {
+		files = append(files, file)
+	})
+	return files, errors.Trace(err)
+}
+
 // ReadSchemasFiles reads the schema and datafiles from the backupmeta.
 // This function is compatible with the old backupmeta.
 func (reader *MetaReader) ReadSchemasFiles(ctx context.Context, output chan<- *Table) error {
@@ -12,6 +12,7 @@ import (
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
 
 	mockstorage "github.com/pingcap/br/pkg/mock/storage"
+	"github.com/pingcap/br/pkg/storage"
 )
 
 type metaSuit struct{}
@@ -133,3 +134,39 @@ func (m *metaSuit) TestWalkMetaFile(c *C) {
 		c.Assert(files[i], DeepEquals, expect[i])
 	}
 }
+
+func (m *metaSuit) TestClusterInfo(c *C) {
+	ctx := context.Background()
+	s, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+
+	// the backups taken by the older BR don't record it.
+	info, err := ReadClusterInfo(ctx, s)
+	c.Assert(err, IsNil)
+	c.Assert(info, IsNil)
+
+	enabled := true
+	c.Assert(WriteClusterInfo(ctx, s, &ClusterInfo{
+		NewCollationsEnabled: &enabled,
+		BackupTS:             42,
+		SchemaVersion:        10,
+		RecentDDLs:           []*DDLRecord{{JobID: 1, Query: "create table t (a int)", SchemaVersion: 10}},
+	}), IsNil)
+	info, err = ReadClusterInfo(ctx, s)
+	c.Assert(err, IsNil)
+	c.Assert(*info.NewCollationsEnabled, IsTrue)
+	c.Assert(info.BackupTS, Equals, uint64(42))
+	c.Assert(info.SchemaVersion, Equals, int64(10))
+	c.Assert(info.RecentDDLs, HasLen, 1)
+	c.Assert(info.RecentDDLs[0].Query, Equals, "create table t (a int)")
+
+	// the new collations are unknown.
+	c.Assert(WriteClusterInfo(ctx, s, &ClusterInfo{BackupTS: 42}), IsNil)
+	info, err = ReadClusterInfo(ctx, s)
+	c.Assert(err, IsNil)
+	c.Assert(info.NewCollationsEnabled, IsNil)
+
+	c.Assert(s.WriteFile(ctx, ClusterInfoFile, []byte("{")), IsNil)
+	_, err = ReadClusterInfo(ctx, s)
+	c.Assert(err, ErrorMatches, ".*invalid backupcluster.json.*")
+}
@@ -95,6 +95,20 @@ func (mr *MockBackendMockRecorder) CloseEngine(arg0, arg1, arg2 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseEngine", reflect.TypeOf((*MockBackend)(nil).CloseEngine), arg0, arg1, arg2)
 }
 
+// CloseWithContext mocks base method
+func (m *MockBackend) CloseWithContext(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseWithContext", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseWithContext indicates an expected call of CloseWithContext
+func (mr *MockBackendMockRecorder) CloseWithContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseWithContext", reflect.TypeOf((*MockBackend)(nil).CloseWithContext), arg0)
+}
+
 // CollectLocalDuplicateRows mocks base method
 func (m *MockBackend) CollectLocalDuplicateRows(arg0 context.Context, arg1 table.Table) error {
 	m.ctrl.T.Helper()
@@ -137,6 +151,21 @@ func (mr *MockBackendMockRecorder) EngineFileSizes() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EngineFileSizes", reflect.TypeOf((*MockBackend)(nil).EngineFileSizes))
 }
 
+// EngineMetadata mocks base method
+func (m *MockBackend) EngineMetadata(arg0 context.Context, arg1 uuid.UUID) (*backend.EngineMetadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EngineMetadata", arg0, arg1)
+	ret0, _ := ret[0].(*backend.EngineMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EngineMetadata indicates an expected call of EngineMetadata
+func (mr *MockBackendMockRecorder) EngineMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EngineMetadata", reflect.TypeOf((*MockBackend)(nil).EngineMetadata), arg0, arg1)
+}
+
 // FetchRemoteTableModels mocks base method
 func (m *MockBackend) FetchRemoteTableModels(arg0 context.Context, arg1 string) ([]*model.TableInfo, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,415 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+// Package mocksplit provides an in-memory restore.SplitClient, so that the
+// splits, the scatters and the scans of the regions can be tested without a
+// cluster.
+package mocksplit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/errorpb"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/codec"
+	"github.com/tikv/pd/server/schedule/placement"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+// Client is an in-memory restore.SplitClient. The regions cover the whole key
+// space, each has a peer on every store and the leader on the first store.
+// Like TiKV, a split bumps the version of the epoch, keeps the rightmost part
+// in the original region and rejects the requests of a stale epoch.
+//
+// The regions returned are copies, so they may be kept by the callers while
+// the client changes.
+type Client struct {
+	mu sync.Mutex
+	// regions are sorted by the start key.
+	regions   []*restore.RegionInfo
+	stores    map[uint64]*metapb.Store
+	scattered map[uint64]bool
+	rules     map[string]placement.Rule
+	nextID    uint64
+
+	// BeforeSplit, BeforeScatter and BeforeScan are called before the
+	// requests if not nil, the request fails with the error returned.
+	BeforeSplit   func(region *restore.RegionInfo, keys [][]byte) error
+	BeforeScatter func(region *restore.RegionInfo) error
+	BeforeScan    func(key, endKey []byte, limit int) error
+}
+
+// NewClient creates a Client of storeCount stores and the regions split at
+// the raw keys.
+func NewClient(storeCount int, splitKeys ...[]byte) *Client {
+	c := &Client{
+		stores:    make(map[uint64]*metapb.Store, storeCount),
+		scattered: make(map[uint64]bool),
+		rules:     make(map[string]placement.Rule),
+		nextID:    uint64(storeCount) + 1,
+	}
+	for id := uint64(1); id <= uint64(storeCount); id++ {
+		c.stores[id] = &metapb.Store{
+			Id:      id,
+			Address: fmt.Sprintf("127.0.0.1:%d", 20160+id),
+			State:   metapb.StoreState_Up,
+		}
+	}
+	region := c.newRegion(nil, nil, &metapb.RegionEpoch{ConfVer: 1, Version: 1})
+	c.regions = []*restore.RegionInfo{region}
+	if len(splitKeys) > 0 {
+		if _, _, err := c.split(region, splitKeys); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+func (c *Client) allocID() uint64 {
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+func (c *Client) newRegion(startKey, endKey []byte, epoch *metapb.RegionEpoch) *restore.RegionInfo {
+	region := &metapb.Region{
+		Id:          c.allocID(),
+		StartKey:    startKey,
+		EndKey:      endKey,
+		RegionEpoch: epoch,
+	}
+	for storeID := uint64(1); storeID <= uint64(len(c.stores)); storeID++ {
+		region.Peers = append(region.Peers, &metapb.Peer{Id: c.allocID(), StoreId: storeID})
+	}
+	info := &restore.RegionInfo{Region: region}
+	if len(region.Peers) > 0 {
+		info.Leader = region.Peers[0]
+	}
+	return info
+}
+
+func cloneRegion(region *restore.RegionInfo) *restore.RegionInfo {
+	clone := &restore.RegionInfo{Region: proto.Clone(region.Region).(*metapb.Region)}
+	if region.Leader != nil {
+		clone.Leader = proto.Clone(region.Leader).(*metapb.Peer)
+	}
+	return clone
+}
+
+// locate returns the index of the region containing the encoded key.
+func (c *Client) locate(key []byte) int {
+	return sort.Search(len(c.regions), func(i int) bool {
+		end := c.regions[i].Region.EndKey
+		return len(end) == 0 || bytes.Compare(key, end) < 0
+	})
+}
+
+func (c *Client) regionByID(regionID uint64) (int, *restore.RegionInfo) {
+	for i, region := range c.regions {
+		if region.Region.Id == regionID {
+			return i, region
+		}
+	}
+	return -1, nil
+}
+
+// split splits the region at the raw keys, returning the original region and
+// the new ones, both cloned.
+func (c *Client) split(
+	region *restore.RegionInfo, keys [][]byte,
+) (*restore.RegionInfo, []*restore.RegionInfo, error) {
+	splitKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		splitKey := codec.EncodeBytes(key)
+		if region.ContainsInterior(splitKey) {
+			splitKeys = append(splitKeys, splitKey)
+		}
+	}
+	if len(splitKeys) == 0 {
+		return nil, nil, errors.Annotatef(berrors.ErrRestoreSplitFailed,
+			"split region %d failed: no valid key", region.Region.Id)
+	}
+	sort.Slice(splitKeys, func(i, j int) bool {
+		return bytes.Compare(splitKeys[i], splitKeys[j]) < 0
+	})
+	splitKeys = dedupKeys(splitKeys)
+	epoch := &metapb.RegionEpoch{
+		ConfVer: region.Region.RegionEpoch.GetConfVer(),
+		Version: region.Region.RegionEpoch.GetVersion() + uint64(len(splitKeys)),
+	}
+	newRegions := make([]*restore.RegionInfo, 0, len(splitKeys))
+	startKey := region.Region.StartKey
+	for _, splitKey := range splitKeys {
+		newRegion := c.newRegion(startKey, splitKey, proto.Clone(epoch).(*metapb.RegionEpoch))
+		// the leaders of the new regions stay on the store of the original one.
+		for _, peer := range newRegion.Region.Peers {
+			if peer.StoreId == region.Leader.GetStoreId() {
+				newRegion.Leader = peer
+			}
+		}
+		newRegions = append(newRegions, newRegion)
+		startKey = splitKey
+	}
+	region.Region.StartKey = startKey
+	region.Region.RegionEpoch = epoch
+
+	i, _ := c.regionByID(region.Region.Id)
+	regions := make([]*restore.RegionInfo, 0, len(c.regions)+len(newRegions))
+	regions = append(regions, c.regions[:i]...)
+	regions = append(regions, newRegions...)
+	regions = append(regions, c.regions[i:]...)
+	c.regions = regions
+
+	clones := make([]*restore.RegionInfo, 0, len(newRegions))
+	for _, newRegion := range newRegions {
+		clones = append(clones, cloneRegion(newRegion))
+	}
+	return cloneRegion(region), clones, nil
+}
+
+func dedupKeys(sorted [][]byte) [][]byte {
+	keys := sorted[:1]
+	for _, key := range sorted[1:] {
+		if !bytes.Equal(key, keys[len(keys)-1]) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Regions returns all the regions sorted by the start key.
+func (c *Client) Regions() []*restore.RegionInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	regions := make([]*restore.RegionInfo, 0, len(c.regions))
+	for _, region := range c.regions {
+		regions = append(regions, cloneRegion(region))
+	}
+	return regions
+}
+
+// SetLeader transfers the leader of the region to the peer on the store, the
+// epoch is kept as TiKV does.
+func (c *Client) SetLeader(regionID, storeID uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, region := c.regionByID(regionID)
+	if region == nil {
+		return errors.Errorf("region %d not found", regionID)
+	}
+	for _, peer := range region.Region.Peers {
+		if peer.StoreId == storeID {
+			region.Leader = peer
+			return nil
+		}
+	}
+	return errors.Errorf("region %d has no peer on store %d", regionID, storeID)
+}
+
+// Scattered returns whether the region has been scattered.
+func (c *Client) Scattered(regionID uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.scattered[regionID]
+}
+
+// StoreLabels returns the labels of the store.
+func (c *Client) StoreLabels(storeID uint64) []*metapb.StoreLabel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if store, ok := c.stores[storeID]; ok {
+		return store.Labels
+	}
+	return nil
+}
+
+// GetStore implements restore.SplitClient.
+func (c *Client) GetStore(ctx context.Context, storeID uint64) (*metapb.Store, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	store, ok := c.stores[storeID]
+	if !ok {
+		return nil, errors.Errorf("store %d not found", storeID)
+	}
+	return proto.Clone(store).(*metapb.Store), nil
+}
+
+// GetRegion implements restore.SplitClient.
+func (c *Client) GetRegion(ctx context.Context, key []byte) (*restore.RegionInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cloneRegion(c.regions[c.locate(key)]), nil
+}
+
+// GetRegionByID implements restore.SplitClient.
+func (c *Client) GetRegionByID(ctx context.Context, regionID uint64) (*restore.RegionInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, region := c.regionByID(regionID)
+	if region == nil {
+		return nil, nil
+	}
+	return cloneRegion(region), nil
+}
+
+// SplitRegion implements restore.SplitClient.
+func (c *Client) SplitRegion(
+	ctx context.Context, regionInfo *restore.RegionInfo, key []byte,
+) (*restore.RegionInfo, error) {
+	if !regionInfo.ContainsInterior(codec.EncodeBytes(key)) {
+		return nil, nil
+	}
+	newRegions, err := c.BatchSplitRegions(ctx, regionInfo, [][]byte{key})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return newRegions[0], nil
+}
+
+// BatchSplitRegions implements restore.SplitClient.
+func (c *Client) BatchSplitRegions(
+	ctx context.Context, regionInfo *restore.RegionInfo, keys [][]byte,
+) ([]*restore.RegionInfo, error) {
+	_, newRegions, err := c.BatchSplitRegionsWithOrigin(ctx, regionInfo, keys)
+	return newRegions, err
+}
+
+// BatchSplitRegionsWithOrigin implements restore.SplitClient.
+func (c *Client) BatchSplitRegionsWithOrigin(
+	ctx context.Context, regionInfo *restore.RegionInfo, keys [][]byte,
+) (*restore.RegionInfo, []*restore.RegionInfo, error) {
+	if c.BeforeSplit != nil {
+		if err := c.BeforeSplit(regionInfo, keys); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, region := c.regionByID(regionInfo.Region.GetId())
+	if region == nil {
+		return nil, nil, errors.Annotatef(berrors.ErrRestoreSplitFailed, "split region failed: err=%v",
+			&errorpb.Error{RegionNotFound: &errorpb.RegionNotFound{RegionId: regionInfo.Region.GetId()}})
+	}
+	if !proto.Equal(region.Region.RegionEpoch, regionInfo.Region.RegionEpoch) {
+		return nil, nil, errors.Annotatef(berrors.ErrRestoreSplitFailed, "split region failed: err=%v",
+			&errorpb.Error{
+				Message:       "epoch not match",
+				EpochNotMatch: &errorpb.EpochNotMatch{CurrentRegions: []*metapb.Region{region.Region}},
+			})
+	}
+	return c.split(region, keys)
+}
+
+// ScatterRegion implements restore.SplitClient.
+func (c *Client) ScatterRegion(ctx context.Context, regionInfo *restore.RegionInfo) error {
+	if c.BeforeScatter != nil {
+		if err := c.BeforeScatter(regionInfo); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, region := c.regionByID(regionInfo.Region.GetId()); region == nil {
+		return errors.Errorf("region %d not found", regionInfo.Region.GetId())
+	}
+	c.scattered[regionInfo.Region.GetId()] = true
+	return nil
+}
+
+// GetOperator implements restore.SplitClient. The scatter is finished as soon
+// as it's requested.
+func (c *Client) GetOperator(ctx context.Context, regionID uint64) (*pdpb.GetOperatorResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := &pdpb.GetOperatorResponse{Header: new(pdpb.ResponseHeader), RegionId: regionID}
+	if c.scattered[regionID] {
+		resp.Desc = []byte("scatter-region")
+		resp.Status = pdpb.OperatorStatus_SUCCESS
+	}
+	return resp, nil
+}
+
+// ScanRegions implements restore.SplitClient.
+func (c *Client) ScanRegions(ctx context.Context, key, endKey []byte, limit int) ([]*restore.RegionInfo, error) {
+	if c.BeforeScan != nil {
+		if err := c.BeforeScan(key, endKey, limit); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	regions := make([]*restore.RegionInfo, 0)
+	for i := c.locate(key); i < len(c.regions); i++ {
+		if limit > 0 && len(regions) >= limit {
+			break
+		}
+		region := c.regions[i]
+		if len(endKey) > 0 && bytes.Compare(region.Region.StartKey, endKey) >= 0 {
+			break
+		}
+		regions = append(regions, cloneRegion(region))
+	}
+	return regions, nil
+}
+
+func ruleKey(groupID, ruleID string) string {
+	return groupID + "/" + ruleID
+}
+
+// GetPlacementRule implements restore.SplitClient.
+func (c *Client) GetPlacementRule(ctx context.Context, groupID, ruleID string) (placement.Rule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rule, ok := c.rules[ruleKey(groupID, ruleID)]
+	if !ok {
+		return placement.Rule{}, errors.Errorf("placement rule %s not found", ruleKey(groupID, ruleID))
+	}
+	return rule, nil
+}
+
+// SetPlacementRule implements restore.SplitClient.
+func (c *Client) SetPlacementRule(ctx context.Context, rule placement.Rule) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules[ruleKey(rule.GroupID, rule.ID)] = rule
+	return nil
+}
+
+// DeletePlacementRule implements restore.SplitClient.
+func (c *Client) DeletePlacementRule(ctx context.Context, groupID, ruleID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.rules, ruleKey(groupID, ruleID))
+	return nil
+}
+
+// SetStoresLabel implements restore.SplitClient.
+func (c *Client) SetStoresLabel(ctx context.Context, stores []uint64, labelKey, labelValue string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range stores {
+		store, ok := c.stores[id]
+		if !ok {
+			return errors.Errorf("store %d not found", id)
+		}
+		labels := make([]*metapb.StoreLabel, 0, len(store.Labels)+1)
+		for _, label := range store.Labels {
+			if label.Key != labelKey {
+				labels = append(labels, label)
+			}
+		}
+		if labelValue != "" {
+			labels = append(labels, &metapb.StoreLabel{Key: labelKey, Value: labelValue})
+		}
+		store.Labels = labels
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package mocksplit_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/codec"
+	"github.com/tikv/pd/server/schedule/placement"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/mock/mocksplit"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/rtree"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testMockSplitSuite{})
+
+type testMockSplitSuite struct{}
+
+var _ restore.SplitClient = (*mocksplit.Client)(nil)
+
+func checkContinuous(c *C, regions []*restore.RegionInfo) {
+	c.Assert(regions, Not(HasLen), 0)
+	c.Assert(regions[0].Region.StartKey, HasLen, 0)
+	c.Assert(regions[len(regions)-1].Region.EndKey, HasLen, 0)
+	for i := 1; i < len(regions); i++ {
+		c.Assert(regions[i].Region.StartKey, DeepEquals, regions[i-1].Region.EndKey)
+	}
+}
+
+func (s *testMockSplitSuite) TestSplit(c *C) {
+	ctx := context.Background()
+	cli := mocksplit.NewClient(3, []byte("b"), []byte("d"))
+	regions := cli.Regions()
+	c.Assert(regions, HasLen, 3)
+	checkContinuous(c, regions)
+	c.Assert(regions[1].Region.StartKey, DeepEquals, codec.EncodeBytes([]byte("b")))
+	c.Assert(regions[1].Region.Peers, HasLen, 3)
+	c.Assert(regions[1].Leader.StoreId, Equals, uint64(1))
+
+	region, err := cli.GetRegion(ctx, codec.EncodeBytes([]byte("c")))
+	c.Assert(err, IsNil)
+	c.Assert(region, DeepEquals, regions[1])
+
+	c.Assert(cli.SetLeader(region.Region.Id, 2), IsNil)
+	origin, newRegions, err := cli.BatchSplitRegionsWithOrigin(ctx, region, [][]byte{[]byte("cc"), []byte("c"), []byte("e")})
+	c.Assert(err, IsNil)
+	c.Assert(newRegions, HasLen, 2)
+	c.Assert(newRegions[0].Region.StartKey, DeepEquals, codec.EncodeBytes([]byte("b")))
+	c.Assert(newRegions[1].Region.EndKey, DeepEquals, codec.EncodeBytes([]byte("cc")))
+	c.Assert(newRegions[1].Leader.StoreId, Equals, uint64(2))
+	c.Assert(origin.Region.Id, Equals, region.Region.Id)
+	c.Assert(origin.Region.StartKey, DeepEquals, codec.EncodeBytes([]byte("cc")))
+	c.Assert(origin.Region.RegionEpoch.Version, Equals, region.Region.RegionEpoch.Version+2)
+	checkContinuous(c, cli.Regions())
+	c.Assert(cli.Regions(), HasLen, 5)
+
+	// the epoch of region is stale.
+	_, err = cli.BatchSplitRegions(ctx, region, [][]byte{[]byte("cd")})
+	c.Assert(err, ErrorMatches, ".*epoch not match.*")
+	_, err = cli.BatchSplitRegions(ctx, origin, [][]byte{[]byte("a")})
+	c.Assert(err, ErrorMatches, ".*no valid key.*")
+	newRegion, err := cli.SplitRegion(ctx, origin, []byte("a"))
+	c.Assert(err, IsNil)
+	c.Assert(newRegion, IsNil)
+
+	cli.BeforeSplit = func(*restore.RegionInfo, [][]byte) error {
+		return errors.Annotate(berrors.ErrRestoreSplitFailed, "injected")
+	}
+	_, err = cli.SplitRegion(ctx, origin, []byte("cd"))
+	c.Assert(berrors.Is(err, berrors.ErrRestoreSplitFailed), IsTrue)
+}
+
+func (s *testMockSplitSuite) TestScan(c *C) {
+	ctx := context.Background()
+	cli := mocksplit.NewClient(1, []byte("b"), []byte("c"), []byte("d"))
+	regions, err := cli.ScanRegions(ctx, codec.EncodeBytes([]byte("bb")), codec.EncodeBytes([]byte("d")), 0)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 2)
+	regions, err = cli.ScanRegions(ctx, nil, nil, 3)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 3)
+
+	regions, err = restore.PaginateScanRegion(ctx, cli, []byte{}, []byte{}, 1)
+	c.Assert(err, IsNil)
+	c.Assert(regions, DeepEquals, cli.Regions())
+}
+
+func (s *testMockSplitSuite) TestRegionSplitter(c *C) {
+	ctx := context.Background()
+	cli := mocksplit.NewClient(3, []byte("b"))
+	ranges := []rtree.Range{
+		{StartKey: []byte("a"), EndKey: []byte("aa")},
+		{StartKey: []byte("c"), EndKey: []byte("cc")},
+	}
+	splitter := restore.NewRegionSplitter(cli)
+	c.Assert(splitter.Split(ctx, ranges, &restore.RewriteRules{}, func([][]byte) {}), IsNil)
+
+	regions := cli.Regions()
+	checkContinuous(c, regions)
+	for _, rg := range ranges {
+		key := codec.EncodeBytes(rg.EndKey)
+		found := false
+		for _, region := range regions {
+			// the new regions are the left parts, which are scattered.
+			if bytes.Equal(region.Region.EndKey, key) {
+				found = true
+				c.Assert(cli.Scattered(region.Region.Id), IsTrue)
+			}
+		}
+		c.Assert(found, IsTrue, Commentf("not split at %q", rg.EndKey))
+	}
+}
+
+func (s *testMockSplitSuite) TestPlacement(c *C) {
+	ctx := context.Background()
+	cli := mocksplit.NewClient(2)
+	_, err := cli.GetPlacementRule(ctx, "pd", "default")
+	c.Assert(err, NotNil)
+	rule := placement.Rule{GroupID: "pd", ID: "default", Count: 3}
+	c.Assert(cli.SetPlacementRule(ctx, rule), IsNil)
+	got, err := cli.GetPlacementRule(ctx, "pd", "default")
+	c.Assert(err, IsNil)
+	c.Assert(got.Count, Equals, 3)
+	c.Assert(cli.DeletePlacementRule(ctx, "pd", "default"), IsNil)
+	_, err = cli.GetPlacementRule(ctx, "pd", "default")
+	c.Assert(err, NotNil)
+
+	c.Assert(cli.SetStoresLabel(ctx, []uint64{1, 2}, "exclusive", "restore"), IsNil)
+	c.Assert(cli.StoreLabels(2), HasLen, 1)
+	c.Assert(cli.SetStoresLabel(ctx, []uint64{2}, "exclusive", ""), IsNil)
+	c.Assert(cli.StoreLabels(1), HasLen, 1)
+	c.Assert(cli.StoreLabels(2), HasLen, 0)
+	c.Assert(cli.SetStoresLabel(ctx, []uint64{3}, "exclusive", "restore"), NotNil)
+}
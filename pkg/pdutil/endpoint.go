@@ -0,0 +1,167 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package pdutil
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+const (
+	// srvScheme is the scheme of the domains whose DNS SRV records list the
+	// PDs, e.g. "srv://pd.cluster.local".
+	srvScheme = "srv"
+	// serverNameParam is the query overriding the TLS server name of an
+	// endpoint, e.g. "10.0.0.1:2379?server-name=pd.cluster.local".
+	serverNameParam = "server-name"
+)
+
+// Endpoint is a PD endpoint given by the users.
+type Endpoint struct {
+	// Host is the host and the port of PD, IPv6 addresses are bracketed,
+	// e.g. "[::1]:2379". It's the domain to look up if SRV is set.
+	Host string
+	// SRV is whether the host is a domain whose DNS SRV records list the PDs.
+	SRV bool
+	// ServerName overrides the name the TLS certificate of PD is verified
+	// against, e.g. when PD is reached through a proxy. Only the PD HTTP API
+	// respects it, the gRPC connections of the PD client verify the host.
+	ServerName string
+}
+
+// ParseEndpoint parses a PD address, which is one of "host:port",
+// "[ipv6]:port", a bare IPv6 address, a URL with the http or https scheme
+// matching whether TLS is enabled, or "srv://domain" to be looked up by DNS
+// SRV. The "server-name" query overrides the TLS server name, e.g.
+// "https://10.0.0.1:2379?server-name=pd.cluster.local".
+func ParseEndpoint(addr string, useTLS bool) (Endpoint, error) {
+	raw := strings.TrimSpace(addr)
+	// a bare IPv6 address can't be told from its port, so it has no port.
+	if ip := net.ParseIP(raw); ip != nil && strings.Contains(raw, ":") {
+		return Endpoint{Host: "[" + raw + "]"}, nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "//" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Endpoint{}, errors.Annotatef(berrors.ErrInvalidArgument, "invalid pd address %q: %s", addr, err)
+	}
+
+	var e Endpoint
+	switch u.Scheme {
+	case "":
+	case "http":
+		if useTLS {
+			return Endpoint{}, errors.Annotate(berrors.ErrInvalidArgument, "pd url starts with http while TLS enabled")
+		}
+	case "https":
+		if !useTLS {
+			return Endpoint{}, errors.Annotate(berrors.ErrInvalidArgument, "pd url starts with https while TLS disabled")
+		}
+	case srvScheme:
+		if u.Port() != "" {
+			return Endpoint{}, errors.Annotatef(berrors.ErrInvalidArgument,
+				"the SRV domain of pd address %q has a port, the ports are in the SRV records", addr)
+		}
+		e.SRV = true
+	default:
+		return Endpoint{}, errors.Annotatef(berrors.ErrInvalidArgument,
+			"unsupported scheme %q of pd address %q", u.Scheme, addr)
+	}
+	if u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.Fragment != "" {
+		return Endpoint{}, errors.Annotatef(berrors.ErrInvalidArgument,
+			"invalid pd address %q, it should be like \"host:port\" or \"[ipv6]:port\"", addr)
+	}
+	query := u.Query()
+	for key := range query {
+		if key != serverNameParam {
+			return Endpoint{}, errors.Annotatef(berrors.ErrInvalidArgument,
+				"unknown option %q of pd address %q, only %q is supported", key, addr, serverNameParam)
+		}
+	}
+	e.Host = u.Host
+	e.ServerName = query.Get(serverNameParam)
+	return e, nil
+}
+
+// ParseEndpoints parses the PD addresses by ParseEndpoint.
+func ParseEndpoints(addrs []string, useTLS bool) ([]Endpoint, error) {
+	endpoints := make([]Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		e, err := ParseEndpoint(addr, useTLS)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, nil
+}
+
+// String formats the endpoint in the form parsed by ParseEndpoint.
+func (e Endpoint) String() string {
+	s := e.Host
+	if e.SRV {
+		s = srvScheme + "://" + s
+	}
+	if e.ServerName != "" {
+		s += "?" + serverNameParam + "=" + url.QueryEscape(e.ServerName)
+	}
+	return s
+}
+
+// SRVResolver looks up the DNS SRV records, e.g. net.DefaultResolver.
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// ResolveEndpoints expands the SRV endpoints into the PDs listed by their
+// records, in the order of the priorities. The PDs inherit the server name of
+// the SRV endpoint.
+func ResolveEndpoints(ctx context.Context, resolver SRVResolver, endpoints []Endpoint) ([]Endpoint, error) {
+	resolved := make([]Endpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		if !e.SRV {
+			resolved = append(resolved, e)
+			continue
+		}
+		_, records, err := resolver.LookupSRV(ctx, "", "", e.Host)
+		if err != nil {
+			return nil, errors.Annotatef(berrors.ErrPDUpdateFailed, "failed to look up the SRV records of %s: %s", e.Host, err)
+		}
+		if len(records) == 0 {
+			return nil, errors.Annotatef(berrors.ErrPDUpdateFailed, "no SRV record of %s", e.Host)
+		}
+		for _, record := range records {
+			host := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+			resolved = append(resolved, Endpoint{Host: host, ServerName: e.ServerName})
+		}
+	}
+	return resolved, nil
+}
+
+// Hosts returns the hosts of the resolved endpoints, which are the addresses
+// passed to the PD client and the TiKV storage.
+func Hosts(endpoints []Endpoint) []string {
+	hosts := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		hosts = append(hosts, e.Host)
+	}
+	return hosts
+}
+
+// JoinEndpoints joins the endpoints by comma, keeping their server names.
+func JoinEndpoints(endpoints []Endpoint) string {
+	addrs := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		addrs = append(addrs, e.String())
+	}
+	return strings.Join(addrs, ",")
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package pdutil
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	. "github.com/pingcap/check"
+)
+
+type testEndpointSuite struct{}
+
+var _ = Suite(&testEndpointSuite{})
+
+func (s *testEndpointSuite) TestParseEndpoint(c *C) {
+	cases := []struct {
+		addr   string
+		useTLS bool
+		expect Endpoint
+	}{
+		{addr: "127.0.0.1:2379", expect: Endpoint{Host: "127.0.0.1:2379"}},
+		{addr: " pd:2379 ", expect: Endpoint{Host: "pd:2379"}},
+		{addr: "http://127.0.0.1:2379/", expect: Endpoint{Host: "127.0.0.1:2379"}},
+		{addr: "[::1]:2379", expect: Endpoint{Host: "[::1]:2379"}},
+		{addr: "fd00::1", expect: Endpoint{Host: "[fd00::1]"}},
+		{addr: "https://[fd00::1]:2379", useTLS: true, expect: Endpoint{Host: "[fd00::1]:2379"}},
+		{addr: "srv://pd.cluster.local", expect: Endpoint{Host: "pd.cluster.local", SRV: true}},
+		{
+			addr:   "https://10.0.0.1:2379?server-name=pd.cluster.local",
+			useTLS: true,
+			expect: Endpoint{Host: "10.0.0.1:2379", ServerName: "pd.cluster.local"},
+		},
+	}
+	for _, ca := range cases {
+		e, err := ParseEndpoint(ca.addr, ca.useTLS)
+		c.Assert(err, IsNil, Commentf("addr %s", ca.addr))
+		c.Assert(e, DeepEquals, ca.expect, Commentf("addr %s", ca.addr))
+
+		// the formatted endpoint is parsed back.
+		again, err := ParseEndpoint(e.String(), ca.useTLS)
+		c.Assert(err, IsNil)
+		c.Assert(again, DeepEquals, e)
+	}
+
+	for _, addr := range []string{
+		"https://127.0.0.1:2379",
+		"srv://pd.cluster.local:2379",
+		"etcd://127.0.0.1:2379",
+		"127.0.0.1:2379/pd/api",
+		"127.0.0.1:2379?sni=pd",
+		"",
+	} {
+		_, err := ParseEndpoint(addr, false)
+		c.Assert(err, NotNil, Commentf("addr %s", addr))
+	}
+	_, err := ParseEndpoint("http://127.0.0.1:2379", true)
+	c.Assert(err, ErrorMatches, ".*pd url starts with http while TLS enabled.*")
+}
+
+type fakeSRVResolver map[string][]*net.SRV
+
+func (r fakeSRVResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	return "", r[name], nil
+}
+
+func (s *testEndpointSuite) TestResolveEndpoints(c *C) {
+	resolver := fakeSRVResolver{
+		"pd.cluster.local": {
+			{Target: "pd-0.cluster.local.", Port: 2379},
+			{Target: "fd00::2", Port: 2379},
+		},
+	}
+	endpoints, err := ParseEndpoints([]string{"10.0.0.1:2379", "srv://pd.cluster.local?server-name=pd"}, true)
+	c.Assert(err, IsNil)
+	resolved, err := ResolveEndpoints(context.Background(), resolver, endpoints)
+	c.Assert(err, IsNil)
+	c.Assert(resolved, DeepEquals, []Endpoint{
+		{Host: "10.0.0.1:2379"},
+		{Host: "pd-0.cluster.local:2379", ServerName: "pd"},
+		{Host: "[fd00::2]:2379", ServerName: "pd"},
+	})
+	c.Assert(Hosts(resolved), DeepEquals, []string{"10.0.0.1:2379", "pd-0.cluster.local:2379", "[fd00::2]:2379"})
+	c.Assert(JoinEndpoints(resolved), Equals,
+		"10.0.0.1:2379,pd-0.cluster.local:2379?server-name=pd,[fd00::2]:2379?server-name=pd")
+
+	_, err = ResolveEndpoints(context.Background(), resolver, []Endpoint{{Host: "unknown.local", SRV: true}})
+	c.Assert(err, ErrorMatches, ".*no SRV record of unknown.local.*")
+
+	cli := NewHTTPClientFromEndpoints(resolved, &tls.Config{})
+	c.Assert(cli.addrs, DeepEquals, []string{
+		"https://10.0.0.1:2379", "https://pd-0.cluster.local:2379", "https://[fd00::2]:2379",
+	})
+	c.Assert(cli.serverNameClis, HasLen, 2)
+	c.Assert(cli.serverNameClis["https://pd-0.cluster.local:2379"], NotNil)
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package pdutil
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pdEndpointHealthGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "br",
+			Subsystem: "pd",
+			Name:      "endpoint_health",
+			Help:      "Whether the PD endpoint answered the last request, 1 for healthy.",
+		}, []string{"endpoint"})
+
+	pdEndpointFailureCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "br",
+			Subsystem: "pd",
+			Name:      "endpoint_failures",
+			Help:      "The requests failed to reach the PD endpoint.",
+		}, []string{"endpoint"})
+
+	pdEndpointSwitchoverCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "br",
+			Subsystem: "pd",
+			Name:      "endpoint_switchovers",
+			Help:      "The switchovers of the PD endpoint the requests are sent to first.",
+		})
+)
+
+func init() { // nolint:gochecknoinits
+	prometheus.MustRegister(pdEndpointHealthGauge)
+	prometheus.MustRegister(pdEndpointFailureCounter)
+	prometheus.MustRegister(pdEndpointSwitchoverCounter)
+}
@@ -3,13 +3,12 @@
 package pdutil
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -21,14 +20,12 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
-	"github.com/pingcap/tidb/util/codec"
 	pd "github.com/tikv/pd/client"
 	pdapi "github.com/tikv/pd/server/api"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 
 	berrors "github.com/pingcap/br/pkg/errors"
-	"github.com/pingcap/br/pkg/httputil"
 	"github.com/pingcap/br/pkg/lightning/common"
 )
 
@@ -39,13 +36,17 @@ const (
 	schedulerPrefix      = "pd/api/v1/schedulers"
 	maxMsgSize           = int(128 * units.MiB) // pd.ScanRegion may return a large response
 	scheduleConfigPrefix = "pd/api/v1/config/schedule"
-	pauseTimeout         = 5 * time.Minute
+	// pauseTimeout is the TTL of pausing the schedulers and configs. The pause
+	// is renewed periodically, so PD resumes them soon if BR exits abnormally.
+	pauseTimeout = 5 * time.Minute
 
 	// pd request retry time when connection fail
 	pdRequestRetryTime = 10
 
 	// set max-pending-peer-count to a large value to avoid scatter region failed.
 	maxPendingPeerUnlimited uint64 = math.MaxInt32
+
+	mergeScheduleLimitKey = "merge-schedule-limit"
 )
 
 // pauseConfigGenerator generate a config value according to store count and current value.
@@ -86,6 +87,7 @@ type ClusterConfig struct {
 }
 
 type pauseSchedulerBody struct {
+	// Delay is in seconds.
 	Delay int64 `json:"delay"`
 }
 
@@ -145,6 +147,9 @@ func pdRequest(
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	resp, err := cli.Do(req)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -165,7 +170,10 @@ func pdRequest(
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		res, _ := io.ReadAll(resp.Body)
-		return nil, errors.Annotatef(berrors.ErrPDInvalidResponse, "[%d] %s %s", resp.StatusCode, res, reqURL)
+		return nil, &responseError{
+			statusCode: resp.StatusCode,
+			err:        errors.Annotatef(berrors.ErrPDInvalidResponse, "[%d] %s %s", resp.StatusCode, res, reqURL),
+		}
 	}
 
 	r, err := io.ReadAll(resp.Body)
@@ -177,10 +185,12 @@ func pdRequest(
 
 // PdController manage get/update config from pd.
 type PdController struct {
-	addrs    []string
-	cli      *http.Client
-	pdClient pd.Client
-	version  *semver.Version
+	addrs          []string
+	cli            *http.Client
+	serverNameClis map[string]*http.Client
+	picker         *endpointPicker
+	pdClient       pd.Client
+	version        *semver.Version
 
 	// control the pause schedulers goroutine
 	schedulerPauseCh chan struct{}
@@ -193,31 +203,24 @@ func NewPdController(
 	tlsConf *tls.Config,
 	securityOption pd.SecurityOption,
 ) (*PdController, error) {
-	cli := httputil.NewClient(tlsConf)
-
-	addrs := strings.Split(pdAddrs, ",")
-	processedAddrs := make([]string, 0, len(addrs))
-	var failure error
-	var versionBytes []byte
-	for _, addr := range addrs {
-		if !strings.HasPrefix(addr, "http") {
-			if tlsConf != nil {
-				addr = "https://" + addr
-			} else {
-				addr = "http://" + addr
-			}
-		}
-		processedAddrs = append(processedAddrs, addr)
-		versionBytes, failure = pdRequest(ctx, addr, clusterVersionPrefix, cli, http.MethodGet, nil)
-		if failure == nil {
-			break
-		}
+	endpoints, err := ParseEndpoints(strings.Split(pdAddrs, ","), tlsConf != nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if endpoints, err = ResolveEndpoints(ctx, net.DefaultResolver, endpoints); err != nil {
+		return nil, errors.Trace(err)
 	}
-	if failure != nil {
+	addrs := Hosts(endpoints)
+	httpCli := NewHTTPClientFromEndpoints(endpoints, tlsConf)
+	clusterVersion, err := httpCli.GetClusterVersion(ctx)
+	if err != nil {
 		return nil, errors.Annotatef(berrors.ErrPDUpdateFailed, "pd address (%s) not available, please check network", pdAddrs)
 	}
+	if err := httpCli.DiscoverMembers(ctx); err != nil {
+		log.Warn("failed to discover the PD members, only failing over the given PDs", zap.Error(err))
+	}
 
-	version := parseVersion(versionBytes)
+	version := parseVersion([]byte(clusterVersion))
 	maxCallMsgSize := []grpc.DialOption{
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)),
 		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(maxMsgSize)),
@@ -233,10 +236,12 @@ func NewPdController(
 	}
 
 	return &PdController{
-		addrs:    processedAddrs,
-		cli:      cli,
-		pdClient: pdClient,
-		version:  version,
+		addrs:          httpCli.addrs,
+		cli:            httpCli.cli,
+		serverNameClis: httpCli.serverNameClis,
+		picker:         httpCli.picker,
+		pdClient:       pdClient,
+		version:        version,
 		// We should make a buffered channel here otherwise when context canceled,
 		// gracefully shutdown will stick at resuming schedulers.
 		schedulerPauseCh: make(chan struct{}, 1),
@@ -271,6 +276,7 @@ func (p *PdController) isPauseConfigEnabled() bool {
 func (p *PdController) SetHTTP(addrs []string, cli *http.Client) {
 	p.addrs = addrs
 	p.cli = cli
+	p.picker = nil
 }
 
 // SetPDClient set pd addrs and cli for test.
@@ -283,23 +289,22 @@ func (p *PdController) GetPDClient() pd.Client {
 	return p.pdClient
 }
 
+// HTTPClient returns the client of the PD HTTP API.
+func (p *PdController) HTTPClient() *HTTPClient {
+	return p.httpClientWith(pdRequest)
+}
+
+func (p *PdController) httpClientWith(send pdHTTPRequest) *HTTPClient {
+	return &HTTPClient{addrs: p.addrs, cli: p.cli, serverNameClis: p.serverNameClis, picker: p.picker, send: send}
+}
+
 // GetClusterVersion returns the current cluster version.
 func (p *PdController) GetClusterVersion(ctx context.Context) (string, error) {
 	return p.getClusterVersionWith(ctx, pdRequest)
 }
 
 func (p *PdController) getClusterVersionWith(ctx context.Context, get pdHTTPRequest) (string, error) {
-	var err error
-	for _, addr := range p.addrs {
-		v, e := get(ctx, addr, clusterVersionPrefix, p.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		return string(v), nil
-	}
-
-	return "", errors.Trace(err)
+	return p.httpClientWith(get).GetClusterVersion(ctx)
 }
 
 // GetRegionCount returns the region count in the specified range.
@@ -310,30 +315,7 @@ func (p *PdController) GetRegionCount(ctx context.Context, startKey, endKey []by
 func (p *PdController) getRegionCountWith(
 	ctx context.Context, get pdHTTPRequest, startKey, endKey []byte,
 ) (int, error) {
-	// TiKV reports region start/end keys to PD in memcomparable-format.
-	var start, end string
-	start = url.QueryEscape(string(codec.EncodeBytes(nil, startKey)))
-	if len(endKey) != 0 { // Empty end key means the max.
-		end = url.QueryEscape(string(codec.EncodeBytes(nil, endKey)))
-	}
-	var err error
-	for _, addr := range p.addrs {
-		query := fmt.Sprintf(
-			"%s?start_key=%s&end_key=%s",
-			regionCountPrefix, start, end)
-		v, e := get(ctx, addr, query, p.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		regionsMap := make(map[string]interface{})
-		err = json.Unmarshal(v, &regionsMap)
-		if err != nil {
-			return 0, errors.Trace(err)
-		}
-		return int(regionsMap["count"].(float64)), nil
-	}
-	return 0, errors.Trace(err)
+	return p.httpClientWith(get).GetRegionCount(ctx, startKey, endKey)
 }
 
 // GetStoreInfo returns the info of store with the specified id.
@@ -343,46 +325,19 @@ func (p *PdController) GetStoreInfo(ctx context.Context, storeID uint64) (*pdapi
 
 func (p *PdController) getStoreInfoWith(
 	ctx context.Context, get pdHTTPRequest, storeID uint64) (*pdapi.StoreInfo, error) {
-	var err error
-	for _, addr := range p.addrs {
-		query := fmt.Sprintf(
-			"%s/%d",
-			storePrefix, storeID)
-		v, e := get(ctx, addr, query, p.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		store := pdapi.StoreInfo{}
-		err = json.Unmarshal(v, &store)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return &store, nil
-	}
-	return nil, errors.Trace(err)
+	return p.httpClientWith(get).GetStore(ctx, storeID)
 }
 
 func (p *PdController) doPauseSchedulers(ctx context.Context, schedulers []string, post pdHTTPRequest) ([]string, error) {
-	// pause this scheduler with 300 seconds
-	body, err := json.Marshal(pauseSchedulerBody{Delay: int64(pauseTimeout)})
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
+	cli := p.httpClientWith(post)
 	// PauseSchedulers remove pd scheduler temporarily.
 	removedSchedulers := make([]string, 0, len(schedulers))
 	for _, scheduler := range schedulers {
-		prefix := fmt.Sprintf("%s/%s", schedulerPrefix, scheduler)
-		for _, addr := range p.addrs {
-			_, err = post(ctx, addr, prefix, p.cli, http.MethodPost, bytes.NewBuffer(body))
-			if err == nil {
-				removedSchedulers = append(removedSchedulers, scheduler)
-				break
-			}
-		}
-		if err != nil {
+		// pause this scheduler with 300 seconds
+		if err := cli.PauseScheduler(ctx, scheduler, pauseTimeout); err != nil {
 			return removedSchedulers, errors.Trace(err)
 		}
+		removedSchedulers = append(removedSchedulers, scheduler)
 	}
 	return removedSchedulers, nil
 }
@@ -410,34 +365,51 @@ func (p *PdController) pauseSchedulersAndConfigWith(
 		log.Info("pause configs successful at beginning", zap.Any("cfg", schedulerCfg))
 	}
 
-	go func() {
-		tick := time.NewTicker(pauseTimeout / 3)
-		defer tick.Stop()
+	go p.renewPauseLease(ctx, removedSchedulers, schedulerCfg, post)
+	return removedSchedulers, nil
+}
+
+// renewPauseLease pauses the schedulers and configs again before the pause
+// expires, until they are resumed or the context is done. The pause isn't
+// renewed after BR exits, so PD resumes them after at most pauseTimeout.
+func (p *PdController) renewPauseLease(
+	ctx context.Context, schedulers []string,
+	schedulerCfg map[string]interface{}, post pdHTTPRequest,
+) {
+	tick := time.NewTicker(pauseTimeout / 3)
+	defer tick.Stop()
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-tick.C:
-				_, err := p.doPauseSchedulers(ctx, schedulers, post)
-				if err != nil {
-					log.Warn("pause scheduler failed, ignore it and wait next time pause", zap.Error(err))
+	expireAt := time.Now().Add(pauseTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			_, err := p.doPauseSchedulers(ctx, schedulers, post)
+			if err != nil {
+				log.Warn("pause scheduler failed, ignore it and wait next time pause", zap.Error(err))
+			}
+			if schedulerCfg != nil {
+				if cfgErr := p.doPauseConfigs(ctx, schedulerCfg, post); cfgErr != nil {
+					log.Warn("pause configs failed, ignore it and wait next time pause", zap.Error(cfgErr))
+					err = cfgErr
 				}
-				if schedulerCfg != nil {
-					err = p.doPauseConfigs(ctx, schedulerCfg, post)
-					if err != nil {
-						log.Warn("pause configs failed, ignore it and wait next time pause", zap.Error(err))
-					}
+			}
+			if err != nil {
+				if time.Now().After(expireAt) {
+					log.Warn("the pause of schedulers has expired, they may be resumed by PD until the next pause",
+						zap.Strings("name", schedulers), zap.Time("expireAt", expireAt))
 				}
-				log.Info("pause scheduler(configs)", zap.Strings("name", removedSchedulers),
-					zap.Any("cfg", schedulerCfg))
-			case <-p.schedulerPauseCh:
-				log.Info("exit pause scheduler and configs successful")
-				return
+				continue
 			}
+			expireAt = time.Now().Add(pauseTimeout)
+			log.Info("pause scheduler(configs)", zap.Strings("name", schedulers),
+				zap.Any("cfg", schedulerCfg))
+		case <-p.schedulerPauseCh:
+			log.Info("exit pause scheduler and configs successful")
+			return
 		}
-	}()
-	return removedSchedulers, nil
+	}
 }
 
 // ResumeSchedulers resume pd scheduler.
@@ -449,20 +421,10 @@ func (p *PdController) resumeSchedulerWith(ctx context.Context, schedulers []str
 	log.Info("resume scheduler", zap.Strings("schedulers", schedulers))
 	p.schedulerPauseCh <- struct{}{}
 
-	// 0 means stop pause.
-	body, err := json.Marshal(pauseSchedulerBody{Delay: 0})
-	if err != nil {
-		return errors.Trace(err)
-	}
+	cli := p.httpClientWith(post)
 	for _, scheduler := range schedulers {
-		prefix := fmt.Sprintf("%s/%s", schedulerPrefix, scheduler)
-		for _, addr := range p.addrs {
-			_, err = post(ctx, addr, prefix, p.cli, http.MethodPost, bytes.NewBuffer(body))
-			if err == nil {
-				break
-			}
-		}
-		if err != nil {
+		// 0 means stop pause.
+		if err = cli.PauseScheduler(ctx, scheduler, 0); err != nil {
 			log.Error("failed to resume scheduler after retry, you may reset this scheduler manually"+
 				"or just wait this scheduler pause timeout", zap.String("scheduler", scheduler))
 		} else {
@@ -479,21 +441,7 @@ func (p *PdController) ListSchedulers(ctx context.Context) ([]string, error) {
 }
 
 func (p *PdController) listSchedulersWith(ctx context.Context, get pdHTTPRequest) ([]string, error) {
-	var err error
-	for _, addr := range p.addrs {
-		v, e := get(ctx, addr, schedulerPrefix, p.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		d := make([]string, 0)
-		err = json.Unmarshal(v, &d)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return d, nil
-	}
-	return nil, errors.Trace(err)
+	return p.httpClientWith(get).ListSchedulers(ctx)
 }
 
 // GetPDScheduleConfig returns PD schedule config value associated with the key.
@@ -501,22 +449,7 @@ func (p *PdController) listSchedulersWith(ctx context.Context, get pdHTTPRequest
 func (p *PdController) GetPDScheduleConfig(
 	ctx context.Context,
 ) (map[string]interface{}, error) {
-	var err error
-	for _, addr := range p.addrs {
-		v, e := pdRequest(
-			ctx, addr, scheduleConfigPrefix, p.cli, http.MethodGet, nil)
-		if e != nil {
-			err = e
-			continue
-		}
-		cfg := make(map[string]interface{})
-		err = json.Unmarshal(v, &cfg)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return cfg, nil
-	}
-	return nil, errors.Trace(err)
+	return p.HTTPClient().GetScheduleConfig(ctx)
 }
 
 // UpdatePDScheduleConfig updates PD schedule config value associated with the key.
@@ -526,31 +459,22 @@ func (p *PdController) UpdatePDScheduleConfig(ctx context.Context) error {
 }
 
 func (p *PdController) doUpdatePDScheduleConfig(
-	ctx context.Context, cfg map[string]interface{}, post pdHTTPRequest, prefixs ...string,
+	ctx context.Context, cfg map[string]interface{}, post pdHTTPRequest,
 ) error {
-	prefix := scheduleConfigPrefix
-	if len(prefixs) != 0 {
-		prefix = prefixs[0]
+	if err := p.httpClientWith(post).UpdateScheduleConfig(ctx, cfg); err != nil {
+		log.Warn("failed to update PD config", zap.Error(err))
+		return errors.Annotate(berrors.ErrPDUpdateFailed, "failed to update PD schedule config")
 	}
-	for _, addr := range p.addrs {
-		reqData, err := json.Marshal(cfg)
-		if err != nil {
-			return errors.Trace(err)
-		}
-		_, e := post(ctx, addr, prefix,
-			p.cli, http.MethodPost, bytes.NewBuffer(reqData))
-		if e == nil {
-			return nil
-		}
-		log.Warn("failed to update PD config, will try next", zap.Error(e), zap.String("pd", addr))
-	}
-	return errors.Annotate(berrors.ErrPDUpdateFailed, "failed to update PD schedule config")
+	return nil
 }
 
 func (p *PdController) doPauseConfigs(ctx context.Context, cfg map[string]interface{}, post pdHTTPRequest) error {
 	// pause this scheduler with 300 seconds
-	prefix := fmt.Sprintf("%s?ttlSecond=%.0f", scheduleConfigPrefix, pauseTimeout.Seconds())
-	return p.doUpdatePDScheduleConfig(ctx, cfg, post, prefix)
+	if err := p.httpClientWith(post).PauseScheduleConfig(ctx, cfg, pauseTimeout); err != nil {
+		log.Warn("failed to pause PD config", zap.Error(err))
+		return errors.Annotate(berrors.ErrPDUpdateFailed, "failed to update PD schedule config")
+	}
+	return nil
 }
 
 func restoreSchedulers(ctx context.Context, pd *PdController, clusterCfg ClusterConfig) error {
@@ -568,18 +492,54 @@ func restoreSchedulers(ctx context.Context, pd *PdController, clusterCfg Cluster
 		mergeCfg[cfgKey] = value
 	}
 
-	prefix := make([]string, 0, 1)
+	cli := pd.HTTPClient()
+	var err error
 	if pd.isPauseConfigEnabled() {
 		// set config's ttl to zero, make temporary config invalid immediately.
-		prefix = append(prefix, fmt.Sprintf("%s?ttlSecond=%d", scheduleConfigPrefix, 0))
+		err = cli.PauseScheduleConfig(ctx, mergeCfg, 0)
+	} else {
+		// reset config with previous value.
+		err = cli.UpdateScheduleConfig(ctx, mergeCfg)
 	}
-	// reset config with previous value.
-	if err := pd.doUpdatePDScheduleConfig(ctx, mergeCfg, pdRequest, prefix...); err != nil {
+	if err != nil {
 		return errors.Annotate(err, "fail to update PD merge config")
 	}
 	return nil
 }
 
+// AccelerateRegionMerge raises the merge schedule limit of PD for the ttl, so
+// the small regions are merged faster. It changes nothing if PD doesn't
+// support the configs with TTL, lest the raised limit stays forever when BR
+// exits abnormally.
+func (p *PdController) AccelerateRegionMerge(ctx context.Context, ttl time.Duration) (UndoFunc, error) {
+	if !p.isPauseConfigEnabled() {
+		log.Warn("PD doesn't support the configs with TTL, keep the merge schedule limit unchanged")
+		return Nop, nil
+	}
+	stores, err := p.pdClient.GetAllStores(ctx)
+	if err != nil {
+		return Nop, errors.Trace(err)
+	}
+	scheduleCfg, err := p.GetPDScheduleConfig(ctx)
+	if err != nil {
+		return Nop, errors.Trace(err)
+	}
+	origin, ok := scheduleCfg[mergeScheduleLimitKey]
+	if !ok {
+		return Nop, nil
+	}
+	cli := p.HTTPClient()
+	raised := map[string]interface{}{mergeScheduleLimitKey: pauseConfigMulStores(len(stores), origin)}
+	if err := cli.PauseScheduleConfig(ctx, raised, ttl); err != nil {
+		return Nop, errors.Annotate(berrors.ErrPDUpdateFailed, err.Error())
+	}
+	log.Info("raised the merge schedule limit", zap.Any("cfg", raised), zap.Duration("ttl", ttl))
+	return func(ctx context.Context) error {
+		// a zero ttl makes the raised limit invalid immediately.
+		return errors.Trace(cli.PauseScheduleConfig(ctx, map[string]interface{}{mergeScheduleLimitKey: origin}, 0))
+	}, nil
+}
+
 // MakeUndoFunctionByConfig return an UndoFunc based on specified ClusterConfig
 func (p *PdController) MakeUndoFunctionByConfig(config ClusterConfig) UndoFunc {
 	restore := func(ctx context.Context) error {
@@ -678,6 +638,8 @@ func (p *PdController) doRemoveSchedulersWith(
 	} else {
 		// adapt to earlier version (before 4.0.8) of pd cluster
 		// which doesn't have temporary config setting.
+		log.Warn("the PD configs are changed without TTL, they must be restored manually if BR exits abnormally",
+			zap.Any("cfg", disablePDCfg))
 		err = p.doUpdatePDScheduleConfig(ctx, disablePDCfg, pdRequest)
 		if err != nil {
 			return nil, err
@@ -695,18 +657,5 @@ func (p *PdController) Close() {
 
 // FetchPDVersion get pd version
 func FetchPDVersion(ctx context.Context, tls *common.TLS, pdAddr string) (*semver.Version, error) {
-	// An example of PD version API.
-	// curl http://pd_address/pd/api/v1/version
-	// {
-	//   "version": "v4.0.0-rc.2-451-g760fb650"
-	// }
-	var rawVersion struct {
-		Version string `json:"version"`
-	}
-	err := tls.WithHost(pdAddr).GetJSON(ctx, "/pd/api/v1/version", &rawVersion)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-
-	return parseVersion([]byte(rawVersion.Version)), nil
+	return NewHTTPClientFromTLS(tls.WithHost(pdAddr)).GetVersion(ctx)
 }
@@ -86,6 +86,28 @@ func (s *testPDControllerSuite) TestScheduler(c *C) {
 	c.Assert(schedulers[0], Equals, scheduler)
 }
 
+func (s *testPDControllerSuite) TestPauseSchedulerDelay(c *C) {
+	ctx := context.Background()
+	var bodies []string
+	mock := func(_ context.Context, _ string, _ string, _ *http.Client, _ string, body io.Reader) ([]byte, error) {
+		b, err := io.ReadAll(body)
+		c.Assert(err, IsNil)
+		bodies = append(bodies, string(b))
+		return nil, nil
+	}
+	pdController := &PdController{addrs: []string{""}, schedulerPauseCh: make(chan struct{}, 1)}
+
+	// the schedulers are paused with a TTL in seconds, so PD resumes them if
+	// the pause isn't renewed.
+	_, err := pdController.pauseSchedulersAndConfigWith(ctx, []string{"balance-leader-scheduler"}, nil, mock)
+	c.Assert(err, IsNil)
+	c.Assert(bodies, DeepEquals, []string{`{"delay":300}`})
+
+	err = pdController.resumeSchedulerWith(ctx, []string{"balance-leader-scheduler"}, mock)
+	c.Assert(err, IsNil)
+	c.Assert(bodies, DeepEquals, []string{`{"delay":300}`, `{"delay":0}`})
+}
+
 func (s *testPDControllerSuite) TestGetClusterVersion(c *C) {
 	pdController := &PdController{addrs: []string{"", ""}} // two endpoints
 	counter := 0
@@ -0,0 +1,503 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package pdutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/util/codec"
+	pdapi "github.com/tikv/pd/server/api"
+	pdconfig "github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/audit"
+	"github.com/pingcap/br/pkg/httputil"
+	"github.com/pingcap/br/pkg/lightning/common"
+)
+
+const (
+	versionPrefix         = "pd/api/v1/version"
+	storesPrefix          = "pd/api/v1/stores"
+	replicateConfigPrefix = "pd/api/v1/config/replicate"
+	placementRulePrefix   = "pd/api/v1/config/rule"
+	placementRulesPrefix  = "pd/api/v1/config/rules"
+	resetTSPrefix         = "pd/api/v1/admin/reset-ts"
+	regionsKeyPrefix      = "pd/api/v1/regions/key"
+	operatorsPrefix       = "pd/api/v1/operators"
+
+	// the rounds of trying all PD addresses when none of them can be reached.
+	pdHTTPRetryTimes    = 3
+	pdHTTPRetryInterval = 200 * time.Millisecond
+)
+
+// responseError is the error of a request answered by PD with a non-OK status.
+type responseError struct {
+	statusCode int
+	err        error
+}
+
+func (e *responseError) Error() string {
+	return e.err.Error()
+}
+
+// Cause implements the causer interface of github.com/pingcap/errors.
+func (e *responseError) Cause() error {
+	return e.err
+}
+
+func (e *responseError) Unwrap() error {
+	return e.err
+}
+
+// statusCodeOf returns the status of the PD response failing the request, or 0
+// if the request isn't answered.
+func statusCodeOf(err error) int {
+	if e, ok := err.(*responseError); ok {
+		return e.statusCode
+	}
+	return 0
+}
+
+// HTTPClient is a client of the PD HTTP API.
+//
+// A request is sent to the PD addresses in turn until one of them answers it.
+// The server errors are retried by every address, and the requests reaching no
+// PD are retried with backoff, while the client errors fail immediately.
+type HTTPClient struct {
+	addrs []string
+	cli   *http.Client
+	// serverNameClis are the clients of the addresses overriding the TLS
+	// server name.
+	serverNameClis map[string]*http.Client
+	// picker orders the addresses to try, nil means trying them in order.
+	picker *endpointPicker
+	send   pdHTTPRequest
+}
+
+// NewHTTPClient creates an HTTPClient. The addresses without scheme are
+// completed by whether TLS is enabled.
+func NewHTTPClient(addrs []string, tlsConf *tls.Config) *HTTPClient {
+	processedAddrs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.HasPrefix(addr, "http") {
+			if tlsConf != nil {
+				addr = "https://" + addr
+			} else {
+				addr = "http://" + addr
+			}
+		}
+		processedAddrs = append(processedAddrs, strings.TrimRight(addr, "/"))
+	}
+	return &HTTPClient{
+		addrs:  processedAddrs,
+		cli:    httputil.NewClient(tlsConf),
+		picker: newEndpointPicker(processedAddrs),
+		send:   pdRequest,
+	}
+}
+
+// NewHTTPClientFromEndpoints creates an HTTPClient of the resolved endpoints,
+// verifying the TLS server names they override.
+func NewHTTPClientFromEndpoints(endpoints []Endpoint, tlsConf *tls.Config) *HTTPClient {
+	c := NewHTTPClient(Hosts(endpoints), tlsConf)
+	if tlsConf == nil {
+		return c
+	}
+	for i, e := range endpoints {
+		if e.ServerName == "" {
+			continue
+		}
+		if c.serverNameClis == nil {
+			c.serverNameClis = make(map[string]*http.Client)
+		}
+		conf := tlsConf.Clone()
+		conf.ServerName = e.ServerName
+		c.serverNameClis[c.addrs[i]] = httputil.NewClient(conf)
+	}
+	return c
+}
+
+// NewHTTPClientFromTLS creates an HTTPClient sending the requests to the host
+// of the TLS instance of lightning.
+func NewHTTPClientFromTLS(tls *common.TLS) *HTTPClient {
+	return &HTTPClient{
+		addrs: []string{tls.URL()},
+		cli:   tls.HTTPClient(),
+		send:  pdRequest,
+	}
+}
+
+func (c *HTTPClient) request(ctx context.Context, method, prefix string, body []byte) ([]byte, error) {
+	var err error
+	backoff := pdHTTPRetryInterval
+	for i := 0; i < pdHTTPRetryTimes; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, errors.Trace(ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		addrs := c.addrs
+		if c.picker != nil {
+			addrs = c.picker.order()
+		}
+		for _, addr := range addrs {
+			var reader io.Reader
+			if body != nil {
+				reader = bytes.NewReader(body)
+			}
+			var resp []byte
+			cli := c.cli
+			if serverNameCli, ok := c.serverNameClis[addr]; ok {
+				cli = serverNameCli
+			}
+			resp, err = c.send(ctx, addr, prefix, cli, method, reader)
+			code := statusCodeOf(err)
+			if err == nil || (code != 0 && code < http.StatusInternalServerError) {
+				// the client errors are answered by a healthy PD.
+				if c.picker != nil {
+					c.picker.succeed(addr)
+				}
+				if err != nil {
+					return nil, err
+				}
+				return resp, nil
+			}
+			if c.picker != nil {
+				c.picker.fail(addr)
+			}
+			log.Warn("failed to request PD, will try next",
+				zap.String("pd", addr), zap.String("path", prefix), zap.Error(err))
+		}
+		if statusCodeOf(err) != 0 {
+			// the server errors have been retried by pdRequest.
+			break
+		}
+	}
+	return nil, err
+}
+
+func (c *HTTPClient) getJSON(ctx context.Context, prefix string, v interface{}) error {
+	resp, err := c.request(ctx, http.MethodGet, prefix, nil)
+	if err != nil {
+		return err
+	}
+	return errors.Trace(json.Unmarshal(resp, v))
+}
+
+func (c *HTTPClient) postJSON(ctx context.Context, prefix string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = c.request(ctx, http.MethodPost, prefix, body)
+	return err
+}
+
+// GetVersion returns the version of PD.
+func (c *HTTPClient) GetVersion(ctx context.Context) (*semver.Version, error) {
+	// An example of PD version API.
+	// curl http://pd_address/pd/api/v1/version
+	// {
+	//   "version": "v4.0.0-rc.2-451-g760fb650"
+	// }
+	var rawVersion struct {
+		Version string `json:"version"`
+	}
+	if err := c.getJSON(ctx, versionPrefix, &rawVersion); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return parseVersion([]byte(rawVersion.Version)), nil
+}
+
+// GetClusterVersion returns the cluster version.
+func (c *HTTPClient) GetClusterVersion(ctx context.Context) (string, error) {
+	v, err := c.request(ctx, http.MethodGet, clusterVersionPrefix, nil)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(v), nil
+}
+
+// GetScheduleConfig returns the schedule config.
+func (c *HTTPClient) GetScheduleConfig(ctx context.Context) (map[string]interface{}, error) {
+	cfg := make(map[string]interface{})
+	if err := c.getJSON(ctx, scheduleConfigPrefix, &cfg); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return cfg, nil
+}
+
+// UpdateScheduleConfig updates the schedule config items in cfg.
+func (c *HTTPClient) UpdateScheduleConfig(ctx context.Context, cfg map[string]interface{}) error {
+	start := time.Now()
+	err := c.postJSON(ctx, scheduleConfigPrefix, cfg)
+	audit.Record(audit.ActionUpdateScheduleConfig, "schedule-config", fmt.Sprint(cfg), start, err)
+	return errors.Trace(err)
+}
+
+// PauseScheduleConfig updates the schedule config items in cfg temporarily,
+// they are restored by PD after the ttl. A zero ttl restores them at once.
+// It's supported since PD v4.0.8.
+func (c *HTTPClient) PauseScheduleConfig(ctx context.Context, cfg map[string]interface{}, ttl time.Duration) error {
+	start := time.Now()
+	prefix := fmt.Sprintf("%s?ttlSecond=%.0f", scheduleConfigPrefix, ttl.Seconds())
+	err := c.postJSON(ctx, prefix, cfg)
+	audit.Record(audit.ActionUpdateScheduleConfig, "schedule-config", fmt.Sprintf("%v, ttl %s", cfg, ttl), start, err)
+	return errors.Trace(err)
+}
+
+// ListSchedulers returns the names of the schedulers.
+func (c *HTTPClient) ListSchedulers(ctx context.Context) ([]string, error) {
+	schedulers := make([]string, 0)
+	if err := c.getJSON(ctx, schedulerPrefix, &schedulers); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return schedulers, nil
+}
+
+// PauseScheduler pauses the scheduler for the delay, after which PD resumes it.
+// A zero delay resumes it at once.
+func (c *HTTPClient) PauseScheduler(ctx context.Context, scheduler string, delay time.Duration) error {
+	start := time.Now()
+	prefix := fmt.Sprintf("%s/%s", schedulerPrefix, scheduler)
+	err := c.postJSON(ctx, prefix, pauseSchedulerBody{Delay: int64(delay.Seconds())})
+	if delay == 0 {
+		audit.Record(audit.ActionResumeScheduler, scheduler, "", start, err)
+	} else {
+		audit.Record(audit.ActionPauseScheduler, scheduler, fmt.Sprintf("delay %s", delay), start, err)
+	}
+	return errors.Trace(err)
+}
+
+// GetRegionCount returns the region count in the specified range.
+func (c *HTTPClient) GetRegionCount(ctx context.Context, startKey, endKey []byte) (int, error) {
+	stats, err := c.GetRangeStats(ctx, startKey, endKey)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return stats.Count, nil
+}
+
+// RangeStats is the approximate size of the regions in a key range reported
+// by PD.
+type RangeStats struct {
+	Count int `json:"count"`
+	// StorageSize is in MiB.
+	StorageSize int64 `json:"storage_size"`
+	StorageKeys int64 `json:"storage_keys"`
+}
+
+// GetRangeStats returns the stats of the regions in the specified range of
+// the raw keys, an empty end key means the max.
+func (c *HTTPClient) GetRangeStats(ctx context.Context, startKey, endKey []byte) (*RangeStats, error) {
+	// TiKV reports region start/end keys to PD in memcomparable-format.
+	var start, end string
+	start = url.QueryEscape(string(codec.EncodeBytes(nil, startKey)))
+	if len(endKey) != 0 { // Empty end key means the max.
+		end = url.QueryEscape(string(codec.EncodeBytes(nil, endKey)))
+	}
+	query := fmt.Sprintf("%s?start_key=%s&end_key=%s", regionCountPrefix, start, end)
+	stats := new(RangeStats)
+	if err := c.getJSON(ctx, query, stats); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return stats, nil
+}
+
+// RegionStats is the approximate size and the state of a region reported by
+// PD.
+type RegionStats struct {
+	ID uint64 `json:"id"`
+	// StartKey and EndKey are the hex of the keys in memcomparable-format.
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+	// ApproximateSize is in MiB.
+	ApproximateSize int64 `json:"approximate_size"`
+	ApproximateKeys int64 `json:"approximate_keys"`
+	// Peers and Leader are where the replicas of the region are placed.
+	Peers  []RegionPeer `json:"peers"`
+	Leader RegionPeer   `json:"leader"`
+	// Epoch, PendingPeers and DownPeers tell whether the region is changing
+	// or unhealthy.
+	Epoch        RegionEpoch  `json:"epoch"`
+	PendingPeers []RegionPeer `json:"pending_peers,omitempty"`
+	DownPeers    []DownPeer   `json:"down_peers,omitempty"`
+}
+
+// RegionEpoch is the version of a region reported by PD, which is increased
+// by the splits, the merges and the conf changes.
+type RegionEpoch struct {
+	ConfVer uint64 `json:"conf_ver"`
+	Version uint64 `json:"version"`
+}
+
+// DownPeer is a replica of a region not heartbeating for a while.
+type DownPeer struct {
+	Peer        RegionPeer `json:"peer"`
+	DownSeconds uint64     `json:"down_seconds"`
+}
+
+// RegionPeer is a replica of a region reported by PD.
+type RegionPeer struct {
+	ID        uint64 `json:"id"`
+	StoreID   uint64 `json:"store_id"`
+	IsLearner bool   `json:"is_learner"`
+}
+
+// Keys returns the keys of the region in memcomparable-format.
+func (r *RegionStats) Keys() (startKey, endKey []byte, err error) {
+	if startKey, err = hex.DecodeString(r.StartKey); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if endKey, err = hex.DecodeString(r.EndKey); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return startKey, endKey, nil
+}
+
+// ScanRegionStats returns the stats of at most limit regions from the start
+// key. The keys are in memcomparable-format, an empty end key means the max.
+func (c *HTTPClient) ScanRegionStats(ctx context.Context, startKey, endKey []byte, limit int) ([]RegionStats, error) {
+	query := fmt.Sprintf("%s?key=%s&end_key=%s&limit=%d",
+		regionsKeyPrefix, url.QueryEscape(string(startKey)), url.QueryEscape(string(endKey)), limit)
+	var regions struct {
+		Regions []RegionStats `json:"regions"`
+	}
+	if err := c.getJSON(ctx, query, &regions); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return regions.Regions, nil
+}
+
+// MergeRegions asks PD to merge the source region into the adjacent target
+// region.
+func (c *HTTPClient) MergeRegions(ctx context.Context, source, target uint64) error {
+	start := time.Now()
+	err := c.postJSON(ctx, operatorsPrefix, map[string]interface{}{
+		"name":             "merge-region",
+		"source_region_id": source,
+		"target_region_id": target,
+	})
+	audit.Record(audit.ActionMergeRegion, fmt.Sprintf("region %d", source), fmt.Sprintf("into region %d", target), start, err)
+	return errors.Trace(err)
+}
+
+// GetOperators returns the operators running in PD, each is described as a
+// string including the region it works on.
+func (c *HTTPClient) GetOperators(ctx context.Context) ([]string, error) {
+	var operators []string
+	if err := c.getJSON(ctx, operatorsPrefix, &operators); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return operators, nil
+}
+
+// GetStore returns the info of store with the specified id.
+func (c *HTTPClient) GetStore(ctx context.Context, storeID uint64) (*pdapi.StoreInfo, error) {
+	store := &pdapi.StoreInfo{}
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/%d", storePrefix, storeID), store); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return store, nil
+}
+
+// GetStores returns the info of all stores except the tombstone ones.
+func (c *HTTPClient) GetStores(ctx context.Context) (*pdapi.StoresInfo, error) {
+	stores := &pdapi.StoresInfo{}
+	if err := c.getJSON(ctx, storesPrefix, stores); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return stores, nil
+}
+
+// SetStoreLabel sets a label of the store.
+func (c *HTTPClient) SetStoreLabel(ctx context.Context, storeID uint64, key, value string) error {
+	start := time.Now()
+	prefix := fmt.Sprintf("%s/%d/label", storePrefix, storeID)
+	err := c.postJSON(ctx, prefix, map[string]string{key: value})
+	audit.Record(audit.ActionSetStoreLabel, fmt.Sprintf("store %d", storeID), fmt.Sprintf("%s=%s", key, value), start, err)
+	return errors.Trace(err)
+}
+
+// GetReplicateConfig returns the replication config.
+func (c *HTTPClient) GetReplicateConfig(ctx context.Context) (*pdconfig.ReplicationConfig, error) {
+	cfg := &pdconfig.ReplicationConfig{}
+	if err := c.getJSON(ctx, replicateConfigPrefix, cfg); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return cfg, nil
+}
+
+// GetPlacementRules returns the placement rules. It returns no rules if the
+// placement rules are disabled.
+func (c *HTTPClient) GetPlacementRules(ctx context.Context) ([]placement.Rule, error) {
+	var rules []placement.Rule
+	err := c.getJSON(ctx, placementRulesPrefix, &rules)
+	if statusCodeOf(err) == http.StatusPreconditionFailed {
+		return []placement.Rule{}, nil
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return rules, nil
+}
+
+// GetPlacementRule returns the placement rule.
+func (c *HTTPClient) GetPlacementRule(ctx context.Context, groupID, ruleID string) (placement.Rule, error) {
+	var rule placement.Rule
+	prefix := fmt.Sprintf("%s/%s/%s", placementRulePrefix, groupID, ruleID)
+	if err := c.getJSON(ctx, prefix, &rule); err != nil {
+		return rule, errors.Trace(err)
+	}
+	return rule, nil
+}
+
+// SetPlacementRule creates or updates the placement rule.
+func (c *HTTPClient) SetPlacementRule(ctx context.Context, rule placement.Rule) error {
+	start := time.Now()
+	err := c.postJSON(ctx, placementRulePrefix, rule)
+	audit.Record(audit.ActionSetPlacementRule, rule.GroupID+"/"+rule.ID, rule.String(), start, err)
+	return errors.Trace(err)
+}
+
+// DeletePlacementRule deletes the placement rule.
+func (c *HTTPClient) DeletePlacementRule(ctx context.Context, groupID, ruleID string) error {
+	start := time.Now()
+	prefix := fmt.Sprintf("%s/%s/%s", placementRulePrefix, groupID, ruleID)
+	_, err := c.request(ctx, http.MethodDelete, prefix, nil)
+	audit.Record(audit.ActionDeletePlacementRule, groupID+"/"+ruleID, "", start, err)
+	return errors.Trace(err)
+}
+
+// ResetTS resets the timestamp of PD to a bigger value. It does nothing if the
+// timestamp of PD is already bigger.
+func (c *HTTPClient) ResetTS(ctx context.Context, ts uint64) error {
+	payload := struct {
+		TSO string `json:"tso,omitempty"`
+	}{TSO: fmt.Sprintf("%d", ts)}
+	start := time.Now()
+	err := c.postJSON(ctx, resetTSPrefix, payload)
+	if statusCodeOf(err) == http.StatusForbidden {
+		audit.Record(audit.ActionResetTS, "pd", fmt.Sprintf("ts %d, already bigger", ts), start, nil)
+		return nil
+	}
+	audit.Record(audit.ActionResetTS, "pd", fmt.Sprintf("ts %d", ts), start, err)
+	return errors.Trace(err)
+}
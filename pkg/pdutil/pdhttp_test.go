@@ -0,0 +1,69 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package pdutil
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server/api"
+)
+
+type testPDHTTPClientSuite struct{}
+
+var _ = Suite(&testPDHTTPClientSuite{})
+
+func (s *testPDHTTPClientSuite) TestRequestRetry(c *C) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/pd/api/v1/stores":
+			err := json.NewEncoder(w).Encode(api.StoresInfo{Count: 1, Stores: []*api.StoreInfo{{}}})
+			c.Assert(err, IsNil)
+		case "/pd/api/v1/store/1/label":
+			body, err := io.ReadAll(r.Body)
+			c.Assert(err, IsNil)
+			c.Assert(string(body), Equals, `{"k":"v"}`)
+		case "/pd/api/v1/config/rules":
+			w.WriteHeader(http.StatusPreconditionFailed)
+		case "/pd/api/v1/admin/reset-ts":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	// the first address can't be reached.
+	cli := NewHTTPClient([]string{"127.0.0.1:1", ts.URL}, nil)
+	stores, err := cli.GetStores(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(stores.Stores, HasLen, 1)
+	c.Assert(cli.SetStoreLabel(ctx, 1, "k", "v"), IsNil)
+	rules, err := cli.GetPlacementRules(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 0)
+	c.Assert(cli.ResetTS(ctx, 1), IsNil)
+
+	// the client errors aren't retried.
+	paths = paths[:0]
+	_, err = cli.ListSchedulers(ctx)
+	c.Assert(err, ErrorMatches, ".*404.*")
+	c.Assert(paths, DeepEquals, []string{"GET /pd/api/v1/schedulers"})
+
+	// the requests reaching no PD are retried by rounds.
+	sent := 0
+	cli.send = func(context.Context, string, string, *http.Client, string, io.Reader) ([]byte, error) {
+		sent++
+		return nil, io.ErrUnexpectedEOF
+	}
+	_, err = cli.GetClusterVersion(ctx)
+	c.Assert(err, ErrorMatches, ".*unexpected EOF.*")
+	c.Assert(sent, Equals, 2*pdHTTPRetryTimes)
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package pdutil
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+const (
+	membersPrefix = "pd/api/v1/members"
+
+	// pdEndpointCooldown is how long a PD failing a request is tried after
+	// the others, so that the requests don't stall on its dial timeouts.
+	pdEndpointCooldown = time.Minute
+)
+
+// endpointPicker orders the PD addresses to try by pick-first with failover:
+// the address answering the last request is tried first, then the others in
+// the given order, and the addresses failed within the cooldown are tried
+// last. It's shared by the copies of the HTTP client.
+type endpointPicker struct {
+	mu        sync.Mutex
+	addrs     []string
+	preferred string
+	failedAt  map[string]time.Time
+	now       func() time.Time
+}
+
+func newEndpointPicker(addrs []string) *endpointPicker {
+	p := &endpointPicker{
+		addrs:    append([]string(nil), addrs...),
+		failedAt: make(map[string]time.Time),
+		now:      time.Now,
+	}
+	if len(addrs) > 0 {
+		p.preferred = addrs[0]
+	}
+	for _, addr := range addrs {
+		pdEndpointHealthGauge.WithLabelValues(addr).Set(1)
+	}
+	return p
+}
+
+// order returns the addresses in the order to try.
+func (p *endpointPicker) order() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	healthy := make([]string, 0, len(p.addrs))
+	var failed []string
+	for _, addr := range p.addrs {
+		if at, ok := p.failedAt[addr]; ok && now.Sub(at) < pdEndpointCooldown {
+			failed = append(failed, addr)
+			continue
+		}
+		if addr == p.preferred {
+			healthy = append([]string{addr}, healthy...)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+	return append(healthy, failed...)
+}
+
+// succeed records the address answered a request, which becomes the first to
+// try.
+func (p *endpointPicker) succeed(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.failedAt[addr]; ok {
+		delete(p.failedAt, addr)
+		log.Info("PD recovered", zap.String("pd", addr))
+	}
+	pdEndpointHealthGauge.WithLabelValues(addr).Set(1)
+	if p.preferred != addr {
+		log.Info("switch over PD", zap.String("from", p.preferred), zap.String("to", addr))
+		pdEndpointSwitchoverCounter.Inc()
+		p.preferred = addr
+	}
+}
+
+// fail records the address is unreachable.
+func (p *endpointPicker) fail(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failedAt[addr] = p.now()
+	pdEndpointHealthGauge.WithLabelValues(addr).Set(0)
+	pdEndpointFailureCounter.WithLabelValues(addr).Inc()
+}
+
+// add adds the addresses not known yet, after the known ones.
+func (p *endpointPicker) add(addrs []string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	known := make(map[string]struct{}, len(p.addrs))
+	for _, addr := range p.addrs {
+		known[addr] = struct{}{}
+	}
+	var added []string
+	for _, addr := range addrs {
+		if _, ok := known[addr]; ok {
+			continue
+		}
+		known[addr] = struct{}{}
+		p.addrs = append(p.addrs, addr)
+		added = append(added, addr)
+		pdEndpointHealthGauge.WithLabelValues(addr).Set(1)
+	}
+	return added
+}
+
+// DiscoverMembers adds the client URLs of the PD members to the addresses to
+// fail over to, so that the PDs not given by the users are tried as well when
+// the given ones are down.
+func (c *HTTPClient) DiscoverMembers(ctx context.Context) error {
+	var members struct {
+		Members []struct {
+			Name       string   `json:"name"`
+			ClientURLs []string `json:"client_urls"`
+		} `json:"members"`
+	}
+	if err := c.getJSON(ctx, membersPrefix, &members); err != nil {
+		return err
+	}
+	var urls []string
+	for _, member := range members.Members {
+		for _, u := range member.ClientURLs {
+			urls = append(urls, strings.TrimRight(u, "/"))
+		}
+	}
+	if c.picker == nil {
+		return nil
+	}
+	if added := c.picker.add(urls); len(added) > 0 {
+		log.Info("discovered PD members", zap.Strings("added", added))
+	}
+	return nil
+}
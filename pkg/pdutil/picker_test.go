@@ -0,0 +1,78 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package pdutil
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+type testEndpointPickerSuite struct{}
+
+var _ = Suite(&testEndpointPickerSuite{})
+
+func (s *testEndpointPickerSuite) TestOrder(c *C) {
+	now := time.Unix(1600000000, 0)
+	p := newEndpointPicker([]string{"http://pd1", "http://pd2", "http://pd3"})
+	p.now = func() time.Time { return now }
+	c.Assert(p.order(), DeepEquals, []string{"http://pd1", "http://pd2", "http://pd3"})
+
+	// the failed PD is tried last, and the PD answering is tried first.
+	p.fail("http://pd1")
+	c.Assert(p.order(), DeepEquals, []string{"http://pd2", "http://pd3", "http://pd1"})
+	p.succeed("http://pd3")
+	c.Assert(p.order(), DeepEquals, []string{"http://pd3", "http://pd2", "http://pd1"})
+
+	// the failed PD is tried in order again after the cooldown.
+	now = now.Add(pdEndpointCooldown)
+	c.Assert(p.order(), DeepEquals, []string{"http://pd3", "http://pd1", "http://pd2"})
+
+	c.Assert(p.add([]string{"http://pd2", "http://pd4"}), DeepEquals, []string{"http://pd4"})
+	c.Assert(p.order(), DeepEquals, []string{"http://pd3", "http://pd1", "http://pd2", "http://pd4"})
+}
+
+func (s *testEndpointPickerSuite) TestFailover(c *C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pd/api/v1/members":
+			err := json.NewEncoder(w).Encode(map[string]interface{}{
+				"members": []map[string]interface{}{
+					{"name": "pd-0", "client_urls": []string{"http://127.0.0.1:1"}},
+					{"name": "pd-1", "client_urls": []string{"http://127.0.0.1:3/"}},
+				},
+			})
+			c.Assert(err, IsNil)
+		default:
+			_, err := w.Write([]byte(`"5.2.0"`))
+			c.Assert(err, IsNil)
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	cli := NewHTTPClient([]string{"127.0.0.1:1", ts.URL}, nil)
+	var tried []string
+	send := cli.send
+	cli.send = func(ctx context.Context, addr, prefix string, hc *http.Client, method string, body io.Reader) ([]byte, error) {
+		tried = append(tried, addr)
+		return send(ctx, addr, prefix, hc, method, body)
+	}
+	_, err := cli.GetClusterVersion(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(tried, DeepEquals, []string{"http://127.0.0.1:1", ts.URL})
+
+	// the PD down isn't dialed first any more.
+	tried = tried[:0]
+	_, err = cli.GetClusterVersion(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(tried, DeepEquals, []string{ts.URL})
+
+	c.Assert(cli.DiscoverMembers(ctx), IsNil)
+	c.Assert(cli.picker.order(), DeepEquals, []string{ts.URL, "http://127.0.0.1:3", "http://127.0.0.1:1"})
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/meta/autoid"
+	"github.com/pingcap/tidb/tablecodec"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// autoIDRebaseMargin is the gap left above the max restored ID, for the IDs
+// allocated but not written yet when the data was backed up.
+const autoIDRebaseMargin = 1000
+
+// RebaseAutoIDs rebases the auto increment and auto random allocators of the
+// restored tables above the max IDs found in their restored data, so the
+// first writes after restore don't meet duplicated keys.
+//
+// The max ID of a table is taken across all its partitions, since they share
+// the allocator of the table. The rebase is done by DDL, which only moves the
+// allocators forward and is seen by all TiDB instances, and then verified by
+// reading the allocators back. Only the IDs used as the row handles can be
+// found in the data, the other auto increment columns keep the backed up
+// allocator base.
+func (rc *Client) RebaseAutoIDs(ctx context.Context, dom *domain.Domain, tables []*metautil.Table) error {
+	// the restored data is read at a snapshot after the restore.
+	ts, err := rc.GetTS(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, table := range tables {
+		if table.Info.IsView() || table.Info.IsSequence() {
+			continue
+		}
+		newInfo, err := rc.GetTableSchema(dom, table.DB.Name, table.Info.Name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = rc.rebaseTableAutoID(ctx, dom.Store(), table.DB, newInfo, ts); err != nil {
+			return errors.Annotatef(err, "failed to rebase the auto ID of %s",
+				utils.EncloseDBAndTable(table.DB.Name.O, table.Info.Name.O))
+		}
+	}
+	return nil
+}
+
+func (rc *Client) rebaseTableAutoID(
+	ctx context.Context,
+	store kv.Storage,
+	db *model.DBInfo,
+	info *model.TableInfo,
+	ts uint64,
+) error {
+	isAutoRandom := info.PKIsHandle && info.ContainsAutoRandomBits()
+	if info.IsCommonHandle || (!isAutoRandom && !utils.NeedAutoID(info)) {
+		return nil
+	}
+	maxID, err := maxRestoredAutoID(store, info, isAutoRandom, ts)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if maxID <= 0 {
+		return nil
+	}
+	base := maxID + autoIDRebaseMargin
+
+	allocType := autoid.RowIDAllocType
+	if isAutoRandom {
+		allocType = autoid.AutoRandomType
+	}
+	alloc := autoid.NewAllocator(store, db.ID, false, allocType)
+	return utils.WithRetry(ctx, func() error {
+		if err := rc.db.RebaseAutoID(ctx, db.Name, info.Name, isAutoRandom, base); err != nil {
+			return errors.Trace(err)
+		}
+		next, err := alloc.NextGlobalAutoID(info.ID)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if next <= maxID {
+			return errors.Annotatef(berrors.ErrRestoreAutoIDRebase,
+				"the next ID %d is not above the max restored ID %d", next, maxID)
+		}
+		log.Info("rebase auto ID",
+			zap.Stringer("db", db.Name),
+			zap.Stringer("table", info.Name),
+			zap.Bool("autoRandom", isAutoRandom),
+			zap.Int64("maxID", maxID),
+			zap.Int64("nextID", next))
+		return nil
+	}, newRebaseAutoIDBackoffer())
+}
+
+// maxRestoredAutoID returns the max auto ID used as the row handle of the
+// table at the snapshot of ts. For auto random, it's the max of the increment
+// bits in every shard.
+func maxRestoredAutoID(store kv.Storage, info *model.TableInfo, isAutoRandom bool, ts uint64) (int64, error) {
+	physicalIDs := []int64{info.ID}
+	if info.Partition != nil {
+		physicalIDs = physicalIDs[:0]
+		for _, def := range info.Partition.Definitions {
+			physicalIDs = append(physicalIDs, def.ID)
+		}
+	}
+
+	// without auto random, the whole handle space is a single shard.
+	shards, incrementBits := int64(1), uint64(63)
+	if isAutoRandom {
+		if pkCol := info.GetPkColInfo(); pkCol != nil && mysql.HasUnsignedFlag(pkCol.Flag) {
+			// the keys are ordered by the signed handles, so the shards of
+			// the unsigned handles can't be scanned in order.
+			log.Warn("skip rebasing the auto random ID of the unsigned primary key",
+				zap.Stringer("table", info.Name))
+			return 0, nil
+		}
+		incrementBits = 63 - info.AutoRandomBits
+		shards = 1 << info.AutoRandomBits
+	}
+
+	snapshot := store.GetSnapshot(kv.NewVersion(ts))
+	var maxID int64
+	for _, id := range physicalIDs {
+		for shard := int64(0); shard < shards; shard++ {
+			lower := shard << incrementBits
+			upperKey := tablecodec.GenTableRecordPrefix(id).PrefixNext()
+			if shard+1 < shards {
+				upperKey = tablecodec.EncodeRowKeyWithHandle(id, kv.IntHandle((shard+1)<<incrementBits))
+			}
+			handle, err := lastHandleBefore(snapshot, id, upperKey)
+			if err != nil {
+				return 0, errors.Trace(err)
+			}
+			if handle < lower {
+				continue
+			}
+			if autoID := handle - lower; autoID > maxID {
+				maxID = autoID
+			}
+		}
+	}
+	return maxID, nil
+}
+
+// lastHandleBefore returns the handle of the last record before the key, or -1
+// if there is no such record in the table.
+func lastHandleBefore(snapshot kv.Snapshot, physicalID int64, key kv.Key) (int64, error) {
+	iter, err := snapshot.IterReverse(key)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer iter.Close()
+	if !iter.Valid() || !iter.Key().HasPrefix(tablecodec.GenTableRecordPrefix(physicalID)) {
+		return -1, nil
+	}
+	_, handle, err := tablecodec.DecodeRecordKey(iter.Key())
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return handle.IntValue(), nil
+}
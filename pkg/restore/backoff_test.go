@@ -8,7 +8,6 @@ import (
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/util/testleak"
-	"go.uber.org/multierr"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -72,7 +71,7 @@ func (s *testBackofferSuite) TestBackoffWithFatalError(c *C) {
 		return nil
 	}, backoffer)
 	c.Assert(counter, Equals, 4)
-	c.Assert(multierr.Errors(err), DeepEquals, []error{
+	c.Assert(utils.RetryErrors(err), DeepEquals, []error{
 		gRPCError,
 		berrors.ErrKVEpochNotMatch,
 		berrors.ErrKVDownloadFailed,
@@ -89,7 +88,7 @@ func (s *testBackofferSuite) TestBackoffWithFatalRawGRPCError(c *C) {
 		return canceledError // nolint:wrapcheck
 	}, backoffer)
 	c.Assert(counter, Equals, 1)
-	c.Assert(multierr.Errors(err), DeepEquals, []error{
+	c.Assert(utils.RetryErrors(err), DeepEquals, []error{
 		canceledError,
 	})
 }
@@ -102,7 +101,7 @@ func (s *testBackofferSuite) TestBackoffWithRetryableError(c *C) {
 		return berrors.ErrKVEpochNotMatch
 	}, backoffer)
 	c.Assert(counter, Equals, 10)
-	c.Assert(multierr.Errors(err), DeepEquals, []error{
+	c.Assert(utils.RetryErrors(err), DeepEquals, []error{
 		berrors.ErrKVEpochNotMatch,
 		berrors.ErrKVEpochNotMatch,
 		berrors.ErrKVEpochNotMatch,
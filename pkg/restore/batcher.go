@@ -340,7 +340,19 @@ func (b *Batcher) sendIfFull() {
 }
 
 // Add adds a task to the Batcher.
+// The table without any range is restored once created, so it's output at
+// once rather than going through the placement rules, the split and the
+// ingest of a batch.
 func (b *Batcher) Add(tbs TableWithRange) {
+	if len(tbs.Range) == 0 {
+		log.Debug("table is empty, skip the batch",
+			zap.Stringer("db", tbs.OldTable.DB.Name),
+			zap.Stringer("table", tbs.Table.Name),
+			zap.Int64("new id", tbs.Table.ID),
+		)
+		b.outCh <- tbs.CreatedTable
+		return
+	}
 	b.cachedTablesMu.Lock()
 	log.Debug("adding table to batch",
 		zap.Stringer("db", tbs.OldTable.DB.Name),
@@ -389,3 +389,42 @@ func (*testBatcherSuite) TestBatcherLen(c *C) {
 	default:
 	}
 }
+
+func (*testBatcherSuite) TestEmptyTables(c *C) {
+	ctx := context.Background()
+	errCh := make(chan error, 8)
+	sender := newDrySender()
+	manager := newMockManager()
+	batcher, outCh := restore.NewBatcher(ctx, sender, manager, errCh)
+	batcher.SetThreshold(2)
+
+	emptyTable := fakeTableWithRange(1, nil)
+	simpleTable := fakeTableWithRange(2, []rtree.Range{fakeRange("caa", "cab")})
+
+	batcher.Add(emptyTable)
+	c.Assert(batcher.Len(), Equals, 0)
+	// the empty table is output at once, without any batch.
+	select {
+	case tbl := <-outCh:
+		c.Assert(tbl.Table.ID, Equals, int64(1))
+	case <-time.After(time.Second):
+		c.Fatal("the empty table isn't output")
+	}
+	c.Assert(sender.BatchCount(), Equals, 0)
+	c.Assert(manager.Has(emptyTable), IsFalse)
+
+	batcher.Add(simpleTable)
+	batcher.Close()
+	c.Assert(sender.Ranges(), DeepEquals, simpleTable.Range)
+	ids := make([]int64, 0, 1)
+	for tbl := range outCh {
+		ids = append(ids, tbl.Table.ID)
+	}
+	c.Assert(ids, DeepEquals, []int64{2})
+
+	select {
+	case err := <-errCh:
+		c.Fatal(errors.Trace(err))
+	default:
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pingcap/br/pkg/metautil"
@@ -20,7 +21,6 @@ import (
 	"github.com/pingcap/errors"
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/kvproto/pkg/import_sstpb"
-	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/domain"
@@ -54,10 +54,15 @@ import (
 // checksum tasks.
 const defaultChecksumConcurrency = 64
 
+// defaultDDLBatchSize is the max number of the tables created in one
+// round-trip, if the session supports it.
+const defaultDDLBatchSize = 128
+
 // Client sends requests to restore files.
 type Client struct {
 	pdClient      pd.Client
 	toolClient    SplitClient
+	pdHTTPClient  *pdutil.HTTPClient
 	fileImporter  FileImporter
 	workerPool    *utils.WorkerPool
 	tlsConf       *tls.Config
@@ -82,6 +87,14 @@ type Client struct {
 	hasSpeedLimited bool
 
 	restoreStores []uint64
+	// stagingReplicas is the number of the replicas the tables are restored
+	// with, zero disables it. The other replicas are added after the restore.
+	stagingReplicas int
+	// stagingTables are the physical tables whose staging rules are set.
+	stagingMu     sync.Mutex
+	stagingTables map[int64]struct{}
+	// tableHooks are run on each table after it's restored, see SetTableHooks.
+	tableHooks []TableHookEntry
 
 	storage            storage.ExternalStorage
 	backend            *backuppb.StorageBackend
@@ -93,6 +106,24 @@ type Client struct {
 	// and restore stats with #dump.LoadStatsFromJSON
 	statsHandler *handle.Handle
 	dom          *domain.Domain
+
+	// prebuiltRewrites decides the rewrites of the tables in it if not nil.
+	prebuiltRewrites *RewriteMap
+	// rewrites records the rewrites of the restored tables.
+	rewrites *RewriteMap
+
+	// ingestMergeSizeBytes and ingestMergeKeyCount are the thresholds of
+	// coalescing the adjacent small files into an ingest, zero disables it.
+	ingestMergeSizeBytes uint64
+	ingestMergeKeyCount  uint64
+
+	// sstCache caches the files of the retried downloads, nil if disabled.
+	sstCache *SSTCache
+
+	// parallelDownloadSize and parallelDownloadParts decide the files
+	// downloaded into their regions in parallel, see FileImporter.
+	parallelDownloadSize  uint64
+	parallelDownloadParts uint
 }
 
 // NewRestoreClient returns a new RestoreClient.
@@ -127,6 +158,7 @@ func NewRestoreClient(
 		switchCh:      make(chan struct{}),
 		dom:           dom,
 		statsHandler:  statsHandle,
+		rewrites:      NewRewriteMap(),
 	}, nil
 }
 
@@ -196,9 +228,13 @@ func (rc *Client) InitBackupMeta(c context.Context, backupMeta *backuppb.BackupM
 	rc.backupMeta = backupMeta
 	log.Info("load backupmeta", zap.Int("databases", len(rc.databases)), zap.Int("jobs", len(rc.ddlJobs)))
 
-	metaClient := NewSplitClient(rc.pdClient, rc.tlsConf)
+	metaClient := NewSplitClientWithHTTP(rc.pdClient, rc.tlsConf, rc.pdHTTPClient)
 	importCli := NewImportClient(metaClient, rc.tlsConf, rc.keepaliveConf)
 	rc.fileImporter = NewFileImporter(metaClient, importCli, backend, rc.backupMeta.IsRawKv, rc.rateLimit)
+	if rc.sstCache != nil {
+		rc.fileImporter.SetSSTCache(rc.sstCache, externalStorage, backupMeta.GetEndVersion())
+	}
+	rc.fileImporter.SetParallelDownload(rc.parallelDownloadSize, rc.parallelDownloadParts)
 	return rc.fileImporter.CheckMultiIngestSupport(c, rc.pdClient)
 }
 
@@ -268,6 +304,37 @@ func (rc *Client) SetConcurrency(c uint) {
 	rc.workerPool = utils.NewWorkerPool(c, "file")
 }
 
+// SetIngestMergeThreshold sets the thresholds of coalescing the adjacent small
+// files into a single download and ingest, which reduces the requests to TiKV
+// when the backup contains lots of small files. It takes effect only if all
+// the TiKVs support multi-ingest.
+func (rc *Client) SetIngestMergeThreshold(sizeBytes, keyCount uint64) {
+	rc.ingestMergeSizeBytes = sizeBytes
+	rc.ingestMergeKeyCount = keyCount
+}
+
+// SetSSTCache sets the cache the retried downloads read the files from, it
+// must be called before InitBackupMeta.
+func (rc *Client) SetSSTCache(cache *SSTCache) {
+	rc.sstCache = cache
+}
+
+// SetPDHTTPClient sets the client of the HTTP API of PD requested by the
+// placement and the splits of the restore, which fails over to the other PDs
+// when the leader is unavailable. It must be called before InitBackupMeta.
+func (rc *Client) SetPDHTTPClient(cli *pdutil.HTTPClient) {
+	rc.pdHTTPClient = cli
+	rc.toolClient = NewSplitClientWithHTTP(rc.pdClient, rc.tlsConf, cli)
+}
+
+// SetParallelDownload sets the files larger than size to be downloaded into
+// their regions in parallel, up to parts at a time. A size of 0 disables it.
+func (rc *Client) SetParallelDownload(size uint64, parts uint) {
+	rc.parallelDownloadSize = size
+	rc.parallelDownloadParts = parts
+	rc.fileImporter.SetParallelDownload(size, parts)
+}
+
 // EnableOnline sets the mode of restore to online.
 func (rc *Client) EnableOnline() {
 	rc.isOnline = true
@@ -289,29 +356,16 @@ func (rc *Client) GetTS(ctx context.Context) (uint64, error) {
 }
 
 // ResetTS resets the timestamp of PD to a bigger value.
-func (rc *Client) ResetTS(ctx context.Context, pdAddrs []string) error {
+func (rc *Client) ResetTS(ctx context.Context, pdCli *pdutil.HTTPClient) error {
 	restoreTS := rc.backupMeta.GetEndVersion()
 	log.Info("reset pd timestamp", zap.Uint64("ts", restoreTS))
-	i := 0
-	return utils.WithRetry(ctx, func() error {
-		idx := i % len(pdAddrs)
-		i++
-		return pdutil.ResetTS(ctx, pdAddrs[idx], restoreTS, rc.tlsConf)
-	}, newPDReqBackoffer())
+	return errors.Trace(pdCli.ResetTS(ctx, restoreTS))
 }
 
 // GetPlacementRules return the current placement rules.
-func (rc *Client) GetPlacementRules(ctx context.Context, pdAddrs []string) ([]placement.Rule, error) {
-	var placementRules []placement.Rule
-	i := 0
-	errRetry := utils.WithRetry(ctx, func() error {
-		var err error
-		idx := i % len(pdAddrs)
-		i++
-		placementRules, err = pdutil.GetPlacementRules(ctx, pdAddrs[idx], rc.tlsConf)
-		return errors.Trace(err)
-	}, newPDReqBackoffer())
-	return placementRules, errors.Trace(errRetry)
+func (rc *Client) GetPlacementRules(ctx context.Context, pdCli *pdutil.HTTPClient) ([]placement.Rule, error) {
+	placementRules, err := pdCli.GetPlacementRules(ctx)
+	return placementRules, errors.Trace(err)
 }
 
 // GetDatabases returns all databases.
@@ -392,21 +446,23 @@ func (rc *Client) CreateTables(
 	return rewriteRules, newTables, nil
 }
 
-func (rc *Client) createTable(
-	ctx context.Context,
-	db *DB,
+// createTables creates the tables in one round-trip if the DB supports it.
+func (rc *Client) createTables(ctx context.Context, db *DB, tables []*metautil.Table) error {
+	if rc.IsSkipCreateSQL() {
+		for _, table := range tables {
+			log.Info("skip create table and alter autoIncID", zap.Stringer("table", table.Info.Name))
+		}
+		return nil
+	}
+	return errors.Trace(db.CreateTables(ctx, tables))
+}
+
+// createdTable returns the table created with its rewrite rules.
+func (rc *Client) createdTable(
 	dom *domain.Domain,
 	table *metautil.Table,
 	newTS uint64,
 ) (CreatedTable, error) {
-	if rc.IsSkipCreateSQL() {
-		log.Info("skip create table and alter autoIncID", zap.Stringer("table", table.Info.Name))
-	} else {
-		err := db.CreateTable(ctx, table)
-		if err != nil {
-			return CreatedTable{}, errors.Trace(err)
-		}
-	}
 	newTableInfo, err := rc.GetTableSchema(dom, table.DB.Name, table.Info.Name)
 	if err != nil {
 		return CreatedTable{}, errors.Trace(err)
@@ -418,7 +474,27 @@ func (rc *Client) createTable(
 			table.Info.IsCommonHandle,
 			newTableInfo.IsCommonHandle)
 	}
-	rules := GetRewriteRules(newTableInfo, table.Info, newTS)
+	if rc.IsIncremental() {
+		// the table is created by the replayed ddl jobs, make sure its schema
+		// is still compatible with the incremental data.
+		if err = ValidateTableRewrite(newTableInfo, table.Info); err != nil {
+			return CreatedTable{}, errors.Trace(err)
+		}
+	}
+	rewrite := NewTableRewrite(table.DB.Name.O, newTableInfo, table.Info)
+	if rc.prebuiltRewrites != nil {
+		if prebuilt, ok := rc.prebuiltRewrites.Get(table.Info.ID); ok {
+			if err = prebuilt.Validate(newTableInfo, table.Info); err != nil {
+				return CreatedTable{}, errors.Trace(err)
+			}
+			rewrite = prebuilt
+		}
+	}
+	rules := rewrite.RewriteRules(newTS)
+	if err = ValidateRewriteRules(rules, newTableInfo, table.Info); err != nil {
+		return CreatedTable{}, errors.Trace(err)
+	}
+	rc.rewrites.Add(rewrite)
 	et := CreatedTable{
 		RewriteRule: rules,
 		Table:       newTableInfo,
@@ -448,29 +524,34 @@ func (rc *Client) GoCreateTables(
 	}
 	outCh := make(chan CreatedTable, len(tables))
 	rater := logutil.TraceRateOver(logutil.MetricTableCreatedCounter)
-	createOneTable := func(c context.Context, db *DB, t *metautil.Table) error {
+	createTableBatch := func(c context.Context, db *DB, batch []*metautil.Table) error {
 		select {
 		case <-c.Done():
 			return c.Err()
 		default:
 		}
-		rt, err := rc.createTable(c, db, dom, t, newTS)
-		if err != nil {
-			log.Error("create table failed",
-				zap.Error(err),
-				zap.Stringer("db", t.DB.Name),
-				zap.Stringer("table", t.Info.Name))
+		if err := rc.createTables(c, db, batch); err != nil {
 			return errors.Trace(err)
 		}
-		log.Debug("table created and send to next",
-			zap.Int("output chan size", len(outCh)),
-			zap.Stringer("table", t.Info.Name),
-			zap.Stringer("database", t.DB.Name))
-		outCh <- rt
-		rater.Inc()
-		rater.L().Info("table created",
-			zap.Stringer("table", t.Info.Name),
-			zap.Stringer("database", t.DB.Name))
+		for _, t := range batch {
+			rt, err := rc.createdTable(dom, t, newTS)
+			if err != nil {
+				log.Error("create table failed",
+					zap.Error(err),
+					zap.Stringer("db", t.DB.Name),
+					zap.Stringer("table", t.Info.Name))
+				return errors.Trace(err)
+			}
+			log.Debug("table created and send to next",
+				zap.Int("output chan size", len(outCh)),
+				zap.Stringer("table", t.Info.Name),
+				zap.Stringer("database", t.DB.Name))
+			outCh <- rt
+			rater.Inc()
+			rater.L().Info("table created",
+				zap.Stringer("table", t.Info.Name),
+				zap.Stringer("database", t.DB.Name))
+		}
 		return nil
 	}
 	go func() {
@@ -478,9 +559,11 @@ func (rc *Client) GoCreateTables(
 		defer log.Debug("all tables are created")
 		var err error
 		if len(dbPool) > 0 {
-			err = rc.createTablesWithDBPool(ctx, createOneTable, tables, dbPool)
+			batches := splitTableBatches(tables, ddlBatchSizeOf(dbPool[0]))
+			err = rc.createTablesWithDBPool(ctx, createTableBatch, batches, dbPool)
 		} else {
-			err = rc.createTablesWithSoleDB(ctx, createOneTable, tables)
+			batches := splitTableBatches(tables, ddlBatchSizeOf(rc.db))
+			err = rc.createTablesWithSoleDB(ctx, createTableBatch, batches)
 		}
 		if err != nil {
 			errCh <- err
@@ -489,11 +572,32 @@ func (rc *Client) GoCreateTables(
 	return outCh
 }
 
+// ddlBatchSizeOf returns how many tables the DB creates in one round-trip.
+func ddlBatchSizeOf(db *DB) int {
+	if db != nil && db.SupportBatchCreateTable() {
+		return defaultDDLBatchSize
+	}
+	return 1
+}
+
+// splitTableBatches splits the tables into the batches of up to size tables.
+func splitTableBatches(tables []*metautil.Table, size int) [][]*metautil.Table {
+	batches := make([][]*metautil.Table, 0, (len(tables)+size-1)/size)
+	for len(tables) > size {
+		batches = append(batches, tables[:size])
+		tables = tables[size:]
+	}
+	if len(tables) > 0 {
+		batches = append(batches, tables)
+	}
+	return batches
+}
+
 func (rc *Client) createTablesWithSoleDB(ctx context.Context,
-	createOneTable func(ctx context.Context, db *DB, t *metautil.Table) error,
-	tables []*metautil.Table) error {
-	for _, t := range tables {
-		if err := createOneTable(ctx, rc.db, t); err != nil {
+	createTableBatch func(ctx context.Context, db *DB, batch []*metautil.Table) error,
+	batches [][]*metautil.Table) error {
+	for _, batch := range batches {
+		if err := createTableBatch(ctx, rc.db, batch); err != nil {
 			return errors.Trace(err)
 		}
 	}
@@ -501,15 +605,15 @@ func (rc *Client) createTablesWithSoleDB(ctx context.Context,
 }
 
 func (rc *Client) createTablesWithDBPool(ctx context.Context,
-	createOneTable func(ctx context.Context, db *DB, t *metautil.Table) error,
-	tables []*metautil.Table, dbPool []*DB) error {
+	createTableBatch func(ctx context.Context, db *DB, batch []*metautil.Table) error,
+	batches [][]*metautil.Table, dbPool []*DB) error {
 	eg, ectx := errgroup.WithContext(ctx)
 	workers := utils.NewWorkerPool(uint(len(dbPool)), "DDL workers")
-	for _, t := range tables {
-		table := t
+	for _, b := range batches {
+		batch := b
 		workers.ApplyWithIDInErrorGroup(eg, func(id uint64) error {
 			db := dbPool[id%uint64(len(dbPool))]
-			return createOneTable(ectx, db, table)
+			return createTableBatch(ectx, db, batch)
 		})
 	}
 	return eg.Wait()
@@ -517,17 +621,26 @@ func (rc *Client) createTablesWithDBPool(ctx context.Context,
 
 // ExecDDLs executes the queries of the ddl jobs.
 func (rc *Client) ExecDDLs(ctx context.Context, ddlJobs []*model.Job) error {
-	// Sort the ddl jobs by schema version in ascending order.
+	// Sort the ddl jobs by schema version in ascending order, the jobs with
+	// the same schema version are sorted by their IDs.
 	sort.Slice(ddlJobs, func(i, j int) bool {
-		return ddlJobs[i].BinlogInfo.SchemaVersion < ddlJobs[j].BinlogInfo.SchemaVersion
+		if ddlJobs[i].BinlogInfo.SchemaVersion != ddlJobs[j].BinlogInfo.SchemaVersion {
+			return ddlJobs[i].BinlogInfo.SchemaVersion < ddlJobs[j].BinlogInfo.SchemaVersion
+		}
+		return ddlJobs[i].ID < ddlJobs[j].ID
 	})
 
-	for _, job := range ddlJobs {
+	for i, job := range ddlJobs {
+		// a job may be filtered in by both its database and its table.
+		if i > 0 && ddlJobs[i-1].ID == job.ID {
+			continue
+		}
 		err := rc.db.ExecDDL(ctx, job)
 		if err != nil {
-			return errors.Trace(err)
+			return errors.Annotatef(err, "failed to replay ddl job %d: %s", job.ID, job.Query)
 		}
 		log.Info("execute ddl query",
+			zap.Int64("jobID", job.ID),
 			zap.String("db", job.SchemaName),
 			zap.String("query", job.Query),
 			zap.Int64("historySchemaVersion", job.BinlogInfo.SchemaVersion))
@@ -584,6 +697,48 @@ func drainFilesByRange(files []*backuppb.File, supportMulti bool) ([]*backuppb.F
 	return files[:idx], files[idx:]
 }
 
+// drainFilesByThreshold drains the files of the adjacent ranges as long as
+// they are under the thresholds and rewritten by the same rule.
+func drainFilesByThreshold(
+	files []*backuppb.File, sizeBytes, keyCount uint64,
+) ([]*backuppb.File, []*backuppb.File) {
+	unit, left := drainFilesByRange(files, true)
+	if len(unit) == 0 {
+		return nil, nil
+	}
+	size, keys := filesSizeAndKeys(unit)
+	for len(left) != 0 {
+		next, rest := drainFilesByRange(left, true)
+		nextSize, nextKeys := filesSizeAndKeys(next)
+		if size+nextSize > sizeBytes || keys+nextKeys > keyCount ||
+			!isSameRewritePattern(unit[0].GetStartKey(), next[0].GetStartKey()) {
+			break
+		}
+		// the drained files are always a prefix of the files.
+		unit = files[:len(unit)+len(next)]
+		left = rest
+		size += nextSize
+		keys += nextKeys
+	}
+	return unit, left
+}
+
+func filesSizeAndKeys(files []*backuppb.File) (size, keys uint64) {
+	for _, f := range files {
+		size += f.GetTotalBytes()
+		keys += f.GetTotalKvs()
+	}
+	return
+}
+
+func filesTotalBytes(files []*backuppb.File) int64 {
+	var total uint64
+	for _, f := range files {
+		total += f.TotalBytes
+	}
+	return int64(total)
+}
+
 // RestoreFiles tries to restore the files.
 func (rc *Client) RestoreFiles(
 	ctx context.Context,
@@ -614,9 +769,15 @@ func (rc *Client) RestoreFiles(
 		return errors.Trace(err)
 	}
 
+	drainFiles := func(files []*backuppb.File) ([]*backuppb.File, []*backuppb.File) {
+		if rc.fileImporter.supportMultiIngest && rc.ingestMergeSizeBytes > 0 && rc.ingestMergeKeyCount > 0 {
+			return drainFilesByThreshold(files, rc.ingestMergeSizeBytes, rc.ingestMergeKeyCount)
+		}
+		return drainFilesByRange(files, rc.fileImporter.supportMultiIngest)
+	}
 	var rangeFiles []*backuppb.File
 	var leftFiles []*backuppb.File
-	for rangeFiles, leftFiles = drainFilesByRange(files, rc.fileImporter.supportMultiIngest); len(rangeFiles) != 0; rangeFiles, leftFiles = drainFilesByRange(leftFiles, rc.fileImporter.supportMultiIngest) {
+	for rangeFiles, leftFiles = drainFiles(files); len(rangeFiles) != 0; rangeFiles, leftFiles = drainFiles(leftFiles) {
 		filesReplica := rangeFiles
 		rc.workerPool.ApplyOnErrorGroup(eg,
 			func() error {
@@ -626,7 +787,11 @@ func (rc *Client) RestoreFiles(
 						zap.Duration("take", time.Since(fileStart)))
 					updateCh.Inc()
 				}()
-				return rc.fileImporter.Import(ectx, filesReplica, rewriteRules)
+				if err := rc.fileImporter.Import(ectx, filesReplica, rewriteRules); err != nil {
+					return err
+				}
+				glue.AddProgressBytes(updateCh, filesTotalBytes(filesReplica))
+				return nil
 			})
 	}
 
@@ -653,9 +818,7 @@ func (rc *Client) RestoreRaw(
 			logutil.Key("endKey", endKey),
 			zap.Duration("take", elapsed))
 	}()
-	errCh := make(chan error, len(files))
 	eg, ectx := errgroup.WithContext(ctx)
-	defer close(errCh)
 
 	err := rc.fileImporter.SetRawRange(startKey, endKey)
 	if err != nil {
@@ -667,7 +830,11 @@ func (rc *Client) RestoreRaw(
 		rc.workerPool.ApplyOnErrorGroup(eg,
 			func() error {
 				defer updateCh.Inc()
-				return rc.fileImporter.Import(ectx, []*backuppb.File{fileReplica}, EmptyRewriteRule())
+				if err := rc.fileImporter.Import(ectx, []*backuppb.File{fileReplica}, EmptyRewriteRule()); err != nil {
+					return err
+				}
+				glue.AddProgressBytes(updateCh, int64(fileReplica.TotalBytes))
+				return nil
 			})
 	}
 	if err := eg.Wait(); err != nil {
@@ -795,6 +962,7 @@ func (rc *Client) GoValidateChecksum(
 			// if we use ectx here, maybe canceled will mask real error.
 			case <-ctx.Done():
 				errCh <- ctx.Err()
+				return
 			case tbl, ok := <-tableStream:
 				if !ok {
 					return
@@ -810,6 +978,9 @@ func (rc *Client) GoValidateChecksum(
 					if err != nil {
 						return errors.Trace(err)
 					}
+					if err = rc.RunTableHooks(ectx, tbl); err != nil {
+						return errors.Trace(err)
+					}
 					updateCh.Inc()
 					return nil
 				})
@@ -827,6 +998,8 @@ func (rc *Client) execChecksum(ctx context.Context, tbl CreatedTable, kvClient k
 
 	if tbl.OldTable.NoChecksum() {
 		logger.Warn("table has no checksum, skipping checksum")
+		// e.g. the tables of the schema-only backup still have the stats.
+		rc.loadStats(tbl, logger)
 		return nil
 	}
 
@@ -868,18 +1041,24 @@ func (rc *Client) execChecksum(ctx context.Context, tbl CreatedTable, kvClient k
 		)
 		return errors.Annotate(berrors.ErrRestoreChecksumMismatch, "failed to validate checksum")
 	}
-	if table.Stats != nil {
-		logger.Info("start loads analyze after validate checksum",
-			zap.Int64("old id", tbl.OldTable.Info.ID),
-			zap.Int64("new id", tbl.Table.ID),
-		)
-		if err := rc.statsHandler.LoadStatsFromJSON(rc.dom.InfoSchema(), table.Stats); err != nil {
-			logger.Error("analyze table failed", zap.Any("table", table.Stats), zap.Error(err))
-		}
-	}
+	rc.loadStats(tbl, logger)
 	return nil
 }
 
+func (rc *Client) loadStats(tbl CreatedTable, logger *zap.Logger) {
+	table := tbl.OldTable
+	if table.Stats == nil {
+		return
+	}
+	logger.Info("start loads analyze after validate checksum",
+		zap.Int64("old id", tbl.OldTable.Info.ID),
+		zap.Int64("new id", tbl.Table.ID),
+	)
+	if err := rc.statsHandler.LoadStatsFromJSON(rc.dom.InfoSchema(), table.Stats); err != nil {
+		logger.Error("analyze table failed", zap.Any("table", table.Stats), zap.Error(err))
+	}
+}
+
 const (
 	restoreLabelKey   = "exclusive"
 	restoreLabelValue = "restore"
@@ -896,20 +1075,15 @@ func (rc *Client) LoadRestoreStores(ctx context.Context) error {
 		ctx = opentracing.ContextWithSpan(ctx, span1)
 	}
 
-	stores, err := rc.pdClient.GetAllStores(ctx)
+	// the draining stores are excluded, or the restored regions would be
+	// placed on them.
+	stores, err := conn.GetAllTiKVStores(ctx, rc.pdClient, conn.SkipTiFlash,
+		conn.UpStoresOnly, conn.WithStoreLabels(map[string]string{restoreLabelKey: restoreLabelValue}))
 	if err != nil {
 		return errors.Trace(err)
 	}
 	for _, s := range stores {
-		if s.GetState() != metapb.StoreState_Up {
-			continue
-		}
-		for _, l := range s.GetLabels() {
-			if l.GetKey() == restoreLabelKey && l.GetValue() == restoreLabelValue {
-				rc.restoreStores = append(rc.restoreStores, s.GetId())
-				break
-			}
-		}
+		rc.restoreStores = append(rc.restoreStores, s.GetId())
 	}
 	log.Info("load restore stores", zap.Uint64s("store-ids", rc.restoreStores))
 	return nil
@@ -1049,6 +1223,17 @@ func (rc *Client) EnableSkipCreateSQL() {
 	rc.noSchema = true
 }
 
+// SetPrebuiltRewriteMap sets the rewrites of the tables in the map instead of
+// matching the partitions and indices by name.
+func (rc *Client) SetPrebuiltRewriteMap(m *RewriteMap) {
+	rc.prebuiltRewrites = m
+}
+
+// RewriteMap returns the rewrites of the restored tables.
+func (rc *Client) RewriteMap() *RewriteMap {
+	return rc.rewrites
+}
+
 // IsSkipCreateSQL returns whether we need skip create schema and tables in restore.
 func (rc *Client) IsSkipCreateSQL() bool {
 	return rc.noSchema
@@ -1075,43 +1260,41 @@ func (rc *Client) PreCheckTableTiFlashReplica(
 	return nil
 }
 
-// PreCheckTableClusterIndex checks whether backup tables and existed tables have different cluster index options。
+// PreCheckTableClusterIndex checks whether backup tables and existed tables have different cluster index options.
+// The clustered index option of an existing table can't be changed, so all the
+// mismatched tables are reported at once, instead of failing on them one by one.
 func (rc *Client) PreCheckTableClusterIndex(
 	tables []*metautil.Table,
 	ddlJobs []*model.Job,
 	dom *domain.Domain,
 ) error {
-	for _, table := range tables {
-		oldTableInfo, err := rc.GetTableSchema(dom, table.DB.Name, table.Info.Name)
+	var mismatches []string
+	check := func(dbName model.CIStr, tableInfo *model.TableInfo) {
+		oldTableInfo, err := rc.GetTableSchema(dom, dbName, tableInfo.Name)
 		// table exists in database
-		if err == nil {
-			if table.Info.IsCommonHandle != oldTableInfo.IsCommonHandle {
-				return errors.Annotatef(berrors.ErrRestoreModeMismatch,
-					"Clustered index option mismatch. Restored cluster's @@tidb_enable_clustered_index should be %v (backup table = %v, created table = %v).",
-					transferBoolToValue(table.Info.IsCommonHandle),
-					table.Info.IsCommonHandle,
-					oldTableInfo.IsCommonHandle)
-			}
+		if err == nil && tableInfo.IsCommonHandle != oldTableInfo.IsCommonHandle {
+			name := utils.EncloseDBAndTable(dbName.O, tableInfo.Name.O)
+			log.Error("clustered index option mismatch", zap.String("table", name),
+				zap.Bool("backup", tableInfo.IsCommonHandle), zap.Bool("created", oldTableInfo.IsCommonHandle))
+			mismatches = append(mismatches, fmt.Sprintf(
+				"%s: @@tidb_enable_clustered_index should be %v (backup table = %v, created table = %v)",
+				name, transferBoolToValue(tableInfo.IsCommonHandle), tableInfo.IsCommonHandle, oldTableInfo.IsCommonHandle))
 		}
 	}
+	for _, table := range tables {
+		check(table.DB.Name, table.Info)
+	}
 	for _, job := range ddlJobs {
-		if job.Type == model.ActionCreateTable {
-			tableInfo := job.BinlogInfo.TableInfo
-			if tableInfo != nil {
-				oldTableInfo, err := rc.GetTableSchema(dom, model.NewCIStr(job.SchemaName), tableInfo.Name)
-				// table exists in database
-				if err == nil {
-					if tableInfo.IsCommonHandle != oldTableInfo.IsCommonHandle {
-						return errors.Annotatef(berrors.ErrRestoreModeMismatch,
-							"Clustered index option mismatch. Restored cluster's @@tidb_enable_clustered_index should be %v (backup table = %v, created table = %v).",
-							transferBoolToValue(tableInfo.IsCommonHandle),
-							tableInfo.IsCommonHandle,
-							oldTableInfo.IsCommonHandle)
-					}
-				}
-			}
+		if job.Type == model.ActionCreateTable && job.BinlogInfo.TableInfo != nil {
+			check(model.NewCIStr(job.SchemaName), job.BinlogInfo.TableInfo)
 		}
 	}
+	if len(mismatches) > 0 {
+		return errors.Annotatef(berrors.ErrRestoreModeMismatch,
+			"Clustered index option mismatch of %d tables: %s. "+
+				"Drop and recreate them with the expected @@tidb_enable_clustered_index, or exclude them by --filter.",
+			len(mismatches), strings.Join(mismatches, "; "))
+	}
 	return nil
 }
 
@@ -15,7 +15,9 @@ import (
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/types"
+	"github.com/pingcap/tidb/meta/autoid"
 	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/testkit"
 	"github.com/pingcap/tidb/util/testleak"
 	pd "github.com/tikv/pd/client"
 	"google.golang.org/grpc/keepalive"
@@ -103,6 +105,42 @@ func (s *testRestoreClientSuite) TestCreateTables(c *C) {
 	}
 }
 
+func (s *testRestoreClientSuite) TestRebaseAutoIDs(c *C) {
+	c.Assert(s.mock.Start(), IsNil)
+	defer s.mock.Stop()
+	client, err := restore.NewRestoreClient(gluetidb.New(), s.mock.PDClient, s.mock.Storage, nil, defaultKeepaliveCfg)
+	c.Assert(err, IsNil)
+
+	tk := testkit.NewTestKit(c, s.mock.Storage)
+	tk.MustExec("use test")
+	tk.MustExec("create table rebase_inc (id bigint primary key auto_increment, v int)")
+	tk.MustExec("insert into rebase_inc values (5000, 1)")
+	tk.MustExec("create table rebase_rand (id bigint primary key auto_random(5), v int)")
+	tk.MustExec("insert into rebase_rand (v) values (1), (2), (3)")
+
+	info, err := s.mock.Domain.GetSnapshotInfoSchema(math.MaxUint64)
+	c.Assert(err, IsNil)
+	dbSchema, isExist := info.SchemaByName(model.NewCIStr("test"))
+	c.Assert(isExist, IsTrue)
+	var tables []*metautil.Table
+	for _, name := range []string{"rebase_inc", "rebase_rand"} {
+		table, err := info.TableByName(model.NewCIStr("test"), model.NewCIStr(name))
+		c.Assert(err, IsNil)
+		tables = append(tables, &metautil.Table{DB: dbSchema, Info: table.Meta()})
+	}
+	c.Assert(client.RebaseAutoIDs(context.Background(), s.mock.Domain, tables), IsNil)
+
+	// the allocators are rebased above the max restored IDs with the margin.
+	next, err := autoid.NewAllocator(s.mock.Storage, dbSchema.ID, false, autoid.RowIDAllocType).
+		NextGlobalAutoID(tables[0].Info.ID)
+	c.Assert(err, IsNil)
+	c.Assert(next > 6000, IsTrue, Commentf("next auto increment ID %d", next))
+	next, err = autoid.NewAllocator(s.mock.Storage, dbSchema.ID, false, autoid.AutoRandomType).
+		NextGlobalAutoID(tables[1].Info.ID)
+	c.Assert(err, IsNil)
+	c.Assert(next > 1000, IsTrue, Commentf("next auto random ID %d", next))
+}
+
 func (s *testRestoreClientSuite) TestIsOnline(c *C) {
 	c.Assert(s.mock.Start(), IsNil)
 	defer s.mock.Stop()
@@ -155,6 +193,12 @@ func (s *testRestoreClientSuite) TestPreCheckTableClusterIndex(c *C) {
 	c.Assert(client.PreCheckTableClusterIndex(tables, nil, s.mock.Domain),
 		ErrorMatches, `.*@@tidb_enable_clustered_index should be ON \(backup table = true, created table = false\).*`)
 
+	// all the different tables are reported.
+	tables[2].Info.IsCommonHandle = true
+	c.Assert(client.PreCheckTableClusterIndex(tables, nil, s.mock.Domain),
+		ErrorMatches, "Clustered index option mismatch of 2 tables: `test`.`test1`: .*; `test`.`test2`: .*")
+	tables[2].Info.IsCommonHandle = false
+
 	// exist different DDLs
 	jobs := []*model.Job{{
 		ID:         5,
@@ -247,3 +291,24 @@ func (s *testRestoreClientSuite) TestPreCheckTableTiFlashReplicas(c *C) {
 		}
 	}
 }
+
+type nopProgress struct{}
+
+func (nopProgress) Inc()   {}
+func (nopProgress) Close() {}
+
+func (s *testRestoreClientSuite) TestValidateChecksumCanceled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// the stream is never closed, the validation must stop on the cancel.
+	tableStream := make(chan restore.CreatedTable)
+	errCh := make(chan error, 1)
+	client := &restore.Client{}
+	finish := client.GoValidateChecksum(ctx, tableStream, nil, errCh, nopProgress{}, 1)
+	select {
+	case <-finish:
+	case <-time.After(5 * time.Second):
+		c.Fatal("checksum validation not stopped on cancel")
+	}
+	c.Assert(<-errCh, Equals, context.Canceled)
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/types"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/audit"
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// CollationAffectedTable is a table whose keys are encoded differently when
+// the new collations are enabled or not, so the restored keys can't be read
+// by a cluster with the other setting.
+type CollationAffectedTable struct {
+	Table *metautil.Table
+	// Indices are the indices on the collation sensitive columns, they can be
+	// rebuilt from the rows after restore.
+	Indices []*model.IndexInfo
+	// Unrebuildable is the reason the table can't be fixed by rebuilding the
+	// indices, empty if it can.
+	Unrebuildable string
+}
+
+// isCollationSensitive returns whether the keys containing the column depend on
+// whether the new collations are enabled. With the new collations disabled all
+// the string columns are compared as binary, and with them enabled only those
+// in the binary collation are, the others, even utf8mb4_bin which pads the
+// spaces, encode the sort keys.
+func isCollationSensitive(col *model.ColumnInfo) bool {
+	return types.IsString(col.Tp) && col.Collate != charset.CollationBin
+}
+
+// FindCollationAffectedTables finds the tables containing keys encoded with
+// the collations, which must be fixed when restoring into a cluster with the
+// new collations enabled differently. The rows are encoded regardless of the
+// collations, except those clustered by a string primary key, so such tables
+// can't be fixed.
+func FindCollationAffectedTables(tables []*metautil.Table) []*CollationAffectedTable {
+	var affected []*CollationAffectedTable
+	for _, table := range tables {
+		if table.Info.IsView() || table.Info.IsSequence() {
+			continue
+		}
+		var found *CollationAffectedTable
+		for _, index := range table.Info.Indices {
+			sensitive, hidden := false, false
+			for _, idxCol := range index.Columns {
+				col := table.Info.Columns[idxCol.Offset]
+				sensitive = sensitive || isCollationSensitive(col)
+				hidden = hidden || col.Hidden
+			}
+			if !sensitive {
+				continue
+			}
+			if found == nil {
+				found = &CollationAffectedTable{Table: table}
+				affected = append(affected, found)
+			}
+			found.Indices = append(found.Indices, index)
+			switch {
+			case index.Primary && table.Info.IsCommonHandle:
+				found.Unrebuildable = "the rows are clustered by the primary key on the string columns"
+			case hidden && found.Unrebuildable == "":
+				found.Unrebuildable = fmt.Sprintf("the expression index %s can't be rebuilt", utils.EncloseName(index.Name.O))
+			}
+		}
+	}
+	return affected
+}
+
+// RebuildCollationIndexes rebuilds the indices of the tables by dropping and
+// adding them back, so the keys are encoded by the collations of the cluster
+// restored into. It must be called after the data is restored, and none of the
+// tables may be unrebuildable.
+//
+// The indices are rebuilt one by one since TiDB doesn't support changing
+// several of them in a single statement, hence each index is missing until it
+// is added back. The unique indices may fail to be added back if the values
+// are duplicated under the new collations.
+func (rc *Client) RebuildCollationIndexes(ctx context.Context, affected []*CollationAffectedTable) error {
+	for _, t := range affected {
+		if t.Unrebuildable != "" {
+			return errors.Annotatef(berrors.ErrRestoreModeMismatch, "cannot rebuild the indices of %s: %s",
+				utils.EncloseDBAndTable(t.Table.DB.Name.O, t.Table.Info.Name.O), t.Unrebuildable)
+		}
+		for _, index := range t.Indices {
+			if err := rc.db.RebuildIndex(ctx, t.Table.DB.Name, t.Table.Info, index); err != nil {
+				return errors.Annotatef(err, "failed to rebuild the index %s of %s", utils.EncloseName(index.Name.O),
+					utils.EncloseDBAndTable(t.Table.DB.Name.O, t.Table.Info.Name.O))
+			}
+		}
+		log.Info("rebuild collation sensitive indices",
+			zap.Stringer("db", t.Table.DB.Name),
+			zap.Stringer("table", t.Table.Info.Name),
+			zap.Int("indices", len(t.Indices)))
+	}
+	return nil
+}
+
+// RebuildIndex drops the index of the table and adds it back.
+func (db *DB) RebuildIndex(ctx context.Context, dbName model.CIStr, table *model.TableInfo, index *model.IndexInfo) error {
+	tableName := utils.EncloseDBAndTable(dbName.O, table.Name.O)
+	cols := make([]string, 0, len(index.Columns))
+	for _, idxCol := range index.Columns {
+		col := utils.EncloseName(idxCol.Name.O)
+		if idxCol.Length != types.UnspecifiedLength {
+			col = fmt.Sprintf("%s(%d)", col, idxCol.Length)
+		}
+		cols = append(cols, col)
+	}
+
+	var dropQuery, addQuery string
+	switch {
+	case index.Primary:
+		dropQuery = fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", tableName)
+		addQuery = fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s) NONCLUSTERED", tableName, strings.Join(cols, ", "))
+	default:
+		kind := "INDEX"
+		if index.Unique {
+			kind = "UNIQUE INDEX"
+		}
+		dropQuery = fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", tableName, utils.EncloseName(index.Name.O))
+		addQuery = fmt.Sprintf("ALTER TABLE %s ADD %s %s (%s)", tableName, kind,
+			utils.EncloseName(index.Name.O), strings.Join(cols, ", "))
+	}
+	if index.Invisible {
+		addQuery += " INVISIBLE"
+	}
+	for _, query := range []string{dropQuery, addQuery} {
+		start := time.Now()
+		err := db.se.Execute(ctx, query)
+		audit.Record(audit.ActionRebuildIndex, tableName, query, start, err)
+		if err != nil {
+			log.Error("rebuild index failed",
+				zap.String("query", query),
+				zap.Stringer("db", dbName),
+				zap.Stringer("table", table.Name),
+				zap.Error(err))
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/types"
+
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testCollationSuite struct{}
+
+var _ = Suite(&testCollationSuite{})
+
+func newCollationTable(name string, cols []*model.ColumnInfo, indices ...*model.IndexInfo) *metautil.Table {
+	for i, col := range cols {
+		col.Offset = i
+	}
+	return &metautil.Table{
+		DB:   &model.DBInfo{Name: model.NewCIStr("test")},
+		Info: &model.TableInfo{Name: model.NewCIStr(name), Columns: cols, Indices: indices},
+	}
+}
+
+func newCollationIndex(name string, offsets ...int) *model.IndexInfo {
+	index := &model.IndexInfo{Name: model.NewCIStr(name)}
+	for _, offset := range offsets {
+		index.Columns = append(index.Columns, &model.IndexColumn{Offset: offset, Length: types.UnspecifiedLength})
+	}
+	return index
+}
+
+func (s *testCollationSuite) TestFindCollationAffectedTables(c *C) {
+	newCols := func() []*model.ColumnInfo {
+		id := &model.ColumnInfo{Name: model.NewCIStr("id")}
+		id.Tp = mysql.TypeLonglong
+		name := &model.ColumnInfo{Name: model.NewCIStr("name")}
+		name.Tp, name.Collate = mysql.TypeVarchar, "utf8mb4_bin"
+		raw := &model.ColumnInfo{Name: model.NewCIStr("raw")}
+		raw.Tp, raw.Collate = mysql.TypeVarchar, charset.CollationBin
+		return []*model.ColumnInfo{id, name, raw}
+	}
+
+	unaffected := newCollationTable("unaffected", newCols(), newCollationIndex("idx_id", 0), newCollationIndex("idx_raw", 2))
+	indexed := newCollationTable("indexed", newCols(), newCollationIndex("idx_id", 0), newCollationIndex("idx_id_name", 0, 1))
+	clustered := newCollationTable("clustered", newCols(), newCollationIndex("primary", 1))
+	clustered.Info.IsCommonHandle = true
+	clustered.Info.Indices[0].Primary = true
+	expression := newCollationTable("expression", newCols(), newCollationIndex("idx_expr", 1))
+	expression.Info.Columns[1].Hidden = true
+
+	affected := restore.FindCollationAffectedTables([]*metautil.Table{unaffected, indexed, clustered, expression})
+	c.Assert(affected, HasLen, 3)
+	c.Assert(affected[0].Table, Equals, indexed)
+	c.Assert(affected[0].Indices, HasLen, 1)
+	c.Assert(affected[0].Indices[0].Name.O, Equals, "idx_id_name")
+	c.Assert(affected[0].Unrebuildable, Equals, "")
+	c.Assert(affected[1].Table, Equals, clustered)
+	c.Assert(affected[1].Unrebuildable, Matches, ".*clustered by the primary key.*")
+	c.Assert(affected[2].Table, Equals, expression)
+	c.Assert(affected[2].Unrebuildable, Matches, "the expression index `idx_expr` can't be rebuilt")
+}
@@ -0,0 +1,471 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/metautil"
+)
+
+const (
+	planPath      = "/restore/plan"
+	claimPath     = "/restore/claim"
+	heartbeatPath = "/restore/heartbeat"
+	finishPath    = "/restore/finish"
+
+	// PartitionLease is how long a partition claimed by an agent is kept
+	// without its heartbeats, after which it's claimed by another agent.
+	PartitionLease = time.Minute
+)
+
+// TableName names a table in the backup.
+type TableName struct {
+	DB    string `json:"db"`
+	Table string `json:"table"`
+}
+
+// Partition is a part of the tables to restore, which is restored by an agent.
+type Partition struct {
+	ID     int         `json:"id"`
+	Tables []TableName `json:"tables"`
+	// Size is the total bytes of the files of the tables.
+	Size uint64 `json:"size"`
+}
+
+// Plan is the restore plan the coordinator shares with the agents. The
+// coordinator has created the tables, the agents split and ingest the files of
+// the partitions they claim.
+type Plan struct {
+	// Storage is the storage URL of the backup, without the options such as
+	// the credentials, which the agents configure by themselves.
+	Storage string `json:"storage"`
+	// NewTS is the TS rewriting the keys in the incremental restore.
+	NewTS      uint64      `json:"new-ts"`
+	Partitions []Partition `json:"partitions"`
+}
+
+// PartitionTables partitions the tables into at most n partitions of similar
+// sizes, the largest partitions first.
+func PartitionTables(tables []*metautil.Table, n int) []Partition {
+	if n > len(tables) {
+		n = len(tables)
+	}
+	if n <= 0 {
+		return nil
+	}
+	sorted := make([]*metautil.Table, len(tables))
+	copy(sorted, tables)
+	sizes := make(map[*metautil.Table]uint64, len(tables))
+	for _, t := range sorted {
+		size, _ := filesSizeAndKeys(t.Files)
+		sizes[t] = size
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sizes[sorted[i]] > sizes[sorted[j]] })
+
+	partitions := make([]Partition, n)
+	for _, t := range sorted {
+		// put the table into the smallest partition.
+		smallest := 0
+		for i := range partitions {
+			if partitions[i].Size < partitions[smallest].Size {
+				smallest = i
+			}
+		}
+		p := &partitions[smallest]
+		p.Tables = append(p.Tables, TableName{DB: t.DB.Name.O, Table: t.Info.Name.O})
+		p.Size += sizes[t]
+	}
+	sort.SliceStable(partitions, func(i, j int) bool { return partitions[i].Size > partitions[j].Size })
+	for i := range partitions {
+		partitions[i].ID = i
+	}
+	return partitions
+}
+
+type partitionState struct {
+	agent    string
+	beatenAt time.Time
+	done     bool
+}
+
+// Coordinator hands out the partitions of the restore plan to the agents
+// over HTTP, and reclaims the partitions of the agents whose heartbeats are
+// lost. It's done when all the partitions are restored, or an agent fails.
+type Coordinator struct {
+	plan     Plan
+	now      func() time.Time
+	restored func(Partition)
+
+	mu        sync.Mutex
+	states    []partitionState
+	remaining int
+	err       error
+	doneCh    chan struct{}
+	// agents records whether each agent claiming partitions has been told
+	// that all the partitions are restored.
+	agents       map[string]bool
+	agentsDone   bool
+	agentsDoneCh chan struct{}
+}
+
+// NewCoordinator creates a coordinator of the plan.
+func NewCoordinator(plan Plan) *Coordinator {
+	c := &Coordinator{
+		plan:      plan,
+		now:       time.Now,
+		states:    make([]partitionState, len(plan.Partitions)),
+		remaining: len(plan.Partitions),
+		doneCh:    make(chan struct{}),
+
+		agents:       make(map[string]bool),
+		agentsDoneCh: make(chan struct{}),
+	}
+	if c.remaining == 0 {
+		close(c.doneCh)
+	}
+	return c
+}
+
+// OnPartitionRestored sets the callback called when a partition is restored.
+func (c *Coordinator) OnPartitionRestored(f func(Partition)) {
+	c.restored = f
+}
+
+// Wait waits until all the partitions are restored, it returns the error of
+// the agent failed.
+func (c *Coordinator) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return errors.Trace(ctx.Err())
+	case <-c.doneCh:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// WaitAgents waits until all the agents which have claimed partitions are
+// told that the partitions are all restored, so that they exit successfully
+// rather than failing to reach the coordinator stopped. It gives up after the
+// timeout, as the lost agents never claim again.
+func (c *Coordinator) WaitAgents(ctx context.Context, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-c.agentsDoneCh:
+	case <-timer.C:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for agent, told := range c.agents {
+			if !told {
+				log.Warn("the agent isn't told the restore is done", zap.String("agent", agent))
+			}
+		}
+	}
+}
+
+// checkAgentsDoneLocked closes agentsDoneCh once all the partitions are
+// restored and all the agents are told so.
+func (c *Coordinator) checkAgentsDoneLocked() {
+	if c.agentsDone || c.remaining != 0 {
+		return
+	}
+	for _, told := range c.agents {
+		if !told {
+			return
+		}
+	}
+	c.agentsDone = true
+	close(c.agentsDoneCh)
+}
+
+// claim returns the partition the agent is to restore, ok is false if there
+// is none for now, and done is true if all the partitions are restored.
+func (c *Coordinator) claim(agent string) (p Partition, ok bool, done bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.remaining == 0 || c.err != nil {
+		c.agents[agent] = true
+		c.checkAgentsDoneLocked()
+		return Partition{}, false, true
+	}
+	c.agents[agent] = false
+	now := c.now()
+	for i := range c.states {
+		s := &c.states[i]
+		if s.done {
+			continue
+		}
+		if s.agent != "" && now.Sub(s.beatenAt) < PartitionLease {
+			continue
+		}
+		if s.agent != "" {
+			log.Warn("the agent restoring the partition is lost, reclaim it",
+				zap.Int("partition", i), zap.String("lost", s.agent), zap.String("agent", agent))
+		}
+		s.agent = agent
+		s.beatenAt = now
+		log.Info("partition claimed", zap.Int("partition", i), zap.String("agent", agent),
+			zap.Int("tables", len(c.plan.Partitions[i].Tables)), zap.Uint64("size", c.plan.Partitions[i].Size))
+		return c.plan.Partitions[i], true, false
+	}
+	return Partition{}, false, false
+}
+
+// heartbeat returns false if the partition has been claimed by another agent.
+func (c *Coordinator) heartbeat(agent string, id int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := &c.states[id]
+	if s.agent != agent {
+		return false
+	}
+	s.beatenAt = c.now()
+	return true
+}
+
+// finish returns false if the partition is not claimed by the agent, which
+// may have been reclaimed by another agent.
+func (c *Coordinator) finish(agent string, id int, errMsg string) bool {
+	c.mu.Lock()
+	s := &c.states[id]
+	if s.agent != agent {
+		c.mu.Unlock()
+		log.Warn("the agent finishing the partition doesn't hold it, ignore it",
+			zap.Int("partition", id), zap.String("agent", agent), zap.String("holder", s.agent))
+		return false
+	}
+	if s.done || c.err != nil {
+		c.mu.Unlock()
+		return true
+	}
+	if errMsg != "" {
+		log.Error("agent failed to restore the partition",
+			zap.Int("partition", id), zap.String("agent", agent), zap.String("error", errMsg))
+		c.err = errors.Annotatef(berrors.ErrRestoreAgentFailed,
+			"agent %s failed to restore partition %d: %s", agent, id, errMsg)
+		close(c.doneCh)
+		c.mu.Unlock()
+		return true
+	}
+	log.Info("partition restored", zap.Int("partition", id), zap.String("agent", agent))
+	s.done = true
+	c.remaining--
+	allDone := c.remaining == 0
+	c.mu.Unlock()
+
+	// the callback is called before Wait returns.
+	if c.restored != nil {
+		c.restored(c.plan.Partitions[id])
+	}
+	if allDone {
+		close(c.doneCh)
+	}
+	return true
+}
+
+// ServeHTTP implements http.Handler.
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	agent := r.URL.Query().Get("agent")
+	if r.URL.Path != planPath && agent == "" {
+		http.Error(w, "missing agent", http.StatusBadRequest)
+		return
+	}
+	id := -1
+	if raw := r.URL.Query().Get("partition"); raw != "" {
+		var err error
+		id, err = strconv.Atoi(raw)
+		if err != nil || id < 0 || id >= len(c.states) {
+			http.Error(w, "invalid partition", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Path == planPath && r.Method == http.MethodGet:
+		writeJSON(w, c.plan)
+	case r.URL.Path == claimPath && r.Method == http.MethodPost:
+		p, ok, done := c.claim(agent)
+		switch {
+		case done:
+			w.WriteHeader(http.StatusGone)
+		case !ok:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, p)
+		}
+	case r.URL.Path == heartbeatPath && r.Method == http.MethodPost && id >= 0:
+		if !c.heartbeat(agent, id) {
+			w.WriteHeader(http.StatusConflict)
+		}
+	case r.URL.Path == finishPath && r.Method == http.MethodPost && id >= 0:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !c.finish(agent, id, string(body)) {
+			w.WriteHeader(http.StatusConflict)
+		}
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn("failed to write response", zap.Error(err))
+	}
+}
+
+// CoordinatorClient is the client of the coordinator used by the agents.
+type CoordinatorClient struct {
+	addr  string
+	agent string
+	cli   *http.Client
+}
+
+// NewCoordinatorClient creates a client of the coordinator at the address,
+// the agent is the name of the agent reported to the coordinator.
+func NewCoordinatorClient(addr, agent string, tlsConf *tls.Config) *CoordinatorClient {
+	scheme := "http://"
+	transport := http.DefaultTransport
+	if tlsConf != nil {
+		scheme = "https://"
+		transport = &http.Transport{TLSClientConfig: tlsConf}
+	}
+	return &CoordinatorClient{
+		addr:  scheme + addr,
+		agent: agent,
+		cli:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+func (c *CoordinatorClient) post(ctx context.Context, path string, id int, body []byte) (*http.Response, error) {
+	query := url.Values{"agent": {c.agent}}
+	if id >= 0 {
+		query.Set("partition", strconv.Itoa(id))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.addr+path+"?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return nil, errors.Annotatef(berrors.ErrFailedToConnect, "failed to reach the coordinator %s: %s", c.addr, err)
+	}
+	return resp, nil
+}
+
+func checkResponse(resp *http.Response, expected ...int) error {
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return errors.Annotatef(berrors.ErrRestoreAgentFailed, "unexpected response of the coordinator: %s %s",
+		resp.Status, bytes.TrimSpace(msg))
+}
+
+// FetchPlan fetches the restore plan.
+func (c *CoordinatorClient) FetchPlan(ctx context.Context) (*Plan, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.addr+planPath, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return nil, errors.Annotatef(berrors.ErrFailedToConnect, "failed to reach the coordinator %s: %s", c.addr, err)
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp, http.StatusOK); err != nil {
+		return nil, errors.Trace(err)
+	}
+	plan := &Plan{}
+	if err = json.NewDecoder(resp.Body).Decode(plan); err != nil {
+		return nil, errors.Annotatef(berrors.ErrRestoreAgentFailed, "invalid restore plan: %s", err)
+	}
+	return plan, nil
+}
+
+// Claim claims a partition to restore, ok is false if there is none for
+// now, and done is true if all the partitions are restored.
+func (c *CoordinatorClient) Claim(ctx context.Context) (p Partition, ok bool, done bool, err error) {
+	resp, err := c.post(ctx, claimPath, -1, nil)
+	if err != nil {
+		return Partition{}, false, false, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusGone:
+		return Partition{}, false, true, nil
+	case http.StatusNoContent:
+		return Partition{}, false, false, nil
+	}
+	if err = checkResponse(resp, http.StatusOK); err != nil {
+		return Partition{}, false, false, errors.Trace(err)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return Partition{}, false, false, errors.Annotate(berrors.ErrRestoreAgentFailed, err.Error())
+	}
+	return p, true, false, nil
+}
+
+// Heartbeat keeps the partition claimed, it fails with
+// ErrRestoreReclaimed if the partition has been claimed by another
+// agent.
+func (c *CoordinatorClient) Heartbeat(ctx context.Context, id int) error {
+	resp, err := c.post(ctx, heartbeatPath, id, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	return errors.Trace(checkClaimedResponse(resp, id))
+}
+
+// checkClaimedResponse checks the response of a request on a partition the
+// agent has claimed.
+func checkClaimedResponse(resp *http.Response, id int) error {
+	if resp.StatusCode == http.StatusConflict {
+		return errors.Annotatef(berrors.ErrRestoreReclaimed, "partition %d", id)
+	}
+	return checkResponse(resp, http.StatusOK)
+}
+
+// Finish reports the partition is restored, or failed by the error. It fails
+// with ErrRestoreReclaimed if the partition has been claimed by
+// another agent.
+func (c *CoordinatorClient) Finish(ctx context.Context, id int, restoreErr error) error {
+	var body []byte
+	if restoreErr != nil {
+		body = []byte(restoreErr.Error())
+	}
+	resp, err := c.post(ctx, finishPath, id, body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	return errors.Trace(checkClaimedResponse(resp, id))
+}
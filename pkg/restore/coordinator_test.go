@@ -0,0 +1,145 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testCoordinatorSuite struct{}
+
+var _ = Suite(&testCoordinatorSuite{})
+
+func tableOfSize(name string, size uint64) *metautil.Table {
+	return &metautil.Table{
+		DB:    &model.DBInfo{Name: model.NewCIStr("db")},
+		Info:  &model.TableInfo{Name: model.NewCIStr(name)},
+		Files: []*backuppb.File{{Name: name, TotalBytes: size}},
+	}
+}
+
+func (s *testCoordinatorSuite) TestPartitionTables(c *C) {
+	tables := []*metautil.Table{
+		tableOfSize("a", 10), tableOfSize("b", 60), tableOfSize("c", 30),
+		tableOfSize("d", 20), tableOfSize("e", 40),
+	}
+	partitions := restore.PartitionTables(tables, 2)
+	c.Assert(partitions, HasLen, 2)
+	c.Assert(partitions[0].ID, Equals, 0)
+	c.Assert(partitions[0].Size, Equals, uint64(80))
+	c.Assert(partitions[1].Size, Equals, uint64(80))
+	seen := 0
+	for _, p := range partitions {
+		seen += len(p.Tables)
+	}
+	c.Assert(seen, Equals, len(tables))
+
+	// no more partitions than the tables.
+	c.Assert(restore.PartitionTables(tables[:1], 4), HasLen, 1)
+	c.Assert(restore.PartitionTables(nil, 4), HasLen, 0)
+}
+
+func (s *testCoordinatorSuite) TestCoordinate(c *C) {
+	ctx := context.Background()
+	tables := []*metautil.Table{tableOfSize("a", 10), tableOfSize("b", 20)}
+	coordinator := restore.NewCoordinator(restore.Plan{
+		Storage:    "local:///backup",
+		NewTS:      42,
+		Partitions: restore.PartitionTables(tables, 2),
+	})
+	var restored []int
+	coordinator.OnPartitionRestored(func(p restore.Partition) {
+		restored = append(restored, p.ID)
+	})
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	agent1 := restore.NewCoordinatorClient(addr, "agent-1", nil)
+	agent2 := restore.NewCoordinatorClient(addr, "agent-2", nil)
+
+	plan, err := agent1.FetchPlan(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(plan.Storage, Equals, "local:///backup")
+	c.Assert(plan.NewTS, Equals, uint64(42))
+	c.Assert(plan.Partitions, HasLen, 2)
+
+	p1, ok, done, err := agent1.Claim(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(ok && !done, IsTrue)
+	p2, ok, done, err := agent2.Claim(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(ok && !done, IsTrue)
+	c.Assert(p1.ID, Not(Equals), p2.ID)
+
+	// all the partitions are claimed but not restored yet.
+	_, ok, done, err = agent1.Claim(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(ok || done, IsFalse)
+
+	c.Assert(agent1.Heartbeat(ctx, p1.ID), IsNil)
+	// the partition isn't claimed by agent 2, nor by an unknown agent.
+	err = agent2.Heartbeat(ctx, p1.ID)
+	c.Assert(berrors.Is(err, berrors.ErrRestoreReclaimed), IsTrue, Commentf("err: %v", err))
+	err = agent2.Finish(ctx, p1.ID, nil)
+	c.Assert(berrors.Is(err, berrors.ErrRestoreReclaimed), IsTrue, Commentf("err: %v", err))
+	unknown := restore.NewCoordinatorClient(addr, "unknown", nil)
+	err = unknown.Finish(ctx, p1.ID, errors.New("failed"))
+	c.Assert(berrors.Is(err, berrors.ErrRestoreReclaimed), IsTrue, Commentf("err: %v", err))
+	c.Assert(restored, HasLen, 0)
+
+	c.Assert(agent1.Finish(ctx, p1.ID, nil), IsNil)
+	c.Assert(agent2.Finish(ctx, p2.ID, nil), IsNil)
+	c.Assert(coordinator.Wait(ctx), IsNil)
+	c.Assert(restored, HasLen, 2)
+
+	// the coordinator keeps serving until both agents are told it's done.
+	waited := make(chan struct{})
+	go func() {
+		coordinator.WaitAgents(ctx, time.Minute)
+		close(waited)
+	}()
+	_, ok, done, err = agent1.Claim(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(!ok && done, IsTrue)
+	select {
+	case <-waited:
+		c.Fatal("the coordinator stops waiting before agent 2 is told")
+	case <-time.After(100 * time.Millisecond):
+	}
+	_, ok, done, err = agent2.Claim(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(!ok && done, IsTrue)
+	select {
+	case <-waited:
+	case <-time.After(10 * time.Second):
+		c.Fatal("the coordinator keeps waiting after all the agents are told")
+	}
+}
+
+func (s *testCoordinatorSuite) TestAgentFailed(c *C) {
+	ctx := context.Background()
+	coordinator := restore.NewCoordinator(restore.Plan{
+		Partitions: restore.PartitionTables([]*metautil.Table{tableOfSize("a", 10)}, 1),
+	})
+	server := httptest.NewServer(coordinator)
+	defer server.Close()
+	agent := restore.NewCoordinatorClient(strings.TrimPrefix(server.URL, "http://"), "agent", nil)
+
+	p, ok, _, err := agent.Claim(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	c.Assert(agent.Finish(ctx, p.ID, context.Canceled), IsNil)
+	c.Assert(coordinator.Wait(ctx), ErrorMatches, ".*agent agent failed to restore partition 0.*context canceled.*")
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/pingcap/br/pkg/metautil"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/pingcap/tidb/kv"
 	"go.uber.org/zap"
 
+	"github.com/pingcap/br/pkg/audit"
 	"github.com/pingcap/br/pkg/glue"
 	"github.com/pingcap/br/pkg/utils"
 )
@@ -46,6 +48,13 @@ func NewDB(g glue.Glue, store kv.Storage) (*DB, error) {
 
 // ExecDDL executes the query of a ddl job.
 func (db *DB) ExecDDL(ctx context.Context, ddlJob *model.Job) error {
+	start := time.Now()
+	err := db.execDDL(ctx, ddlJob)
+	audit.Record(audit.ActionExecDDL, ddlJob.SchemaName, ddlJob.Query, start, err)
+	return err
+}
+
+func (db *DB) execDDL(ctx context.Context, ddlJob *model.Job) error {
 	var err error
 	tableInfo := ddlJob.BinlogInfo.TableInfo
 	dbInfo := ddlJob.BinlogInfo.DBInfo
@@ -91,16 +100,32 @@ func (db *DB) ExecDDL(ctx context.Context, ddlJob *model.Job) error {
 
 // CreateDatabase executes a CREATE DATABASE SQL.
 func (db *DB) CreateDatabase(ctx context.Context, schema *model.DBInfo) error {
-	err := db.se.CreateDatabase(ctx, schema)
+	start := time.Now()
+	// the database is created if not exists, so it's safe to retry.
+	err := utils.WithRetry(ctx, func() error {
+		return db.se.CreateDatabase(ctx, schema)
+	}, utils.NewDDLBackoffer())
+	audit.Record(audit.ActionCreateDatabase, schema.Name.O, "", start, err)
 	if err != nil {
 		log.Error("create database failed", zap.Stringer("db", schema.Name), zap.Error(err))
 	}
 	return errors.Trace(err)
 }
 
+// SupportBatchCreateTable checks whether the tables can be created in batch.
+func (db *DB) SupportBatchCreateTable() bool {
+	_, ok := db.se.(glue.BatchCreateTableSession)
+	return ok
+}
+
 // CreateTable executes a CREATE TABLE SQL.
 func (db *DB) CreateTable(ctx context.Context, table *metautil.Table) error {
-	err := db.se.CreateTable(ctx, table.DB.Name, table.Info)
+	start := time.Now()
+	// the table is created if not exists, so it's safe to retry.
+	err := utils.WithRetry(ctx, func() error {
+		return db.se.CreateTable(ctx, table.DB.Name, table.Info)
+	}, utils.NewDDLBackoffer())
+	audit.Record(audit.ActionCreateTable, utils.EncloseDBAndTable(table.DB.Name.O, table.Info.Name.O), "", start, err)
 	if err != nil {
 		log.Error("create table failed",
 			zap.Stringer("db", table.DB.Name),
@@ -108,7 +133,51 @@ func (db *DB) CreateTable(ctx context.Context, table *metautil.Table) error {
 			zap.Error(err))
 		return errors.Trace(err)
 	}
+	return errors.Trace(db.restoreTableMeta(ctx, table))
+}
+
+// CreateTables creates the tables in one round-trip if the session supports
+// it, or one by one otherwise.
+func (db *DB) CreateTables(ctx context.Context, tables []*metautil.Table) error {
+	batchSession, ok := db.se.(glue.BatchCreateTableSession)
+	if !ok || len(tables) <= 1 {
+		for _, table := range tables {
+			if err := db.CreateTable(ctx, table); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	}
+
+	infos := make(map[string][]*model.TableInfo)
+	for _, table := range tables {
+		infos[table.DB.Name.L] = append(infos[table.DB.Name.L], table.Info)
+	}
+	start := time.Now()
+	// the tables are created if not exist, so the tables created by the
+	// failed attempt are skipped on retry.
+	err := utils.WithRetry(ctx, func() error {
+		return batchSession.CreateTables(ctx, infos)
+	}, utils.NewDDLBackoffer())
+	for _, table := range tables {
+		audit.Record(audit.ActionCreateTable, utils.EncloseDBAndTable(table.DB.Name.O, table.Info.Name.O), "", start, err)
+	}
+	if err != nil {
+		log.Error("create tables failed", zap.Int("count", len(tables)), zap.Error(err))
+		return errors.Trace(err)
+	}
+	for _, table := range tables {
+		if err = db.restoreTableMeta(ctx, table); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
 
+// restoreTableMeta restores the sequence value and the auto IDs of the table
+// created.
+func (db *DB) restoreTableMeta(ctx context.Context, table *metautil.Table) error {
+	var err error
 	var restoreMetaSQL string
 	if table.Info.IsSequence() {
 		setValFormat := fmt.Sprintf("do setval(%s.%s, %%d);",
@@ -203,6 +272,27 @@ func (db *DB) CreateTable(ctx context.Context, table *metautil.Table) error {
 	return errors.Trace(err)
 }
 
+// RebaseAutoID rebases the auto increment or auto random allocator of the
+// table to the base. The allocator is never moved backward.
+func (db *DB) RebaseAutoID(ctx context.Context, dbName, tableName model.CIStr, autoRandom bool, base int64) error {
+	format := "alter table %s.%s auto_increment = %d"
+	if autoRandom {
+		format = "alter table %s.%s auto_random_base = %d"
+	}
+	query := fmt.Sprintf(format, utils.EncloseName(dbName.O), utils.EncloseName(tableName.O), base)
+	start := time.Now()
+	err := db.se.Execute(ctx, query)
+	audit.Record(audit.ActionRebaseAutoID, utils.EncloseDBAndTable(dbName.O, tableName.O), query, start, err)
+	if err != nil {
+		log.Error("rebase auto ID failed",
+			zap.String("query", query),
+			zap.Stringer("db", dbName),
+			zap.Stringer("table", tableName),
+			zap.Error(err))
+	}
+	return errors.Trace(err)
+}
+
 // Close closes the connection.
 func (db *DB) Close() {
 	db.se.Close()
@@ -265,6 +355,24 @@ func FilterDDLJobs(allDDLJobs []*model.Job, tables []*metautil.Table) (ddlJobs [
 	return ddlJobs
 }
 
+// FilterDDLJobsInRange filters the ddl jobs finished in (startTS, endTS], which
+// are the schema changes between the last backup and the incremental backup.
+// The jobs without a finished ts are kept.
+func FilterDDLJobsInRange(allDDLJobs []*model.Job, startTS, endTS uint64) (ddlJobs []*model.Job) {
+	for _, job := range allDDLJobs {
+		finishedTS := job.BinlogInfo.FinishedTS
+		if finishedTS != 0 && (finishedTS <= startTS || finishedTS > endTS) {
+			log.Info("skip ddl job out of the backup range",
+				zap.Int64("jobID", job.ID),
+				zap.String("query", job.Query),
+				zap.Uint64("finishedTS", finishedTS))
+			continue
+		}
+		ddlJobs = append(ddlJobs, job)
+	}
+	return ddlJobs
+}
+
 func getDatabases(tables []*metautil.Table) (dbs []*model.DBInfo) {
 	dbIDs := make(map[int64]bool)
 	for _, table := range tables {
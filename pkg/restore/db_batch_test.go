@@ -0,0 +1,95 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/br/pkg/metautil"
+)
+
+var _ = Suite(&testBatchCreateTableSuite{})
+
+type testBatchCreateTableSuite struct{}
+
+// batchSession is a glue.BatchCreateTableSession recording the DDLs.
+type batchSession struct {
+	created  []string
+	batches  int
+	failures int
+}
+
+func (se *batchSession) Execute(ctx context.Context, sql string) error {
+	return nil
+}
+
+func (se *batchSession) CreateDatabase(ctx context.Context, schema *model.DBInfo) error {
+	return nil
+}
+
+func (se *batchSession) CreateTable(ctx context.Context, dbName model.CIStr, table *model.TableInfo) error {
+	se.created = append(se.created, dbName.L+"."+table.Name.L)
+	return nil
+}
+
+func (se *batchSession) CreateTables(ctx context.Context, tables map[string][]*model.TableInfo) error {
+	if se.failures > 0 {
+		se.failures--
+		return errors.New("[ddl:8201]TiDB server is not a DDL owner")
+	}
+	se.batches++
+	for db, infos := range tables {
+		for _, info := range infos {
+			se.created = append(se.created, db+"."+info.Name.L)
+		}
+	}
+	return nil
+}
+
+func (se *batchSession) Close() {}
+
+func fakeTables(db string, n int) []*metautil.Table {
+	tables := make([]*metautil.Table, 0, n)
+	for i := 0; i < n; i++ {
+		tables = append(tables, &metautil.Table{
+			DB:   &model.DBInfo{Name: model.NewCIStr(db)},
+			Info: &model.TableInfo{ID: int64(i + 1), Name: model.NewCIStr(fmt.Sprintf("t%d", i))},
+		})
+	}
+	return tables
+}
+
+func (s *testBatchCreateTableSuite) TestSplitTableBatches(c *C) {
+	tables := fakeTables("db", 5)
+	batches := splitTableBatches(tables, 2)
+	c.Assert(batches, DeepEquals, [][]*metautil.Table{tables[:2], tables[2:4], tables[4:]})
+	c.Assert(splitTableBatches(tables, 5), HasLen, 1)
+	c.Assert(splitTableBatches(nil, 5), HasLen, 0)
+}
+
+func (s *testBatchCreateTableSuite) TestCreateTables(c *C) {
+	ctx := context.Background()
+	se := &batchSession{failures: 1}
+	db := &DB{se: se}
+	c.Assert(db.SupportBatchCreateTable(), IsTrue)
+	c.Assert(ddlBatchSizeOf(db), Equals, defaultDDLBatchSize)
+
+	// the batch failed by the change of the DDL owner is retried.
+	tables := append(fakeTables("a", 2), fakeTables("b", 1)...)
+	c.Assert(db.CreateTables(ctx, tables), IsNil)
+	c.Assert(se.batches, Equals, 1)
+	sort.Strings(se.created)
+	c.Assert(se.created, DeepEquals, []string{"a.t0", "a.t1", "b.t0"})
+
+	// a single table is created by itself.
+	se.created = nil
+	c.Assert(db.CreateTables(ctx, fakeTables("c", 1)), IsNil)
+	c.Assert(se.batches, Equals, 1)
+	c.Assert(se.created, DeepEquals, []string{"c.t0"})
+}
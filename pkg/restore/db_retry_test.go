@@ -0,0 +1,58 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/br/pkg/metautil"
+)
+
+var _ = Suite(&testDDLRetrySuite{})
+
+type testDDLRetrySuite struct{}
+
+// flakySession is a glue.Session failing the DDLs by the change of the DDL
+// owner for some times.
+type flakySession struct {
+	created  []string
+	failures int
+}
+
+func (se *flakySession) Execute(ctx context.Context, sql string) error {
+	return nil
+}
+
+func (se *flakySession) CreateDatabase(ctx context.Context, schema *model.DBInfo) error {
+	return nil
+}
+
+func (se *flakySession) CreateTable(ctx context.Context, dbName model.CIStr, table *model.TableInfo) error {
+	if se.failures > 0 {
+		se.failures--
+		return errors.New("[ddl:8201]TiDB server is not a DDL owner")
+	}
+	se.created = append(se.created, dbName.L+"."+table.Name.L)
+	return nil
+}
+
+func (se *flakySession) Close() {}
+
+func (s *testDDLRetrySuite) TestCreateTableRetry(c *C) {
+	ctx := context.Background()
+	se := &flakySession{failures: 1}
+	db := &DB{se: se}
+	table := &metautil.Table{
+		DB:   &model.DBInfo{Name: model.NewCIStr("a")},
+		Info: &model.TableInfo{ID: 1, Name: model.NewCIStr("t0")},
+	}
+
+	// the table failed by the change of the DDL owner is retried.
+	c.Assert(db.CreateTable(ctx, table), IsNil)
+	c.Assert(se.created, DeepEquals, []string{"a.t0"})
+	c.Assert(se.failures, Equals, 0)
+}
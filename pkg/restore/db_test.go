@@ -220,3 +220,45 @@ func (s *testRestoreSchemaSuite) TestFilterDDLJobsV2(c *C) {
 	}
 	c.Assert(len(ddlJobs), Equals, 7)
 }
+
+func (s *testRestoreSchemaSuite) TestFilterDDLJobsInRange(c *C) {
+	newJob := func(id int64, finishedTS uint64) *model.Job {
+		return &model.Job{ID: id, BinlogInfo: &model.HistoryInfo{FinishedTS: finishedTS}}
+	}
+	allDDLJobs := []*model.Job{
+		newJob(1, 100),
+		newJob(2, 101),
+		newJob(3, 150),
+		newJob(4, 200),
+		newJob(5, 201),
+		newJob(6, 0),
+	}
+	ddlJobs := restore.FilterDDLJobsInRange(allDDLJobs, 100, 200)
+	ids := make([]int64, 0, len(ddlJobs))
+	for _, job := range ddlJobs {
+		ids = append(ids, job.ID)
+	}
+	c.Assert(ids, DeepEquals, []int64{2, 3, 4, 6})
+}
+
+func (s *testRestoreSchemaSuite) TestScanTableOverlaps(c *C) {
+	tk := testkit.NewTestKit(c, s.mock.Storage)
+	tk.MustExec("CREATE DATABASE IF NOT EXISTS overlap_db;")
+	tk.MustExec("CREATE TABLE overlap_db.empty_table (c1 INT);")
+	tk.MustExec("CREATE TABLE overlap_db.filled_table (c1 INT) PARTITION BY HASH(c1) PARTITIONS 2;")
+	tk.MustExec("INSERT INTO overlap_db.filled_table VALUES (1);")
+
+	dbInfo := &model.DBInfo{Name: model.NewCIStr("overlap_db")}
+	tables := []*metautil.Table{
+		{DB: dbInfo, Info: &model.TableInfo{Name: model.NewCIStr("empty_table")}},
+		{DB: dbInfo, Info: &model.TableInfo{Name: model.NewCIStr("filled_table")}},
+		{DB: dbInfo, Info: &model.TableInfo{Name: model.NewCIStr("new_table")}},
+	}
+	ts, err := s.mock.GetOracle().GetTimestamp(context.Background(), &oracle.Option{TxnScope: oracle.GlobalTxnScope})
+	c.Assert(err, IsNil)
+	overlaps, err := restore.ScanTableOverlaps(s.mock.Storage, s.mock.Domain, tables, ts)
+	c.Assert(err, IsNil)
+	c.Assert(overlaps, HasLen, 1)
+	c.Assert(overlaps[0].Table.O, Equals, "filled_table")
+	c.Assert(overlaps[0].Key, NotNil)
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"fmt"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+)
+
+var _ = Suite(&testDrainFilesSuite{})
+
+type testDrainFilesSuite struct{}
+
+// rangeFiles returns the files of the default and write CF of a range.
+func rangeFiles(tableID, i int64, size, keys uint64) []*backuppb.File {
+	startKey := tablecodec.EncodeRowKey(tableID, codec.EncodeInt(nil, i))
+	endKey := tablecodec.EncodeRowKey(tableID, codec.EncodeInt(nil, i+1))
+	files := make([]*backuppb.File, 0, 2)
+	for _, cf := range []string{defaultCFName, writeCFName} {
+		files = append(files, &backuppb.File{
+			Name:       fmt.Sprintf("1_%d_%d_key_1_%s.sst", tableID, i, cf),
+			StartKey:   startKey,
+			EndKey:     endKey,
+			TotalBytes: size,
+			TotalKvs:   keys,
+			Cf:         cf,
+		})
+	}
+	return files
+}
+
+func (s *testDrainFilesSuite) TestDrainFilesByThreshold(c *C) {
+	var files []*backuppb.File
+	for i := int64(0); i < 5; i++ {
+		files = append(files, rangeFiles(1, i, 10, 1)...)
+	}
+	files = append(files, rangeFiles(2, 0, 10, 1)...)
+
+	// the thresholds are per unit, 2 ranges of 4 files fit in 40 bytes.
+	var units [][]*backuppb.File
+	for unit, left := drainFilesByThreshold(files, 40, 100); len(unit) != 0; unit, left = drainFilesByThreshold(left, 40, 100) {
+		units = append(units, unit)
+	}
+	c.Assert(units, HasLen, 4)
+	c.Assert(units[0], DeepEquals, files[0:4])
+	c.Assert(units[1], DeepEquals, files[4:8])
+	c.Assert(units[2], DeepEquals, files[8:10])
+	// the files of different tables are never coalesced.
+	c.Assert(units[3], DeepEquals, files[10:12])
+
+	// a range larger than the thresholds is still drained as a whole.
+	unit, left := drainFilesByThreshold(files, 1, 1)
+	c.Assert(unit, DeepEquals, files[0:2])
+	c.Assert(left, DeepEquals, files[2:])
+
+	unit, left = drainFilesByThreshold(files[10:], 40, 1)
+	c.Assert(unit, DeepEquals, files[10:12])
+	c.Assert(left, HasLen, 0)
+}
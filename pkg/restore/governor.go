@@ -0,0 +1,210 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// SLOProbe probes the service level of the cluster restored into.
+type SLOProbe interface {
+	// Probe returns the latency of the probe, or an error if the service is
+	// unavailable.
+	Probe(ctx context.Context) (time.Duration, error)
+	// Close releases the resources of the probe.
+	Close()
+}
+
+type sqlProbe struct {
+	db    *DB
+	query string
+}
+
+// NewSQLProbe returns a probe executing the query by the db, whose latency is
+// the latency of the query. The db is owned by the probe and closed with it.
+func NewSQLProbe(db *DB, query string) SLOProbe {
+	return &sqlProbe{db: db, query: query}
+}
+
+func (p *sqlProbe) Probe(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	err := p.db.se.Execute(ctx, p.query)
+	return time.Since(start), errors.Trace(err)
+}
+
+func (p *sqlProbe) Close() {
+	p.db.Close()
+}
+
+type httpProbe struct {
+	cli *http.Client
+	url string
+}
+
+// NewHTTPProbe returns a probe requesting the URL by GET, which fails unless
+// the status is 2xx.
+func NewHTTPProbe(cli *http.Client, url string) SLOProbe {
+	return &httpProbe{cli: cli, url: url}
+}
+
+func (p *httpProbe) Probe(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	resp, err := p.cli.Do(req)
+	if err != nil {
+		return time.Since(start), errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	latency := time.Since(start)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return latency, errors.Errorf("probe %s responds %s", p.url, resp.Status)
+	}
+	return latency, nil
+}
+
+func (p *httpProbe) Close() {
+	p.cli.CloseIdleConnections()
+}
+
+// SpeedGovernorConfig is the config of a SpeedGovernor.
+type SpeedGovernorConfig struct {
+	// Interval is how often the probe runs, a probe running longer than it
+	// fails.
+	Interval time.Duration
+	// LatencyThreshold is the latency above which the probe is breached.
+	LatencyThreshold time.Duration
+	// MinConcurrency is the concurrency the governor never lowers below.
+	MinConcurrency uint
+}
+
+// SpeedGovernor adjusts the concurrency of a worker pool by the probe of the
+// cluster restored into. The concurrency is halved each time the probe is
+// breached and raised by one each time it's healthy, up to the limit of the
+// pool, so the restore yields to the online workload quickly and catches up
+// gradually.
+//
+// The concurrency is lowered by holding the idle workers of the pool, so the
+// tasks running aren't interrupted, and it takes effect as they finish.
+type SpeedGovernor struct {
+	pool  *utils.WorkerPool
+	probe SLOProbe
+	cfg   SpeedGovernorConfig
+
+	// concurrency is the target concurrency of the pool.
+	concurrency uint
+	held        []*utils.Worker
+}
+
+// NewSpeedGovernor returns a governor of the pool.
+func NewSpeedGovernor(pool *utils.WorkerPool, probe SLOProbe, cfg SpeedGovernorConfig) *SpeedGovernor {
+	if cfg.MinConcurrency == 0 {
+		cfg.MinConcurrency = 1
+	}
+	if cfg.MinConcurrency > pool.Limit() {
+		cfg.MinConcurrency = pool.Limit()
+	}
+	return &SpeedGovernor{
+		pool:        pool,
+		probe:       probe,
+		cfg:         cfg,
+		concurrency: pool.Limit(),
+	}
+}
+
+// Run probes and adjusts the concurrency until the context is done, and then
+// restores the full concurrency of the pool.
+func (g *SpeedGovernor) Run(ctx context.Context) {
+	defer g.release(uint(len(g.held)))
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.step(ctx)
+		}
+	}
+}
+
+// step runs the probe once and adjusts the concurrency by its result.
+func (g *SpeedGovernor) step(ctx context.Context) {
+	pctx, cancel := context.WithTimeout(ctx, g.cfg.Interval)
+	latency, err := g.probe.Probe(pctx)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	concurrency := g.concurrency
+	switch {
+	case err != nil || latency > g.cfg.LatencyThreshold:
+		concurrency /= 2
+		if concurrency < g.cfg.MinConcurrency {
+			concurrency = g.cfg.MinConcurrency
+		}
+	case concurrency < g.pool.Limit():
+		concurrency++
+	}
+	if concurrency != g.concurrency {
+		log.Info("adjust restore concurrency by the SLO probe",
+			zap.Uint("from", g.concurrency),
+			zap.Uint("to", concurrency),
+			zap.Duration("latency", latency),
+			zap.Duration("threshold", g.cfg.LatencyThreshold),
+			zap.Error(err))
+		g.concurrency = concurrency
+	}
+
+	// the workers not idle yet are held by the next steps.
+	toHold := g.pool.Limit() - g.concurrency
+	if held := uint(len(g.held)); held > toHold {
+		g.release(held - toHold)
+	}
+	for uint(len(g.held)) < toHold {
+		worker := g.pool.TryApplyWorker()
+		if worker == nil {
+			break
+		}
+		g.held = append(g.held, worker)
+	}
+}
+
+// release recycles n held workers into the pool.
+func (g *SpeedGovernor) release(n uint) {
+	for i := uint(0); i < n; i++ {
+		last := len(g.held) - 1
+		g.pool.RecycleWorker(g.held[last])
+		g.held = g.held[:last]
+	}
+}
+
+// GoGovernSpeed starts a governor of the concurrency of restoring the files,
+// and returns the function stopping it.
+func (rc *Client) GoGovernSpeed(ctx context.Context, probe SLOProbe, cfg SpeedGovernorConfig) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	governor := NewSpeedGovernor(rc.workerPool, probe, cfg)
+	go func() {
+		defer close(done)
+		governor.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
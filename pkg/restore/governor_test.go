@@ -0,0 +1,109 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/utils"
+)
+
+type testGovernorSuite struct{}
+
+var _ = Suite(&testGovernorSuite{})
+
+// mockProbe returns the latencies in order, and fails if the latency is
+// negative.
+type mockProbe struct {
+	latencies []time.Duration
+}
+
+func (p *mockProbe) Probe(ctx context.Context) (time.Duration, error) {
+	latency := p.latencies[0]
+	p.latencies = p.latencies[1:]
+	if latency < 0 {
+		return 0, errors.New("probe failed")
+	}
+	return latency, nil
+}
+
+func (p *mockProbe) Close() {}
+
+func idleWorkers(pool *utils.WorkerPool) uint {
+	workers := make([]*utils.Worker, 0, pool.Limit())
+	for {
+		worker := pool.TryApplyWorker()
+		if worker == nil {
+			break
+		}
+		workers = append(workers, worker)
+	}
+	for _, worker := range workers {
+		pool.RecycleWorker(worker)
+	}
+	return uint(len(workers))
+}
+
+func (s *testGovernorSuite) TestSpeedGovernor(c *C) {
+	ctx := context.Background()
+	pool := utils.NewWorkerPool(8, "test")
+	slow, fast := 2*time.Second, 10*time.Millisecond
+	probe := &mockProbe{latencies: []time.Duration{fast, slow, -1, slow, slow, fast, fast}}
+	governor := NewSpeedGovernor(pool, probe, SpeedGovernorConfig{
+		Interval:         time.Second,
+		LatencyThreshold: time.Second,
+		MinConcurrency:   2,
+	})
+
+	// halved when breached or failed, down to the min concurrency, and raised
+	// by one when healthy.
+	for _, expected := range []uint{8, 4, 2, 2, 2, 3, 4} {
+		governor.step(ctx)
+		c.Assert(governor.concurrency, Equals, expected)
+		c.Assert(idleWorkers(pool), Equals, expected)
+	}
+
+	// the workers in use are held once they're idle.
+	busy := []*utils.Worker{pool.ApplyWorker(), pool.ApplyWorker(), pool.ApplyWorker()}
+	probe.latencies = []time.Duration{slow, fast}
+	governor.step(ctx)
+	c.Assert(governor.concurrency, Equals, uint(2))
+	c.Assert(governor.held, HasLen, 5)
+	c.Assert(idleWorkers(pool), Equals, uint(0))
+	for _, worker := range busy {
+		pool.RecycleWorker(worker)
+	}
+	governor.step(ctx)
+	c.Assert(governor.concurrency, Equals, uint(3))
+	c.Assert(governor.held, HasLen, 5)
+	c.Assert(idleWorkers(pool), Equals, uint(3))
+
+	// the held workers are released after the governor stops.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	governor.Run(cctx)
+	c.Assert(idleWorkers(pool), Equals, uint(8))
+}
+
+func (s *testGovernorSuite) TestHTTPProbe(c *C) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	probe := NewHTTPProbe(server.Client(), server.URL)
+	defer probe.Close()
+	_, err := probe.Probe(context.Background())
+	c.Assert(err, IsNil)
+
+	status = http.StatusServiceUnavailable
+	_, err = probe.Probe(context.Background())
+	c.Assert(err, ErrorMatches, ".*responds 503 Service Unavailable")
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// TableHook is the post-processing of each restored table, e.g. restoring the
+// grants, invalidating the downstream caches or creating the changefeeds.
+type TableHook interface {
+	// Name is the name of the hook in the logs and the errors.
+	Name() string
+	// AfterTableRestored is called once the data of the table is fully
+	// restored, and checksummed if the checksum is enabled. It's called
+	// concurrently for the different tables.
+	AfterTableRestored(ctx context.Context, table CreatedTable) error
+}
+
+// HookErrorPolicy is how the error of a table hook is handled.
+type HookErrorPolicy int
+
+const (
+	// HookErrorAbort fails the restore on the error of the hook.
+	HookErrorAbort HookErrorPolicy = iota
+	// HookErrorWarn logs the error of the hook and continues the restore,
+	// the later hooks of the table are still run.
+	HookErrorWarn
+)
+
+// TableHookEntry is a table hook with the order it runs in and its error
+// policy.
+type TableHookEntry struct {
+	Hook TableHook
+	// Order is the order of the hook among the hooks of a table, the hooks of
+	// smaller orders run first.
+	Order  int
+	Policy HookErrorPolicy
+}
+
+var (
+	tableHooksMu sync.Mutex
+	tableHooks   []TableHookEntry
+)
+
+// RegisterTableHook registers the hook run on each restored table, usually
+// in the init function of the integrating package.
+func RegisterTableHook(hook TableHook, order int, policy HookErrorPolicy) {
+	tableHooksMu.Lock()
+	defer tableHooksMu.Unlock()
+	tableHooks = append(tableHooks, TableHookEntry{Hook: hook, Order: order, Policy: policy})
+}
+
+// RegisteredTableHooks returns the registered table hooks by their orders,
+// the hooks of the same order are in the order they're registered.
+func RegisteredTableHooks() []TableHookEntry {
+	tableHooksMu.Lock()
+	defer tableHooksMu.Unlock()
+	hooks := append([]TableHookEntry(nil), tableHooks...)
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Order < hooks[j].Order
+	})
+	return hooks
+}
+
+// SetTableHooks sets the hooks run on each restored table, see
+// RegisteredTableHooks.
+func (rc *Client) SetTableHooks(hooks []TableHookEntry) {
+	rc.tableHooks = hooks
+}
+
+// RunTableHooks runs the table hooks on the restored table one by one.
+func (rc *Client) RunTableHooks(ctx context.Context, table CreatedTable) error {
+	for _, entry := range rc.tableHooks {
+		start := time.Now()
+		err := entry.Hook.AfterTableRestored(ctx, table)
+		logger := log.With(
+			zap.String("hook", entry.Hook.Name()),
+			zap.Stringer("table", table.Table.Name),
+			zap.Duration("take", time.Since(start)),
+		)
+		if err == nil {
+			logger.Debug("table hook finished")
+			continue
+		}
+		if entry.Policy == HookErrorWarn {
+			logger.Warn("table hook failed, ignored", zap.Error(err))
+			continue
+		}
+		logger.Error("table hook failed", zap.Error(err))
+		return errors.Annotatef(berrors.ErrRestoreTableHookFailed, "hook %s of table %s: %v",
+			entry.Hook.Name(), table.Table.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testTableHookSuite struct{}
+
+var _ = Suite(&testTableHookSuite{})
+
+type recordHook struct {
+	name   string
+	err    error
+	record *[]string
+}
+
+func (h recordHook) Name() string {
+	return h.name
+}
+
+func (h recordHook) AfterTableRestored(ctx context.Context, table restore.CreatedTable) error {
+	*h.record = append(*h.record, h.name+":"+table.Table.Name.O)
+	return h.err
+}
+
+func (s *testTableHookSuite) TestRegisterTableHook(c *C) {
+	var record []string
+	restore.RegisterTableHook(recordHook{name: "cdc", record: &record}, 20, restore.HookErrorAbort)
+	restore.RegisterTableHook(recordHook{name: "grant", record: &record}, 10, restore.HookErrorAbort)
+	restore.RegisterTableHook(recordHook{name: "cache", record: &record}, 20, restore.HookErrorWarn)
+	hooks := restore.RegisteredTableHooks()
+	c.Assert(hooks, HasLen, 3)
+	names := make([]string, 0, len(hooks))
+	for _, h := range hooks {
+		names = append(names, h.Hook.Name())
+	}
+	c.Assert(names, DeepEquals, []string{"grant", "cdc", "cache"})
+}
+
+func (s *testTableHookSuite) TestRunTableHooks(c *C) {
+	var record []string
+	table := restore.CreatedTable{Table: &model.TableInfo{Name: model.NewCIStr("t")}}
+	client := &restore.Client{}
+	client.SetTableHooks([]restore.TableHookEntry{
+		{Hook: recordHook{name: "grant", record: &record}},
+		{Hook: recordHook{name: "cache", err: errors.New("unreachable"), record: &record}, Policy: restore.HookErrorWarn},
+		{Hook: recordHook{name: "cdc", record: &record}},
+	})
+	c.Assert(client.RunTableHooks(context.Background(), table), IsNil)
+	c.Assert(record, DeepEquals, []string{"grant:t", "cache:t", "cdc:t"})
+
+	record = nil
+	client.SetTableHooks([]restore.TableHookEntry{
+		{Hook: recordHook{name: "grant", err: errors.New("no privilege"), record: &record}},
+		{Hook: recordHook{name: "cdc", record: &record}},
+	})
+	err := client.RunTableHooks(context.Background(), table)
+	c.Assert(err, ErrorMatches, ".*hook grant of table t: no privilege.*")
+	c.Assert(record, DeepEquals, []string{"grant:t"})
+}
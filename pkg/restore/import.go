@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"fmt"
 	"sync"
 	"time"
 
@@ -27,9 +28,11 @@ import (
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
 
+	"github.com/pingcap/br/pkg/audit"
 	"github.com/pingcap/br/pkg/conn"
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/summary"
 	"github.com/pingcap/br/pkg/utils"
 )
@@ -204,6 +207,18 @@ type FileImporter struct {
 	rawStartKey        []byte
 	rawEndKey          []byte
 	supportMultiIngest bool
+
+	// sstCache caches the files of the retried downloads, nil if disabled.
+	sstCache *SSTCache
+	storage  storage.ExternalStorage
+	// cacheKey is the key of the backup in the SST cache.
+	cacheKey string
+
+	// parallelDownloadSize is the size of a file since which its parts in the
+	// regions are downloaded in parallel, 0 if disabled.
+	parallelDownloadSize uint64
+	// parallelDownloadParts is the max parts of a file downloaded at a time.
+	parallelDownloadParts uint
 }
 
 // NewFileImporter returns a new file importClient.
@@ -243,6 +258,122 @@ func (importer *FileImporter) CheckMultiIngestSupport(ctx context.Context, pdCli
 	return nil
 }
 
+// SetSSTCache sets the cache of the files of the retried downloads, which are
+// fetched from the storage of the backup at backupTS.
+func (importer *FileImporter) SetSSTCache(cache *SSTCache, s storage.ExternalStorage, backupTS uint64) {
+	importer.sstCache = cache
+	importer.storage = s
+	if s != nil {
+		importer.cacheKey = SSTCacheKey(s.URI(), backupTS)
+	}
+}
+
+// backendOf returns the storage backend TiKV downloads the file from. It's the
+// cache if the file is cached, or if the download is retried and the file can
+// be fetched into the cache.
+func (importer *FileImporter) backendOf(ctx context.Context, file *backuppb.File, retry bool) *backuppb.StorageBackend {
+	if importer.sstCache == nil {
+		return importer.backend
+	}
+	if importer.sstCache.Contains(importer.cacheKey, file) {
+		return importer.sstCache.Backend(importer.cacheKey)
+	}
+	if !retry || importer.storage == nil {
+		return importer.backend
+	}
+	if err := importer.sstCache.Fetch(ctx, importer.storage, importer.cacheKey, file); err != nil {
+		log.Warn("failed to cache the file, download it from the external storage",
+			logutil.File(file), logutil.ShortError(err))
+		return importer.backend
+	}
+	return importer.sstCache.Backend(importer.cacheKey)
+}
+
+// SetParallelDownload sets the files larger than size to be downloaded into
+// their regions in parallel, up to parts at a time. A size of 0 disables it.
+func (importer *FileImporter) SetParallelDownload(size uint64, parts uint) {
+	importer.parallelDownloadSize = size
+	importer.parallelDownloadParts = parts
+}
+
+// shouldDownloadInParallel checks whether the files spanning the regions are
+// large enough to be downloaded in parallel.
+func (importer *FileImporter) shouldDownloadInParallel(files []*backuppb.File, regions int) bool {
+	if importer.parallelDownloadSize == 0 || importer.parallelDownloadParts <= 1 || regions <= 1 {
+		return false
+	}
+	var size uint64
+	for _, f := range files {
+		size += f.GetSize_()
+	}
+	return size >= importer.parallelDownloadSize
+}
+
+// importRegionsInParallel imports the files into the regions they span, each
+// region is a part of the files downloaded by its own requests, up to
+// parallelDownloadParts at a time. All the parts are tried even if some fail,
+// and the errors are aggregated into the one of the first failed part, whose
+// cause decides whether the import is retried.
+func (importer *FileImporter) importRegionsInParallel(
+	ctx context.Context,
+	regionInfos []*RegionInfo,
+	files []*backuppb.File,
+	rewriteRules *RewriteRules,
+	startKey, endKey []byte,
+	importRetried bool,
+) error {
+	log.Info("download the file in parallel",
+		logutil.Files(files),
+		zap.Int("parts", len(regionInfos)),
+		zap.Uint("concurrency", importer.parallelDownloadParts))
+	errs := make([]error, len(regionInfos))
+	sem := make(chan struct{}, importer.parallelDownloadParts)
+	var wg sync.WaitGroup
+	for i, info := range regionInfos {
+		select {
+		case <-ctx.Done():
+			errs[i] = errors.Trace(ctx.Err())
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, info *RegionInfo) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = importer.importRegion(ctx, info, files, rewriteRules, startKey, endKey, importRetried)
+		}(i, info)
+	}
+	wg.Wait()
+	return aggregatePartErrors(errs)
+}
+
+// aggregatePartErrors returns the error of the first failed part annotated by
+// the errors of the other failed parts, nil if all the parts succeed.
+func aggregatePartErrors(errs []error) error {
+	var first error
+	others := make([]error, 0)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+			continue
+		}
+		others = append(others, err)
+	}
+	if first == nil {
+		return nil
+	}
+	if len(others) == 0 {
+		return errors.Trace(first)
+	}
+	return errors.Annotatef(first, "%d of %d parts failed, the others: %v",
+		len(others)+1, len(errs), multierr.Combine(others...))
+}
+
 // SetRawRange sets the range to be restored in raw kv mode.
 func (importer *FileImporter) SetRawRange(startKey, endKey []byte) error {
 	if !importer.isRawKvMode {
@@ -286,7 +417,9 @@ func (importer *FileImporter) Import(
 		logutil.Key("startKey", startKey),
 		logutil.Key("endKey", endKey))
 
+	importAttempt := 0
 	err := utils.WithRetry(ctx, func() error {
+		importAttempt++
 		tctx, cancel := context.WithTimeout(ctx, importScanRegionTime)
 		defer cancel()
 		// Scan regions covered by the file range
@@ -298,131 +431,166 @@ func (importer *FileImporter) Import(
 
 		log.Debug("scan regions", logutil.Files(files), zap.Int("count", len(regionInfos)))
 		// Try to download and ingest the file in every region
-	regionLoop:
-		for _, regionInfo := range regionInfos {
-			info := regionInfo
-			// Try to download file.
-			downloadMetas := make([]*import_sstpb.SSTMeta, 0, len(files))
-			remainFiles := files
-			errDownload := utils.WithRetry(ctx, func() error {
-				var e error
-				for i, f := range remainFiles {
-					var downloadMeta *import_sstpb.SSTMeta
-					if importer.isRawKvMode {
-						downloadMeta, e = importer.downloadRawKVSST(ctx, info, f)
-					} else {
-						downloadMeta, e = importer.downloadSST(ctx, info, f, rewriteRules)
-					}
-					failpoint.Inject("restore-storage-error", func(val failpoint.Value) {
-						msg := val.(string)
-						log.Debug("failpoint restore-storage-error injected.", zap.String("msg", msg))
-						e = errors.Annotate(e, msg)
-					})
-					if e != nil {
-						remainFiles = remainFiles[i:]
-						return errors.Trace(e)
-					}
-					downloadMetas = append(downloadMetas, downloadMeta)
+		importRetried := importAttempt > 1
+		if importer.shouldDownloadInParallel(files, len(regionInfos)) {
+			if err := importer.importRegionsInParallel(
+				ctx, regionInfos, files, rewriteRules, startKey, endKey, importRetried); err != nil {
+				return errors.Trace(err)
+			}
+		} else {
+			for _, info := range regionInfos {
+				if err := importer.importRegion(
+					ctx, info, files, rewriteRules, startKey, endKey, importRetried); err != nil {
+					return errors.Trace(err)
 				}
+			}
+		}
+		for _, f := range files {
+			summary.CollectSuccessUnit(summary.TotalKV, 1, f.TotalKvs)
+			summary.CollectSuccessUnit(summary.TotalBytes, 1, f.TotalBytes)
+		}
 
-				return nil
-			}, newDownloadSSTBackoffer())
-			if errDownload != nil {
-				for _, e := range multierr.Errors(errDownload) {
-					switch errors.Cause(e) { // nolint:errorlint
-					case berrors.ErrKVRewriteRuleNotFound, berrors.ErrKVRangeIsEmpty:
-						// Skip this region
-						log.Warn("download file skipped",
-							logutil.Files(files),
-							logutil.Region(info.Region),
-							logutil.Key("startKey", startKey),
-							logutil.Key("endKey", endKey),
-							logutil.ShortError(e))
-						continue regionLoop
-					}
+		return nil
+	}, newImportSSTBackoffer())
+	return errors.Trace(err)
+}
+
+// importRegion downloads the files into the region and ingests them. The
+// region is skipped if the files have no key in it.
+func (importer *FileImporter) importRegion(
+	ctx context.Context,
+	info *RegionInfo,
+	files []*backuppb.File,
+	rewriteRules *RewriteRules,
+	startKey, endKey []byte,
+	importRetried bool,
+) error {
+	// Try to download file.
+	downloadMetas := make([]*import_sstpb.SSTMeta, 0, len(files))
+	remainFiles := files
+	downloadAttempt := 0
+	errDownload := utils.WithRetry(ctx, func() error {
+		downloadAttempt++
+		retry := importRetried || downloadAttempt > 1
+		var e error
+		for i, f := range remainFiles {
+			var downloadMeta *import_sstpb.SSTMeta
+			backend := importer.backendOf(ctx, f, retry)
+			if importer.isRawKvMode {
+				downloadMeta, e = importer.downloadRawKVSST(ctx, info, f, backend)
+			} else {
+				downloadMeta, e = importer.downloadSST(ctx, info, f, rewriteRules, backend)
+			}
+			failpoint.Inject("restore-storage-error", func(val failpoint.Value) {
+				msg := val.(string)
+				log.Debug("failpoint restore-storage-error injected.", zap.String("msg", msg))
+				e = errors.Annotate(e, msg)
+			})
+			if e != nil {
+				if errors.Cause(e) == berrors.ErrKVRangeIsEmpty { // nolint:errorlint
+					// the file has no key in the region, but the other
+					// files, e.g. the coalesced ones, may have.
+					continue
 				}
-				log.Error("download file failed",
+				remainFiles = remainFiles[i:]
+				return errors.Trace(e)
+			}
+			downloadMetas = append(downloadMetas, downloadMeta)
+		}
+
+		return nil
+	}, newDownloadSSTBackoffer())
+	if errDownload == nil && len(downloadMetas) == 0 {
+		errDownload = errors.Trace(berrors.ErrKVRangeIsEmpty)
+	}
+	if errDownload != nil {
+		for _, e := range utils.RetryErrors(errDownload) {
+			switch errors.Cause(e) { // nolint:errorlint
+			case berrors.ErrKVRewriteRuleNotFound, berrors.ErrKVRangeIsEmpty:
+				// Skip this region
+				log.Warn("download file skipped",
 					logutil.Files(files),
 					logutil.Region(info.Region),
 					logutil.Key("startKey", startKey),
 					logutil.Key("endKey", endKey),
-					logutil.ShortError(errDownload))
-				return errors.Trace(errDownload)
+					logutil.ShortError(e))
+				return nil
 			}
-
-			ingestResp, errIngest := importer.ingestSSTs(ctx, downloadMetas, info)
-		ingestRetry:
-			for errIngest == nil {
-				errPb := ingestResp.GetError()
-				if errPb == nil {
-					// Ingest success
-					break ingestRetry
+		}
+		log.Error("download file failed",
+			logutil.Files(files),
+			logutil.Region(info.Region),
+			logutil.Key("startKey", startKey),
+			logutil.Key("endKey", endKey),
+			logutil.ShortError(errDownload))
+		return errors.Trace(errDownload)
+	}
+
+	ingestResp, errIngest := importer.ingestSSTs(ctx, downloadMetas, info)
+ingestRetry:
+	for errIngest == nil {
+		errPb := ingestResp.GetError()
+		if errPb == nil {
+			// Ingest success
+			break ingestRetry
+		}
+		switch {
+		case errPb.NotLeader != nil:
+			// If error is `NotLeader`, update the region info and retry
+			var newInfo *RegionInfo
+			if newLeader := errPb.GetNotLeader().GetLeader(); newLeader != nil {
+				newInfo = &RegionInfo{
+					Leader: newLeader,
+					Region: info.Region,
 				}
-				switch {
-				case errPb.NotLeader != nil:
-					// If error is `NotLeader`, update the region info and retry
-					var newInfo *RegionInfo
-					if newLeader := errPb.GetNotLeader().GetLeader(); newLeader != nil {
-						newInfo = &RegionInfo{
-							Leader: newLeader,
-							Region: info.Region,
-						}
-					} else {
-						// Slow path, get region from PD
-						newInfo, errIngest = importer.metaClient.GetRegion(
-							ctx, info.Region.GetStartKey())
-						if errIngest != nil {
-							break ingestRetry
-						}
-						// do not get region info, wait a second and continue
-						if newInfo == nil {
-							log.Warn("get region by key return nil", logutil.Region(info.Region))
-							time.Sleep(time.Second)
-							continue
-						}
-					}
-					log.Debug("ingest sst returns not leader error, retry it",
-						logutil.Region(info.Region),
-						zap.Stringer("newLeader", newInfo.Leader))
-
-					if !checkRegionEpoch(newInfo, info) {
-						errIngest = errors.Trace(berrors.ErrKVEpochNotMatch)
-						break ingestRetry
-					}
-					ingestResp, errIngest = importer.ingestSSTs(ctx, downloadMetas, newInfo)
-				case errPb.EpochNotMatch != nil:
-					// TODO handle epoch not match error
-					//      1. retry download if needed
-					//      2. retry ingest
-					errIngest = errors.Trace(berrors.ErrKVEpochNotMatch)
-					break ingestRetry
-				case errPb.KeyNotInRegion != nil:
-					errIngest = errors.Trace(berrors.ErrKVKeyNotInRegion)
-					break ingestRetry
-				default:
-					// Other errors like `ServerIsBusy`, `RegionNotFound`, etc. should be retryable
-					errIngest = errors.Annotatef(berrors.ErrKVIngestFailed, "ingest error %s", errPb)
+			} else {
+				// Slow path, get region from PD
+				newInfo, errIngest = importer.metaClient.GetRegion(
+					ctx, info.Region.GetStartKey())
+				if errIngest != nil {
 					break ingestRetry
 				}
+				// do not get region info, wait a second and continue
+				if newInfo == nil {
+					log.Warn("get region by key return nil", logutil.Region(info.Region))
+					time.Sleep(time.Second)
+					continue
+				}
 			}
+			log.Debug("ingest sst returns not leader error, retry it",
+				logutil.Region(info.Region),
+				zap.Stringer("newLeader", newInfo.Leader))
 
-			if errIngest != nil {
-				log.Error("ingest file failed",
-					logutil.Files(files),
-					logutil.SSTMetas(downloadMetas),
-					logutil.Region(info.Region),
-					zap.Error(errIngest))
-				return errors.Trace(errIngest)
+			if !checkRegionEpoch(newInfo, info) {
+				errIngest = errors.Trace(berrors.ErrKVEpochNotMatch)
+				break ingestRetry
 			}
+			ingestResp, errIngest = importer.ingestSSTs(ctx, downloadMetas, newInfo)
+		case errPb.EpochNotMatch != nil:
+			// TODO handle epoch not match error
+			//      1. retry download if needed
+			//      2. retry ingest
+			errIngest = errors.Trace(berrors.ErrKVEpochNotMatch)
+			break ingestRetry
+		case errPb.KeyNotInRegion != nil:
+			errIngest = errors.Trace(berrors.ErrKVKeyNotInRegion)
+			break ingestRetry
+		default:
+			// Other errors like `ServerIsBusy`, `RegionNotFound`, etc. should be retryable
+			errIngest = errors.Annotatef(berrors.ErrKVIngestFailed, "ingest error %s", errPb)
+			break ingestRetry
 		}
-		for _, f := range files {
-			summary.CollectSuccessUnit(summary.TotalKV, 1, f.TotalKvs)
-			summary.CollectSuccessUnit(summary.TotalBytes, 1, f.TotalBytes)
-		}
+	}
 
-		return nil
-	}, newImportSSTBackoffer())
-	return errors.Trace(err)
+	if errIngest != nil {
+		log.Error("ingest file failed",
+			logutil.Files(files),
+			logutil.SSTMetas(downloadMetas),
+			logutil.Region(info.Region),
+			zap.Error(errIngest))
+		return errors.Trace(errIngest)
+	}
+	return nil
 }
 
 func (importer *FileImporter) setDownloadSpeedLimit(ctx context.Context, storeID uint64) error {
@@ -438,6 +606,7 @@ func (importer *FileImporter) downloadSST(
 	regionInfo *RegionInfo,
 	file *backuppb.File,
 	rewriteRules *RewriteRules,
+	backend *backuppb.StorageBackend,
 ) (*import_sstpb.SSTMeta, error) {
 	uid := uuid.New()
 	id := uid[:]
@@ -458,7 +627,7 @@ func (importer *FileImporter) downloadSST(
 
 	req := &import_sstpb.DownloadRequest{
 		Sst:            sstMeta,
-		StorageBackend: importer.backend,
+		StorageBackend: backend,
 		Name:           file.GetName(),
 		RewriteRule:    rule,
 	}
@@ -489,6 +658,7 @@ func (importer *FileImporter) downloadRawKVSST(
 	ctx context.Context,
 	regionInfo *RegionInfo,
 	file *backuppb.File,
+	backend *backuppb.StorageBackend,
 ) (*import_sstpb.SSTMeta, error) {
 	uid := uuid.New()
 	id := uid[:]
@@ -511,7 +681,7 @@ func (importer *FileImporter) downloadRawKVSST(
 
 	req := &import_sstpb.DownloadRequest{
 		Sst:            sstMeta,
-		StorageBackend: importer.backend,
+		StorageBackend: backend,
 		Name:           file.GetName(),
 		RewriteRule:    rule,
 		IsRawKv:        true,
@@ -540,7 +710,17 @@ func (importer *FileImporter) ingestSSTs(
 	ctx context.Context,
 	sstMetas []*import_sstpb.SSTMeta,
 	regionInfo *RegionInfo,
-) (*import_sstpb.IngestResponse, error) {
+) (resp *import_sstpb.IngestResponse, err error) {
+	start := time.Now()
+	defer func() {
+		auditErr := err
+		if auditErr == nil && resp.GetError() != nil {
+			auditErr = errors.New(resp.GetError().GetMessage())
+		}
+		audit.Record(audit.ActionIngestSST, fmt.Sprintf("region %d", regionInfo.Region.GetId()),
+			fmt.Sprintf("%d files, %d bytes", len(sstMetas), sstMetasTotalLength(sstMetas)), start, auditErr)
+	}()
+
 	leader := regionInfo.Leader
 	if leader == nil {
 		leader = regionInfo.Region.GetPeers()[0]
@@ -561,7 +741,7 @@ func (importer *FileImporter) ingestSSTs(
 			Sst:     sstMetas[0],
 		}
 		log.Debug("ingest SST", logutil.SSTMeta(sstMetas[0]), logutil.Leader(leader))
-		resp, err := importer.importClient.IngestSST(ctx, leader.GetStoreId(), req)
+		resp, err = importer.importClient.IngestSST(ctx, leader.GetStoreId(), req)
 		return resp, errors.Trace(err)
 	}
 
@@ -570,6 +750,14 @@ func (importer *FileImporter) ingestSSTs(
 		Ssts:    sstMetas,
 	}
 	log.Debug("ingest SSTs", logutil.SSTMetas(sstMetas), logutil.Leader(leader))
-	resp, err := importer.importClient.MultiIngest(ctx, leader.GetStoreId(), req)
+	resp, err = importer.importClient.MultiIngest(ctx, leader.GetStoreId(), req)
 	return resp, errors.Trace(err)
 }
+
+func sstMetasTotalLength(sstMetas []*import_sstpb.SSTMeta) uint64 {
+	var total uint64
+	for _, meta := range sstMetas {
+		total += meta.GetLength()
+	}
+	return total
+}
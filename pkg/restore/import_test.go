@@ -0,0 +1,49 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"github.com/docker/go-units"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+var _ = Suite(&testParallelDownloadSuite{})
+
+type testParallelDownloadSuite struct{}
+
+func (s *testParallelDownloadSuite) TestShouldDownloadInParallel(c *C) {
+	importer := &FileImporter{}
+	huge := []*backuppb.File{{Name: "huge.sst", Size_: 10 * units.GiB}}
+	small := []*backuppb.File{{Name: "a.sst", Size_: units.MiB}, {Name: "b.sst", Size_: units.MiB}}
+	c.Assert(importer.shouldDownloadInParallel(huge, 100), IsFalse)
+
+	importer.SetParallelDownload(4*units.GiB, 8)
+	c.Assert(importer.shouldDownloadInParallel(huge, 100), IsTrue)
+	c.Assert(importer.shouldDownloadInParallel(huge, 1), IsFalse)
+	c.Assert(importer.shouldDownloadInParallel(small, 100), IsFalse)
+
+	importer.SetParallelDownload(2*units.MiB, 8)
+	c.Assert(importer.shouldDownloadInParallel(small, 100), IsTrue)
+	importer.SetParallelDownload(2*units.MiB, 1)
+	c.Assert(importer.shouldDownloadInParallel(small, 100), IsFalse)
+}
+
+func (s *testParallelDownloadSuite) TestAggregatePartErrors(c *C) {
+	c.Assert(aggregatePartErrors([]error{nil, nil}), IsNil)
+
+	err := aggregatePartErrors([]error{nil, errors.Trace(berrors.ErrKVEpochNotMatch), nil})
+	c.Assert(errors.Cause(err), Equals, berrors.ErrKVEpochNotMatch)
+
+	err = aggregatePartErrors([]error{
+		errors.Annotate(berrors.ErrKVDownloadFailed, "part 0"),
+		nil,
+		errors.Annotate(berrors.ErrKVIngestFailed, "part 2"),
+	})
+	// the first failed part decides the retry.
+	c.Assert(errors.Cause(err), Equals, berrors.ErrKVDownloadFailed)
+	c.Assert(err, ErrorMatches, "2 of 3 parts failed, the others: part 2.*part 0.*")
+}
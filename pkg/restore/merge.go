@@ -107,34 +107,21 @@ func MergeFileRanges(
 		if leftKeys+rightKeys > splitKeyCount {
 			return false
 		}
-		// Do not merge ranges in different tables.
-		if tablecodec.DecodeTableID(kv.Key(left.StartKey)) != tablecodec.DecodeTableID(kv.Key(right.StartKey)) {
-			return false
-		}
-		// Do not merge ranges in different indexes even if they are in the same
-		// table, as rewrite rule only supports rewriting one pattern.
-		// tableID, indexID, indexValues, err
-		_, indexID1, _, err1 := tablecodec.DecodeIndexKey(kv.Key(left.StartKey))
-		_, indexID2, _, err2 := tablecodec.DecodeIndexKey(kv.Key(right.StartKey))
-		// If both of them are index keys, ...
-		if err1 == nil && err2 == nil {
-			// Merge left and right if they are in the same index.
-			return indexID1 == indexID2
-		}
-		// Otherwise, merge if they are both record keys
-		return err1 != nil && err2 != nil
+		return isSameRewritePattern(left.StartKey, right.StartKey)
 	}
 	sortedRanges := rangeTree.GetSortedRanges()
-	for i := 1; i < len(sortedRanges); {
-		if !needMerge(&sortedRanges[i-1], &sortedRanges[i]) {
-			i++
+	// merge the ranges in place, sortedRanges[:n] are the merged ranges so far.
+	n := 0
+	for i := range sortedRanges {
+		if n > 0 && needMerge(&sortedRanges[n-1], &sortedRanges[i]) {
+			sortedRanges[n-1].EndKey = sortedRanges[i].EndKey
+			sortedRanges[n-1].Files = append(sortedRanges[n-1].Files, sortedRanges[i].Files...)
 			continue
 		}
-		sortedRanges[i-1].EndKey = sortedRanges[i].EndKey
-		sortedRanges[i-1].Files = append(sortedRanges[i-1].Files, sortedRanges[i].Files...)
-		// TODO: this is slow when there are lots of ranges need to merge.
-		sortedRanges = append(sortedRanges[:i], sortedRanges[i+1:]...)
+		sortedRanges[n] = sortedRanges[i]
+		n++
 	}
+	sortedRanges = sortedRanges[:n]
 
 	regionBytesAvg := totalBytes / uint64(totalRegions)
 	regionKeysAvg := totalKvs / uint64(totalRegions)
@@ -153,3 +140,25 @@ func MergeFileRanges(
 		MergedRegionBytesAvg: int(mergedRegionBytesAvg),
 	}, nil
 }
+
+// isSameRewritePattern checks whether the keys are rewritten by the same rule,
+// i.e. they are in the same table, and in the same index or both records, as
+// a rewrite rule only supports rewriting one pattern.
+func isSameRewritePattern(left, right []byte) bool {
+	// Do not merge ranges in different tables.
+	if tablecodec.DecodeTableID(kv.Key(left)) != tablecodec.DecodeTableID(kv.Key(right)) {
+		return false
+	}
+	// Do not merge ranges in different indexes even if they are in the same
+	// table, as rewrite rule only supports rewriting one pattern.
+	// tableID, indexID, indexValues, err
+	_, indexID1, _, err1 := tablecodec.DecodeIndexKey(kv.Key(left))
+	_, indexID2, _, err2 := tablecodec.DecodeIndexKey(kv.Key(right))
+	// If both of them are index keys, ...
+	if err1 == nil && err2 == nil {
+		// Merge left and right if they are in the same index.
+		return indexID1 == indexID2
+	}
+	// Otherwise, merge if they are both record keys
+	return err1 != nil && err2 != nil
+}
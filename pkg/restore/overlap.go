@@ -0,0 +1,76 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+
+	"github.com/pingcap/br/pkg/metautil"
+)
+
+// TableOverlap is a table to restore whose key range in the cluster already
+// contains data, which would be silently overwritten by the restore.
+type TableOverlap struct {
+	DB    model.CIStr
+	Table model.CIStr
+	// PhysicalID is the ID of the table or the partition containing data.
+	PhysicalID int64
+	// Key is the first key found in the range.
+	Key kv.Key
+}
+
+// ScanTableOverlaps scans the key ranges of the existing tables the tables
+// are restored into, and returns the ones already containing data.
+// The tables don't exist yet are created by the restore, so they are empty.
+// The data is read at the snapshot of ts, which should be fetched from PD.
+func ScanTableOverlaps(store kv.Storage, dom *domain.Domain, tables []*metautil.Table, ts uint64) ([]TableOverlap, error) {
+	info := dom.InfoSchema()
+	snapshot := store.GetSnapshot(kv.NewVersion(ts))
+	var overlaps []TableOverlap
+	for _, table := range tables {
+		existing, err := info.TableByName(table.DB.Name, table.Info.Name)
+		if err != nil {
+			// the table doesn't exist.
+			continue
+		}
+		meta := existing.Meta()
+		physicalIDs := []int64{meta.ID}
+		if meta.Partition != nil {
+			for _, def := range meta.Partition.Definitions {
+				physicalIDs = append(physicalIDs, def.ID)
+			}
+		}
+		for _, id := range physicalIDs {
+			key, err := firstKeyOfTable(snapshot, id)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if key != nil {
+				overlaps = append(overlaps, TableOverlap{
+					DB:         table.DB.Name,
+					Table:      table.Info.Name,
+					PhysicalID: id,
+					Key:        key,
+				})
+				break
+			}
+		}
+	}
+	return overlaps, nil
+}
+
+func firstKeyOfTable(snapshot kv.Snapshot, physicalID int64) (kv.Key, error) {
+	iter, err := snapshot.Iter(tablecodec.EncodeTablePrefix(physicalID), tablecodec.EncodeTablePrefix(physicalID+1))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer iter.Close()
+	if !iter.Valid() {
+		return nil, nil
+	}
+	return iter.Key().Clone(), nil
+}
@@ -114,6 +114,10 @@ func (manager *brContextManager) Leave(ctx context.Context, tables []CreatedTabl
 }
 
 func splitPostWork(ctx context.Context, client *Client, tables []*model.TableInfo) {
+	// the restored tables are replicated while the others are being restored.
+	if err := client.ResetStagingRules(ctx, tables); err != nil {
+		log.Warn("reset staging placement rules failed", zap.Error(err))
+	}
 	err := client.ResetPlacementRules(ctx, tables)
 	if err != nil {
 		log.Warn("reset placement rules failed", zap.Error(err))
@@ -122,6 +126,10 @@ func splitPostWork(ctx context.Context, client *Client, tables []*model.TableInf
 }
 
 func splitPrepareWork(ctx context.Context, client *Client, tables []*model.TableInfo) error {
+	if err := client.SetupStagingRules(ctx, tables); err != nil {
+		log.Error("setup staging placement rules failed", zap.Error(err))
+		return errors.Trace(err)
+	}
 	err := client.SetupPlacementRules(ctx, tables)
 	if err != nil {
 		log.Error("setup placement rules failed", zap.Error(err))
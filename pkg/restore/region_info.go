@@ -0,0 +1,140 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/util/codec"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/rtree"
+)
+
+// operatorRegionPattern finds the region in the description of an operator,
+// e.g. "scatter-region {...} (kind:region,leader, region:42(5,3), ...)".
+var operatorRegionPattern = regexp.MustCompile(`region:(\d+)`)
+
+// RegionInfoClient is the PD API fetching the regions and the operators on
+// them.
+type RegionInfoClient interface {
+	RegionStatsScanner
+	// GetOperators returns the descriptions of the running operators.
+	GetOperators(ctx context.Context) ([]string, error)
+}
+
+// RegionInfoReport is the state of the regions covering a key range, to be
+// attached to the issue reports when a restore stalls on the regions.
+type RegionInfoReport struct {
+	// StartKey and EndKey are the hex of the raw keys, an empty end key means
+	// the max.
+	StartKey string          `json:"start-key"`
+	EndKey   string          `json:"end-key"`
+	Regions  []*RegionStatus `json:"regions"`
+	// Problems are what may stall the restore, such as the holes in the range
+	// and the regions without leader.
+	Problems []string `json:"problems"`
+}
+
+// RegionStatus is a region reported by PD and the operators running on it,
+// such as the pending splits and scatters.
+type RegionStatus struct {
+	pdutil.RegionStats
+	Operators []string `json:"operators,omitempty"`
+}
+
+// FetchRegionInfo reports the regions covering each of the key ranges. The
+// operators are omitted if they can't be got, since the regions alone still
+// help.
+func FetchRegionInfo(ctx context.Context, cli RegionInfoClient, ranges []rtree.Range) ([]*RegionInfoReport, error) {
+	operators := make(map[uint64][]string)
+	if ops, err := cli.GetOperators(ctx); err == nil {
+		for _, op := range ops {
+			if m := operatorRegionPattern.FindStringSubmatch(op); m != nil {
+				id, err := strconv.ParseUint(m[1], 10, 64)
+				if err == nil {
+					operators[id] = append(operators[id], op)
+				}
+			}
+		}
+	} else {
+		log.Warn("failed to get the operators, they are omitted", zap.Error(err))
+	}
+
+	reports := make([]*RegionInfoReport, 0, len(ranges))
+	for _, rg := range ranges {
+		start := codec.EncodeBytes(nil, rg.StartKey)
+		var end []byte
+		if len(rg.EndKey) != 0 {
+			end = codec.EncodeBytes(nil, rg.EndKey)
+		}
+		regions, err := scanRegionStats(ctx, cli, start, end)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		report := &RegionInfoReport{
+			StartKey: hex.EncodeToString(rg.StartKey),
+			EndKey:   hex.EncodeToString(rg.EndKey),
+			Regions:  make([]*RegionStatus, 0, len(regions)),
+			Problems: []string{},
+		}
+		for i := range regions {
+			report.Regions = append(report.Regions, &RegionStatus{
+				RegionStats: regions[i],
+				Operators:   operators[regions[i].ID],
+			})
+		}
+		report.Problems = checkRegions(report.Regions, start, end)
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// checkRegions finds the problems of the regions covering [start, end), the
+// keys are in memcomparable-format.
+func checkRegions(regions []*RegionStatus, start, end []byte) []string {
+	problems := []string{}
+	if len(regions) == 0 {
+		return append(problems, "no region covers the range")
+	}
+	// the key to be covered next.
+	next := start
+	for _, region := range regions {
+		startKey, endKey, err := region.Keys()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("region %d has malformed keys: %v", region.ID, err))
+			continue
+		}
+		if bytes.Compare(startKey, next) > 0 {
+			problems = append(problems, fmt.Sprintf("no region covers [%X, %X)", next, startKey))
+		}
+		next = endKey
+		if region.Leader.ID == 0 {
+			problems = append(problems, fmt.Sprintf("region %d has no leader", region.ID))
+		}
+		if len(region.PendingPeers) > 0 {
+			problems = append(problems, fmt.Sprintf("region %d has %d pending peers", region.ID, len(region.PendingPeers)))
+		}
+		if len(region.DownPeers) > 0 {
+			problems = append(problems, fmt.Sprintf("region %d has %d down peers", region.ID, len(region.DownPeers)))
+		}
+		if len(region.Operators) > 0 {
+			problems = append(problems, fmt.Sprintf("region %d has %d running operators", region.ID, len(region.Operators)))
+		}
+		if len(next) == 0 {
+			break
+		}
+	}
+	if len(next) != 0 && (len(end) == 0 || bytes.Compare(next, end) < 0) {
+		problems = append(problems, fmt.Sprintf("no region covers [%X, %X)", next, end))
+	}
+	return problems
+}
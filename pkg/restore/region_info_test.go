@@ -0,0 +1,74 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"context"
+	"encoding/hex"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testRegionInfoSuite struct{}
+
+var _ = Suite(&testRegionInfoSuite{})
+
+// fakeRegionInfoClient reports the regions and the operators.
+type fakeRegionInfoClient struct {
+	fakeRegionMergeClient
+	operators []string
+}
+
+func (f *fakeRegionInfoClient) GetOperators(context.Context) ([]string, error) {
+	return f.operators, nil
+}
+
+func (s *testRegionInfoSuite) TestFetchRegionInfo(c *C) {
+	encode := func(key []byte) string {
+		return hex.EncodeToString(codec.EncodeBytes(nil, key))
+	}
+	ranges := restore.TableKeyRanges([]int64{10})
+	middle := tablecodec.EncodeRowKeyWithHandle(10, kv.IntHandle(100))
+	after := tablecodec.EncodeRowKeyWithHandle(10, kv.IntHandle(200))
+	peers := []pdutil.RegionPeer{{ID: 11, StoreID: 1}, {ID: 12, StoreID: 2}}
+	cli := &fakeRegionInfoClient{
+		fakeRegionMergeClient: fakeRegionMergeClient{regions: []pdutil.RegionStats{
+			{ID: 1, StartKey: encode(ranges[0].StartKey), EndKey: encode(middle), Peers: peers, Leader: peers[0]},
+			// the region is missing from the middle to after, e.g. when the
+			// split isn't reported yet, and the next region has no leader.
+			{ID: 2, StartKey: encode(after), EndKey: encode(ranges[0].EndKey), Peers: peers,
+				PendingPeers: peers[1:]},
+		}},
+		operators: []string{
+			"scatter-region {mv peer: store [1] to [3]} (kind:region,leader, region:2(5,3), createAt:...)",
+			"admin-split-region {split: region 5 use policy USEKEY} (kind:admin, region:5(1,1), createAt:...)",
+		},
+	}
+
+	reports, err := restore.FetchRegionInfo(context.Background(), cli, ranges)
+	c.Assert(err, IsNil)
+	c.Assert(reports, HasLen, 1)
+	report := reports[0]
+	c.Assert(report.StartKey, Equals, hex.EncodeToString(ranges[0].StartKey))
+	c.Assert(report.Regions, HasLen, 2)
+	c.Assert(report.Regions[0].Operators, HasLen, 0)
+	c.Assert(report.Regions[1].Operators, DeepEquals, cli.operators[:1])
+	c.Assert(report.Problems, HasLen, 4)
+	c.Assert(report.Problems[0], Matches, "no region covers .*")
+	c.Assert(report.Problems[1:], DeepEquals, []string{
+		"region 2 has no leader",
+		"region 2 has 1 pending peers",
+		"region 2 has 1 running operators",
+	})
+
+	// nothing covers the range.
+	reports, err = restore.FetchRegionInfo(context.Background(), cli, restore.TableKeyRanges([]int64{20}))
+	c.Assert(err, IsNil)
+	c.Assert(reports[0].Problems, DeepEquals, []string{"no region covers the range"})
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/rtree"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// RegionStatsScanner is the PD API scanning the stats of the regions.
+type RegionStatsScanner interface {
+	// ScanRegionStats returns the stats of at most limit regions from the
+	// start key, the keys are in memcomparable-format.
+	ScanRegionStats(ctx context.Context, startKey, endKey []byte, limit int) ([]pdutil.RegionStats, error)
+}
+
+// RegionMergeClient is the PD API merging the restored regions.
+type RegionMergeClient interface {
+	RegionStatsScanner
+	// MergeRegions merges the source region into the adjacent target region.
+	MergeRegions(ctx context.Context, source, target uint64) error
+}
+
+// RegionMergeConfig is the config of merging the restored regions.
+type RegionMergeConfig struct {
+	// MaxRegionSizeMiB and MaxRegionKeys are the limits of the merged regions,
+	// the same as the `max-merge-region-size` and `max-merge-region-keys` of
+	// PD usually.
+	MaxRegionSizeMiB int64
+	MaxRegionKeys    int64
+	// Interval is the time to wait for the merges of a round to finish.
+	Interval time.Duration
+}
+
+// RegionMergeStat is the result of merging the restored regions.
+type RegionMergeStat struct {
+	Rounds         int
+	RegionsBefore  int
+	RegionsAfter   int
+	MergesSent     int
+	MergesRejected int
+}
+
+// TableKeyRanges returns the key ranges of the tables.
+func TableKeyRanges(physicalIDs []int64) []rtree.Range {
+	ranges := make([]rtree.Range, 0, len(physicalIDs))
+	for _, id := range physicalIDs {
+		ranges = append(ranges, rtree.Range{
+			StartKey: tablecodec.EncodeTablePrefix(id),
+			EndKey:   tablecodec.EncodeTablePrefix(id + 1),
+		})
+	}
+	return ranges
+}
+
+// MergeRegions merges the adjacent small regions inside the key ranges by the
+// merge operators of PD, so the cluster doesn't keep the tiny regions split by
+// the restore until PD merges them, which may take days when there are
+// millions of them. The regions are merged in pairs round by round, until no
+// more regions can be merged or the context is done.
+func MergeRegions(
+	ctx context.Context, cli RegionMergeClient, ranges []rtree.Range, cfg RegionMergeConfig,
+) (*RegionMergeStat, error) {
+	stat := &RegionMergeStat{}
+	for {
+		sent := 0
+		regions := 0
+		for _, rg := range ranges {
+			start := codec.EncodeBytes(nil, rg.StartKey)
+			end := codec.EncodeBytes(nil, rg.EndKey)
+			stats, err := scanRegionStats(ctx, cli, start, end)
+			if err != nil {
+				return stat, errors.Trace(err)
+			}
+			regions += len(stats)
+			for _, pair := range planRegionMerges(stats, start, end, cfg) {
+				if err := cli.MergeRegions(ctx, pair.source, pair.target); err != nil {
+					if ctx.Err() != nil {
+						return stat, errors.Trace(ctx.Err())
+					}
+					// e.g. the region is being scheduled by another operator,
+					// it's merged in the next round.
+					log.Debug("failed to merge region", zap.Uint64("source", pair.source),
+						zap.Uint64("target", pair.target), logutil.ShortError(err))
+					stat.MergesRejected++
+					continue
+				}
+				sent++
+			}
+		}
+		if stat.Rounds == 0 {
+			stat.RegionsBefore = regions
+		}
+		stat.RegionsAfter = regions
+		stat.MergesSent += sent
+		stat.Rounds++
+		log.Info("merge restored regions", zap.Int("round", stat.Rounds),
+			zap.Int("regions", regions), zap.Int("merges", sent))
+		if sent == 0 {
+			return stat, nil
+		}
+		if err := utils.SleepWithContext(ctx, cfg.Interval); err != nil {
+			return stat, errors.Trace(err)
+		}
+	}
+}
+
+func scanRegionStats(ctx context.Context, cli RegionStatsScanner, start, end []byte) ([]pdutil.RegionStats, error) {
+	var regions []pdutil.RegionStats
+	for {
+		batch, err := cli.ScanRegionStats(ctx, start, end, ScanRegionPaginationLimit)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		regions = append(regions, batch...)
+		if len(batch) < ScanRegionPaginationLimit {
+			return regions, nil
+		}
+		_, last, err := batch[len(batch)-1].Keys()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if len(last) == 0 || (len(end) != 0 && bytes.Compare(last, end) >= 0) {
+			return regions, nil
+		}
+		start = last
+	}
+}
+
+type regionMerge struct {
+	source, target uint64
+}
+
+// planRegionMerges pairs the adjacent regions inside [start, end) whose total
+// size is under the limits, each region is in one pair at most, as a region
+// can't be merged by two operators at once.
+func planRegionMerges(regions []pdutil.RegionStats, start, end []byte, cfg RegionMergeConfig) []regionMerge {
+	inside := func(r *pdutil.RegionStats) bool {
+		startKey, endKey, err := r.Keys()
+		if err != nil {
+			return false
+		}
+		return bytes.Compare(startKey, start) >= 0 && len(endKey) != 0 && bytes.Compare(endKey, end) <= 0
+	}
+	var merges []regionMerge
+	for i := 0; i+1 < len(regions); {
+		left, right := &regions[i], &regions[i+1]
+		if left.EndKey != right.StartKey || !inside(left) || !inside(right) ||
+			left.ApproximateSize+right.ApproximateSize > cfg.MaxRegionSizeMiB ||
+			left.ApproximateKeys+right.ApproximateKeys > cfg.MaxRegionKeys {
+			i++
+			continue
+		}
+		merges = append(merges, regionMerge{source: right.ID, target: left.ID})
+		i += 2
+	}
+	return merges
+}
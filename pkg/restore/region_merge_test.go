@@ -0,0 +1,103 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testRegionMergeSuite struct{}
+
+var _ = Suite(&testRegionMergeSuite{})
+
+// fakeRegionMergeClient merges the regions at once.
+type fakeRegionMergeClient struct {
+	regions []pdutil.RegionStats
+}
+
+func (f *fakeRegionMergeClient) ScanRegionStats(
+	_ context.Context, startKey, endKey []byte, limit int,
+) ([]pdutil.RegionStats, error) {
+	var regions []pdutil.RegionStats
+	for i := range f.regions {
+		r := &f.regions[i]
+		start, end, err := r.Keys()
+		if err != nil {
+			return nil, err
+		}
+		if (len(end) == 0 || bytes.Compare(end, startKey) > 0) &&
+			(len(endKey) == 0 || bytes.Compare(start, endKey) < 0) && len(regions) < limit {
+			regions = append(regions, *r)
+		}
+	}
+	return regions, nil
+}
+
+func (f *fakeRegionMergeClient) MergeRegions(_ context.Context, source, target uint64) error {
+	for i := 0; i+1 < len(f.regions); i++ {
+		left, right := &f.regions[i], &f.regions[i+1]
+		if left.ID == target && right.ID == source {
+			left.EndKey = right.EndKey
+			left.ApproximateSize += right.ApproximateSize
+			left.ApproximateKeys += right.ApproximateKeys
+			f.regions = append(f.regions[:i+1], f.regions[i+2:]...)
+			return nil
+		}
+	}
+	return errors.New("regions not adjacent")
+}
+
+func (s *testRegionMergeSuite) TestMergeRegions(c *C) {
+	ranges := restore.TableKeyRanges([]int64{10})
+	encode := func(key []byte) string {
+		if len(key) == 0 {
+			return ""
+		}
+		return hex.EncodeToString(codec.EncodeBytes(nil, key))
+	}
+	// the regions of the table, besides a region before and after it.
+	keys := [][]byte{{}, ranges[0].StartKey}
+	for i := int64(1); i < 8; i++ {
+		keys = append(keys, tablecodec.EncodeRowKeyWithHandle(10, kv.IntHandle(i*100)))
+	}
+	keys = append(keys, ranges[0].EndKey, []byte{})
+	cli := &fakeRegionMergeClient{}
+	for i := 0; i+1 < len(keys); i++ {
+		cli.regions = append(cli.regions, pdutil.RegionStats{
+			ID:              uint64(i + 1),
+			StartKey:        encode(keys[i]),
+			EndKey:          encode(keys[i+1]),
+			ApproximateSize: 1,
+			ApproximateKeys: 10,
+		})
+	}
+	// the 3rd region of the table is too large to merge.
+	cli.regions[3].ApproximateSize = 20
+
+	stat, err := restore.MergeRegions(context.Background(), cli, ranges, restore.RegionMergeConfig{
+		MaxRegionSizeMiB: 20,
+		MaxRegionKeys:    200000,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(stat.RegionsBefore, Equals, 8)
+	// [r2, r3], [r4], [r5, r9], and the regions outside the table.
+	c.Assert(stat.RegionsAfter, Equals, 3)
+	c.Assert(stat.MergesSent, Equals, 5)
+	c.Assert(cli.regions, HasLen, 5)
+	c.Assert(cli.regions[0].ID, Equals, uint64(1))
+	c.Assert(cli.regions[1].ApproximateSize, Equals, int64(2))
+	c.Assert(cli.regions[2].ID, Equals, uint64(4))
+	c.Assert(cli.regions[3].ApproximateSize, Equals, int64(5))
+	c.Assert(cli.regions[4].ID, Equals, uint64(10))
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/import_sstpb"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/tablecodec"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// TableRewrite is the mapping from the IDs of a backed up table to the IDs of
+// the restored table, which decides how the keys of the table are rewritten.
+type TableRewrite struct {
+	DB         string `json:"db"`
+	Table      string `json:"table"`
+	OldTableID int64  `json:"old-table-id"`
+	NewTableID int64  `json:"new-table-id"`
+	// Partitions maps the old partition IDs to the new partition IDs.
+	Partitions map[int64]int64 `json:"partitions,omitempty"`
+	// Indices maps the old index IDs to the new index IDs.
+	Indices map[int64]int64 `json:"indices,omitempty"`
+}
+
+// NewTableRewrite matches the partitions and indices of the old table to the
+// new table by name.
+func NewTableRewrite(db string, newTable, oldTable *model.TableInfo) *TableRewrite {
+	rewrite := &TableRewrite{
+		DB:         db,
+		Table:      newTable.Name.O,
+		OldTableID: oldTable.ID,
+		NewTableID: newTable.ID,
+		Indices:    make(map[int64]int64),
+	}
+	if oldTable.Partition != nil {
+		rewrite.Partitions = make(map[int64]int64)
+		for _, srcPart := range oldTable.Partition.Definitions {
+			for _, destPart := range newTable.Partition.Definitions {
+				if srcPart.Name == destPart.Name {
+					rewrite.Partitions[srcPart.ID] = destPart.ID
+				}
+			}
+		}
+	}
+	for _, srcIndex := range oldTable.Indices {
+		for _, destIndex := range newTable.Indices {
+			if srcIndex.Name == destIndex.Name {
+				rewrite.Indices[srcIndex.ID] = destIndex.ID
+			}
+		}
+	}
+	return rewrite
+}
+
+// Validate checks that the rewrite covers every partition and index of the old
+// table, and only rewrites them into the IDs of the new table.
+func (r *TableRewrite) Validate(newTable, oldTable *model.TableInfo) error {
+	if r.OldTableID != oldTable.ID || r.NewTableID != newTable.ID {
+		return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+			"table %s should be rewritten from %d to %d, but the rewrite map says from %d to %d",
+			newTable.Name, oldTable.ID, newTable.ID, r.OldTableID, r.NewTableID)
+	}
+	if oldTable.Partition != nil {
+		newIDs := make(map[int64]bool)
+		if newTable.Partition != nil {
+			for _, def := range newTable.Partition.Definitions {
+				newIDs[def.ID] = true
+			}
+		}
+		for _, def := range oldTable.Partition.Definitions {
+			if newID, ok := r.Partitions[def.ID]; !ok || !newIDs[newID] {
+				return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+					"partition %s of table %s is not rewritten into the table", def.Name, newTable.Name)
+			}
+		}
+	}
+	newIDs := make(map[int64]bool)
+	for _, index := range newTable.Indices {
+		newIDs[index.ID] = true
+	}
+	for _, index := range oldTable.Indices {
+		if newID, ok := r.Indices[index.ID]; !ok || !newIDs[newID] {
+			return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+				"index %s of table %s is not rewritten into the table", index.Name, newTable.Name)
+		}
+	}
+	return nil
+}
+
+// RewriteRules returns the rewrite rules of the table.
+func (r *TableRewrite) RewriteRules(newTimeStamp uint64) *RewriteRules {
+	tableIDs := map[int64]int64{r.OldTableID: r.NewTableID}
+	for oldID, newID := range r.Partitions {
+		tableIDs[oldID] = newID
+	}
+	dataRules := make([]*import_sstpb.RewriteRule, 0)
+	for oldTableID, newTableID := range tableIDs {
+		dataRules = append(dataRules, &import_sstpb.RewriteRule{
+			OldKeyPrefix: append(tablecodec.EncodeTablePrefix(oldTableID), recordPrefixSep...),
+			NewKeyPrefix: append(tablecodec.EncodeTablePrefix(newTableID), recordPrefixSep...),
+			NewTimestamp: newTimeStamp,
+		})
+		for oldIndexID, newIndexID := range r.Indices {
+			dataRules = append(dataRules, &import_sstpb.RewriteRule{
+				OldKeyPrefix: tablecodec.EncodeTableIndexPrefix(oldTableID, oldIndexID),
+				NewKeyPrefix: tablecodec.EncodeTableIndexPrefix(newTableID, newIndexID),
+				NewTimestamp: newTimeStamp,
+			})
+		}
+	}
+	return &RewriteRules{
+		Data: dataRules,
+	}
+}
+
+// RewriteMap is the table rewrites of a restore. It can be exported for the
+// external tools to translate the keys in the same way as the restore does,
+// or be imported to decide the rewrites of the restore.
+type RewriteMap struct {
+	mu     sync.Mutex
+	tables map[int64]*TableRewrite
+}
+
+// NewRewriteMap creates an empty RewriteMap.
+func NewRewriteMap() *RewriteMap {
+	return &RewriteMap{tables: make(map[int64]*TableRewrite)}
+}
+
+// ParseRewriteMap parses the RewriteMap from its JSON form.
+func ParseRewriteMap(data []byte) (*RewriteMap, error) {
+	var tables []*TableRewrite
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, errors.Annotate(berrors.ErrInvalidArgument, err.Error())
+	}
+	m := NewRewriteMap()
+	for _, table := range tables {
+		if _, ok := m.tables[table.OldTableID]; ok {
+			return nil, errors.Annotatef(berrors.ErrInvalidArgument,
+				"table %d is rewritten more than once", table.OldTableID)
+		}
+		m.tables[table.OldTableID] = table
+	}
+	return m, nil
+}
+
+// Add adds the rewrite of a table.
+func (m *RewriteMap) Add(rewrite *TableRewrite) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tables[rewrite.OldTableID] = rewrite
+}
+
+// Get returns the rewrite of the old table.
+func (m *RewriteMap) Get(oldTableID int64) (*TableRewrite, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rewrite, ok := m.tables[oldTableID]
+	return rewrite, ok
+}
+
+// Tables returns the rewrites of the tables sorted by the old table IDs.
+func (m *RewriteMap) Tables() []*TableRewrite {
+	m.mu.Lock()
+	tables := make([]*TableRewrite, 0, len(m.tables))
+	for _, table := range m.tables {
+		tables = append(tables, table)
+	}
+	m.mu.Unlock()
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].OldTableID < tables[j].OldTableID
+	})
+	return tables
+}
+
+// MarshalJSON implements json.Marshaler, the rewrites are sorted by the old
+// table IDs.
+func (m *RewriteMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Tables())
+}
+
+// NewPhysicalIDs returns the IDs of the restored tables and their partitions
+// in order.
+func (m *RewriteMap) NewPhysicalIDs() []int64 {
+	m.mu.Lock()
+	ids := make([]int64, 0, len(m.tables))
+	for _, table := range m.tables {
+		ids = append(ids, table.NewTableID)
+		for _, partitionID := range table.Partitions {
+			ids = append(ids, partitionID)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
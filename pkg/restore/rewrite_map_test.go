@@ -0,0 +1,78 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"encoding/json"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testRewriteMapSuite struct{}
+
+var _ = Suite(&testRewriteMapSuite{})
+
+func newPartitionedTable(id, indexID int64) *model.TableInfo {
+	return &model.TableInfo{
+		ID:      id,
+		Name:    model.NewCIStr("t"),
+		Indices: []*model.IndexInfo{{ID: indexID, Name: model.NewCIStr("idx")}},
+		Partition: &model.PartitionInfo{
+			Definitions: []model.PartitionDefinition{
+				{ID: id + 1, Name: model.NewCIStr("p0")},
+				{ID: id + 2, Name: model.NewCIStr("p1")},
+			},
+		},
+	}
+}
+
+func (s *testRewriteMapSuite) TestRewriteMapRoundTrip(c *C) {
+	oldTable, newTable := newPartitionedTable(10, 1), newPartitionedTable(100, 2)
+	rewrite := restore.NewTableRewrite("test", newTable, oldTable)
+	c.Assert(rewrite.Partitions, DeepEquals, map[int64]int64{11: 101, 12: 102})
+	c.Assert(rewrite.Indices, DeepEquals, map[int64]int64{1: 2})
+	c.Assert(rewrite.Validate(newTable, oldTable), IsNil)
+	// 3 physical tables, each of them has a record rule and an index rule.
+	c.Assert(rewrite.RewriteRules(0).Data, HasLen, 6)
+
+	m := restore.NewRewriteMap()
+	m.Add(rewrite)
+	data, err := json.Marshal(m)
+	c.Assert(err, IsNil)
+	parsed, err := restore.ParseRewriteMap(data)
+	c.Assert(err, IsNil)
+	got, ok := parsed.Get(10)
+	c.Assert(ok, IsTrue)
+	c.Assert(got, DeepEquals, rewrite)
+	_, ok = parsed.Get(100)
+	c.Assert(ok, IsFalse)
+
+	_, err = restore.ParseRewriteMap([]byte(`[{"old-table-id": 1}, {"old-table-id": 1}]`))
+	c.Assert(err, ErrorMatches, "table 1 is rewritten more than once.*")
+}
+
+func (s *testRewriteMapSuite) TestValidatePrebuiltRewrite(c *C) {
+	oldTable, newTable := newPartitionedTable(10, 1), newPartitionedTable(100, 2)
+	rewrite := &restore.TableRewrite{
+		OldTableID: 10,
+		NewTableID: 100,
+		Partitions: map[int64]int64{11: 102, 12: 101},
+		Indices:    map[int64]int64{1: 2},
+	}
+	c.Assert(rewrite.Validate(newTable, oldTable), IsNil)
+
+	rewrite.Indices[1] = 3
+	err := rewrite.Validate(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "index idx of table t is not rewritten into the table.*")
+
+	delete(rewrite.Partitions, 12)
+	err = rewrite.Validate(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "partition p1 of table t is not rewritten into the table.*")
+
+	rewrite.NewTableID = 200
+	err = rewrite.Validate(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "table t should be rewritten from 10 to 100, but the rewrite map says from 10 to 200.*")
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/codec"
+
+	"github.com/pingcap/br/pkg/pdutil"
+)
+
+// TableSpace is how the regions of a table occupy the cluster.
+type TableSpace struct {
+	Name        string  `json:"name"`
+	PhysicalIDs []int64 `json:"physical-ids"`
+	Regions     int     `json:"regions"`
+	// ApproximateSize is in MiB, summed from the regions reported by PD.
+	ApproximateSize int64 `json:"approximate-size"`
+	ApproximateKeys int64 `json:"approximate-keys"`
+	// SmallRegions are the regions under the merge limits, which are left for
+	// PD to merge.
+	SmallRegions int `json:"small-regions"`
+	// Stores are where the replicas of the regions are, sorted by the IDs.
+	Stores []*StoreSpace `json:"stores"`
+}
+
+// StoreSpace is how the replicas of the regions of a table occupy a store.
+type StoreSpace struct {
+	StoreID  uint64 `json:"store-id"`
+	Address  string `json:"address,omitempty"`
+	Peers    int    `json:"peers"`
+	Leaders  int    `json:"leaders"`
+	Learners int    `json:"learners"`
+	// ApproximateSize is in MiB, the size of the regions replicated here.
+	ApproximateSize int64 `json:"approximate-size"`
+}
+
+// ReportTableSpace scans the regions of the physical tables from PD and sums
+// their sizes and replicas. A region across the boundary of the table is
+// counted as a whole, so the sizes are approximate at the boundaries, which
+// are usually split by the restore and the import. The regions are compared
+// with the limits of the merge config to estimate the merges to come.
+func ReportTableSpace(
+	ctx context.Context,
+	cli RegionStatsScanner,
+	name string,
+	physicalIDs []int64,
+	mergeCfg RegionMergeConfig,
+) (*TableSpace, error) {
+	space := &TableSpace{Name: name, PhysicalIDs: physicalIDs}
+	stores := make(map[uint64]*StoreSpace)
+	seen := make(map[uint64]struct{})
+	for _, rg := range TableKeyRanges(physicalIDs) {
+		start := codec.EncodeBytes(nil, rg.StartKey)
+		end := codec.EncodeBytes(nil, rg.EndKey)
+		regions, err := scanRegionStats(ctx, cli, start, end)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for i := range regions {
+			region := &regions[i]
+			// the partitions may share the regions.
+			if _, ok := seen[region.ID]; ok {
+				continue
+			}
+			seen[region.ID] = struct{}{}
+			addRegionSpace(space, stores, region, mergeCfg)
+		}
+	}
+	space.Stores = make([]*StoreSpace, 0, len(stores))
+	for _, store := range stores {
+		space.Stores = append(space.Stores, store)
+	}
+	sort.Slice(space.Stores, func(i, j int) bool {
+		return space.Stores[i].StoreID < space.Stores[j].StoreID
+	})
+	return space, nil
+}
+
+func addRegionSpace(
+	space *TableSpace, stores map[uint64]*StoreSpace, region *pdutil.RegionStats, mergeCfg RegionMergeConfig,
+) {
+	space.Regions++
+	space.ApproximateSize += region.ApproximateSize
+	space.ApproximateKeys += region.ApproximateKeys
+	if region.ApproximateSize < mergeCfg.MaxRegionSizeMiB && region.ApproximateKeys < mergeCfg.MaxRegionKeys {
+		space.SmallRegions++
+	}
+	for _, peer := range region.Peers {
+		store, ok := stores[peer.StoreID]
+		if !ok {
+			store = &StoreSpace{StoreID: peer.StoreID}
+			stores[peer.StoreID] = store
+		}
+		store.Peers++
+		store.ApproximateSize += region.ApproximateSize
+		if peer.IsLearner {
+			store.Learners++
+		}
+		if peer.ID == region.Leader.ID {
+			store.Leaders++
+		}
+	}
+}
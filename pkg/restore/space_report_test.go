@@ -0,0 +1,74 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"context"
+	"encoding/hex"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testSpaceReportSuite struct{}
+
+var _ = Suite(&testSpaceReportSuite{})
+
+func (s *testSpaceReportSuite) TestReportTableSpace(c *C) {
+	encode := func(key []byte) string {
+		if len(key) == 0 {
+			return ""
+		}
+		return hex.EncodeToString(codec.EncodeBytes(nil, key))
+	}
+	// the regions of the partitions 10 and 11, besides a region before and
+	// after them.
+	keys := [][]byte{
+		{},
+		tablecodec.EncodeTablePrefix(10),
+		tablecodec.EncodeRowKeyWithHandle(10, kv.IntHandle(100)),
+		tablecodec.EncodeTablePrefix(11),
+		tablecodec.EncodeTablePrefix(12),
+		{},
+	}
+	cli := &fakeRegionMergeClient{}
+	for i := 0; i+1 < len(keys); i++ {
+		id := uint64(i + 1)
+		peers := []pdutil.RegionPeer{
+			{ID: id*10 + 1, StoreID: 1},
+			{ID: id*10 + 2, StoreID: 2},
+			{ID: id*10 + 3, StoreID: 3},
+		}
+		cli.regions = append(cli.regions, pdutil.RegionStats{
+			ID:              id,
+			StartKey:        encode(keys[i]),
+			EndKey:          encode(keys[i+1]),
+			ApproximateSize: int64(i + 1),
+			ApproximateKeys: 1000,
+			Peers:           peers,
+			Leader:          peers[i%3],
+		})
+	}
+	// the region of the partition 11 has a learner.
+	cli.regions[3].Peers = append(cli.regions[3].Peers, pdutil.RegionPeer{ID: 44, StoreID: 4, IsLearner: true})
+
+	space, err := restore.ReportTableSpace(context.Background(), cli, "`db`.`t`", []int64{10, 11},
+		restore.RegionMergeConfig{MaxRegionSizeMiB: 3, MaxRegionKeys: 200000})
+	c.Assert(err, IsNil)
+	c.Assert(space.Name, Equals, "`db`.`t`")
+	c.Assert(space.Regions, Equals, 3)
+	c.Assert(space.ApproximateSize, Equals, int64(2+3+4))
+	c.Assert(space.ApproximateKeys, Equals, int64(3000))
+	c.Assert(space.SmallRegions, Equals, 1)
+	c.Assert(space.Stores, DeepEquals, []*restore.StoreSpace{
+		{StoreID: 1, Peers: 3, Leaders: 1, ApproximateSize: 9},
+		{StoreID: 2, Peers: 3, Leaders: 1, ApproximateSize: 9},
+		{StoreID: 3, Peers: 3, Leaders: 1, ApproximateSize: 9},
+		{StoreID: 4, Peers: 1, Learners: 1, ApproximateSize: 4},
+	})
+}
@@ -20,6 +20,7 @@ import (
 
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/redact"
 	"github.com/pingcap/br/pkg/rtree"
 	"github.com/pingcap/br/pkg/utils"
 )
@@ -40,6 +41,9 @@ const (
 	ScatterWaitUpperInterval = 180 * time.Second
 
 	ScanRegionPaginationLimit = 128
+	ScanRegionRetryTimes      = 3
+	ScanRegionWaitInterval    = 50 * time.Millisecond
+	ScanRegionMaxWaitInterval = time.Second
 
 	RejectStoreCheckRetryTimes  = 64
 	RejectStoreCheckInterval    = 100 * time.Millisecond
@@ -298,6 +302,9 @@ func (rs *RegionSplitter) ScatterRegions(ctx context.Context, newRegions []*Regi
 // PaginateScanRegion scan regions with a limit pagination and
 // return all regions at once.
 // It reduces max gRPC message size.
+// The regions are verified to be a continuous chain covering the range, and
+// scanned again with backoff if not, since PD may return the stale regions
+// shortly after the splits.
 func PaginateScanRegion(
 	ctx context.Context, client SplitClient, startKey, endKey []byte, limit int,
 ) ([]*RegionInfo, error) {
@@ -306,6 +313,28 @@ func PaginateScanRegion(
 			hex.EncodeToString(startKey), hex.EncodeToString(endKey))
 	}
 
+	var regions []*RegionInfo
+	err := utils.WithRetry(ctx, func() error {
+		var err error
+		regions, err = scanRegions(ctx, client, startKey, endKey, limit)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = CheckRegionConsistency(startKey, endKey, regions); err != nil {
+			log.Warn("the scanned regions don't cover the range, retrying", logutil.ShortError(err))
+			return errors.Trace(err)
+		}
+		return nil
+	}, utils.NewScanRegionBackoffer(ScanRegionRetryTimes, ScanRegionWaitInterval, ScanRegionMaxWaitInterval))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return regions, nil
+}
+
+func scanRegions(
+	ctx context.Context, client SplitClient, startKey, endKey []byte, limit int,
+) ([]*RegionInfo, error) {
 	regions := []*RegionInfo{}
 	for {
 		batch, err := client.ScanRegions(ctx, startKey, endKey, limit)
@@ -327,6 +356,36 @@ func PaginateScanRegion(
 	return regions, nil
 }
 
+// CheckRegionConsistency checks whether the regions are a continuous chain
+// covering [startKey, endKey), an empty end key means the max. The error tells
+// where the chain breaks.
+func CheckRegionConsistency(startKey, endKey []byte, regions []*RegionInfo) error {
+	if len(regions) == 0 {
+		return errors.Annotatef(berrors.ErrPDBatchScanRegion, "scan region return empty result, startKey: %s, endKey: %s",
+			redact.Key(startKey), redact.Key(endKey))
+	}
+	if bytes.Compare(regions[0].Region.StartKey, startKey) > 0 {
+		return errors.Annotatef(berrors.ErrPDBatchScanRegion,
+			"first region %d's startKey > startKey, startKey: %s, regionStartKey: %s",
+			regions[0].Region.Id, redact.Key(startKey), redact.Key(regions[0].Region.StartKey))
+	}
+	last := regions[len(regions)-1].Region
+	if len(last.EndKey) != 0 && (len(endKey) == 0 || bytes.Compare(last.EndKey, endKey) < 0) {
+		return errors.Annotatef(berrors.ErrPDBatchScanRegion,
+			"last region %d's endKey < endKey, endKey: %s, regionEndKey: %s",
+			last.Id, redact.Key(endKey), redact.Key(last.EndKey))
+	}
+	for i := 1; i < len(regions); i++ {
+		prev, cur := regions[i-1].Region, regions[i].Region
+		if !bytes.Equal(prev.EndKey, cur.StartKey) {
+			return errors.Annotatef(berrors.ErrPDBatchScanRegion,
+				"region %d's endKey not equal to next region %d's startKey, endKey: %s, startKey: %s",
+				prev.Id, cur.Id, redact.Key(prev.EndKey), redact.Key(cur.StartKey))
+		}
+	}
+	return nil
+}
+
 // getSplitKeys checks if the regions should be split by the new prefix of the rewrites rule and the end key of
 // the ranges, groups the split keys by region id.
 func getSplitKeys(rewriteRules *RewriteRules, ranges []rtree.Range, regions []*RegionInfo) map[uint64][][]byte {
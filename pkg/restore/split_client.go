@@ -6,12 +6,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-	"path"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -33,21 +27,28 @@ import (
 	"google.golang.org/grpc/status"
 
 	berrors "github.com/pingcap/br/pkg/errors"
-	"github.com/pingcap/br/pkg/httputil"
 	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/pdutil"
 )
 
 const (
 	splitRegionMaxRetryTime = 4
 )
 
-// SplitClient is an external client used by RegionSplitter.
+// SplitClient is the access to the regions of the cluster used by
+// RegionSplitter, the importers and the placement of the restore. The keys of
+// the regions, and those passed to GetRegion and ScanRegions, are encoded in
+// the memcomparable format, while the keys to split at are raw.
+//
+// NewSplitClient returns the implementation backed by PD and TiKV, and
+// mocksplit.Client is an in-memory one for the tests.
 type SplitClient interface {
 	// GetStore gets a store by a store id.
 	GetStore(ctx context.Context, storeID uint64) (*metapb.Store, error)
 	// GetRegion gets a region which includes a specified key.
 	GetRegion(ctx context.Context, key []byte) (*RegionInfo, error)
-	// GetRegionByID gets a region by a region id.
+	// GetRegionByID gets a region by a region id, it returns nil if the region
+	// doesn't exist.
 	GetRegionByID(ctx context.Context, regionID uint64) (*RegionInfo, error)
 	// SplitRegion splits a region from a key, if key is not included in the region, it will return nil.
 	// note: the key should not be encoded
@@ -55,14 +56,16 @@ type SplitClient interface {
 	// BatchSplitRegions splits a region from a batch of keys.
 	// note: the keys should not be encoded
 	BatchSplitRegions(ctx context.Context, regionInfo *RegionInfo, keys [][]byte) ([]*RegionInfo, error)
-	// BatchSplitRegionsWithOrigin splits a region from a batch of keys and return the original region and split new regions
+	// BatchSplitRegionsWithOrigin splits a region from a batch of keys and return the original region and split new regions.
+	// The split fails if the epoch of regionInfo is stale.
 	BatchSplitRegionsWithOrigin(ctx context.Context, regionInfo *RegionInfo, keys [][]byte) (*RegionInfo, []*RegionInfo, error)
 	// ScatterRegion scatters a specified region.
 	ScatterRegion(ctx context.Context, regionInfo *RegionInfo) error
 	// GetOperator gets the status of operator of the specified region.
 	GetOperator(ctx context.Context, regionID uint64) (*pdpb.GetOperatorResponse, error)
 	// ScanRegion gets a list of regions, starts from the region that contains key.
-	// Limit limits the maximum number of regions returned.
+	// Limit limits the maximum number of regions returned, a limit not greater
+	// than 0 means no limit.
 	ScanRegions(ctx context.Context, key, endKey []byte, limit int) ([]*RegionInfo, error)
 	// GetPlacementRule loads a placement rule from PD.
 	GetPlacementRule(ctx context.Context, groupID, ruleID string) (placement.Rule, error)
@@ -80,14 +83,25 @@ type pdClient struct {
 	mu         sync.Mutex
 	client     pd.Client
 	tlsConf    *tls.Config
+	httpCli    *pdutil.HTTPClient
 	storeCache map[uint64]*metapb.Store
 }
 
-// NewSplitClient returns a client used by RegionSplitter.
+// NewSplitClient returns a client used by RegionSplitter. The HTTP API of PD,
+// used by the placement rules and the store labels, is requested to the
+// leader only.
 func NewSplitClient(client pd.Client, tlsConf *tls.Config) SplitClient {
+	return NewSplitClientWithHTTP(client, tlsConf, nil)
+}
+
+// NewSplitClientWithHTTP returns a client used by RegionSplitter requesting
+// the HTTP API of PD by httpCli, which fails over to the other PDs if one is
+// unavailable. A nil httpCli requests the leader only, as NewSplitClient.
+func NewSplitClientWithHTTP(client pd.Client, tlsConf *tls.Config, httpCli *pdutil.HTTPClient) SplitClient {
 	return &pdClient{
 		client:     client,
 		tlsConf:    tlsConf,
+		httpCli:    httpCli,
 		storeCache: make(map[uint64]*metapb.Store),
 	}
 }
@@ -400,99 +414,56 @@ func (c *pdClient) ScanRegions(ctx context.Context, key, endKey []byte, limit in
 
 func (c *pdClient) GetPlacementRule(ctx context.Context, groupID, ruleID string) (placement.Rule, error) {
 	var rule placement.Rule
-	addr := c.getPDAPIAddr()
-	if addr == "" {
-		return rule, errors.Annotate(berrors.ErrRestoreSplitFailed, "failed to add stores labels: no leader")
-	}
-	req, err := http.NewRequestWithContext(ctx, "GET", addr+path.Join("/pd/api/v1/config/rule", groupID, ruleID), nil)
-	if err != nil {
-		return rule, errors.Trace(err)
-	}
-	res, err := httputil.NewClient(c.tlsConf).Do(req)
-	if err != nil {
-		return rule, errors.Trace(err)
-	}
-	b, err := io.ReadAll(res.Body)
+	cli, err := c.pdHTTPClient()
 	if err != nil {
 		return rule, errors.Trace(err)
 	}
-	res.Body.Close()
-	err = json.Unmarshal(b, &rule)
-	if err != nil {
-		return rule, errors.Trace(err)
-	}
-	return rule, nil
+	rule, err = cli.GetPlacementRule(ctx, groupID, ruleID)
+	return rule, errors.Trace(err)
 }
 
 func (c *pdClient) SetPlacementRule(ctx context.Context, rule placement.Rule) error {
-	addr := c.getPDAPIAddr()
-	if addr == "" {
-		return errors.Annotate(berrors.ErrPDLeaderNotFound, "failed to add stores labels")
-	}
-	m, _ := json.Marshal(rule)
-	req, err := http.NewRequestWithContext(ctx, "POST", addr+path.Join("/pd/api/v1/config/rule"), bytes.NewReader(m))
-	if err != nil {
-		return errors.Trace(err)
-	}
-	res, err := httputil.NewClient(c.tlsConf).Do(req)
+	cli, err := c.pdHTTPClient()
 	if err != nil {
 		return errors.Trace(err)
 	}
-	return errors.Trace(res.Body.Close())
+	return errors.Trace(cli.SetPlacementRule(ctx, rule))
 }
 
 func (c *pdClient) DeletePlacementRule(ctx context.Context, groupID, ruleID string) error {
-	addr := c.getPDAPIAddr()
-	if addr == "" {
-		return errors.Annotate(berrors.ErrPDLeaderNotFound, "failed to add stores labels")
-	}
-	req, err := http.NewRequestWithContext(ctx, "DELETE", addr+path.Join("/pd/api/v1/config/rule", groupID, ruleID), nil)
-	if err != nil {
-		return errors.Trace(err)
-	}
-	res, err := httputil.NewClient(c.tlsConf).Do(req)
+	cli, err := c.pdHTTPClient()
 	if err != nil {
 		return errors.Trace(err)
 	}
-	return errors.Trace(res.Body.Close())
+	return errors.Trace(cli.DeletePlacementRule(ctx, groupID, ruleID))
 }
 
 func (c *pdClient) SetStoresLabel(
 	ctx context.Context, stores []uint64, labelKey, labelValue string,
 ) error {
-	b := []byte(fmt.Sprintf(`{"%s": "%s"}`, labelKey, labelValue))
-	addr := c.getPDAPIAddr()
-	if addr == "" {
-		return errors.Annotate(berrors.ErrPDLeaderNotFound, "failed to add stores labels")
+	cli, err := c.pdHTTPClient()
+	if err != nil {
+		return errors.Trace(err)
 	}
-	httpCli := httputil.NewClient(c.tlsConf)
 	for _, id := range stores {
-		req, err := http.NewRequestWithContext(
-			ctx, "POST",
-			addr+path.Join("/pd/api/v1/store", strconv.FormatUint(id, 10), "label"),
-			bytes.NewReader(b),
-		)
-		if err != nil {
-			return errors.Trace(err)
-		}
-		res, err := httpCli.Do(req)
-		if err != nil {
-			return errors.Trace(err)
-		}
-		err = res.Body.Close()
-		if err != nil {
+		if err := cli.SetStoreLabel(ctx, id, labelKey, labelValue); err != nil {
 			return errors.Trace(err)
 		}
 	}
 	return nil
 }
 
-func (c *pdClient) getPDAPIAddr() string {
+// pdHTTPClient returns the client of the HTTP API of PD, which is the one of
+// the leader if none is given.
+func (c *pdClient) pdHTTPClient() (*pdutil.HTTPClient, error) {
+	if c.httpCli != nil {
+		return c.httpCli, nil
+	}
 	addr := c.client.GetLeaderAddr()
-	if addr != "" && !strings.HasPrefix(addr, "http") {
-		addr = "http://" + addr
+	if addr == "" {
+		return nil, errors.Annotate(berrors.ErrPDLeaderNotFound, "failed to request PD")
 	}
-	return strings.TrimRight(addr, "/")
+	return pdutil.NewHTTPClient([]string{addr}, c.tlsConf), nil
 }
 
 func checkRegionEpoch(new, old *RegionInfo) bool {
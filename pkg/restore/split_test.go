@@ -371,3 +371,69 @@ func (s *testRangeSuite) TestNeedSplit(c *C) {
 	// Out of region
 	c.Assert(restore.NeedSplit([]byte("e"), regions), IsNil)
 }
+
+// gapClient drops the second region of the first scans, like the stale
+// regions PD returns shortly after a split.
+type gapClient struct {
+	*TestClient
+	gaps int
+}
+
+func (c *gapClient) ScanRegions(ctx context.Context, key, endKey []byte, limit int) ([]*restore.RegionInfo, error) {
+	regions, err := c.TestClient.ScanRegions(ctx, key, endKey, limit)
+	if err != nil || c.gaps == 0 || len(regions) < 2 {
+		return regions, err
+	}
+	c.gaps--
+	return append(regions[:1:1], regions[2:]...), nil
+}
+
+func (s *testRangeSuite) TestPaginateScanRegion(c *C) {
+	ctx := context.Background()
+	startKey := codec.EncodeBytes([]byte{}, []byte("b"))
+	endKey := codec.EncodeBytes([]byte{}, []byte("c"))
+
+	// [aay, bba), [bba, bbh), [bbh, cca) cover the range.
+	regions, err := restore.PaginateScanRegion(ctx, initTestClient(), startKey, endKey, 2)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 3)
+	c.Assert(restore.CheckRegionConsistency(startKey, endKey, regions), IsNil)
+
+	// the gaps are scanned again.
+	cli := &gapClient{TestClient: initTestClient(), gaps: restore.ScanRegionRetryTimes - 1}
+	regions, err = restore.PaginateScanRegion(ctx, cli, startKey, endKey, 3)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 3)
+
+	cli = &gapClient{TestClient: initTestClient(), gaps: restore.ScanRegionRetryTimes}
+	_, err = restore.PaginateScanRegion(ctx, cli, startKey, endKey, 3)
+	c.Assert(err, ErrorMatches, ".*region 2's endKey not equal to next region 4's startKey.*")
+}
+
+func (s *testRangeSuite) TestCheckRegionConsistency(c *C) {
+	key := func(k string) []byte {
+		if k == "" {
+			return []byte{}
+		}
+		return codec.EncodeBytes([]byte{}, []byte(k))
+	}
+	region := func(id uint64, start, end string) *restore.RegionInfo {
+		return &restore.RegionInfo{Region: &metapb.Region{Id: id, StartKey: key(start), EndKey: key(end)}}
+	}
+
+	err := restore.CheckRegionConsistency(key("a"), key("c"), nil)
+	c.Assert(err, ErrorMatches, ".*scan region return empty result.*")
+	err = restore.CheckRegionConsistency(key("a"), key("c"), []*restore.RegionInfo{region(1, "b", "d")})
+	c.Assert(err, ErrorMatches, ".*first region 1's startKey > startKey.*")
+	err = restore.CheckRegionConsistency(key("a"), key("c"), []*restore.RegionInfo{region(1, "", "b")})
+	c.Assert(err, ErrorMatches, ".*last region 1's endKey < endKey.*")
+	// an empty end key means the max.
+	err = restore.CheckRegionConsistency(key("a"), key(""), []*restore.RegionInfo{region(1, "", "b")})
+	c.Assert(err, ErrorMatches, ".*last region 1's endKey < endKey.*")
+	err = restore.CheckRegionConsistency(key("a"), key("c"),
+		[]*restore.RegionInfo{region(1, "", "b"), region(2, "bb", "")})
+	c.Assert(err, ErrorMatches, ".*region 1's endKey not equal to next region 2's startKey.*")
+	err = restore.CheckRegionConsistency(key("a"), key(""),
+		[]*restore.RegionInfo{region(1, "", "b"), region(2, "b", "")})
+	c.Assert(err, IsNil)
+}
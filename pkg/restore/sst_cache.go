@@ -0,0 +1,244 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+// sstCacheTempSuffix is the suffix of the files being fetched, which are
+// renamed once completed, so TiKV never reads a partial file.
+const sstCacheTempSuffix = ".fetching"
+
+// SSTCache caches the SST files of the external storage in a local directory
+// shared with TiKV, e.g. a NFS mounted at the same path on BR and all the TiKV
+// nodes. The retried downloads read the files from the cache instead of the
+// external storage, which cuts the egress of the object storage when the
+// downloads fail repeatedly. The least recently used files are evicted when
+// the total size exceeds the capacity.
+//
+// The files of a backup are cached in the subdirectory of the backup, see
+// SSTCacheKey, since the files of different backups may share the names.
+type SSTCache struct {
+	dir      string
+	capacity uint64
+
+	mu   sync.Mutex
+	size uint64
+	// lru is the list of *sstCacheEntry, the front is the most recently used.
+	lru      *list.List
+	entries  map[string]*list.Element
+	fetching singleflight.Group
+}
+
+type sstCacheEntry struct {
+	name string
+	size uint64
+}
+
+// NewSSTCache creates a SSTCache in the directory. The files cached by the
+// previous restores are kept, in the order of the modification time.
+func NewSSTCache(dir string, capacity uint64) (*SSTCache, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Annotatef(berrors.ErrInvalidArgument, "failed to create the SST cache %s: %s", dir, err)
+	}
+	c := &SSTCache{
+		dir:      dir,
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	type cachedFile struct {
+		name string
+		info os.FileInfo
+	}
+	var files []cachedFile
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if strings.HasSuffix(path, sstCacheTempSuffix) {
+			// left by an interrupted fetch.
+			return os.Remove(path)
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, cachedFile{name: filepath.ToSlash(name), info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		c.addLocked(f.name, uint64(f.info.Size()))
+	}
+	log.Info("SST cache loaded", zap.String("dir", dir), zap.Int("files", c.lru.Len()),
+		zap.Uint64("size", c.size), zap.Uint64("capacity", capacity))
+	return c, nil
+}
+
+// SSTCacheKey returns the key of the backup in the cache, which is the
+// subdirectory its files are cached in. The backups are told apart by the
+// storage and the backup TS.
+func SSTCacheKey(storageURI string, backupTS uint64) string {
+	sum := sha256.Sum256([]byte(storageURI))
+	return fmt.Sprintf("%x-%d", sum[:8], backupTS)
+}
+
+// Backend returns the storage backend TiKV downloads the cached files of the
+// backup from.
+func (c *SSTCache) Backend(key string) *backuppb.StorageBackend {
+	return &backuppb.StorageBackend{
+		Backend: &backuppb.StorageBackend_Local{Local: &backuppb.Local{Path: filepath.Join(c.dir, key)}},
+	}
+}
+
+// Contains checks whether the file of the backup is cached, and marks it as
+// recently used. The cached file of a different size is dropped.
+func (c *SSTCache) Contains(key string, file *backuppb.File) bool {
+	name := path.Join(key, file.GetName())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[name]
+	if !ok {
+		return false
+	}
+	if size := elem.Value.(*sstCacheEntry).size; file.GetSize_() != 0 && size != file.GetSize_() {
+		log.Warn("the cached file doesn't match the backup, drop it", zap.String("name", name),
+			zap.Uint64("size", size), zap.Uint64("expected", file.GetSize_()))
+		c.removeLocked(elem)
+		return false
+	}
+	c.lru.MoveToFront(elem)
+	return true
+}
+
+// Fetch caches the file of the backup in the external storage, the concurrent
+// fetches of the same file read the external storage only once. The fetched
+// file is verified by its size and sha256.
+func (c *SSTCache) Fetch(ctx context.Context, s storage.ExternalStorage, key string, file *backuppb.File) error {
+	if c.Contains(key, file) {
+		return nil
+	}
+	name := path.Join(key, file.GetName())
+	_, err, _ := c.fetching.Do(name, func() (interface{}, error) {
+		if c.Contains(key, file) {
+			return nil, nil
+		}
+		size, err := c.fetch(ctx, s, name, file)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.addLocked(name, size)
+		return nil, nil
+	})
+	return errors.Trace(err)
+}
+
+func (c *SSTCache) fetch(ctx context.Context, s storage.ExternalStorage, name string, file *backuppb.File) (uint64, error) {
+	cachePath := c.path(name)
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return 0, errors.Trace(err)
+	}
+	r, err := s.Open(ctx, file.GetName())
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer r.Close()
+
+	tmpPath := fmt.Sprintf("%s%s", cachePath, sstCacheTempSuffix)
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && uint64(size) > c.capacity {
+		err = errors.Annotatef(berrors.ErrInvalidArgument,
+			"the file %s of %d bytes is larger than the SST cache", name, size)
+	}
+	if err == nil && file.GetSize_() != 0 && uint64(size) != file.GetSize_() {
+		err = errors.Annotatef(berrors.ErrRestoreChecksumMismatch,
+			"the file %s is of %d bytes, expected %d bytes", name, size, file.GetSize_())
+	}
+	if sum := h.Sum(nil); err == nil && len(file.GetSha256()) != 0 && !bytes.Equal(sum, file.GetSha256()) {
+		err = errors.Annotatef(berrors.ErrRestoreChecksumMismatch,
+			"the file %s has sha256 %x, expected %x", name, sum, file.GetSha256())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, cachePath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, errors.Trace(err)
+	}
+	return uint64(size), nil
+}
+
+func (c *SSTCache) path(name string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(name))
+}
+
+// addLocked adds the file as the most recently used, and evicts the least
+// recently used ones if the cache is full.
+func (c *SSTCache) addLocked(name string, size uint64) {
+	if elem, ok := c.entries[name]; ok {
+		c.size -= elem.Value.(*sstCacheEntry).size
+		c.lru.Remove(elem)
+	}
+	c.entries[name] = c.lru.PushFront(&sstCacheEntry{name: name, size: size})
+	c.size += size
+	for c.size > c.capacity && c.lru.Len() > 1 {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+// removeLocked removes the file from the cache.
+func (c *SSTCache) removeLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*sstCacheEntry)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+	if err := os.Remove(c.path(entry.name)); err != nil && !os.IsNotExist(err) {
+		log.Warn("failed to evict the file from the SST cache", zap.String("name", entry.name), zap.Error(err))
+	}
+}
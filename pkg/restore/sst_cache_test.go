@@ -0,0 +1,124 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type testSSTCacheSuite struct{}
+
+var _ = Suite(&testSSTCacheSuite{})
+
+func writeSST(c *C, s storage.ExternalStorage, name string, content []byte) *backuppb.File {
+	c.Assert(s.WriteFile(context.Background(), name, content), IsNil)
+	sum := sha256.Sum256(content)
+	return &backuppb.File{Name: name, Sha256: sum[:], Size_: uint64(len(content))}
+}
+
+func (s *testSSTCacheSuite) TestFetch(c *C) {
+	ctx := context.Background()
+	external, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	file := writeSST(c, external, "1_2.sst", bytes.Repeat([]byte("a"), 10))
+
+	dir := c.MkDir()
+	cache, err := restore.NewSSTCache(dir, 100)
+	c.Assert(err, IsNil)
+	key := restore.SSTCacheKey(external.URI(), 42)
+	c.Assert(cache.Backend(key).GetLocal().GetPath(), Equals, filepath.Join(dir, key))
+	c.Assert(cache.Contains(key, file), IsFalse)
+
+	c.Assert(cache.Fetch(ctx, external, key, file), IsNil)
+	c.Assert(cache.Contains(key, file), IsTrue)
+	content, err := os.ReadFile(filepath.Join(dir, key, "1_2.sst"))
+	c.Assert(err, IsNil)
+	c.Assert(content, DeepEquals, bytes.Repeat([]byte("a"), 10))
+
+	// the missing file isn't cached.
+	missing := &backuppb.File{Name: "missing.sst"}
+	c.Assert(cache.Fetch(ctx, external, key, missing), NotNil)
+	c.Assert(cache.Contains(key, missing), IsFalse)
+}
+
+func (s *testSSTCacheSuite) TestBackupIdentity(c *C) {
+	ctx := context.Background()
+	external1, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	external2, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	file1 := writeSST(c, external1, "1_2.sst", bytes.Repeat([]byte("a"), 10))
+	file2 := writeSST(c, external2, "1_2.sst", bytes.Repeat([]byte("b"), 20))
+
+	cache, err := restore.NewSSTCache(c.MkDir(), 100)
+	c.Assert(err, IsNil)
+	key1 := restore.SSTCacheKey(external1.URI(), 42)
+	key2 := restore.SSTCacheKey(external2.URI(), 42)
+	c.Assert(key1, Not(Equals), key2)
+	c.Assert(key1, Not(Equals), restore.SSTCacheKey(external1.URI(), 43))
+
+	// the file of the same name in another backup isn't a hit.
+	c.Assert(cache.Fetch(ctx, external1, key1, file1), IsNil)
+	c.Assert(cache.Contains(key2, file2), IsFalse)
+	c.Assert(cache.Fetch(ctx, external2, key2, file2), IsNil)
+	c.Assert(cache.Contains(key1, file1), IsTrue)
+	c.Assert(cache.Contains(key2, file2), IsTrue)
+
+	// the cached file not matching the backup file is dropped.
+	resized := &backuppb.File{Name: "1_2.sst", Sha256: file1.Sha256, Size_: 11}
+	c.Assert(cache.Contains(key1, resized), IsFalse)
+	c.Assert(cache.Contains(key1, file1), IsFalse)
+
+	// the corrupted file isn't cached.
+	corrupted := &backuppb.File{Name: "1_2.sst", Sha256: file2.Sha256, Size_: file1.Size_}
+	c.Assert(cache.Fetch(ctx, external1, key1, corrupted), ErrorMatches, ".*sha256.*")
+	c.Assert(cache.Contains(key1, corrupted), IsFalse)
+}
+
+func (s *testSSTCacheSuite) TestEvict(c *C) {
+	ctx := context.Background()
+	external, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	files := make(map[string]*backuppb.File)
+	for _, name := range []string{"a.sst", "b.sst", "c.sst"} {
+		files[name] = writeSST(c, external, name, make([]byte, 40))
+	}
+	files["large.sst"] = writeSST(c, external, "large.sst", make([]byte, 101))
+
+	dir := c.MkDir()
+	cache, err := restore.NewSSTCache(dir, 100)
+	c.Assert(err, IsNil)
+	key := restore.SSTCacheKey(external.URI(), 42)
+	c.Assert(cache.Fetch(ctx, external, key, files["a.sst"]), IsNil)
+	c.Assert(cache.Fetch(ctx, external, key, files["b.sst"]), IsNil)
+	// a.sst becomes the most recently used, b.sst is evicted.
+	c.Assert(cache.Contains(key, files["a.sst"]), IsTrue)
+	c.Assert(cache.Fetch(ctx, external, key, files["c.sst"]), IsNil)
+	c.Assert(cache.Contains(key, files["b.sst"]), IsFalse)
+	c.Assert(cache.Contains(key, files["a.sst"]), IsTrue)
+	c.Assert(cache.Contains(key, files["c.sst"]), IsTrue)
+	_, err = os.Stat(filepath.Join(dir, key, "b.sst"))
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	// the file larger than the cache is refused.
+	c.Assert(cache.Fetch(ctx, external, key, files["large.sst"]), NotNil)
+	c.Assert(cache.Contains(key, files["large.sst"]), IsFalse)
+	_, err = os.Stat(filepath.Join(dir, key, "large.sst.fetching"))
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	// the cached files are kept by the next restore.
+	cache, err = restore.NewSSTCache(dir, 100)
+	c.Assert(err, IsNil)
+	c.Assert(cache.Contains(key, files["a.sst"]), IsTrue)
+	c.Assert(cache.Contains(key, files["c.sst"]), IsTrue)
+}
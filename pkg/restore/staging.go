@@ -0,0 +1,222 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+)
+
+const (
+	// stagingRuleIndex overrides the default placement rule, like the rules
+	// of the online restore.
+	stagingRuleIndex = 100
+
+	replicatedCheckInterval = 10 * time.Second
+)
+
+// EnableStagingReplicas makes the tables restored with fewer replicas, which
+// speeds up the ingest. The other replicas are added by PD after the tables
+// are restored, see WaitReplicated. The replicas must be fewer than the ones
+// of the default placement rule, which is checked by CheckStagingReplicas.
+func (rc *Client) EnableStagingReplicas(replicas int) {
+	rc.stagingReplicas = replicas
+	rc.stagingTables = make(map[int64]struct{})
+}
+
+// CheckStagingReplicas checks the staging replicas are fewer than the replicas
+// of the default placement rule.
+func (rc *Client) CheckStagingReplicas(ctx context.Context) error {
+	if rc.stagingReplicas == 0 {
+		return nil
+	}
+	rule, err := rc.toolClient.GetPlacementRule(ctx, "pd", "default")
+	if err != nil {
+		return errors.Annotate(err, "failed to get the default placement rule for the staging replicas")
+	}
+	if rc.stagingReplicas >= rule.Count {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"the staging replicas %d must be fewer than the %d replicas of the default placement rule",
+			rc.stagingReplicas, rule.Count)
+	}
+	return nil
+}
+
+func encodedTableRange(physicalID int64) (startKey, endKey []byte) {
+	return codec.EncodeBytes([]byte{}, tablecodec.EncodeTablePrefix(physicalID)),
+		codec.EncodeBytes([]byte{}, tablecodec.EncodeTablePrefix(physicalID+1))
+}
+
+func stagingRuleID(physicalID int64) string {
+	return "restore-staging-t" + strconv.FormatInt(physicalID, 10)
+}
+
+// SetupStagingRules sets the placement rules restoring the tables with the
+// staging replicas.
+func (rc *Client) SetupStagingRules(ctx context.Context, tables []*model.TableInfo) error {
+	if rc.stagingReplicas == 0 || len(tables) == 0 {
+		return nil
+	}
+	rule, err := rc.toolClient.GetPlacementRule(ctx, "pd", "default")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	rule.Index = stagingRuleIndex
+	rule.Override = true
+	rule.Count = rc.stagingReplicas
+	for _, t := range tables {
+		for _, id := range physicalIDs(t) {
+			rule.ID = stagingRuleID(id)
+			start, end := encodedTableRange(id)
+			rule.StartKeyHex, rule.EndKeyHex = hex.EncodeToString(start), hex.EncodeToString(end)
+			if err = rc.toolClient.SetPlacementRule(ctx, rule); err != nil {
+				return errors.Trace(err)
+			}
+			rc.stagingMu.Lock()
+			rc.stagingTables[id] = struct{}{}
+			rc.stagingMu.Unlock()
+		}
+	}
+	log.Info("set staging placement rules", zap.Int("replicas", rc.stagingReplicas), zap.Int("tables", len(tables)))
+	return nil
+}
+
+// ResetStagingRules removes the staging placement rules of the tables, so
+// that PD adds the other replicas by the default placement rule.
+func (rc *Client) ResetStagingRules(ctx context.Context, tables []*model.TableInfo) error {
+	if rc.stagingReplicas == 0 {
+		return nil
+	}
+	var ids []int64
+	for _, t := range tables {
+		ids = append(ids, physicalIDs(t)...)
+	}
+	return errors.Trace(rc.deleteStagingRules(ctx, ids))
+}
+
+// CleanupStagingRules removes the staging placement rules left, e.g. by a
+// failed restore, which would keep the tables with the staging replicas. It
+// should be deferred once the staging replicas are enabled.
+func (rc *Client) CleanupStagingRules(ctx context.Context) error {
+	if rc.stagingReplicas == 0 {
+		return nil
+	}
+	rc.stagingMu.Lock()
+	ids := make([]int64, 0, len(rc.stagingTables))
+	for id := range rc.stagingTables {
+		ids = append(ids, id)
+	}
+	rc.stagingMu.Unlock()
+	if len(ids) > 0 {
+		log.Info("clean up the staging placement rules left", zap.Int("rules", len(ids)))
+	}
+	return errors.Trace(rc.deleteStagingRules(ctx, ids))
+}
+
+func (rc *Client) deleteStagingRules(ctx context.Context, ids []int64) error {
+	var failedRules []string
+	for _, id := range ids {
+		if err := rc.toolClient.DeletePlacementRule(ctx, "pd", stagingRuleID(id)); err != nil {
+			log.Warn("failed to delete staging placement rule", zap.Int64("physicalID", id), zap.Error(err))
+			failedRules = append(failedRules, stagingRuleID(id))
+			continue
+		}
+		rc.stagingMu.Lock()
+		delete(rc.stagingTables, id)
+		rc.stagingMu.Unlock()
+	}
+	if len(failedRules) > 0 {
+		return errors.Annotatef(berrors.ErrPDInvalidResponse,
+			"failed to delete the staging placement rules %v of group pd, please delete them manually", failedRules)
+	}
+	return nil
+}
+
+// WaitReplicated waits until the regions of the tables have the voters of the
+// default placement rule, after the staging placement rules are removed. The
+// progress is increased once for each physical table replicated. It fails if
+// the tables aren't replicated in the timeout, PD keeps adding the replicas
+// then.
+func (rc *Client) WaitReplicated(ctx context.Context, ids []int64, timeout time.Duration, progress glue.Progress) error {
+	if rc.stagingReplicas == 0 {
+		return nil
+	}
+	parentCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	rule, err := rc.toolClient.GetPlacementRule(ctx, "pd", "default")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("start waiting the restored tables to be replicated",
+		zap.Int("replicas", rule.Count), zap.Int("tables", len(ids)))
+	ticker := time.NewTicker(replicatedCheckInterval)
+	defer ticker.Stop()
+	pending := append([]int64(nil), ids...)
+	for {
+		remaining := pending[:0]
+		for _, id := range pending {
+			ok, err := rc.isReplicated(ctx, id, rule.Count)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if ok {
+				progress.Inc()
+			} else {
+				remaining = append(remaining, id)
+			}
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			log.Info("the restored tables are replicated")
+			return nil
+		}
+		log.Info("waiting the restored tables to be replicated", zap.Int("pending", len(pending)))
+		select {
+		case <-ctx.Done():
+			if parentCtx.Err() == nil {
+				return errors.Annotatef(berrors.ErrRestoreNotReplicated,
+					"%d restored tables aren't replicated in %s, PD keeps adding the replicas", len(pending), timeout)
+			}
+			return errors.Trace(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (rc *Client) isReplicated(ctx context.Context, physicalID int64, voters int) (bool, error) {
+	start, end := encodedTableRange(physicalID)
+	regions, err := rc.toolClient.ScanRegions(ctx, start, end, -1)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	for _, r := range regions {
+		if countVoters(r.Region) < voters {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func countVoters(region *metapb.Region) int {
+	voters := 0
+	for _, p := range region.GetPeers() {
+		if p.GetRole() == metapb.PeerRole_Voter {
+			voters++
+		}
+	}
+	return voters
+}
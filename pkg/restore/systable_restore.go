@@ -15,6 +15,7 @@ import (
 	"go.uber.org/zap"
 
 	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
 	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/utils"
 )
@@ -57,6 +58,10 @@ func isUnrecoverableTable(tableName string) bool {
 	return ok
 }
 
+// bindingsTable is the table of the SQL bindings, whose rows are merged into
+// the existing ones instead of replacing, since it has no unique key.
+const bindingsTable = "bind_info"
+
 func isStatsTable(tableName string) bool {
 	_, ok := statsTables[tableName]
 	return ok
@@ -139,11 +144,19 @@ func (rc *Client) afterSystemTablesReplaced(ctx context.Context, tables []string
 	for _, table := range tables {
 		switch {
 		case table == "user":
-			// We cannot execute `rc.dom.NotifyUpdatePrivilege` here, because there isn't
-			// sessionctx.Context provided by the glue.
-			// TODO: update the glue type and allow we retrive a session context from it.
+			// `rc.dom.NotifyUpdatePrivilege` requires a sessionctx.Context, which is
+			// only provided by the glues running an in-process TiDB session.
+			if sp, ok := rc.db.se.(glue.SessionCtxProvider); ok && rc.dom != nil {
+				rc.dom.NotifyUpdatePrivilege(sp.GetSessionCtx())
+				continue
+			}
 			err = multierr.Append(err, errors.Annotatef(berrors.ErrUnsupportedSystemTable,
 				"restored user info may not take effect, until you should execute `FLUSH PRIVILEGES` manually"))
+		case table == bindingsTable:
+			if e := rc.db.se.Execute(ctx, "ADMIN RELOAD BINDINGS"); e != nil {
+				err = multierr.Append(err, errors.Annotatef(e,
+					"restored bindings may not take effect, until you execute `ADMIN RELOAD BINDINGS` manually"))
+			}
 		}
 	}
 	return err
@@ -187,6 +200,21 @@ func (rc *Client) replaceTemporaryTableToSystable(ctx context.Context, tableName
 		return berrors.ErrUnsupportedSystemTable.GenWithStack("restoring unsupported `mysql` schema table")
 	}
 
+	if db.ExistingTables[tableName] != nil && tableName == bindingsTable {
+		log.Info("table existing, merging the bindings not existing",
+			zap.String("table", tableName),
+			zap.Stringer("schema", db.Name))
+		// the builtin binding lock row exists in every cluster, so it's
+		// skipped as well.
+		insertSQL := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s t WHERE NOT EXISTS "+
+			"(SELECT 1 FROM %s b WHERE b.original_sql = t.original_sql AND b.bind_sql = t.bind_sql "+
+			"AND b.default_db = t.default_db);",
+			utils.EncloseDBAndTable(db.Name.L, tableName),
+			utils.EncloseDBAndTable(db.TemporaryName.L, tableName),
+			utils.EncloseDBAndTable(db.Name.L, tableName))
+		return execSQL(insertSQL)
+	}
+
 	if db.ExistingTables[tableName] != nil {
 		log.Info("table existing, using replace into for restore",
 			zap.String("table", tableName),
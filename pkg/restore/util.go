@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/glue"
 	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/redact"
 	"github.com/pingcap/br/pkg/rtree"
 	"github.com/pingcap/br/pkg/summary"
 	"github.com/pingcap/br/pkg/utils"
@@ -39,45 +41,186 @@ var (
 func GetRewriteRules(
 	newTable, oldTable *model.TableInfo, newTimeStamp uint64,
 ) *RewriteRules {
-	tableIDs := make(map[int64]int64)
-	tableIDs[oldTable.ID] = newTable.ID
+	return NewTableRewrite("", newTable, oldTable).RewriteRules(newTimeStamp)
+}
+
+// ValidateTableRewrite checks that the rewrite rules from the old table to the
+// new table cover every partition and index of the old table, and that the
+// columns keep their IDs, since the rows are encoded by column IDs.
+// In incremental restore, the new table is the result of replaying the DDL
+// jobs, so a mismatch means the replayed schema differs from the backed up one.
+func ValidateTableRewrite(newTable, oldTable *model.TableInfo) error {
 	if oldTable.Partition != nil {
+		if newTable.Partition == nil {
+			return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+				"table %s is partitioned in the backup but not in the cluster", oldTable.Name)
+		}
 		for _, srcPart := range oldTable.Partition.Definitions {
+			found := false
 			for _, destPart := range newTable.Partition.Definitions {
 				if srcPart.Name == destPart.Name {
-					tableIDs[srcPart.ID] = destPart.ID
+					found = true
+					break
 				}
 			}
+			if !found {
+				return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+					"partition %s of table %s does not exist in the cluster", srcPart.Name, oldTable.Name)
+			}
 		}
 	}
-	indexIDs := make(map[int64]int64)
 	for _, srcIndex := range oldTable.Indices {
+		found := false
 		for _, destIndex := range newTable.Indices {
 			if srcIndex.Name == destIndex.Name {
-				indexIDs[srcIndex.ID] = destIndex.ID
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+				"index %s of table %s does not exist in the cluster", srcIndex.Name, oldTable.Name)
+		}
+	}
+	for _, srcCol := range oldTable.Columns {
+		var destCol *model.ColumnInfo
+		for _, col := range newTable.Columns {
+			if col.Name.L == srcCol.Name.L {
+				destCol = col
+				break
+			}
+		}
+		if destCol == nil {
+			return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+				"column %s of table %s does not exist in the cluster", srcCol.Name, oldTable.Name)
+		}
+		if destCol.ID != srcCol.ID {
+			return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+				"column %s of table %s has ID %d in the backup but %d in the cluster",
+				srcCol.Name, oldTable.Name, srcCol.ID, destCol.ID)
+		}
+	}
+	return nil
+}
+
+// ValidateRewriteRules cross-checks the rewrite rules of a table against the
+// created table before ingesting, so a drift between the backed up and the
+// created schema fails the restore with the cause, instead of a checksum
+// mismatch afterwards. The drift usually comes from creating the table in a
+// TiDB of another version, e.g. an index dropped by the DDL or a primary key
+// no longer being the row handle.
+//
+// Every row and index of the old table must be rewritten, into a row or index
+// of the new table in the same physical table, and the indices must keep their
+// columns, since the index keys are encoded from them.
+func ValidateRewriteRules(rules *RewriteRules, newTable, oldTable *model.TableInfo) error {
+	if newTable.PKIsHandle != oldTable.PKIsHandle || newTable.IsCommonHandle != oldTable.IsCommonHandle {
+		return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+			"table %s has the handle (int handle = %v, clustered = %v) in the backup but (int handle = %v, clustered = %v) in the cluster",
+			oldTable.Name, oldTable.PKIsHandle, oldTable.IsCommonHandle, newTable.PKIsHandle, newTable.IsCommonHandle)
+	}
+
+	newRows := make(map[string]struct{})
+	newIndices := make(map[string]*model.IndexInfo)
+	for _, id := range physicalIDs(newTable) {
+		newRows[string(append(tablecodec.EncodeTablePrefix(id), recordPrefixSep...))] = struct{}{}
+		for _, index := range newTable.Indices {
+			newIndices[string(tablecodec.EncodeTableIndexPrefix(id, index.ID))] = index
+		}
+	}
+	// oldPrefixes maps the prefixes of the old table to their descriptions,
+	// the entries are removed once rewritten.
+	oldPrefixes := make(map[string]string)
+	oldIndices := make(map[string]*model.IndexInfo)
+	for _, id := range physicalIDs(oldTable) {
+		oldPrefixes[string(append(tablecodec.EncodeTablePrefix(id), recordPrefixSep...))] = fmt.Sprintf("the rows of table %d", id)
+		for _, index := range oldTable.Indices {
+			prefix := string(tablecodec.EncodeTableIndexPrefix(id, index.ID))
+			oldPrefixes[prefix] = fmt.Sprintf("index %s of table %d", index.Name, id)
+			oldIndices[prefix] = index
+		}
+	}
+
+	for _, rule := range rules.Data {
+		oldPrefix, newPrefix := string(rule.GetOldKeyPrefix()), string(rule.GetNewKeyPrefix())
+		desc, ok := oldPrefixes[oldPrefix]
+		if !ok {
+			return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+				"rewrite rule from %s of table %s doesn't belong to the table",
+				redact.Key(rule.GetOldKeyPrefix()), oldTable.Name)
+		}
+		if oldIndex, isIndex := oldIndices[oldPrefix]; isIndex {
+			newIndex, ok := newIndices[newPrefix]
+			if !ok {
+				return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+					"%s of table %s is rewritten to %s, which is not an index of the created table",
+					desc, oldTable.Name, redact.Key(rule.GetNewKeyPrefix()))
+			}
+			if diff := diffIndexColumns(newIndex, oldIndex); diff != "" {
+				return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+					"%s of table %s is rewritten to index %s, but %s",
+					desc, oldTable.Name, newIndex.Name, diff)
+			}
+		} else if _, ok := newRows[newPrefix]; !ok {
+			return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+				"%s of table %s is rewritten to %s, which is not the rows of the created table",
+				desc, oldTable.Name, redact.Key(rule.GetNewKeyPrefix()))
+		}
+		// the rows and the indices of a partition must stay together.
+		oldID, newID := tablecodec.DecodeTableID(rule.GetOldKeyPrefix()), tablecodec.DecodeTableID(rule.GetNewKeyPrefix())
+		recordRule := string(append(tablecodec.EncodeTablePrefix(oldID), recordPrefixSep...))
+		for _, other := range rules.Data {
+			if string(other.GetOldKeyPrefix()) == recordRule && tablecodec.DecodeTableID(other.GetNewKeyPrefix()) != newID {
+				return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+					"%s of table %s is rewritten to table %d, but the rows to table %d",
+					desc, oldTable.Name, newID, tablecodec.DecodeTableID(other.GetNewKeyPrefix()))
 			}
 		}
+		delete(oldPrefixes, oldPrefix)
 	}
 
-	dataRules := make([]*import_sstpb.RewriteRule, 0)
-	for oldTableID, newTableID := range tableIDs {
-		dataRules = append(dataRules, &import_sstpb.RewriteRule{
-			OldKeyPrefix: append(tablecodec.EncodeTablePrefix(oldTableID), recordPrefixSep...),
-			NewKeyPrefix: append(tablecodec.EncodeTablePrefix(newTableID), recordPrefixSep...),
-			NewTimestamp: newTimeStamp,
-		})
-		for oldIndexID, newIndexID := range indexIDs {
-			dataRules = append(dataRules, &import_sstpb.RewriteRule{
-				OldKeyPrefix: tablecodec.EncodeTableIndexPrefix(oldTableID, oldIndexID),
-				NewKeyPrefix: tablecodec.EncodeTableIndexPrefix(newTableID, newIndexID),
-				NewTimestamp: newTimeStamp,
-			})
+	if len(oldPrefixes) > 0 {
+		missing := make([]string, 0, len(oldPrefixes))
+		for _, desc := range oldPrefixes {
+			missing = append(missing, desc)
 		}
+		sort.Strings(missing)
+		return errors.Annotatef(berrors.ErrRestoreInvalidRewrite,
+			"%s of table %s are not rewritten, the created table may lack them",
+			strings.Join(missing, ", "), oldTable.Name)
 	}
+	return nil
+}
 
-	return &RewriteRules{
-		Data: dataRules,
+// physicalIDs returns the IDs of the table, and of its partitions if any.
+func physicalIDs(table *model.TableInfo) []int64 {
+	ids := []int64{table.ID}
+	if table.Partition != nil {
+		for _, def := range table.Partition.Definitions {
+			ids = append(ids, def.ID)
+		}
+	}
+	return ids
+}
+
+// diffIndexColumns describes how the new index differs from the old index in
+// the encoding of the keys, or returns "" if they are encoded alike.
+func diffIndexColumns(newIndex, oldIndex *model.IndexInfo) string {
+	if newIndex.Unique != oldIndex.Unique || newIndex.Primary != oldIndex.Primary {
+		return fmt.Sprintf("it is (unique = %v, primary = %v) in the backup but (unique = %v, primary = %v) in the cluster",
+			oldIndex.Unique, oldIndex.Primary, newIndex.Unique, newIndex.Primary)
+	}
+	if len(newIndex.Columns) != len(oldIndex.Columns) {
+		return fmt.Sprintf("it has %d columns in the backup but %d in the cluster", len(oldIndex.Columns), len(newIndex.Columns))
+	}
+	for i, oldCol := range oldIndex.Columns {
+		newCol := newIndex.Columns[i]
+		if newCol.Name.L != oldCol.Name.L || newCol.Length != oldCol.Length {
+			return fmt.Sprintf("its column #%d is %s(%d) in the backup but %s(%d) in the cluster",
+				i, oldCol.Name, oldCol.Length, newCol.Name, newCol.Length)
+		}
 	}
+	return ""
 }
 
 // GetSSTMetaFromFile compares the keys in file, region and rewrite rules, then returns a sst conn.
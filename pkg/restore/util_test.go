@@ -10,6 +10,7 @@ import (
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/kvproto/pkg/import_sstpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/tablecodec"
 	"github.com/pingcap/tidb/util/codec"
 
@@ -167,6 +168,107 @@ func (s *testRestoreUtilSuite) TestValidateFileRewriteRule(c *C) {
 	c.Assert(err, ErrorMatches, ".*unexpected rewrite rules.*")
 }
 
+func (s *testRestoreUtilSuite) TestValidateTableRewrite(c *C) {
+	newTableInfo := func() *model.TableInfo {
+		return &model.TableInfo{
+			ID:   1,
+			Name: model.NewCIStr("t"),
+			Columns: []*model.ColumnInfo{
+				{ID: 1, Name: model.NewCIStr("a")},
+				{ID: 2, Name: model.NewCIStr("b")},
+			},
+			Indices: []*model.IndexInfo{{ID: 1, Name: model.NewCIStr("idx_a")}},
+			Partition: &model.PartitionInfo{
+				Definitions: []model.PartitionDefinition{{ID: 2, Name: model.NewCIStr("p0")}},
+			},
+		}
+	}
+	oldTable := newTableInfo()
+	newTable := newTableInfo()
+	newTable.ID = 10
+	newTable.Partition.Definitions[0].ID = 11
+	newTable.Indices[0].ID = 3
+	// a column added after the backup doesn't matter.
+	newTable.Columns = append(newTable.Columns, &model.ColumnInfo{ID: 3, Name: model.NewCIStr("c")})
+	c.Assert(restore.ValidateTableRewrite(newTable, oldTable), IsNil)
+
+	newTable.Columns[1].ID = 4
+	err := restore.ValidateTableRewrite(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "column b of table t has ID 2 in the backup but 4 in the cluster.*")
+
+	newTable.Columns = newTable.Columns[:1]
+	err = restore.ValidateTableRewrite(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "column b of table t does not exist in the cluster.*")
+
+	newTable.Indices = nil
+	err = restore.ValidateTableRewrite(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "index idx_a of table t does not exist in the cluster.*")
+
+	newTable.Partition.Definitions[0].Name = model.NewCIStr("p1")
+	err = restore.ValidateTableRewrite(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "partition p0 of table t does not exist in the cluster.*")
+
+	newTable.Partition = nil
+	err = restore.ValidateTableRewrite(newTable, oldTable)
+	c.Assert(err, ErrorMatches, "table t is partitioned in the backup but not in the cluster.*")
+}
+
+func (s *testRestoreUtilSuite) TestValidateRewriteRules(c *C) {
+	newTableInfo := func() *model.TableInfo {
+		return &model.TableInfo{
+			ID:         1,
+			Name:       model.NewCIStr("t"),
+			PKIsHandle: true,
+			Indices: []*model.IndexInfo{{
+				ID:      1,
+				Name:    model.NewCIStr("idx_a"),
+				Columns: []*model.IndexColumn{{Name: model.NewCIStr("a")}},
+			}},
+			Partition: &model.PartitionInfo{
+				Definitions: []model.PartitionDefinition{{ID: 2, Name: model.NewCIStr("p0")}},
+			},
+		}
+	}
+	oldTable := newTableInfo()
+	newTable := newTableInfo()
+	newTable.ID = 10
+	newTable.Partition.Definitions[0].ID = 11
+	newTable.Indices[0].ID = 3
+	rules := restore.GetRewriteRules(newTable, oldTable, 0)
+	c.Assert(restore.ValidateRewriteRules(rules, newTable, oldTable), IsNil)
+
+	// the index is dropped by the DDL of the cluster.
+	droppedTable := newTableInfo()
+	droppedTable.ID = 10
+	droppedTable.Indices = nil
+	err := restore.ValidateRewriteRules(restore.GetRewriteRules(droppedTable, oldTable, 0), droppedTable, oldTable)
+	c.Assert(err, ErrorMatches, "index idx_a of table 1, index idx_a of table 2 of table t are not rewritten.*")
+
+	// the index is rewritten to an index of other columns.
+	newTable.Indices[0].Columns[0].Name = model.NewCIStr("b")
+	err = restore.ValidateRewriteRules(rules, newTable, oldTable)
+	c.Assert(err, ErrorMatches, "index idx_a of table [12] of table t is rewritten to index idx_a, "+
+		"but its column #0 is a\\(0\\) in the backup but b\\(0\\) in the cluster.*")
+	newTable.Indices[0].Columns[0].Name = model.NewCIStr("a")
+	newTable.Indices[0].Unique = true
+	err = restore.ValidateRewriteRules(rules, newTable, oldTable)
+	c.Assert(err, ErrorMatches, ".*it is \\(unique = false, primary = false\\) in the backup but \\(unique = true, primary = false\\) in the cluster.*")
+	newTable.Indices[0].Unique = false
+
+	// the rows are rewritten into another table.
+	otherTable := newTableInfo()
+	otherTable.ID = 20
+	otherTable.Partition.Definitions[0].ID = 21
+	err = restore.ValidateRewriteRules(restore.GetRewriteRules(otherTable, oldTable, 0), newTable, oldTable)
+	c.Assert(err, ErrorMatches, ".* of table t is rewritten to .*, which is not .* of the created table.*")
+
+	// the primary key is no longer the handle.
+	newTable.PKIsHandle = false
+	err = restore.ValidateRewriteRules(rules, newTable, oldTable)
+	c.Assert(err, ErrorMatches, "table t has the handle \\(int handle = true, clustered = false\\) in the backup "+
+		"but \\(int handle = false, clustered = false\\) in the cluster.*")
+}
+
 func (s *testRestoreUtilSuite) TestPaginateScanRegion(c *C) {
 	peers := make([]*metapb.Peer, 1)
 	peers[0] = &metapb.Peer{
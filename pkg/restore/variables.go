@@ -0,0 +1,46 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/util/sqlexec"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/audit"
+)
+
+// ApplyGlobalVariables sets the global variables recorded by the backup in
+// the cluster restored into, so that the restored data is interpreted the
+// same as in the backed up cluster. They take effect in the new sessions.
+func (rc *Client) ApplyGlobalVariables(ctx context.Context, vars map[string]string) error {
+	return rc.db.SetGlobalVariables(ctx, vars)
+}
+
+// SetGlobalVariables sets the global variables in the order of the names.
+func (db *DB) SetGlobalVariables(ctx context.Context, vars map[string]string) error {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		query, err := sqlexec.EscapeSQL("SET GLOBAL %n = %?", name, vars[name])
+		if err != nil {
+			return errors.Trace(err)
+		}
+		start := time.Now()
+		err = db.se.Execute(ctx, query)
+		audit.Record(audit.ActionSetGlobalVariable, name, vars[name], start, err)
+		if err != nil {
+			return errors.Annotatef(err, "failed to set the global variable %s", name)
+		}
+		log.Info("set global variable", zap.String("name", name), zap.String("value", vars[name]))
+	}
+	return nil
+}
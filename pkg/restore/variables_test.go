@@ -0,0 +1,53 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+var _ = Suite(&testVariablesSuite{})
+
+type testVariablesSuite struct{}
+
+// execSession is a glue.Session recording the statements executed.
+type execSession struct {
+	batchSession
+	executed []string
+	failOn   string
+}
+
+func (se *execSession) Execute(ctx context.Context, sql string) error {
+	if sql == se.failOn {
+		return errors.New("[variable:1231]Variable can't be set")
+	}
+	se.executed = append(se.executed, sql)
+	return nil
+}
+
+func (s *testVariablesSuite) TestSetGlobalVariables(c *C) {
+	ctx := context.Background()
+	se := &execSession{}
+	db := &DB{se: se}
+	vars := map[string]string{
+		"time_zone": "+08:00",
+		"sql_mode":  "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES",
+	}
+	c.Assert(db.SetGlobalVariables(ctx, vars), IsNil)
+	c.Assert(se.executed, DeepEquals, []string{
+		"SET GLOBAL `sql_mode` = 'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES'",
+		"SET GLOBAL `time_zone` = '+08:00'",
+	})
+
+	// the values are escaped.
+	se.executed = nil
+	c.Assert(db.SetGlobalVariables(ctx, map[string]string{"time_zone": "x'; DROP DATABASE test; --"}), IsNil)
+	c.Assert(se.executed, DeepEquals, []string{"SET GLOBAL `time_zone` = 'x\\'; DROP DATABASE test; --'"})
+
+	se.failOn = "SET GLOBAL `time_zone` = '+08:00'"
+	err := db.SetGlobalVariables(ctx, vars)
+	c.Assert(err, ErrorMatches, "failed to set the global variable time_zone.*")
+}
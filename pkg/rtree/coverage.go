@@ -0,0 +1,180 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package rtree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+)
+
+// The kinds of the ranges in a coverage.
+const (
+	// CoverageCovered is a range covered by the backup files.
+	CoverageCovered = "covered"
+	// CoverageOverlap is a range of the backup files overlapping the previous
+	// ones, which is restored more than once.
+	CoverageOverlap = "overlap"
+	// CoverageGap is a range of the table which no backup file covers.
+	CoverageGap = "gap"
+)
+
+// CoverageRange is a range in a coverage, the keys are in hex.
+type CoverageRange struct {
+	StartKey string `json:"start-key"`
+	EndKey   string `json:"end-key"`
+	Kind     string `json:"kind"`
+	Files    int    `json:"files,omitempty"`
+	Bytes    uint64 `json:"bytes,omitempty"`
+	KVs      uint64 `json:"kvs,omitempty"`
+}
+
+func newCoverageRange(rg *Range, kind string) CoverageRange {
+	bytes, kvs := rg.BytesAndKeys()
+	return CoverageRange{
+		StartKey: hex.EncodeToString(rg.StartKey),
+		EndKey:   hex.EncodeToString(rg.EndKey),
+		Kind:     kind,
+		Files:    len(rg.Files),
+		Bytes:    bytes,
+		KVs:      kvs,
+	}
+}
+
+// TableCoverage is how the backup files of a table cover its key ranges, for
+// inspecting the gaps and overlaps when the checksum mismatches.
+type TableCoverage struct {
+	Name     string          `json:"name"`
+	Ranges   []CoverageRange `json:"ranges"`
+	Gaps     int             `json:"gaps"`
+	Overlaps int             `json:"overlaps"`
+	// SplitRanges are the ranges the restore splits the regions by.
+	SplitRanges []CoverageRange `json:"split-ranges,omitempty"`
+}
+
+// NewTableCoverage builds the coverage of the backup files in the expected
+// key ranges of the table.
+func NewTableCoverage(name string, expected []Range, files []*backuppb.File) TableCoverage {
+	// the files of the column families share the same range.
+	byRange := make(map[string]*Range)
+	ranges := make([]*Range, 0, len(files))
+	for _, file := range files {
+		key := string(file.GetStartKey()) + "\x00" + string(file.GetEndKey())
+		rg, ok := byRange[key]
+		if !ok {
+			rg = &Range{StartKey: file.GetStartKey(), EndKey: file.GetEndKey()}
+			byRange[key] = rg
+			ranges = append(ranges, rg)
+		}
+		rg.Files = append(rg.Files, file)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if c := bytes.Compare(ranges[i].StartKey, ranges[j].StartKey); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(ranges[i].EndKey, ranges[j].EndKey) < 0
+	})
+
+	coverage := TableCoverage{Name: name, Ranges: make([]CoverageRange, 0, len(ranges))}
+	covered := NewRangeTree()
+	var maxEnd []byte
+	for i, rg := range ranges {
+		// an empty end key means the max key.
+		if i > 0 && (len(maxEnd) == 0 || bytes.Compare(rg.StartKey, maxEnd) < 0) {
+			coverage.Ranges = append(coverage.Ranges, newCoverageRange(rg, CoverageOverlap))
+			coverage.Overlaps++
+			if len(maxEnd) != 0 && (len(rg.EndKey) == 0 || bytes.Compare(rg.EndKey, maxEnd) > 0) {
+				// the part beyond the previous ranges is covered as well.
+				covered.InsertRange(Range{StartKey: maxEnd, EndKey: rg.EndKey})
+			}
+		} else {
+			coverage.Ranges = append(coverage.Ranges, newCoverageRange(rg, CoverageCovered))
+			covered.InsertRange(*rg)
+		}
+		if i == 0 || (len(maxEnd) != 0 && (len(rg.EndKey) == 0 || bytes.Compare(rg.EndKey, maxEnd) > 0)) {
+			maxEnd = rg.EndKey
+		}
+	}
+	for _, exp := range expected {
+		for _, gap := range covered.GetIncompleteRange(exp.StartKey, exp.EndKey) {
+			gap := gap
+			coverage.Ranges = append(coverage.Ranges, newCoverageRange(&gap, CoverageGap))
+			coverage.Gaps++
+		}
+	}
+	sort.SliceStable(coverage.Ranges, func(i, j int) bool {
+		return coverage.Ranges[i].StartKey < coverage.Ranges[j].StartKey
+	})
+	return coverage
+}
+
+// SetSplitRanges sets the ranges the restore splits the regions by.
+func (c *TableCoverage) SetSplitRanges(ranges []Range) {
+	c.SplitRanges = make([]CoverageRange, 0, len(ranges))
+	for i := range ranges {
+		c.SplitRanges = append(c.SplitRanges, newCoverageRange(&ranges[i], CoverageCovered))
+	}
+}
+
+const (
+	svgRowHeight  = 20
+	svgRowGap     = 4
+	svgLabelWidth = 240
+	svgRowWidth   = 960
+)
+
+// WriteCoverageSVG draws the coverages as a heatmap, a row per table. The
+// ranges of a row are drawn in the order of the keys with the same width, the
+// darker a covered range is, the more bytes it has. The gaps are red and the
+// overlaps are orange.
+func WriteCoverageSVG(w io.Writer, coverages []TableCoverage) error {
+	var maxBytes uint64
+	for _, c := range coverages {
+		for _, rg := range c.Ranges {
+			if rg.Bytes > maxBytes {
+				maxBytes = rg.Bytes
+			}
+		}
+	}
+	var buf bytes.Buffer
+	height := len(coverages)*(svgRowHeight+svgRowGap) + svgRowGap
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="12">`+"\n",
+		svgLabelWidth+svgRowWidth, height)
+	for row, c := range coverages {
+		y := svgRowGap + row*(svgRowHeight+svgRowGap)
+		fmt.Fprintf(&buf, `<text x="0" y="%d">%s (%d gaps, %d overlaps)</text>`+"\n",
+			y+svgRowHeight-6, html.EscapeString(c.Name), c.Gaps, c.Overlaps)
+		if len(c.Ranges) == 0 {
+			continue
+		}
+		width := float64(svgRowWidth) / float64(len(c.Ranges))
+		for i, rg := range c.Ranges {
+			fmt.Fprintf(&buf, `<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="%s"><title>%s [%s, %s) %d files, %d bytes</title></rect>`+"\n",
+				float64(svgLabelWidth)+float64(i)*width, y, width, svgRowHeight, coverageColor(rg, maxBytes),
+				rg.Kind, rg.StartKey, rg.EndKey, rg.Files, rg.Bytes)
+		}
+	}
+	buf.WriteString("</svg>\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func coverageColor(rg CoverageRange, maxBytes uint64) string {
+	switch rg.Kind {
+	case CoverageGap:
+		return "#d62728"
+	case CoverageOverlap:
+		return "#ff7f0e"
+	}
+	// from light green of the empty ranges to dark green of the largest ones.
+	ratio := 0.0
+	if maxBytes > 0 {
+		ratio = float64(rg.Bytes) / float64(maxBytes)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", int(200-170*ratio), int(240-140*ratio), int(200-170*ratio))
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package rtree_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/rtree"
+)
+
+var _ = Suite(&testCoverageSuite{})
+
+type testCoverageSuite struct{}
+
+func newFile(start, end string, size uint64) *backuppb.File {
+	return &backuppb.File{StartKey: []byte(start), EndKey: []byte(end), TotalBytes: size, TotalKvs: 1}
+}
+
+func (s *testCoverageSuite) TestTableCoverage(c *C) {
+	expected := []rtree.Range{{StartKey: []byte("a"), EndKey: []byte("z")}}
+	files := []*backuppb.File{
+		// the default and write CF of the same range.
+		newFile("a", "c", 10),
+		newFile("a", "c", 20),
+		newFile("b", "d", 10),
+		newFile("f", "z", 10),
+	}
+	coverage := rtree.NewTableCoverage("`db`.`t`", expected, files)
+	c.Assert(coverage.Gaps, Equals, 1)
+	c.Assert(coverage.Overlaps, Equals, 1)
+	kinds := make([]string, 0, len(coverage.Ranges))
+	for _, rg := range coverage.Ranges {
+		kinds = append(kinds, rg.Kind)
+	}
+	c.Assert(kinds, DeepEquals, []string{rtree.CoverageCovered, rtree.CoverageOverlap, rtree.CoverageGap, rtree.CoverageCovered})
+	c.Assert(coverage.Ranges[0].Files, Equals, 2)
+	c.Assert(coverage.Ranges[0].Bytes, Equals, uint64(30))
+	// the gap is between the end of the overlapping file and the start of the last.
+	c.Assert(coverage.Ranges[2].StartKey, Equals, "64")
+	c.Assert(coverage.Ranges[2].EndKey, Equals, "66")
+
+	full := rtree.NewTableCoverage("`db`.`t`", expected, []*backuppb.File{newFile("a", "z", 10)})
+	c.Assert(full.Gaps, Equals, 0)
+	c.Assert(full.Overlaps, Equals, 0)
+
+	var buf bytes.Buffer
+	c.Assert(rtree.WriteCoverageSVG(&buf, []rtree.TableCoverage{coverage, full}), IsNil)
+	svg := buf.String()
+	c.Assert(strings.HasPrefix(svg, "<svg"), IsTrue)
+	c.Assert(strings.Count(svg, "<rect"), Equals, 5)
+	c.Assert(strings.Contains(svg, "`db`.`t` (1 gaps, 1 overlaps)"), IsTrue)
+}
@@ -78,14 +78,37 @@ var _ btree.Item = &Range{}
 
 // RangeTree is sorted tree for Ranges.
 // All the ranges it stored do not overlap.
+//
+// Besides the ranges, it indexes the gaps between them, so finding the overlap
+// of a range and the incomplete ranges costs O(log n) plus the size of the
+// result instead of scanning the whole tree, which matters when there are
+// millions of ranges, e.g. the small ranges of a large backup. The ranges
+// must be mutated by the methods of RangeTree rather than the embedded
+// BTree, otherwise the gaps would be stale.
 type RangeTree struct {
 	*btree.BTree
+
+	gaps *gapIndex
+}
+
+// gapIndex is the ranges of the key space not covered by any range, an empty
+// end key means the max key.
+type gapIndex struct {
+	*btree.BTree
+
+	// stale is set once InsertRange makes the ranges overlap, the gaps are
+	// rebuilt by the next query then.
+	stale bool
 }
 
 // NewRangeTree returns an empty range tree.
 func NewRangeTree() RangeTree {
+	gaps := &gapIndex{BTree: btree.New(32)}
+	// the whole key space is a gap at first.
+	gaps.ReplaceOrInsert(&Range{StartKey: []byte{}, EndKey: []byte{}})
 	return RangeTree{
 		BTree: btree.New(32),
+		gaps:  gaps,
 	}
 }
 
@@ -105,6 +128,32 @@ func (rangeTree *RangeTree) Find(rg *Range) *Range {
 	return ret
 }
 
+// overlapsOthers returns whether the range overlaps the ranges in the tree
+// other than the one starting at the same key.
+func (rangeTree *RangeTree) overlapsOthers(rg *Range) bool {
+	overlapped := false
+	rangeTree.DescendLessOrEqual(rg, func(i btree.Item) bool {
+		prev := i.(*Range)
+		if bytes.Equal(prev.StartKey, rg.StartKey) {
+			return true
+		}
+		overlapped = prev.Contains(rg.StartKey)
+		return false
+	})
+	if overlapped {
+		return true
+	}
+	rangeTree.AscendGreaterOrEqual(rg, func(i btree.Item) bool {
+		next := i.(*Range)
+		if bytes.Equal(next.StartKey, rg.StartKey) {
+			return true
+		}
+		overlapped = len(rg.EndKey) == 0 || bytes.Compare(next.StartKey, rg.EndKey) < 0
+		return false
+	})
+	return overlapped
+}
+
 // getOverlaps gets the ranges which are overlapped with the specified range range.
 func (rangeTree *RangeTree) getOverlaps(rg *Range) []*Range {
 	// note that find() gets the last item that is less or equal than the range.
@@ -141,6 +190,7 @@ func (rangeTree *RangeTree) Update(rg Range) {
 		rangeTree.Delete(item)
 	}
 	rangeTree.ReplaceOrInsert(&rg)
+	rangeTree.resetGaps(rg.StartKey, rg.EndKey)
 }
 
 // Put forms a range and inserts it into tree.
@@ -155,14 +205,150 @@ func (rangeTree *RangeTree) Put(
 	rangeTree.Update(rg)
 }
 
+// PutDedup forms a range and inserts it into tree unless a range in the tree
+// covers it, e.g. a response re-sent for a part of a region backed up as a
+// whole before the region split. It returns the ranges made redundant, that
+// is the range itself if it's covered, otherwise the overlapping ranges
+// replaced by it.
+func (rangeTree *RangeTree) PutDedup(
+	startKey, endKey []byte, files []*backuppb.File,
+) []Range {
+	rg := Range{
+		StartKey: startKey,
+		EndKey:   endKey,
+		Files:    files,
+	}
+	if found := rangeTree.Find(&rg); found != nil &&
+		(len(found.EndKey) == 0 || (len(endKey) != 0 && bytes.Compare(endKey, found.EndKey) <= 0)) {
+		return []Range{rg}
+	}
+	overlaps := rangeTree.getOverlaps(&rg)
+	redundant := make([]Range, 0, len(overlaps))
+	for _, item := range overlaps {
+		redundant = append(redundant, *item)
+		rangeTree.Delete(item)
+	}
+	rangeTree.ReplaceOrInsert(&rg)
+	rangeTree.resetGaps(rg.StartKey, rg.EndKey)
+	return redundant
+}
+
 // InsertRange inserts ranges into the range tree.
 // It returns a non-nil range if there are soe overlapped ranges.
 func (rangeTree *RangeTree) InsertRange(rg Range) *Range {
+	overlapped := rangeTree.overlapsOthers(&rg)
 	out := rangeTree.ReplaceOrInsert(&rg)
+	if overlapped {
+		rangeTree.gaps.stale = true
+	}
 	if out == nil {
+		rangeTree.resetGaps(rg.StartKey, rg.EndKey)
 		return nil
 	}
-	return out.(*Range)
+	replaced := out.(*Range)
+	endKey := rg.EndKey
+	if len(endKey) != 0 && (len(replaced.EndKey) == 0 || bytes.Compare(replaced.EndKey, endKey) > 0) {
+		// the part of the replaced range beyond the range may be a gap now.
+		endKey = replaced.EndKey
+	}
+	rangeTree.resetGaps(rg.StartKey, endKey)
+	return replaced
+}
+
+// rebuildGaps rebuilds all the gaps after they are stale. They are kept stale
+// while the ranges still overlap.
+func (rangeTree *RangeTree) rebuildGaps() {
+	rangeTree.gaps.BTree = btree.New(32)
+	rangeTree.gaps.stale = false
+	rangeTree.resetGaps([]byte{}, []byte{})
+
+	var last *Range
+	rangeTree.Ascend(func(i btree.Item) bool {
+		rg := i.(*Range)
+		if last != nil && (len(last.EndKey) == 0 || bytes.Compare(rg.StartKey, last.EndKey) < 0) {
+			rangeTree.gaps.stale = true
+			return false
+		}
+		last = rg
+		return true
+	})
+}
+
+// resetGaps rebuilds the gaps between the neighbours of the ranges starting in
+// [startKey, endKey), after the ranges there are changed. It's skipped while
+// the gaps are stale.
+func (rangeTree *RangeTree) resetGaps(startKey, endKey []byte) {
+	if rangeTree.gaps.stale {
+		return
+	}
+	// the window is from the start of the range before startKey to the start
+	// of the range after endKey, an empty hi means the max key.
+	lo := []byte{}
+	rangeTree.DescendLessOrEqual(&Range{StartKey: startKey}, func(i btree.Item) bool {
+		rg := i.(*Range)
+		if bytes.Equal(rg.StartKey, startKey) {
+			return true
+		}
+		lo = rg.StartKey
+		return false
+	})
+	var hi []byte
+	if len(endKey) != 0 {
+		if bytes.Compare(endKey, startKey) < 0 {
+			endKey = startKey
+		}
+		rangeTree.AscendGreaterOrEqual(&Range{StartKey: endKey}, func(i btree.Item) bool {
+			rg := i.(*Range)
+			if bytes.Equal(rg.StartKey, startKey) {
+				// an empty range, the window extends to the next one.
+				return true
+			}
+			hi = rg.StartKey
+			return false
+		})
+	}
+
+	var stale []btree.Item
+	collect := func(i btree.Item) bool {
+		stale = append(stale, i)
+		return true
+	}
+	if hi == nil {
+		rangeTree.gaps.AscendGreaterOrEqual(&Range{StartKey: lo}, collect)
+	} else {
+		rangeTree.gaps.AscendRange(&Range{StartKey: lo}, &Range{StartKey: hi}, collect)
+	}
+	for _, gap := range stale {
+		rangeTree.gaps.Delete(gap)
+	}
+
+	cursor, toMax := lo, false
+	visit := func(i btree.Item) bool {
+		rg := i.(*Range)
+		if bytes.Compare(cursor, rg.StartKey) < 0 {
+			rangeTree.gaps.ReplaceOrInsert(&Range{StartKey: cursor, EndKey: rg.StartKey})
+		}
+		switch {
+		case len(rg.EndKey) == 0:
+			toMax = true
+			return false
+		case bytes.Compare(cursor, rg.EndKey) < 0:
+			cursor = rg.EndKey
+		}
+		return true
+	}
+	if hi == nil {
+		rangeTree.AscendGreaterOrEqual(&Range{StartKey: lo}, visit)
+	} else {
+		rangeTree.AscendRange(&Range{StartKey: lo}, &Range{StartKey: hi}, visit)
+	}
+	switch {
+	case toMax:
+	case hi == nil:
+		rangeTree.gaps.ReplaceOrInsert(&Range{StartKey: cursor, EndKey: []byte{}})
+	case bytes.Compare(cursor, hi) < 0:
+		rangeTree.gaps.ReplaceOrInsert(&Range{StartKey: cursor, EndKey: hi})
+	}
 }
 
 // GetSortedRanges collects and returns sorted ranges.
@@ -185,36 +371,30 @@ func (rangeTree *RangeTree) GetIncompleteRange(
 	if len(startKey) != 0 && bytes.Equal(startKey, endKey) {
 		return []Range{}
 	}
+	if rangeTree.gaps.stale {
+		rangeTree.rebuildGaps()
+	}
 	incomplete := make([]Range, 0, 64)
 	requsetRange := Range{StartKey: startKey, EndKey: endKey}
-	lastEndKey := startKey
 	pviot := &Range{StartKey: startKey}
-	if first := rangeTree.Find(pviot); first != nil {
+	var first *Range
+	rangeTree.gaps.DescendLessOrEqual(pviot, func(i btree.Item) bool {
+		first = i.(*Range)
+		return false
+	})
+	if first != nil && first.Contains(startKey) {
 		pviot.StartKey = first.StartKey
 	}
-	rangeTree.AscendGreaterOrEqual(pviot, func(i btree.Item) bool {
-		rg := i.(*Range)
-		if bytes.Compare(lastEndKey, rg.StartKey) < 0 {
-			start, end, isIntersect :=
-				requsetRange.Intersect(lastEndKey, rg.StartKey)
-			if isIntersect {
-				// There is a gap between the last item and the current item.
-				incomplete =
-					append(incomplete, Range{StartKey: start, EndKey: end})
-			}
+	rangeTree.gaps.AscendGreaterOrEqual(pviot, func(i btree.Item) bool {
+		gap := i.(*Range)
+		if len(endKey) != 0 && bytes.Compare(gap.StartKey, endKey) >= 0 {
+			return false
 		}
-		lastEndKey = rg.EndKey
-		return len(endKey) == 0 || bytes.Compare(rg.EndKey, endKey) < 0
-	})
-
-	// Check whether we need append the last range
-	if !bytes.Equal(lastEndKey, endKey) && len(lastEndKey) != 0 &&
-		(len(endKey) == 0 || bytes.Compare(lastEndKey, endKey) < 0) {
-		start, end, isIntersect := requsetRange.Intersect(lastEndKey, endKey)
+		start, end, isIntersect := requsetRange.Intersect(gap.StartKey, gap.EndKey)
 		if isIntersect {
-			incomplete =
-				append(incomplete, Range{StartKey: start, EndKey: end})
+			incomplete = append(incomplete, Range{StartKey: start, EndKey: end})
 		}
-	}
+		return true
+	})
 	return incomplete
 }
@@ -3,10 +3,13 @@
 package rtree_test
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
+	"github.com/google/btree"
 	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
 
 	"github.com/pingcap/br/pkg/rtree"
 )
@@ -128,6 +131,95 @@ func (s *testRangeTreeSuite) TestRangeTree(c *C) {
 	rangeTree.Update(*rangeC)
 	c.Assert(rangeTree.Len(), Equals, 5)
 	assertAllComplete()
+
+	// Overwrite the middle of range BD, b-c and d-e should be empty.
+	rangeTree.Update(*newRange([]byte("b"), []byte("e")))
+	rangeTree.Update(*newRange([]byte("c"), []byte("d")))
+	assertIncomplete([]byte(""), []byte(""), []rtree.Range{
+		{StartKey: []byte("b"), EndKey: []byte("c")},
+		{StartKey: []byte("d"), EndKey: []byte("")},
+	})
+}
+
+func (s *testRangeTreeSuite) TestIncompleteRangeOfEmptyTree(c *C) {
+	rangeTree := rtree.NewRangeTree()
+	incomplete := rangeTree.GetIncompleteRange([]byte(""), []byte(""))
+	c.Assert(incomplete, HasLen, 1)
+	c.Assert(incomplete[0].StartKey, HasLen, 0)
+	c.Assert(incomplete[0].EndKey, HasLen, 0)
+	incomplete = rangeTree.GetIncompleteRange([]byte("a"), []byte("b"))
+	c.Assert(incomplete, DeepEquals, []rtree.Range{{StartKey: []byte("a"), EndKey: []byte("b")}})
+}
+
+func (s *testRangeTreeSuite) TestInsertRange(c *C) {
+	rangeTree := rtree.NewRangeTree()
+	c.Assert(rangeTree.InsertRange(*newRange([]byte("b"), []byte("d"))), IsNil)
+	c.Assert(rangeTree.InsertRange(*newRange([]byte("d"), []byte("f"))), IsNil)
+	c.Assert(rangeTree.InsertRange(*newRange([]byte("a"), []byte("b"))), IsNil)
+	c.Assert(rangeTree.InsertRange(*newRange([]byte("h"), []byte("k"))), IsNil)
+	c.Assert(rangeTree.GetIncompleteRange([]byte(""), []byte("")), DeepEquals, []rtree.Range{
+		{StartKey: []byte(""), EndKey: []byte("a")},
+		{StartKey: []byte("f"), EndKey: []byte("h")},
+		{StartKey: []byte("k"), EndKey: []byte("")},
+	})
+
+	// only the range starting at the same key is replaced.
+	out := rangeTree.InsertRange(*newRange([]byte("h"), []byte("i")))
+	c.Assert(out, DeepEquals, newRange([]byte("h"), []byte("k")))
+	c.Assert(rangeTree.Len(), Equals, 4)
+	c.Assert(rangeTree.GetIncompleteRange([]byte("g"), []byte("")), DeepEquals, []rtree.Range{
+		{StartKey: []byte("g"), EndKey: []byte("h")},
+		{StartKey: []byte("i"), EndKey: []byte("")},
+	})
+
+	// the ranges overlapping with the others are inserted as well.
+	c.Assert(rangeTree.InsertRange(*newRange([]byte("c"), []byte("g"))), IsNil)
+	c.Assert(rangeTree.InsertRange(*newRange([]byte("0"), []byte("a"))), IsNil)
+	c.Assert(rangeTree.Len(), Equals, 6)
+	c.Assert(rangeTree.GetIncompleteRange([]byte(""), []byte("")), DeepEquals, []rtree.Range{
+		{StartKey: []byte(""), EndKey: []byte("0")},
+		{StartKey: []byte("g"), EndKey: []byte("h")},
+		{StartKey: []byte("i"), EndKey: []byte("")},
+	})
+
+	// the gaps are rebuilt once the overlaps are removed.
+	rangeTree.Update(*newRange([]byte("b"), []byte("g")))
+	c.Assert(rangeTree.Len(), Equals, 4)
+	c.Assert(rangeTree.InsertRange(*newRange([]byte("k"), []byte("m"))), IsNil)
+	c.Assert(rangeTree.GetIncompleteRange([]byte(""), []byte("")), DeepEquals, []rtree.Range{
+		{StartKey: []byte(""), EndKey: []byte("0")},
+		{StartKey: []byte("g"), EndKey: []byte("h")},
+		{StartKey: []byte("i"), EndKey: []byte("k")},
+		{StartKey: []byte("m"), EndKey: []byte("")},
+	})
+}
+
+func (s *testRangeTreeSuite) TestPutDedup(c *C) {
+	rangeTree := rtree.NewRangeTree()
+	file := func(name string) []*backuppb.File {
+		return []*backuppb.File{{Name: name}}
+	}
+	c.Assert(rangeTree.PutDedup([]byte("a"), []byte("d"), file("ad")), HasLen, 0)
+
+	// the response of a part of the range is covered, and dropped.
+	redundant := rangeTree.PutDedup([]byte("b"), []byte("c"), file("bc"))
+	c.Assert(redundant, HasLen, 1)
+	c.Assert(redundant[0].Files[0].Name, Equals, "bc")
+	c.Assert(rangeTree.PutDedup([]byte("a"), []byte("d"), file("ad2")), HasLen, 1)
+
+	// the range covering more replaces the overlapping ranges.
+	c.Assert(rangeTree.PutDedup([]byte("d"), []byte("f"), file("df")), HasLen, 0)
+	redundant = rangeTree.PutDedup([]byte("c"), []byte(""), file("c"))
+	c.Assert(redundant, HasLen, 2)
+	c.Assert(redundant[0].Files[0].Name, Equals, "ad")
+	c.Assert(redundant[1].Files[0].Name, Equals, "df")
+	c.Assert(rangeTree.GetSortedRanges(), DeepEquals, []rtree.Range{
+		{StartKey: []byte("c"), EndKey: []byte(""), Files: file("c")},
+	})
+	c.Assert(rangeTree.GetIncompleteRange([]byte(""), []byte("")), DeepEquals, []rtree.Range{
+		{StartKey: []byte(""), EndKey: []byte("c")},
+	})
+	c.Assert(rangeTree.PutDedup([]byte("x"), []byte(""), file("x")), HasLen, 1)
 }
 
 func (s *testRangeTreeSuite) TestRangeIntersect(c *C) {
@@ -184,3 +276,77 @@ func BenchmarkRangeTreeUpdate(b *testing.B) {
 		rangeTree.Update(item)
 	}
 }
+
+// rangeKey encodes the keys of the ranges in order.
+func rangeKey(i int) []byte {
+	return []byte(fmt.Sprintf("%020d", i))
+}
+
+// newBenchRangeTree returns a tree of n ranges, with a gap in every gapEvery
+// ranges, like the small ranges of a backup with a few regions failed.
+func newBenchRangeTree(n, gapEvery int) rtree.RangeTree {
+	rangeTree := rtree.NewRangeTree()
+	for i := 0; i < n; i++ {
+		if i%gapEvery == 0 {
+			continue
+		}
+		rangeTree.InsertRange(rtree.Range{StartKey: rangeKey(i), EndKey: rangeKey(i + 1)})
+	}
+	return rangeTree
+}
+
+// scanIncompleteRange finds the incomplete ranges by scanning the ranges,
+// which is how the gaps were found before they are indexed.
+func scanIncompleteRange(rangeTree *rtree.RangeTree, startKey, endKey []byte) []rtree.Range {
+	incomplete := make([]rtree.Range, 0, 64)
+	lastEndKey := startKey
+	rangeTree.AscendGreaterOrEqual(&rtree.Range{StartKey: startKey}, func(i btree.Item) bool {
+		rg := i.(*rtree.Range)
+		if len(endKey) != 0 && bytes.Compare(rg.StartKey, endKey) >= 0 {
+			return false
+		}
+		if bytes.Compare(lastEndKey, rg.StartKey) < 0 {
+			incomplete = append(incomplete, rtree.Range{StartKey: lastEndKey, EndKey: rg.StartKey})
+		}
+		lastEndKey = rg.EndKey
+		return true
+	})
+	return incomplete
+}
+
+var benchRangeTreeSizes = []int{50_000, 500_000, 5_000_000}
+
+func BenchmarkRangeTreeGetIncompleteRange(b *testing.B) {
+	for _, n := range benchRangeTreeSizes {
+		if n > 500_000 && testing.Short() {
+			continue
+		}
+		rangeTree := newBenchRangeTree(n, 100_000)
+		start, end := rangeKey(0), rangeKey(n)
+		b.Run(fmt.Sprintf("index/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				rangeTree.GetIncompleteRange(start, end)
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scanIncompleteRange(&rangeTree, start, end)
+			}
+		})
+	}
+}
+
+func BenchmarkRangeTreeInsertRange(b *testing.B) {
+	for _, n := range benchRangeTreeSizes {
+		if n > 500_000 && testing.Short() {
+			continue
+		}
+		rangeTree := newBenchRangeTree(n, 2)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// every other range overlaps the ranges in the tree, and is inserted still.
+				rangeTree.InsertRange(rtree.Range{StartKey: rangeKey(n + i), EndKey: rangeKey(n + i + 2)})
+			}
+		})
+	}
+}
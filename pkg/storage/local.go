@@ -5,10 +5,15 @@ package storage
 import (
 	"bufio"
 	"context"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"unsafe"
 
 	"github.com/pingcap/errors"
+
+	berrors "github.com/pingcap/br/pkg/errors"
 )
 
 const (
@@ -18,18 +23,108 @@ const (
 	LocalURIPrefix = "file://"
 )
 
+const (
+	// LocalSyncNone leaves flushing written files to the operating system.
+	LocalSyncNone = "none"
+	// LocalSyncPerFile calls fsync on every file when it is closed.
+	LocalSyncPerFile = "per-file"
+	// LocalSyncPerBatch calls fsync on written files once every
+	// `sync-batch-size` files, and on the remaining ones when Sync is called.
+	LocalSyncPerBatch = "per-batch"
+
+	defaultLocalSyncBatchSize = 64
+	// the alignment of buffers, offsets and sizes required by O_DIRECT.
+	directIOAlignment = 4096
+	// the buffer size used by the O_DIRECT writer, must be a multiple of
+	// directIOAlignment.
+	directIOBufferSize = 1 << 20
+)
+
+// LocalBackendOptions are options for configuring the durability of the local
+// storage. They are usually given as query parameters of the storage URL,
+// e.g. `file:///backup?sync=per-file&direct-io=true`.
+type LocalBackendOptions struct {
+	// Sync is the fsync policy, one of "none", "per-file" or "per-batch".
+	Sync string `json:"sync" toml:"sync"`
+	// SyncBatchSize is the number of files synced together under the
+	// "per-batch" policy.
+	SyncBatchSize int64 `json:"sync-batch-size" toml:"sync-batch-size"`
+	// DirectIO writes files with O_DIRECT to avoid polluting the page cache,
+	// which matters when the backup target is colocated with TiKV.
+	DirectIO bool `json:"direct-io" toml:"direct-io"`
+	// Preallocate reserves the disk space of a file before writing it, if
+	// the size is known in advance.
+	Preallocate bool `json:"preallocate" toml:"preallocate"`
+}
+
+func (options *LocalBackendOptions) adjust() error {
+	switch options.Sync {
+	case "":
+		options.Sync = LocalSyncNone
+	case LocalSyncNone, LocalSyncPerFile, LocalSyncPerBatch:
+	default:
+		return errors.Annotatef(berrors.ErrStorageInvalidConfig,
+			"invalid local sync policy '%s', should be one of none, per-file or per-batch", options.Sync)
+	}
+	if options.SyncBatchSize < 0 {
+		return errors.Annotatef(berrors.ErrStorageInvalidConfig,
+			"invalid local sync-batch-size %d", options.SyncBatchSize)
+	}
+	if options.SyncBatchSize == 0 {
+		options.SyncBatchSize = defaultLocalSyncBatchSize
+	}
+	if options.DirectIO && !directIOSupported {
+		return errors.Annotate(berrors.ErrStorageInvalidConfig, "direct-io is not supported on this platform")
+	}
+	return nil
+}
+
 // LocalStorage represents local file system storage.
 //
 // export for using in tests.
 type LocalStorage struct {
 	base string
+	opts LocalBackendOptions
+
+	// pending records the files which are closed but not synced yet under the
+	// per-batch sync policy.
+	pendingMu sync.Mutex
+	pending   []string
 }
 
 // WriteFile writes data to a file to storage.
 func (l *LocalStorage) WriteFile(ctx context.Context, name string, data []byte) error {
 	path := filepath.Join(l.base, name)
-	return os.WriteFile(path, data, localFilePerm)
-	// the backup meta file _is_ intended to be world-readable.
+	if l.opts.Sync == LocalSyncNone && !l.opts.DirectIO && !l.opts.Preallocate {
+		return os.WriteFile(path, data, localFilePerm)
+		// the backup meta file _is_ intended to be world-readable.
+	}
+
+	file, err := l.openFile(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if l.opts.Preallocate {
+		if err = preallocate(file, int64(len(data))); err != nil {
+			file.Close()
+			return errors.Annotatef(err, "failed to preallocate %d bytes for %s", len(data), path)
+		}
+	}
+	var w io.Writer = file
+	if l.opts.DirectIO {
+		w = newDirectWriter(file)
+	}
+	if _, err = w.Write(data); err != nil {
+		file.Close()
+		return errors.Trace(err)
+	}
+	if dw, ok := w.(*directWriter); ok {
+		if err = dw.Flush(); err != nil {
+			file.Close()
+			return errors.Trace(err)
+		}
+	}
+	return l.closeFile(file)
 }
 
 // ReadFile reads the file from the storage and returns the contents.
@@ -44,6 +139,15 @@ func (l *LocalStorage) FileExists(ctx context.Context, name string) (bool, error
 	return pathExists(path)
 }
 
+// DeleteFile implements Deleter.
+func (l *LocalStorage) DeleteFile(ctx context.Context, name string) error {
+	path := filepath.Join(l.base, name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 // WalkDir traverse all the files in a dir.
 //
 // fn is the function called for each regular file visited by WalkDir.
@@ -93,12 +197,161 @@ func (l *LocalStorage) Open(ctx context.Context, path string) (ExternalFileReade
 
 // Create implements ExternalStorage interface.
 func (l *LocalStorage) Create(ctx context.Context, name string) (ExternalFileWriter, error) {
-	file, err := os.Create(filepath.Join(l.base, name))
+	file, err := l.openFile(filepath.Join(l.base, name))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	closer := &localFileCloser{storage: l, file: file}
+	if l.opts.DirectIO {
+		dw := newDirectWriter(file)
+		return newFlushStorageWriter(dw, dw, closer), nil
+	}
 	buf := bufio.NewWriter(file)
-	return newFlushStorageWriter(buf, buf, file), nil
+	return newFlushStorageWriter(buf, buf, closer), nil
+}
+
+// Sync flushes the files which are written under the per-batch sync policy
+// but not synced yet. It is a no-op for other policies.
+func (l *LocalStorage) Sync(ctx context.Context) error {
+	l.pendingMu.Lock()
+	defer l.pendingMu.Unlock()
+	return l.syncPendingLocked()
+}
+
+func (l *LocalStorage) openFile(path string) (*os.File, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if l.opts.DirectIO {
+		flag |= directIOFlag
+	}
+	return os.OpenFile(path, flag, localFilePerm)
+}
+
+// closeFile closes a written file, applying the sync policy.
+func (l *LocalStorage) closeFile(file *os.File) error {
+	switch l.opts.Sync {
+	case LocalSyncPerFile:
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return errors.Trace(err)
+		}
+		if err := file.Close(); err != nil {
+			return errors.Trace(err)
+		}
+		return syncDir(filepath.Dir(file.Name()))
+	case LocalSyncPerBatch:
+		if err := file.Close(); err != nil {
+			return errors.Trace(err)
+		}
+		l.pendingMu.Lock()
+		defer l.pendingMu.Unlock()
+		l.pending = append(l.pending, file.Name())
+		if int64(len(l.pending)) < l.opts.SyncBatchSize {
+			return nil
+		}
+		return l.syncPendingLocked()
+	default:
+		return errors.Trace(file.Close())
+	}
+}
+
+func (l *LocalStorage) syncPendingLocked() error {
+	dirs := make(map[string]struct{})
+	for _, path := range l.pending {
+		if err := syncFile(path); err != nil {
+			return errors.Trace(err)
+		}
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	l.pending = l.pending[:0]
+	return nil
+}
+
+func syncFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer file.Close()
+	return errors.Trace(file.Sync())
+}
+
+type localFileCloser struct {
+	storage *LocalStorage
+	file    *os.File
+}
+
+func (c *localFileCloser) Close() error {
+	return c.storage.closeFile(c.file)
+}
+
+// directWriter buffers written bytes into aligned blocks, so they can be
+// written into a file opened with O_DIRECT.
+type directWriter struct {
+	file *os.File
+	buf  []byte
+	n    int
+}
+
+func newDirectWriter(file *os.File) *directWriter {
+	return &directWriter{file: file, buf: alignedBuffer(directIOBufferSize)}
+}
+
+func (w *directWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[w.n:], p)
+		w.n += n
+		written += n
+		p = p[n:]
+		if w.n == len(w.buf) {
+			if _, err := w.file.Write(w.buf); err != nil {
+				return written, errors.Trace(err)
+			}
+			w.n = 0
+		}
+	}
+	return written, nil
+}
+
+// Flush writes the remaining bytes. The tail of the file is usually not
+// aligned, so it is written after dropping O_DIRECT from the file. Therefore
+// Flush must only be called once all data is written.
+func (w *directWriter) Flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	aligned := w.n / directIOAlignment * directIOAlignment
+	if aligned > 0 {
+		if _, err := w.file.Write(w.buf[:aligned]); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if aligned < w.n {
+		if err := clearDirectIO(w.file); err != nil {
+			return errors.Trace(err)
+		}
+		if _, err := w.file.Write(w.buf[aligned:w.n]); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	w.n = 0
+	return nil
+}
+
+// alignedBuffer allocates a buffer whose address is aligned to
+// directIOAlignment.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directIOAlignment)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & (directIOAlignment - 1)); rem != 0 {
+		offset = directIOAlignment - rem
+	}
+	return buf[offset : offset+size : offset+size]
 }
 
 func pathExists(_path string) (bool, error) {
@@ -116,6 +369,20 @@ func pathExists(_path string) (bool, error) {
 //
 // export for test.
 func NewLocalStorage(base string) (*LocalStorage, error) {
+	return NewLocalStorageWithOptions(base, nil)
+}
+
+// NewLocalStorageWithOptions return a LocalStorage at directory `base`, which
+// writes files following the durability options. A nil `options` is the same
+// as the default options.
+func NewLocalStorageWithOptions(base string, options *LocalBackendOptions) (*LocalStorage, error) {
+	var opts LocalBackendOptions
+	if options != nil {
+		opts = *options
+	}
+	if err := opts.adjust(); err != nil {
+		return nil, errors.Trace(err)
+	}
 	ok, err := pathExists(base)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -126,5 +393,5 @@ func NewLocalStorage(base string) (*LocalStorage, error) {
 			return nil, errors.Trace(err)
 		}
 	}
-	return &LocalStorage{base: base}, nil
+	return &LocalStorage{base: base, opts: opts}, nil
 }
@@ -0,0 +1,43 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+// +build linux
+
+package storage
+
+import (
+	"os"
+
+	"github.com/pingcap/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	directIOSupported = true
+	directIOFlag      = unix.O_DIRECT
+)
+
+// clearDirectIO drops O_DIRECT from an opened file, so that unaligned data can
+// be written into it.
+func clearDirectIO(file *os.File) error {
+	fd := file.Fd()
+	flag, err := unix.FcntlInt(fd, unix.F_GETFL, 0)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = unix.FcntlInt(fd, unix.F_SETFL, flag&^unix.O_DIRECT)
+	return errors.Trace(err)
+}
+
+// preallocate reserves `size` bytes of disk space for the file.
+func preallocate(file *os.File, size int64) error {
+	if size == 0 {
+		return nil
+	}
+	err := unix.Fallocate(int(file.Fd()), 0, 0, size)
+	if err == unix.EOPNOTSUPP {
+		// the file system doesn't support fallocate, preallocation is only an
+		// optimization so just skip it.
+		return nil
+	}
+	return errors.Trace(err)
+}
@@ -0,0 +1,23 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+// +build !linux
+
+package storage
+
+import (
+	"os"
+)
+
+const (
+	directIOSupported = false
+	directIOFlag      = 0
+)
+
+func clearDirectIO(file *os.File) error {
+	return nil
+}
+
+// preallocate is a no-op outside of linux.
+func preallocate(file *os.File, size int64) error {
+	return nil
+}
@@ -66,3 +66,95 @@ func (r *testStorageSuite) TestWalkDirWithSoftLinkFile(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(i, Equals, 2)
 }
+
+func (r *testLocalSuite) TestParseLocalOptions(c *C) {
+	options := &BackendOptions{}
+	s, err := ParseBackend("file:///tmp/backup?sync=per-batch&sync-batch-size=3&direct_io=false&preallocate=1", options)
+	c.Assert(err, IsNil)
+	c.Assert(s.GetLocal().GetPath(), Equals, "/tmp/backup")
+	c.Assert(options.Local, DeepEquals, LocalBackendOptions{
+		Sync:          LocalSyncPerBatch,
+		SyncBatchSize: 3,
+		Preallocate:   true,
+	})
+
+	options = &BackendOptions{}
+	_, err = ParseBackend("local:///tmp/backup", options)
+	c.Assert(err, IsNil)
+	c.Assert(options.Local.Sync, Equals, LocalSyncNone)
+	c.Assert(options.Local.SyncBatchSize, Equals, int64(defaultLocalSyncBatchSize))
+
+	_, err = ParseBackend("file:///tmp/backup?sync=always", &BackendOptions{})
+	c.Assert(err, ErrorMatches, "invalid local sync policy 'always'.*")
+}
+
+func (r *testLocalSuite) TestLocalSyncPolicies(c *C) {
+	ctx := context.Background()
+	for _, opts := range []LocalBackendOptions{
+		{Sync: LocalSyncPerFile, Preallocate: true},
+		{Sync: LocalSyncPerBatch, SyncBatchSize: 2},
+	} {
+		opts := opts
+		store, err := NewLocalStorageWithOptions(c.MkDir(), &opts)
+		c.Assert(err, IsNil)
+
+		for _, name := range []string{"a", "b", "c"} {
+			err = store.WriteFile(ctx, name, []byte(name+name))
+			c.Assert(err, IsNil)
+		}
+		w, err := store.Create(ctx, "d")
+		c.Assert(err, IsNil)
+		_, err = w.Write(ctx, []byte("dd"))
+		c.Assert(err, IsNil)
+		c.Assert(w.Close(ctx), IsNil)
+
+		if opts.Sync == LocalSyncPerBatch {
+			c.Assert(store.pending, HasLen, 2)
+		}
+		c.Assert(store.Sync(ctx), IsNil)
+		c.Assert(store.pending, HasLen, 0)
+
+		for _, name := range []string{"a", "b", "c", "d"} {
+			content, err := store.ReadFile(ctx, name)
+			c.Assert(err, IsNil)
+			c.Assert(string(content), Equals, name+name)
+		}
+	}
+}
+
+func (r *testLocalSuite) TestDirectWriter(c *C) {
+	f, err := os.Create(filepath.Join(c.MkDir(), "direct"))
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	data := make([]byte, directIOBufferSize+directIOAlignment+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	w := newDirectWriter(f)
+	n, err := w.Write(data[:10])
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 10)
+	n, err = w.Write(data[10:])
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, len(data)-10)
+	c.Assert(w.Flush(), IsNil)
+
+	content, err := os.ReadFile(f.Name())
+	c.Assert(err, IsNil)
+	c.Assert(content, DeepEquals, data)
+}
+
+func (r *testStorageSuite) TestLocalDeleteFile(c *C) {
+	ctx := context.Background()
+	store, err := NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	c.Assert(store.WriteFile(ctx, "a.sst", []byte("a")), IsNil)
+
+	c.Assert(store.DeleteFile(ctx, "a.sst"), IsNil)
+	exists, err := store.FileExists(ctx, "a.sst")
+	c.Assert(err, IsNil)
+	c.Assert(exists, IsFalse)
+	// deleting a file not existing succeeds.
+	c.Assert(store.DeleteFile(ctx, "a.sst"), IsNil)
+}
@@ -17,3 +17,13 @@ func mkdirAll(base string) error {
 	syscall.Umask(mask)
 	return errors.Trace(err)
 }
+
+// syncDir makes the directory entries of newly created files durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer d.Close()
+	return errors.Trace(d.Sync())
+}
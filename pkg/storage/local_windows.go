@@ -11,3 +11,8 @@ import (
 func mkdirAll(base string) error {
 	return os.MkdirAll(base, localDirPerm)
 }
+
+// syncDir is a no-op on windows, where directories cannot be opened for sync.
+func syncDir(dir string) error {
+	return nil
+}
@@ -13,13 +13,15 @@ import (
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
 
 	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/validation"
 )
 
 // BackendOptions further configures the storage backend not expressed by the
 // storage URL.
 type BackendOptions struct {
-	S3  S3BackendOptions  `json:"s3" toml:"s3"`
-	GCS GCSBackendOptions `json:"gcs" toml:"gcs"`
+	S3    S3BackendOptions    `json:"s3" toml:"s3"`
+	GCS   GCSBackendOptions   `json:"gcs" toml:"gcs"`
+	Local LocalBackendOptions `json:"local" toml:"local"`
 }
 
 // ParseRawURL parse raw url to url object.
@@ -55,6 +57,13 @@ func ParseBackend(rawURL string, options *BackendOptions) (*backuppb.StorageBack
 		return &backuppb.StorageBackend{Backend: &backuppb.StorageBackend_Local{Local: local}}, nil
 
 	case "local", "file":
+		if options == nil {
+			options = &BackendOptions{}
+		}
+		ExtractQueryParameters(u, &options.Local)
+		if err := options.Local.adjust(); err != nil {
+			return nil, errors.Trace(err)
+		}
 		local := &backuppb.Local{Path: u.Path}
 		return &backuppb.StorageBackend{Backend: &backuppb.StorageBackend_Local{Local: local}}, nil
 
@@ -100,9 +109,9 @@ func ParseBackend(rawURL string, options *BackendOptions) (*backuppb.StorageBack
 // ExtractQueryParameters moves the query parameters of the URL into the options
 // using reflection.
 //
-// The options must be a pointer to a struct which contains only string or bool
-// fields (more types will be supported in the future), and tagged for JSON
-// serialization.
+// The options must be a pointer to a struct which contains only string, bool
+// or int64 fields (more types will be supported in the future), and tagged for
+// JSON serialization.
 //
 // All of the URL's query parameters will be removed after calling this method.
 func ExtractQueryParameters(u *url.URL, options interface{}) {
@@ -138,6 +147,10 @@ func ExtractQueryParameters(u *url.URL, options interface{}) {
 				}
 			case reflect.String:
 				field.SetString(param)
+			case reflect.Int64:
+				if v, e := strconv.ParseInt(param, 10, 64); e == nil {
+					field.SetInt(v)
+				}
 			default:
 				panic("BackendOption introduced an unsupported kind, please handle it! " + f.kind.String())
 			}
@@ -170,3 +183,38 @@ func FormatBackendURL(backend *backuppb.StorageBackend) (u url.URL) {
 	}
 	return
 }
+
+// Validate reports the options of the other storages than the one of the URL,
+// which are ignored, e.g. --s3.endpoint for a gcs:// URL.
+func (options *BackendOptions) Validate(rawURL string, v *validation.Validator) {
+	u, err := ParseRawURL(rawURL)
+	if err != nil {
+		// reported by ParseBackend.
+		return
+	}
+	setOptions := func(prefix string, values ...string) []string {
+		var set []string
+		for i := 0; i+1 < len(values); i += 2 {
+			if values[i+1] != "" {
+				set = append(set, "--"+prefix+"."+values[i])
+			}
+		}
+		return set
+	}
+	if u.Scheme != "s3" {
+		s3 := &options.S3
+		if set := setOptions("s3", "endpoint", s3.Endpoint, "region", s3.Region, "storage-class", s3.StorageClass,
+			"sse", s3.Sse, "sse-kms-key-id", s3.SseKmsKeyID, "acl", s3.ACL, "provider", s3.Provider); len(set) > 0 {
+			v.Error(set, "the S3 options are set but the storage isn't S3",
+				"remove them, or use a storage URL like s3://bucket/prefix")
+		}
+	}
+	if u.Scheme != "gs" && u.Scheme != "gcs" {
+		gcs := &options.GCS
+		if set := setOptions("gcs", "endpoint", gcs.Endpoint, "storage-class", gcs.StorageClass,
+			"predefined-acl", gcs.PredefinedACL, "credentials-file", gcs.CredentialsFile); len(set) > 0 {
+			v.Error(set, "the GCS options are set but the storage isn't GCS",
+				"remove them, or use a storage URL like gcs://bucket/prefix")
+		}
+	}
+}
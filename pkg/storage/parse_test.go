@@ -10,6 +10,8 @@ import (
 
 	. "github.com/pingcap/check"
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/validation"
 )
 
 func Test(t *testing.T) {
@@ -174,3 +176,26 @@ func (r *testStorageSuite) TestFormatBackendURL(c *C) {
 	})
 	c.Assert(url.String(), Equals, "gcs://bucket/some%20prefix/")
 }
+
+func (r *testStorageSuite) TestValidateBackendOptions(c *C) {
+	options := &BackendOptions{}
+	options.S3.Endpoint = "https://s3.example.com/"
+	options.S3.Region = "us-west-2"
+
+	v := &validation.Validator{}
+	options.Validate("s3://bucket/prefix", v)
+	c.Assert(v.Problems(), HasLen, 0)
+
+	v = &validation.Validator{}
+	options.Validate("gcs://bucket/prefix", v)
+	c.Assert(v.Problems(), HasLen, 1)
+	c.Assert(v.Problems()[0].Items, DeepEquals, []string{"--s3.endpoint", "--s3.region"})
+	c.Assert(v.Err(), ErrorMatches, "(?s).*the S3 options are set but the storage isn't S3.*")
+
+	options = &BackendOptions{}
+	options.GCS.CredentialsFile = "/tmp/credentials.json"
+	v = &validation.Validator{}
+	options.Validate("local:///tmp/backup", v)
+	c.Assert(v.Problems(), HasLen, 1)
+	c.Assert(v.Problems()[0].Items, DeepEquals, []string{"--gcs.credentials-file"})
+}
@@ -383,6 +383,56 @@ func (rs *S3Storage) WriteFile(ctx context.Context, file string, data []byte) er
 	return errors.Trace(err)
 }
 
+// escapeCopySource escapes the copy source "bucket/key" segment by segment, the
+// slashes separating the segments must be kept as is.
+func escapeCopySource(source string) string {
+	segments := strings.Split(source, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// CanCopyFrom implements Copier. The objects can be copied between the
+// buckets of the same endpoint.
+func (rs *S3Storage) CanCopyFrom(src ExternalStorage) bool {
+	srcS3, ok := src.(*S3Storage)
+	return ok && srcS3.options.Endpoint == rs.options.Endpoint
+}
+
+// CopyFrom implements Copier. The object is copied by the CopyObject API, so
+// the credentials of this storage must be able to read the source object.
+func (rs *S3Storage) CopyFrom(ctx context.Context, src ExternalStorage, file string) error {
+	srcS3, ok := src.(*S3Storage)
+	if !ok {
+		return errors.Annotatef(berrors.ErrStorageInvalidConfig, "cannot copy file from %s to s3", src.URI())
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(rs.options.Bucket),
+		Key:        aws.String(rs.options.Prefix + file),
+		CopySource: aws.String(escapeCopySource(srcS3.options.Bucket + "/" + srcS3.options.Prefix + file)),
+	}
+	if rs.options.Acl != "" {
+		input = input.SetACL(rs.options.Acl)
+	}
+	if rs.options.Sse != "" {
+		input = input.SetServerSideEncryption(rs.options.Sse)
+	}
+	if rs.options.SseKmsKeyId != "" {
+		input = input.SetSSEKMSKeyId(rs.options.SseKmsKeyId)
+	}
+	if rs.options.StorageClass != "" {
+		input = input.SetStorageClass(rs.options.StorageClass)
+	}
+
+	_, err := rs.svc.CopyObjectWithContext(ctx, input)
+	if err != nil {
+		return errors.Annotatef(err, "failed to copy s3 file, file info: input.copySource='%s', input.bucket='%s', input.key='%s'",
+			*input.CopySource, *input.Bucket, *input.Key)
+	}
+	return nil
+}
+
 // ReadFile reads the file from the storage and returns the contents.
 func (rs *S3Storage) ReadFile(ctx context.Context, file string) ([]byte, error) {
 	input := &s3.GetObjectInput{
@@ -423,6 +473,16 @@ func (rs *S3Storage) FileExists(ctx context.Context, file string) (bool, error)
 	return true, nil
 }
 
+// DeleteFile implements Deleter, deleting a key not existing succeeds on s3.
+func (rs *S3Storage) DeleteFile(ctx context.Context, file string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(rs.options.Bucket),
+		Key:    aws.String(rs.options.Prefix + file),
+	}
+	_, err := rs.svc.DeleteObjectWithContext(ctx, input)
+	return errors.Trace(err)
+}
+
 // WalkDir traverse all the files in a dir.
 //
 // fn is the function called for each regular file visited by WalkDir.
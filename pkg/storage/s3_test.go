@@ -466,6 +466,33 @@ func (s *s3Suite) TestWriteNoError(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// TestCopyFrom ensures the CopyFrom API issues a CopyObject request with the
+// source object of the other bucket.
+func (s *s3Suite) TestCopyFrom(c *C) {
+	s.setUpTest(c)
+	defer s.tearDownTest()
+	ctx := aws.BackgroundContext()
+
+	src := NewS3StorageForTest(s.s3, &backuppb.S3{Bucket: "src-bucket", Prefix: "src prefix/"})
+	c.Assert(s.storage.CanCopyFrom(src), IsTrue)
+	c.Assert(s.storage.CanCopyFrom(NewS3StorageForTest(s.s3, &backuppb.S3{Endpoint: "http://minio"})), IsFalse)
+
+	s.s3.EXPECT().
+		CopyObjectWithContext(ctx, gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+			c.Assert(aws.StringValue(input.Bucket), Equals, "bucket")
+			c.Assert(aws.StringValue(input.Key), Equals, "prefix/file")
+			c.Assert(aws.StringValue(input.CopySource), Equals, "src-bucket/src%20prefix/file")
+			c.Assert(aws.StringValue(input.ACL), Equals, "acl")
+			c.Assert(aws.StringValue(input.ServerSideEncryption), Equals, "sse")
+			c.Assert(aws.StringValue(input.StorageClass), Equals, "sc")
+			return &s3.CopyObjectOutput{}, nil
+		})
+
+	err := s.storage.CopyFrom(ctx, src, "file")
+	c.Assert(err, IsNil)
+}
+
 // TestReadNoError ensures the ReadFile API issues a GetObject request and correctly
 // read the entire body.
 func (s *s3Suite) TestReadNoError(c *C) {
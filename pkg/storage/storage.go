@@ -95,6 +95,22 @@ type ExternalStorage interface {
 	Create(ctx context.Context, path string) (ExternalFileWriter, error)
 }
 
+// Copier is implemented by the storages which can copy files from another
+// storage on the provider side, without downloading and uploading them.
+type Copier interface {
+	// CanCopyFrom checks whether the files of src can be copied by CopyFrom.
+	CanCopyFrom(src ExternalStorage) bool
+	// CopyFrom copies the file of src to the same name in this storage.
+	CopyFrom(ctx context.Context, src ExternalStorage, name string) error
+}
+
+// Deleter is implemented by the storages which can delete files, e.g. to
+// clean up the files uploaded but not referred to by the backup meta.
+type Deleter interface {
+	// DeleteFile deletes the file, deleting a file not existing succeeds.
+	DeleteFile(ctx context.Context, name string) error
+}
+
 // ExternalFileReader represents the streaming external file reader.
 type ExternalFileReader interface {
 	io.ReadCloser
@@ -139,6 +155,16 @@ type ExternalStorageOptions struct {
 	// CheckPermissions check the given permission in New() function.
 	// make sure we can access the storage correctly before execute tasks.
 	CheckPermissions []Permission
+
+	// LocalOptions configures the durability of the local storage. It is
+	// ignored by other storages. nil means the default options.
+	LocalOptions *LocalBackendOptions
+}
+
+// Syncer is implemented by storages which may delay the durability of written
+// files until Sync is called, e.g. the local storage with per-batch sync.
+type Syncer interface {
+	Sync(ctx context.Context) error
 }
 
 // Create creates ExternalStorage.
@@ -159,7 +185,7 @@ func New(ctx context.Context, backend *backuppb.StorageBackend, opts *ExternalSt
 		if backend.Local == nil {
 			return nil, errors.Annotate(berrors.ErrStorageInvalidConfig, "local config not found")
 		}
-		return NewLocalStorage(backend.Local.Path)
+		return NewLocalStorageWithOptions(backend.Local.Path, opts.LocalOptions)
 	case *backuppb.StorageBackend_S3:
 		if backend.S3 == nil {
 			return nil, errors.Annotate(berrors.ErrStorageInvalidConfig, "s3 config not found")
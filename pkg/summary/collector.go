@@ -43,6 +43,8 @@ type LogCollector interface {
 
 	CollectUInt(name string, t uint64)
 
+	CollectString(name string, t string)
+
 	SetSuccessStatus(success bool)
 
 	Summary(name string)
@@ -82,6 +84,7 @@ type logCollector struct {
 	durations        map[string]time.Duration
 	ints             map[string]int
 	uints            map[string]uint64
+	strs             map[string]string
 	successStatus    bool
 	startTime        time.Time
 
@@ -99,6 +102,7 @@ func NewLogCollector(log logFunc) LogCollector {
 		durations:        make(map[string]time.Duration),
 		ints:             make(map[string]int),
 		uints:            make(map[string]uint64),
+		strs:             make(map[string]string),
 		log:              log,
 		startTime:        time.Now(),
 	}
@@ -150,6 +154,12 @@ func (tc *logCollector) CollectUInt(name string, t uint64) {
 	tc.uints[name] += t
 }
 
+func (tc *logCollector) CollectString(name string, t string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.strs[name] = t
+}
+
 func (tc *logCollector) SetSuccessStatus(success bool) {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
@@ -165,6 +175,7 @@ func (tc *logCollector) Summary(name string) {
 	defer func() {
 		tc.durations = make(map[string]time.Duration)
 		tc.ints = make(map[string]int)
+		tc.strs = make(map[string]string)
 		tc.successCosts = make(map[string]time.Duration)
 		tc.failureReasons = make(map[string]error)
 		tc.mu.Unlock()
@@ -187,6 +198,9 @@ func (tc *logCollector) Summary(name string) {
 	for key, val := range tc.uints {
 		logFields = append(logFields, zap.Uint64(logKeyFor(key), val))
 	}
+	for key, val := range tc.strs {
+		logFields = append(logFields, zap.String(logKeyFor(key), val))
+	}
 
 	if len(tc.failureReasons) != 0 || !tc.successStatus {
 		for unitName, reason := range tc.failureReasons {
@@ -34,6 +34,11 @@ func CollectUint(name string, t uint64) {
 	collector.CollectUInt(name, t)
 }
 
+// CollectString collects log string field, the later one replaces the former.
+func CollectString(name string, t string) {
+	collector.CollectString(name, t)
+}
+
 // SetSuccessStatus sets final success status.
 func SetSuccessStatus(success bool) {
 	collector.SetSuccessStatus(success)
@@ -0,0 +1,71 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/audit"
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+// startAuditLog starts recording the actions mutating the cluster into the
+// audit log of the config. The returned function stops recording.
+//
+// A local audit log is appended as the actions happen, while an audit log in
+// the external storage is uploaded once the recording stops.
+func startAuditLog(ctx context.Context, cfg *Config) (func(), error) {
+	if cfg.AuditLog == "" {
+		return func() {}, nil
+	}
+	if !strings.Contains(cfg.AuditLog, "://") {
+		f, err := os.OpenFile(cfg.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to open audit log %s", cfg.AuditLog)
+		}
+		audit.SetLogger(audit.NewLogger(f))
+		return func() {
+			audit.SetLogger(nil)
+			if err := f.Close(); err != nil {
+				log.Warn("failed to close audit log", zap.String("path", cfg.AuditLog), zap.Error(err))
+			}
+		}, nil
+	}
+
+	u, err := url.Parse(cfg.AuditLog)
+	if err != nil {
+		return nil, errors.Annotatef(berrors.ErrInvalidArgument, "invalid audit log %s: %s", cfg.AuditLog, err)
+	}
+	if u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return nil, errors.Annotatef(berrors.ErrInvalidArgument, "audit log %s should be a file", cfg.AuditLog)
+	}
+	name := path.Base(u.Path)
+	u.Path = path.Dir(u.Path)
+	backend, err := storage.ParseBackend(u.String(), &cfg.BackendOptions)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	s, err := storage.New(ctx, backend, storageOpts(cfg))
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed to open the storage of audit log %s", cfg.AuditLog)
+	}
+	buf := new(bytes.Buffer)
+	audit.SetLogger(audit.NewLogger(buf))
+	return func() {
+		audit.SetLogger(nil)
+		// upload the log even if the task is canceled, which needs it the most.
+		if err := s.WriteFile(context.Background(), name, buf.Bytes()); err != nil {
+			log.Warn("failed to upload audit log", zap.String("path", cfg.AuditLog), zap.Error(err))
+		}
+	}, nil
+}
@@ -10,7 +10,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/docker/go-units"
+	"github.com/google/uuid"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
@@ -20,15 +20,16 @@ import (
 	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/statistics/handle"
 	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/collate"
 	"github.com/spf13/pflag"
 	"github.com/tikv/client-go/v2/oracle"
 	"go.uber.org/zap"
 
 	"github.com/pingcap/br/pkg/backup"
 	"github.com/pingcap/br/pkg/checksum"
+	"github.com/pingcap/br/pkg/conn"
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/glue"
-	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/metautil"
 	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/summary"
@@ -44,11 +45,31 @@ const (
 	flagRemoveSchedulers = "remove-schedulers"
 	flagIgnoreStats      = "ignore-stats"
 	flagUseBackupMetaV2  = "use-backupmeta-v2"
+	flagEventWebhook     = "event-webhook"
+	flagPushDownLabels   = "push-down-store-labels"
+	flagFileLayout       = "file-layout"
+	flagColdAfter        = "cold-after"
+	flagColdStorageClass = "cold-storage-class"
+	flagResolveLocks     = "resolve-locks-timeout"
+	flagSchemaRead       = "schema-replica-read"
+	flagChecksumRead     = "checksum-replica-read"
+	flagAgents           = "agents"
+	flagAgentLabel       = "agent-locality-label"
+	flagRateLimitSched   = "ratelimit-schedule"
+	flagHistoryFile      = "history-file"
+
+	// FlagSchemaOnly is the flag name of backing up the schemas only, which
+	// is set by `br backup schema`.
+	FlagSchemaOnly = "schema-only"
 
 	flagGCTTL = "gcttl"
 
 	defaultBackupConcurrency = 4
 	maxBackupConcurrency     = 256
+
+	// eventCloseTimeout is the time waiting for the pending events to be
+	// published when the backup exits.
+	eventCloseTimeout = time.Minute
 )
 
 // CompressionConfig is the configuration for sst file compression.
@@ -68,6 +89,47 @@ type BackupConfig struct {
 	RemoveSchedulers bool          `json:"remove-schedulers" toml:"remove-schedulers"`
 	IgnoreStats      bool          `json:"ignore-stats" toml:"ignore-stats"`
 	UseBackupMetaV2  bool          `json:"use-backupmeta-v2"`
+	// Mirrors are the extra storages the finished backup is copied to.
+	Mirrors []string `json:"mirrors" toml:"mirrors"`
+	// EventWebhook is the url the events of the backup are posted to.
+	EventWebhook string `json:"event-webhook" toml:"event-webhook"`
+	// PushDownStoreLabels selects the stores the backup is pushed down to.
+	// It's only a hint, the regions whose leaders are on the other stores are
+	// still backed up from their leaders by the fine-grained backup.
+	PushDownStoreLabels map[string]string `json:"push-down-store-labels" toml:"push-down-store-labels"`
+	// FileLayout is how the backup files are laid out in the storage, flat or
+	// under the per-table prefixes.
+	FileLayout string `json:"file-layout" toml:"file-layout"`
+	// ColdAfter is how long the stats of a table aren't updated before it's
+	// cold, and its backup files are written under the cold tier prefix,
+	// 0 to write all the files into the hot tier.
+	ColdAfter time.Duration `json:"cold-after" toml:"cold-after"`
+	// ColdStorageClass is the storage class the backup files of the cold
+	// tables are written with, e.g. STANDARD_IA of S3.
+	ColdStorageClass string `json:"cold-storage-class" toml:"cold-storage-class"`
+	// ResolveLocksTimeout is the max time resolving the locks before the
+	// backup TS ahead of the backup, 0 to resolve them during the backup.
+	ResolveLocksTimeout time.Duration `json:"resolve-locks-timeout" toml:"resolve-locks-timeout"`
+	// SchemaReplicaRead and ChecksumReplicaRead are the replicas the schemas
+	// and the checksums are read from, so that the leaders aren't loaded.
+	SchemaReplicaRead   utils.ReplicaRead `json:"schema-replica-read" toml:"schema-replica-read"`
+	ChecksumReplicaRead utils.ReplicaRead `json:"checksum-replica-read" toml:"checksum-replica-read"`
+	// Agents are the addresses of the backup agents by the localities, the
+	// backup requests of a store are sent through the agent in the locality
+	// of the store, which is the value of its AgentLocalityLabel label.
+	Agents             map[string]string `json:"agents" toml:"agents"`
+	AgentLocalityLabel string            `json:"agent-locality-label" toml:"agent-locality-label"`
+	// SchemaOnly backs up the schemas and the stats of the tables without
+	// their data, the restored tables are empty.
+	SchemaOnly bool `json:"schema-only" toml:"schema-only"`
+	// RateLimitSchedule changes the rate limit during the backup by the time
+	// of the day, nil to keep the rate limit.
+	RateLimitSchedule *backup.RateLimitSchedule `json:"ratelimit-schedule" toml:"ratelimit-schedule"`
+	// HistoryFile is the local path or the external storage URL of the file
+	// keeping the throughput of the recent backups by the clusters, which
+	// estimates the duration of the backup and flags the slow phases. Empty
+	// means not recording.
+	HistoryFile string `json:"history-file" toml:"history-file"`
 	CompressionConfig
 }
 
@@ -110,6 +172,56 @@ func DefineBackupFlags(flags *pflag.FlagSet) {
 	// but will generate v1 meta due to this flag is false. the behaviour is as same as v4.0.15, v4.0.16.
 	// finally v4.0.17 will set this flag to true, and generate v2 meta.
 	_ = flags.MarkHidden(flagUseBackupMetaV2)
+
+	flags.StringSlice(flagMirror, nil,
+		"the extra storage urls the backup is copied to and verified after finishing, "+
+			`eg, "s3://dr-bucket/path/prefix", can be specified multiple times`)
+
+	flags.String(flagEventWebhook, "",
+		"post the events of the backup, e.g. uploaded files and checksummed tables, to this url as JSON arrays")
+
+	flags.String(flagPushDownLabels, "",
+		"a hint to only push the backup down to the stores having all these labels, e.g. 'zone=us-west-1,disk=ssd', "+
+			"the regions whose leaders are on the other stores are still backed up from those stores")
+
+	flags.String(flagFileLayout, backup.FileLayoutFlat,
+		"the layout of the backup files in the storage, 'flat' writes them into the root, "+
+			"'table' writes them under the 'db/table/' prefixes, e.g. for the per-table lifecycle policies")
+	flags.Duration(flagColdAfter, 0,
+		"write the backup files of the tables whose stats aren't updated for this long under the 'cold/' prefix, "+
+			"0 to write all the files into the hot tier")
+	flags.String(flagColdStorageClass, "",
+		"the storage class the backup files of the cold tables are written with, e.g. 'STANDARD_IA' of s3")
+
+	flags.Duration(flagResolveLocks, 0,
+		"resolve the locks before the backup ts ahead of the backup for at most this long, and report the "+
+			"transactions still running then, which would block the backup. 0 to resolve them during the backup")
+
+	flags.String(flagSchemaRead, string(utils.ReplicaReadLeader),
+		"the replicas the schemas are read from at the backup ts, 'leader', 'follower' or 'stale', "+
+			"the reads fall back to the leaders if the replicas fail them")
+	flags.String(flagChecksumRead, string(utils.ReplicaReadLeader),
+		"the replicas the checksums are read from at the backup ts, 'leader', 'follower' or 'stale', "+
+			"the checksum of a table falls back to the leaders if the replicas fail it")
+
+	flags.String(flagAgents, "",
+		"the backup agents by the localities, e.g. 'us-west-1a=10.0.1.1:8290,us-west-1b=10.0.2.1:8290', "+
+			"the backup requests of a store are sent through the agent in its locality, see `br backup agent`")
+	flags.String(flagAgentLabel, "zone", "the store label telling the localities of the agents")
+	flags.Bool(FlagSchemaOnly, false,
+		"only back up the schemas and the stats of the tables without their data, "+
+			"restoring it creates the empty tables, e.g. the skeleton of a cluster for testing")
+	flags.Bool(flagWithBindings, false,
+		"back up the SQL bindings, i.e. the table mysql.bind_info, besides the tables selected, "+
+			"all the bindings are backed up even if only some tables are selected")
+	flags.String(flagRateLimitSched, "",
+		"the rate limits by the time windows of the day in the local time, MB/s per node, e.g. "+
+			"'09:00-21:00=50' throttles the backup during the business hours, --ratelimit applies out of the "+
+			"windows. The rate limit changes for the ranges started after the window begins or ends")
+	flags.String(flagHistoryFile, "",
+		"keep the throughput of the recent backups of each cluster in this file, either a local path or "+
+			"an external storage URL, to estimate the duration of the backup at the start and flag the phases "+
+			"much slower than the last runs in the summary")
 }
 
 // ParseFromFlags parses the backup-related flags from the flag set.
@@ -157,7 +269,89 @@ func (cfg *BackupConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if cfg.SchemaOnly, err = flags.GetBool(FlagSchemaOnly); err != nil {
+		return errors.Trace(err)
+	}
+	// the stats are a part of the schemas backed up unless ignored explicitly.
+	if cfg.SchemaOnly && !flags.Changed(flagIgnoreStats) {
+		cfg.IgnoreStats = false
+	}
 	cfg.UseBackupMetaV2, err = flags.GetBool(flagUseBackupMetaV2)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.Mirrors, err = flags.GetStringSlice(flagMirror)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	pushDownLabels, err := flags.GetString(flagPushDownLabels)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.PushDownStoreLabels, err = conn.ParseStoreLabels(pushDownLabels); err != nil {
+		return errors.Annotatef(err, "invalid --%s", flagPushDownLabels)
+	}
+	fileLayout, err := flags.GetString(flagFileLayout)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.FileLayout, err = backup.ParseFileLayout(fileLayout); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ColdAfter, err = flags.GetDuration(flagColdAfter); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ColdStorageClass, err = flags.GetString(flagColdStorageClass); err != nil {
+		return errors.Trace(err)
+	}
+	cfg.ResolveLocksTimeout, err = flags.GetDuration(flagResolveLocks)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ResolveLocksTimeout < 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "negative --%s is not allowed", flagResolveLocks)
+	}
+	schemaRead, err := flags.GetString(flagSchemaRead)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.SchemaReplicaRead, err = utils.ParseReplicaRead(schemaRead); err != nil {
+		return errors.Annotatef(err, "invalid --%s", flagSchemaRead)
+	}
+	checksumRead, err := flags.GetString(flagChecksumRead)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ChecksumReplicaRead, err = utils.ParseReplicaRead(checksumRead); err != nil {
+		return errors.Annotatef(err, "invalid --%s", flagChecksumRead)
+	}
+	agents, err := flags.GetString(flagAgents)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Agents, err = conn.ParseStoreLabels(agents); err != nil {
+		return errors.Annotatef(err, "invalid --%s", flagAgents)
+	}
+	if cfg.AgentLocalityLabel, err = flags.GetString(flagAgentLabel); err != nil {
+		return errors.Trace(err)
+	}
+	schedule, err := flags.GetString(flagRateLimitSched)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if schedule != "" {
+		rateLimitUnit, err := flags.GetUint64(flagRateLimitUnit)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if cfg.RateLimitSchedule, err = backup.ParseRateLimitSchedule(schedule, cfg.RateLimit, rateLimitUnit); err != nil {
+			return errors.Annotatef(err, "invalid --%s", flagRateLimitSched)
+		}
+	}
+	if cfg.HistoryFile, err = flags.GetString(flagHistoryFile); err != nil {
+		return errors.Trace(err)
+	}
+	cfg.EventWebhook, err = flags.GetString(flagEventWebhook)
 	return errors.Trace(err)
 }
 
@@ -187,25 +381,19 @@ func parseCompressionFlags(flags *pflag.FlagSet) (*CompressionConfig, error) {
 // so that both binary and TiDB will use same default value.
 func (cfg *BackupConfig) adjustBackupConfig() {
 	cfg.adjust()
-	usingDefaultConcurrency := false
 	if cfg.Config.Concurrency == 0 {
 		cfg.Config.Concurrency = defaultBackupConcurrency
-		usingDefaultConcurrency = true
 	}
 	if cfg.Config.Concurrency > maxBackupConcurrency {
 		cfg.Config.Concurrency = maxBackupConcurrency
 	}
-	if cfg.RateLimit != unlimited {
+	// The rate limited ranges are serialized by the client with the schedule.
+	if cfg.RateLimit != unlimited && cfg.RateLimitSchedule == nil {
 		// TiKV limits the upload rate by each backup request.
 		// When the backup requests are sent concurrently,
 		// the ratelimit couldn't work as intended.
 		// Degenerating to sequentially sending backup requests to avoid this.
-		if !usingDefaultConcurrency {
-			logutil.WarnTerm("setting `--ratelimit` and `--concurrency` at the same time, "+
-				"ignoring `--concurrency`: `--ratelimit` forces sequential (i.e. concurrency = 1) backup",
-				zap.String("ratelimit", units.HumanSize(float64(cfg.RateLimit))+"/s"),
-				zap.Uint32("concurrency-specified", cfg.Config.Concurrency))
-		}
+		// Specifying the concurrency as well is reported by the validation.
 		cfg.Config.Concurrency = 1
 	}
 
@@ -220,12 +408,21 @@ func (cfg *BackupConfig) adjustBackupConfig() {
 
 // RunBackup starts a backup task inside the current goroutine.
 func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig) error {
+	if done, err := validateTask(cmdName, cfg, cfg.ValidateOnly); done {
+		return errors.Trace(err)
+	}
 	cfg.adjustBackupConfig()
 
 	defer summary.Summary(cmdName)
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	stopAudit, err := startAuditLog(ctx, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer stopAudit()
+
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("task.RunBackup", opentracing.ChildOf(span.Context()))
 		defer span1.Finish()
@@ -240,8 +437,9 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 	// For backup, Domain is not needed if user ignores stats.
 	// Domain loads all table info into memory. By skipping Domain, we save
 	// lots of memory (about 500MB for 40K 40 fields YCSB tables).
-	needDomain := !skipStats
-	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.CheckRequirements, needDomain)
+	// The cold tables are found by their stats, which needs Domain as well.
+	needDomain := !skipStats || cfg.ColdAfter > 0
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, needDomain)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -251,7 +449,13 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		statsHandle = mgr.GetDomain().StatsHandle()
 	}
 
-	client, err := backup.NewBackupClient(ctx, mgr)
+	var clientMgr backup.ClientMgr = mgr
+	if len(cfg.Agents) > 0 {
+		agentMgr := backup.NewAgentClientMgr(mgr, cfg.AgentLocalityLabel, cfg.Agents, mgr.GetTLSConfig())
+		defer agentMgr.Close()
+		clientMgr = agentMgr
+	}
+	client, err := backup.NewBackupClient(ctx, clientMgr)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -259,10 +463,15 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		NoCredentials:   cfg.NoCreds,
 		SendCredentials: cfg.SendCreds,
 		SkipCheckPath:   cfg.SkipCheckPath,
+		LocalOptions:    &cfg.BackendOptions.Local,
 	}
 	if err = client.SetStorage(ctx, u, &opts); err != nil {
 		return errors.Trace(err)
 	}
+	mirrors, err := openMirrors(ctx, cfg, &opts)
+	if err != nil {
+		return errors.Trace(err)
+	}
 	err = client.SetLockFile(ctx)
 	if err != nil {
 		return errors.Trace(err)
@@ -290,6 +499,30 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		return errors.Trace(err)
 	}
 
+	var events *backup.EventEmitter
+	if cfg.EventWebhook != "" {
+		if cfg.taskID == "" {
+			cfg.taskID = uuid.New().String()
+		}
+		events = backup.NewEventEmitter(cfg.EventWebhook, cfg.taskID, client.GetStorage().URI(), backupTS)
+		defer func() {
+			closeCtx, closeCancel := context.WithTimeout(context.Background(), eventCloseTimeout)
+			defer closeCancel()
+			events.Close(closeCtx)
+		}()
+	}
+	client.SetEventEmitter(events)
+	if len(cfg.PushDownStoreLabels) > 0 {
+		client.SetStoreFilters(conn.WithStoreLabels(cfg.PushDownStoreLabels))
+	}
+	// the watcher stops when the backup exits and ctx is canceled.
+	storeWatcher := conn.NewStoreWatcher(mgr.GetPDClient(), conn.DefaultStoreWatchInterval)
+	if err = storeWatcher.Start(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	client.SetStoreWatcher(storeWatcher)
+	events.Emit(&backup.Event{Type: backup.EventBackupStarted})
+
 	isIncrementalBackup := cfg.LastBackupTS > 0
 
 	if cfg.RemoveSchedulers {
@@ -323,8 +556,16 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 	if err != nil {
 		return errors.Trace(err)
 	}
+	clusterInfo, err := buildClusterInfo(ctx, mgr, backupTS)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = metautil.WriteClusterInfo(ctx, client.GetStorage(), clusterInfo); err != nil {
+		return errors.Trace(err)
+	}
 
-	ranges, schemas, err := backup.BuildBackupRangeAndSchema(mgr.GetStorage(), cfg.TableFilter, backupTS)
+	ranges, schemas, err := backup.BuildBackupRangeAndSchemaWithReplicaRead(
+		mgr.GetStorage(), cfg.TableFilter, backupTS, cfg.SchemaReplicaRead)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -349,6 +590,19 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 			zap.String("PD address", pdAddress))
 		return metawriter.FinishWriteMetas(ctx, metautil.AppendSchema)
 	}
+	if cfg.FileLayout == backup.FileLayoutTable {
+		client.SetTableLayout(schemas)
+	}
+	if cfg.RateLimitSchedule != nil {
+		client.SetRateLimitSchedule(cfg.RateLimitSchedule)
+	}
+	if cfg.ColdAfter > 0 {
+		cold, err := schemas.ColdPhysicalTables(mgr.GetDomain().StatsHandle(), backupTS, cfg.ColdAfter)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		client.SetColdTables(cold, cfg.ColdStorageClass)
+	}
 
 	if isIncrementalBackup {
 		if backupTS <= cfg.LastBackupTS {
@@ -371,7 +625,22 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		}
 	}
 
+	if cfg.SchemaOnly {
+		log.Info("skip backing up the data of the tables", zap.Int("ranges", len(ranges)))
+		ranges = nil
+	}
 	summary.CollectInt("backup total ranges", len(ranges))
+	var history *historyRecorder
+	// the incremental and schema-only backups aren't comparable with the full ones.
+	if cfg.HistoryFile != "" && !isIncrementalBackup && !cfg.SchemaOnly {
+		history = startHistoryRecorder(ctx, cfg, mgr.HTTPClient(), client.GetClusterID(), ranges)
+	}
+
+	if cfg.ResolveLocksTimeout > 0 {
+		if err = client.ResolveLocks(ctx, ranges, backupTS, cfg.ResolveLocksTimeout); err != nil {
+			return errors.Trace(err)
+		}
+	}
 
 	var updateCh glue.Progress
 	var unit backup.ProgressUnit
@@ -388,14 +657,20 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 			approximateRegions += regionCount
 		}
 		// Redirect to log if there is no log file to avoid unreadable output.
-		updateCh = g.StartProgress(
-			ctx, cmdName, int64(approximateRegions), !cfg.LogProgress)
+		updateCh, err = startProgress(
+			ctx, g, mgr.GetStorage(), &cfg.Config, cmdName, cmdName, int64(approximateRegions))
+		if err != nil {
+			return errors.Trace(err)
+		}
 		summary.CollectInt("backup total regions", approximateRegions)
 	} else {
 		unit = backup.RangeUnit
 		// To reduce the costs, we can use the range as unit of progress.
-		updateCh = g.StartProgress(
-			ctx, cmdName, int64(len(ranges)), !cfg.LogProgress)
+		updateCh, err = startProgress(
+			ctx, g, mgr.GetStorage(), &cfg.Config, cmdName, cmdName, int64(len(ranges)))
+		if err != nil {
+			return errors.Trace(err)
+		}
 	}
 
 	progressCount := 0
@@ -420,12 +695,15 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		}
 	}
 	metawriter.StartWriteMetasAsync(ctx, metautil.AppendDataFile)
+	history.startPhase()
 	err = client.BackupRanges(ctx, ranges, req, uint(cfg.Concurrency), metawriter, progressCallBack)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	history.finishPhase(historyPhaseBackup)
 	// Backup has finished
 	updateCh.Close()
+	events.Emit(backup.NewPhaseCompletedEvent("backup"))
 
 	err = metawriter.FinishWriteMetas(ctx, metautil.AppendDataFile)
 	if err != nil {
@@ -441,21 +719,30 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		m.BrVersion = brVersion
 	})
 
-	skipChecksum := !cfg.Checksum || isIncrementalBackup
+	// the checksums of the schema-only backup would mismatch the empty files.
+	skipChecksum := !cfg.Checksum || isIncrementalBackup || cfg.SchemaOnly
 	checksumProgress := int64(schemas.Len())
 	if skipChecksum {
 		checksumProgress = 1
 		if isIncrementalBackup {
 			// Since we don't support checksum for incremental data, fast checksum should be skipped.
 			log.Info("Skip fast checksum in incremental backup")
+		} else if cfg.SchemaOnly {
+			log.Info("Skip fast checksum in schema-only backup")
 		} else {
 			// When user specified not to calculate checksum, don't calculate checksum.
 			log.Info("Skip fast checksum")
 		}
 	}
-	updateCh = g.StartProgress(ctx, "Checksum", checksumProgress, !cfg.LogProgress)
+	updateCh, err = startProgress(ctx, g, mgr.GetStorage(), &cfg.Config, cmdName, "Checksum", checksumProgress)
+	if err != nil {
+		return errors.Trace(err)
+	}
 	schemasConcurrency := uint(utils.MinInt(backup.DefaultSchemaConcurrency, schemas.Len()))
+	schemas.SetEventEmitter(events)
+	schemas.SetChecksumReplicaRead(cfg.ChecksumReplicaRead)
 
+	history.startPhase()
 	err = schemas.BackupSchemas(
 		ctx, metawriter, mgr.GetStorage(), statsHandle, backupTS, schemasConcurrency, cfg.ChecksumConcurrency, skipChecksum, updateCh)
 	if err != nil {
@@ -463,6 +750,7 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 	}
 	// Checksum has finished, close checksum progress.
 	updateCh.Close()
+	events.Emit(backup.NewPhaseCompletedEvent("checksum"))
 
 	if !skipChecksum {
 		// Check if checksum from files matches checksum from coprocessor.
@@ -470,6 +758,7 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		if err != nil {
 			return errors.Trace(err)
 		}
+		history.finishPhase(historyPhaseChecksum)
 	}
 
 	g.Record(summary.BackupDataSize, metawriter.ArchiveSize())
@@ -487,11 +776,79 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		}
 		time.Sleep(3 * time.Second)
 	})
+	// Files written under the per-batch sync policy must be durable before
+	// the backup is reported as finished.
+	if syncer, ok := client.GetStorage().(storage.Syncer); ok {
+		if err = syncer.Sync(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if len(mirrors) > 0 {
+		files, err := listBackupFiles(ctx, client.GetStorage())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		updateCh, err = startProgress(
+			ctx, g, mgr.GetStorage(), &cfg.Config, cmdName, "Mirror", int64(len(files)*len(mirrors)))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		err = mirrorBackup(ctx, client.GetStorage(), mirrors, files, uint(cfg.Concurrency), updateCh)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		updateCh.Close()
+		summary.CollectInt("backup mirrors", len(mirrors))
+		events.Emit(backup.NewPhaseCompletedEvent("mirror"))
+	}
+	events.Emit(backup.NewPhaseCompletedEvent("finished"))
+	history.finish(ctx)
 	// Set task summary to success status.
 	summary.SetSuccessStatus(true)
 	return nil
 }
 
+// maxRecordedDDLJobs is the max number of the DDL jobs before the backup point
+// recorded for starting a changefeed after restore.
+const maxRecordedDDLJobs = 100
+
+// buildClusterInfo builds the state of the cluster at the backup point.
+func buildClusterInfo(ctx context.Context, mgr *conn.Mgr, backupTS uint64) (*metautil.ClusterInfo, error) {
+	schemaVersion, jobs, err := backup.GetSchemaHistory(mgr.GetStorage(), backupTS, maxRecordedDDLJobs)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	info := &metautil.ClusterInfo{BackupTS: backupTS, SchemaVersion: schemaVersion}
+	for _, job := range jobs {
+		info.RecentDDLs = append(info.RecentDDLs, &metautil.DDLRecord{
+			JobID:         job.ID,
+			Type:          job.Type.String(),
+			SchemaName:    job.SchemaName,
+			Query:         job.Query,
+			SchemaVersion: job.BinlogInfo.SchemaVersion,
+			FinishedTS:    job.BinlogInfo.FinishedTS,
+		})
+	}
+	// the bootstrap of the domain loads whether the new collations of the
+	// cluster are enabled, which is unknown without the domain.
+	if mgr.GetDomain() != nil {
+		enabled := collate.NewCollationEnabled()
+		info.NewCollationsEnabled = &enabled
+	} else {
+		log.Warn("skip recording whether the new collations are enabled without loading the domain, " +
+			"the restore won't check it")
+	}
+	if info.Topology, err = mgr.GetTopology(ctx); err != nil {
+		log.Warn("failed to record the topology of the cluster, the restore won't be planned by it", zap.Error(err))
+	}
+	info.GlobalVariables, err = backup.GetGlobalVariables(mgr.GetStorage(), backupTS, backup.SnapshotVariables)
+	if err != nil {
+		log.Warn("failed to record the global variables, they can't be applied by the restore", zap.Error(err))
+	}
+	return info, nil
+}
+
 // parseTSString port from tidb setSnapshotTS.
 func parseTSString(ts string) (uint64, error) {
 	if len(ts) == 0 {
@@ -0,0 +1,140 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"go.etcd.io/etcd/pkg/transport"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/pingcap/br/pkg/backup"
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+)
+
+const (
+	flagAgentListen = "listen"
+
+	defaultAgentListen = "127.0.0.1:8290"
+)
+
+// BackupAgentConfig is the configuration of `br backup agent`.
+type BackupAgentConfig struct {
+	Config
+
+	// Listen is the address the agent serves the coordinator BR on. A non-loopback
+	// address requires TLS.
+	Listen string `json:"listen" toml:"listen"`
+}
+
+// DefineBackupAgentFlags defines the flags of `br backup agent`.
+func DefineBackupAgentFlags(flags *pflag.FlagSet) {
+	flags.String(flagAgentListen, defaultAgentListen,
+		"the address the agent serves the backup requests dispatched by the coordinator br on, "+
+			"which must be a loopback address unless TLS is enabled, the coordinator must present a certificate "+
+			"signed by the --ca then")
+}
+
+// ParseFromFlags parses the backup agent flags from the flag set.
+func (cfg *BackupAgentConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.Listen, err = flags.GetString(flagAgentListen); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Listen == "" {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s is required", flagAgentListen)
+	}
+	return errors.Trace(cfg.Config.ParseFromFlags(flags))
+}
+
+// RunBackupAgent serves the backup requests dispatched by the coordinator BR
+// running with --agents, and sends them to the stores, until the context is
+// done. The agents should run in the localities of the stores, so that the
+// backup responses don't cross the localities.
+func RunBackupAgent(c context.Context, g glue.Glue, cmdName string, cfg *BackupAgentConfig) error {
+	var serverTLS *tls.Config
+	if cfg.TLS.IsEnabled() {
+		var err error
+		if serverTLS, err = agentServerTLSConfig(&cfg.TLS); err != nil {
+			return errors.Trace(err)
+		}
+	} else if !isLoopbackAddr(cfg.Listen) {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"the agent refuses to listen on the non-loopback address %s without TLS", cfg.Listen)
+	}
+
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, false)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer mgr.Close()
+
+	var opts []grpc.ServerOption
+	if serverTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(serverTLS)))
+	}
+	server := grpc.NewServer(opts...)
+	backuppb.RegisterBackupServer(server, backup.NewAgent(mgr))
+
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "failed to listen on %s: %s", cfg.Listen, err)
+	}
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	log.Info("backup agent started", zap.String("cmd", cmdName), zap.String("listen", cfg.Listen))
+	if err := server.Serve(listener); err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("backup agent stopped", zap.String("cmd", cmdName))
+	return nil
+}
+
+// agentServerTLSConfig returns the TLS config the backup agent and the restore
+// coordinator serve with, which only accepts the clients presenting a
+// certificate signed by the cluster CA.
+func agentServerTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
+	tlsInfo := transport.TLSInfo{
+		CertFile:       cfg.Cert,
+		KeyFile:        cfg.Key,
+		TrustedCAFile:  cfg.CA,
+		ClientCertAuth: true,
+	}
+	tlsConf, err := tlsInfo.ServerConfig()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if tlsConf.ClientCAs == nil {
+		return nil, errors.Annotate(berrors.ErrInvalidArgument, "the CA is required to verify the client certificates")
+	}
+	tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConf, nil
+}
+
+// isLoopbackAddr returns whether the address only listens on the loopback
+// interface, an empty host listens on all the interfaces.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
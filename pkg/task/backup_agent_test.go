@@ -0,0 +1,28 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+
+	. "github.com/pingcap/check"
+)
+
+type testBackupAgentSuite struct{}
+
+var _ = Suite(&testBackupAgentSuite{})
+
+func (s *testBackupAgentSuite) TestIsLoopbackAddr(c *C) {
+	for _, addr := range []string{"127.0.0.1:8290", "[::1]:8290", "localhost:8290", defaultAgentListen} {
+		c.Assert(isLoopbackAddr(addr), IsTrue, Commentf("addr: %s", addr))
+	}
+	for _, addr := range []string{"0.0.0.0:8290", ":8290", "10.0.0.1:8290", "agent:8290", "127.0.0.1"} {
+		c.Assert(isLoopbackAddr(addr), IsFalse, Commentf("addr: %s", addr))
+	}
+}
+
+func (s *testBackupAgentSuite) TestRunBackupAgentRequiresTLS(c *C) {
+	cfg := &BackupAgentConfig{Listen: "0.0.0.0:8290"}
+	err := RunBackupAgent(context.Background(), nil, "backup agent", cfg)
+	c.Assert(err, ErrorMatches, ".*refuses to listen on the non-loopback address 0.0.0.0:8290 without TLS.*")
+}
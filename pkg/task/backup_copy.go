@@ -0,0 +1,284 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"sync/atomic"
+
+	"github.com/docker/go-units"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagCopyTargetStorage = "target-storage"
+
+	defaultCopyConcurrency = 16
+	// copyChunkSize is the size of each part re-uploaded to the target storage.
+	copyChunkSize = 8 * units.MiB
+)
+
+// BackupCopyConfig is the configuration of `br backup copy`.
+type BackupCopyConfig struct {
+	Config
+
+	TargetStorage string `json:"target-storage" toml:"target-storage"`
+}
+
+// DefineBackupCopyFlags defines the flags of `br backup copy`.
+func DefineBackupCopyFlags(flags *pflag.FlagSet) {
+	flags.String(flagCopyTargetStorage, "",
+		`specify the url where the backup is copied to, eg, "s3://dr-bucket/path/prefix"`)
+}
+
+// ParseFromFlags parses the backup copy config from the flag set.
+func (cfg *BackupCopyConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.TargetStorage, err = flags.GetString(flagCopyTargetStorage); err != nil {
+		return errors.Trace(err)
+	}
+	if err = cfg.Config.ParseFromFlags(flags); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Storage == "" || cfg.TargetStorage == "" {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s and --%s must be specified", flagStorage, flagCopyTargetStorage)
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = defaultCopyConcurrency
+	}
+	return nil
+}
+
+// RunBackupCopy copies an existing backup from one external storage to
+// another. The files are copied on the provider side if both storages support
+// it, and re-uploaded otherwise. Every copied file is verified against the
+// sha256 recorded in the backupmeta, or against the source file if there is
+// no such record.
+//
+// The files are referenced by the paths relative to the storage in the
+// backupmeta, so the backupmeta is copied as is.
+func RunBackupCopy(c context.Context, g glue.Glue, cmdName string, cfg *BackupCopyConfig) error {
+	defer summary.Summary(cmdName)
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	_, src, backupMeta, err := ReadBackupMeta(ctx, metautil.MetaFile, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	dataFiles, err := metautil.NewMetaReader(backupMeta, src).ReadDataFiles(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	checksums := make(map[string][]byte, len(dataFiles))
+	for _, file := range dataFiles {
+		if len(file.Sha256) > 0 {
+			checksums[file.Name] = file.Sha256
+		}
+	}
+
+	u, err := storage.ParseBackend(cfg.TargetStorage, &cfg.BackendOptions)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	target, err := storage.New(ctx, u, storageOpts(&cfg.Config))
+	if err != nil {
+		return errors.Annotate(err, "create target storage failed")
+	}
+	if err = checkNoBackup(ctx, target); err != nil {
+		return errors.Trace(err)
+	}
+
+	files, err := listBackupFiles(ctx, src)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updateCh := cfg.startProgressPrinter(ctx, g, cmdName, int64(len(files)))
+	copier := newBackupCopier(src, target, checksums)
+	err = copier.copyFiles(ctx, files, uint(cfg.Concurrency), updateCh)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updateCh.Close()
+	if syncer, ok := target.(storage.Syncer); ok {
+		if err = syncer.Sync(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	summary.CollectInt("copied files", len(files))
+	summary.CollectInt("server-side copied files", int(copier.serverSideCopied))
+	summary.CollectInt("re-uploaded bytes", int(copier.reuploadedBytes))
+	log.Info("backup copied", zap.String("source", src.URI()), zap.String("target", target.URI()),
+		zap.Int("files", len(files)), zap.Int64("serverSideCopied", copier.serverSideCopied))
+	summary.SetSuccessStatus(true)
+	return nil
+}
+
+// checkNoBackup checks that the storage does not contain another backup.
+func checkNoBackup(ctx context.Context, s storage.ExternalStorage) error {
+	for _, name := range []string{metautil.MetaFile, metautil.LockFile} {
+		exist, err := s.FileExists(ctx, name)
+		if err != nil {
+			return errors.Annotatef(err, "error occurred when checking %s file", name)
+		}
+		if exist {
+			return errors.Annotatef(berrors.ErrInvalidArgument, "%s file exists in %v, "+
+				"there may be some backup files in the path already, "+
+				"please specify a correct backup directory!", name, s.URI()+"/"+name)
+		}
+	}
+	return nil
+}
+
+type backupCopier struct {
+	src       storage.ExternalStorage
+	target    storage.ExternalStorage
+	checksums map[string][]byte
+	// serverSide is the target storage if it can copy the files of the source
+	// storage on the provider side.
+	serverSide storage.Copier
+
+	serverSideCopied int64
+	reuploadedBytes  uint64
+}
+
+func newBackupCopier(src, target storage.ExternalStorage, checksums map[string][]byte) *backupCopier {
+	c := &backupCopier{src: src, target: target, checksums: checksums}
+	if copier, ok := target.(storage.Copier); ok && copier.CanCopyFrom(src) {
+		c.serverSide = copier
+	}
+	return c
+}
+
+// copyFiles copies the files concurrently. The backupmeta, which must be the
+// last one of the files, is copied after all the other files, so an
+// interrupted copy is never mistaken for a complete backup.
+func (c *backupCopier) copyFiles(ctx context.Context, files []string, concurrency uint, updateCh glue.Progress) error {
+	dataFiles, metaFiles := files, []string(nil)
+	if len(files) > 0 && files[len(files)-1] == metautil.MetaFile {
+		dataFiles, metaFiles = files[:len(files)-1], files[len(files)-1:]
+	}
+	pool := utils.NewWorkerPool(concurrency, "copy")
+	for _, batch := range [][]string{dataFiles, metaFiles} {
+		eg, ectx := errgroup.WithContext(ctx)
+		for _, file := range batch {
+			file := file
+			pool.ApplyOnErrorGroup(eg, func() error {
+				// the storage services may throttle the copy of many files.
+				err := utils.WithRetry(ectx, func() error {
+					return c.copyFile(ectx, file)
+				}, utils.NewStorageBackoffer())
+				if err != nil {
+					return errors.Annotatef(err, "failed to copy %s", file)
+				}
+				updateCh.Inc()
+				return nil
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (c *backupCopier) copyFile(ctx context.Context, name string) error {
+	expected := c.checksums[name]
+	if c.serverSide != nil {
+		if err := c.serverSide.CopyFrom(ctx, c.src, name); err != nil {
+			return errors.Trace(err)
+		}
+		atomic.AddInt64(&c.serverSideCopied, 1)
+		if expected == nil {
+			// there is no recorded checksum, compare with the source file.
+			h := sha256.New()
+			if err := readFileTo(ctx, c.src, name, h); err != nil {
+				return errors.Trace(err)
+			}
+			expected = h.Sum(nil)
+		}
+	} else {
+		actual, err := c.reupload(ctx, name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if expected != nil && !bytes.Equal(expected, actual) {
+			return errors.Annotatef(berrors.ErrBackupChecksumMismatch,
+				"the source file is corrupted, expected sha256 %x, got %x", expected, actual)
+		}
+		expected = actual
+	}
+
+	h := sha256.New()
+	if err := readFileTo(ctx, c.target, name, h); err != nil {
+		return errors.Trace(err)
+	}
+	if actual := h.Sum(nil); !bytes.Equal(expected, actual) {
+		return errors.Annotatef(berrors.ErrBackupChecksumMismatch,
+			"the copied file is corrupted, expected sha256 %x, got %x", expected, actual)
+	}
+	return nil
+}
+
+// reupload streams the file from the source storage to the target storage in
+// parts of copyChunkSize, and returns the sha256 of the file.
+func (c *backupCopier) reupload(ctx context.Context, name string) ([]byte, error) {
+	reader, err := c.src.Open(ctx, name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer reader.Close()
+	writer, err := c.target.Create(ctx, name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	h := sha256.New()
+	buf := make([]byte, copyChunkSize)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			if _, werr := writer.Write(ctx, buf[:n]); werr != nil {
+				return nil, errors.Trace(werr)
+			}
+			atomic.AddUint64(&c.reuploadedBytes, uint64(n))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if err = writer.Close(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return h.Sum(nil), nil
+}
+
+func readFileTo(ctx context.Context, s storage.ExternalStorage, name string, h hash.Hash) error {
+	reader, err := s.Open(ctx, name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer reader.Close()
+	_, err = io.Copy(h, reader)
+	return errors.Trace(err)
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/gluetikv"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type testBackupCopySuite struct{}
+
+var _ = Suite(&testBackupCopySuite{})
+
+func (s *testBackupCopySuite) writeBackup(c *C, dir string, files map[string]string) storage.ExternalStorage {
+	ctx := context.Background()
+	src, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	meta := &backuppb.BackupMeta{}
+	for name, content := range files {
+		c.Assert(src.WriteFile(ctx, name, []byte(content)), IsNil)
+		checksum := sha256.Sum256([]byte(content))
+		meta.Files = append(meta.Files, &backuppb.File{Name: name, Sha256: checksum[:]})
+	}
+	data, err := meta.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(src.WriteFile(ctx, metautil.LockFile, []byte("lock")), IsNil)
+	c.Assert(src.WriteFile(ctx, metautil.MetaFile, data), IsNil)
+	return src
+}
+
+func (s *testBackupCopySuite) TestRunBackupCopy(c *C) {
+	ctx := context.Background()
+	srcDir, targetDir := c.MkDir(), c.MkDir()
+	files := map[string]string{
+		"1_2_write.sst":   "write cf",
+		"1_3_default.sst": "default cf",
+	}
+	src := s.writeBackup(c, srcDir, files)
+
+	cfg := &BackupCopyConfig{
+		Config:        Config{Storage: "local://" + srcDir, Concurrency: 2, LogProgress: true},
+		TargetStorage: "local://" + targetDir,
+	}
+	err := RunBackupCopy(ctx, gluetikv.Glue{}, "Backup copy", cfg)
+	c.Assert(err, IsNil)
+	target, err := storage.NewLocalStorage(targetDir)
+	c.Assert(err, IsNil)
+	for _, name := range []string{"1_2_write.sst", "1_3_default.sst", metautil.LockFile, metautil.MetaFile} {
+		expected, err := src.ReadFile(ctx, name)
+		c.Assert(err, IsNil)
+		actual, err := target.ReadFile(ctx, name)
+		c.Assert(err, IsNil)
+		c.Assert(actual, DeepEquals, expected)
+	}
+
+	// the target must not contain another backup.
+	err = RunBackupCopy(ctx, gluetikv.Glue{}, "Backup copy", cfg)
+	c.Assert(err, ErrorMatches, ".*backupmeta file exists in .*")
+}
+
+func (s *testBackupCopySuite) TestCopyCorruptedBackup(c *C) {
+	ctx := context.Background()
+	src := s.writeBackup(c, c.MkDir(), map[string]string{"1_2_write.sst": "write cf"})
+	c.Assert(src.WriteFile(ctx, "1_2_write.sst", []byte("corrupted")), IsNil)
+
+	target, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	files, err := listBackupFiles(ctx, src)
+	c.Assert(err, IsNil)
+	checksum := sha256.Sum256([]byte("write cf"))
+	copier := newBackupCopier(src, target, map[string][]byte{"1_2_write.sst": checksum[:]})
+	c.Assert(copier.serverSide, IsNil)
+	err = copier.copyFiles(ctx, files, 2, &countingProgress{})
+	c.Assert(err, ErrorMatches, "failed to copy 1_2_write.sst: the source file is corrupted.*")
+
+	// the backupmeta is not copied if any other file fails.
+	exist, err := target.FileExists(ctx, metautil.MetaFile)
+	c.Assert(err, IsNil)
+	c.Assert(exist, IsFalse)
+}
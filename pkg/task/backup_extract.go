@@ -0,0 +1,269 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// BackupExtractConfig is the configuration of `br backup extract`.
+type BackupExtractConfig struct {
+	Config
+
+	TargetStorage string `json:"target-storage" toml:"target-storage"`
+}
+
+// DefineBackupExtractFlags defines the flags of `br backup extract`.
+func DefineBackupExtractFlags(flags *pflag.FlagSet) {
+	flags.String(flagCopyTargetStorage, "",
+		`specify the url where the extracted backup is written to, eg, "s3://share-bucket/path/prefix"`)
+}
+
+// ParseFromFlags parses the backup extract config from the flag set.
+func (cfg *BackupExtractConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.TargetStorage, err = flags.GetString(flagCopyTargetStorage); err != nil {
+		return errors.Trace(err)
+	}
+	if err = cfg.Config.ParseFromFlags(flags); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Storage == "" || cfg.TargetStorage == "" {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s and --%s must be specified", flagStorage, flagCopyTargetStorage)
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = defaultCopyConcurrency
+	}
+	return nil
+}
+
+// RunBackupExtract extracts the tables matching the filter from an existing
+// backup into a standalone backup, which can be shared or restored without
+// the rest of the backup. The files of the tables are copied as in
+// RunBackupCopy, and a backupmeta containing only the tables is written.
+func RunBackupExtract(c context.Context, g glue.Glue, cmdName string, cfg *BackupExtractConfig) error {
+	defer summary.Summary(cmdName)
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	_, src, backupMeta, err := ReadBackupMeta(ctx, metautil.MetaFile, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if backupMeta.IsRawKv {
+		return errors.Annotate(berrors.ErrRestoreModeMismatch, "cannot extract tables from raw kv backup")
+	}
+	reader := metautil.NewMetaReader(backupMeta, src)
+	tables, err := loadMatchedTables(ctx, reader, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(tables) == 0 {
+		return errors.Annotate(berrors.ErrInvalidArgument, "no table in the backup matches the filter")
+	}
+
+	u, err := storage.ParseBackend(cfg.TargetStorage, &cfg.BackendOptions)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	target, err := storage.New(ctx, u, storageOpts(&cfg.Config))
+	if err != nil {
+		return errors.Annotate(err, "create target storage failed")
+	}
+	if err = checkNoBackup(ctx, target); err != nil {
+		return errors.Trace(err)
+	}
+	if err = target.WriteFile(ctx, metautil.LockFile, []byte("DO NOT DELETE\n"+
+		"This file exists to remind other backup jobs won't use this path")); err != nil {
+		return errors.Trace(err)
+	}
+
+	var files []string
+	checksums := make(map[string][]byte)
+	for _, table := range tables {
+		for _, file := range table.Files {
+			files = append(files, file.Name)
+			if len(file.Sha256) > 0 {
+				checksums[file.Name] = file.Sha256
+			}
+		}
+	}
+	sort.Strings(files)
+	updateCh := cfg.startProgressPrinter(ctx, g, cmdName, int64(len(files)))
+	copier := newBackupCopier(src, target, checksums)
+	err = copier.copyFiles(ctx, files, uint(cfg.Concurrency), updateCh)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	updateCh.Close()
+
+	info, err := metautil.ReadClusterInfo(ctx, src)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if info != nil {
+		if err = metautil.WriteClusterInfo(ctx, target, info); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	// the backupmeta is written after the files, so an interrupted extract is
+	// never mistaken for a complete backup.
+	if err = writeExtractedMeta(ctx, target, backupMeta, reader, tables); err != nil {
+		return errors.Trace(err)
+	}
+	if syncer, ok := target.(storage.Syncer); ok {
+		if err = syncer.Sync(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	summary.CollectInt("extracted tables", len(tables))
+	summary.CollectInt("copied files", len(files))
+	summary.CollectInt("server-side copied files", int(copier.serverSideCopied))
+	log.Info("backup extracted", zap.String("source", src.URI()), zap.String("target", target.URI()),
+		zap.Int("tables", len(tables)), zap.Int("files", len(files)))
+	summary.SetSuccessStatus(true)
+	return nil
+}
+
+// loadMatchedTables loads the tables matching the filter of the config, in the
+// order of the names.
+func loadMatchedTables(ctx context.Context, reader *metautil.MetaReader, cfg *BackupExtractConfig) ([]*metautil.Table, error) {
+	databases, err := utils.LoadBackupTables(ctx, reader)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var tables []*metautil.Table
+	for _, db := range databases {
+		for _, table := range db.Tables {
+			if cfg.TableFilter.MatchTable(db.Info.Name.O, table.Info.Name.O) {
+				tables = append(tables, table)
+			}
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].DB.Name.L != tables[j].DB.Name.L {
+			return tables[i].DB.Name.L < tables[j].DB.Name.L
+		}
+		return tables[i].Info.Name.L < tables[j].Info.Name.L
+	})
+	return tables, nil
+}
+
+// writeExtractedMeta writes the backupmeta of the tables into the target
+// storage, in the same version as the source backupmeta. The files keep their
+// names, so the references are still relative to the root of the storage.
+func writeExtractedMeta(
+	ctx context.Context,
+	target storage.ExternalStorage,
+	backupMeta *backuppb.BackupMeta,
+	reader *metautil.MetaReader,
+	tables []*metautil.Table,
+) error {
+	writer := metautil.NewMetaWriter(target, metautil.MetaFileSize, backupMeta.Version == metautil.MetaV2)
+	writer.Update(func(m *backuppb.BackupMeta) {
+		m.StartVersion = backupMeta.StartVersion
+		m.EndVersion = backupMeta.EndVersion
+		m.ClusterId = backupMeta.ClusterId
+		m.ClusterVersion = backupMeta.ClusterVersion
+		m.BrVersion = backupMeta.BrVersion
+	})
+
+	// the DDL jobs of an incremental backup are replayed by the restore, keep
+	// those of the tables.
+	ddls, err := reader.ReadDDLs(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var jobs []*model.Job
+	if len(ddls) > 0 {
+		if err = json.Unmarshal(ddls, &jobs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	tableIDs := make(map[int64]struct{}, len(tables))
+	for _, table := range tables {
+		tableIDs[table.Info.ID] = struct{}{}
+	}
+	writer.StartWriteMetasAsync(ctx, metautil.AppendDDL)
+	for _, job := range jobs {
+		if _, ok := tableIDs[job.TableID]; !ok {
+			continue
+		}
+		jobBytes, err := json.Marshal(job)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = writer.Send(jobBytes, metautil.AppendDDL); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err = writer.FinishWriteMetas(ctx, metautil.AppendDDL); err != nil {
+		return errors.Trace(err)
+	}
+
+	writer.StartWriteMetasAsync(ctx, metautil.AppendDataFile)
+	for _, table := range tables {
+		if err = writer.Send(table.Files, metautil.AppendDataFile); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err = writer.FinishWriteMetas(ctx, metautil.AppendDataFile); err != nil {
+		return errors.Trace(err)
+	}
+
+	writer.StartWriteMetasAsync(ctx, metautil.AppendSchema)
+	for _, table := range tables {
+		schema, err := tableToSchema(table)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = writer.Send(schema, metautil.AppendSchema); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(writer.FinishWriteMetas(ctx, metautil.AppendSchema))
+}
+
+func tableToSchema(table *metautil.Table) (*backuppb.Schema, error) {
+	dbData, err := json.Marshal(table.DB)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	tableData, err := json.Marshal(table.Info)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var statsData []byte
+	if table.Stats != nil {
+		if statsData, err = json.Marshal(table.Stats); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return &backuppb.Schema{
+		Db:              dbData,
+		Table:           tableData,
+		Crc64Xor:        table.Crc64Xor,
+		TotalKvs:        table.TotalKvs,
+		TotalBytes:      table.TotalBytes,
+		TiflashReplicas: uint32(table.TiFlashReplicas),
+		Stats:           statsData,
+	}, nil
+}
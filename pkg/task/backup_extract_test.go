@@ -0,0 +1,97 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb/tablecodec"
+
+	"github.com/pingcap/br/pkg/gluetikv"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type testBackupExtractSuite struct{}
+
+var _ = Suite(&testBackupExtractSuite{})
+
+func (s *testBackupExtractSuite) writeBackup(c *C, dir string) {
+	ctx := context.Background()
+	src, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	db := &model.DBInfo{ID: 1, Name: model.NewCIStr("test")}
+	dbData, err := json.Marshal(db)
+	c.Assert(err, IsNil)
+	meta := &backuppb.BackupMeta{EndVersion: 42, Ddls: []byte("[]")}
+	for _, tbl := range []*model.TableInfo{
+		{ID: 10, Name: model.NewCIStr("t1")},
+		{ID: 20, Name: model.NewCIStr("t2")},
+	} {
+		tableData, err := json.Marshal(tbl)
+		c.Assert(err, IsNil)
+		meta.Schemas = append(meta.Schemas, &backuppb.Schema{Db: dbData, Table: tableData, TotalKvs: 1})
+		name := tbl.Name.O + "_write.sst"
+		c.Assert(src.WriteFile(ctx, name, []byte(tbl.Name.O)), IsNil)
+		meta.Files = append(meta.Files, &backuppb.File{
+			Name:     name,
+			StartKey: tablecodec.EncodeTablePrefix(tbl.ID),
+			EndKey:   tablecodec.EncodeTablePrefix(tbl.ID + 1),
+			TotalKvs: 1,
+		})
+	}
+	data, err := meta.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(src.WriteFile(ctx, metautil.MetaFile, data), IsNil)
+}
+
+func (s *testBackupExtractSuite) TestRunBackupExtract(c *C) {
+	ctx := context.Background()
+	srcDir, targetDir := c.MkDir(), c.MkDir()
+	s.writeBackup(c, srcDir)
+
+	cfg := &BackupExtractConfig{
+		Config: Config{
+			Storage:     "local://" + srcDir,
+			Concurrency: 2,
+			LogProgress: true,
+			TableFilter: filter.NewTablesFilter(filter.Table{Schema: "test", Name: "t1"}),
+		},
+		TargetStorage: "local://" + targetDir,
+	}
+	err := RunBackupExtract(ctx, gluetikv.Glue{}, "Backup extract", cfg)
+	c.Assert(err, IsNil)
+
+	target, err := storage.NewLocalStorage(targetDir)
+	c.Assert(err, IsNil)
+	content, err := target.ReadFile(ctx, "t1_write.sst")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "t1")
+	exist, err := target.FileExists(ctx, "t2_write.sst")
+	c.Assert(err, IsNil)
+	c.Assert(exist, IsFalse)
+
+	data, err := target.ReadFile(ctx, metautil.MetaFile)
+	c.Assert(err, IsNil)
+	meta := &backuppb.BackupMeta{}
+	c.Assert(meta.Unmarshal(data), IsNil)
+	c.Assert(meta.EndVersion, Equals, uint64(42))
+	c.Assert(meta.Files, HasLen, 1)
+	c.Assert(meta.Files[0].Name, Equals, "t1_write.sst")
+	c.Assert(meta.Schemas, HasLen, 1)
+	tbl := &model.TableInfo{}
+	c.Assert(json.Unmarshal(meta.Schemas[0].Table, tbl), IsNil)
+	c.Assert(tbl.Name.O, Equals, "t1")
+	c.Assert(meta.Schemas[0].TotalKvs, Equals, uint64(1))
+
+	// no table matches.
+	cfg.TableFilter = filter.NewTablesFilter(filter.Table{Schema: "test", Name: "t3"})
+	cfg.TargetStorage = "local://" + c.MkDir()
+	err = RunBackupExtract(ctx, gluetikv.Glue{}, "Backup extract", cfg)
+	c.Assert(err, ErrorMatches, ".*no table in the backup matches the filter.*")
+}
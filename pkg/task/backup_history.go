@@ -0,0 +1,289 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/rtree"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+)
+
+const (
+	historyPhaseBackup   = "backup"
+	historyPhaseChecksum = "checksum"
+
+	// maxHistoryRuns is how many recent runs of a cluster are kept.
+	maxHistoryRuns = 20
+	// historyCompareRuns is how many recent runs the estimations and the
+	// comparisons are based on.
+	historyCompareRuns = 5
+	// slowWarnRatio is how much longer than expected a phase takes to be
+	// flagged as slow.
+	slowWarnRatio = 0.5
+	// maxEstimatedRanges is the max number of the ranges whose sizes are
+	// requested one by one, more ranges are estimated as a whole.
+	maxEstimatedRanges = 100
+)
+
+// backupHistory is the throughput of the recent backups by the clusters, it's
+// persisted in the history file to estimate the durations of the later ones.
+type backupHistory struct {
+	// Clusters are the runs by the cluster IDs, the oldest first.
+	Clusters map[string][]backupRun `json:"clusters"`
+}
+
+// backupRun is the throughput of a finished backup.
+type backupRun struct {
+	StartTime time.Time `json:"start-time"`
+	// Bytes is the size of the ranges estimated by PD at the start, so that
+	// the runs are comparable by the estimations of the later ones.
+	Bytes uint64 `json:"bytes"`
+	// Durations are the seconds taken by the phases.
+	Durations map[string]float64 `json:"durations"`
+}
+
+// recentRuns returns the last runs of the cluster, the oldest first.
+func (h *backupHistory) recentRuns(clusterID uint64) []backupRun {
+	runs := h.Clusters[strconv.FormatUint(clusterID, 10)]
+	if len(runs) > historyCompareRuns {
+		runs = runs[len(runs)-historyCompareRuns:]
+	}
+	return runs
+}
+
+// addRun appends the run of the cluster and drops the oldest runs beyond
+// maxHistoryRuns.
+func (h *backupHistory) addRun(clusterID uint64, run backupRun) {
+	if h.Clusters == nil {
+		h.Clusters = make(map[string][]backupRun)
+	}
+	key := strconv.FormatUint(clusterID, 10)
+	runs := append(h.Clusters[key], run)
+	if len(runs) > maxHistoryRuns {
+		runs = runs[len(runs)-maxHistoryRuns:]
+	}
+	h.Clusters[key] = runs
+}
+
+// averageRate returns the bytes per second of the phase in the runs, 0 if
+// none of the runs took the phase.
+func averageRate(runs []backupRun, phase string) float64 {
+	var size, seconds float64
+	for _, run := range runs {
+		if d := run.Durations[phase]; d > 0 && run.Bytes > 0 {
+			size += float64(run.Bytes)
+			seconds += d
+		}
+	}
+	if seconds == 0 {
+		return 0
+	}
+	return size / seconds
+}
+
+// estimateDuration returns how long the phases take for the size by the
+// rates of the runs, 0 if any phase is unknown.
+func estimateDuration(runs []backupRun, size uint64, phases ...string) time.Duration {
+	var seconds float64
+	for _, phase := range phases {
+		rate := averageRate(runs, phase)
+		if rate == 0 {
+			return 0
+		}
+		seconds += float64(size) / rate
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// slowPhases reports the phases of the run taking slowWarnRatio longer than
+// expected by the rates of the runs before.
+func slowPhases(runs []backupRun, run *backupRun) []string {
+	var warnings []string
+	for _, phase := range []string{historyPhaseBackup, historyPhaseChecksum} {
+		d, ok := run.Durations[phase]
+		rate := averageRate(runs, phase)
+		if !ok || rate == 0 || run.Bytes == 0 {
+			continue
+		}
+		expected := float64(run.Bytes) / rate
+		if slower := d/expected - 1; slower >= slowWarnRatio {
+			warnings = append(warnings, fmt.Sprintf(
+				"the %s phase is %.0f%% slower than the average of the last %d runs", phase, slower*100, len(runs)))
+		}
+	}
+	return warnings
+}
+
+// estimateRangesSize returns the size of the ranges estimated by PD. Too many
+// ranges are estimated by the span covering all of them.
+func estimateRangesSize(ctx context.Context, cli *pdutil.HTTPClient, ranges []rtree.Range) (uint64, error) {
+	if len(ranges) > maxEstimatedRanges {
+		span := rtree.Range{StartKey: ranges[0].StartKey, EndKey: ranges[0].EndKey}
+		for _, r := range ranges[1:] {
+			if bytes.Compare(r.StartKey, span.StartKey) < 0 {
+				span.StartKey = r.StartKey
+			}
+			if len(span.EndKey) != 0 && (len(r.EndKey) == 0 || bytes.Compare(r.EndKey, span.EndKey) > 0) {
+				span.EndKey = r.EndKey
+			}
+		}
+		ranges = []rtree.Range{span}
+	}
+	var size uint64
+	for _, r := range ranges {
+		stats, err := cli.GetRangeStats(ctx, r.StartKey, r.EndKey)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		size += uint64(stats.StorageSize) * units.MiB
+	}
+	return size, nil
+}
+
+// openFileStorage opens the storage of the file, which is either a local path
+// or an external storage URL, and returns the name of the file in it.
+func openFileStorage(ctx context.Context, cfg *Config, file string) (storage.ExternalStorage, string, error) {
+	dir, name := filepath.Dir(file), filepath.Base(file)
+	if strings.Contains(file, "://") {
+		u, err := url.Parse(file)
+		if err != nil {
+			return nil, "", errors.Annotatef(berrors.ErrInvalidArgument, "invalid file %s: %s", file, err)
+		}
+		if u.Path == "" || strings.HasSuffix(u.Path, "/") {
+			return nil, "", errors.Annotatef(berrors.ErrInvalidArgument, "%s should be a file", file)
+		}
+		name = path.Base(u.Path)
+		u.Path = path.Dir(u.Path)
+		dir = u.String()
+	}
+	backend, err := storage.ParseBackend(dir, &cfg.BackendOptions)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	s, err := storage.New(ctx, backend, storageOpts(cfg))
+	if err != nil {
+		return nil, "", errors.Annotatef(err, "failed to open the storage of %s", file)
+	}
+	return s, name, nil
+}
+
+// historyRecorder estimates the backup by the history file and records the
+// throughput of the backup into it. A nil historyRecorder records nothing.
+type historyRecorder struct {
+	storage   storage.ExternalStorage
+	name      string
+	clusterID uint64
+	history   backupHistory
+	run       backupRun
+	// phaseStart is when the current phase started.
+	phaseStart time.Time
+}
+
+// startHistoryRecorder loads the history file and reports the duration of the
+// backup estimated by the recent runs of the cluster. The backup isn't
+// recorded if the history file can't be loaded or the size can't be
+// estimated.
+func startHistoryRecorder(
+	ctx context.Context,
+	cfg *BackupConfig,
+	cli *pdutil.HTTPClient,
+	clusterID uint64,
+	ranges []rtree.Range,
+) *historyRecorder {
+	s, name, err := openFileStorage(ctx, &cfg.Config, cfg.HistoryFile)
+	if err != nil {
+		log.Warn("failed to open the history file, the backup isn't recorded", zap.Error(err))
+		return nil
+	}
+	r := &historyRecorder{storage: s, name: name, clusterID: clusterID}
+	exists, err := s.FileExists(ctx, name)
+	if err == nil && exists {
+		var data []byte
+		if data, err = s.ReadFile(ctx, name); err == nil {
+			err = json.Unmarshal(data, &r.history)
+		}
+	}
+	if err != nil {
+		log.Warn("failed to load the history file, the backup isn't recorded",
+			zap.String("file", cfg.HistoryFile), zap.Error(err))
+		return nil
+	}
+	size, err := estimateRangesSize(ctx, cli, ranges)
+	if err != nil {
+		log.Warn("failed to estimate the size of the backup, the backup isn't recorded", zap.Error(err))
+		return nil
+	}
+	r.run = backupRun{StartTime: time.Now(), Bytes: size, Durations: make(map[string]float64)}
+
+	runs := r.history.recentRuns(clusterID)
+	phases := []string{historyPhaseBackup}
+	if cfg.Checksum {
+		phases = append(phases, historyPhaseChecksum)
+	}
+	if eta := estimateDuration(runs, size, phases...); eta > 0 {
+		log.Info("estimate the backup by the recent runs",
+			zap.String("size", units.HumanSize(float64(size))),
+			zap.Int("runs", len(runs)),
+			zap.Duration("duration", eta))
+		summary.CollectDuration("estimated duration", eta)
+	}
+	return r
+}
+
+// startPhase marks the start of a phase.
+func (r *historyRecorder) startPhase() {
+	if r == nil {
+		return
+	}
+	r.phaseStart = time.Now()
+}
+
+// finishPhase records how long the phase took since startPhase.
+func (r *historyRecorder) finishPhase(phase string) {
+	if r == nil {
+		return
+	}
+	r.run.Durations[phase] = time.Since(r.phaseStart).Seconds()
+}
+
+// finish flags the phases slower than the recent runs in the summary, and
+// saves the run into the history file.
+func (r *historyRecorder) finish(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	warnings := slowPhases(r.history.recentRuns(r.clusterID), &r.run)
+	for _, warning := range warnings {
+		logutil.WarnTerm(warning)
+	}
+	if len(warnings) > 0 {
+		summary.CollectString("slowness", strings.Join(warnings, "; "))
+	}
+	r.history.addRun(r.clusterID, r.run)
+	data, err := json.Marshal(&r.history)
+	if err == nil {
+		err = r.storage.WriteFile(ctx, r.name, data)
+	}
+	if err != nil {
+		log.Warn("failed to save the history file", zap.String("file", r.name), zap.Error(err))
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/go-units"
+	. "github.com/pingcap/check"
+)
+
+type testBackupHistorySuite struct{}
+
+var _ = Suite(&testBackupHistorySuite{})
+
+func runOf(size uint64, backup, checksum float64) backupRun {
+	durations := map[string]float64{historyPhaseBackup: backup}
+	if checksum > 0 {
+		durations[historyPhaseChecksum] = checksum
+	}
+	return backupRun{Bytes: size, Durations: durations}
+}
+
+func (s *testBackupHistorySuite) TestEstimate(c *C) {
+	h := &backupHistory{}
+	c.Assert(h.recentRuns(1), HasLen, 0)
+	c.Assert(estimateDuration(nil, units.GiB, historyPhaseBackup), Equals, time.Duration(0))
+
+	// 100 MiB/s backup and 200 MiB/s checksum.
+	h.addRun(1, runOf(10000*units.MiB, 100, 50))
+	h.addRun(1, runOf(20000*units.MiB, 200, 0))
+	h.addRun(2, runOf(units.GiB, 1, 1))
+	runs := h.recentRuns(1)
+	c.Assert(runs, HasLen, 2)
+	c.Assert(averageRate(runs, historyPhaseBackup), Equals, float64(100*units.MiB))
+	c.Assert(averageRate(runs, historyPhaseChecksum), Equals, float64(200*units.MiB))
+	c.Assert(estimateDuration(runs, 50*units.GiB, historyPhaseBackup), Equals, 512*time.Second)
+	c.Assert(estimateDuration(runs, 50*units.GiB, historyPhaseBackup, historyPhaseChecksum), Equals, 768*time.Second)
+	c.Assert(estimateDuration(h.recentRuns(3), units.GiB, historyPhaseBackup), Equals, time.Duration(0))
+
+	for i := 0; i < maxHistoryRuns; i++ {
+		h.addRun(1, runOf(units.GiB, 1, 1))
+	}
+	c.Assert(h.Clusters["1"], HasLen, maxHistoryRuns)
+	c.Assert(h.recentRuns(1), HasLen, historyCompareRuns)
+}
+
+func (s *testBackupHistorySuite) TestSlowPhases(c *C) {
+	runs := []backupRun{runOf(10000*units.MiB, 100, 50), runOf(10000*units.MiB, 100, 50)}
+
+	run := runOf(10000*units.MiB, 140, 50)
+	c.Assert(slowPhases(runs, &run), HasLen, 0)
+	run = runOf(20000*units.MiB, 300, 100)
+	warnings := slowPhases(runs, &run)
+	c.Assert(warnings, HasLen, 1)
+	c.Assert(warnings[0], Equals, "the backup phase is 50% slower than the average of the last 2 runs")
+	// the checksum is skipped.
+	run = runOf(units.GiB, 10, 0)
+	c.Assert(slowPhases(runs, &run), HasLen, 0)
+	c.Assert(slowPhases(nil, &run), HasLen, 0)
+}
+
+func (s *testBackupHistorySuite) TestHistoryFile(c *C) {
+	ctx := context.Background()
+	file := filepath.Join(c.MkDir(), "history.json")
+	cfg := &BackupConfig{HistoryFile: file}
+
+	st, name, err := openFileStorage(ctx, &cfg.Config, file)
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, "history.json")
+	r := &historyRecorder{storage: st, name: name, clusterID: 1, run: runOf(units.GiB, 0, 0)}
+	r.startPhase()
+	r.finishPhase(historyPhaseBackup)
+	r.finish(ctx)
+
+	exists, err := st.FileExists(ctx, name)
+	c.Assert(err, IsNil)
+	c.Assert(exists, IsTrue)
+
+	_, _, err = openFileStorage(ctx, &cfg.Config, "s3://bucket/")
+	c.Assert(err, ErrorMatches, ".*should be a file.*")
+
+	// a nil recorder records nothing.
+	var nilRecorder *historyRecorder
+	nilRecorder.startPhase()
+	nilRecorder.finishPhase(historyPhaseBackup)
+	nilRecorder.finish(ctx)
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const flagMirror = "mirror"
+
+// openMirrors opens the mirror storages of the backup, which must not contain
+// another backup.
+func openMirrors(ctx context.Context, cfg *BackupConfig, opts *storage.ExternalStorageOptions) ([]storage.ExternalStorage, error) {
+	mirrors := make([]storage.ExternalStorage, 0, len(cfg.Mirrors))
+	for _, rawURL := range cfg.Mirrors {
+		u, err := storage.ParseBackend(rawURL, &cfg.BackendOptions)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		s, err := storage.New(ctx, u, opts)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to open mirror %s", rawURL)
+		}
+		if err = checkNoBackup(ctx, s); err != nil {
+			return nil, errors.Trace(err)
+		}
+		mirrors = append(mirrors, s)
+	}
+	return mirrors, nil
+}
+
+// listBackupFiles lists all the files of the backup. The backupmeta, if
+// exists, is the last one.
+func listBackupFiles(ctx context.Context, s storage.ExternalStorage) ([]string, error) {
+	files := make([]string, 0)
+	hasMeta := false
+	err := s.WalkDir(ctx, &storage.WalkOption{}, func(path string, _ int64) error {
+		if path == metautil.MetaFile {
+			hasMeta = true
+		} else {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sort.Strings(files)
+	if hasMeta {
+		files = append(files, metautil.MetaFile)
+	}
+	return files, nil
+}
+
+// mirrorBackup copies the files of the finished backup from src to each of
+// the mirrors, and verifies the copies by reading them back. The backupmeta is
+// copied after all the other files, so a mirror interrupted in the middle is
+// never mistaken for a complete backup.
+func mirrorBackup(
+	ctx context.Context,
+	src storage.ExternalStorage,
+	mirrors []storage.ExternalStorage,
+	files []string,
+	concurrency uint,
+	updateCh glue.Progress,
+) error {
+	dataFiles, metaFiles := files, []string(nil)
+	if len(files) > 0 && files[len(files)-1] == metautil.MetaFile {
+		dataFiles, metaFiles = files[:len(files)-1], files[len(files)-1:]
+	}
+
+	pool := utils.NewWorkerPool(concurrency, "mirror")
+	for _, mirror := range mirrors {
+		for _, batch := range [][]string{dataFiles, metaFiles} {
+			eg, ectx := errgroup.WithContext(ctx)
+			for _, file := range batch {
+				mirror, file := mirror, file
+				pool.ApplyOnErrorGroup(eg, func() error {
+					if err := copyAndVerifyFile(ectx, src, mirror, file); err != nil {
+						return errors.Trace(err)
+					}
+					updateCh.Inc()
+					return nil
+				})
+			}
+			if err := eg.Wait(); err != nil {
+				return errors.Annotatef(err, "failed to mirror the backup to %s", mirror.URI())
+			}
+		}
+		if syncer, ok := mirror.(storage.Syncer); ok {
+			if err := syncer.Sync(ctx); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		log.Info("backup mirrored", zap.String("mirror", mirror.URI()), zap.Int("files", len(files)))
+	}
+	return nil
+}
+
+func copyAndVerifyFile(ctx context.Context, src, dest storage.ExternalStorage, name string) error {
+	data, err := src.ReadFile(ctx, name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = dest.WriteFile(ctx, name, data); err != nil {
+		return errors.Trace(err)
+	}
+	copied, err := dest.ReadFile(ctx, name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	expected, actual := sha256.Sum256(data), sha256.Sum256(copied)
+	if !bytes.Equal(expected[:], actual[:]) {
+		return errors.Annotatef(berrors.ErrBackupChecksumMismatch,
+			"the copy of %s is corrupted, expected sha256 %x, got %x", name, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"sync/atomic"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type testBackupMirrorSuite struct{}
+
+var _ = Suite(&testBackupMirrorSuite{})
+
+// corruptedStorage flips the first byte of every written file.
+type corruptedStorage struct {
+	storage.ExternalStorage
+}
+
+func (s corruptedStorage) WriteFile(ctx context.Context, name string, data []byte) error {
+	corrupted := append([]byte{}, data...)
+	corrupted[0] ^= 0xff
+	return s.ExternalStorage.WriteFile(ctx, name, corrupted)
+}
+
+type countingProgress struct {
+	count int64
+}
+
+func (p *countingProgress) Inc() {
+	atomic.AddInt64(&p.count, 1)
+}
+
+func (p *countingProgress) Close() {}
+
+func (s *testBackupMirrorSuite) TestMirrorBackup(c *C) {
+	ctx := context.Background()
+	src, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	for _, name := range []string{metautil.MetaFile, metautil.LockFile, "1_2_write.sst", "1_3_default.sst", "backupmeta.datafile.000000001"} {
+		c.Assert(src.WriteFile(ctx, name, []byte("content of "+name)), IsNil)
+	}
+
+	files, err := listBackupFiles(ctx, src)
+	c.Assert(err, IsNil)
+	c.Assert(files, DeepEquals, []string{
+		"1_2_write.sst", "1_3_default.sst", metautil.LockFile, "backupmeta.datafile.000000001", metautil.MetaFile,
+	})
+
+	mirror1, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	mirror2, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	progress := &countingProgress{}
+	err = mirrorBackup(ctx, src, []storage.ExternalStorage{mirror1, mirror2}, files, 2, progress)
+	c.Assert(err, IsNil)
+	c.Assert(atomic.LoadInt64(&progress.count), Equals, int64(10))
+	for _, mirror := range []storage.ExternalStorage{mirror1, mirror2} {
+		for _, name := range files {
+			data, err := mirror.ReadFile(ctx, name)
+			c.Assert(err, IsNil)
+			c.Assert(string(data), Equals, "content of "+name)
+		}
+	}
+
+	// the backupmeta is not copied if any other file fails.
+	bad, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	err = mirrorBackup(ctx, src, []storage.ExternalStorage{corruptedStorage{bad}}, files, 2, &countingProgress{})
+	c.Assert(err, ErrorMatches, "failed to mirror the backup to .*: the copy of .* is corrupted.*")
+	exist, err := bad.FileExists(ctx, metautil.MetaFile)
+	c.Assert(err, IsNil)
+	c.Assert(exist, IsFalse)
+}
+
+func (s *testBackupMirrorSuite) TestOpenMirrors(c *C) {
+	ctx := context.Background()
+	dir := c.MkDir()
+	cfg := &BackupConfig{Mirrors: []string{"local://" + dir}}
+	mirrors, err := openMirrors(ctx, cfg, &storage.ExternalStorageOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(mirrors, HasLen, 1)
+
+	c.Assert(mirrors[0].WriteFile(ctx, metautil.LockFile, []byte("lock")), IsNil)
+	_, err = openMirrors(ctx, cfg, &storage.ExternalStorageOptions{})
+	c.Assert(err, ErrorMatches, ".*backup.lock file exists in .*")
+}
@@ -0,0 +1,317 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"github.com/tikv/client-go/v2/oracle"
+	pd "github.com/tikv/pd/client"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagMultiBackupCluster = "cluster"
+
+	// MultiBackupManifestFile is the manifest of a multi-cluster backup, which
+	// lists the backups of the clusters taken at the same TSO.
+	MultiBackupManifestFile = "multibackup.json"
+
+	// maxBackupTSAhead is how far the backup TS can be ahead of the TSO of a
+	// cluster, which is waited for instead of failing, e.g. for the skew of
+	// the clocks.
+	maxBackupTSAhead = time.Minute
+)
+
+// MultiBackupCluster is a cluster backed up by `br backup multi`.
+type MultiBackupCluster struct {
+	Name string   `json:"name" toml:"name"`
+	PD   []string `json:"pd" toml:"pd"`
+}
+
+// MultiBackupConfig is the configuration of `br backup multi`.
+type MultiBackupConfig struct {
+	BackupConfig
+
+	Clusters []MultiBackupCluster `json:"clusters" toml:"clusters"`
+}
+
+// multiBackupManifest is the content of the MultiBackupManifestFile.
+type multiBackupManifest struct {
+	BackupTS   uint64                       `json:"backup-ts"`
+	BackupTime time.Time                    `json:"backup-time"`
+	Clusters   []multiBackupManifestCluster `json:"clusters"`
+}
+
+type multiBackupManifestCluster struct {
+	Name string   `json:"name"`
+	PD   []string `json:"pd"`
+	// Path is where the backup of the cluster is, relative to the storage of
+	// the manifest.
+	Path string `json:"path"`
+}
+
+// DefineMultiBackupFlags defines the flags of `br backup multi`.
+func DefineMultiBackupFlags(flags *pflag.FlagSet) {
+	flags.StringArray(flagMultiBackupCluster, nil,
+		`the cluster to backup in the form of "name=pd-addr[,pd-addr...]", can be specified multiple times, `+
+			`the cluster is backed up into the "name" directory of the storage`)
+}
+
+// ParseFromFlags parses the multi-cluster backup config from the flag set.
+func (cfg *MultiBackupConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	if err := cfg.BackupConfig.ParseFromFlags(flags); err != nil {
+		return errors.Trace(err)
+	}
+	clusters, err := flags.GetStringArray(flagMultiBackupCluster)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(clusters) == 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must be specified", flagMultiBackupCluster)
+	}
+	names := make(map[string]struct{}, len(clusters))
+	cfg.Clusters = cfg.Clusters[:0]
+	for _, value := range clusters {
+		cluster, err := parseMultiBackupCluster(value, cfg.TLS.IsEnabled())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if _, ok := names[cluster.Name]; ok {
+			return errors.Annotatef(berrors.ErrInvalidArgument, "cluster %s is specified more than once", cluster.Name)
+		}
+		names[cluster.Name] = struct{}{}
+		cfg.Clusters = append(cfg.Clusters, cluster)
+	}
+	if cfg.Storage == "" {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must be specified", flagStorage)
+	}
+	return nil
+}
+
+func parseMultiBackupCluster(value string, useTLS bool) (MultiBackupCluster, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.ContainsAny(parts[0], `/\`) {
+		return MultiBackupCluster{}, errors.Annotatef(berrors.ErrInvalidArgument,
+			"cluster %q is not in the form of \"name=pd-addr[,pd-addr...]\"", value)
+	}
+	cluster := MultiBackupCluster{Name: parts[0]}
+	for _, addr := range strings.Split(parts[1], ",") {
+		addr, err := normalizePDURL(addr, useTLS)
+		if err != nil {
+			return MultiBackupCluster{}, errors.Trace(err)
+		}
+		cluster.PD = append(cluster.PD, addr)
+	}
+	return cluster, nil
+}
+
+// joinStorageURL returns the URL of the directory in the storage.
+func joinStorageURL(rawURL, dir string) (string, error) {
+	u, err := storage.ParseRawURL(rawURL)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	u.Path = path.Join(u.Path, dir)
+	return u.String(), nil
+}
+
+// RunMultiBackup backs up several clusters at the same TSO, so the backups are
+// mutually consistent, e.g. for the shards of an application across clusters.
+//
+// The backup TS is taken from --backupts, or the current time of BR minus
+// --timeago. The TSO is the time since it's how PD allocates them, and the
+// backup of a cluster waits until the TSO of the cluster passes it, so no
+// transaction of the cluster commits before the backup TS later. After the TSO
+// of every cluster passes the backup TS, the clusters are backed up one by one
+// into their own directories in the storage, so each backup has its own
+// summary and progress, while the GC safe points of the clusters not backed up
+// yet are held at the backup TS. The manifest listing the backups is written
+// after all of them succeed.
+func RunMultiBackup(c context.Context, g glue.Glue, cmdName string, cfg *MultiBackupConfig) error {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	u, err := storage.ParseBackend(cfg.Storage, &cfg.BackendOptions)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	root, err := storage.New(ctx, u, storageOpts(&cfg.Config))
+	if err != nil {
+		return errors.Annotate(err, "create storage failed")
+	}
+	if err = checkNoBackup(ctx, root); err != nil {
+		return errors.Trace(err)
+	}
+	exist, err := root.FileExists(ctx, MultiBackupManifestFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if exist {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"%s exists in %s, please specify a correct backup directory", MultiBackupManifestFile, root.URI())
+	}
+
+	backupTS := cfg.BackupTS
+	if backupTS == 0 {
+		backupTS = oracle.ComposeTS(oracle.GetPhysical(time.Now().Add(-cfg.TimeAgo)), 0)
+	}
+	log.Info("multi-cluster backup starts", zap.Uint64("backupTS", backupTS),
+		zap.Time("backupTime", oracle.GetTimeFromTS(backupTS)), zap.Int("clusters", len(cfg.Clusters)))
+
+	manifest := &multiBackupManifest{BackupTS: backupTS, BackupTime: oracle.GetTimeFromTS(backupTS)}
+	backupCfgs := make([]BackupConfig, 0, len(cfg.Clusters))
+	for _, cluster := range cfg.Clusters {
+		clusterStorage, err := joinStorageURL(cfg.Storage, cluster.Name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		manifest.Clusters = append(manifest.Clusters, multiBackupManifestCluster{
+			Name: cluster.Name,
+			PD:   cluster.PD,
+			Path: cluster.Name,
+		})
+
+		backupCfg := cfg.BackupConfig
+		backupCfg.PD = cluster.PD
+		backupCfg.Storage = clusterStorage
+		backupCfg.BackupTS = backupTS
+		backupCfg.TimeAgo = 0
+		// the clusters are backed up in a single process, where the domains
+		// share the global states, e.g. whether the new collations are
+		// enabled, so they aren't loaded.
+		backupCfg.IgnoreStats = true
+		backupCfgs = append(backupCfgs, backupCfg)
+	}
+
+	// the safe points are held until all the clusters are backed up.
+	keepCtx, stopKeeping := context.WithCancel(ctx)
+	var pdClients []pd.Client
+	defer func() {
+		stopKeeping()
+		for _, pdClient := range pdClients {
+			pdClient.Close()
+		}
+	}()
+	eg, ectx := errgroup.WithContext(ctx)
+	clientCh := make(chan pd.Client, len(cfg.Clusters))
+	for _, cluster := range cfg.Clusters {
+		cluster := cluster
+		eg.Go(func() error {
+			// the client outlives the errgroup, which cancels ectx once done.
+			pdClient, err := connectClusterPD(ctx, &cfg.Config, cluster)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			clientCh <- pdClient
+			return errors.Trace(holdClusterTS(ectx, keepCtx, &cfg.BackupConfig, pdClient, cluster, backupTS))
+		})
+	}
+	err = eg.Wait()
+	close(clientCh)
+	for pdClient := range clientCh {
+		pdClients = append(pdClients, pdClient)
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for i, cluster := range cfg.Clusters {
+		if err := RunBackup(ctx, g, cmdName+" "+cluster.Name, &backupCfgs[i]); err != nil {
+			return errors.Annotatef(err, "failed to backup cluster %s", cluster.Name)
+		}
+		log.Info("cluster backed up", zap.String("cluster", cluster.Name), zap.String("storage", backupCfgs[i].Storage))
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = root.WriteFile(ctx, MultiBackupManifestFile, data); err != nil {
+		return errors.Trace(err)
+	}
+	summary.CollectInt("backup clusters", len(cfg.Clusters))
+	summary.CollectUint("backup ts", backupTS)
+	summary.SetSuccessStatus(true)
+	log.Info("multi-cluster backup finished", zap.Uint64("backupTS", backupTS), zap.String("storage", root.URI()))
+	return nil
+}
+
+func connectClusterPD(ctx context.Context, cfg *Config, cluster MultiBackupCluster) (pd.Client, error) {
+	securityOption := pd.SecurityOption{}
+	if cfg.TLS.IsEnabled() {
+		securityOption.CAPath = cfg.TLS.CA
+		securityOption.CertPath = cfg.TLS.Cert
+		securityOption.KeyPath = cfg.TLS.Key
+	}
+	pdClient, err := pd.NewClientWithContext(ctx, cluster.PD, securityOption)
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed to connect to the PD of cluster %s", cluster.Name)
+	}
+	return pdClient, nil
+}
+
+// holdClusterTS waits until the TSO of the cluster passes the ts, then keeps
+// the GC safe point of the cluster at the ts until keepCtx is done.
+func holdClusterTS(
+	ctx, keepCtx context.Context,
+	cfg *BackupConfig,
+	pdClient pd.Client,
+	cluster MultiBackupCluster,
+	ts uint64,
+) error {
+	if err := waitClusterTS(ctx, pdClient, cluster, ts); err != nil {
+		return errors.Trace(err)
+	}
+	sp := utils.BRServiceSafePoint{
+		BackupTS: ts,
+		TTL:      cfg.GCTTL,
+		ID:       utils.MakeSafePointID(),
+	}
+	if sp.TTL <= 0 {
+		sp.TTL = utils.DefaultBRGCSafePointTTL
+	}
+	if err := utils.StartServiceSafePointKeeper(keepCtx, pdClient, sp); err != nil {
+		return errors.Annotatef(err, "failed to keep the GC safe point of cluster %s", cluster.Name)
+	}
+	return nil
+}
+
+// waitClusterTS waits until the TSO of the cluster passes the ts.
+func waitClusterTS(ctx context.Context, pdClient pd.Client, cluster MultiBackupCluster, ts uint64) error {
+	for {
+		physical, logical, err := pdClient.GetTS(ctx)
+		if err != nil {
+			return errors.Annotatef(err, "failed to get the TSO of cluster %s", cluster.Name)
+		}
+		current := oracle.ComposeTS(physical, logical)
+		if current > ts {
+			return nil
+		}
+		ahead := oracle.GetTimeFromTS(ts).Sub(oracle.GetTimeFromTS(current))
+		if ahead > maxBackupTSAhead {
+			return errors.Annotatef(berrors.ErrInvalidArgument,
+				"the backup ts %d is %s ahead of the TSO %d of cluster %s", ts, ahead, current, cluster.Name)
+		}
+		log.Info("wait for the TSO of the cluster to pass the backup ts",
+			zap.String("cluster", cluster.Name), zap.Uint64("backupTS", ts),
+			zap.Uint64("currentTS", current), zap.Duration("ahead", ahead))
+		if err := utils.SleepWithContext(ctx, ahead+time.Millisecond); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
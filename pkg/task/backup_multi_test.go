@@ -0,0 +1,41 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	. "github.com/pingcap/check"
+)
+
+type testBackupMultiSuite struct{}
+
+var _ = Suite(&testBackupMultiSuite{})
+
+func (s *testBackupMultiSuite) TestParseMultiBackupCluster(c *C) {
+	cluster, err := parseMultiBackupCluster("east=http://pd1:2379,pd2:2379", false)
+	c.Assert(err, IsNil)
+	c.Assert(cluster, DeepEquals, MultiBackupCluster{Name: "east", PD: []string{"pd1:2379", "pd2:2379"}})
+
+	for _, value := range []string{"east", "=pd1:2379", "east=", "a/b=pd1:2379"} {
+		_, err = parseMultiBackupCluster(value, false)
+		c.Assert(err, ErrorMatches, ".*is not in the form of.*", Commentf("value: %s", value))
+	}
+	_, err = parseMultiBackupCluster("east=http://pd1:2379", true)
+	c.Assert(err, ErrorMatches, ".*pd url starts with http while TLS enabled.*")
+}
+
+func (s *testBackupMultiSuite) TestJoinStorageURL(c *C) {
+	cases := []struct {
+		raw      string
+		expected string
+	}{
+		{"s3://bucket/prefix", "s3://bucket/prefix/east"},
+		{"s3://bucket/prefix/?endpoint=http://minio:9000", "s3://bucket/prefix/east?endpoint=http://minio:9000"},
+		{"local:///tmp/backup", "local:///tmp/backup/east"},
+		{"/tmp/backup", "/tmp/backup/east"},
+	}
+	for _, ca := range cases {
+		joined, err := joinStorageURL(ca.raw, "east")
+		c.Assert(err, IsNil)
+		c.Assert(joined, Equals, ca.expected, Commentf("raw: %s", ca.raw))
+	}
+}
@@ -127,6 +127,12 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	stopAudit, err := startAuditLog(ctx, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer stopAudit()
+
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("task.RunBackupRaw", opentracing.ChildOf(span.Context()))
 		defer span1.Finish()
@@ -139,7 +145,7 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 	}
 	// Backup raw does not need domain.
 	needDomain := false
-	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.CheckRequirements, needDomain)
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, needDomain)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -153,6 +159,7 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 		NoCredentials:   cfg.NoCreds,
 		SendCredentials: cfg.SendCreds,
 		SkipCheckPath:   cfg.SkipCheckPath,
+		LocalOptions:    &cfg.BackendOptions.Local,
 	}
 	if err = client.SetStorage(ctx, u, &opts); err != nil {
 		return errors.Trace(err)
@@ -192,8 +199,7 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 
 	// Backup
 	// Redirect to log if there is no log file to avoid unreadable output.
-	updateCh := g.StartProgress(
-		ctx, cmdName, int64(approximateRegions), !cfg.LogProgress)
+	updateCh := cfg.startProgressPrinter(ctx, g, cmdName, int64(approximateRegions))
 
 	progressCallBack := func(unit backup.ProgressUnit) {
 		if unit == backup.RangeUnit {
@@ -237,6 +243,13 @@ func RunBackupRaw(c context.Context, g glue.Glue, cmdName string, cfg *RawKvConf
 	}
 	g.Record(summary.BackupDataSize, metaWriter.ArchiveSize())
 
+	// Files written under the per-batch sync policy must be durable before
+	// the backup is reported as finished.
+	if syncer, ok := client.GetStorage().(storage.Syncer); ok {
+		if err = syncer.Sync(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	// Set task summary to success status.
 	summary.SetSuccessStatus(true)
 	return nil
@@ -0,0 +1,178 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"time"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/backup"
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+const (
+	flagVerifyWatch        = "watch"
+	flagVerifyInterval     = "watch-interval"
+	flagVerifySampleFiles  = "sample-files"
+	flagVerifyAlertWebhook = "alert-webhook"
+
+	defaultVerifyInterval    = time.Hour
+	defaultVerifySampleFiles = 64
+)
+
+// VerifyConfig is the configuration of verifying the stored backup files
+// continuously.
+type VerifyConfig struct {
+	Config
+
+	Watch bool `json:"watch" toml:"watch"`
+	// Interval is how often a sample of the files is verified.
+	Interval time.Duration `json:"watch-interval" toml:"watch-interval"`
+	// SampleFiles is how many files are verified each time, 0 means all.
+	SampleFiles int `json:"sample-files" toml:"sample-files"`
+	// AlertWebhook is the url the corrupted files are posted to.
+	AlertWebhook string `json:"alert-webhook" toml:"alert-webhook"`
+}
+
+// DefineVerifyFlags defines the flags of verifying the stored backup files
+// continuously.
+func DefineVerifyFlags(flags *pflag.FlagSet) {
+	flags.Bool(flagVerifyWatch, false,
+		"keep verifying random samples of the backup files periodically, instead of all of them once")
+	flags.Duration(flagVerifyInterval, defaultVerifyInterval, "the interval between the samples verified with --"+flagVerifyWatch)
+	flags.Int(flagVerifySampleFiles, defaultVerifySampleFiles,
+		"the number of the files verified in each sample with --"+flagVerifyWatch+", 0 means all of the files")
+	flags.String(flagVerifyAlertWebhook, "",
+		"the url the corrupted files are posted to as the file-corrupted events")
+}
+
+// ParseFromFlags parses the verify config from the flag set.
+func (cfg *VerifyConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.Watch, err = flags.GetBool(flagVerifyWatch); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Interval, err = flags.GetDuration(flagVerifyInterval); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.SampleFiles, err = flags.GetInt(flagVerifySampleFiles); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.AlertWebhook, err = flags.GetString(flagVerifyAlertWebhook); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Watch && cfg.Interval <= 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must be positive", flagVerifyInterval)
+	}
+	if cfg.SampleFiles < 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must not be negative", flagVerifySampleFiles)
+	}
+	return errors.Trace(cfg.Config.ParseFromFlags(flags))
+}
+
+// RunVerifyWatch verifies random samples of the stored backup files against
+// the sha256 recorded in the backupmeta every interval, until the context is
+// done, so that the bit rot in the storage is found before a restore needs
+// the files. The corrupted files are logged and posted to the alert webhook,
+// they don't stop the watch.
+func RunVerifyWatch(ctx context.Context, cfg *VerifyConfig) error {
+	_, s, backupMeta, err := ReadBackupMeta(ctx, metautil.MetaFile, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	files, err := metautil.NewMetaReader(backupMeta, s).ReadDataFiles(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var events *backup.EventEmitter
+	if cfg.AlertWebhook != "" {
+		events = backup.NewEventEmitter(cfg.AlertWebhook, "verify", s.URI(), backupMeta.GetEndVersion())
+		defer func() {
+			closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			events.Close(closeCtx)
+		}()
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	log.Info("start verifying the backup files", zap.String("storage", s.URI()),
+		zap.Int("files", len(files)), zap.Duration("interval", cfg.Interval), zap.Int("sample", cfg.SampleFiles))
+	for {
+		corrupted, err := verifyFiles(ctx, s, sampleFiles(rng, files, cfg.SampleFiles))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for _, f := range corrupted {
+			event := backup.NewFileUploadedEvent(f.file)
+			event.Type = backup.EventFileCorrupted
+			event.Error = f.err.Error()
+			events.Emit(event)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sampleFiles returns n random files with the recorded sha256, all of them if
+// n is 0 or there are not so many.
+func sampleFiles(rng *rand.Rand, files []*backuppb.File, n int) []*backuppb.File {
+	sample := make([]*backuppb.File, 0, len(files))
+	for _, f := range files {
+		if len(f.Sha256) > 0 {
+			sample = append(sample, f)
+		}
+	}
+	if n == 0 || n >= len(sample) {
+		return sample
+	}
+	rng.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	return sample[:n]
+}
+
+type corruptedFile struct {
+	file *backuppb.File
+	err  error
+}
+
+// verifyFiles reads the files and compares them with their recorded sha256.
+// It only fails if the context is done, the files failing to be read are
+// reported as corrupted.
+func verifyFiles(ctx context.Context, s storage.ExternalStorage, files []*backuppb.File) ([]corruptedFile, error) {
+	var corrupted []corruptedFile
+	for _, file := range files {
+		data, err := s.ReadFile(ctx, file.Name)
+		if ctx.Err() != nil {
+			return corrupted, errors.Trace(ctx.Err())
+		}
+		if err == nil {
+			sum := sha256.Sum256(data)
+			if !bytes.Equal(sum[:], file.Sha256) {
+				err = errors.Annotatef(berrors.ErrBackupChecksumMismatch,
+					"calculated sha256 is %s, origin sha256 is %s", hex.EncodeToString(sum[:]), hex.EncodeToString(file.Sha256))
+			}
+		}
+		if err != nil {
+			log.Error("backup file corrupted", zap.String("file", file.Name), zap.Error(err))
+			corrupted = append(corrupted, corruptedFile{file: file, err: err})
+		}
+	}
+	log.Info("backup files verified", zap.Int("files", len(files)), zap.Int("corrupted", len(corrupted)))
+	return corrupted, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/rand"
+
+	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type testBackupVerifySuite struct{}
+
+var _ = Suite(&testBackupVerifySuite{})
+
+func (s *testBackupVerifySuite) TestSampleFiles(c *C) {
+	files := []*backuppb.File{
+		{Name: "1.sst", Sha256: []byte{1}},
+		{Name: "2.sst", Sha256: []byte{2}},
+		{Name: "3.sst", Sha256: []byte{3}},
+		// the files without the sha256 can't be verified.
+		{Name: "4.sst"},
+	}
+	rng := rand.New(rand.NewSource(1))
+	c.Assert(sampleFiles(rng, files, 0), HasLen, 3)
+	c.Assert(sampleFiles(rng, files, 5), HasLen, 3)
+	sample := sampleFiles(rng, files, 2)
+	c.Assert(sample, HasLen, 2)
+	c.Assert(sample[0].Name, Not(Equals), sample[1].Name)
+	for _, f := range sample {
+		c.Assert(f.Sha256, NotNil)
+	}
+}
+
+func (s *testBackupVerifySuite) TestVerifyFiles(c *C) {
+	ctx := context.Background()
+	store, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	var files []*backuppb.File
+	for _, name := range []string{"good.sst", "rotten.sst"} {
+		checksum := sha256.Sum256([]byte(name))
+		files = append(files, &backuppb.File{Name: name, Sha256: checksum[:]})
+	}
+	files = append(files, &backuppb.File{Name: "missing.sst", Sha256: []byte{0}})
+	c.Assert(store.WriteFile(ctx, "good.sst", []byte("good.sst")), IsNil)
+	c.Assert(store.WriteFile(ctx, "rotten.sst", []byte("rotten.sSt")), IsNil)
+
+	corrupted, err := verifyFiles(ctx, store, files)
+	c.Assert(err, IsNil)
+	c.Assert(corrupted, HasLen, 2)
+	c.Assert(corrupted[0].file.Name, Equals, "rotten.sst")
+	c.Assert(corrupted[0].err, ErrorMatches, ".*checksum mismatch.*")
+	c.Assert(corrupted[1].file.Name, Equals, "missing.sst")
+}
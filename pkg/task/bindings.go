@@ -0,0 +1,44 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"strings"
+
+	"github.com/pingcap/parser/mysql"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+)
+
+const (
+	flagWithBindings = "with-bindings"
+
+	// bindingsTable is the system table of the SQL bindings, which are also
+	// the plan baselines. TiDB has no user-defined functions yet, their
+	// metadata would be added here once it's stored in the system tables.
+	bindingsTable = "bind_info"
+)
+
+// bindingsFilter matches the bindings table besides the tables matched by
+// the filter. The filter embedded must be case insensitive already, since
+// filter.CaseInsensitive unwraps it.
+type bindingsFilter struct {
+	filter.Filter
+}
+
+func withBindings(f filter.Filter) filter.Filter {
+	return bindingsFilter{Filter: f}
+}
+
+func isBindingsTable(schema, table string) bool {
+	return strings.EqualFold(schema, mysql.SystemDB) && strings.EqualFold(table, bindingsTable)
+}
+
+// MatchTable implements filter.Filter.
+func (f bindingsFilter) MatchTable(schema string, table string) bool {
+	return isBindingsTable(schema, table) || f.Filter.MatchTable(schema, table)
+}
+
+// MatchSchema implements filter.Filter.
+func (f bindingsFilter) MatchSchema(schema string) bool {
+	return strings.EqualFold(schema, mysql.SystemDB) || f.Filter.MatchSchema(schema)
+}
@@ -0,0 +1,291 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/util"
+	"github.com/spf13/pflag"
+	"github.com/tikv/client-go/v2/oracle"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagCloneFromPD      = "from-pd"
+	flagCloneToPD        = "to-pd"
+	flagCloneTable       = "table"
+	flagClonePiped       = "piped"
+	flagClonePipedTables = "piped-batch-tables"
+
+	defaultPipedBatchTables = 16
+)
+
+// CloneConfig is the configuration of `br clone`.
+type CloneConfig struct {
+	Config
+
+	// FromPD and ToPD are the PD addresses of the source and target clusters.
+	FromPD []string `json:"from-pd" toml:"from-pd"`
+	ToPD   []string `json:"to-pd" toml:"to-pd"`
+	// Piped restores the tables batch by batch while the next batch is being
+	// backed up, and deletes the spool of each batch once it's restored.
+	Piped            bool `json:"piped" toml:"piped"`
+	PipedBatchTables uint `json:"piped-batch-tables" toml:"piped-batch-tables"`
+}
+
+// DefineCloneFlags defines the flags of `br clone`.
+func DefineCloneFlags(flags *pflag.FlagSet) {
+	flags.StringSlice(flagCloneFromPD, nil, "PD address of the cluster the tables are cloned from")
+	flags.StringSlice(flagCloneToPD, nil, "PD address of the cluster the tables are cloned to")
+	flags.StringArray(flagCloneTable, nil,
+		`the tables to clone in the form of table filter rules, e.g. "db.t", can be specified multiple times`)
+	flags.Bool(flagClonePiped, false,
+		"restore the tables batch by batch while the next batch is being backed up, and delete the spool of each "+
+			"batch once it's restored, so the spool only holds two batches instead of all the tables")
+	flags.Uint(flagClonePipedTables, defaultPipedBatchTables, "the number of the tables in a batch of --"+flagClonePiped)
+}
+
+// ParseFromFlags parses the clone config from the flag set.
+func (cfg *CloneConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if err = cfg.Config.ParseFromFlags(flags); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.FromPD, err = flags.GetStringSlice(flagCloneFromPD); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ToPD, err = flags.GetStringSlice(flagCloneToPD); err != nil {
+		return errors.Trace(err)
+	}
+	if len(cfg.FromPD) == 0 || len(cfg.ToPD) == 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s and --%s must be specified", flagCloneFromPD, flagCloneToPD)
+	}
+	for _, pds := range [][]string{cfg.FromPD, cfg.ToPD} {
+		for i := range pds {
+			if pds[i], err = normalizePDURL(pds[i], cfg.TLS.IsEnabled()); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	if cfg.Storage == "" {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"--%s must be specified as the spool, which must be accessible by both clusters", flagStorage)
+	}
+	tables, err := flags.GetStringArray(flagCloneTable)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(tables) == 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must be specified", flagCloneTable)
+	}
+	f, err := filter.Parse(tables)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.TableFilter = filter.CaseInsensitive(f)
+	if cfg.Piped, err = flags.GetBool(flagClonePiped); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.PipedBatchTables, err = flags.GetUint(flagClonePipedTables); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Piped && cfg.PipedBatchTables == 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must be positive", flagClonePipedTables)
+	}
+	return nil
+}
+
+// RunClone clones the tables from a cluster into another one. The snapshot of
+// the tables is backed up from the source cluster into the storage as the
+// spool, and restored from it into the target cluster. The spool is left as a
+// complete backup, so a failed restore can be resumed by `br restore`.
+//
+// The files are spooled since TiKV only backs up into and restores from the
+// external storages. With --piped, the spool only holds the batches being
+// restored and backed up, see runPipedClone.
+func RunClone(c context.Context, g glue.Glue, cmdName string, cfg *CloneConfig) error {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+	if cfg.Piped {
+		return runPipedClone(ctx, g, cmdName, cfg)
+	}
+
+	backupCfg := BackupConfig{Config: cfg.Config, IgnoreStats: true}
+	backupCfg.PD = cfg.FromPD
+	summary.SetUnit(summary.BackupUnit)
+	if err := RunBackup(ctx, g, cmdName+" backup", &backupCfg); err != nil {
+		return errors.Annotate(err, "failed to back up the tables from the source cluster")
+	}
+	log.Info("tables spooled", zap.Strings("from-pd", cfg.FromPD), zap.String("spool", cfg.Storage))
+
+	restoreCfg := RestoreConfig{Config: cfg.Config}
+	restoreCfg.PD = cfg.ToPD
+	summary.SetUnit(summary.RestoreUnit)
+	if err := RunRestore(ctx, g, cmdName+" restore", &restoreCfg); err != nil {
+		return errors.Annotatef(err, "failed to restore the tables into the target cluster, "+
+			"retry by `br restore full` from the spool %s", cfg.Storage)
+	}
+	log.Info("tables cloned", zap.Strings("from-pd", cfg.FromPD), zap.Strings("to-pd", cfg.ToPD))
+	return nil
+}
+
+// runPipedClone clones the tables batch by batch at the same snapshot. Each
+// batch is backed up into its own spool under the storage, and restored from
+// it while the next batch is being backed up, then the spool is deleted.
+func runPipedClone(ctx context.Context, g glue.Glue, cmdName string, cfg *CloneConfig) error {
+	if _, err := pipedCloneSpool(cfg.Storage, 0); err != nil {
+		return errors.Trace(err)
+	}
+	mgr, err := NewMgr(ctx, g, cfg.FromPD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer mgr.Close()
+	physical, logical, err := mgr.GetPDClient().GetTS(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	backupTS := oracle.ComposeTS(physical, logical)
+	// the snapshot is kept between the batches.
+	sp := utils.BRServiceSafePoint{
+		BackupTS: backupTS,
+		TTL:      utils.DefaultBRGCSafePointTTL,
+		ID:       utils.MakeSafePointID(),
+	}
+	if err = utils.StartServiceSafePointKeeper(ctx, mgr.GetPDClient(), sp); err != nil {
+		return errors.Trace(err)
+	}
+	is, err := mgr.GetDomain().GetSnapshotInfoSchema(backupTS)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	batches := pipedCloneBatches(pipedCloneTables(is, cfg.TableFilter), int(cfg.PipedBatchTables))
+	log.Info("start piped clone", zap.Uint64("backup-ts", backupTS), zap.Int("batches", len(batches)))
+
+	summary.SetUnit(summary.RestoreUnit)
+	spooled := make(chan int, 1)
+	eg, ectx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		defer close(spooled)
+		for i, batch := range batches {
+			backupCfg := BackupConfig{Config: cfg.Config, IgnoreStats: true, BackupTS: backupTS}
+			backupCfg.PD = cfg.FromPD
+			backupCfg.TableFilter = filter.CaseInsensitive(filter.NewTablesFilter(batch...))
+			backupCfg.Storage, _ = pipedCloneSpool(cfg.Storage, i)
+			name := fmt.Sprintf("%s backup %d/%d", cmdName, i+1, len(batches))
+			if err := RunBackup(ectx, g, name, &backupCfg); err != nil {
+				return errors.Annotatef(err, "failed to back up the batch %d from the source cluster", i)
+			}
+			select {
+			case spooled <- i:
+			case <-ectx.Done():
+				return errors.Trace(ectx.Err())
+			}
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		for i := range spooled {
+			restoreCfg := RestoreConfig{Config: cfg.Config}
+			restoreCfg.PD = cfg.ToPD
+			restoreCfg.Storage, _ = pipedCloneSpool(cfg.Storage, i)
+			name := fmt.Sprintf("%s restore %d/%d", cmdName, i+1, len(batches))
+			if err := RunRestore(ectx, g, name, &restoreCfg); err != nil {
+				return errors.Annotatef(err, "failed to restore the batch %d into the target cluster, "+
+					"retry by `br restore full` from the spool %s", i, restoreCfg.Storage)
+			}
+			deletePipedCloneSpool(ectx, &restoreCfg.Config)
+		}
+		return nil
+	})
+	if err = eg.Wait(); err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("tables cloned", zap.Strings("from-pd", cfg.FromPD), zap.Strings("to-pd", cfg.ToPD),
+		zap.Int("batches", len(batches)))
+	return nil
+}
+
+// pipedCloneTables returns the tables to clone, the views are the last since
+// they're created after the tables they depend on.
+func pipedCloneTables(is infoschema.InfoSchema, tableFilter filter.Filter) []filter.Table {
+	var tables, views []filter.Table
+	for _, db := range is.AllSchemas() {
+		if util.IsMemDB(db.Name.L) {
+			continue
+		}
+		for _, tbl := range is.SchemaTables(db.Name) {
+			table := tbl.Meta()
+			if !tableFilter.MatchTable(db.Name.O, table.Name.O) {
+				continue
+			}
+			t := filter.Table{Schema: db.Name.O, Name: table.Name.O}
+			if table.IsView() {
+				views = append(views, t)
+			} else {
+				tables = append(tables, t)
+			}
+		}
+	}
+	return append(tables, views...)
+}
+
+func pipedCloneBatches(tables []filter.Table, size int) [][]filter.Table {
+	var batches [][]filter.Table
+	for len(tables) > size {
+		batches = append(batches, tables[:size:size])
+		tables = tables[size:]
+	}
+	if len(tables) > 0 {
+		batches = append(batches, tables)
+	}
+	return batches
+}
+
+// pipedCloneSpool returns the storage of the spool of the batch.
+func pipedCloneSpool(spool string, batch int) (string, error) {
+	u, err := url.Parse(spool)
+	if err != nil {
+		return "", errors.Annotatef(berrors.ErrStorageInvalidConfig, "invalid spool %s: %v", spool, err)
+	}
+	u.Path = path.Join(u.Path, fmt.Sprintf("batch-%d", batch))
+	return u.String(), nil
+}
+
+// deletePipedCloneSpool deletes the files of the restored spool, the failures
+// are only logged since the tables are already cloned.
+func deletePipedCloneSpool(ctx context.Context, cfg *Config) {
+	_, s, err := GetStorage(ctx, cfg)
+	if err != nil {
+		log.Warn("failed to open the spool, it's left", zap.String("spool", cfg.Storage), zap.Error(err))
+		return
+	}
+	deleter, ok := s.(storage.Deleter)
+	if !ok {
+		log.Warn("the spool can't delete files, it's left", zap.String("spool", cfg.Storage))
+		return
+	}
+	err = s.WalkDir(ctx, &storage.WalkOption{}, func(name string, _ int64) error {
+		return errors.Trace(deleter.DeleteFile(ctx, name))
+	})
+	if err != nil {
+		log.Warn("failed to delete the spool, it's left", zap.String("spool", cfg.Storage), zap.Error(err))
+		return
+	}
+	log.Info("spool deleted", zap.String("spool", cfg.Storage))
+}
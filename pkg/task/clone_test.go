@@ -0,0 +1,69 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	. "github.com/pingcap/check"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/spf13/pflag"
+)
+
+type testCloneSuite struct{}
+
+var _ = Suite(&testCloneSuite{})
+
+func (s *testCloneSuite) parse(args ...string) (*CloneConfig, error) {
+	flags := pflag.NewFlagSet("clone", pflag.ContinueOnError)
+	DefineCommonFlags(flags)
+	DefineCloneFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg := &CloneConfig{}
+	return cfg, cfg.ParseFromFlags(flags)
+}
+
+func (s *testCloneSuite) TestParseFromFlags(c *C) {
+	cfg, err := s.parse("--from-pd", "http://src-pd:2379", "--to-pd", "dst-pd:2379",
+		"--table", "db.t", "--table", "Other.*", "-s", "s3://bucket/spool")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.FromPD, DeepEquals, []string{"src-pd:2379"})
+	c.Assert(cfg.ToPD, DeepEquals, []string{"dst-pd:2379"})
+	c.Assert(cfg.TableFilter.MatchTable("db", "t"), IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("db", "t2"), IsFalse)
+	c.Assert(cfg.TableFilter.MatchTable("other", "t"), IsTrue)
+
+	_, err = s.parse("--from-pd", "src-pd:2379", "--table", "db.t", "-s", "s3://bucket/spool")
+	c.Assert(err, ErrorMatches, ".*--from-pd and --to-pd must be specified.*")
+	_, err = s.parse("--from-pd", "src-pd:2379", "--to-pd", "dst-pd:2379", "--table", "db.t")
+	c.Assert(err, ErrorMatches, ".*--storage must be specified as the spool.*")
+	_, err = s.parse("--from-pd", "src-pd:2379", "--to-pd", "dst-pd:2379", "-s", "s3://bucket/spool")
+	c.Assert(err, ErrorMatches, ".*--table must be specified.*")
+}
+
+func (s *testCloneSuite) TestParsePiped(c *C) {
+	cfg, err := s.parse("--from-pd", "src-pd:2379", "--to-pd", "dst-pd:2379",
+		"--table", "db.*", "-s", "s3://bucket/spool", "--piped")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Piped, IsTrue)
+	c.Assert(cfg.PipedBatchTables, Equals, uint(defaultPipedBatchTables))
+
+	_, err = s.parse("--from-pd", "src-pd:2379", "--to-pd", "dst-pd:2379",
+		"--table", "db.*", "-s", "s3://bucket/spool", "--piped", "--piped-batch-tables", "0")
+	c.Assert(err, ErrorMatches, ".*--piped-batch-tables must be positive.*")
+}
+
+func (s *testCloneSuite) TestPipedCloneBatches(c *C) {
+	tables := []filter.Table{{Schema: "db", Name: "a"}, {Schema: "db", Name: "b"}, {Schema: "db", Name: "c"}}
+	batches := pipedCloneBatches(tables, 2)
+	c.Assert(batches, DeepEquals, [][]filter.Table{tables[:2], tables[2:]})
+	c.Assert(pipedCloneBatches(tables, 3), HasLen, 1)
+	c.Assert(pipedCloneBatches(nil, 3), HasLen, 0)
+
+	spool, err := pipedCloneSpool("s3://bucket/spool?endpoint=http://minio:9000", 3)
+	c.Assert(err, IsNil)
+	c.Assert(spool, Equals, "s3://bucket/spool/batch-3?endpoint=http://minio:9000")
+	spool, err = pipedCloneSpool("/mnt/nfs/spool", 0)
+	c.Assert(err, IsNil)
+	c.Assert(spool, Equals, "/mnt/nfs/spool/batch-0")
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/url"
 	"path"
 	"strings"
@@ -29,6 +30,8 @@ import (
 	"github.com/pingcap/br/pkg/conn"
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/grpcutil"
+	"github.com/pingcap/br/pkg/pdutil"
 	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/utils"
 )
@@ -66,9 +69,25 @@ const (
 	flagGrpcKeepaliveTime = "grpc-keepalive-time"
 	// flagGrpcKeepaliveTimeout is the max time a grpc conn can keep idel before killed.
 	flagGrpcKeepaliveTimeout = "grpc-keepalive-timeout"
+	flagGrpcMaxRecvMsgSize   = "grpc-max-recv-msg-size"
+	flagGrpcMaxSendMsgSize   = "grpc-max-send-msg-size"
+	flagGrpcCompression      = "grpc-compression"
+	flagGrpcServiceConfig    = "grpc-service-config"
+	// flagGrpcDialTimeout is the timeout of connecting to a store, the links
+	// across data centers may need a longer one.
+	flagGrpcDialTimeout          = "grpc-dial-timeout"
+	flagGrpcMaxConcurrentStreams = "grpc-max-concurrent-streams"
 	// flagEnableOpenTracing is whether to enable opentracing
 	flagEnableOpenTracing = "enable-opentracing"
 	flagSkipCheckPath     = "skip-check-path"
+	// flagProgressTable is the table where the progress of the task is written.
+	flagProgressTable = "progress-table"
+	// flagProgress is how the progress is printed.
+	flagProgress = "progress"
+	// flagAuditLog is where the actions mutating the cluster are recorded.
+	flagAuditLog = "audit-log"
+	// flagValidateOnly exits after validating the config.
+	flagValidateOnly = "validate-only"
 
 	defaultSwitchInterval       = 5 * time.Minute
 	defaultGRPCKeepaliveTime    = 10 * time.Second
@@ -150,13 +169,37 @@ type Config struct {
 	GRPCKeepaliveTime time.Duration `json:"grpc-keepalive-time" toml:"grpc-keepalive-time"`
 	// GrpcKeepaliveTimeout is the max time a grpc conn can keep idel before killed.
 	GRPCKeepaliveTimeout time.Duration `json:"grpc-keepalive-timeout" toml:"grpc-keepalive-timeout"`
+	// GRPC is the options of the gRPC connections to the TiKV stores.
+	GRPC grpcutil.Config `json:"grpc" toml:"grpc"`
+
+	// ProgressTable is the table in the form of `db.table` which the progress
+	// is written into periodically. Empty means not writing the progress.
+	// It only works with glues supporting SQL.
+	ProgressTable string `json:"progress-table" toml:"progress-table"`
+	// ProgressMode is how the progress is printed, empty means printing the
+	// progress bar if LogProgress is set, otherwise printing into the log.
+	ProgressMode utils.ProgressMode `json:"progress" toml:"progress"`
+	// AuditLog is the local path or the external storage URL of the file
+	// which the actions mutating the cluster are recorded into. Empty means
+	// not recording.
+	AuditLog string `json:"audit-log" toml:"audit-log"`
+	// ValidateOnly reports the problems of the config and exits without
+	// running the task.
+	ValidateOnly bool `json:"validate-only" toml:"validate-only"`
+	// WithBindings backs up or restores the SQL bindings besides the tables
+	// matched by the filter.
+	WithBindings bool `json:"with-bindings" toml:"with-bindings"`
+	// taskID identifies the task in the progress table.
+	taskID string
 }
 
 // DefineCommonFlags defines the flags common to all BRIE commands.
 func DefineCommonFlags(flags *pflag.FlagSet) {
 	flags.BoolP(flagSendCreds, "c", true, "Whether send credentials to tikv")
 	flags.StringP(flagStorage, "s", "", `specify the url where backup storage, eg, "s3://bucket/path/prefix"`)
-	flags.StringSliceP(flagPD, "u", []string{"127.0.0.1:2379"}, "PD address")
+	flags.StringSliceP(flagPD, "u", []string{"127.0.0.1:2379"},
+		"PD address, e.g. \"127.0.0.1:2379\", \"[::1]:2379\", or \"srv://pd.cluster.local\" listing the PDs by DNS SRV, "+
+			"append \"?server-name=name\" to verify the TLS certificate of PD against another name, e.g. behind a proxy")
 	flags.String(flagCA, "", "CA certificate path for TLS connection")
 	flags.String(flagCert, "", "Certificate path for TLS connection")
 	flags.String(flagKey, "", "Private key path for TLS connection")
@@ -188,6 +231,18 @@ func DefineCommonFlags(flags *pflag.FlagSet) {
 		"the max time a gRPC connection can keep idle before killed, must keep the same value with TiKV and PD")
 	_ = flags.MarkHidden(flagGrpcKeepaliveTime)
 	_ = flags.MarkHidden(flagGrpcKeepaliveTimeout)
+	flags.Int(flagGrpcMaxRecvMsgSize, 0,
+		"the max size in bytes of a gRPC message received from TiKV, 0 means the gRPC default")
+	flags.Int(flagGrpcMaxSendMsgSize, 0,
+		"the max size in bytes of a gRPC message sent to TiKV, 0 means the gRPC default")
+	flags.String(flagGrpcCompression, "none", "the compression of the gRPC requests to TiKV, none or gzip")
+	flags.String(flagGrpcServiceConfig, "",
+		"the default gRPC service config in JSON, e.g. to set the retry policy of some methods, "+
+			"the retry policy only takes effect with the environment variable GRPC_GO_RETRY=on")
+	flags.Duration(flagGrpcDialTimeout, 0,
+		"the timeout of connecting to a TiKV store, 0 means the default 30s")
+	flags.Int(flagGrpcMaxConcurrentStreams, 0,
+		"the max gRPC streams open at the same time on a connection to TiKV, 0 means no limit")
 
 	flags.Bool(flagEnableOpenTracing, false,
 		"Set whether to enable opentracing during the backup/restore process")
@@ -197,6 +252,17 @@ func DefineCommonFlags(flags *pflag.FlagSet) {
 	flags.BoolP(flagSkipCheckPath, "", false, "Skip path verification")
 	_ = flags.MarkHidden(flagSkipCheckPath)
 
+	flags.String(flagProgressTable, "",
+		fmt.Sprintf("write the progress into this table periodically, e.g. %q", DefaultProgressTable))
+	flags.String(flagProgress, "",
+		"how the progress is printed, one of fancy (the progress bar), plain (the log lines, for CI) and none. "+
+			"by default it's fancy if the log is written into a file, otherwise plain")
+	flags.String(flagAuditLog, "",
+		"record the actions mutating the cluster, e.g. DDLs and PD configs, into this file for review, "+
+			"either a local path or an external storage URL, e.g. \"s3://bucket/audit/restore.log\"")
+	flags.Bool(flagValidateOnly, false,
+		"report all the problems of the flags as a whole, and exit without running the task")
+
 	storage.DefineFlags(flags)
 }
 
@@ -325,6 +391,14 @@ func (cfg *Config) ParseFromFlags(flags *pflag.FlagSet) error {
 	if !caseSensitive {
 		cfg.TableFilter = filter.CaseInsensitive(cfg.TableFilter)
 	}
+	if flags.Lookup(flagWithBindings) != nil {
+		if cfg.WithBindings, err = flags.GetBool(flagWithBindings); err != nil {
+			return errors.Trace(err)
+		}
+		if cfg.WithBindings {
+			cfg.TableFilter = withBindings(cfg.TableFilter)
+		}
+	}
 	checkRequirements, err := flags.GetBool(flagCheckRequirement)
 	if err != nil {
 		return errors.Trace(err)
@@ -343,6 +417,27 @@ func (cfg *Config) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if cfg.GRPC.MaxRecvMsgSize, err = flags.GetInt(flagGrpcMaxRecvMsgSize); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.GRPC.MaxSendMsgSize, err = flags.GetInt(flagGrpcMaxSendMsgSize); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.GRPC.Compression, err = flags.GetString(flagGrpcCompression); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.GRPC.ServiceConfig, err = flags.GetString(flagGrpcServiceConfig); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.GRPC.DialTimeout.Duration, err = flags.GetDuration(flagGrpcDialTimeout); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.GRPC.MaxConcurrentStreams, err = flags.GetInt(flagGrpcMaxConcurrentStreams); err != nil {
+		return errors.Trace(err)
+	}
+	if err = cfg.GRPC.Validate(); err != nil {
+		return errors.Trace(err)
+	}
 	cfg.EnableOpenTracing, err = flags.GetBool(flagEnableOpenTracing)
 	if err != nil {
 		return errors.Trace(err)
@@ -368,6 +463,27 @@ func (cfg *Config) ParseFromFlags(flags *pflag.FlagSet) error {
 	if cfg.SkipCheckPath, err = flags.GetBool(flagSkipCheckPath); err != nil {
 		return errors.Trace(err)
 	}
+	if cfg.ProgressTable, err = flags.GetString(flagProgressTable); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ProgressTable != "" {
+		if _, err = parseProgressTable(cfg.ProgressTable); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	progressMode, err := flags.GetString(flagProgress)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ProgressMode, err = utils.ParseProgressMode(progressMode); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.AuditLog, err = flags.GetString(flagAuditLog); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ValidateOnly, err = flags.GetBool(flagValidateOnly); err != nil {
+		return errors.Trace(err)
+	}
 	return cfg.normalizePDURLs()
 }
 
@@ -376,6 +492,7 @@ func NewMgr(ctx context.Context,
 	g glue.Glue, pds []string,
 	tlsConfig TLSConfig,
 	keepalive keepalive.ClientParameters,
+	grpcCfg grpcutil.Config,
 	checkRequirements bool,
 	needDomain bool,
 ) (*conn.Mgr, error) {
@@ -383,10 +500,19 @@ func NewMgr(ctx context.Context,
 		tlsConf *tls.Config
 		err     error
 	)
-	pdAddress := strings.Join(pds, ",")
-	if len(pdAddress) == 0 {
+	if len(strings.Join(pds, "")) == 0 {
 		return nil, errors.Annotate(berrors.ErrInvalidArgument, "pd address can not be empty")
 	}
+	endpoints, err := pdutil.ParseEndpoints(pds, tlsConfig.IsEnabled())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// the PDs behind the SRV records are looked up once, so that the TiKV
+	// storage and the PD controller connect the same PDs.
+	if endpoints, err = pdutil.ResolveEndpoints(ctx, net.DefaultResolver, endpoints); err != nil {
+		return nil, errors.Trace(err)
+	}
+	pdAddress := strings.Join(pdutil.Hosts(endpoints), ",")
 
 	securityOption := pd.SecurityOption{}
 	if tlsConfig.IsEnabled() {
@@ -407,7 +533,7 @@ func NewMgr(ctx context.Context,
 
 	// Is it necessary to remove `StoreBehavior`?
 	return conn.NewMgr(
-		ctx, g, pdAddress, store, tlsConf, securityOption, keepalive, conn.SkipTiFlash,
+		ctx, g, pdutil.JoinEndpoints(endpoints), store, tlsConf, securityOption, keepalive, grpcCfg, conn.SkipTiFlash,
 		checkRequirements, needDomain,
 	)
 }
@@ -433,6 +559,7 @@ func storageOpts(cfg *Config) *storage.ExternalStorageOptions {
 		NoCredentials:   cfg.NoCreds,
 		SendCredentials: cfg.SendCreds,
 		SkipCheckPath:   cfg.SkipCheckPath,
+		LocalOptions:    &cfg.BackendOptions.Local,
 	}
 }
 
@@ -442,6 +569,24 @@ func ReadBackupMeta(
 	fileName string,
 	cfg *Config,
 ) (*backuppb.StorageBackend, storage.ExternalStorage, *backuppb.BackupMeta, error) {
+	u, s, metaData, err := ReadRawBackupMeta(ctx, fileName, cfg)
+	if err != nil {
+		return nil, nil, nil, errors.Trace(err)
+	}
+	backupMeta := &backuppb.BackupMeta{}
+	if err = proto.Unmarshal(metaData, backupMeta); err != nil {
+		return nil, nil, nil, errors.Annotate(err, "parse backupmeta failed")
+	}
+	return u, s, backupMeta, nil
+}
+
+// ReadRawBackupMeta reads the encoded backupmeta from the storage, e.g. to
+// keep the fields unknown to this BR when it's re-encoded.
+func ReadRawBackupMeta(
+	ctx context.Context,
+	fileName string,
+	cfg *Config,
+) (*backuppb.StorageBackend, storage.ExternalStorage, []byte, error) {
 	u, s, err := GetStorage(ctx, cfg)
 	if err != nil {
 		return nil, nil, nil, errors.Trace(err)
@@ -469,11 +614,7 @@ func ReadBackupMeta(
 			return nil, nil, nil, errors.Annotate(err, "load backupmeta failed")
 		}
 	}
-	backupMeta := &backuppb.BackupMeta{}
-	if err = proto.Unmarshal(metaData, backupMeta); err != nil {
-		return nil, nil, nil, errors.Annotate(err, "parse backupmeta failed")
-	}
-	return u, s, backupMeta, nil
+	return u, s, metaData, nil
 }
 
 // flagToZapField checks whether this flag can be logged,
@@ -525,19 +666,11 @@ func (cfg *Config) adjust() {
 }
 
 func normalizePDURL(pd string, useTLS bool) (string, error) {
-	if strings.HasPrefix(pd, "http://") {
-		if useTLS {
-			return "", errors.Annotate(berrors.ErrInvalidArgument, "pd url starts with http while TLS enabled")
-		}
-		return strings.TrimPrefix(pd, "http://"), nil
-	}
-	if strings.HasPrefix(pd, "https://") {
-		if !useTLS {
-			return "", errors.Annotate(berrors.ErrInvalidArgument, "pd url starts with https while TLS disabled")
-		}
-		return strings.TrimPrefix(pd, "https://"), nil
+	e, err := pdutil.ParseEndpoint(pd, useTLS)
+	if err != nil {
+		return "", errors.Trace(err)
 	}
-	return pd, nil
+	return e.String(), nil
 }
 
 // check whether it's a bug before #647, to solve case #1
@@ -8,6 +8,7 @@ import (
 	"github.com/pingcap/tidb/config"
 
 	. "github.com/pingcap/check"
+	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
 
@@ -63,3 +64,20 @@ func (s *testCommonSuite) TestStripingPDURL(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(noChange, Equals, "127.0.0.1:2379")
 }
+
+func (*testCommonSuite) TestWithBindingsFilter(c *C) {
+	cmd := &cobra.Command{}
+	DefineCommonFlags(cmd.Flags())
+	DefineFilterFlags(cmd, []string{"*.*", "!mysql.*"})
+	cmd.Flags().Bool(flagWithBindings, false, "")
+	c.Assert(cmd.Flags().Parse([]string{"-f", "db.*", "--with-bindings"}), IsNil)
+	cfg := &Config{}
+	c.Assert(cfg.ParseFromFlags(cmd.Flags()), IsNil)
+	c.Assert(cfg.WithBindings, IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("db", "t"), IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("mysql", "bind_info"), IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("MySQL", "BIND_INFO"), IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("mysql", "user"), IsFalse)
+	c.Assert(cfg.TableFilter.MatchTable("other", "t"), IsFalse)
+	c.Assert(cfg.TableFilter.MatchSchema("mysql"), IsTrue)
+}
@@ -0,0 +1,463 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"path"
+	"time"
+
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/docker/go-units"
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/kvproto/pkg/import_sstpb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+)
+
+const (
+	flagBenchTableCount    = "table-count"
+	flagBenchFilesPerTable = "files-per-table"
+	flagBenchKVsPerFile    = "kvs-per-file"
+	flagBenchValueSize     = "value-size"
+	flagBenchBaseTableID   = "base-table-id"
+	flagBenchKeepData      = "keep-data"
+	flagBenchKeepFiles     = "keep-files"
+
+	defaultBenchTableCount    = 4
+	defaultBenchFilesPerTable = 16
+	defaultBenchKVsPerFile    = 10000
+	defaultBenchValueSize     = 128
+	// defaultBenchBaseTableID is far beyond the table IDs allocated by TiDB
+	// in practice, so the synthetic tables won't collide with real ones.
+	defaultBenchBaseTableID = 1 << 40
+
+	// maxBenchValueSize is the max length of a short value, which is inlined
+	// into the write CF record.
+	maxBenchValueSize = 255
+
+	ingestBenchDir = "ingest-bench"
+
+	writeTypePut     = 'P'
+	shortValuePrefix = 'v'
+	dataKeyPrefix    = 'z'
+)
+
+// IngestBenchConfig is the configuration of `br debug ingest-bench`.
+type IngestBenchConfig struct {
+	Config
+	RestoreCommonConfig
+
+	TableCount    uint  `json:"table-count" toml:"table-count"`
+	FilesPerTable uint  `json:"files-per-table" toml:"files-per-table"`
+	KVsPerFile    uint  `json:"kvs-per-file" toml:"kvs-per-file"`
+	ValueSize     uint  `json:"value-size" toml:"value-size"`
+	BaseTableID   int64 `json:"base-table-id" toml:"base-table-id"`
+	KeepData      bool  `json:"keep-data" toml:"keep-data"`
+	KeepFiles     bool  `json:"keep-files" toml:"keep-files"`
+}
+
+// DefineIngestBenchFlags defines the flags of `br debug ingest-bench`.
+func DefineIngestBenchFlags(flags *pflag.FlagSet) {
+	flags.Uint(flagBenchTableCount, defaultBenchTableCount, "the number of disposable tables to generate")
+	flags.Uint(flagBenchFilesPerTable, defaultBenchFilesPerTable, "the number of SST files of each table")
+	flags.Uint(flagBenchKVsPerFile, defaultBenchKVsPerFile, "the number of key-value pairs of each SST file")
+	flags.Uint(flagBenchValueSize, defaultBenchValueSize, "the size in bytes of each value, at most 255")
+	flags.Int64(flagBenchBaseTableID, defaultBenchBaseTableID,
+		"the table ID of the first disposable table, the range of the tables must be empty")
+	flags.Bool(flagBenchKeepData, false, "do not destroy the ingested data after the benchmark")
+	flags.Bool(flagBenchKeepFiles, false, "do not delete the generated SST files from the storage after the benchmark")
+
+	DefineRestoreCommonFlags(flags)
+}
+
+// ParseFromFlags parses the ingest bench config from the flag set.
+func (cfg *IngestBenchConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.TableCount, err = flags.GetUint(flagBenchTableCount); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.FilesPerTable, err = flags.GetUint(flagBenchFilesPerTable); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.KVsPerFile, err = flags.GetUint(flagBenchKVsPerFile); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ValueSize, err = flags.GetUint(flagBenchValueSize); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.BaseTableID, err = flags.GetInt64(flagBenchBaseTableID); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.KeepData, err = flags.GetBool(flagBenchKeepData); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.KeepFiles, err = flags.GetBool(flagBenchKeepFiles); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.TableCount == 0 || cfg.FilesPerTable == 0 || cfg.KVsPerFile == 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"--%s, --%s and --%s must be positive", flagBenchTableCount, flagBenchFilesPerTable, flagBenchKVsPerFile)
+	}
+	if cfg.ValueSize == 0 || cfg.ValueSize > maxBenchValueSize {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"--%s must be in [1, %d]", flagBenchValueSize, maxBenchValueSize)
+	}
+	if cfg.BaseTableID <= 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must be positive", flagBenchBaseTableID)
+	}
+	if err = cfg.RestoreCommonConfig.ParseFromFlags(flags); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(cfg.Config.ParseFromFlags(flags))
+}
+
+func (cfg *IngestBenchConfig) adjust() {
+	cfg.Config.adjust()
+	cfg.RestoreCommonConfig.adjust()
+
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = defaultRestoreConcurrency
+	}
+}
+
+// tableRange returns the raw key range covering all disposable tables.
+func (cfg *IngestBenchConfig) tableRange() (kv.Key, kv.Key) {
+	return tablecodec.EncodeTablePrefix(cfg.BaseTableID),
+		tablecodec.EncodeTablePrefix(cfg.BaseTableID + int64(cfg.TableCount))
+}
+
+// IngestBenchResult is the result of the ingest benchmark.
+type IngestBenchResult struct {
+	Files      int
+	Ranges     int
+	TotalKVs   uint64
+	TotalBytes uint64
+
+	Generate time.Duration
+	Split    time.Duration
+	Ingest   time.Duration
+	Cleanup  time.Duration
+}
+
+// String implements fmt.Stringer.
+func (r *IngestBenchResult) String() string {
+	throughput := float64(0)
+	if r.Ingest > 0 {
+		throughput = float64(r.TotalBytes) / r.Ingest.Seconds()
+	}
+	return fmt.Sprintf("ingest bench: %d files, %d ranges, %d kvs, %s\n"+
+		"  generate: %s\n"+
+		"  split & scatter: %s\n"+
+		"  download & ingest: %s, throughput %s/s\n"+
+		"  cleanup: %s",
+		r.Files, r.Ranges, r.TotalKVs, units.HumanSize(float64(r.TotalBytes)),
+		r.Generate, r.Split, r.Ingest, units.HumanSize(throughput), r.Cleanup)
+}
+
+// RunIngestBench generates synthetic SST files covering disposable table IDs,
+// restores them through the split/scatter/download/ingest pipeline, and
+// destroys the ingested data and deletes the generated files at last. It measures how fast the cluster can
+// ingest, independent of the content of any real backup.
+func RunIngestBench(c context.Context, g glue.Glue, cfg *IngestBenchConfig) (result *IngestBenchResult, err error) {
+	cfg.adjust()
+
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	// Ingest bench does not need domain.
+	needDomain := false
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, needDomain)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer mgr.Close()
+
+	keepaliveCfg := GetKeepalive(&cfg.Config)
+	keepaliveCfg.PermitWithoutStream = true
+	client, err := restore.NewRestoreClient(g, mgr.GetPDClient(), mgr.GetStorage(), mgr.GetTLSConfig(), keepaliveCfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer client.Close()
+	client.SetRateLimit(cfg.RateLimit)
+	client.SetConcurrency(uint(cfg.Concurrency))
+	if cfg.Online {
+		client.EnableOnline()
+	}
+	client.SetSwitchModeInterval(cfg.SwitchModeInterval)
+
+	startKey, endKey := cfg.tableRange()
+	if err = checkRangeEmpty(mgr.GetStorage(), startKey, endKey); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	u, s, err := GetStorage(ctx, &cfg.Config)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, ok := s.(storage.Deleter); !ok && !cfg.KeepFiles {
+		return nil, errors.Annotatef(berrors.ErrInvalidArgument,
+			"the storage %s can't delete the generated files, please set --%s", s.URI(), flagBenchKeepFiles)
+	}
+	commitTS, err := client.GetTS(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	result = &IngestBenchResult{}
+	dir := path.Join(ingestBenchDir, time.Now().Format("20060102150405"))
+	log.Info("start ingest bench",
+		zap.String("storage", s.URI()),
+		zap.String("dir", dir),
+		zap.Int64("base-table-id", cfg.BaseTableID),
+		zap.Uint("table-count", cfg.TableCount),
+		zap.Uint("files-per-table", cfg.FilesPerTable),
+		zap.Uint("kvs-per-file", cfg.KVsPerFile))
+
+	if !cfg.KeepFiles {
+		defer func() {
+			// the restore context may be canceled, cleanup anyway.
+			if cleanupErr := deleteBenchSSTs(c, s.(storage.Deleter), s, dir); cleanupErr != nil {
+				log.Warn("failed to delete the files generated by the bench",
+					zap.String("dir", dir), logutil.ShortError(cleanupErr))
+				if err == nil {
+					err = errors.Trace(cleanupErr)
+				}
+			}
+		}()
+	}
+
+	begin := time.Now()
+	files, err := generateBenchSSTs(ctx, s, dir, cfg, commitTS)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result.Generate = time.Since(begin)
+	result.Files = len(files)
+	for _, f := range files {
+		result.TotalKVs += f.TotalKvs
+		result.TotalBytes += f.TotalBytes
+	}
+
+	backupMeta := &backuppb.BackupMeta{
+		Files:      files,
+		EndVersion: commitTS,
+	}
+	if err = client.InitBackupMeta(ctx, backupMeta, u, s, metautil.NewMetaReader(backupMeta, s)); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if !cfg.KeepData {
+		defer func() {
+			cleanupBegins := time.Now()
+			// the restore context may be canceled, cleanup anyway.
+			cleanupErr := mgr.UnsafeDestroyRange(c, startKey, endKey)
+			result.Cleanup = time.Since(cleanupBegins)
+			if cleanupErr != nil {
+				log.Warn("failed to destroy the data ingested by the bench",
+					logutil.Key("start", startKey), logutil.Key("end", endKey), logutil.ShortError(cleanupErr))
+				if err == nil {
+					err = errors.Trace(cleanupErr)
+				}
+			}
+		}()
+	}
+
+	ranges, _, err := restore.MergeFileRanges(
+		files, cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	result.Ranges = len(ranges)
+
+	rewriteRules := &restore.RewriteRules{}
+	for i := uint(0); i < cfg.TableCount; i++ {
+		prefix := tablecodec.EncodeTablePrefix(cfg.BaseTableID + int64(i))
+		rewriteRules.Data = append(rewriteRules.Data, &import_sstpb.RewriteRule{
+			OldKeyPrefix: prefix,
+			NewKeyPrefix: prefix,
+		})
+	}
+
+	updateCh := cfg.startProgressPrinter(
+		ctx,
+		g,
+		"Ingest Bench",
+		// Split/Scatter + Download/Ingest
+		int64(len(ranges)+len(files)))
+	defer updateCh.Close()
+
+	begin = time.Now()
+	if err = restore.SplitRanges(ctx, client, ranges, rewriteRules, updateCh); err != nil {
+		return result, errors.Trace(err)
+	}
+	result.Split = time.Since(begin)
+
+	restoreSchedulers, err := restorePreWork(ctx, client, mgr, cfg.NoSchedulerPause)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	defer restorePostWork(ctx, client, restoreSchedulers)
+
+	begin = time.Now()
+	if err = client.RestoreFiles(ctx, files, rewriteRules, updateCh); err != nil {
+		return result, errors.Trace(err)
+	}
+	result.Ingest = time.Since(begin)
+
+	summary.SetSuccessStatus(true)
+	return result, nil
+}
+
+// checkRangeEmpty makes sure no data lives in the range, because the data of
+// the range would be destroyed after the bench.
+func checkRangeEmpty(store kv.Storage, startKey, endKey kv.Key) error {
+	iter, err := store.GetSnapshot(kv.MaxVersion).Iter(startKey, endKey)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer iter.Close()
+	if iter.Valid() {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"the range of the disposable tables is not empty (found key %s), please choose another --%s",
+			iter.Key(), flagBenchBaseTableID)
+	}
+	return nil
+}
+
+// generateBenchSSTs writes the SST files of all disposable tables into the
+// external storage, as if they were backed up at commitTS.
+func generateBenchSSTs(
+	ctx context.Context,
+	s storage.ExternalStorage,
+	dir string,
+	cfg *IngestBenchConfig,
+	commitTS uint64,
+) ([]*backuppb.File, error) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	value := make([]byte, cfg.ValueSize)
+	files := make([]*backuppb.File, 0, cfg.TableCount*cfg.FilesPerTable)
+	for t := uint(0); t < cfg.TableCount; t++ {
+		tableID := cfg.BaseTableID + int64(t)
+		for i := uint(0); i < cfg.FilesPerTable; i++ {
+			// random data, so that compression won't affect the result.
+			_, _ = rng.Read(value)
+			startHandle := int64(i) * int64(cfg.KVsPerFile)
+			// keep the file name pattern of backup, which is
+			// `{store_id}_{region_id}_{epoch_version}_{key}_{ts}_{cf}.sst`.
+			name := path.Join(dir, fmt.Sprintf("0_%d_%d_0_%d_write.sst", tableID, i, commitTS))
+			file, err := writeBenchSST(ctx, s, name, tableID, startHandle, int64(cfg.KVsPerFile), value, commitTS)
+			if err != nil {
+				return nil, errors.Annotatef(err, "failed to generate %s", name)
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// deleteBenchSSTs deletes all files generated into dir, including those of an
+// interrupted generation.
+func deleteBenchSSTs(ctx context.Context, deleter storage.Deleter, s storage.ExternalStorage, dir string) error {
+	return errors.Trace(s.WalkDir(ctx, &storage.WalkOption{SubDir: dir}, func(name string, _ int64) error {
+		return errors.Trace(deleter.DeleteFile(ctx, name))
+	}))
+}
+
+// writeBenchSST writes a write CF SST file containing the rows
+// [startHandle, startHandle+count) of the table, and returns its metadata.
+func writeBenchSST(
+	ctx context.Context,
+	s storage.ExternalStorage,
+	name string,
+	tableID, startHandle, count int64,
+	value []byte,
+	commitTS uint64,
+) (*backuppb.File, error) {
+	buf := &memSSTFile{}
+	w := sstable.NewWriter(buf, sstable.WriterOptions{BlockSize: 16 * 1024})
+	internalKey := sstable.InternalKey{
+		Trailer: uint64(sstable.InternalKeyKindSet),
+	}
+	writeValue := encodeBenchWriteValue(commitTS-1, value)
+	var totalBytes uint64
+	for h := startHandle; h < startHandle+count; h++ {
+		internalKey.UserKey = encodeBenchWriteKey(tablecodec.EncodeRowKeyWithHandle(tableID, kv.IntHandle(h)), commitTS)
+		if err := w.Add(internalKey, writeValue); err != nil {
+			return nil, errors.Trace(err)
+		}
+		totalBytes += uint64(len(internalKey.UserKey) + len(writeValue))
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	content := buf.Bytes()
+	if err := s.WriteFile(ctx, name, content); err != nil {
+		return nil, errors.Trace(err)
+	}
+	checksum := sha256.Sum256(content)
+	return &backuppb.File{
+		Name:         name,
+		Sha256:       checksum[:],
+		StartKey:     tablecodec.EncodeRowKeyWithHandle(tableID, kv.IntHandle(startHandle)),
+		EndKey:       tablecodec.EncodeRowKeyWithHandle(tableID, kv.IntHandle(startHandle+count)),
+		StartVersion: 0,
+		EndVersion:   commitTS,
+		TotalKvs:     uint64(count),
+		TotalBytes:   totalBytes,
+		Cf:           "write",
+		Size_:        uint64(len(content)),
+	}, nil
+}
+
+// encodeBenchWriteKey encodes the key in the format of the write CF of TiKV,
+// with the data key prefix.
+func encodeBenchWriteKey(key []byte, commitTS uint64) []byte {
+	encoded := []byte{dataKeyPrefix}
+	encoded = codec.EncodeBytes(encoded, key)
+	return codec.EncodeUintDesc(encoded, commitTS)
+}
+
+// encodeBenchWriteValue encodes a PUT write record with the short value
+// inlined.
+func encodeBenchWriteValue(startTS uint64, value []byte) []byte {
+	b := make([]byte, 0, 1+binary.MaxVarintLen64+2+len(value))
+	b = append(b, writeTypePut)
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], startTS)
+	b = append(b, tmp[:n]...)
+	b = append(b, shortValuePrefix, byte(len(value)))
+	return append(b, value...)
+}
+
+// memSSTFile is an in-memory file the SST writer writes to.
+type memSSTFile struct {
+	bytes.Buffer
+}
+
+func (*memSSTFile) Sync() error {
+	return nil
+}
+
+func (*memSSTFile) Close() error {
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type testIngestBenchSuite struct{}
+
+var _ = Suite(&testIngestBenchSuite{})
+
+func (s *testIngestBenchSuite) TestEncodeBenchWrite(c *C) {
+	rowKey := tablecodec.EncodeRowKeyWithHandle(42, kv.IntHandle(7))
+	key := encodeBenchWriteKey(rowKey, 100)
+	c.Assert(key[0], Equals, byte('z'))
+	remain, decoded, err := codec.DecodeBytes(key[1:], nil)
+	c.Assert(err, IsNil)
+	c.Assert(decoded, DeepEquals, []byte(rowKey))
+	_, ts, err := codec.DecodeUintDesc(remain)
+	c.Assert(err, IsNil)
+	c.Assert(ts, Equals, uint64(100))
+
+	value := encodeBenchWriteValue(99, []byte("abc"))
+	c.Assert(value[0], Equals, byte('P'))
+	startTS, n := binary.Uvarint(value[1:])
+	c.Assert(startTS, Equals, uint64(99))
+	c.Assert(value[1+n:], DeepEquals, []byte("v\x03abc"))
+}
+
+func (s *testIngestBenchSuite) TestGenerateBenchSSTs(c *C) {
+	ctx := context.Background()
+	base := c.MkDir()
+	c.Assert(os.Mkdir(filepath.Join(base, "bench"), 0o755), IsNil)
+	st, err := storage.NewLocalStorage(base)
+	c.Assert(err, IsNil)
+	cfg := &IngestBenchConfig{
+		TableCount:    2,
+		FilesPerTable: 3,
+		KVsPerFile:    10,
+		ValueSize:     16,
+		BaseTableID:   defaultBenchBaseTableID,
+	}
+	files, err := generateBenchSSTs(ctx, st, "bench", cfg, 1000)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 6)
+
+	startKey, endKey := cfg.tableRange()
+	for i, f := range files {
+		c.Assert(f.Cf, Equals, "write")
+		c.Assert(f.TotalKvs, Equals, uint64(10))
+		c.Assert(f.EndVersion, Equals, uint64(1000))
+		c.Assert(string(f.StartKey) >= string(startKey), IsTrue)
+		c.Assert(string(f.EndKey) <= string(endKey), IsTrue)
+		if i%3 != 0 {
+			// files of the same table are adjacent.
+			c.Assert(f.StartKey, DeepEquals, files[i-1].EndKey)
+		}
+
+		content, err := st.ReadFile(ctx, f.Name)
+		c.Assert(err, IsNil)
+		c.Assert(uint64(len(content)), Equals, f.Size_)
+		checksum := sha256.Sum256(content)
+		c.Assert(f.Sha256, DeepEquals, checksum[:])
+	}
+}
+
+func (s *testIngestBenchSuite) TestDeleteBenchSSTs(c *C) {
+	ctx := context.Background()
+	base := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(base, "ingest-bench", "1"), 0o755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(base, "ingest-bench", "2"), 0o755), IsNil)
+	st, err := storage.NewLocalStorage(base)
+	c.Assert(err, IsNil)
+	cfg := &IngestBenchConfig{
+		TableCount:    2,
+		FilesPerTable: 2,
+		KVsPerFile:    10,
+		ValueSize:     16,
+		BaseTableID:   defaultBenchBaseTableID,
+	}
+	_, err = generateBenchSSTs(ctx, st, "ingest-bench/1", cfg, 1000)
+	c.Assert(err, IsNil)
+	c.Assert(st.WriteFile(ctx, "ingest-bench/2/other.sst", []byte("other")), IsNil)
+
+	c.Assert(deleteBenchSSTs(ctx, st, st, "ingest-bench/1"), IsNil)
+	var names []string
+	err = st.WalkDir(ctx, &storage.WalkOption{}, func(name string, _ int64) error {
+		names = append(names, name)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"ingest-bench/2/other.sst"})
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	// DefaultProgressTable is the table read by TiDB to show the progress of
+	// background tasks.
+	DefaultProgressTable = "mysql.tidb_background_task_progress"
+
+	defaultProgressTableInterval = 10 * time.Second
+
+	createProgressTableSQL = `CREATE TABLE IF NOT EXISTS %s (
+		task_id VARCHAR(64) NOT NULL,
+		task_type VARCHAR(64) NOT NULL,
+		step VARCHAR(64) NOT NULL,
+		current BIGINT NOT NULL,
+		total BIGINT NOT NULL,
+		finished BOOLEAN NOT NULL DEFAULT FALSE,
+		update_time TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (task_id, step)
+	)`
+	replaceProgressSQL = "REPLACE INTO %s (task_id, task_type, step, current, total, finished, update_time) " +
+		"VALUES ('%s', '%s', '%s', %d, %d, %t, NOW())"
+)
+
+// parseProgressTable parses a table name in the form of `db.table` and returns
+// the enclosed name used in SQL.
+func parseProgressTable(name string) (string, error) {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.Annotatef(berrors.ErrInvalidArgument,
+			"progress table '%s' should be in the form of `db.table`", name)
+	}
+	return utils.EncloseDBAndTable(parts[0], parts[1]), nil
+}
+
+func quoteProgressString(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", "''")
+}
+
+// tableProgress is a glue.Progress which also writes the progress into a
+// table periodically through a glue session. So the tools in TiDB can report
+// the progress of backup and restore without reading the logs of BR.
+type tableProgress struct {
+	glue.Progress
+
+	se       glue.Session
+	table    string
+	taskID   string
+	taskType string
+	step     string
+	total    int64
+	current  int64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startTableProgress wraps the progress and starts writing into the progress
+// table. It returns the progress as is if the glue cannot execute SQL.
+func startTableProgress(
+	ctx context.Context,
+	g glue.Glue,
+	store kv.Storage,
+	progress glue.Progress,
+	tableName string,
+	taskID string,
+	taskType string,
+	step string,
+	total int64,
+) (glue.Progress, error) {
+	table, err := parseProgressTable(tableName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	se, err := g.CreateSession(store)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// The session may be nil in raw kv mode.
+	if se == nil {
+		log.Info("glue doesn't support SQL, skip writing progress table",
+			zap.String("table", tableName))
+		return progress, nil
+	}
+	if err = se.Execute(ctx, fmt.Sprintf(createProgressTableSQL, table)); err != nil {
+		se.Close()
+		return nil, errors.Annotatef(err, "failed to create progress table %s", tableName)
+	}
+	return newTableProgress(ctx, progress, se, table, taskID, taskType, step, total, defaultProgressTableInterval), nil
+}
+
+func newTableProgress(
+	ctx context.Context,
+	progress glue.Progress,
+	se glue.Session,
+	table string,
+	taskID string,
+	taskType string,
+	step string,
+	total int64,
+	interval time.Duration,
+) *tableProgress {
+	cctx, cancel := context.WithCancel(ctx)
+	tp := &tableProgress{
+		Progress: progress,
+		se:       se,
+		table:    table,
+		taskID:   taskID,
+		taskType: taskType,
+		step:     step,
+		total:    total,
+		cancel:   cancel,
+	}
+	tp.wg.Add(1)
+	go tp.run(cctx, interval)
+	return tp
+}
+
+func (tp *tableProgress) run(ctx context.Context, interval time.Duration) {
+	defer tp.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	tp.write(ctx, false)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			tp.write(ctx, false)
+		}
+	}
+}
+
+func (tp *tableProgress) write(ctx context.Context, finished bool) {
+	current := atomic.LoadInt64(&tp.current)
+	if current > tp.total {
+		current = tp.total
+	}
+	sql := fmt.Sprintf(replaceProgressSQL, tp.table,
+		quoteProgressString(tp.taskID), quoteProgressString(tp.taskType), quoteProgressString(tp.step),
+		current, tp.total, finished)
+	if err := tp.se.Execute(ctx, sql); err != nil {
+		// the progress table is only informative, never fail the task.
+		log.Warn("failed to update progress table", zap.String("sql", sql), zap.Error(err))
+	}
+}
+
+// Inc implements glue.Progress.
+func (tp *tableProgress) Inc() {
+	atomic.AddInt64(&tp.current, 1)
+	tp.Progress.Inc()
+}
+
+// AddBytes implements glue.BytesProgress.
+func (tp *tableProgress) AddBytes(n int64) {
+	glue.AddProgressBytes(tp.Progress, n)
+}
+
+// Close implements glue.Progress.
+func (tp *tableProgress) Close() {
+	tp.cancel()
+	tp.wg.Wait()
+	atomic.StoreInt64(&tp.current, tp.total)
+	tp.write(context.Background(), true)
+	tp.se.Close()
+	tp.Progress.Close()
+}
+
+// nopProgress is the progress of the tasks not printing the progress.
+type nopProgress struct{}
+
+// Inc implements glue.Progress.
+func (nopProgress) Inc() {}
+
+// Close implements glue.Progress.
+func (nopProgress) Close() {}
+
+// startProgressPrinter starts a progress printed in the progress mode of the
+// config.
+func (cfg *Config) startProgressPrinter(ctx context.Context, g glue.Glue, step string, total int64) glue.Progress {
+	switch cfg.ProgressMode {
+	case utils.ProgressModeNone:
+		return nopProgress{}
+	case utils.ProgressModePlain:
+		return g.StartProgress(ctx, step, total, true)
+	case utils.ProgressModeFancy:
+		return g.StartProgress(ctx, step, total, false)
+	default:
+		return g.StartProgress(ctx, step, total, !cfg.LogProgress)
+	}
+}
+
+// startProgress starts a progress of the task, which is also written into the
+// progress table if it is configured.
+func startProgress(
+	ctx context.Context,
+	g glue.Glue,
+	store kv.Storage,
+	cfg *Config,
+	cmdName string,
+	step string,
+	total int64,
+) (glue.Progress, error) {
+	progress := cfg.startProgressPrinter(ctx, g, step, total)
+	if cfg.ProgressTable == "" {
+		return progress, nil
+	}
+	if cfg.taskID == "" {
+		cfg.taskID = uuid.New().String()
+	}
+	tp, err := startTableProgress(ctx, g, store, progress, cfg.ProgressTable, cfg.taskID, cmdName, step, total)
+	if err != nil {
+		progress.Close()
+		return nil, errors.Trace(err)
+	}
+	return tp, nil
+}
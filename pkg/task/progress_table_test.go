@@ -0,0 +1,84 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+)
+
+type testProgressTableSuite struct{}
+
+var _ = Suite(&testProgressTableSuite{})
+
+type recordSession struct {
+	mu     sync.Mutex
+	sqls   []string
+	closed bool
+}
+
+func (s *recordSession) Execute(ctx context.Context, sql string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sqls = append(s.sqls, sql)
+	return nil
+}
+
+func (s *recordSession) CreateDatabase(ctx context.Context, schema *model.DBInfo) error {
+	return nil
+}
+
+func (s *recordSession) CreateTable(ctx context.Context, dbName model.CIStr, table *model.TableInfo) error {
+	return nil
+}
+
+func (s *recordSession) Close() {
+	s.closed = true
+}
+
+type countProgress struct {
+	count  int
+	closed bool
+}
+
+func (p *countProgress) Inc() {
+	p.count++
+}
+
+func (p *countProgress) Close() {
+	p.closed = true
+}
+
+func (s *testProgressTableSuite) TestParseProgressTable(c *C) {
+	table, err := parseProgressTable(DefaultProgressTable)
+	c.Assert(err, IsNil)
+	c.Assert(table, Equals, "`mysql`.`tidb_background_task_progress`")
+
+	for _, name := range []string{"no_db", ".t", "db."} {
+		_, err = parseProgressTable(name)
+		c.Assert(err, ErrorMatches, ".*should be in the form of `db.table`.*")
+	}
+}
+
+func (s *testProgressTableSuite) TestTableProgress(c *C) {
+	se := &recordSession{}
+	inner := &countProgress{}
+	tp := newTableProgress(context.Background(), inner, se, "`db`.`t`", "id", "Full Restore", "Full Restore", 3, time.Hour)
+	tp.Inc()
+	tp.Inc()
+	tp.Close()
+
+	c.Assert(inner.count, Equals, 2)
+	c.Assert(inner.closed, IsTrue)
+	c.Assert(se.closed, IsTrue)
+	c.Assert(len(se.sqls), Equals, 2)
+	c.Assert(strings.HasPrefix(se.sqls[0], "REPLACE INTO `db`.`t`"), IsTrue)
+	// the first row is written asynchronously, the progress may or may not be counted.
+	c.Assert(se.sqls[0], Matches, ".*VALUES \\('id', 'Full Restore', 'Full Restore', [0-2], 3, false, NOW\\(\\)\\)")
+	c.Assert(se.sqls[1], Matches, ".*VALUES \\('id', 'Full Restore', 'Full Restore', 3, 3, true, NOW\\(\\)\\)")
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/rtree"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// RegionInfoConfig is the configuration of `br debug fetch-region-info`.
+type RegionInfoConfig struct {
+	Config
+
+	// StartKey and EndKey are the raw key range whose regions are reported,
+	// an empty end key means the max. The tables matched by the table filter
+	// are reported instead if the range isn't given.
+	StartKey []byte `json:"start-key" toml:"start-key"`
+	EndKey   []byte `json:"end-key" toml:"end-key"`
+}
+
+// DefineRegionInfoFlags defines the flags of `br debug fetch-region-info`.
+func DefineRegionInfoFlags(flags *pflag.FlagSet) {
+	flags.String(flagKeyFormat, "hex", "start/end key format, support raw|escaped|hex")
+	flags.String(flagStartKey, "", "the start key of the range whose regions are reported, key is inclusive")
+	flags.String(flagEndKey, "", "the end key of the range whose regions are reported, key is exclusive")
+}
+
+// ParseFromFlags parses the region info config from the flag set.
+func (cfg *RegionInfoConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	format, err := flags.GetString(flagKeyFormat)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	start, err := flags.GetString(flagStartKey)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.StartKey, err = utils.ParseKey(format, start); err != nil {
+		return errors.Trace(err)
+	}
+	end, err := flags.GetString(flagEndKey)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.EndKey, err = utils.ParseKey(format, end); err != nil {
+		return errors.Trace(err)
+	}
+	byRange := len(cfg.StartKey) > 0 || len(cfg.EndKey) > 0
+	if byRange == flags.Changed(flagFilter) {
+		return errors.Annotate(berrors.ErrInvalidArgument, "either --filter or a key range must be given")
+	}
+	if len(cfg.StartKey) > 0 && len(cfg.EndKey) > 0 && bytes.Compare(cfg.StartKey, cfg.EndKey) >= 0 {
+		return errors.Annotate(berrors.ErrInvalidArgument, "the end key must be greater than the start key")
+	}
+	return errors.Trace(cfg.Config.ParseFromFlags(flags))
+}
+
+// RunFetchRegionInfo dumps the regions covering the tables or the key range,
+// with their leaders, epochs, peer states and the operators running on them,
+// and writes them to w in JSON. It is for the issue reports when a restore
+// stalls on the regions, e.g. retrying to scan them.
+func RunFetchRegionInfo(c context.Context, g glue.Glue, cfg *RegionInfoConfig, w io.Writer) error {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	// the domain is only needed to find the tables by the filter.
+	byRange := len(cfg.StartKey) > 0 || len(cfg.EndKey) > 0
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, !byRange)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer mgr.Close()
+
+	ranges := []rtree.Range{{StartKey: cfg.StartKey, EndKey: cfg.EndKey}}
+	if !byRange {
+		ranges = ranges[:0]
+		for _, table := range spaceReportTablesFromSchemas(mgr.GetDomain().InfoSchema(), cfg.TableFilter) {
+			ranges = append(ranges, restore.TableKeyRanges(table.physicalIDs)...)
+		}
+		if len(ranges) == 0 {
+			return errors.Annotate(berrors.ErrInvalidArgument, "no table matches the filter")
+		}
+	}
+
+	reports, err := restore.FetchRegionInfo(ctx, mgr.HTTPClient(), ranges)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, report := range reports {
+		log.Info("fetched the regions of the range", zap.String("start", report.StartKey),
+			zap.String("end", report.EndKey), zap.Int("regions", len(report.Regions)),
+			zap.Strings("problems", report.Problems))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Trace(encoder.Encode(reports))
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/spf13/cobra"
+)
+
+type testRegionInfoSuite struct{}
+
+var _ = Suite(&testRegionInfoSuite{})
+
+func (s *testRegionInfoSuite) parse(args ...string) (*RegionInfoConfig, error) {
+	cmd := &cobra.Command{}
+	DefineCommonFlags(cmd.Flags())
+	DefineFilterFlags(cmd, []string{"*.*"})
+	DefineRegionInfoFlags(cmd.Flags())
+	if err := cmd.Flags().Parse(args); err != nil {
+		return nil, err
+	}
+	cfg := &RegionInfoConfig{}
+	return cfg, cfg.ParseFromFlags(cmd.Flags())
+}
+
+func (s *testRegionInfoSuite) TestParseFromFlags(c *C) {
+	cfg, err := s.parse("--start", "7480", "--end", "7481")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.StartKey, DeepEquals, []byte{0x74, 0x80})
+	c.Assert(cfg.EndKey, DeepEquals, []byte{0x74, 0x81})
+
+	cfg, err = s.parse("-f", "db.t")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.StartKey, HasLen, 0)
+	c.Assert(cfg.TableFilter.MatchTable("db", "t"), IsTrue)
+	c.Assert(cfg.TableFilter.MatchTable("db", "t2"), IsFalse)
+
+	_, err = s.parse()
+	c.Assert(err, ErrorMatches, ".*either --filter or a key range must be given.*")
+	_, err = s.parse("-f", "db.t", "--start", "7480")
+	c.Assert(err, ErrorMatches, ".*either --filter or a key range must be given.*")
+	_, err = s.parse("--start", "7481", "--end", "7480")
+	c.Assert(err, ErrorMatches, ".*the end key must be greater than the start key.*")
+}
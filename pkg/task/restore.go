@@ -4,25 +4,39 @@ package task
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pingcap/br/pkg/metautil"
 
 	"github.com/pingcap/br/pkg/version"
 
+	"github.com/docker/go-units"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
 	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
 	"github.com/pingcap/tidb/config"
+	"github.com/pingcap/tidb/util/collate"
 	"github.com/spf13/pflag"
+	"github.com/tikv/client-go/v2/oracle"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"github.com/pingcap/br/pkg/conn"
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/pdutil"
 	"github.com/pingcap/br/pkg/restore"
 	"github.com/pingcap/br/pkg/storage"
@@ -31,8 +45,38 @@ import (
 )
 
 const (
-	flagOnline   = "online"
-	flagNoSchema = "no-schema"
+	flagOnline           = "online"
+	flagNoSchedulerPause = "no-scheduler-pause"
+	flagNoSchema         = "no-schema"
+	flagRewriteMap       = "rewrite-map"
+	flagRewriteMapOutput = "rewrite-map-output"
+	flagCheckOverlap     = "check-overlap"
+	flagAllowOverlap     = "allow-overlap"
+	flagMergeRegions     = "merge-regions-timeout"
+	flagRebuildIndexes   = "rebuild-collation-indexes"
+	flagApplyVariables   = "apply-variables"
+	flagEmitCDCStartTS   = "emit-cdc-start-ts"
+	flagCoordinator      = "coordinator-listen"
+	flagPartitions       = "partitions"
+	flagStagingReplicas  = "staging-replicas"
+	flagStagingTimeout   = "staging-replicas-timeout"
+	flagAdaptTopology    = "adapt-to-topology"
+
+	flagSLOProbeSQL       = "slo-probe-sql"
+	flagSLOProbeURL       = "slo-probe-url"
+	flagSLOLatency        = "slo-latency-threshold"
+	flagSLOProbeInterval  = "slo-probe-interval"
+	flagSLOMinConcurrency = "slo-min-concurrency"
+
+	flagDownloadCache     = "download-cache"
+	flagDownloadCacheSize = "download-cache-size"
+
+	flagParallelDownloadSize  = "parallel-download-size"
+	flagParallelDownloadParts = "parallel-download-parts"
+
+	// maxReportedOverlaps is the max number of the overlapped tables listed in
+	// the error.
+	maxReportedOverlaps = 10
 
 	// FlagMergeRegionSizeBytes is the flag name of merge small regions by size
 	FlagMergeRegionSizeBytes = "merge-region-size-bytes"
@@ -40,19 +84,53 @@ const (
 	FlagMergeRegionKeyCount = "merge-region-key-count"
 
 	defaultRestoreConcurrency = 128
+	defaultRestorePartitions  = 16
 	maxRestoreBatchSizeLimit  = 10240
 	defaultDDLConcurrency     = 16
+	defaultSLOLatency         = time.Second
+	defaultSLOProbeInterval   = 5 * time.Second
+	defaultDownloadCacheSize  = 100 * 1024 // MiB
+
+	defaultParallelDownloadSize  = 4 * 1024 // MiB
+	defaultParallelDownloadParts = 8
+
+	// the defaults of the `max-merge-region-size` and `max-merge-region-keys`
+	// of PD, used if PD doesn't report them.
+	defaultMaxMergeRegionSizeMiB = 20
+	defaultMaxMergeRegionKeys    = 200000
+	// mergeRegionsInterval is the time to wait for a round of the merges.
+	mergeRegionsInterval = 10 * time.Second
+
+	defaultStagingTimeout = 2 * time.Hour
+	// stagingCleanupTimeout is how long to remove the staging placement rules
+	// left when the restore exits.
+	stagingCleanupTimeout = 30 * time.Second
 )
 
 // RestoreCommonConfig is the common configuration for all BR restore tasks.
 type RestoreCommonConfig struct {
 	Online bool `json:"online" toml:"online"`
+	// NoSchedulerPause keeps the PD schedulers running during the restore.
+	NoSchedulerPause bool `json:"no-scheduler-pause" toml:"no-scheduler-pause"`
 
 	// MergeSmallRegionSizeBytes is the threshold of merging small regions (Default 96MB, region split size).
 	// MergeSmallRegionKeyCount is the threshold of merging smalle regions (Default 960_000, region split key count).
+	// The adjacent small files are coalesced into an ingest up to the thresholds as well.
 	// See https://github.com/tikv/tikv/blob/v4.0.8/components/raftstore/src/coprocessor/config.rs#L35-L38
 	MergeSmallRegionSizeBytes uint64 `json:"merge-region-size-bytes" toml:"merge-region-size-bytes"`
 	MergeSmallRegionKeyCount  uint64 `json:"merge-region-key-count" toml:"merge-region-key-count"`
+
+	// DownloadCache is the directory the retried downloads are cached in, it
+	// must be shared by BR and all the TiKVs at the same path. Empty disables it.
+	DownloadCache string `json:"download-cache" toml:"download-cache"`
+	// DownloadCacheSize is the capacity of the DownloadCache in bytes.
+	DownloadCacheSize uint64 `json:"download-cache-size" toml:"download-cache-size"`
+
+	// ParallelDownloadSize is the size in bytes since which a file spanning
+	// some regions is downloaded into them in parallel. 0 disables it.
+	ParallelDownloadSize uint64 `json:"parallel-download-size" toml:"parallel-download-size"`
+	// ParallelDownloadParts is the max regions of a file downloaded at a time.
+	ParallelDownloadParts uint `json:"parallel-download-parts" toml:"parallel-download-parts"`
 }
 
 // adjust adjusts the abnormal config value in the current config.
@@ -64,19 +142,47 @@ func (cfg *RestoreCommonConfig) adjust() {
 	if cfg.MergeSmallRegionSizeBytes == 0 {
 		cfg.MergeSmallRegionSizeBytes = restore.DefaultMergeRegionSizeBytes
 	}
+	if cfg.DownloadCacheSize == 0 {
+		cfg.DownloadCacheSize = defaultDownloadCacheSize * units.MiB
+	}
+	if cfg.ParallelDownloadParts == 0 {
+		cfg.ParallelDownloadParts = defaultParallelDownloadParts
+	}
+}
+
+// newSSTCache creates the cache of the retried downloads, nil if disabled.
+func (cfg *RestoreCommonConfig) newSSTCache() (*restore.SSTCache, error) {
+	if cfg.DownloadCache == "" {
+		return nil, nil
+	}
+	cache, err := restore.NewSSTCache(cfg.DownloadCache, cfg.DownloadCacheSize)
+	return cache, errors.Trace(err)
 }
 
 // DefineRestoreCommonFlags defines common flags for the restore command.
 func DefineRestoreCommonFlags(flags *pflag.FlagSet) {
 	// TODO remove experimental tag if it's stable
 	flags.Bool(flagOnline, false, "(experimental) Whether online when restore")
+	flags.Bool(flagNoSchedulerPause, false,
+		"don't pause the PD schedulers during the restore, the balancing may slow down the restore")
 
 	flags.Uint64(FlagMergeRegionSizeBytes, restore.DefaultMergeRegionSizeBytes,
-		"the threshold of merging small regions (Default 96MB, region split size)")
+		"the size threshold of coalescing the adjacent small files into a region to split and an ingest, "+
+			"the region split size of TiKV by default")
 	flags.Uint64(FlagMergeRegionKeyCount, restore.DefaultMergeRegionKeyCount,
-		"the threshold of merging smalle regions (Default 960_000, region split key count)")
-	_ = flags.MarkHidden(FlagMergeRegionSizeBytes)
-	_ = flags.MarkHidden(FlagMergeRegionKeyCount)
+		"the key count threshold of coalescing the adjacent small files into a region to split and an ingest, "+
+			"the region split keys of TiKV by default")
+
+	flags.String(flagDownloadCache, "",
+		"the directory caching the SST files of the retried downloads, which must be shared by BR and all the TiKVs "+
+			"at the same path, e.g. a NFS. The retries read the cache instead of the external storage")
+	flags.Uint64(flagDownloadCacheSize, defaultDownloadCacheSize, "the capacity of the download cache, MiB")
+
+	flags.Uint64(flagParallelDownloadSize, defaultParallelDownloadSize,
+		"the size of a file since which its parts in the regions are downloaded by TiKV in parallel, MiB. "+
+			"0 disables it")
+	flags.Uint(flagParallelDownloadParts, defaultParallelDownloadParts,
+		"the max parts of a large file downloaded in parallel")
 }
 
 // ParseFromFlags parses the config from the flag set.
@@ -86,6 +192,10 @@ func (cfg *RestoreCommonConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	cfg.NoSchedulerPause, err = flags.GetBool(flagNoSchedulerPause)
+	if err != nil {
+		return errors.Trace(err)
+	}
 	cfg.MergeSmallRegionKeyCount, err = flags.GetUint64(FlagMergeRegionKeyCount)
 	if err != nil {
 		return errors.Trace(err)
@@ -94,6 +204,21 @@ func (cfg *RestoreCommonConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	cfg.DownloadCache, err = flags.GetString(flagDownloadCache)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cacheSize, err := flags.GetUint64(flagDownloadCacheSize)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.DownloadCacheSize = cacheSize * units.MiB
+	parallelSize, err := flags.GetUint64(flagParallelDownloadSize)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.ParallelDownloadSize = parallelSize * units.MiB
+	cfg.ParallelDownloadParts, err = flags.GetUint(flagParallelDownloadParts)
 	return errors.Trace(err)
 }
 
@@ -103,6 +228,57 @@ type RestoreConfig struct {
 	RestoreCommonConfig
 
 	NoSchema bool `json:"no-schema" toml:"no-schema"`
+	// RenameTo restores the only table matched by the table filter under
+	// this name if it isn't nil.
+	RenameTo *filter.Table `json:"-" toml:"-"`
+	// RewriteMap is the path of a pre-built rewrite map, which decides how
+	// the keys of the tables in it are rewritten.
+	RewriteMap string `json:"rewrite-map" toml:"rewrite-map"`
+	// RewriteMapOutput is the path the rewrite map of the restore is written to.
+	RewriteMapOutput string `json:"rewrite-map-output" toml:"rewrite-map-output"`
+	// CheckOverlap scans whether the existing tables to restore into contain
+	// data before ingesting, and refuses to restore unless AllowOverlap.
+	CheckOverlap bool `json:"check-overlap" toml:"check-overlap"`
+	AllowOverlap bool `json:"allow-overlap" toml:"allow-overlap"`
+	// MergeRegionsTimeout is how long to merge the small regions of the
+	// restored tables after the restore, zero disables it.
+	MergeRegionsTimeout time.Duration `json:"merge-regions-timeout" toml:"merge-regions-timeout"`
+	// RebuildCollationIndexes rebuilds the indices encoded by the collations
+	// after restore, if the new collations of the backed up cluster are enabled
+	// differently.
+	RebuildCollationIndexes bool `json:"rebuild-collation-indexes" toml:"rebuild-collation-indexes"`
+	// ApplyVariables sets the global variables recorded by the backup, e.g.
+	// sql_mode and time_zone, in the cluster restored into after restore.
+	ApplyVariables bool `json:"apply-variables" toml:"apply-variables"`
+	// EmitCDCStartTS is the path the start point of a changefeed from the
+	// backed up cluster is written to after restore.
+	EmitCDCStartTS string `json:"emit-cdc-start-ts" toml:"emit-cdc-start-ts"`
+	// SLOProbeSQL and SLOProbeURL are the probe of the cluster restored into,
+	// by which the concurrency of restoring the files is lowered when its
+	// latency exceeds SLOLatencyThreshold, and raised back when healthy.
+	SLOProbeSQL         string        `json:"slo-probe-sql" toml:"slo-probe-sql"`
+	SLOProbeURL         string        `json:"slo-probe-url" toml:"slo-probe-url"`
+	SLOLatencyThreshold time.Duration `json:"slo-latency-threshold" toml:"slo-latency-threshold"`
+	SLOProbeInterval    time.Duration `json:"slo-probe-interval" toml:"slo-probe-interval"`
+	SLOMinConcurrency   uint          `json:"slo-min-concurrency" toml:"slo-min-concurrency"`
+	// CoordinatorListen is the address the restore plan is served to the
+	// agents on. If it's set, the files are restored by the agents running
+	// `br restore agent`, each of which restores the partitions of the tables
+	// it claims, rather than by this process.
+	CoordinatorListen string `json:"coordinator-listen" toml:"coordinator-listen"`
+	// Partitions is the number of the partitions the tables are split into
+	// for the agents.
+	Partitions uint `json:"partitions" toml:"partitions"`
+	// StagingReplicas is the number of the replicas the tables are restored
+	// with, zero disables it. The other replicas are added after the tables
+	// are restored, and the restore waits for them.
+	StagingReplicas uint `json:"staging-replicas" toml:"staging-replicas"`
+	// StagingTimeout is how long the restore waits for the other replicas of
+	// the tables restored with the staging replicas.
+	StagingTimeout time.Duration `json:"staging-replicas-timeout" toml:"staging-replicas-timeout"`
+	// AdaptTopology adjusts the concurrency and the merge thresholds left at
+	// the defaults by the topologies of the backed up and the target cluster.
+	AdaptTopology bool `json:"adapt-to-topology" toml:"adapt-to-topology"`
 }
 
 // DefineRestoreFlags defines common flags for the restore tidb command.
@@ -110,6 +286,58 @@ func DefineRestoreFlags(flags *pflag.FlagSet) {
 	flags.Bool(flagNoSchema, false, "skip creating schemas and tables, reuse existing empty ones")
 	// Do not expose this flag
 	_ = flags.MarkHidden(flagNoSchema)
+	flags.String(flagRewriteMap, "",
+		"the path of a rewrite map in JSON, which decides the new table, partition and index IDs "+
+			"the keys of the tables in it are rewritten into")
+	flags.String(flagRewriteMapOutput, "",
+		"the path to write the rewrite map of the restored tables to, in JSON")
+	flags.Bool(flagCheckOverlap, false,
+		"check whether the existing tables to restore into contain data before ingesting, "+
+			"and refuse to restore if any does")
+	flags.Bool(flagAllowOverlap, false,
+		"only report the existing tables containing data found by --"+flagCheckOverlap+", and restore anyway")
+	flags.Duration(flagMergeRegions, 0,
+		"merge the small regions of the restored tables for at most the duration after the restore, "+
+			"instead of waiting for PD to merge them, 0 to disable")
+	flags.Bool(flagRebuildIndexes, false,
+		"rebuild the indices on the collation sensitive columns after restore, "+
+			"if the new collations of the backed up cluster are enabled differently from the cluster restored into")
+	flags.Bool(flagApplyVariables, false,
+		"set the global variables of the backed up cluster interpreting the data, e.g. sql_mode, time_zone "+
+			"and the collations, in the cluster restored into after restore")
+	flags.String(flagEmitCDCStartTS, "",
+		"the path to write the start point of a changefeed from the backed up cluster to after restore, in JSON, "+
+			"so the changefeed continues exactly from the backup point")
+	flags.String(flagSLOProbeSQL, "",
+		"the SQL probing the cluster restored into, the concurrency of restoring is lowered "+
+			"while it runs longer than --"+flagSLOLatency+" or fails, and raised back when healthy")
+	flags.String(flagSLOProbeURL, "",
+		"the URL probing the service by GET, used like --"+flagSLOProbeSQL+", a status other than 2xx fails")
+	flags.Duration(flagSLOLatency, defaultSLOLatency, "the latency of the SLO probe above which it is breached")
+	flags.Duration(flagSLOProbeInterval, defaultSLOProbeInterval, "how often the SLO probe runs")
+	flags.Uint(flagSLOMinConcurrency, 1, "the concurrency of restoring never lowered below by the SLO probe")
+	flags.String(flagCoordinator, "",
+		"coordinate the agents running `br restore agent` on this address to restore the files, "+
+			"this br creates the tables, and waits for the agents to restore the partitions of them. "+
+			"Without TLS, the address must be a loopback one. The agents don't receive the storage options, "+
+			"so they must configure the storage credentials themselves")
+	flags.Uint(flagPartitions, defaultRestorePartitions,
+		"the number of the partitions of the tables claimed by the agents, "+
+			"more than the agents so that the faster ones claim more")
+	flags.Uint(flagStagingReplicas, 0,
+		"restore the tables with fewer replicas by the placement rules to speed up ingesting, "+
+			"then add the other replicas and wait for them after the restore, 0 to disable. "+
+			"It must be fewer than the replicas of the default placement rule")
+	flags.Duration(flagStagingTimeout, defaultStagingTimeout,
+		"how long to wait for the other replicas of the tables restored with the staging replicas, "+
+			"the restore fails if they aren't added in time")
+
+	flags.Bool(flagAdaptTopology, true,
+		"adjust the concurrency and the merge thresholds left at the defaults by the stores and the region settings "+
+			"of the backed up and the target cluster, and warn if the target cluster is much smaller")
+	flags.Bool(flagWithBindings, false,
+		"restore the SQL bindings in the backup besides the tables selected, the bindings existing are kept, "+
+			"so the plans don't regress after the restore")
 
 	DefineRestoreCommonFlags(flags)
 }
@@ -121,6 +349,60 @@ func (cfg *RestoreConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	cfg.RewriteMap, err = flags.GetString(flagRewriteMap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.RewriteMapOutput, err = flags.GetString(flagRewriteMapOutput)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.CheckOverlap, err = flags.GetBool(flagCheckOverlap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.AllowOverlap, err = flags.GetBool(flagAllowOverlap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.MergeRegionsTimeout, err = flags.GetDuration(flagMergeRegions)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.RebuildCollationIndexes, err = flags.GetBool(flagRebuildIndexes)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ApplyVariables, err = flags.GetBool(flagApplyVariables); err != nil {
+		return errors.Trace(err)
+	}
+	cfg.EmitCDCStartTS, err = flags.GetString(flagEmitCDCStartTS)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = cfg.parseSLOProbeFromFlags(flags); err != nil {
+		return errors.Trace(err)
+	}
+	cfg.CoordinatorListen, err = flags.GetString(flagCoordinator)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.Partitions, err = flags.GetUint(flagPartitions)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.StagingReplicas, err = flags.GetUint(flagStagingReplicas)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.StagingTimeout, err = flags.GetDuration(flagStagingTimeout)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.AdaptTopology, err = flags.GetBool(flagAdaptTopology)
+	if err != nil {
+		return errors.Trace(err)
+	}
 	err = cfg.Config.ParseFromFlags(flags)
 	if err != nil {
 		return errors.Trace(err)
@@ -136,6 +418,39 @@ func (cfg *RestoreConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	return nil
 }
 
+func (cfg *RestoreConfig) parseSLOProbeFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	cfg.SLOProbeSQL, err = flags.GetString(flagSLOProbeSQL)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.SLOProbeURL, err = flags.GetString(flagSLOProbeURL)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.SLOLatencyThreshold, err = flags.GetDuration(flagSLOLatency)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.SLOProbeInterval, err = flags.GetDuration(flagSLOProbeInterval)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.SLOMinConcurrency, err = flags.GetUint(flagSLOMinConcurrency)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.SLOProbeSQL != "" && cfg.SLOProbeURL != "" {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"--%s and --%s can't be specified together", flagSLOProbeSQL, flagSLOProbeURL)
+	}
+	if (cfg.SLOProbeSQL != "" || cfg.SLOProbeURL != "") && (cfg.SLOLatencyThreshold <= 0 || cfg.SLOProbeInterval <= 0) {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"--%s and --%s must be positive", flagSLOLatency, flagSLOProbeInterval)
+	}
+	return nil
+}
+
 // adjustRestoreConfig is use for BR(binary) and BR in TiDB.
 // When new config was add and not included in parser.
 // we should set proper value in this function.
@@ -150,6 +465,12 @@ func (cfg *RestoreConfig) adjustRestoreConfig() {
 	if cfg.Config.SwitchModeInterval == 0 {
 		cfg.Config.SwitchModeInterval = defaultSwitchInterval
 	}
+	if cfg.SLOLatencyThreshold == 0 {
+		cfg.SLOLatencyThreshold = defaultSLOLatency
+	}
+	if cfg.SLOProbeInterval == 0 {
+		cfg.SLOProbeInterval = defaultSLOProbeInterval
+	}
 }
 
 // CheckRestoreDBAndTable is used to check whether the restore dbs or tables have been backup
@@ -189,21 +510,41 @@ func CheckRestoreDBAndTable(client *restore.Client, cfg *RestoreConfig) error {
 
 // RunRestore starts a restore task inside the current goroutine.
 func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConfig) error {
+	if done, err := validateTask(cmdName, cfg, cfg.ValidateOnly); done {
+		return errors.Trace(err)
+	}
 	cfg.adjustRestoreConfig()
 
 	defer summary.Summary(cmdName)
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	stopAudit, err := startAuditLog(ctx, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer stopAudit()
+
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("task.RunRestore", opentracing.ChildOf(span.Context()))
 		defer span1.Finish()
 		ctx = opentracing.ContextWithSpan(ctx, span1)
 	}
 
+	var (
+		coordinatorListener net.Listener
+		coordinatorTLS      *tls.Config
+	)
+	if cfg.CoordinatorListen != "" {
+		if coordinatorListener, coordinatorTLS, err = listenCoordinator(cfg); err != nil {
+			return errors.Trace(err)
+		}
+		defer coordinatorListener.Close()
+	}
+
 	// Restore needs domain to do DDL.
 	needDomain := true
-	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.CheckRequirements, needDomain)
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, needDomain)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -230,14 +571,45 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		return errors.Trace(err)
 	}
 	client.SetRateLimit(cfg.RateLimit)
+	client.SetPDHTTPClient(mgr.HTTPClient())
+	client.SetTableHooks(restore.RegisteredTableHooks())
 	client.SetConcurrency(uint(cfg.Concurrency))
+	client.SetIngestMergeThreshold(cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount)
+	sstCache, err := cfg.newSSTCache()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	client.SetSSTCache(sstCache)
+	client.SetParallelDownload(cfg.ParallelDownloadSize, cfg.ParallelDownloadParts)
 	if cfg.Online {
 		client.EnableOnline()
 	}
+	if cfg.StagingReplicas > 0 {
+		client.EnableStagingReplicas(int(cfg.StagingReplicas))
+		if err = client.CheckStagingReplicas(ctx); err != nil {
+			return errors.Trace(err)
+		}
+		defer func() {
+			// the rules left by a failed restore would keep the tables with
+			// the staging replicas.
+			cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), stagingCleanupTimeout)
+			defer cleanupCancel()
+			if err := client.CleanupStagingRules(cleanupCtx); err != nil {
+				log.Warn("failed to clean up the staging placement rules", zap.Error(err))
+			}
+		}()
+	}
 	if cfg.NoSchema {
 		client.EnableSkipCreateSQL()
 	}
 	client.SetSwitchModeInterval(cfg.SwitchModeInterval)
+	if cfg.RewriteMap != "" {
+		rewriteMap, err := readRewriteMap(cfg.RewriteMap)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		client.SetPrebuiltRewriteMap(rewriteMap)
+	}
 	err = client.LoadRestoreStores(ctx)
 	if err != nil {
 		return errors.Trace(err)
@@ -247,6 +619,9 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = metautil.CheckMetaVersion(backupMeta); err != nil {
+		return errors.Trace(err)
+	}
 	backupVersion := version.NormalizeBackupVersion(backupMeta.ClusterVersion)
 	if cfg.CheckRequirements && backupVersion != nil {
 		if versionErr := version.CheckClusterVersion(ctx, mgr.GetPDClient(), version.CheckVersionForBackup(backupVersion)); versionErr != nil {
@@ -268,8 +643,28 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	if len(dbs) == 0 && len(tables) != 0 {
 		return errors.Annotate(berrors.ErrRestoreInvalidBackup, "contain tables but no databases")
 	}
+	if cfg.RenameTo != nil {
+		if tables, dbs, err = renameRestoreTable(tables, *cfg.RenameTo); err != nil {
+			return errors.Trace(err)
+		}
+		if mgr.GetDomain().InfoSchema().TableExists(tables[0].DB.Name, tables[0].Info.Name) {
+			return errors.Annotatef(berrors.ErrInvalidArgument, "table %s already exists",
+				utils.EncloseDBAndTable(tables[0].DB.Name.O, tables[0].Info.Name.O))
+		}
+	}
+	clusterInfo, err := metautil.ReadClusterInfo(ctx, s)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	collationAffected, err := checkNewCollations(clusterInfo, tables, cfg.RebuildCollationIndexes)
+	if err != nil {
+		return errors.Trace(err)
+	}
 	archiveSize := reader.ArchiveSize(ctx, files)
 	g.Record(summary.RestoreDataSize, archiveSize)
+	if cfg.AdaptTopology {
+		adaptToTopology(ctx, mgr, client, cfg, clusterInfo, archiveSize)
+	}
 	restoreTS, err := client.GetTS(ctx)
 	if err != nil {
 		return errors.Trace(err)
@@ -293,6 +688,11 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		newTS = restoreTS
 	}
 	ddlJobs := restore.FilterDDLJobs(client.GetDDLJobs(), tables)
+	if client.IsIncremental() {
+		// replay the schema changes between the last backup and this one
+		// before creating the tables and ingesting the incremental data.
+		ddlJobs = restore.FilterDDLJobsInRange(ddlJobs, backupMeta.StartVersion, backupMeta.EndVersion)
+	}
 
 	err = client.PreCheckTableTiFlashReplica(ctx, tables)
 	if err != nil {
@@ -322,6 +722,13 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		return nil
 	}
 
+	// the incremental data is expected to be restored into the existing tables.
+	if cfg.CheckOverlap && !client.IsIncremental() {
+		if err = checkTableOverlaps(ctx, mgr, tables, cfg.AllowOverlap); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
 	for _, db := range dbs {
 		err = client.CreateDatabase(ctx, db.Info)
 		if err != nil {
@@ -360,25 +767,32 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	tableFileMap := restore.MapTableToFiles(files)
 	log.Debug("mapped table to files", zap.Any("result map", tableFileMap))
 
-	rangeStream := restore.GoValidateFileRanges(
-		ctx, tableStream, tableFileMap, cfg.MergeSmallRegionKeyCount, cfg.MergeSmallRegionKeyCount, errCh)
+	var rangeStream <-chan restore.TableWithRange
+	if cfg.CoordinatorListen == "" {
+		rangeStream = restore.GoValidateFileRanges(
+			ctx, tableStream, tableFileMap, cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount, errCh)
+	}
 
 	rangeSize := restore.EstimateRangeSize(files)
 	summary.CollectInt("restore ranges", rangeSize)
 	log.Info("range and file prepared", zap.Int("file count", len(files)), zap.Int("range count", rangeSize))
 
-	restoreSchedulers, err := restorePreWork(ctx, client, mgr)
+	restoreSchedulers, err := restorePreWork(ctx, client, mgr, cfg.NoSchedulerPause)
 	if err != nil {
 		return errors.Trace(err)
 	}
 	// Always run the post-work even on error, so we don't stuck in the import
 	// mode or emptied schedulers
-	defer restorePostWork(ctx, client, restoreSchedulers)
+	var postWorkOnce sync.Once
+	postWork := func() {
+		postWorkOnce.Do(func() { restorePostWork(ctx, client, restoreSchedulers) })
+	}
+	defer postWork()
 
 	// Do not reset timestamp if we are doing incremental restore, because
 	// we are not allowed to decrease timestamp.
 	if !client.IsIncremental() {
-		if err = client.ResetTS(ctx, cfg.PD); err != nil {
+		if err = client.ResetTS(ctx, mgr.HTTPClient()); err != nil {
 			log.Error("reset pd TS failed", zap.Error(err))
 			return errors.Trace(err)
 		}
@@ -392,22 +806,45 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	})
 
 	// Redirect to log if there is no log file to avoid unreadable output.
-	updateCh := g.StartProgress(
-		ctx,
+	updateCh, err := startProgress(
+		ctx, g, mgr.GetStorage(), &cfg.Config,
+		cmdName,
 		cmdName,
 		// Split/Scatter + Download/Ingest + Checksum
-		int64(rangeSize+len(files)+len(tables)),
-		!cfg.LogProgress)
+		int64(rangeSize+len(files)+len(tables)))
+	if err != nil {
+		return errors.Trace(err)
+	}
 	defer updateCh.Close()
-	sender, err := restore.NewTiKVSender(ctx, client, updateCh)
+	var afterRestoreStream <-chan restore.CreatedTable
+	if cfg.CoordinatorListen != "" {
+		afterRestoreStream = goCoordinateRestore(ctx, coordinatorListener, coordinatorTLS, cfg, tables, tableStream, newTS, updateCh, errCh)
+	} else {
+		sender, err := restore.NewTiKVSender(ctx, client, updateCh)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		manager := restore.NewBRContextManager(client)
+		var batcher *restore.Batcher
+		batcher, afterRestoreStream = restore.NewBatcher(ctx, sender, manager, errCh)
+		batcher.SetThreshold(batchSize)
+		batcher.EnableAutoCommit(ctx, time.Second)
+		go restoreTableStream(ctx, rangeStream, batcher, errCh)
+	}
+
+	probe, err := newSLOProbe(g, mgr, cfg)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	manager := restore.NewBRContextManager(client)
-	batcher, afterRestoreStream := restore.NewBatcher(ctx, sender, manager, errCh)
-	batcher.SetThreshold(batchSize)
-	batcher.EnableAutoCommit(ctx, time.Second)
-	go restoreTableStream(ctx, rangeStream, batcher, errCh)
+	if probe != nil {
+		defer probe.Close()
+		stopGovernor := client.GoGovernSpeed(ctx, probe, restore.SpeedGovernorConfig{
+			Interval:         cfg.SLOProbeInterval,
+			LatencyThreshold: cfg.SLOLatencyThreshold,
+			MinConcurrency:   cfg.SLOMinConcurrency,
+		})
+		defer stopGovernor()
+	}
 
 	var finish <-chan struct{}
 	// Checksum
@@ -416,7 +853,7 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 			ctx, afterRestoreStream, mgr.GetStorage().GetClient(), errCh, updateCh, cfg.ChecksumConcurrency)
 	} else {
 		// when user skip checksum, just collect tables, and drop them.
-		finish = dropToBlackhole(ctx, afterRestoreStream, errCh, updateCh)
+		finish = dropToBlackhole(ctx, afterRestoreStream, client.RunTableHooks, errCh, updateCh)
 	}
 
 	select {
@@ -430,20 +867,333 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		return errors.Trace(err)
 	}
 
+	// Rebase the auto IDs above the restored data, after all of it is ingested.
+	if err = client.RebaseAutoIDs(ctx, mgr.GetDomain(), tables); err != nil {
+		return errors.Trace(err)
+	}
+	if err = client.RebuildCollationIndexes(ctx, collationAffected); err != nil {
+		return errors.Trace(err)
+	}
+
 	// The cost of rename user table / replace into system table wouldn't be so high.
 	// So leave it out of the pipeline for easier implementation.
 	client.RestoreSystemSchemas(ctx, cfg.TableFilter)
 
+	if err = applyGlobalVariables(ctx, client, clusterInfo, cfg.ApplyVariables); err != nil {
+		return errors.Trace(err)
+	}
+
+	if cfg.RewriteMapOutput != "" {
+		if err = writeRewriteMap(cfg.RewriteMapOutput, client.RewriteMap()); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if cfg.EmitCDCStartTS != "" {
+		startPoint := newCDCStartPoint(backupMeta, clusterInfo, tables)
+		if err = writeCDCStartPoint(cfg.EmitCDCStartTS, startPoint); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if cfg.StagingReplicas > 0 {
+		// the replicas are added in the normal mode with the schedule limits
+		// of PD restored.
+		postWork()
+		if err = waitRestoredReplicated(ctx, g, mgr, client, cfg, cmdName); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if cfg.MergeRegionsTimeout > 0 {
+		// the regions are merged in the normal mode with the merge configs of
+		// PD restored.
+		postWork()
+		mergeRestoredRegions(ctx, mgr, client.RewriteMap().NewPhysicalIDs(), cfg.MergeRegionsTimeout)
+	}
+
 	// Set task summary to success status.
 	summary.SetSuccessStatus(true)
 	return nil
 }
 
+// newSLOProbe returns the SLO probe configured, or nil if there is none. The
+// SQL probe runs in its own session since the session of the client executes
+// the DDLs concurrently.
+func newSLOProbe(g glue.Glue, mgr *conn.Mgr, cfg *RestoreConfig) (restore.SLOProbe, error) {
+	switch {
+	case cfg.SLOProbeSQL != "":
+		db, err := restore.NewDB(g, mgr.GetStorage())
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if db == nil {
+			return nil, errors.Annotatef(berrors.ErrInvalidArgument, "--%s requires a TiDB session", flagSLOProbeSQL)
+		}
+		return restore.NewSQLProbe(db, cfg.SLOProbeSQL), nil
+	case cfg.SLOProbeURL != "":
+		return restore.NewHTTPProbe(&http.Client{}, cfg.SLOProbeURL), nil
+	default:
+		return nil, nil
+	}
+}
+
+// checkTableOverlaps reports the existing tables to restore into that already
+// contain data, whose data would be overwritten and only be noticed by the
+// checksum at the end.
+func checkTableOverlaps(ctx context.Context, mgr *conn.Mgr, tables []*metautil.Table, allowOverlap bool) error {
+	physical, logical, err := mgr.GetPDClient().GetTS(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	overlaps, err := restore.ScanTableOverlaps(mgr.GetStorage(), mgr.GetDomain(), tables, oracle.ComposeTS(physical, logical))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(overlaps) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(overlaps))
+	for _, overlap := range overlaps {
+		name := utils.EncloseDBAndTable(overlap.DB.O, overlap.Table.O)
+		log.Warn("the table to restore into contains data",
+			zap.String("table", name),
+			zap.Int64("physicalID", overlap.PhysicalID),
+			logutil.Key("key", overlap.Key))
+		if len(names) < maxReportedOverlaps {
+			names = append(names, name)
+		}
+	}
+	summary.CollectInt("overlapped tables", len(overlaps))
+	if allowOverlap {
+		return nil
+	}
+	return errors.Annotatef(berrors.ErrRestoreTableOverlap,
+		"%d tables to restore into contain data, e.g. %s, use --%s to restore anyway",
+		len(overlaps), strings.Join(names, ", "), flagAllowOverlap)
+}
+
+// applyGlobalVariables sets the global variables recorded by the backup in the
+// cluster restored into if apply, otherwise reports them to be applied
+// manually.
+func applyGlobalVariables(ctx context.Context, client *restore.Client, info *metautil.ClusterInfo, apply bool) error {
+	if info == nil || len(info.GlobalVariables) == 0 {
+		if apply {
+			log.Warn("the backup records no global variables, skip applying them")
+		}
+		return nil
+	}
+	if !apply {
+		log.Info("the global variables of the backed up cluster aren't applied, "+
+			"compare them with the cluster restored into, or apply them by --"+flagApplyVariables,
+			zap.Any("variables", info.GlobalVariables))
+		return nil
+	}
+	return errors.Trace(client.ApplyGlobalVariables(ctx, info.GlobalVariables))
+}
+
+// checkNewCollations compares whether the new collations are enabled in the
+// backed up cluster and the cluster restored into, and finds the restored
+// tables whose keys are encoded by the collations if they differ. The keys
+// encoded under the other setting are unreadable, so the restore is refused
+// unless the indices are rebuilt after it.
+func checkNewCollations(
+	info *metautil.ClusterInfo,
+	tables []*metautil.Table,
+	rebuildIndexes bool,
+) ([]*restore.CollationAffectedTable, error) {
+	// the domain of the cluster restored into is bootstrapped, which loads
+	// whether its new collations are enabled.
+	enabled := collate.NewCollationEnabled()
+	if info == nil || info.NewCollationsEnabled == nil {
+		log.Info("the backup doesn't record whether the new collations are enabled, skip checking",
+			zap.Bool("new-collations-enabled", enabled))
+		return nil, nil
+	}
+	backupEnabled := *info.NewCollationsEnabled
+	if backupEnabled == enabled {
+		return nil, nil
+	}
+	affected := restore.FindCollationAffectedTables(tables)
+	if len(affected) == 0 {
+		log.Warn("the new collations are enabled differently from the backed up cluster, "+
+			"but no table to restore is affected",
+			zap.Bool("backup", backupEnabled), zap.Bool("restore", enabled))
+		return nil, nil
+	}
+	names := make([]string, 0, len(affected))
+	var unrebuildable []string
+	for _, t := range affected {
+		name := utils.EncloseDBAndTable(t.Table.DB.Name.O, t.Table.Info.Name.O)
+		log.Warn("the table contains keys encoded by the collations",
+			zap.String("table", name),
+			zap.Int("indices", len(t.Indices)),
+			zap.String("unrebuildable", t.Unrebuildable))
+		names = append(names, name)
+		if t.Unrebuildable != "" {
+			unrebuildable = append(unrebuildable, fmt.Sprintf("%s: %s", name, t.Unrebuildable))
+		}
+	}
+	if len(unrebuildable) > 0 {
+		return nil, errors.Annotatef(berrors.ErrRestoreModeMismatch,
+			"new_collations_enabled_on_first_bootstrap of the backup is %t but of the cluster is %t, "+
+				"and %d tables can't be restored: %s",
+			backupEnabled, enabled, len(unrebuildable), strings.Join(unrebuildable, "; "))
+	}
+	if !rebuildIndexes {
+		return nil, errors.Annotatef(berrors.ErrRestoreModeMismatch,
+			"new_collations_enabled_on_first_bootstrap of the backup is %t but of the cluster is %t, "+
+				"the indices of %d tables must be rebuilt: %s, use --%s to rebuild them after restore",
+			backupEnabled, enabled, len(names), strings.Join(names, ", "), flagRebuildIndexes)
+	}
+	summary.CollectInt("collation rebuilt tables", len(affected))
+	return affected, nil
+}
+
+// getRegionMergeConfig returns the merge limits of PD, or the defaults if they
+// can't be got.
+func getRegionMergeConfig(ctx context.Context, mgr *conn.Mgr) restore.RegionMergeConfig {
+	mergeCfg := restore.RegionMergeConfig{
+		MaxRegionSizeMiB: defaultMaxMergeRegionSizeMiB,
+		MaxRegionKeys:    defaultMaxMergeRegionKeys,
+		Interval:         mergeRegionsInterval,
+	}
+	if scheduleCfg, err := mgr.GetPDScheduleConfig(ctx); err == nil {
+		if size, ok := scheduleCfg["max-merge-region-size"].(float64); ok && size > 0 {
+			mergeCfg.MaxRegionSizeMiB = int64(size)
+		}
+		if keys, ok := scheduleCfg["max-merge-region-keys"].(float64); ok && keys > 0 {
+			mergeCfg.MaxRegionKeys = int64(keys)
+		}
+	}
+	return mergeCfg
+}
+
+// waitRestoredReplicated waits for the replicas of the restored tables
+// restored with the staging replicas, reporting the tables replicated as the
+// progress.
+func waitRestoredReplicated(
+	ctx context.Context, g glue.Glue, mgr *conn.Mgr, client *restore.Client, cfg *RestoreConfig, cmdName string,
+) error {
+	ids := client.RewriteMap().NewPhysicalIDs()
+	updateCh, err := startProgress(ctx, g, mgr.GetStorage(), &cfg.Config, cmdName, "Replicate", int64(len(ids)))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer updateCh.Close()
+	return errors.Trace(client.WaitReplicated(ctx, ids, cfg.StagingTimeout, updateCh))
+}
+
+// mergeRestoredRegions merges the small regions of the restored tables split
+// by the restore, and reports how many are merged. The failure only leaves
+// the regions to PD, so it doesn't fail the restore.
+func mergeRestoredRegions(ctx context.Context, mgr *conn.Mgr, physicalIDs []int64, timeout time.Duration) {
+	mergeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	undo, err := mgr.AccelerateRegionMerge(mergeCtx, timeout)
+	if err != nil {
+		log.Warn("failed to raise the merge schedule limit", zap.Error(err))
+	}
+	defer func() {
+		if err := undo(ctx); err != nil {
+			log.Warn("failed to restore the merge schedule limit", zap.Error(err))
+		}
+	}()
+
+	mergeCfg := getRegionMergeConfig(mergeCtx, mgr)
+	stat, err := restore.MergeRegions(mergeCtx, mgr.HTTPClient(), restore.TableKeyRanges(physicalIDs), mergeCfg)
+	switch {
+	case err == nil:
+	case errors.Cause(err) == context.DeadlineExceeded && ctx.Err() == nil: // nolint:errorlint
+		log.Info("merging the restored regions timed out, the rest are left to PD", zap.Duration("timeout", timeout))
+	default:
+		log.Warn("failed to merge the restored regions", zap.Error(err))
+	}
+	if stat != nil {
+		log.Info("merged the restored regions", zap.Int("rounds", stat.Rounds),
+			zap.Int("before", stat.RegionsBefore), zap.Int("after", stat.RegionsAfter),
+			zap.Int("merges", stat.MergesSent), zap.Int("rejected", stat.MergesRejected))
+		summary.CollectInt("merged regions", stat.RegionsBefore-stat.RegionsAfter)
+	}
+}
+
+func readRewriteMap(path string) (*restore.RewriteMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed to read rewrite map %s", path)
+	}
+	rewriteMap, err := restore.ParseRewriteMap(data)
+	return rewriteMap, errors.Annotatef(err, "invalid rewrite map %s", path)
+}
+
+func writeRewriteMap(path string, rewriteMap *restore.RewriteMap) error {
+	data, err := json.MarshalIndent(rewriteMap, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return errors.Annotatef(err, "failed to write rewrite map %s", path)
+	}
+	log.Info("rewrite map written", zap.String("path", path))
+	return nil
+}
+
+// cdcStartPoint is where a changefeed from the backed up cluster starts after
+// restore, the changes after the backup point are replicated into the cluster
+// restored into by it.
+type cdcStartPoint struct {
+	// StartTS is the backup point, the `--start-ts` of the changefeed.
+	StartTS        uint64 `json:"start-ts"`
+	ClusterID      uint64 `json:"cluster-id"`
+	ClusterVersion string `json:"cluster-version"`
+	// SchemaVersion and RecentDDLs are the schema at the backup point, they
+	// are unknown for the backups taken by the older BR.
+	SchemaVersion int64                 `json:"schema-version,omitempty"`
+	RecentDDLs    []*metautil.DDLRecord `json:"recent-ddls,omitempty"`
+	// Tables are the restored tables, which the changefeed should replicate.
+	Tables []string `json:"tables"`
+}
+
+func newCDCStartPoint(
+	backupMeta *backuppb.BackupMeta,
+	info *metautil.ClusterInfo,
+	tables []*metautil.Table,
+) *cdcStartPoint {
+	startPoint := &cdcStartPoint{
+		StartTS:        backupMeta.EndVersion,
+		ClusterID:      backupMeta.ClusterId,
+		ClusterVersion: backupMeta.ClusterVersion,
+		Tables:         make([]string, 0, len(tables)),
+	}
+	if info != nil {
+		startPoint.SchemaVersion = info.SchemaVersion
+		startPoint.RecentDDLs = info.RecentDDLs
+	}
+	for _, table := range tables {
+		startPoint.Tables = append(startPoint.Tables, utils.EncloseDBAndTable(table.DB.Name.O, table.Info.Name.O))
+	}
+	return startPoint
+}
+
+func writeCDCStartPoint(path string, startPoint *cdcStartPoint) error {
+	data, err := json.MarshalIndent(startPoint, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return errors.Annotatef(err, "failed to write the changefeed start point %s", path)
+	}
+	log.Info("changefeed start point written", zap.String("path", path), zap.Uint64("start-ts", startPoint.StartTS))
+	return nil
+}
+
 // dropToBlackhole drop all incoming tables into black hole,
-// i.e. don't execute checksum, just increase the process anyhow.
+// i.e. don't execute checksum, just run the table hooks if any and increase
+// the process anyhow.
 func dropToBlackhole(
 	ctx context.Context,
 	tableStream <-chan restore.CreatedTable,
+	runHooks func(context.Context, restore.CreatedTable) error,
 	errCh chan<- error,
 	updateCh glue.Progress,
 ) <-chan struct{} {
@@ -457,10 +1207,16 @@ func dropToBlackhole(
 			case <-ctx.Done():
 				errCh <- ctx.Err()
 				return
-			case _, ok := <-tableStream:
+			case tbl, ok := <-tableStream:
 				if !ok {
 					return
 				}
+				if runHooks != nil {
+					if err := runHooks(ctx, tbl); err != nil {
+						errCh <- err
+						return
+					}
+				}
 				updateCh.Inc()
 			}
 		}
@@ -493,9 +1249,41 @@ func filterRestoreFiles(
 	return
 }
 
+// renameRestoreTable renames the only table to restore to the target. The
+// files are still mapped to the table by the table ID in the backup, so only
+// the schema is changed.
+func renameRestoreTable(
+	tables []*metautil.Table,
+	target filter.Table,
+) ([]*metautil.Table, []*utils.Database, error) {
+	if len(tables) != 1 {
+		return nil, nil, errors.Annotatef(berrors.ErrInvalidArgument,
+			"exactly one table can be renamed, but %d tables are matched", len(tables))
+	}
+	dbInfo := tables[0].DB.Clone()
+	dbInfo.Name = model.NewCIStr(target.Schema)
+	dbInfo.Tables = nil
+	table := *tables[0]
+	table.DB = dbInfo
+	table.Info = tables[0].Info.Clone()
+	table.Info.Name = model.NewCIStr(target.Name)
+	if table.Stats != nil {
+		stats := *table.Stats
+		stats.DatabaseName = target.Schema
+		stats.TableName = target.Name
+		table.Stats = &stats
+	}
+	log.Info("restore table under a new name",
+		zap.String("table", utils.EncloseDBAndTable(tables[0].DB.Name.O, tables[0].Info.Name.O)),
+		zap.String("as", utils.EncloseDBAndTable(target.Schema, target.Name)))
+	return []*metautil.Table{&table}, []*utils.Database{{Info: dbInfo, Tables: []*metautil.Table{&table}}}, nil
+}
+
 // restorePreWork executes some prepare work before restore.
 // TODO make this function returns a restore post work.
-func restorePreWork(ctx context.Context, client *restore.Client, mgr *conn.Mgr) (pdutil.UndoFunc, error) {
+func restorePreWork(
+	ctx context.Context, client *restore.Client, mgr *conn.Mgr, noSchedulerPause bool,
+) (pdutil.UndoFunc, error) {
 	if client.IsOnline() {
 		return pdutil.Nop, nil
 	}
@@ -503,6 +1291,10 @@ func restorePreWork(ctx context.Context, client *restore.Client, mgr *conn.Mgr)
 	// Switch TiKV cluster to import mode (adjust rocksdb configuration).
 	client.SwitchToImportMode(ctx)
 
+	if noSchedulerPause {
+		log.Warn("PD schedulers are not paused during the restore")
+		return pdutil.Nop, nil
+	}
 	return mgr.RemoveSchedulers(ctx)
 }
 
@@ -0,0 +1,419 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pingcap/errors"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/conn"
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagCoordinatorAddr = "coordinator"
+	flagAgentName       = "agent-name"
+
+	// agentClaimInterval is how often an agent claims a partition again while
+	// the others are all claimed but not restored yet, which may be reclaimed
+	// from the lost agents.
+	agentClaimInterval = 5 * time.Second
+	// coordinatorGracePeriod is how long the coordinator keeps telling the
+	// agents that all the partitions are restored, after which the agents
+	// not told yet are considered lost.
+	coordinatorGracePeriod = 3 * agentClaimInterval
+)
+
+// RestoreAgentConfig is the configuration of `br restore agent`.
+type RestoreAgentConfig struct {
+	RestoreConfig
+
+	// Coordinator is the address of the br running with --coordinator-listen.
+	Coordinator string `json:"coordinator" toml:"coordinator"`
+	// AgentName is the name of the agent reported to the coordinator.
+	AgentName string `json:"agent-name" toml:"agent-name"`
+}
+
+// DefineRestoreAgentFlags defines the flags of `br restore agent`.
+func DefineRestoreAgentFlags(flags *pflag.FlagSet) {
+	flags.String(flagCoordinatorAddr, "", "the address of the br restoring with --"+flagCoordinator)
+	flags.String(flagAgentName, "", "the name of the agent reported to the coordinator, hostname:pid by default")
+}
+
+// ParseFromFlags parses the restore agent flags from the flag set.
+func (cfg *RestoreAgentConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.Coordinator, err = flags.GetString(flagCoordinatorAddr); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Coordinator == "" {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s is required", flagCoordinatorAddr)
+	}
+	if cfg.AgentName, err = flags.GetString(flagAgentName); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.AgentName == "" {
+		hostname, _ := os.Hostname()
+		cfg.AgentName = fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	}
+	return errors.Trace(cfg.RestoreConfig.ParseFromFlags(flags))
+}
+
+// listenCoordinator listens on the address serving the restore plan to the
+// agents. The plan points to the backup, so the coordinator only accepts the
+// agents presenting a certificate signed by the cluster CA, or listens on the
+// loopback interface if TLS is disabled.
+func listenCoordinator(cfg *RestoreConfig) (net.Listener, *tls.Config, error) {
+	var serverTLS *tls.Config
+	if cfg.TLS.IsEnabled() {
+		var err error
+		if serverTLS, err = agentServerTLSConfig(&cfg.TLS); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	} else if !isLoopbackAddr(cfg.CoordinatorListen) {
+		return nil, nil, errors.Annotatef(berrors.ErrInvalidArgument,
+			"the coordinator refuses to listen on the non-loopback address %s without TLS", cfg.CoordinatorListen)
+	}
+	listener, err := net.Listen("tcp", cfg.CoordinatorListen)
+	if err != nil {
+		return nil, nil, errors.Annotatef(berrors.ErrInvalidArgument,
+			"failed to listen on %s: %s", cfg.CoordinatorListen, err)
+	}
+	return listener, serverTLS, nil
+}
+
+// planStorage returns the storage URL of the backup shared in the restore
+// plan, without the options which may carry the credentials.
+func planStorage(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.Annotatef(berrors.ErrInvalidArgument, "invalid storage URL: %s", err)
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// goCoordinateRestore waits for the tables to be created, then serves the
+// plan restoring them to the agents on the listener, and sends the tables to
+// the output after the agents have restored all of them.
+func goCoordinateRestore(
+	ctx context.Context,
+	listener net.Listener,
+	serverTLS *tls.Config,
+	cfg *RestoreConfig,
+	tables []*metautil.Table,
+	tableStream <-chan restore.CreatedTable,
+	newTS uint64,
+	updateCh glue.Progress,
+	errCh chan<- error,
+) <-chan restore.CreatedTable {
+	outCh := make(chan restore.CreatedTable, len(tables))
+	go func() {
+		defer close(outCh)
+		created := make([]restore.CreatedTable, 0, len(tables))
+		for t := range tableStream {
+			created = append(created, t)
+		}
+		if ctx.Err() != nil {
+			errCh <- ctx.Err()
+			return
+		}
+
+		storageURL, err := planStorage(cfg.Storage)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		partitions := restore.PartitionTables(tables, int(cfg.Partitions))
+		coordinator := restore.NewCoordinator(restore.Plan{
+			Storage:    storageURL,
+			NewTS:      newTS,
+			Partitions: partitions,
+		})
+		tableFiles := make(map[restore.TableName][]*backuppb.File, len(tables))
+		for _, t := range tables {
+			tableFiles[restore.TableName{DB: t.DB.Name.O, Table: t.Info.Name.O}] = t.Files
+		}
+		coordinator.OnPartitionRestored(func(p restore.Partition) {
+			// the same steps as restoring the files locally.
+			var files []*backuppb.File
+			for _, name := range p.Tables {
+				files = append(files, tableFiles[name]...)
+			}
+			for i := 0; i < restore.EstimateRangeSize(files)+len(files); i++ {
+				updateCh.Inc()
+			}
+		})
+
+		server := &http.Server{Handler: coordinator, TLSConfig: serverTLS}
+		go func() {
+			var err error
+			if server.TLSConfig != nil {
+				err = server.ServeTLS(listener, "", "")
+			} else {
+				err = server.Serve(listener)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Warn("restore coordinator stopped", zap.Error(err))
+			}
+		}()
+		defer server.Close()
+		log.Info("waiting for the agents to restore the partitions",
+			zap.String("listen", cfg.CoordinatorListen), zap.Int("partitions", len(partitions)))
+
+		if err := coordinator.Wait(ctx); err != nil {
+			errCh <- err
+			return
+		}
+		for _, t := range created {
+			outCh <- t
+		}
+		// the agents polling for the partitions exit successfully only if
+		// they are told so before the server closes.
+		coordinator.WaitAgents(ctx, coordinatorGracePeriod)
+	}()
+	return outCh
+}
+
+// RunRestoreAgent restores the partitions of the tables claimed from the
+// coordinator, until all the partitions are restored. The coordinator has
+// created the tables, paused the schedulers and switched TiKV to the import
+// mode, so the agent only splits and ingests the files.
+func RunRestoreAgent(c context.Context, g glue.Glue, cmdName string, cfg *RestoreAgentConfig) error {
+	defer summary.Summary(cmdName)
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	tlsConf, err := cfg.TLS.ToTLSConfig()
+	if err != nil && cfg.TLS.IsEnabled() {
+		return errors.Trace(err)
+	}
+	if !cfg.TLS.IsEnabled() {
+		tlsConf = nil
+	}
+	coordinator := restore.NewCoordinatorClient(cfg.Coordinator, cfg.AgentName, tlsConf)
+	plan, err := coordinator.FetchPlan(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Storage == "" {
+		cfg.Storage = plan.Storage
+	}
+
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer mgr.Close()
+
+	keepaliveCfg := GetKeepalive(&cfg.Config)
+	keepaliveCfg.PermitWithoutStream = true
+	client, err := restore.NewRestoreClient(g, mgr.GetPDClient(), mgr.GetStorage(), mgr.GetTLSConfig(), keepaliveCfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer client.Close()
+
+	u, err := storage.ParseBackend(cfg.Storage, &cfg.BackendOptions)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	opts := storage.ExternalStorageOptions{
+		NoCredentials:   cfg.NoCreds,
+		SendCredentials: cfg.SendCreds,
+		SkipCheckPath:   cfg.SkipCheckPath,
+	}
+	if err = client.SetStorage(ctx, u, &opts); err != nil {
+		return errors.Trace(err)
+	}
+	client.SetRateLimit(cfg.RateLimit)
+	client.SetPDHTTPClient(mgr.HTTPClient())
+	client.SetConcurrency(uint(cfg.Concurrency))
+	client.SetIngestMergeThreshold(cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount)
+	sstCache, err := cfg.newSSTCache()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	client.SetSSTCache(sstCache)
+	client.SetParallelDownload(cfg.ParallelDownloadSize, cfg.ParallelDownloadParts)
+	if cfg.StagingReplicas > 0 {
+		client.EnableStagingReplicas(int(cfg.StagingReplicas))
+	}
+	// the tables are created by the coordinator, only their rewrite rules
+	// are built from the schemas.
+	client.EnableSkipCreateSQL()
+	if cfg.RewriteMap != "" {
+		rewriteMap, err := readRewriteMap(cfg.RewriteMap)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		client.SetPrebuiltRewriteMap(rewriteMap)
+	}
+	if err = client.LoadRestoreStores(ctx); err != nil {
+		return errors.Trace(err)
+	}
+
+	u, s, backupMeta, err := ReadBackupMeta(ctx, metautil.MetaFile, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = metautil.CheckMetaVersion(backupMeta); err != nil {
+		return errors.Trace(err)
+	}
+	reader := metautil.NewMetaReader(backupMeta, s)
+	if err = client.InitBackupMeta(c, backupMeta, u, s, reader); err != nil {
+		return errors.Trace(err)
+	}
+	tables := make(map[restore.TableName]*metautil.Table)
+	for _, db := range client.GetDatabases() {
+		for _, t := range db.Tables {
+			tables[restore.TableName{DB: t.DB.Name.O, Table: t.Info.Name.O}] = t
+		}
+	}
+
+	for {
+		p, ok, done, err := coordinator.Claim(ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if done {
+			log.Info("all the partitions are restored")
+			summary.SetSuccessStatus(true)
+			return nil
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return errors.Trace(ctx.Err())
+			case <-time.After(agentClaimInterval):
+			}
+			continue
+		}
+
+		restoreErr := restorePartition(ctx, g, mgr, client, cfg, cmdName, plan.NewTS, p, tables, coordinator)
+		if berrors.Is(restoreErr, berrors.ErrRestoreReclaimed) {
+			// the agent claiming it now restores it again.
+			log.Warn("the partition is reclaimed by another agent, give it up", zap.Int("partition", p.ID))
+			continue
+		}
+		if err := coordinator.Finish(ctx, p.ID, restoreErr); err != nil {
+			log.Warn("failed to report the partition to the coordinator", zap.Int("partition", p.ID), zap.Error(err))
+		}
+		if restoreErr != nil {
+			return errors.Trace(restoreErr)
+		}
+	}
+}
+
+// restorePartition splits and ingests the files of the tables of the
+// partition, heartbeating the coordinator meanwhile. It's canceled and fails
+// with ErrRestoreReclaimed once the partition is reclaimed by another agent.
+func restorePartition(
+	c context.Context,
+	g glue.Glue,
+	mgr *conn.Mgr,
+	client *restore.Client,
+	cfg *RestoreAgentConfig,
+	cmdName string,
+	newTS uint64,
+	p restore.Partition,
+	tables map[restore.TableName]*metautil.Table,
+	coordinator *restore.CoordinatorClient,
+) (err error) {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+	reclaimed := atomic.NewBool(false)
+	defer func() {
+		if reclaimed.Load() {
+			err = errors.Annotatef(berrors.ErrRestoreReclaimed, "partition %d", p.ID)
+		}
+	}()
+
+	partTables := make([]*metautil.Table, 0, len(p.Tables))
+	var files []*backuppb.File
+	for _, name := range p.Tables {
+		t, ok := tables[name]
+		if !ok {
+			return errors.Annotatef(berrors.ErrRestoreSchemaNotExists,
+				"table %s isn't in the backup", utils.EncloseDBAndTable(name.DB, name.Table))
+		}
+		partTables = append(partTables, t)
+		files = append(files, t.Files...)
+	}
+
+	go func() {
+		ticker := time.NewTicker(restore.PartitionLease / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := coordinator.Heartbeat(ctx, p.ID)
+				if berrors.Is(err, berrors.ErrRestoreReclaimed) {
+					reclaimed.Store(true)
+					cancel()
+					return
+				}
+				if err != nil {
+					log.Warn("heartbeat to the coordinator failed", zap.Int("partition", p.ID), zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	errCh := make(chan error, 32)
+	tableStream := client.GoCreateTables(ctx, mgr.GetDomain(), partTables, newTS, nil, errCh)
+	rangeStream := restore.GoValidateFileRanges(
+		ctx, tableStream, restore.MapTableToFiles(files), cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount, errCh)
+
+	updateCh, err := startProgress(ctx, g, mgr.GetStorage(), &cfg.Config, cmdName,
+		fmt.Sprintf("%s partition %d", cmdName, p.ID), int64(restore.EstimateRangeSize(files)+len(files)+len(partTables)))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer updateCh.Close()
+	sender, err := restore.NewTiKVSender(ctx, client, updateCh)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	batcher, afterRestoreStream := restore.NewBatcher(ctx, sender, restore.NewBRContextManager(client), errCh)
+	batcher.SetThreshold(utils.ClampInt(int(cfg.Concurrency), defaultRestoreConcurrency, maxRestoreBatchSizeLimit))
+	batcher.EnableAutoCommit(ctx, time.Second)
+	go restoreTableStream(ctx, rangeStream, batcher, errCh)
+
+	select {
+	case err = <-errCh:
+		return errors.Trace(multierr.Append(err, multierr.Combine(restore.Exhaust(errCh)...)))
+	case <-dropToBlackhole(ctx, afterRestoreStream, nil, errCh, updateCh):
+	}
+	select {
+	case err = <-errCh:
+		return errors.Trace(err)
+	default:
+	}
+	log.Info("partition restored", zap.Int("partition", p.ID), zap.Int("tables", len(partTables)))
+	return nil
+}
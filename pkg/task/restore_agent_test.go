@@ -0,0 +1,41 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	. "github.com/pingcap/check"
+)
+
+type testRestoreAgentSuite struct{}
+
+var _ = Suite(&testRestoreAgentSuite{})
+
+func (s *testRestoreAgentSuite) TestPlanStorage(c *C) {
+	cases := []struct {
+		storage  string
+		expected string
+	}{
+		{"s3://bucket/prefix?access-key=ak&secret-access-key=sk&endpoint=http://s3", "s3://bucket/prefix"},
+		{"gcs://bucket/prefix?credentials-file=/etc/gcs.json", "gcs://bucket/prefix"},
+		{"s3://user:password@bucket/prefix", "s3://bucket/prefix"},
+		{"local:///backup", "local:///backup"},
+		{"/backup", "/backup"},
+	}
+	for _, ca := range cases {
+		storage, err := planStorage(ca.storage)
+		c.Assert(err, IsNil)
+		c.Assert(storage, Equals, ca.expected)
+	}
+}
+
+func (s *testRestoreAgentSuite) TestListenCoordinatorRequiresTLS(c *C) {
+	cfg := &RestoreConfig{CoordinatorListen: "0.0.0.0:0"}
+	_, _, err := listenCoordinator(cfg)
+	c.Assert(err, ErrorMatches, ".*refuses to listen on the non-loopback address 0.0.0.0:0 without TLS.*")
+
+	cfg.CoordinatorListen = "127.0.0.1:0"
+	listener, serverTLS, err := listenCoordinator(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(serverTLS, IsNil)
+	c.Assert(listener.Close(), IsNil)
+}
@@ -99,9 +99,15 @@ func RunLogRestore(c context.Context, g glue.Glue, cfg *LogRestoreConfig) error
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	stopAudit, err := startAuditLog(ctx, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer stopAudit()
+
 	// Restore needs domain to do DDL.
 	needDomain := true
-	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.CheckRequirements, needDomain)
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, needDomain)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -65,9 +65,15 @@ func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreR
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	stopAudit, err := startAuditLog(ctx, &cfg.Config)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer stopAudit()
+
 	// Restore raw does not need domain.
 	needDomain := false
-	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.CheckRequirements, needDomain)
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, needDomain)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -83,7 +89,14 @@ func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreR
 	}
 	defer client.Close()
 	client.SetRateLimit(cfg.RateLimit)
+	client.SetPDHTTPClient(mgr.HTTPClient())
 	client.SetConcurrency(uint(cfg.Concurrency))
+	sstCache, err := cfg.newSSTCache()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	client.SetSSTCache(sstCache)
+	client.SetParallelDownload(cfg.ParallelDownloadSize, cfg.ParallelDownloadParts)
 	if cfg.Online {
 		client.EnableOnline()
 	}
@@ -93,6 +106,9 @@ func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreR
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = metautil.CheckMetaVersion(backupMeta); err != nil {
+		return errors.Trace(err)
+	}
 	reader := metautil.NewMetaReader(backupMeta, s)
 	if err = client.InitBackupMeta(c, backupMeta, u, s, reader); err != nil {
 		return errors.Trace(err)
@@ -116,19 +132,19 @@ func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreR
 	summary.CollectInt("restore files", len(files))
 
 	ranges, _, err := restore.MergeFileRanges(
-		files, cfg.MergeSmallRegionKeyCount, cfg.MergeSmallRegionKeyCount)
+		files, cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
 	// Redirect to log if there is no log file to avoid unreadable output.
 	// TODO: How to show progress?
-	updateCh := g.StartProgress(
+	updateCh := cfg.startProgressPrinter(
 		ctx,
+		g,
 		"Raw Restore",
 		// Split/Scatter + Download/Ingest
-		int64(len(ranges)+len(files)),
-		!cfg.LogProgress)
+		int64(len(ranges)+len(files)))
 
 	// RawKV restore does not need to rewrite keys.
 	rewrite := &restore.RewriteRules{}
@@ -137,7 +153,7 @@ func RunRestoreRaw(c context.Context, g glue.Glue, cmdName string, cfg *RestoreR
 		return errors.Trace(err)
 	}
 
-	restoreSchedulers, err := restorePreWork(ctx, client, mgr)
+	restoreSchedulers, err := restorePreWork(ctx, client, mgr, cfg.NoSchedulerPause)
 	if err != nil {
 		return errors.Trace(err)
 	}
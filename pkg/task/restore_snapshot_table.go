@@ -0,0 +1,116 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagSnapshotTS = "ts"
+	flagSnapshotAs = "as"
+)
+
+// SnapshotTableConfig is the configuration of `br restore snapshot-table`.
+type SnapshotTableConfig struct {
+	RestoreConfig
+
+	// SnapshotTS is the historical TS the table is read at.
+	SnapshotTS uint64 `json:"ts" toml:"ts"`
+	// Source is the table to read.
+	Source filter.Table `json:"table" toml:"table"`
+	// Target is the new table the snapshot is restored into.
+	Target filter.Table `json:"as" toml:"as"`
+}
+
+// DefineSnapshotTableFlags defines the flags of `br restore snapshot-table`.
+func DefineSnapshotTableFlags(command *cobra.Command) {
+	flags := command.Flags()
+	flags.String(flagSnapshotTS, "", "the historical TS to read the table at, support TSO or datetime,"+
+		" e.g. '400036290571534337', '2018-05-11 01:42:23'")
+	flags.String(flagTable, "", "the table to read, in the form of 'db.table'")
+	flags.String(flagSnapshotAs, "", "the new table the snapshot is restored into, in the form of 'db.table'")
+	_ = command.MarkFlagRequired(flagSnapshotTS)
+	_ = command.MarkFlagRequired(flagTable)
+	_ = command.MarkFlagRequired(flagSnapshotAs)
+}
+
+// ParseFromFlags parses the snapshot table config from the flag set.
+func (cfg *SnapshotTableConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	ts, err := flags.GetString(flagSnapshotTS)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.SnapshotTS, err = parseTSString(ts); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.SnapshotTS == 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument, "--%s must be specified", flagSnapshotTS)
+	}
+	source, err := flags.GetString(flagTable)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Source, err = parseQualifiedTableName(source); err != nil {
+		return errors.Annotatef(err, "invalid --%s", flagTable)
+	}
+	target, err := flags.GetString(flagSnapshotAs)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.Target, err = parseQualifiedTableName(target); err != nil {
+		return errors.Annotatef(err, "invalid --%s", flagSnapshotAs)
+	}
+	return errors.Trace(cfg.RestoreConfig.ParseFromFlags(flags))
+}
+
+// parseQualifiedTableName parses the table name in the form of 'db.table'.
+func parseQualifiedTableName(name string) (filter.Table, error) {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return filter.Table{}, errors.Annotatef(berrors.ErrInvalidArgument,
+			"table name '%s' is not in the form of 'db.table'", name)
+	}
+	return filter.Table{Schema: parts[0], Name: parts[1]}, nil
+}
+
+// RunSnapshotTableRestore restores a table as it was at a historical TS into
+// a new table. The table is read by backing it up at the TS into the storage
+// first, which must be empty, and then restored from there under the new name.
+// So the TS must not be older than the GC safe point.
+func RunSnapshotTableRestore(c context.Context, g glue.Glue, cmdName string, cfg *SnapshotTableConfig) error {
+	if strings.EqualFold(cfg.Source.Schema, cfg.Target.Schema) && strings.EqualFold(cfg.Source.Name, cfg.Target.Name) {
+		return errors.Annotate(berrors.ErrInvalidArgument, "the table cannot be restored into itself")
+	}
+	tableFilter := filter.CaseInsensitive(filter.NewTablesFilter(cfg.Source))
+	tables := map[string]struct{}{utils.EncloseDBAndTable(cfg.Source.Schema, cfg.Source.Name): {}}
+	schemas := map[string]struct{}{utils.EncloseName(cfg.Source.Schema): {}}
+
+	backupCfg := BackupConfig{Config: cfg.Config, BackupTS: cfg.SnapshotTS, IgnoreStats: true}
+	backupCfg.Concurrency = defaultBackupConcurrency
+	backupCfg.TableFilter, backupCfg.Tables, backupCfg.Schemas = tableFilter, tables, schemas
+	log.Info("backup the table at the snapshot",
+		zap.String("table", utils.EncloseDBAndTable(cfg.Source.Schema, cfg.Source.Name)),
+		zap.Uint64("ts", cfg.SnapshotTS))
+	if err := RunBackup(c, g, cmdName+" backup", &backupCfg); err != nil {
+		return errors.Trace(err)
+	}
+
+	restoreCfg := cfg.RestoreConfig
+	restoreCfg.TableFilter, restoreCfg.Tables, restoreCfg.Schemas = tableFilter, tables, schemas
+	target := cfg.Target
+	restoreCfg.RenameTo = &target
+	return errors.Trace(RunRestore(c, g, cmdName, &restoreCfg))
+}
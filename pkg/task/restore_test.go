@@ -3,8 +3,19 @@
 package task
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
 	. "github.com/pingcap/check"
+	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/parser/model"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb/statistics/handle"
+	"github.com/spf13/pflag"
 
+	"github.com/pingcap/br/pkg/metautil"
 	"github.com/pingcap/br/pkg/restore"
 )
 
@@ -20,4 +31,101 @@ func (s *testRestoreSuite) TestRestoreConfigAdjust(c *C) {
 	c.Assert(cfg.Config.SwitchModeInterval, Equals, defaultSwitchInterval)
 	c.Assert(cfg.MergeSmallRegionKeyCount, Equals, restore.DefaultMergeRegionKeyCount)
 	c.Assert(cfg.MergeSmallRegionSizeBytes, Equals, restore.DefaultMergeRegionSizeBytes)
+	c.Assert(cfg.SLOLatencyThreshold, Equals, defaultSLOLatency)
+	c.Assert(cfg.SLOProbeInterval, Equals, defaultSLOProbeInterval)
+}
+
+func (s *testRestoreSuite) TestParseSLOProbeFromFlags(c *C) {
+	parse := func(args ...string) (*RestoreConfig, error) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		DefineRestoreFlags(flags)
+		c.Assert(flags.Parse(args), IsNil)
+		cfg := &RestoreConfig{}
+		return cfg, cfg.parseSLOProbeFromFlags(flags)
+	}
+
+	cfg, err := parse("--slo-probe-sql", "select 1", "--slo-latency-threshold", "200ms", "--slo-min-concurrency", "4")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.SLOProbeSQL, Equals, "select 1")
+	c.Assert(cfg.SLOLatencyThreshold, Equals, 200*time.Millisecond)
+	c.Assert(cfg.SLOProbeInterval, Equals, defaultSLOProbeInterval)
+	c.Assert(cfg.SLOMinConcurrency, Equals, uint(4))
+
+	_, err = parse("--slo-probe-sql", "select 1", "--slo-probe-url", "http://127.0.0.1/health")
+	c.Assert(err, ErrorMatches, ".*can't be specified together.*")
+	_, err = parse("--slo-probe-url", "http://127.0.0.1/health", "--slo-probe-interval", "0s")
+	c.Assert(err, ErrorMatches, ".*must be positive.*")
+}
+
+func (s *testRestoreSuite) TestParseQualifiedTableName(c *C) {
+	table, err := parseQualifiedTableName("db.t.1")
+	c.Assert(err, IsNil)
+	c.Assert(table, Equals, filter.Table{Schema: "db", Name: "t.1"})
+
+	for _, name := range []string{"", "t", ".t", "db."} {
+		_, err = parseQualifiedTableName(name)
+		c.Assert(err, ErrorMatches, ".*is not in the form of 'db.table'.*")
+	}
+}
+
+func (s *testRestoreSuite) TestRenameRestoreTable(c *C) {
+	dbInfo := &model.DBInfo{ID: 1, Name: model.NewCIStr("db")}
+	tableInfo := &model.TableInfo{ID: 2, Name: model.NewCIStr("t")}
+	files := []*backuppb.File{{Name: "1_2_write.sst"}}
+	table := &metautil.Table{
+		DB:    dbInfo,
+		Info:  tableInfo,
+		Files: files,
+		Stats: &handle.JSONTable{DatabaseName: "db", TableName: "t"},
+	}
+
+	tables, dbs, err := renameRestoreTable([]*metautil.Table{table}, filter.Table{Schema: "db2", Name: "t_restored"})
+	c.Assert(err, IsNil)
+	c.Assert(tables, HasLen, 1)
+	c.Assert(tables[0].DB.Name.O, Equals, "db2")
+	c.Assert(tables[0].Info.Name.O, Equals, "t_restored")
+	c.Assert(tables[0].Info.ID, Equals, int64(2))
+	c.Assert(tables[0].Files, DeepEquals, files)
+	c.Assert(tables[0].Stats.DatabaseName, Equals, "db2")
+	c.Assert(tables[0].Stats.TableName, Equals, "t_restored")
+	c.Assert(dbs, HasLen, 1)
+	c.Assert(dbs[0].Info, Equals, tables[0].DB)
+	c.Assert(dbs[0].Tables, DeepEquals, tables)
+	// the schema in the backup is not changed.
+	c.Assert(dbInfo.Name.O, Equals, "db")
+	c.Assert(tableInfo.Name.O, Equals, "t")
+	c.Assert(table.Stats.TableName, Equals, "t")
+
+	_, _, err = renameRestoreTable([]*metautil.Table{table, table}, filter.Table{Schema: "db", Name: "t2"})
+	c.Assert(err, ErrorMatches, "exactly one table can be renamed, but 2 tables are matched.*")
+}
+
+func (s *testRestoreSuite) TestWriteCDCStartPoint(c *C) {
+	backupMeta := &backuppb.BackupMeta{EndVersion: 42, ClusterId: 1, ClusterVersion: `"v5.2.0"`}
+	tables := []*metautil.Table{{
+		DB:   &model.DBInfo{Name: model.NewCIStr("db")},
+		Info: &model.TableInfo{Name: model.NewCIStr("t")},
+	}}
+	info := &metautil.ClusterInfo{
+		BackupTS:      42,
+		SchemaVersion: 10,
+		RecentDDLs:    []*metautil.DDLRecord{{JobID: 3, Query: "create table t (a int)", SchemaVersion: 10}},
+	}
+
+	path := filepath.Join(c.MkDir(), "cdc.json")
+	c.Assert(writeCDCStartPoint(path, newCDCStartPoint(backupMeta, info, tables)), IsNil)
+	data, err := os.ReadFile(path)
+	c.Assert(err, IsNil)
+	startPoint := &cdcStartPoint{}
+	c.Assert(json.Unmarshal(data, startPoint), IsNil)
+	c.Assert(startPoint.StartTS, Equals, uint64(42))
+	c.Assert(startPoint.ClusterID, Equals, uint64(1))
+	c.Assert(startPoint.SchemaVersion, Equals, int64(10))
+	c.Assert(startPoint.RecentDDLs, DeepEquals, info.RecentDDLs)
+	c.Assert(startPoint.Tables, DeepEquals, []string{"`db`.`t`"})
+
+	// the backups taken by the older BR only have the backup point.
+	startPoint = newCDCStartPoint(backupMeta, nil, tables)
+	c.Assert(startPoint.StartTS, Equals, uint64(42))
+	c.Assert(startPoint.SchemaVersion, Equals, int64(0))
 }
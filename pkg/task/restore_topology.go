@@ -0,0 +1,130 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/go-units"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/conn"
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+const (
+	// downsizeWarnRatio is how many times fewer stores the target cluster has
+	// than the backed up one to be warned.
+	downsizeWarnRatio = 4
+	// minAdaptedConcurrency is the min concurrency lowered for the smaller
+	// target cluster.
+	minAdaptedConcurrency = 16
+	// defaultMaxReplicas is the replicas assumed if PD doesn't tell.
+	defaultMaxReplicas = 3
+)
+
+// topologyPlan is the concurrency and the merge thresholds of the restore
+// adjusted by the topologies, and the warnings about them.
+type topologyPlan struct {
+	Concurrency   uint32
+	MergeSize     uint64
+	MergeKeyCount uint64
+	Warnings      []string
+}
+
+// planByTopology adjusts the concurrency and the merge thresholds left at the
+// defaults. The concurrency is lowered by the ratio of the stores if the
+// target cluster has fewer stores than the backed up one, and the merge
+// thresholds follow the region split settings of the target cluster, since
+// the defaults are the ones of TiKV. Either topology may be nil if unknown.
+func planByTopology(
+	backup, target *metautil.Topology, archiveSize uint64, cfg *RestoreConfig,
+) topologyPlan {
+	plan := topologyPlan{
+		Concurrency:   cfg.Concurrency,
+		MergeSize:     cfg.MergeSmallRegionSizeBytes,
+		MergeKeyCount: cfg.MergeSmallRegionKeyCount,
+	}
+	if target == nil || len(target.Stores) == 0 {
+		return plan
+	}
+	if backup != nil && len(backup.Stores) > len(target.Stores) {
+		backupStores, targetStores := len(backup.Stores), len(target.Stores)
+		if targetStores*downsizeWarnRatio <= backupStores {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+				"the backup of a cluster of %d stores is restored into a cluster of %d stores, "+
+					"the restore may be slow and the stores may run out of space", backupStores, targetStores))
+		}
+		if cfg.Concurrency == defaultRestoreConcurrency {
+			concurrency := uint32(int(cfg.Concurrency) * targetStores / backupStores)
+			if concurrency < minAdaptedConcurrency {
+				concurrency = minAdaptedConcurrency
+			}
+			plan.Concurrency = concurrency
+		}
+	}
+	if capacity := target.TotalCapacity(); capacity > 0 {
+		replicas := target.MaxReplicas
+		if replicas == 0 {
+			replicas = defaultMaxReplicas
+		}
+		// the backup files are compressed, so the data takes more.
+		if archiveSize*replicas > capacity {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+				"the backup of %s with %d replicas is larger than the capacity %s of the target cluster",
+				units.BytesSize(float64(archiveSize)), replicas, units.BytesSize(float64(capacity))))
+		}
+	}
+	if cfg.MergeSmallRegionSizeBytes == restore.DefaultMergeRegionSizeBytes &&
+		cfg.MergeSmallRegionKeyCount == restore.DefaultMergeRegionKeyCount {
+		if target.RegionSplitSize > 0 {
+			plan.MergeSize = target.RegionSplitSize
+		}
+		if target.RegionSplitKeys > 0 {
+			plan.MergeKeyCount = target.RegionSplitKeys
+		}
+	}
+	return plan
+}
+
+// adaptToTopology plans the restore by the topologies and applies the plan to
+// the client. The restore isn't adapted if the topology of the target cluster
+// can't be got.
+func adaptToTopology(
+	ctx context.Context,
+	mgr *conn.Mgr,
+	client *restore.Client,
+	cfg *RestoreConfig,
+	clusterInfo *metautil.ClusterInfo,
+	archiveSize uint64,
+) {
+	target, err := mgr.GetTopology(ctx)
+	if err != nil {
+		log.Warn("failed to get the topology of the cluster, the restore isn't adapted to it", zap.Error(err))
+		return
+	}
+	var backup *metautil.Topology
+	if clusterInfo != nil {
+		backup = clusterInfo.Topology
+	}
+	plan := planByTopology(backup, target, archiveSize, cfg)
+	for _, warning := range plan.Warnings {
+		logutil.WarnTerm(warning)
+	}
+	if plan.Concurrency != cfg.Concurrency {
+		log.Info("adjust the concurrency by the stores",
+			zap.Uint32("from", cfg.Concurrency), zap.Uint32("to", plan.Concurrency))
+		cfg.Concurrency = plan.Concurrency
+		client.SetConcurrency(uint(cfg.Concurrency))
+	}
+	if plan.MergeSize != cfg.MergeSmallRegionSizeBytes || plan.MergeKeyCount != cfg.MergeSmallRegionKeyCount {
+		log.Info("adjust the merge thresholds by the region split settings",
+			zap.Uint64("size", plan.MergeSize), zap.Uint64("keys", plan.MergeKeyCount))
+		cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount = plan.MergeSize, plan.MergeKeyCount
+		client.SetIngestMergeThreshold(cfg.MergeSmallRegionSizeBytes, cfg.MergeSmallRegionKeyCount)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"github.com/docker/go-units"
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+type testTopologySuite struct{}
+
+var _ = Suite(&testTopologySuite{})
+
+func topologyOf(stores int, capacity uint64) *metautil.Topology {
+	t := &metautil.Topology{MaxReplicas: 3}
+	for i := 0; i < stores; i++ {
+		t.Stores = append(t.Stores, &metautil.StoreTopology{ID: uint64(i + 1), Capacity: capacity})
+	}
+	return t
+}
+
+func (s *testTopologySuite) TestPlanByTopology(c *C) {
+	cfg := &RestoreConfig{}
+	cfg.adjustRestoreConfig()
+
+	// the same topology changes nothing.
+	plan := planByTopology(topologyOf(3, units.TiB), topologyOf(3, units.TiB), units.GiB, cfg)
+	c.Assert(plan.Concurrency, Equals, uint32(defaultRestoreConcurrency))
+	c.Assert(plan.MergeSize, Equals, uint64(restore.DefaultMergeRegionSizeBytes))
+	c.Assert(plan.Warnings, HasLen, 0)
+
+	// a 30-store backup restored into 3 stores.
+	plan = planByTopology(topologyOf(30, units.TiB), topologyOf(3, 100*units.GiB), 200*units.GiB, cfg)
+	c.Assert(plan.Concurrency, Equals, uint32(minAdaptedConcurrency))
+	c.Assert(plan.Warnings, HasLen, 2)
+	c.Assert(plan.Warnings[0], Matches, ".*30 stores is restored into a cluster of 3 stores.*")
+	c.Assert(plan.Warnings[1], Matches, ".*larger than the capacity.*")
+
+	plan = planByTopology(topologyOf(4, 0), topologyOf(2, 0), units.GiB, cfg)
+	c.Assert(plan.Concurrency, Equals, uint32(defaultRestoreConcurrency/2))
+	c.Assert(plan.Warnings, HasLen, 0)
+
+	// the region split settings of the target cluster.
+	target := topologyOf(3, 0)
+	target.RegionSplitSize = 256 * units.MiB
+	target.RegionSplitKeys = 2560000
+	plan = planByTopology(nil, target, units.GiB, cfg)
+	c.Assert(plan.MergeSize, Equals, uint64(256*units.MiB))
+	c.Assert(plan.MergeKeyCount, Equals, uint64(2560000))
+
+	// the values set explicitly are kept.
+	cfg.Concurrency = 64
+	cfg.MergeSmallRegionSizeBytes = 32 * units.MiB
+	plan = planByTopology(topologyOf(30, 0), target, units.GiB, cfg)
+	c.Assert(plan.Concurrency, Equals, uint32(64))
+	c.Assert(plan.MergeSize, Equals, uint64(32*units.MiB))
+	c.Assert(plan.MergeKeyCount, Equals, cfg.MergeSmallRegionKeyCount)
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/util"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// SpaceReportConfig is the configuration of `br debug space-report`.
+type SpaceReportConfig struct {
+	Config
+
+	// RewriteMap is the rewrite map written by a restore, whose restored
+	// tables are reported instead of those matched by the table filter.
+	RewriteMap string `json:"rewrite-map" toml:"rewrite-map"`
+}
+
+// spaceReportTable is a table to report by its physical IDs.
+type spaceReportTable struct {
+	name        string
+	physicalIDs []int64
+}
+
+// DefineSpaceReportFlags defines the flags of `br debug space-report`.
+func DefineSpaceReportFlags(flags *pflag.FlagSet) {
+	flags.String(flagRewriteMap, "",
+		"the rewrite map written by --"+flagRewriteMapOutput+" of a restore, "+
+			"the restored tables in it are reported instead of those matched by the table filter")
+}
+
+// ParseFromFlags parses the space report config from the flag set.
+func (cfg *SpaceReportConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.RewriteMap, err = flags.GetString(flagRewriteMap); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(cfg.Config.ParseFromFlags(flags))
+}
+
+// RunSpaceReport reports the regions of the tables by PD, that is the region
+// count, the approximate size and where the replicas are of each table, and
+// writes the report to w in JSON. It verifies where a restore or an import
+// landed, and how many regions are left to be merged after it.
+func RunSpaceReport(c context.Context, g glue.Glue, cfg *SpaceReportConfig, w io.Writer) error {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	// the domain is only needed to find the tables by the filter.
+	needDomain := cfg.RewriteMap == ""
+	mgr, err := NewMgr(ctx, g, cfg.PD, cfg.TLS, GetKeepalive(&cfg.Config), cfg.GRPC, cfg.CheckRequirements, needDomain)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer mgr.Close()
+
+	var tables []spaceReportTable
+	if cfg.RewriteMap != "" {
+		rewriteMap, err := readRewriteMap(cfg.RewriteMap)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		tables = spaceReportTablesFromRewriteMap(rewriteMap)
+	} else {
+		tables = spaceReportTablesFromSchemas(mgr.GetDomain().InfoSchema(), cfg.TableFilter)
+	}
+
+	addresses := make(map[uint64]string)
+	if stores, err := mgr.HTTPClient().GetStores(ctx); err == nil {
+		for _, store := range stores.Stores {
+			addresses[store.Store.GetId()] = store.Store.GetAddress()
+		}
+	} else {
+		log.Warn("failed to get the stores, the addresses are omitted", zap.Error(err))
+	}
+
+	mergeCfg := getRegionMergeConfig(ctx, mgr)
+	report := make([]*restore.TableSpace, 0, len(tables))
+	for _, table := range tables {
+		space, err := restore.ReportTableSpace(ctx, mgr.HTTPClient(), table.name, table.physicalIDs, mergeCfg)
+		if err != nil {
+			return errors.Annotatef(err, "failed to report the space of %s", table.name)
+		}
+		for _, store := range space.Stores {
+			store.Address = addresses[store.StoreID]
+		}
+		report = append(report, space)
+	}
+	log.Info("reported the space of the tables", zap.Int("tables", len(report)))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Trace(encoder.Encode(report))
+}
+
+func spaceReportTablesFromRewriteMap(rewriteMap *restore.RewriteMap) []spaceReportTable {
+	rewrites := rewriteMap.Tables()
+	tables := make([]spaceReportTable, 0, len(rewrites))
+	for _, rewrite := range rewrites {
+		ids := []int64{rewrite.NewTableID}
+		for _, partitionID := range rewrite.Partitions {
+			ids = append(ids, partitionID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		tables = append(tables, spaceReportTable{
+			name:        utils.EncloseDBAndTable(rewrite.DB, rewrite.Table),
+			physicalIDs: ids,
+		})
+	}
+	return tables
+}
+
+func spaceReportTablesFromSchemas(is infoschema.InfoSchema, tableFilter filter.Filter) []spaceReportTable {
+	var tables []spaceReportTable
+	for _, db := range is.AllSchemas() {
+		if util.IsMemDB(db.Name.L) {
+			continue
+		}
+		for _, tbl := range is.SchemaTables(db.Name) {
+			table := tbl.Meta()
+			if table.IsView() || table.IsSequence() || !tableFilter.MatchTable(db.Name.O, table.Name.O) {
+				continue
+			}
+			ids := []int64{table.ID}
+			if partitions := table.GetPartitionInfo(); partitions != nil {
+				for _, def := range partitions.Definitions {
+					ids = append(ids, def.ID)
+				}
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			tables = append(tables, spaceReportTable{
+				name:        utils.EncloseDBAndTable(db.Name.O, table.Name.O),
+				physicalIDs: ids,
+			})
+		}
+	}
+	return tables
+}
@@ -0,0 +1,287 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+const (
+	flagBenchFileSize    = "file-size"
+	flagBenchFileCount   = "file-count"
+	flagBenchConcurrency = "bench-concurrency"
+	flagBenchChunkSize   = "chunk-size"
+
+	defaultBenchFileSize    = 64 * units.MiB
+	defaultBenchFileCount   = 16
+	defaultBenchConcurrency = 4
+	defaultBenchChunkSize   = units.MiB
+
+	storageBenchDir = "storage-bench"
+)
+
+// StorageBenchConfig is the configuration of `br debug storage-bench`.
+type StorageBenchConfig struct {
+	Config
+
+	FileSize         uint64 `json:"file-size" toml:"file-size"`
+	FileCount        uint   `json:"file-count" toml:"file-count"`
+	BenchConcurrency uint   `json:"bench-concurrency" toml:"bench-concurrency"`
+	ChunkSize        uint64 `json:"chunk-size" toml:"chunk-size"`
+}
+
+// DefineStorageBenchFlags defines the flags of `br debug storage-bench`.
+func DefineStorageBenchFlags(flags *pflag.FlagSet) {
+	flags.Uint64(flagBenchFileSize, defaultBenchFileSize, "the size in bytes of each synthetic file")
+	flags.Uint(flagBenchFileCount, defaultBenchFileCount, "the number of synthetic files")
+	flags.Uint(flagBenchConcurrency, defaultBenchConcurrency, "the number of files written or read concurrently")
+	flags.Uint64(flagBenchChunkSize, defaultBenchChunkSize, "the size in bytes of each write call")
+}
+
+// ParseFromFlags parses the storage bench config from the flag set.
+func (cfg *StorageBenchConfig) ParseFromFlags(flags *pflag.FlagSet) error {
+	var err error
+	if cfg.FileSize, err = flags.GetUint64(flagBenchFileSize); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.FileCount, err = flags.GetUint(flagBenchFileCount); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.BenchConcurrency, err = flags.GetUint(flagBenchConcurrency); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.ChunkSize, err = flags.GetUint64(flagBenchChunkSize); err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.FileCount == 0 || cfg.BenchConcurrency == 0 || cfg.ChunkSize == 0 {
+		return errors.Annotatef(berrors.ErrInvalidArgument,
+			"--%s, --%s and --%s must be positive", flagBenchFileCount, flagBenchConcurrency, flagBenchChunkSize)
+	}
+	return errors.Trace(cfg.Config.ParseFromFlags(flags))
+}
+
+// LatencyStats is the statistics of a set of latencies.
+type LatencyStats struct {
+	Count int
+	Min   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// NewLatencyStats computes the percentiles of the latencies.
+func NewLatencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		idx := int(p*float64(len(sorted))+0.5) - 1
+		return sorted[utils.ClampInt(idx, 0, len(sorted)-1)]
+	}
+	return LatencyStats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		P50:   percentile(0.50),
+		P90:   percentile(0.90),
+		P99:   percentile(0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// StorageBenchPhase is the result of a phase (write or read) of the storage
+// benchmark.
+type StorageBenchPhase struct {
+	Name       string
+	TotalBytes uint64
+	Elapsed    time.Duration
+	// FileLatency is the latency of writing or reading a whole file.
+	FileLatency LatencyStats
+	// FirstByteLatency is the latency of creating the writer or opening the
+	// reader.
+	FirstByteLatency LatencyStats
+}
+
+// Throughput returns the bytes per second of the phase.
+func (p *StorageBenchPhase) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.TotalBytes) / p.Elapsed.Seconds()
+}
+
+// String implements fmt.Stringer.
+func (p *StorageBenchPhase) String() string {
+	return fmt.Sprintf("%s: %d files, %s in %s, throughput %s/s\n"+
+		"  file latency:  min %s, p50 %s, p90 %s, p99 %s, max %s\n"+
+		"  first latency: min %s, p50 %s, p90 %s, p99 %s, max %s",
+		p.Name, p.FileLatency.Count, units.HumanSize(float64(p.TotalBytes)), p.Elapsed,
+		units.HumanSize(p.Throughput()),
+		p.FileLatency.Min, p.FileLatency.P50, p.FileLatency.P90, p.FileLatency.P99, p.FileLatency.Max,
+		p.FirstByteLatency.Min, p.FirstByteLatency.P50, p.FirstByteLatency.P90,
+		p.FirstByteLatency.P99, p.FirstByteLatency.Max)
+}
+
+// RunStorageBench writes and then reads synthetic files through the external
+// storage, and returns the statistics of both phases. It helps users to tell
+// whether the storage is the bottleneck of backup and restore.
+func RunStorageBench(ctx context.Context, cfg *StorageBenchConfig) ([]*StorageBenchPhase, error) {
+	_, s, err := GetStorage(ctx, &cfg.Config)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	dir := path.Join(storageBenchDir, time.Now().Format("20060102150405"))
+	names := make([]string, 0, cfg.FileCount)
+	for i := uint(0); i < cfg.FileCount; i++ {
+		names = append(names, path.Join(dir, fmt.Sprintf("file-%d", i)))
+	}
+	log.Info("start storage bench",
+		zap.String("storage", s.URI()),
+		zap.String("dir", dir),
+		zap.Uint64("file-size", cfg.FileSize),
+		zap.Uint("file-count", cfg.FileCount),
+		zap.Uint("concurrency", cfg.BenchConcurrency))
+
+	chunk := make([]byte, cfg.ChunkSize)
+	// random data, so that compression of the storage won't affect the result.
+	_, _ = rand.New(rand.NewSource(time.Now().UnixNano())).Read(chunk)
+
+	write, err := runStorageBenchPhase(ctx, "write", names, cfg.BenchConcurrency,
+		func(ctx context.Context, name string) (time.Duration, uint64, error) {
+			return benchWriteFile(ctx, s, name, chunk, cfg.FileSize)
+		})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	read, err := runStorageBenchPhase(ctx, "read", names, cfg.BenchConcurrency,
+		func(ctx context.Context, name string) (time.Duration, uint64, error) {
+			return benchReadFile(ctx, s, name, cfg.ChunkSize, cfg.FileSize)
+		})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	log.Info("storage bench finished, the synthetic files are left in the storage",
+		zap.String("dir", dir))
+	return []*StorageBenchPhase{write, read}, nil
+}
+
+type benchFileFunc func(ctx context.Context, name string) (firstByte time.Duration, size uint64, err error)
+
+func runStorageBenchPhase(
+	ctx context.Context,
+	phase string,
+	names []string,
+	concurrency uint,
+	fn benchFileFunc,
+) (*StorageBenchPhase, error) {
+	var (
+		mu          sync.Mutex
+		fileLats    = make([]time.Duration, 0, len(names))
+		firstLats   = make([]time.Duration, 0, len(names))
+		totalBytes  uint64
+		pool        = utils.NewWorkerPool(concurrency, "storage bench "+phase)
+		eg, ectx    = errgroup.WithContext(ctx)
+		phaseBegins = time.Now()
+	)
+	for _, name := range names {
+		name := name
+		pool.ApplyOnErrorGroup(eg, func() error {
+			begin := time.Now()
+			firstByte, size, err := fn(ectx, name)
+			if err != nil {
+				return errors.Annotatef(err, "failed to %s %s", phase, name)
+			}
+			elapsed := time.Since(begin)
+			mu.Lock()
+			fileLats = append(fileLats, elapsed)
+			firstLats = append(firstLats, firstByte)
+			totalBytes += size
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &StorageBenchPhase{
+		Name:             phase,
+		TotalBytes:       totalBytes,
+		Elapsed:          time.Since(phaseBegins),
+		FileLatency:      NewLatencyStats(fileLats),
+		FirstByteLatency: NewLatencyStats(firstLats),
+	}, nil
+}
+
+func benchWriteFile(
+	ctx context.Context,
+	s storage.ExternalStorage,
+	name string,
+	chunk []byte,
+	size uint64,
+) (time.Duration, uint64, error) {
+	begin := time.Now()
+	w, err := s.Create(ctx, name)
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	firstByte := time.Since(begin)
+	var written uint64
+	for written < size {
+		data := chunk
+		if remain := size - written; remain < uint64(len(data)) {
+			data = data[:remain]
+		}
+		n, err := w.Write(ctx, data)
+		if err != nil {
+			_ = w.Close(ctx)
+			return 0, 0, errors.Trace(err)
+		}
+		written += uint64(n)
+	}
+	return firstByte, written, errors.Trace(w.Close(ctx))
+}
+
+func benchReadFile(
+	ctx context.Context,
+	s storage.ExternalStorage,
+	name string,
+	chunkSize uint64,
+	size uint64,
+) (time.Duration, uint64, error) {
+	begin := time.Now()
+	r, err := s.Open(ctx, name)
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	defer r.Close()
+	firstByte := time.Since(begin)
+	n, err := io.CopyBuffer(io.Discard, r, make([]byte, chunkSize))
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if uint64(n) != size {
+		return 0, 0, errors.Annotatef(berrors.ErrStorageUnknown,
+			"read %d bytes from %s, but %d bytes were written", n, name, size)
+	}
+	return firstByte, uint64(n), nil
+}
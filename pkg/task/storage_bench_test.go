@@ -0,0 +1,53 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+type testStorageBenchSuite struct{}
+
+var _ = Suite(&testStorageBenchSuite{})
+
+func (s *testStorageBenchSuite) TestLatencyStats(c *C) {
+	c.Assert(NewLatencyStats(nil), DeepEquals, LatencyStats{})
+
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	stats := NewLatencyStats(latencies)
+	c.Assert(stats, DeepEquals, LatencyStats{
+		Count: 100,
+		Min:   time.Millisecond,
+		P50:   50 * time.Millisecond,
+		P90:   90 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+		Max:   100 * time.Millisecond,
+	})
+	// the input should not be reordered.
+	c.Assert(latencies[0], Equals, 100*time.Millisecond)
+}
+
+func (s *testStorageBenchSuite) TestRunStorageBench(c *C) {
+	cfg := &StorageBenchConfig{
+		Config:           Config{Storage: "local://" + c.MkDir()},
+		FileSize:         1000,
+		FileCount:        5,
+		BenchConcurrency: 2,
+		ChunkSize:        64,
+	}
+	phases, err := RunStorageBench(context.Background(), cfg)
+	c.Assert(err, IsNil)
+	c.Assert(phases, HasLen, 2)
+	for _, phase := range phases {
+		c.Assert(phase.TotalBytes, Equals, uint64(5000))
+		c.Assert(phase.FileLatency.Count, Equals, 5)
+	}
+	c.Assert(phases[0].Name, Equals, "write")
+	c.Assert(phases[1].Name, Equals, "read")
+}
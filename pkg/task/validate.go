@@ -0,0 +1,133 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"fmt"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/sessionctx/variable"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/validation"
+)
+
+// Validate reports the problems of the combinations of the common flags.
+func (cfg *Config) Validate(v *validation.Validator) {
+	cfg.BackendOptions.Validate(cfg.Storage, v)
+
+	switch {
+	case cfg.TLS.Cert != "" && cfg.TLS.Key == "":
+		v.Error([]string{"--" + flagCert}, "the certificate is set without the private key",
+			"set --"+flagKey+" as well")
+	case cfg.TLS.Cert == "" && cfg.TLS.Key != "":
+		v.Error([]string{"--" + flagKey}, "the private key is set without the certificate",
+			"set --"+flagCert+" as well")
+	}
+	if cfg.TLS.CA == "" && (cfg.TLS.Cert != "" || cfg.TLS.Key != "") {
+		v.Warn([]string{"--" + flagCert, "--" + flagKey}, "TLS is disabled without the CA, they are ignored",
+			"set --"+flagCA+" to connect the cluster by TLS")
+	}
+	if !cfg.Checksum && cfg.ChecksumConcurrency != 0 && cfg.ChecksumConcurrency != variable.DefChecksumTableConcurrency {
+		v.Warn([]string{"--" + flagChecksum + "=false", "--" + flagChecksumConcurrency},
+			"the checksum is skipped, so its concurrency is ignored",
+			"remove --"+flagChecksumConcurrency+", or enable --"+flagChecksum)
+	}
+}
+
+// Validate reports the problems of the combinations of the backup flags.
+func (cfg *BackupConfig) Validate(v *validation.Validator) {
+	cfg.Config.Validate(v)
+
+	if cfg.RateLimit != unlimited && cfg.Config.Concurrency != 0 {
+		// TiKV limits the upload rate by each backup request, so the requests
+		// are sent sequentially to keep the rate limit.
+		v.Warn([]string{"--" + flagRateLimit, "--" + flagConcurrency},
+			fmt.Sprintf("--%s forces sequential (i.e. concurrency = 1) backup, the concurrency %d is ignored",
+				flagRateLimit, cfg.Config.Concurrency),
+			"remove --"+flagConcurrency)
+	}
+	if cfg.BackupTS != 0 && cfg.TimeAgo != 0 {
+		v.Warn([]string{"--" + flagBackupTS, "--" + flagBackupTimeago},
+			"the backup is at --"+flagBackupTS+", --"+flagBackupTimeago+" is ignored",
+			"remove one of them")
+	}
+	if cfg.LastBackupTS != 0 && cfg.BackupTS != 0 && cfg.BackupTS <= cfg.LastBackupTS {
+		v.Error([]string{"--" + flagLastBackupTS, "--" + flagBackupTS},
+			"the last backup TS isn't before the backup TS, the incremental backup would be empty",
+			"check whether the TSes are swapped")
+	}
+	if cfg.SchemaOnly && cfg.LastBackupTS != 0 {
+		v.Error([]string{"--" + FlagSchemaOnly, "--" + flagLastBackupTS},
+			"the schema-only backup has no data, so it can't be incremental",
+			"remove --"+flagLastBackupTS)
+	}
+	if cfg.ColdStorageClass != "" && cfg.ColdAfter == 0 {
+		v.Warn([]string{"--" + flagColdStorageClass},
+			"no tables are cold without --"+flagColdAfter+", the storage class is ignored",
+			"set --"+flagColdAfter)
+	}
+}
+
+// Validate reports the problems of the combinations of the restore flags.
+func (cfg *RestoreConfig) Validate(v *validation.Validator) {
+	cfg.Config.Validate(v)
+
+	if cfg.AllowOverlap && !cfg.CheckOverlap {
+		v.Warn([]string{"--" + flagAllowOverlap}, "the overlaps aren't checked, so they're never reported",
+			"set --"+flagCheckOverlap+" as well")
+	}
+	hasProbe := cfg.SLOProbeSQL != "" || cfg.SLOProbeURL != ""
+	if hasProbe && cfg.Config.Concurrency != 0 && uint(cfg.Config.Concurrency) < cfg.SLOMinConcurrency {
+		v.Warn([]string{"--" + flagSLOMinConcurrency, "--" + flagConcurrency},
+			"the minimal concurrency is above the concurrency, the concurrency is never lowered",
+			"lower --"+flagSLOMinConcurrency)
+	}
+	if cfg.ParallelDownloadSize > 0 && cfg.ParallelDownloadParts <= 1 {
+		v.Warn([]string{"--" + flagParallelDownloadParts}, "a part is downloaded at a time, the files are never "+
+			"downloaded in parallel", "set --"+flagParallelDownloadParts+" above 1")
+	}
+	if cfg.StagingReplicas > 0 && cfg.Online {
+		v.Error([]string{"--" + flagStagingReplicas, "--" + flagOnline},
+			"the placement rules of the staging replicas conflict with the online restore", "restore offline")
+	}
+	if cfg.StagingReplicas > 0 && cfg.StagingTimeout <= 0 {
+		v.Error([]string{"--" + flagStagingTimeout}, "the restore never waits for the other replicas",
+			"set --"+flagStagingTimeout+" above 0")
+	}
+	if cfg.CoordinatorListen != "" {
+		if cfg.RenameTo != nil {
+			v.Error([]string{"--" + flagCoordinator}, "the agents can't restore the renamed table",
+				"restore the table without the agents")
+		}
+		if cfg.Partitions == 0 {
+			v.Error([]string{"--" + flagPartitions}, "the tables must be split into some partitions",
+				"set a positive --"+flagPartitions)
+		}
+	}
+}
+
+// validateTask validates the config of a task before it runs, warns about the
+// problems ignored and fails with all the problems failing the validation.
+// It returns done when the task should exit after the validation, that is on
+// failure or --validate-only.
+func validateTask(cmdName string, cfg interface {
+	Validate(v *validation.Validator)
+}, validateOnly bool) (done bool, err error) {
+	v := &validation.Validator{}
+	cfg.Validate(v)
+	warnings := v.Warnings()
+	for i := range warnings {
+		logutil.WarnTerm("problem in the config", zap.Stringer("problem", &warnings[i]))
+	}
+	if err := v.Err(); err != nil {
+		return true, err
+	}
+	if validateOnly {
+		log.Info("the config is valid", zap.String("cmd", cmdName), zap.Int("warnings", len(warnings)))
+		fmt.Printf("the config of %s is valid with %d warnings\n", cmdName, len(warnings))
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/validation"
+)
+
+type testValidateSuite struct{}
+
+var _ = Suite(&testValidateSuite{})
+
+func (s *testValidateSuite) TestValidateBackupConfig(c *C) {
+	cfg := &BackupConfig{Config: Config{Storage: "local:///tmp/backup", Checksum: true}}
+	v := &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Problems(), HasLen, 0)
+
+	cfg.RateLimit = 10
+	cfg.Config.Concurrency = 8
+	cfg.BackupTS = 100
+	cfg.TimeAgo = time.Minute
+	cfg.TLS.Cert = "/tmp/cert.pem"
+	v = &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Warnings(), HasLen, 3)
+	c.Assert(v.Problems(), HasLen, 4)
+	c.Assert(v.Err(), ErrorMatches, "(?s)found 4 problems in the config.*--cert: the certificate is set without the private key.*")
+
+	cfg.TLS.Cert = ""
+	cfg.LastBackupTS = 100
+	v = &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Err(), ErrorMatches, "(?s).*--lastbackupts, --backupts: the last backup TS isn't before the backup TS.*")
+
+	cfg = &BackupConfig{Config: Config{Storage: "s3://bucket/backup", Checksum: true}}
+	cfg.ColdStorageClass = "STANDARD_IA"
+	v = &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Warnings(), HasLen, 1)
+	cfg.ColdAfter = 30 * 24 * time.Hour
+	v = &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Problems(), HasLen, 0)
+
+	cfg.SchemaOnly = true
+	cfg.LastBackupTS = 100
+	v = &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Err(), ErrorMatches, "(?s).*--schema-only, --lastbackupts: the schema-only backup has no data.*")
+}
+
+func (s *testValidateSuite) TestValidateRestoreConfig(c *C) {
+	cfg := &RestoreConfig{Config: Config{Storage: "local:///tmp/backup", Checksum: true}}
+	cfg.AllowOverlap = true
+	cfg.SLOProbeSQL = "SELECT 1"
+	cfg.SLOMinConcurrency = 16
+	cfg.Config.Concurrency = 8
+	v := &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Err(), IsNil)
+	c.Assert(v.Warnings(), HasLen, 2)
+	c.Assert(v.Warnings()[0].Items, DeepEquals, []string{"--allow-overlap"})
+	c.Assert(v.Warnings()[1].Items, DeepEquals, []string{"--slo-min-concurrency", "--concurrency"})
+
+	done, err := validateTask("Restore", cfg, true)
+	c.Assert(done, IsTrue)
+	c.Assert(err, IsNil)
+	done, err = validateTask("Restore", cfg, false)
+	c.Assert(done, IsFalse)
+	c.Assert(err, IsNil)
+
+	cfg.StagingReplicas = 1
+	cfg.Online = true
+	v = &validation.Validator{}
+	cfg.Validate(v)
+	c.Assert(v.Err(), ErrorMatches, "(?s).*--staging-replicas, --online: the placement rules of the staging replicas.*")
+	c.Assert(v.Err(), ErrorMatches, "(?s).*--staging-replicas-timeout: the restore never waits for the other replicas.*")
+}
@@ -0,0 +1,298 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+const (
+	storageRetryTimes      = 8
+	storageWaitInterval    = 500 * time.Millisecond
+	storageMaxWaitInterval = 30 * time.Second
+	// storageMaxRetryAfter caps the delay asked by the server, so a broken
+	// header can't hang the task.
+	storageMaxRetryAfter = 5 * time.Minute
+
+	// tikvServerBusyWaitInterval is the least delay after TiKV reports server
+	// busy, which needs longer to recover than the other retryable errors.
+	tikvServerBusyWaitInterval = 500 * time.Millisecond
+
+	ddlRetryTimes      = 5
+	ddlWaitInterval    = 500 * time.Millisecond
+	ddlMaxWaitInterval = 5 * time.Second
+)
+
+var (
+	throttlingMessages = []string{
+		"slowdown",
+		"slow down",
+		"throttl",
+		"toomanyrequests",
+		"too many requests",
+		"requestlimitexceeded",
+		"service unavailable",
+	}
+
+	retryAfterPattern = regexp.MustCompile(`(?i)retry-after\D{0,3}(\d+)`)
+
+	// retryableDDLPattern matches the errors of TiDB failing a DDL by the change
+	// of the DDL owner or the schema: not the DDL owner, information schema is
+	// out of date and information schema is changed.
+	retryableDDLPattern = regexp.MustCompile(`\[(ddl:8201|domain:8027|domain:8028)\]`)
+)
+
+// ContextualBackoffer is a Backoffer deciding the delay by the context as well
+// as the error, e.g. not to sleep past the deadline of the context. WithRetry
+// calls NextBackoffWithContext instead of NextBackoff if it's implemented.
+type ContextualBackoffer interface {
+	Backoffer
+	// NextBackoffWithContext returns a duration to wait before retrying again.
+	NextBackoffWithContext(ctx context.Context, err error) time.Duration
+}
+
+func nextBackoff(ctx context.Context, backoffer Backoffer, err error) time.Duration {
+	if cb, ok := backoffer.(ContextualBackoffer); ok {
+		return cb.NextBackoffWithContext(ctx, err)
+	}
+	return backoffer.NextBackoff(err)
+}
+
+// RetryAfterError is an error with the delay asked by the server before
+// retrying, e.g. the `Retry-After` header of an HTTP response.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// RetryAfterOf returns the delay asked by the server which fails the request,
+// from either a RetryAfterError or the `Retry-After` in the error message.
+func RetryAfterOf(err error) (time.Duration, bool) {
+	found := errors.Find(err, func(e error) bool {
+		_, ok := e.(RetryAfterError)
+		return ok
+	})
+	if found != nil {
+		return found.(RetryAfterError).RetryAfter(), true
+	}
+	if m := retryAfterPattern.FindStringSubmatch(err.Error()); m != nil {
+		if secs, e := strconv.Atoi(m[1]); e == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// MessageIsRetryableDDLError checks whether the message is TiDB failing a DDL
+// by the change of the DDL owner or the schema, which succeeds on retry.
+func MessageIsRetryableDDLError(msg string) bool {
+	return retryableDDLPattern.MatchString(msg)
+}
+
+// MessageIsThrottlingError checks whether the message is the external storage
+// throttling the requests.
+func MessageIsThrottlingError(msg string) bool {
+	msgLower := strings.ToLower(msg)
+	for _, errStr := range throttlingMessages {
+		if strings.Contains(msgLower, errStr) {
+			return true
+		}
+	}
+	return false
+}
+
+func messageIsServerBusy(msg string) bool {
+	msgLower := strings.ToLower(msg)
+	return strings.Contains(msgLower, "server_is_busy") || strings.Contains(msgLower, "server is busy")
+}
+
+// exponentialBackoff is the truncated exponential backoff shared by the
+// backoffers.
+type exponentialBackoff struct {
+	attempt      int
+	delayTime    time.Duration
+	maxDelayTime time.Duration
+}
+
+func (bo *exponentialBackoff) next() time.Duration {
+	bo.delayTime = 2 * bo.delayTime
+	bo.attempt--
+	return bo.truncated()
+}
+
+func (bo *exponentialBackoff) stop() time.Duration {
+	bo.delayTime = 0
+	bo.attempt = 0
+	return 0
+}
+
+func (bo *exponentialBackoff) truncated() time.Duration {
+	if bo.delayTime > bo.maxDelayTime {
+		return bo.maxDelayTime
+	}
+	return bo.delayTime
+}
+
+// Attempt implements Backoffer.
+func (bo *exponentialBackoff) Attempt() int {
+	return bo.attempt
+}
+
+// PDReqBackoffer retries the PD requests on any error.
+type PDReqBackoffer struct {
+	exponentialBackoff
+}
+
+// NewPDReqBackoffer creates a PDReqBackoffer.
+func NewPDReqBackoffer(attempt int, delayTime, maxDelayTime time.Duration) *PDReqBackoffer {
+	return &PDReqBackoffer{exponentialBackoff{attempt: attempt, delayTime: delayTime, maxDelayTime: maxDelayTime}}
+}
+
+// NextBackoff implements Backoffer.
+func (bo *PDReqBackoffer) NextBackoff(err error) time.Duration {
+	return bo.next()
+}
+
+// ScanRegionBackoffer retries scanning the regions when the regions returned by
+// PD don't cover the range, e.g. the new regions of a split aren't reported yet.
+type ScanRegionBackoffer struct {
+	exponentialBackoff
+}
+
+// NewScanRegionBackoffer creates a ScanRegionBackoffer.
+func NewScanRegionBackoffer(attempt int, delayTime, maxDelayTime time.Duration) *ScanRegionBackoffer {
+	return &ScanRegionBackoffer{exponentialBackoff{attempt: attempt, delayTime: delayTime, maxDelayTime: maxDelayTime}}
+}
+
+// NextBackoff implements Backoffer.
+func (bo *ScanRegionBackoffer) NextBackoff(err error) time.Duration {
+	if berrors.Is(err, berrors.ErrPDBatchScanRegion) {
+		return bo.next()
+	}
+	return bo.stop()
+}
+
+// StorageBackoffer retries the requests to the external storage, which may be
+// throttled by the service, e.g. the `SlowDown` of S3. It waits at least the
+// delay asked by the `Retry-After` of the service, but never past the deadline
+// of the context.
+type StorageBackoffer struct {
+	exponentialBackoff
+}
+
+// NewStorageBackoffer creates a StorageBackoffer with the default settings.
+func NewStorageBackoffer() *StorageBackoffer {
+	return &StorageBackoffer{exponentialBackoff{
+		attempt:      storageRetryTimes,
+		delayTime:    storageWaitInterval,
+		maxDelayTime: storageMaxWaitInterval,
+	}}
+}
+
+// NextBackoff implements Backoffer.
+func (bo *StorageBackoffer) NextBackoff(err error) time.Duration {
+	return bo.NextBackoffWithContext(context.Background(), err)
+}
+
+// NextBackoffWithContext implements ContextualBackoffer.
+func (bo *StorageBackoffer) NextBackoffWithContext(ctx context.Context, err error) time.Duration {
+	msg := err.Error()
+	if !MessageIsThrottlingError(msg) && !MessageIsRetryableStorageError(msg) &&
+		!berrors.Is(err, berrors.ErrStorageUnknown) {
+		log.Warn("unexpected storage error, stop to retry", zap.Error(err))
+		return bo.stop()
+	}
+	delay := bo.next()
+	if retryAfter, ok := RetryAfterOf(err); ok {
+		if retryAfter > storageMaxRetryAfter {
+			retryAfter = storageMaxRetryAfter
+		}
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < delay {
+			// sleeping past the deadline gains nothing, give up at once.
+			return bo.stop()
+		}
+	}
+	return delay
+}
+
+// TiKVIngestBackoffer retries downloading and ingesting the SST files into
+// TiKV. It backs off longer when TiKV is busy, and stops at once on the
+// expected errors, e.g. the range is empty.
+type TiKVIngestBackoffer struct {
+	exponentialBackoff
+}
+
+// NewTiKVIngestBackoffer creates a TiKVIngestBackoffer.
+func NewTiKVIngestBackoffer(attempt int, delayTime, maxDelayTime time.Duration) *TiKVIngestBackoffer {
+	return &TiKVIngestBackoffer{exponentialBackoff{attempt: attempt, delayTime: delayTime, maxDelayTime: maxDelayTime}}
+}
+
+// NextBackoff implements Backoffer.
+func (bo *TiKVIngestBackoffer) NextBackoff(err error) time.Duration {
+	if MessageIsRetryableStorageError(err.Error()) {
+		return bo.next()
+	}
+	if isRetryError(err) {
+		// the inner retries of the error are exhausted, don't multiply them.
+		return bo.stop()
+	}
+	switch errors.Cause(err) { // nolint:errorlint
+	case berrors.ErrKVIngestFailed:
+		if messageIsServerBusy(err.Error()) && bo.delayTime < tikvServerBusyWaitInterval {
+			bo.delayTime = tikvServerBusyWaitInterval
+		}
+		return bo.next()
+	case berrors.ErrKVEpochNotMatch, berrors.ErrKVDownloadFailed:
+		return bo.next()
+	case berrors.ErrKVRangeIsEmpty, berrors.ErrKVRewriteRuleNotFound:
+		// Excepted error, finish the operation
+		return bo.stop()
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted:
+		return bo.next()
+	}
+	// Unexcepted error
+	log.Warn("unexcepted error, stop to retry", zap.Error(err))
+	return bo.stop()
+}
+
+// DDLBackoffer retries the DDLs failed by the change of the DDL owner or the
+// schema. The DDLs retried must be idempotent, e.g. CREATE TABLE IF NOT EXISTS.
+type DDLBackoffer struct {
+	exponentialBackoff
+}
+
+// NewDDLBackoffer creates a DDLBackoffer with the default settings.
+func NewDDLBackoffer() *DDLBackoffer {
+	return &DDLBackoffer{exponentialBackoff{
+		attempt:      ddlRetryTimes,
+		delayTime:    ddlWaitInterval,
+		maxDelayTime: ddlMaxWaitInterval,
+	}}
+}
+
+// NextBackoff implements Backoffer.
+func (bo *DDLBackoffer) NextBackoff(err error) time.Duration {
+	if MessageIsRetryableDDLError(err.Error()) {
+		return bo.next()
+	}
+	return bo.stop()
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+type testBackoffSuite struct{}
+
+var _ = Suite(&testBackoffSuite{})
+
+type retryAfterError struct {
+	after time.Duration
+}
+
+func (e retryAfterError) Error() string {
+	return "throttled"
+}
+
+func (e retryAfterError) RetryAfter() time.Duration {
+	return e.after
+}
+
+func (r *testBackoffSuite) TestRetryAfterOf(c *C) {
+	d, ok := RetryAfterOf(errors.Trace(retryAfterError{after: 3 * time.Second}))
+	c.Assert(ok, IsTrue)
+	c.Assert(d, Equals, 3*time.Second)
+	d, ok = RetryAfterOf(errors.New("503 SlowDown, Retry-After: 7"))
+	c.Assert(ok, IsTrue)
+	c.Assert(d, Equals, 7*time.Second)
+	_, ok = RetryAfterOf(errors.New("connection refused"))
+	c.Assert(ok, IsFalse)
+}
+
+func (r *testBackoffSuite) TestStorageBackoffer(c *C) {
+	ctx := context.Background()
+	bo := NewStorageBackoffer()
+	c.Assert(bo.NextBackoffWithContext(ctx, errors.New("SlowDown: please reduce your request rate")), Equals, time.Second)
+	c.Assert(bo.Attempt(), Equals, storageRetryTimes-1)
+	// the delay asked by the service is respected.
+	c.Assert(bo.NextBackoffWithContext(ctx, retryAfterError{after: 20 * time.Second}), Equals, 20*time.Second)
+	c.Assert(bo.NextBackoffWithContext(ctx, retryAfterError{after: time.Hour}), Equals, storageMaxRetryAfter)
+
+	// never sleep past the deadline.
+	tctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	c.Assert(bo.NextBackoffWithContext(tctx, errors.New("connection reset by peer")), Equals, time.Duration(0))
+	c.Assert(bo.Attempt(), Equals, 0)
+
+	bo = NewStorageBackoffer()
+	c.Assert(bo.NextBackoff(errors.Annotate(berrors.ErrStorageInvalidPermission, "access denied")), Equals, time.Duration(0))
+	c.Assert(bo.Attempt(), Equals, 0)
+}
+
+func (r *testBackoffSuite) TestTiKVIngestBackoffer(c *C) {
+	bo := NewTiKVIngestBackoffer(10, 10*time.Millisecond, 10*time.Second)
+	c.Assert(bo.NextBackoff(berrors.ErrKVEpochNotMatch), Equals, 20*time.Millisecond)
+	// TiKV needs longer to recover from busy.
+	busy := errors.Annotate(berrors.ErrKVIngestFailed, "ingest error server_is_busy:<reason:\"scheduler is busy\" >")
+	c.Assert(bo.NextBackoff(busy), Equals, 2*tikvServerBusyWaitInterval)
+	c.Assert(bo.NextBackoff(status.Error(codes.Unavailable, "transport is closing")), Equals, 4*tikvServerBusyWaitInterval)
+	c.Assert(bo.Attempt(), Equals, 7)
+	c.Assert(bo.NextBackoff(berrors.ErrKVRangeIsEmpty), Equals, time.Duration(0))
+	c.Assert(bo.Attempt(), Equals, 0)
+
+	// the exhausted inner retries aren't retried again.
+	bo = NewTiKVIngestBackoffer(10, 10*time.Millisecond, 10*time.Second)
+	c.Assert(bo.NextBackoff(errors.Trace(&RetryError{Attempts: []RetryAttempt{{Err: berrors.ErrKVDownloadFailed}}})),
+		Equals, time.Duration(0))
+	c.Assert(bo.Attempt(), Equals, 0)
+
+	pd := NewPDReqBackoffer(2, 100*time.Millisecond, 150*time.Millisecond)
+	c.Assert(pd.NextBackoff(errors.New("any")), Equals, 150*time.Millisecond)
+	c.Assert(pd.Attempt(), Equals, 1)
+
+	// only the regions not covering the range are scanned again.
+	scan := NewScanRegionBackoffer(3, 50*time.Millisecond, time.Second)
+	c.Assert(scan.NextBackoff(errors.Annotate(berrors.ErrPDBatchScanRegion, "gap")), Equals, 100*time.Millisecond)
+	c.Assert(scan.Attempt(), Equals, 2)
+	c.Assert(scan.NextBackoff(errors.New("scan regions failed")), Equals, time.Duration(0))
+	c.Assert(scan.Attempt(), Equals, 0)
+}
+
+func (r *testBackoffSuite) TestDDLBackoffer(c *C) {
+	bo := NewDDLBackoffer()
+	c.Assert(bo.NextBackoff(errors.New("[ddl:8201]TiDB server is not a DDL owner")), Equals, 2*ddlWaitInterval)
+	c.Assert(bo.NextBackoff(errors.New("[domain:8028]Information schema is changed during the execution")),
+		Equals, 4*ddlWaitInterval)
+	c.Assert(bo.Attempt(), Equals, ddlRetryTimes-2)
+	c.Assert(bo.NextBackoff(errors.New("[schema:1050]Table 't' already exists")), Equals, time.Duration(0))
+	c.Assert(bo.Attempt(), Equals, 0)
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of the retries, backoffs and tickers. It's
+// carried by the context, so the tests can replace it by a FakeClock and
+// advance the time without sleeping.
+type Clock interface {
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// NewTicker returns a ticker sending the time periodically.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is a ticker of a Clock.
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+type clockKey struct{}
+
+// WithClock returns a context carrying the clock.
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// ClockFromContext returns the clock carried by the context, or the real
+// clock if there isn't one.
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return realClock{}
+}
+
+// SleepWithContext sleeps for the duration in the clock of the context. It
+// returns early with the error of the context if the context is done.
+func SleepWithContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ClockFromContext(ctx).After(d):
+		return nil
+	}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+// FakeClock is a Clock whose time only moves by Add, for the tests.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters map[*fakeWaiter]struct{}
+}
+
+type fakeWaiter struct {
+	until  time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+// NewFakeClock creates a FakeClock starting at the time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now, waiters: make(map[*fakeWaiter]struct{})}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now implements Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After implements Clock.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.addWaiter(d, 0).ch
+}
+
+// NewTicker implements Clock.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	return &fakeTicker{clock: c, waiter: c.addWaiter(d, d)}
+}
+
+func (c *FakeClock) addWaiter(d, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{until: c.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		w.ch <- c.now
+		return w
+	}
+	c.waiters[w] = struct{}{}
+	c.cond.Broadcast()
+	return w
+}
+
+// Add advances the time, firing the timers and tickers due. Like the real
+// tickers, a ticker drops the ticks if the receiver falls behind.
+func (c *FakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for w := range c.waiters {
+		for !w.until.After(c.now) {
+			select {
+			case w.ch <- w.until:
+			default:
+			}
+			if w.period == 0 {
+				delete(c.waiters, w)
+				break
+			}
+			w.until = w.until.Add(w.period)
+		}
+	}
+}
+
+// BlockUntil blocks until there are n timers and tickers waiting, so the time
+// is advanced only after the goroutines under test start to wait.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.waiter.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	delete(t.clock.waiters, t.waiter)
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+type testClockSuite struct{}
+
+var _ = Suite(&testClockSuite{})
+
+func (r *testClockSuite) TestFakeClock(c *C) {
+	start := time.Unix(1600000000, 0)
+	clock := NewFakeClock(start)
+	after := clock.After(time.Minute)
+	ticker := clock.NewTicker(20 * time.Second)
+	defer ticker.Stop()
+	clock.BlockUntil(2)
+
+	clock.Add(30 * time.Second)
+	c.Assert(clock.Now(), Equals, start.Add(30*time.Second))
+	c.Assert(<-ticker.Chan(), Equals, start.Add(20*time.Second))
+	select {
+	case <-after:
+		c.Fatal("the timer fired too early")
+	default:
+	}
+	clock.Add(30 * time.Second)
+	c.Assert(<-after, Equals, start.Add(time.Minute))
+	c.Assert(<-ticker.Chan(), Equals, start.Add(40*time.Second))
+	c.Assert(ClockFromContext(context.Background()), Equals, Clock(realClock{}))
+}
+
+func (r *testClockSuite) TestRetryWithFakeClock(c *C) {
+	clock := NewFakeClock(time.Now())
+	ctx := WithClock(context.Background(), clock)
+	done := make(chan error, 1)
+	go func() {
+		done <- WithRetry(ctx, func() error {
+			return errors.New("connection refused")
+		}, NewPDReqBackoffer(3, time.Hour, time.Hour))
+	}()
+	// no need to wait for the real hours of the backoffs.
+	for i := 0; i < 3; i++ {
+		clock.BlockUntil(1)
+		clock.Add(time.Hour)
+	}
+	err := <-done
+	c.Assert(err, ErrorMatches, "connection refused; connection refused; connection refused")
+	history := err.(*RetryError).Attempts
+	c.Assert(history[2].Time.Sub(history[0].Time), Equals, 2*time.Hour)
+
+	sctx, cancel := context.WithCancel(ctx)
+	cancel()
+	c.Assert(SleepWithContext(sctx, time.Hour), Equals, context.Canceled)
+}
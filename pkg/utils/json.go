@@ -12,21 +12,45 @@ import (
 // MarshalBackupMeta converts the backupmeta strcture to JSON.
 // Unlike json.Marshal, this function also format some []byte fields for human reading.
 func MarshalBackupMeta(meta *backuppb.BackupMeta) ([]byte, error) {
+	return MarshalBackupMetaWithUnknownFields(meta, nil)
+}
+
+// MarshalBackupMetaWithUnknownFields is MarshalBackupMeta keeping the unknown
+// fields of the backupmeta in the wire format, see metautil.UnknownFields.
+func MarshalBackupMetaWithUnknownFields(meta *backuppb.BackupMeta, unknown []byte) ([]byte, error) {
 	result, err := makeJSONBackupMeta(meta)
 	if err != nil {
 		return nil, err
 	}
+	if len(unknown) > 0 {
+		result.UnknownFields = hex.EncodeToString(unknown)
+	}
 	return json.Marshal(result)
 }
 
 // UnmarshalBackupMeta converts the prettied JSON format of backupmeta
 // (made by MarshalBackupMeta) back to the go structure.
 func UnmarshalBackupMeta(data []byte) (*backuppb.BackupMeta, error) {
+	meta, _, err := UnmarshalBackupMetaWithUnknownFields(data)
+	return meta, err
+}
+
+// UnmarshalBackupMetaWithUnknownFields is UnmarshalBackupMeta returning the
+// unknown fields kept by MarshalBackupMetaWithUnknownFields as well.
+func UnmarshalBackupMetaWithUnknownFields(data []byte) (*backuppb.BackupMeta, []byte, error) {
 	jMeta := &jsonBackupMeta{}
 	if err := json.Unmarshal(data, jMeta); err != nil {
-		return nil, errors.Trace(err)
+		return nil, nil, errors.Trace(err)
+	}
+	unknown, err := hex.DecodeString(jMeta.UnknownFields)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	meta, err := fromJSONBackupMeta(jMeta)
+	if err != nil {
+		return nil, nil, err
 	}
-	return fromJSONBackupMeta(jMeta)
+	return meta, unknown, nil
 }
 
 type jsonValue interface{}
@@ -129,6 +153,8 @@ type jsonBackupMeta struct {
 	RawRanges []*jsonRawRange `json:"raw_ranges,omitempty"`
 	Schemas   []*jsonSchema   `json:"schemas,omitempty"`
 	DDLs      jsonValue       `json:"ddls,omitempty"`
+	// UnknownFields are the fields unknown to this BR in the wire format.
+	UnknownFields string `json:"unknown_fields,omitempty"`
 
 	*backuppb.BackupMeta
 }
@@ -5,24 +5,66 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/cheggaaa/pb/v3"
+	"github.com/docker/go-units"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
 )
 
 type logFunc func(msg string, fields ...zap.Field)
 
+// ProgressMode is how the progress is printed.
+type ProgressMode string
+
+const (
+	// ProgressModeAuto prints the progress bar to the terminal if the log is
+	// written into a file, otherwise it prints the progress into the log.
+	ProgressModeAuto ProgressMode = ""
+	// ProgressModeFancy prints the progress bar to the terminal.
+	ProgressModeFancy ProgressMode = "fancy"
+	// ProgressModePlain prints the progress into the log periodically, without
+	// any control characters.
+	ProgressModePlain ProgressMode = "plain"
+	// ProgressModeNone doesn't print the progress.
+	ProgressModeNone ProgressMode = "none"
+)
+
+// ParseProgressMode parses the progress mode.
+func ParseProgressMode(s string) (ProgressMode, error) {
+	switch mode := ProgressMode(strings.ToLower(s)); mode {
+	case ProgressModeAuto, ProgressModeFancy, ProgressModePlain, ProgressModeNone:
+		return mode, nil
+	default:
+		return "", errors.Annotatef(berrors.ErrInvalidArgument,
+			"invalid progress mode %q, it should be fancy, plain or none", s)
+	}
+}
+
+// progressETAWindow is the duration of the recent progress which the remaining
+// time is estimated from.
+const progressETAWindow = 30 * time.Second
+
+// progressPhases counts the progress printers started in the process, so the
+// phases of a task can be told apart.
+var progressPhases int32
+
 // ProgressPrinter prints a progress bar.
 type ProgressPrinter struct {
 	name        string
+	phase       int32
 	total       int64
 	redirectLog bool
 	progress    int64
+	bytes       int64
 
 	cancel context.CancelFunc
 }
@@ -35,6 +77,7 @@ func NewProgressPrinter(
 ) *ProgressPrinter {
 	return &ProgressPrinter{
 		name:        name,
+		phase:       atomic.AddInt32(&progressPhases, 1),
 		total:       total,
 		redirectLog: redirectLog,
 		cancel: func() {
@@ -48,6 +91,11 @@ func (pp *ProgressPrinter) Inc() {
 	atomic.AddInt64(&pp.progress, 1)
 }
 
+// AddBytes adds the bytes processed.
+func (pp *ProgressPrinter) AddBytes(n int64) {
+	atomic.AddInt64(&pp.bytes, n)
+}
+
 // Close closes the current progress bar.
 func (pp *ProgressPrinter) Close() {
 	pp.cancel()
@@ -62,8 +110,10 @@ func (pp *ProgressPrinter) goPrintProgress(
 	cctx, cancel := context.WithCancel(ctx)
 	pp.cancel = cancel
 	bar := pb.New64(pp.total)
+	bar.Set("eta", "-")
+	bar.Set("bytes", "-")
 	if pp.redirectLog || testWriter != nil {
-		tmpl := `{"P":"{{percent .}}","C":"{{counters . }}","E":"{{etime .}}","R":"{{rtime .}}","S":"{{speed .}}"}`
+		tmpl := `{"P":"{{percent .}}","C":"{{counters . }}","E":"{{etime .}}","R":"{{string . "eta"}}","S":"{{speed .}}","B":"{{string . "bytes"}}"}`
 		bar.SetTemplateString(tmpl)
 		bar.SetRefreshRate(2 * time.Minute)
 		bar.Set(pb.Static, false)       // Do not update automatically
@@ -74,11 +124,12 @@ func (pp *ProgressPrinter) goPrintProgress(
 		if logFuncImpl == nil {
 			logFuncImpl = log.Info
 		}
-		bar.SetWriter(&wrappedWriter{name: pp.name, log: logFuncImpl})
+		bar.SetWriter(&wrappedWriter{name: pp.name, phase: pp.phase, log: logFuncImpl})
 	} else {
-		tmpl := `{{string . "barName" | green}} {{ bar . "<" "-" (cycle . "-" "\\" "|" "/" ) "." ">"}} {{percent .}}`
+		tmpl := `{{string . "barName" | green}} {{ bar . "<" "-" (cycle . "-" "\\" "|" "/" ) "." ">"}} {{percent .}} ` +
+			`{{counters .}} {{string . "bytes"}} ETA {{string . "eta"}}`
 		bar.SetTemplateString(tmpl)
-		bar.Set("barName", pp.name)
+		bar.Set("barName", fmt.Sprintf("[%d] %s", pp.phase, pp.name))
 	}
 	if testWriter != nil {
 		bar.SetWriter(testWriter)
@@ -91,33 +142,101 @@ func (pp *ProgressPrinter) goPrintProgress(
 		defer t.Stop()
 		defer bar.Finish()
 
+		start := time.Now()
+		eta := etaEstimator{window: progressETAWindow}
 		for {
 			select {
 			case <-cctx.Done():
+				pp.logPhaseFinished(logFuncImpl, time.Since(start))
 				// a hacky way to adapt the old behavior:
 				// when canceled by the outer context, leave the progress unchanged.
 				// when canceled by Close method (the 'internal' way), push the progress to 100%.
 				if ctx.Err() != nil {
 					return
 				}
+				bar.Set("eta", "0s")
 				bar.SetCurrent(pp.total)
 				return
-			case <-t.C:
-			}
-
-			currentProgress := atomic.LoadInt64(&pp.progress)
-			if currentProgress <= pp.total {
+			case now := <-t.C:
+				currentProgress := atomic.LoadInt64(&pp.progress)
+				if currentProgress > pp.total {
+					currentProgress = pp.total
+				}
+				eta.add(now, currentProgress)
+				bar.Set("eta", formatETA(eta.eta(pp.total)))
+				if bytes := atomic.LoadInt64(&pp.bytes); bytes > 0 {
+					bar.Set("bytes", units.HumanSize(float64(bytes)))
+				}
 				bar.SetCurrent(currentProgress)
-			} else {
-				bar.SetCurrent(pp.total)
 			}
 		}
 	}()
 }
 
+func (pp *ProgressPrinter) logPhaseFinished(logFuncImpl logFunc, elapsed time.Duration) {
+	if logFuncImpl == nil {
+		logFuncImpl = log.Info
+	}
+	logFuncImpl("progress phase finished",
+		zap.String("step", pp.name),
+		zap.Int32("phase", pp.phase),
+		zap.Int64("count", atomic.LoadInt64(&pp.progress)),
+		zap.Int64("total", pp.total),
+		zap.Int64("bytes", atomic.LoadInt64(&pp.bytes)),
+		zap.Duration("take", elapsed))
+}
+
+type progressSample struct {
+	at       time.Time
+	progress int64
+}
+
+// etaEstimator estimates the remaining time from the throughput of the recent
+// progress, so it follows the changes of the speed.
+type etaEstimator struct {
+	window  time.Duration
+	samples []progressSample
+}
+
+func (e *etaEstimator) add(at time.Time, progress int64) {
+	e.samples = append(e.samples, progressSample{at: at, progress: progress})
+	// keep one sample out of the window as the base of the throughput.
+	i := 0
+	for i+1 < len(e.samples) && at.Sub(e.samples[i+1].at) >= e.window {
+		i++
+	}
+	e.samples = e.samples[i:]
+}
+
+// eta returns the estimated remaining time, or a negative duration if there
+// is no recent progress to estimate it.
+func (e *etaEstimator) eta(total int64) time.Duration {
+	if len(e.samples) < 2 {
+		return -1
+	}
+	first, last := e.samples[0], e.samples[len(e.samples)-1]
+	if last.progress >= total {
+		return 0
+	}
+	done := last.progress - first.progress
+	if done <= 0 {
+		return -1
+	}
+	elapsed := last.at.Sub(first.at)
+	return time.Duration(float64(elapsed) * float64(total-last.progress) / float64(done))
+}
+
+func formatETA(eta time.Duration) string {
+	if eta < 0 {
+		return "-"
+	}
+	return eta.Round(time.Second).String()
+}
+
 type wrappedWriter struct {
-	name string
-	log  logFunc
+	name  string
+	phase int32
+	log   logFunc
 }
 
 func (ww *wrappedWriter) Write(p []byte) (int, error) {
@@ -127,17 +246,20 @@ func (ww *wrappedWriter) Write(p []byte) (int, error) {
 		E string
 		R string
 		S string
+		B string
 	}
 	if err := json.Unmarshal(p, &info); err != nil {
 		return 0, errors.Trace(err)
 	}
 	ww.log("progress",
 		zap.String("step", ww.name),
+		zap.Int32("phase", ww.phase),
 		zap.String("progress", info.P),
 		zap.String("count", info.C),
 		zap.String("speed", info.S),
 		zap.String("elapsed", info.E),
-		zap.String("remaining", info.R))
+		zap.String("remaining", info.R),
+		zap.String("bytes", info.B))
 	return len(p), nil
 }
 
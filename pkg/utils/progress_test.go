@@ -75,3 +75,33 @@ func (r *testProgressSuite) TestProgress(c *C) {
 	p = <-pCh8
 	c.Assert(p, Matches, `.*"P":"25\.00%".*`)
 }
+
+func (r *testProgressSuite) TestETA(c *C) {
+	now := time.Now()
+	eta := etaEstimator{window: 10 * time.Second}
+	eta.add(now, 0)
+	c.Assert(eta.eta(100), Equals, time.Duration(-1))
+	eta.add(now.Add(5*time.Second), 10)
+	c.Assert(eta.eta(100), Equals, 45*time.Second)
+	// the samples out of the window are dropped, the ETA follows the recent speed.
+	eta.add(now.Add(20*time.Second), 10)
+	eta.add(now.Add(25*time.Second), 40)
+	c.Assert(eta.samples, HasLen, 3)
+	c.Assert(eta.eta(100), Equals, 40*time.Second)
+	c.Assert(formatETA(eta.eta(100)), Equals, "40s")
+	eta.add(now.Add(26*time.Second), 100)
+	c.Assert(eta.eta(100), Equals, time.Duration(0))
+	c.Assert(formatETA(-1), Equals, "-")
+}
+
+func (r *testProgressSuite) TestParseProgressMode(c *C) {
+	for _, s := range []string{"", "fancy", "Plain", "none"} {
+		_, err := ParseProgressMode(s)
+		c.Assert(err, IsNil)
+	}
+	mode, err := ParseProgressMode("PLAIN")
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, ProgressModePlain)
+	_, err = ParseProgressMode("json")
+	c.Assert(err, ErrorMatches, "invalid progress mode.*")
+}
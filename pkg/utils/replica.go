@@ -0,0 +1,66 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/kv"
+	"github.com/tikv/client-go/v2/oracle"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// ReplicaRead is the replicas the reads at a TS are served by.
+type ReplicaRead string
+
+const (
+	// ReplicaReadLeader reads from the leaders.
+	ReplicaReadLeader ReplicaRead = "leader"
+	// ReplicaReadFollower reads from the followers, which wait for the
+	// leaders to confirm they are up to date.
+	ReplicaReadFollower ReplicaRead = "follower"
+	// ReplicaReadStale reads from any replica whose data is safe to read at
+	// the TS, without contacting the leaders.
+	ReplicaReadStale ReplicaRead = "stale"
+)
+
+// ParseReplicaRead parses the replica read mode, the empty string is leader.
+func ParseReplicaRead(s string) (ReplicaRead, error) {
+	switch r := ReplicaRead(s); r {
+	case "":
+		return ReplicaReadLeader, nil
+	case ReplicaReadLeader, ReplicaReadFollower, ReplicaReadStale:
+		return r, nil
+	default:
+		return "", errors.Annotatef(berrors.ErrInvalidArgument,
+			"unknown replica read %q, should be one of leader, follower or stale", s)
+	}
+}
+
+// IsLeader returns whether the reads are served by the leaders, which is
+// where the reads fall back to when the replicas fail them.
+func (r ReplicaRead) IsLeader() bool {
+	return r == "" || r == ReplicaReadLeader
+}
+
+// ApplyToSnapshot makes the reads of the snapshot served by the replicas.
+func (r ReplicaRead) ApplyToSnapshot(snapshot kv.Snapshot) {
+	switch r {
+	case ReplicaReadFollower:
+		snapshot.SetOption(kv.ReplicaRead, kv.ReplicaReadFollower)
+	case ReplicaReadStale:
+		snapshot.SetOption(kv.IsStalenessReadOnly, true)
+		snapshot.SetOption(kv.TxnScope, oracle.GlobalTxnScope)
+	}
+}
+
+// ApplyToRequest makes the coprocessor request served by the replicas.
+func (r ReplicaRead) ApplyToRequest(req *kv.Request) {
+	switch r {
+	case ReplicaReadFollower:
+		req.ReplicaRead = kv.ReplicaReadFollower
+	case ReplicaReadStale:
+		req.IsStaleness = true
+		req.TxnScope = oracle.GlobalTxnScope
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/kv"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+type testReplicaReadSuite struct{}
+
+var _ = Suite(&testReplicaReadSuite{})
+
+func (r *testReplicaReadSuite) TestParseReplicaRead(c *C) {
+	for s, expect := range map[string]ReplicaRead{
+		"":         ReplicaReadLeader,
+		"leader":   ReplicaReadLeader,
+		"follower": ReplicaReadFollower,
+		"stale":    ReplicaReadStale,
+	} {
+		replicaRead, err := ParseReplicaRead(s)
+		c.Assert(err, IsNil)
+		c.Assert(replicaRead, Equals, expect)
+	}
+	_, err := ParseReplicaRead("learner")
+	c.Assert(err, ErrorMatches, ".*unknown replica read \"learner\".*")
+
+	c.Assert(ReplicaRead("").IsLeader(), IsTrue)
+	c.Assert(ReplicaReadStale.IsLeader(), IsFalse)
+}
+
+func (r *testReplicaReadSuite) TestApplyToRequest(c *C) {
+	req := &kv.Request{}
+	ReplicaReadLeader.ApplyToRequest(req)
+	c.Assert(req, DeepEquals, &kv.Request{})
+
+	ReplicaReadFollower.ApplyToRequest(req)
+	c.Assert(req.ReplicaRead, Equals, kv.ReplicaReadFollower)
+	c.Assert(req.IsStaleness, IsFalse)
+
+	req = &kv.Request{}
+	ReplicaReadStale.ApplyToRequest(req)
+	c.Assert(req.IsStaleness, IsTrue)
+	c.Assert(req.TxnScope, Equals, oracle.GlobalTxnScope)
+}
@@ -4,10 +4,18 @@ package utils
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"strings"
 	"time"
 
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
 	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+
+	berrors "github.com/pingcap/br/pkg/errors"
 )
 
 var retryableServerError = []string{
@@ -32,30 +40,142 @@ type Backoffer interface {
 	Attempt() int
 }
 
+// RetryAttempt is a failed attempt of a retried operation.
+type RetryAttempt struct {
+	Time time.Time
+	// Class is the code of the error class of the failure.
+	Class string
+	Err   error
+}
+
+// RetryError is the error of an operation failed in all the attempts. It keeps
+// the history of the attempts, so the intermittent failures, e.g. a flaky
+// network, can be told from a persistent one.
+//
+// The errors of the attempts are split by RetryErrors, multierr.Errors doesn't
+// split it. The cause of it is the error of the last attempt, which is also
+// unwrapped by errors.Is and tells the gRPC status.
+type RetryError struct {
+	Attempts []RetryAttempt
+}
+
+func (e *RetryError) append(at time.Time, err error) {
+	e.Attempts = append(e.Attempts, RetryAttempt{
+		Time:  at,
+		Class: berrors.ClassOf(err).Code,
+		Err:   err,
+	})
+}
+
+// Errors returns the errors of the attempts in order.
+func (e *RetryError) Errors() []error {
+	errs := make([]error, 0, len(e.Attempts))
+	for _, attempt := range e.Attempts {
+		errs = append(errs, attempt.Err)
+	}
+	return errs
+}
+
+// Error implements error. The message is the same as the errors combined by
+// multierr.
+func (e *RetryError) Error() string {
+	return multierr.Combine(e.Errors()...).Error()
+}
+
+// Cause returns the error of the last attempt.
+func (e *RetryError) Cause() error {
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryError) Unwrap() error {
+	return e.Cause()
+}
+
+// GRPCStatus returns the gRPC status of the last attempt for status.FromError.
+func (e *RetryError) GRPCStatus() *status.Status {
+	s, _ := status.FromError(errors.Cause(e.Cause()))
+	return s
+}
+
+// RetryErrors returns the errors of all the attempts if err is or wraps a
+// *RetryError, or splits err by multierr.Errors otherwise.
+func RetryErrors(err error) []error {
+	found := errors.Find(err, func(e error) bool {
+		_, ok := e.(*RetryError)
+		return ok
+	})
+	if retryErr, ok := found.(*RetryError); ok {
+		return retryErr.Errors()
+	}
+	return multierr.Errors(err)
+}
+
+// History returns the attempts in the form of `time [class] error`.
+func (e *RetryError) History() []string {
+	history := make([]string, 0, len(e.Attempts))
+	for _, attempt := range e.Attempts {
+		history = append(history, fmt.Sprintf("%s [%s] %s",
+			attempt.Time.Format("2006-01-02 15:04:05.000"), attempt.Class, attempt.Err))
+	}
+	return history
+}
+
+// Format implements fmt.Formatter, the history is printed with `%+v`, which
+// is the verbose error in the log.
+func (e *RetryError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "failed after %d attempts:", len(e.Attempts))
+		for _, h := range e.History() {
+			fmt.Fprintf(s, "\n  %s", h)
+		}
+		return
+	}
+	_, _ = io.WriteString(s, e.Error())
+}
+
+func isRetryError(err error) bool {
+	found := errors.Find(err, func(e error) bool {
+		_, ok := e.(*RetryError)
+		return ok
+	})
+	return found != nil
+}
+
 // WithRetry retries a given operation with a backoff policy.
 //
 // Returns nil if `retryableFunc` succeeded at least once. Otherwise, returns a
-// multierr containing all errors encountered.
+// *RetryError containing all errors encountered.
 func WithRetry(
 	ctx context.Context,
 	retryableFunc RetryableFunc,
 	backoffer Backoffer,
 ) error {
-	var allErrors error
+	var retryErr *RetryError
+	clock := ClockFromContext(ctx)
 	for backoffer.Attempt() > 0 {
 		err := retryableFunc()
-		if err != nil {
-			allErrors = multierr.Append(allErrors, err)
-			select {
-			case <-ctx.Done():
-				return allErrors // nolint:wrapcheck
-			case <-time.After(backoffer.NextBackoff(err)):
-			}
-		} else {
+		if err == nil {
 			return nil
 		}
+		if retryErr == nil {
+			retryErr = &RetryError{}
+		}
+		retryErr.append(clock.Now(), err)
+		select {
+		case <-ctx.Done():
+			return retryErr
+		case <-clock.After(nextBackoff(ctx, backoffer, err)):
+		}
+	}
+	if retryErr == nil {
+		return nil
+	}
+	if len(retryErr.Attempts) > 1 {
+		log.Warn("operation failed after retries",
+			zap.Int("attempts", len(retryErr.Attempts)), zap.Strings("history", retryErr.History()))
 	}
-	return allErrors // nolint:wrapcheck
+	return retryErr
 }
 
 // MessageIsRetryableStorageError checks whether the message returning from TiKV is retryable ExternalStorageError.
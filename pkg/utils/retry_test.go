@@ -0,0 +1,89 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package utils
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+type testRetrySuite struct{}
+
+var _ = Suite(&testRetrySuite{})
+
+type fixedBackoffer struct {
+	attempt int
+}
+
+func (b *fixedBackoffer) NextBackoff(error) time.Duration {
+	b.attempt--
+	return time.Millisecond
+}
+
+func (b *fixedBackoffer) Attempt() int {
+	return b.attempt
+}
+
+func (r *testRetrySuite) TestRetryHistory(c *C) {
+	errs := []error{
+		errors.New("connection refused"),
+		errors.Annotate(berrors.ErrStorageUnknown, "read timeout"),
+		errors.Annotate(berrors.ErrStorageInvalidPermission, "access denied"),
+	}
+	counter := 0
+	err := WithRetry(context.Background(), func() error {
+		defer func() { counter++ }()
+		return errs[counter]
+	}, &fixedBackoffer{attempt: 3})
+	c.Assert(counter, Equals, 3)
+	c.Assert(RetryErrors(err), DeepEquals, errs)
+	c.Assert(err, ErrorMatches, "connection refused; read timeout.*; access denied.*")
+	// the last failure decides the class of the error.
+	c.Assert(errors.Cause(err), Equals, berrors.ErrStorageInvalidPermission)
+	c.Assert(berrors.ClassOf(err), DeepEquals, berrors.ClassStoragePermission)
+
+	retryErr, ok := err.(*RetryError)
+	c.Assert(ok, IsTrue)
+	history := retryErr.History()
+	c.Assert(history, HasLen, 3)
+	c.Assert(history[0], Matches, `.* \[unknown\] connection refused`)
+	c.Assert(history[1], Matches, `.* \[storage-error\] read timeout.*`)
+	c.Assert(fmt.Sprintf("%+v", err), Matches, "(?s)failed after 3 attempts:.*access denied.*")
+
+	counter = 0
+	err = WithRetry(context.Background(), func() error {
+		counter++
+		if counter < 2 {
+			return errs[0]
+		}
+		return nil
+	}, &fixedBackoffer{attempt: 3})
+	c.Assert(err, IsNil)
+}
+
+func (r *testRetrySuite) TestRetryErrorUnwrap(c *C) {
+	errs := []error{
+		errors.New("connection refused"),
+		status.Error(codes.Unavailable, "store is down"),
+	}
+	counter := 0
+	err := WithRetry(context.Background(), func() error {
+		defer func() { counter++ }()
+		return errs[counter]
+	}, &fixedBackoffer{attempt: 2})
+	c.Assert(stderrors.Is(err, errs[1]), IsTrue)
+	c.Assert(status.Code(err), Equals, codes.Unavailable)
+	c.Assert(RetryErrors(errors.Annotate(err, "download")), DeepEquals, errs)
+
+	// the errors not retried are split as a multierr.
+	c.Assert(RetryErrors(errs[0]), DeepEquals, errs[:1])
+}
@@ -15,6 +15,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/pingcap/br/pkg/audit"
 	berrors "github.com/pingcap/br/pkg/errors"
 )
 
@@ -78,7 +79,10 @@ func CheckGCSafePoint(ctx context.Context, pdClient pd.Client, ts uint64) error
 func updateServiceSafePoint(ctx context.Context, pdClient pd.Client, sp BRServiceSafePoint) error {
 	log.Debug("update PD safePoint limit with TTL", zap.Object("safePoint", sp))
 
+	start := time.Now()
 	lastSafePoint, err := pdClient.UpdateServiceGCSafePoint(ctx, sp.ID, sp.TTL, sp.BackupTS-1)
+	audit.Record(audit.ActionUpdateServiceSafePoint, sp.ID,
+		fmt.Sprintf("safe point %d, ttl %ds", sp.BackupTS-1, sp.TTL), start, err)
 	if lastSafePoint > sp.BackupTS-1 {
 		log.Warn("service GC safe point lost, we may fail to back up if GC lifetime isn't long enough",
 			zap.Uint64("lastSafePoint", lastSafePoint),
@@ -109,8 +113,9 @@ func StartServiceSafePointKeeper(
 
 	// It would be OK since TTL won't be zero, so gapTime should > `0.
 	updateGapTime := time.Duration(sp.TTL) * time.Second / preUpdateServiceSafePointFactor
-	updateTick := time.NewTicker(updateGapTime)
-	checkTick := time.NewTicker(checkGCSafePointGapTime)
+	clock := ClockFromContext(ctx)
+	updateTick := clock.NewTicker(updateGapTime)
+	checkTick := clock.NewTicker(checkGCSafePointGapTime)
 	go func() {
 		defer updateTick.Stop()
 		defer checkTick.Stop()
@@ -119,13 +124,13 @@ func StartServiceSafePointKeeper(
 			case <-ctx.Done():
 				log.Debug("service safe point keeper exited")
 				return
-			case <-updateTick.C:
+			case <-updateTick.Chan():
 				if err := updateServiceSafePoint(ctx, pdClient, sp); err != nil {
 					log.Warn("failed to update service safe point, backup may fail if gc triggered",
 						zap.Error(err),
 					)
 				}
-			case <-checkTick.C:
+			case <-checkTick.Chan():
 				if err := CheckGCSafePoint(ctx, pdClient, sp.BackupTS); err != nil {
 					log.Panic("cannot pass gc safe point check, aborting",
 						zap.Error(err),
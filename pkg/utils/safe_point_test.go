@@ -5,6 +5,7 @@ package utils_test
 import (
 	"context"
 	"sync"
+	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/util/testleak"
@@ -49,11 +50,16 @@ type mockSafePoint struct {
 	pd.Client
 	safepoint           uint64
 	minServiceSafepoint uint64
+	// onUpdate is notified after the service safe point is updated if it isn't nil.
+	onUpdate chan struct{}
 }
 
 func (m *mockSafePoint) UpdateServiceGCSafePoint(ctx context.Context, serviceID string, ttl int64, safePoint uint64) (uint64, error) {
 	m.Lock()
 	defer m.Unlock()
+	if m.onUpdate != nil {
+		defer func() { m.onUpdate <- struct{}{} }()
+	}
 
 	if m.safepoint > safePoint {
 		return m.safepoint, nil
@@ -137,3 +143,24 @@ func (s *testSafePointSuite) TestStartServiceSafePointKeeper(c *C) {
 		cancel()
 	}
 }
+
+func (s *testSafePointSuite) TestServiceSafePointKeeperTicks(c *C) {
+	pdClient := &mockSafePoint{safepoint: 2333, onUpdate: make(chan struct{}, 1)}
+	clock := utils.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(utils.WithClock(context.Background(), clock))
+	defer cancel()
+
+	sp := utils.BRServiceSafePoint{ID: "br", TTL: 30, BackupTS: 2333 + 1}
+	c.Assert(utils.StartServiceSafePointKeeper(ctx, pdClient, sp), IsNil)
+	<-pdClient.onUpdate
+	// the service safe point is updated every TTL/3 by the ticker.
+	clock.BlockUntil(2)
+	clock.Add(9 * time.Second)
+	select {
+	case <-pdClient.onUpdate:
+		c.Fatal("the service safe point is updated too early")
+	default:
+	}
+	clock.Add(time.Second)
+	<-pdClient.onUpdate
+}
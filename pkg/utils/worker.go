@@ -85,6 +85,17 @@ func (pool *WorkerPool) ApplyWorker() *Worker {
 	return worker
 }
 
+// TryApplyWorker applies a worker without waiting, it returns nil if all the
+// workers are in use.
+func (pool *WorkerPool) TryApplyWorker() *Worker {
+	select {
+	case worker := <-pool.workers:
+		return worker
+	default:
+		return nil
+	}
+}
+
 // RecycleWorker recycle a worker.
 func (pool *WorkerPool) RecycleWorker(worker *Worker) {
 	if worker == nil {
@@ -93,6 +104,11 @@ func (pool *WorkerPool) RecycleWorker(worker *Worker) {
 	pool.workers <- worker
 }
 
+// Limit returns the number of the workers in the pool.
+func (pool *WorkerPool) Limit() uint {
+	return pool.limit
+}
+
 // HasWorker checks if the pool has unallocated workers.
 func (pool *WorkerPool) HasWorker() bool {
 	return len(pool.workers) > 0
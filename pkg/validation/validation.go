@@ -0,0 +1,102 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+// Package validation checks the combinations of the config items as a whole,
+// and reports all the problems found at once, each with a suggestion.
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+)
+
+// Severity is how serious a problem is.
+type Severity int
+
+const (
+	// SeverityError fails the validation.
+	SeverityError Severity = iota
+	// SeverityWarning is reported, but the config can still be used, e.g. a
+	// config item ignored in the combination.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Problem is a problem of the config.
+type Problem struct {
+	Severity Severity
+	// Items are the config items or flags involved, in the form users write
+	// them, e.g. "--ratelimit" or "`tikv-importer.backend`".
+	Items      []string
+	Message    string
+	Suggestion string
+}
+
+func (p *Problem) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s: %s", p.Severity, strings.Join(p.Items, ", "), p.Message)
+	if p.Suggestion != "" {
+		fmt.Fprintf(&b, " (suggestion: %s)", p.Suggestion)
+	}
+	return b.String()
+}
+
+// Validator collects the problems of a config.
+type Validator struct {
+	problems []Problem
+}
+
+// Error reports a problem failing the validation.
+func (v *Validator) Error(items []string, message, suggestion string) {
+	v.problems = append(v.problems, Problem{Severity: SeverityError, Items: items, Message: message, Suggestion: suggestion})
+}
+
+// Warn reports a problem not failing the validation.
+func (v *Validator) Warn(items []string, message, suggestion string) {
+	v.problems = append(v.problems, Problem{Severity: SeverityWarning, Items: items, Message: message, Suggestion: suggestion})
+}
+
+// Problems returns the problems in the order reported.
+func (v *Validator) Problems() []Problem {
+	return v.problems
+}
+
+// Warnings returns the problems not failing the validation.
+func (v *Validator) Warnings() []Problem {
+	var warnings []Problem
+	for _, p := range v.problems {
+		if p.Severity == SeverityWarning {
+			warnings = append(warnings, p)
+		}
+	}
+	return warnings
+}
+
+// Err returns an error listing all the problems if any fails the validation,
+// so they can be fixed at once instead of one by one.
+func (v *Validator) Err() error {
+	failed := 0
+	for _, p := range v.problems {
+		if p.Severity == SeverityError {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(v.problems))
+	for i := range v.problems {
+		lines = append(lines, "  "+v.problems[i].String())
+	}
+	return errors.Annotatef(berrors.ErrInvalidArgument, "found %d problems in the config:\n%s",
+		len(v.problems), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,35 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package validation_test
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/br/pkg/validation"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type testValidationSuite struct{}
+
+var _ = Suite(&testValidationSuite{})
+
+func (s *testValidationSuite) TestValidator(c *C) {
+	v := &validation.Validator{}
+	c.Assert(v.Err(), IsNil)
+
+	v.Warn([]string{"--ratelimit", "--concurrency"}, "the concurrency is ignored", "remove --concurrency")
+	c.Assert(v.Err(), IsNil)
+	c.Assert(v.Warnings(), HasLen, 1)
+
+	v.Error([]string{"--cert"}, "the certificate is set without the private key", "")
+	c.Assert(v.Problems(), HasLen, 2)
+	c.Assert(v.Warnings(), HasLen, 1)
+	c.Assert(v.Err(), ErrorMatches, "found 2 problems in the config:\n"+
+		`  \[warning\] --ratelimit, --concurrency: the concurrency is ignored \(suggestion: remove --concurrency\)`+"\n"+
+		`  \[error\] --cert: the certificate is set without the private key: \[BR:Common:ErrInvalidArgument\]invalid argument`)
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import ExpansionPanel from '@material-ui/core/ExpansionPanel';
+import ExpansionPanelDetails from '@material-ui/core/ExpansionPanelDetails';
+import ExpansionPanelSummary from '@material-ui/core/ExpansionPanelSummary';
+import * as React from 'react';
+
+import * as api from './api';
+
+
+interface Props {
+    tableProgress: api.TableProgress
+}
+
+export default class CheckpointPanel extends React.Component<Props> {
+    render() {
+        return (
+            <ExpansionPanel>
+                <ExpansionPanelSummary>
+                    Checkpoint
+                </ExpansionPanelSummary>
+                <ExpansionPanelDetails>
+                    <pre>{JSON.stringify(this.props.tableProgress, null, 2)}</pre>
+                </ExpansionPanelDetails>
+            </ExpansionPanel>
+        );
+    }
+}
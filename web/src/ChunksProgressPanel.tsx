@@ -20,20 +20,25 @@ import TableBody from '@material-ui/core/TableBody';
 import TableCell from '@material-ui/core/TableCell';
 import TableHead from '@material-ui/core/TableHead';
 import TableRow from '@material-ui/core/TableRow';
+import * as fileSize from 'filesize';
 import * as React from 'react';
 
 import * as api from './api';
 
 
 interface Props {
-    tableProgress: api.TableProgress
+    tableEngines: api.TableEngines
 }
 
 interface Chunk {
     key: string
     engineID: number
+    offset: number
+    endOffset: number
     read: number
     total: number
+    speed: number
+    errorRows: number
 }
 
 function sortKey(chunk: Chunk): number {
@@ -50,13 +55,17 @@ function sortKey(chunk: Chunk): number {
 export default class ChunksProgressPanel extends React.Component<Props> {
     render() {
         let files: Chunk[] = [];
-        for (let engineID in this.props.tableProgress.Engines) {
-            for (const progress of this.props.tableProgress.Engines[engineID].Chunks) {
+        for (let engineID in this.props.tableEngines) {
+            for (const progress of this.props.tableEngines[engineID].chunks) {
                 files.push({
-                    key: `${progress.Key.Path}:${progress.Key.Offset}`,
+                    key: `${progress.path}:${progress['start-offset']}`,
                     engineID: +engineID,
-                    read: progress.Chunk.Offset - progress.Key.Offset,
-                    total: progress.Chunk.EndOffset - progress.Key.Offset,
+                    offset: progress.offset,
+                    endOffset: progress['end-offset'],
+                    read: progress.offset - progress['start-offset'],
+                    total: progress['end-offset'] - progress['start-offset'],
+                    speed: progress.speed,
+                    errorRows: progress['error-rows'],
                 });
             }
         }
@@ -85,6 +94,9 @@ export default class ChunksProgressPanel extends React.Component<Props> {
                             <TableRow>
                                 <TableCell>Chunk</TableCell>
                                 <TableCell>Engine</TableCell>
+                                <TableCell>Offset</TableCell>
+                                <TableCell>Speed</TableCell>
+                                <TableCell>Error Rows</TableCell>
                                 <TableCell>Progress</TableCell>
                             </TableRow>
                         </TableHead>
@@ -97,6 +109,15 @@ export default class ChunksProgressPanel extends React.Component<Props> {
                                     <TableCell>
                                         :{chunk.engineID}
                                     </TableCell>
+                                    <TableCell align='right'>
+                                        {chunk.offset} / {chunk.endOffset}
+                                    </TableCell>
+                                    <TableCell align='right'>
+                                        {chunk.speed > 0 ? `${fileSize(chunk.speed)}/s` : ''}
+                                    </TableCell>
+                                    <TableCell align='right'>
+                                        {chunk.errorRows}
+                                    </TableCell>
                                     <TableCell>
                                         <LinearProgress
                                             value={chunk.read * 100 / chunk.total}
@@ -14,11 +14,13 @@
 import ExpansionPanel from '@material-ui/core/ExpansionPanel';
 import ExpansionPanelDetails from '@material-ui/core/ExpansionPanelDetails';
 import ExpansionPanelSummary from '@material-ui/core/ExpansionPanelSummary';
+import LinearProgress from '@material-ui/core/LinearProgress';
 import Table from '@material-ui/core/Table';
 import TableBody from '@material-ui/core/TableBody';
 import TableCell from '@material-ui/core/TableCell';
 import TableHead from '@material-ui/core/TableHead';
 import TableRow from '@material-ui/core/TableRow';
+import * as fileSize from 'filesize';
 import * as React from 'react';
 
 import * as api from './api';
@@ -27,6 +29,7 @@ import DottedProgress from './DottedProgress';
 
 interface Props {
     tableProgress: api.TableProgress
+    tableEngines: api.TableEngines
 }
 
 export default class EnginesProgressPanel extends React.Component<Props> {
@@ -46,23 +49,45 @@ export default class EnginesProgressPanel extends React.Component<Props> {
                             <TableRow>
                                 <TableCell>Engine ID</TableCell>
                                 <TableCell>Status</TableCell>
+                                <TableCell>State</TableCell>
+                                <TableCell>Written</TableCell>
+                                <TableCell>Speed</TableCell>
                                 <TableCell>Files</TableCell>
                             </TableRow>
                         </TableHead>
                         <TableBody>
-                            {engines.map(([engineID, engineProgress]) => (
-                                <TableRow key={engineID}>
-                                    <TableCell component='th' scope='row'>
-                                        :{engineID}
-                                    </TableCell>
-                                    <TableCell>
-                                        <DottedProgress total={api.ENGINE_MAX_STEPS} status={engineProgress.Status} />
-                                    </TableCell>
-                                    <TableCell align='right'>
-                                        {engineProgress.Chunks.length}
-                                    </TableCell>
-                                </TableRow>
-                            ))}
+                            {engines.map(([engineID, engineProgress]) => {
+                                const engineSpeed = this.props.tableEngines[engineID];
+                                return (
+                                    <TableRow key={engineID}>
+                                        <TableCell component='th' scope='row'>
+                                            :{engineID}
+                                        </TableCell>
+                                        <TableCell>
+                                            <DottedProgress total={api.ENGINE_MAX_STEPS} status={engineProgress.Status} />
+                                        </TableCell>
+                                        <TableCell>
+                                            {api.labelOfCheckpointStatus(engineProgress.Status)}
+                                        </TableCell>
+                                        <TableCell title={engineSpeed && `${fileSize(engineSpeed.written)} / ${fileSize(engineSpeed.total)}`}>
+                                            {engineSpeed && engineSpeed.total > 0 &&
+                                                <LinearProgress
+                                                    value={engineSpeed.written * 100 / engineSpeed.total}
+                                                    variant='determinate'
+                                                />
+                                            }
+                                        </TableCell>
+                                        <TableCell align='right'>
+                                            {engineSpeed ? `${fileSize(engineSpeed.speed)}/s` : ''}
+                                        </TableCell>
+                                        <TableCell align='right'>
+                                            {engineSpeed ?
+                                                `${engineSpeed['finished-chunks']} / ${engineProgress.Chunks.length}` :
+                                                engineProgress.Chunks.length}
+                                        </TableCell>
+                                    </TableRow>
+                                );
+                            })}
                         </TableBody>
                     </Table>
                 </ExpansionPanelDetails>
@@ -17,6 +17,7 @@ import Typography from '@material-ui/core/Typography';
 import * as React from 'react';
 
 import * as api from './api';
+import CheckpointPanel from './CheckpointPanel';
 import ChunksProgressPanel from './ChunksProgressPanel';
 import DottedProgress from './DottedProgress';
 import EnginesProgressPanel from './EnginesProgressPanel';
@@ -32,11 +33,27 @@ const styles = (theme: Theme) => createStyles({
     tableDottedProgress: {
         width: 360,
     },
+    duplicateDetection: {
+        marginBottom: theme.spacing(2),
+    },
 });
 
+function labelOfDuplicateDetection(info: api.DuplicateDetectionInfo): string {
+    switch (info.s) {
+        case api.TaskStatus.Running:
+            return `collecting ${info.p} duplicates`;
+        case api.TaskStatus.Completed:
+            return info.m ? `collecting ${info.p} duplicates failed: ${info.m}` : `${info.p} duplicates collected`;
+        default:
+            return 'not started';
+    }
+}
+
 interface Props extends WithStyles<typeof styles> {
     tableName: string
     tableProgress: api.TableProgress
+    tableEngines: api.TableEngines
+    tableInfo?: api.TableInfo
     onChangeActiveTableProgress: (tableName?: string) => void
 }
 
@@ -63,8 +80,15 @@ class TableProgressPage extends React.Component<Props> {
                     </Grid>
                 </Grid>
 
-                <EnginesProgressPanel tableProgress={this.props.tableProgress} />
-                <ChunksProgressPanel tableProgress={this.props.tableProgress} />
+                {this.props.tableInfo && this.props.tableInfo.d &&
+                    <Typography variant='subtitle1' className={classes.duplicateDetection}>
+                        Duplicate detection: {labelOfDuplicateDetection(this.props.tableInfo.d)}
+                    </Typography>
+                }
+
+                <EnginesProgressPanel tableProgress={this.props.tableProgress} tableEngines={this.props.tableEngines} />
+                <ChunksProgressPanel tableEngines={this.props.tableEngines} />
+                <CheckpointPanel tableProgress={this.props.tableProgress} />
             </div>
         )
     }
@@ -51,11 +51,18 @@ export enum CheckpointStatus {
     AnalyzeErrored = 21,
 }
 
+export interface DuplicateDetectionInfo {
+    p: 'local' | 'remote'
+    s: TaskStatus
+    m?: string
+}
+
 export interface TableInfo {
     w: number
     z: number
     s: TaskStatus
     m?: string
+    d?: DuplicateDetectionInfo
 }
 
 export interface TaskProgress {
@@ -66,6 +73,7 @@ export interface TaskProgress {
 
 export interface TaskQueue {
     current: TaskID | null
+    running?: TaskID[]
     queue: TaskID[]
 }
 
@@ -99,6 +107,28 @@ export interface TableProgress {
     Engines: { [engineID: string]: EngineProgress }
 }
 
+export interface ChunkSpeed {
+    path: string
+    'start-offset': number
+    offset: number
+    'end-offset': number
+    'error-rows': number
+    speed: number
+}
+
+export interface EngineSpeed {
+    status: CheckpointStatus
+    written: number
+    total: number
+    speed: number
+    'finished-chunks': number
+    chunks: ChunkSpeed[]
+}
+
+export type TableEngines = { [engineID: string]: EngineSpeed };
+
+export const EMPTY_TABLE_ENGINES: TableEngines = {};
+
 export const EMPTY_TABLE_PROGRESS: TableProgress = {
     Status: CheckpointStatus.Missing,
     AllocBase: 0,
@@ -266,3 +296,13 @@ export async function fetchTableProgress(tableName: string): Promise<TableProgre
         throw res.error;
     }
 }
+
+export async function fetchTableEngines(tableName: string): Promise<TableEngines> {
+    const resp = await fetch('../progress/engines?t=' + encodeURIComponent(tableName))
+    let res = await resp.json();
+    if (resp.ok) {
+        return res;
+    } else {
+        throw res.error;
+    }
+}
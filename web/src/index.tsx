@@ -37,6 +37,7 @@ interface State {
     hasActiveTableName: boolean,
     activeTableName: string,
     activeTableProgress: api.TableProgress,
+    activeTableEngines: api.TableEngines,
     paused: boolean,
 }
 
@@ -57,12 +58,13 @@ class App extends React.Component<Props, State> {
             hasActiveTableName: false,
             activeTableName: '',
             activeTableProgress: api.EMPTY_TABLE_PROGRESS,
+            activeTableEngines: api.EMPTY_TABLE_ENGINES,
             paused: false,
         };
     }
 
     handleRefresh = async () => {
-        const [taskQueue, taskProgress, paused, activeTableProgress] = await Promise.all([
+        const [taskQueue, taskProgress, paused, activeTableProgress, activeTableEngines] = await Promise.all([
             api.fetchTaskQueue(),
             api.fetchTaskProgress(),
             api.fetchPaused(),
@@ -72,8 +74,11 @@ class App extends React.Component<Props, State> {
             this.state.hasActiveTableName ?
                 api.fetchTableProgress(this.state.activeTableName).catch(() => api.EMPTY_TABLE_PROGRESS) :
                 Promise.resolve(api.EMPTY_TABLE_PROGRESS),
+            this.state.hasActiveTableName ?
+                api.fetchTableEngines(this.state.activeTableName).catch(() => api.EMPTY_TABLE_ENGINES) :
+                Promise.resolve(api.EMPTY_TABLE_ENGINES),
         ]);
-        this.setState({ taskQueue, taskProgress, paused, activeTableProgress });
+        this.setState({ taskQueue, taskProgress, paused, activeTableProgress, activeTableEngines });
     }
 
     handleTogglePaused = () => {
@@ -118,11 +123,15 @@ class App extends React.Component<Props, State> {
                 if (!shouldRefresh || !tableName) {
                     return;
                 }
-                const tableProgress = await api.fetchTableProgress(tableName);
+                const [tableProgress, tableEngines] = await Promise.all([
+                    api.fetchTableProgress(tableName),
+                    api.fetchTableEngines(tableName).catch(() => api.EMPTY_TABLE_ENGINES),
+                ]);
                 this.setState({
                     hasActiveTableName: true,
                     activeTableName: tableName,
                     activeTableProgress: tableProgress,
+                    activeTableEngines: tableEngines,
                 });
             },
         );
@@ -162,6 +171,8 @@ class App extends React.Component<Props, State> {
                             {({ location }) => <TableProgressPage
                                 tableName={decodeURIComponent(location.search.substr(3))}
                                 tableProgress={this.state.activeTableProgress}
+                                tableEngines={this.state.activeTableEngines}
+                                tableInfo={this.state.taskProgress.t[decodeURIComponent(location.search.substr(3))]}
                                 onChangeActiveTableProgress={this.handleChangeActiveTableProgress}
                             />}
                         </Route>